@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/WuKongIM/WuKongIM/pkg/auth"
+	"github.com/spf13/cobra"
+)
+
+type passwdCMD struct {
+	ctx *WuKongIMContext
+}
+
+func newPasswdCMD(ctx *WuKongIMContext) *passwdCMD {
+	return &passwdCMD{
+		ctx: ctx,
+	}
+}
+
+func (p *passwdCMD) CMD() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "passwd [password]",
+		Short: "generate the bcrypt hash of the password for auth.users",
+		RunE:  p.run,
+	}
+	return cmd
+}
+
+func (p *passwdCMD) run(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 || args[0] == "" {
+		return errors.New("password is required")
+	}
+	hash, err := auth.HashPassword(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Println(hash)
+	return nil
+}
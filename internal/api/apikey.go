@@ -0,0 +1,267 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/WuKongIM/WuKongIM/internal/ingress"
+	"github.com/WuKongIM/WuKongIM/internal/options"
+	"github.com/WuKongIM/WuKongIM/internal/service"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/WuKongIM/WuKongIM/pkg/wkhttp"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// api key的请求头
+const apiKeyHeader = "X-Api-Key"
+
+// 认证通过后api key名称在上下文中的key
+const apiKeyNameContextKey = "apiKeyName"
+
+// 接口对应的权限范围，没有配置的接口按照 资源:read（GET请求） 资源:write（其他请求）判断，资源为路径的第一段
+var apiScopes = map[string]string{
+	"/message/send":              "message:send",
+	"/message/sendbatch":         "message:send",
+	"/stream/start":              "message:send",
+	"/stream/end":                "message:send",
+	"/message":                   "message:read",
+	"/messages":                  "message:read",
+	"/message/sync":              "message:read",
+	"/message/syncack":           "message:read",
+	"/channel/messagesync":       "message:read",
+	"/user/token":                "user:token",
+	"/user/onlinestatus":         "user:read",
+	"/user/lastseen":             "user:read",
+	"/route/batch":               "route:read",
+	"/conversation/sync":         "conversation:read",
+	"/conversation/syncMessages": "conversation:read",
+}
+
+// 不需要认证的接口
+var apiNoAuthPaths = map[string]struct{}{
+	"/health": {},
+}
+
+type apiKey struct {
+	s             *Server
+	ingressClient *ingress.Client
+	wklog.Log
+}
+
+func newApiKey(s *Server) *apiKey {
+	return &apiKey{
+		s:             s,
+		ingressClient: ingress.NewClient(),
+		Log:           wklog.NewWKLog("apiKey"),
+	}
+}
+
+// route route
+func (a *apiKey) route(r *wkhttp.WKHttp) {
+	r.GET("/apikey/list", a.list)      // 获取api key列表
+	r.POST("/apikey/add", a.add)       // 创建api key
+	r.POST("/apikey/remove", a.remove) // 移除api key
+}
+
+func (a *apiKey) list(c *wkhttp.Context) {
+	var slotId uint32 = 0 // api key默认存储在slot 0上
+	nodeInfo := service.Cluster.SlotLeaderNodeInfo(slotId)
+	if nodeInfo == nil {
+		a.Error("获取slot所在节点失败！", zap.Uint32("slotId", slotId))
+		c.ResponseError(errors.New("获取slot所在节点失败！"))
+		return
+	}
+	var (
+		apiKeys []wkdb.ApiKey
+		err     error
+	)
+	if nodeInfo.Id == options.G.Cluster.NodeId {
+		apiKeys, err = service.Store.GetApiKeys()
+	} else {
+		apiKeys, err = a.ingressClient.GetApiKeys(nodeInfo.Id)
+	}
+	if err != nil {
+		a.Error("获取api key失败！", zap.Error(err))
+		c.ResponseError(errors.New("获取api key失败！"))
+		return
+	}
+	if apiKeys == nil {
+		apiKeys = make([]wkdb.ApiKey, 0)
+	}
+	c.JSON(http.StatusOK, apiKeys)
+}
+
+func (a *apiKey) add(c *wkhttp.Context) {
+	var req struct {
+		Name   string   `json:"name"`   // 名称
+		Scopes []string `json:"scopes"` // 权限范围 比如 message:send channel:write user:token
+	}
+	if _, err := BindJSON(&req, c); err != nil {
+		a.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		c.ResponseError(errors.New("name不能为空！"))
+		return
+	}
+	if len(req.Scopes) == 0 {
+		c.ResponseError(errors.New("scopes不能为空！"))
+		return
+	}
+
+	var slotId uint32 = 0 // api key默认存储在slot 0上
+	nodeInfo := service.Cluster.SlotLeaderNodeInfo(slotId)
+	if nodeInfo == nil {
+		a.Error("槽的领导节点不存在", zap.Uint32("slotId", slotId))
+		c.ResponseError(errors.New("槽的领导节点不存在"))
+		return
+	}
+
+	// 在slot 0的领导节点上创建
+	var (
+		apiKey wkdb.ApiKey
+		key    string
+		err    error
+	)
+	if nodeInfo.Id == options.G.Cluster.NodeId {
+		apiKey, key, err = service.ApiKeyManager.AddApiKey(req.Name, req.Scopes)
+	} else {
+		var resp *ingress.ApiKeyAddResp
+		resp, err = a.ingressClient.AddApiKey(nodeInfo.Id, &ingress.ApiKeyAddReq{Name: req.Name, Scopes: req.Scopes})
+		if resp != nil {
+			apiKey, key = resp.ApiKey, resp.Key
+		}
+	}
+	if err != nil {
+		a.Error("创建api key失败！", zap.Error(err))
+		c.ResponseError(errors.New("创建api key失败！"))
+		return
+	}
+
+	// 通知各个节点重新加载api key
+	err = a.reloadApiKeys(nodeInfo.Id)
+	if err != nil {
+		a.Error("添加api key到缓存失败！", zap.Error(err))
+		c.ResponseError(errors.New("添加api key到缓存失败！"))
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"id":     apiKey.Id,
+		"name":   apiKey.Name,
+		"key":    key, // key只在创建时返回一次
+		"scopes": apiKey.Scopes,
+	})
+}
+
+func (a *apiKey) remove(c *wkhttp.Context) {
+	var req struct {
+		Id uint64 `json:"id"`
+	}
+	if _, err := BindJSON(&req, c); err != nil {
+		a.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	if req.Id == 0 {
+		c.ResponseError(errors.New("id不能为空！"))
+		return
+	}
+
+	var slotId uint32 = 0 // api key默认存储在slot 0上
+	nodeInfo := service.Cluster.SlotLeaderNodeInfo(slotId)
+	if nodeInfo == nil {
+		a.Error("槽的领导节点不存在", zap.Uint32("slotId", slotId))
+		c.ResponseError(errors.New("槽的领导节点不存在"))
+		return
+	}
+
+	// 在slot 0的领导节点上移除
+	var err error
+	if nodeInfo.Id == options.G.Cluster.NodeId {
+		err = service.ApiKeyManager.RemoveApiKey(req.Id)
+	} else {
+		err = a.ingressClient.RemoveApiKey(nodeInfo.Id, req.Id)
+	}
+	if err != nil {
+		a.Error("移除api key失败！", zap.Error(err))
+		c.ResponseError(errors.New("移除api key失败！"))
+		return
+	}
+
+	// 通知各个节点重新加载api key
+	err = a.reloadApiKeys(nodeInfo.Id)
+	if err != nil {
+		a.Error("从缓存中移除api key失败！", zap.Error(err))
+		c.ResponseError(errors.New("从缓存中移除api key失败！"))
+		return
+	}
+	c.ResponseOK()
+}
+
+// 通知其他节点重新加载api key，当前节点不是slot 0的领导节点时也重新加载（领导节点创建和移除时已经更新了自己的缓存）
+func (a *apiKey) reloadApiKeys(leaderId uint64) error {
+	if leaderId != options.G.Cluster.NodeId {
+		if err := service.ApiKeyManager.Reload(); err != nil {
+			return err
+		}
+	}
+	return notifyNodes(a.ingressClient.ReloadApiKeys)
+}
+
+// api key认证中间件（携带managerToken的请求拥有所有权限）
+func apiKeyAuthMiddleware() wkhttp.HandlerFunc {
+	return func(c *wkhttp.Context) {
+		if _, ok := apiNoAuthPaths[c.Request.URL.Path]; ok {
+			c.Next()
+			return
+		}
+		managerToken := c.GetHeader("token")
+		if managerToken != "" && managerToken == options.G.ManagerToken {
+			c.Next()
+			return
+		}
+		key := c.GetHeader(apiKeyHeader)
+		if key == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"msg":    "api key is required",
+				"status": http.StatusUnauthorized,
+			})
+			return
+		}
+		scope := apiScope(c.Request.Method, c.Request.URL.Path)
+		apiKey, err := service.ApiKeyManager.Verify(key, scope)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"msg":    fmt.Sprintf("%s: %s", err.Error(), scope),
+				"status": http.StatusForbidden,
+			})
+			return
+		}
+		c.Set(apiKeyNameContextKey, apiKey.Name)
+		c.Next()
+	}
+}
+
+// 获取接口需要的权限范围
+func apiScope(method string, path string) string {
+	if scope, ok := apiScopes[path]; ok {
+		return scope
+	}
+	resource := strings.Split(strings.TrimPrefix(path, "/"), "/")[0]
+	switch resource {
+	case "conversations":
+		resource = "conversation"
+	case "tmpchannel":
+		resource = "channel"
+	}
+	if method == http.MethodGet {
+		return resource + ":read"
+	}
+	return resource + ":write"
+}
@@ -0,0 +1,312 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/internal/options"
+	"github.com/WuKongIM/WuKongIM/internal/service"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/WuKongIM/WuKongIM/pkg/wkhttp"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	"go.uber.org/zap"
+)
+
+// 审计日志的操作者类型
+const (
+	auditActorUser      = "user"      // 管理后台用户
+	auditActorApiKey    = "apikey"    // api key
+	auditActorManager   = "manager"   // 管理者token
+	auditActorAnonymous = "anonymous" // 匿名
+)
+
+// 不记录审计日志的POST接口（高频的消息发送接口、查询类接口和登录接口）
+var auditSkipPaths = map[string]struct{}{
+	"/message/send":              {},
+	"/message/sendbatch":         {},
+	"/stream/start":              {},
+	"/stream/end":                {},
+	"/message":                   {},
+	"/messages":                  {},
+	"/message/sync":              {},
+	"/message/syncack":           {},
+	"/channel/messagesync":       {},
+	"/conversation/sync":         {},
+	"/conversation/syncMessages": {},
+	"/user/onlinestatus":         {},
+	"/user/lastseen":             {},
+	"/route/batch":               {},
+	"/stress/report":             {},
+	"/manager/login":             {},
+	"/manager/refresh":           {},
+	"/cluster/channel/status":    {},
+}
+
+const (
+	auditLogQueueSize      = 1024 // 待写入的审计日志队列大小，队列满时请求会等待
+	auditLogFlushInterval  = time.Millisecond * 200
+	auditLogMaxBatch       = 100   // 积累到这个数量立即写入
+	auditParamsMaxLength   = 2048  // 记录的请求参数最大长度，超过会截断
+	auditParamsRedactValue = "***" // 敏感字段脱敏后的值
+)
+
+// 需要脱敏的参数名（小写），以password、token、secret结尾的参数也会脱敏
+var auditSensitiveParams = map[string]struct{}{
+	"key":         {},
+	"api_key":     {},
+	"apikey":      {},
+	"private_key": {},
+	"client_key":  {},
+}
+
+type auditLog struct {
+	s     *Server
+	logC  chan wkdb.AuditLog
+	stopC chan struct{}
+	doneC chan struct{}
+	wklog.Log
+}
+
+func newAuditLog(s *Server) *auditLog {
+	return &auditLog{
+		s:     s,
+		logC:  make(chan wkdb.AuditLog, auditLogQueueSize),
+		stopC: make(chan struct{}),
+		doneC: make(chan struct{}),
+		Log:   wklog.NewWKLog("auditLog"),
+	}
+}
+
+// 审计日志批量写入（通过slot复制到副本节点），定时删除超过保留时间的审计日志
+func (a *auditLog) start() {
+	if !options.G.AuditLog.On {
+		close(a.doneC)
+		return
+	}
+	go a.loopWrite()
+	if options.G.AuditLog.Retention <= 0 {
+		return
+	}
+	a.s.Schedule(time.Hour, func() {
+		err := service.Store.RemoveAuditLogsBefore(time.Now().Add(-options.G.AuditLog.Retention))
+		if err != nil {
+			a.Error("remove expired audit logs failed", zap.Error(err))
+		}
+	})
+}
+
+// 停止前写入队列里剩余的审计日志
+func (a *auditLog) stop() {
+	close(a.stopC)
+	<-a.doneC
+}
+
+func (a *auditLog) loopWrite() {
+	defer close(a.doneC)
+	tk := time.NewTicker(auditLogFlushInterval)
+	defer tk.Stop()
+	logs := make([]wkdb.AuditLog, 0, auditLogMaxBatch)
+	flush := func() {
+		if len(logs) == 0 {
+			return
+		}
+		if err := service.Store.AppendAuditLogs(logs); err != nil {
+			a.Error("append audit logs failed", zap.Error(err), zap.Int("count", len(logs)))
+		}
+		logs = make([]wkdb.AuditLog, 0, auditLogMaxBatch)
+	}
+	for {
+		select {
+		case log := <-a.logC:
+			logs = append(logs, log)
+			if len(logs) >= auditLogMaxBatch {
+				flush()
+			}
+		case <-tk.C:
+			flush()
+		case <-a.stopC:
+			for {
+				select {
+				case log := <-a.logC:
+					logs = append(logs, log)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// 审计日志中间件，记录修改类的api调用（需要放在认证中间件之前，这样认证失败的请求也会被记录）
+func (a *auditLog) middleware() wkhttp.HandlerFunc {
+	return func(c *wkhttp.Context) {
+		if !options.G.AuditLog.On || !needAudit(c) {
+			c.Next()
+			return
+		}
+
+		params, err := auditParams(c)
+		if err != nil {
+			a.Warn("read request body failed", zap.Error(err), zap.String("path", c.Request.URL.Path))
+		}
+
+		c.Next()
+
+		actor, actorType := auditActor(c)
+		createdAt := time.Now()
+		log := wkdb.AuditLog{
+			Id:        uint64(options.G.GenMessageId()),
+			Actor:     actor,
+			ActorType: actorType,
+			Ip:        c.ClientIP(),
+			Method:    c.Request.Method,
+			Path:      c.Request.URL.Path,
+			Resource:  auditResource(c.Request.URL.Path),
+			Params:    params,
+			Status:    c.Writer.Status(),
+			NodeId:    options.G.Cluster.NodeId,
+			CreatedAt: &createdAt,
+		}
+		select {
+		case a.logC <- log:
+		case <-a.stopC:
+			a.Warn("audit log is stopped, drop log", zap.String("path", log.Path))
+		}
+	}
+}
+
+// 是否需要记录审计日志
+func needAudit(c *wkhttp.Context) bool {
+	if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodOptions || c.Request.Method == http.MethodHead {
+		return false
+	}
+	path := c.Request.URL.Path
+	if _, ok := auditSkipPaths[path]; ok {
+		return false
+	}
+	// 节点内部同步缓存的接口
+	if strings.HasSuffix(path, "_to_cache") || strings.HasSuffix(path, "_from_cache") {
+		return false
+	}
+	// 节点之间转发的请求已经在接收请求的节点上记录过了
+	if c.GetHeader(wkhttp.HeaderForwarded) != "" && isManagerTokenRequest(c) {
+		return false
+	}
+	return true
+}
+
+// 是否是携带管理者token的请求
+func isManagerTokenRequest(c *wkhttp.Context) bool {
+	token := c.GetHeader("token")
+	return strings.TrimSpace(options.G.ManagerToken) != "" && token == options.G.ManagerToken
+}
+
+// 获取请求的操作者
+func auditActor(c *wkhttp.Context) (string, string) {
+	if apiKeyName := c.GetString(apiKeyNameContextKey); apiKeyName != "" {
+		return apiKeyName, auditActorApiKey
+	}
+	username := c.Username()
+	if username == options.G.ManagerUID || (username == "" && isManagerTokenRequest(c)) {
+		return options.G.ManagerUID, auditActorManager
+	}
+	if username != "" {
+		return username, auditActorUser
+	}
+	return "", auditActorAnonymous
+}
+
+// 脱敏后的请求参数（query和body），读取后会重新设置body以便后续的处理
+// json格式的body按字段脱敏，其他格式的body只记录长度
+func auditParams(c *wkhttp.Context) (string, error) {
+	var body []byte
+	if c.Request.Body != nil {
+		var err error
+		body, err = io.ReadAll(c.Request.Body)
+		if err != nil {
+			return "", err
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	var b strings.Builder
+	if c.Request.URL.RawQuery != "" {
+		query := c.Request.URL.Query()
+		for name := range query {
+			if isAuditSensitiveParam(name) {
+				query.Set(name, auditParamsRedactValue)
+			}
+		}
+		b.WriteString("?")
+		b.WriteString(query.Encode())
+	}
+	if len(bytes.TrimSpace(body)) > 0 {
+		if b.Len() > 0 {
+			b.WriteString(" ")
+		}
+		var v interface{}
+		if err := json.Unmarshal(body, &v); err == nil {
+			b.WriteString(wkutil.ToJSON(redactAuditValue(v)))
+		} else {
+			b.WriteString(fmt.Sprintf("<%d bytes>", len(body)))
+		}
+	}
+	params := b.String()
+	if len(params) > auditParamsMaxLength {
+		params = params[:auditParamsMaxLength] + "...(truncated)"
+	}
+	return params, nil
+}
+
+// 递归脱敏json里的敏感字段
+func redactAuditValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			if isAuditSensitiveParam(k) {
+				val[k] = auditParamsRedactValue
+				continue
+			}
+			val[k] = redactAuditValue(item)
+		}
+		return val
+	case []interface{}:
+		for i, item := range val {
+			val[i] = redactAuditValue(item)
+		}
+		return val
+	}
+	return v
+}
+
+func isAuditSensitiveParam(name string) bool {
+	name = strings.ToLower(name)
+	if _, ok := auditSensitiveParams[name]; ok {
+		return true
+	}
+	return strings.HasSuffix(name, "password") || strings.HasSuffix(name, "token") || strings.HasSuffix(name, "secret")
+}
+
+// 请求的资源 比如 /channel/delete -> channel /cluster/slots/1/migrate -> slot
+func auditResource(path string) string {
+	path = strings.TrimPrefix(path, "/cluster")
+	resource := strings.Split(strings.TrimPrefix(path, "/"), "/")[0]
+	switch resource {
+	case "channels", "tmpchannel":
+		return "channel"
+	case "conversations":
+		return "conversation"
+	case "slots":
+		return "slot"
+	case "nodes":
+		return "node"
+	}
+	return resource
+}
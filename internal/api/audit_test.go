@@ -0,0 +1,62 @@
+package api
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkhttp"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditParams(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		body string
+		want string
+	}{
+		{
+			name: "json body",
+			url:  "/user/token",
+			body: `{"uid":"u1","token":"t1","device_flag":0}`,
+			want: `{"device_flag":0,"token":"***","uid":"u1"}`,
+		},
+		{
+			name: "nested",
+			url:  "/apikey/add",
+			body: `{"users":[{"name":"a","Password":"p"}],"opts":{"manager_token":"x","api_key":"k"}}`,
+			want: `{"opts":{"api_key":"***","manager_token":"***"},"users":[{"Password":"***","name":"a"}]}`,
+		},
+		{
+			name: "query",
+			url:  "/channel/delete?channel_id=c1&secret=s",
+			want: "?channel_id=c1&secret=%2A%2A%2A",
+		},
+		{
+			name: "not json",
+			url:  "/upload",
+			body: "hello",
+			want: "<5 bytes>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &wkhttp.Context{Context: &gin.Context{}}
+			c.Request = httptest.NewRequest("POST", tt.url, strings.NewReader(tt.body))
+			params, err := auditParams(c)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, params)
+		})
+	}
+}
+
+func TestAuditParamsTruncate(t *testing.T) {
+	c := &wkhttp.Context{Context: &gin.Context{}}
+	c.Request = httptest.NewRequest("POST", "/tag/remove", strings.NewReader(`{"data":"`+strings.Repeat("a", auditParamsMaxLength)+`"}`))
+	params, err := auditParams(c)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasSuffix(params, "...(truncated)"))
+	assert.Equal(t, auditParamsMaxLength+len("...(truncated)"), len(params))
+}
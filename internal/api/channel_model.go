@@ -12,24 +12,28 @@ import (
 
 // ChannelInfoReq ChannelInfoReq
 type channelInfoReq struct {
-	ChannelID   string `json:"channel_id"`   // 频道ID
-	ChannelType uint8  `json:"channel_type"` // 频道类型
-	Large       int    `json:"large"`        // 是否是超大群
-	Ban         int    `json:"ban"`          // 是否封禁频道（封禁后此频道所有人都将不能发消息，除了系统账号）
-	Disband     int    `json:"disband"`      // 是否解散频道
+	ChannelID     string `json:"channel_id"`      // 频道ID
+	ChannelType   uint8  `json:"channel_type"`    // 频道类型
+	Large         int    `json:"large"`           // 是否是超大群
+	Ban           int    `json:"ban"`             // 是否封禁频道（封禁后此频道所有人都将不能发消息，除了系统账号）
+	Disband       int    `json:"disband"`         // 是否解散频道
+	SendRateLimit uint32 `json:"send_rate_limit"` // 频道每秒允许发送的消息数量（所有成员共享），0表示使用全局配置
+	SlowMode      uint32 `json:"slow_mode"`       // 慢速模式，每个成员每多少秒只能发送一条消息，0表示使用全局配置
 }
 
 func (c channelInfoReq) ToChannelInfo() wkdb.ChannelInfo {
 	createdAt := time.Now()
 	updatedAt := time.Now()
 	return wkdb.ChannelInfo{
-		ChannelId:   c.ChannelID,
-		ChannelType: c.ChannelType,
-		Large:       c.Large == 1,
-		Ban:         c.Ban == 1,
-		Disband:     c.Disband == 1,
-		CreatedAt:   &createdAt,
-		UpdatedAt:   &updatedAt,
+		ChannelId:     c.ChannelID,
+		ChannelType:   c.ChannelType,
+		Large:         c.Large == 1,
+		Ban:           c.Ban == 1,
+		Disband:       c.Disband == 1,
+		SendRateLimit: c.SendRateLimit,
+		SlowMode:      c.SlowMode,
+		CreatedAt:     &createdAt,
+		UpdatedAt:     &updatedAt,
 	}
 }
 
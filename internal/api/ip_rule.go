@@ -0,0 +1,220 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/WuKongIM/WuKongIM/internal/ingress"
+	"github.com/WuKongIM/WuKongIM/internal/options"
+	"github.com/WuKongIM/WuKongIM/internal/service"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/WuKongIM/WuKongIM/pkg/wkhttp"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"go.uber.org/zap"
+)
+
+// 连接的ip规则（黑白名单）管理
+// 只在管理api上提供，规则的读写在slot 0的领导节点上执行，请求领导节点和节点之间的缓存同步都通过节点rpc（ingress）完成
+type ipRule struct {
+	s             *Server
+	ingressClient *ingress.Client
+	wklog.Log
+}
+
+func newIpRule(s *Server) *ipRule {
+	return &ipRule{
+		s:             s,
+		ingressClient: ingress.NewClient(),
+		Log:           wklog.NewWKLog("ipRule"),
+	}
+}
+
+// route route
+func (i *ipRule) route(r *wkhttp.WKHttp) {
+	r.GET("/iprule/list", i.list)      // 获取ip规则列表
+	r.POST("/iprule/add", i.add)       // 添加ip规则
+	r.POST("/iprule/remove", i.remove) // 移除ip规则
+	r.GET("/iprule/bans", i.bans)      // 获取当前节点自动封禁的ip
+	r.POST("/iprule/unban", i.unban)   // 解封自动封禁的ip（所有节点）
+}
+
+func (i *ipRule) list(c *wkhttp.Context) {
+	var slotId uint32 = 0 // ip规则默认存储在slot 0上
+	nodeInfo := service.Cluster.SlotLeaderNodeInfo(slotId)
+	if nodeInfo == nil {
+		i.Error("获取slot所在节点失败！", zap.Uint32("slotId", slotId))
+		c.ResponseError(errors.New("获取slot所在节点失败！"))
+		return
+	}
+	var (
+		rules []wkdb.IpRule
+		err   error
+	)
+	if nodeInfo.Id == options.G.Cluster.NodeId {
+		rules, err = service.Store.GetIpRules()
+	} else {
+		rules, err = i.ingressClient.GetIpRules(nodeInfo.Id)
+	}
+	if err != nil {
+		i.Error("获取ip规则失败！", zap.Error(err))
+		c.ResponseError(errors.New("获取ip规则失败！"))
+		return
+	}
+	if rules == nil {
+		rules = make([]wkdb.IpRule, 0)
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+func (i *ipRule) add(c *wkhttp.Context) {
+	var req struct {
+		Cidr   string `json:"cidr"`   // ip段 比如 192.168.1.0/24 或者单个ip
+		Action uint8  `json:"action"` // 动作 1.白名单 2.黑名单
+		Remark string `json:"remark"` // 备注
+	}
+	if _, err := BindJSON(&req, c); err != nil {
+		i.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	if strings.TrimSpace(req.Cidr) == "" {
+		c.ResponseError(errors.New("cidr不能为空！"))
+		return
+	}
+	action := wkdb.IpRuleAction(req.Action)
+	if action != wkdb.IpRuleAllow && action != wkdb.IpRuleDeny {
+		c.ResponseError(errors.New("action只能为1（白名单）或2（黑名单）！"))
+		return
+	}
+
+	var slotId uint32 = 0 // ip规则默认存储在slot 0上
+	nodeInfo := service.Cluster.SlotLeaderNodeInfo(slotId)
+	if nodeInfo == nil {
+		i.Error("槽的领导节点不存在", zap.Uint32("slotId", slotId))
+		c.ResponseError(errors.New("槽的领导节点不存在"))
+		return
+	}
+
+	// 在slot 0的领导节点上添加
+	var (
+		rule wkdb.IpRule
+		err  error
+	)
+	if nodeInfo.Id == options.G.Cluster.NodeId {
+		rule, err = service.AdmissionManager.AddIpRule(req.Cidr, action, req.Remark)
+	} else {
+		rule, err = i.ingressClient.AddIpRule(nodeInfo.Id, &ingress.IpRuleAddReq{Cidr: req.Cidr, Action: action, Remark: req.Remark})
+	}
+	if err != nil {
+		i.Error("添加ip规则失败！", zap.Error(err), zap.String("cidr", req.Cidr))
+		c.ResponseError(fmt.Errorf("添加ip规则失败！%s", err.Error()))
+		return
+	}
+
+	// 通知各个节点重新加载ip规则
+	err = i.reloadIpRules(nodeInfo.Id)
+	if err != nil {
+		i.Error("通知节点重新加载ip规则失败！", zap.Error(err))
+		c.ResponseError(errors.New("通知节点重新加载ip规则失败！"))
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+func (i *ipRule) remove(c *wkhttp.Context) {
+	var req struct {
+		Id uint64 `json:"id"`
+	}
+	if _, err := BindJSON(&req, c); err != nil {
+		i.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	if req.Id == 0 {
+		c.ResponseError(errors.New("id不能为空！"))
+		return
+	}
+
+	var slotId uint32 = 0 // ip规则默认存储在slot 0上
+	nodeInfo := service.Cluster.SlotLeaderNodeInfo(slotId)
+	if nodeInfo == nil {
+		i.Error("槽的领导节点不存在", zap.Uint32("slotId", slotId))
+		c.ResponseError(errors.New("槽的领导节点不存在"))
+		return
+	}
+
+	// 在slot 0的领导节点上移除
+	var err error
+	if nodeInfo.Id == options.G.Cluster.NodeId {
+		err = service.AdmissionManager.RemoveIpRule(req.Id)
+	} else {
+		err = i.ingressClient.RemoveIpRule(nodeInfo.Id, req.Id)
+	}
+	if err != nil {
+		i.Error("移除ip规则失败！", zap.Error(err))
+		c.ResponseError(errors.New("移除ip规则失败！"))
+		return
+	}
+
+	// 通知各个节点重新加载ip规则
+	err = i.reloadIpRules(nodeInfo.Id)
+	if err != nil {
+		i.Error("通知节点重新加载ip规则失败！", zap.Error(err))
+		c.ResponseError(errors.New("通知节点重新加载ip规则失败！"))
+		return
+	}
+	c.ResponseOK()
+}
+
+func (i *ipRule) bans(c *wkhttp.Context) {
+	bans := service.AdmissionManager.Bans()
+	resps := make([]map[string]interface{}, 0, len(bans))
+	for ip, expireAt := range bans {
+		resps = append(resps, map[string]interface{}{
+			"ip":        ip,
+			"expire_at": expireAt,
+		})
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"node_id": options.G.Cluster.NodeId,
+		"bans":    resps,
+	})
+}
+
+func (i *ipRule) unban(c *wkhttp.Context) {
+	var req struct {
+		Ip string `json:"ip"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		i.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	if strings.TrimSpace(req.Ip) == "" {
+		c.ResponseError(errors.New("ip不能为空！"))
+		return
+	}
+	service.AdmissionManager.Unban(req.Ip)
+
+	err := notifyNodes(func(nodeId uint64) error {
+		return i.ingressClient.UnbanIp(nodeId, req.Ip)
+	})
+	if err != nil {
+		i.Error("解封ip失败！", zap.Error(err))
+		c.ResponseError(errors.New("解封ip失败！"))
+		return
+	}
+	c.ResponseOK()
+}
+
+// 通知其他节点重新加载ip规则，当前节点不是slot 0的领导节点时也重新加载（领导节点添加和移除时已经更新了自己的缓存）
+func (i *ipRule) reloadIpRules(leaderId uint64) error {
+	if leaderId != options.G.Cluster.NodeId {
+		if err := service.AdmissionManager.ReloadIpRules(); err != nil {
+			return err
+		}
+	}
+	return notifyNodes(i.ingressClient.ReloadIpRules)
+}
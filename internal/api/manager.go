@@ -7,13 +7,22 @@ import (
 	"time"
 
 	"github.com/WuKongIM/WuKongIM/internal/options"
+	"github.com/WuKongIM/WuKongIM/internal/service"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
 	"github.com/WuKongIM/WuKongIM/pkg/wkhttp"
 	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"go.uber.org/zap"
 )
 
+// 管理后台jwt的类型
+const (
+	jwtTypeAccess  = "access"  // 访问token
+	jwtTypeRefresh = "refresh" // 刷新token
+)
+
 type manager struct {
 	s *Server
 	wklog.Log
@@ -29,8 +38,9 @@ func newManager(s *Server) *manager {
 // route route
 func (m *manager) route(r *wkhttp.WKHttp) {
 
-	r.POST("/manager/login", m.login) // 登录
-
+	r.POST("/manager/login", m.login)     // 登录
+	r.POST("/manager/refresh", m.refresh) // 刷新访问token
+	r.POST("/manager/logout", m.logout)   // 退出登录（吊销当前会话的所有token）
 }
 
 func (m *manager) login(c *wkhttp.Context) {
@@ -64,33 +74,180 @@ func (m *manager) login(c *wkhttp.Context) {
 		return
 	}
 
+	// 每次登录生成一个新的会话
+	resp, err := m.genTokens(req.Username, wkutil.GenUUID())
+	if err != nil {
+		m.Error("genTokens error", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// 使用刷新token换取新的访问token，刷新token只能使用一次（换取后旧的刷新token将被吊销）
+func (m *manager) refresh(c *wkhttp.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if strings.TrimSpace(req.RefreshToken) == "" {
+		c.ResponseError(errors.New("refresh_token不能为空"))
+		return
+	}
+
+	claims, err := parseManagerJwt(req.RefreshToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if claims.typ != jwtTypeRefresh {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+	if !options.G.Auth.HasUser(claims.username) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not exist"})
+		return
+	}
+	revoked, err := service.TokenRevokeManager.IsRevoked(claims.sid, claims.jti)
+	if err != nil {
+		m.Error("IsRevoked error", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	if revoked {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token is revoked"})
+		return
+	}
+
+	// 吊销旧的刷新token
+	err = service.TokenRevokeManager.Revoke(newRevokedToken(claims.jti, claims.expireAt))
+	if err != nil {
+		m.Error("revoke refresh token error", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+
+	resp, err := m.genTokens(claims.username, claims.sid)
+	if err != nil {
+		m.Error("genTokens error", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// 退出登录，吊销当前会话（会话下的访问token和刷新token都将失效）
+func (m *manager) logout(c *wkhttp.Context) {
+	sid := c.GetString("sid")
+	if sid == "" { // 通过管理者token认证的请求没有会话
+		c.ResponseOK()
+		return
+	}
+	// 会话下的刷新token最晚在RefreshExpire后过期
+	expireAt := time.Now().Add(options.G.Jwt.RefreshExpire)
+	err := service.TokenRevokeManager.Revoke(newRevokedToken(sid, expireAt))
+	if err != nil {
+		m.Error("revoke session error", zap.Error(err), zap.String("sid", sid))
+		c.ResponseError(err)
+		return
+	}
+	c.ResponseOK()
+}
+
+// 生成访问token和刷新token
+func (m *manager) genTokens(username string, sid string) (gin.H, error) {
 	nw := time.Now()
 	expire := nw.Add(options.G.Jwt.Expire).Unix()
+	refreshExpire := nw.Add(options.G.Jwt.RefreshExpire).Unix()
 
-	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+	tokenStr, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"iss":      options.G.Jwt.Issuer, // 发行者
 		"exp":      expire,               // 过期时间
 		"iat":      nw.Unix(),            // 发行时间
-		"username": req.Username,         // 用户名
-	})
-	tokenStr, err := jwtToken.SignedString([]byte(options.G.Jwt.Secret))
+		"jti":      wkutil.GenUUID(),     // token id
+		"sid":      sid,                  // 会话id
+		"typ":      jwtTypeAccess,        // token类型
+		"username": username,             // 用户名
+	}).SignedString([]byte(options.G.Jwt.Secret))
 	if err != nil {
-		m.Error("jwtToken.SignedString", zap.Error(err))
-		c.ResponseError(err)
-		return
+		return nil, err
+	}
+
+	refreshTokenStr, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iss":      options.G.Jwt.Issuer,
+		"exp":      refreshExpire,
+		"iat":      nw.Unix(),
+		"jti":      wkutil.GenUUID(),
+		"sid":      sid,
+		"typ":      jwtTypeRefresh,
+		"username": username,
+	}).SignedString([]byte(options.G.Jwt.Secret))
+	if err != nil {
+		return nil, err
 	}
 
 	persmissionStr := ""
-	persmissions := options.G.Auth.Persmissions(req.Username)
+	persmissions := options.G.Auth.Persmissions(username)
 	if len(persmissions) > 0 {
 		persmissionStr = persmissions.Format()
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"username":    req.Username,
-		"token":       tokenStr,
-		"exp":         expire,
-		"permissions": persmissionStr,
-	})
+	return gin.H{
+		"username":      username,
+		"token":         tokenStr,
+		"exp":           expire,
+		"refresh_token": refreshTokenStr,
+		"refresh_exp":   refreshExpire,
+		"permissions":   persmissionStr,
+	}, nil
+}
+
+// 管理后台jwt的声明
+type managerClaims struct {
+	username string
+	sid      string
+	jti      string
+	typ      string
+	expireAt time.Time
+}
 
+// 解析并校验管理后台的jwt
+func parseManagerJwt(tokenStr string) (managerClaims, error) {
+	mapClaims := jwt.MapClaims{}
+	jwtToken, err := jwt.ParseWithClaims(tokenStr, mapClaims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(options.G.Jwt.Secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return managerClaims{}, err
+	}
+	if !jwtToken.Valid {
+		return managerClaims{}, errors.New("Invalid jwt token")
+	}
+	claims := managerClaims{}
+	claims.username, _ = mapClaims["username"].(string)
+	claims.sid, _ = mapClaims["sid"].(string)
+	claims.jti, _ = mapClaims["jti"].(string)
+	claims.typ, _ = mapClaims["typ"].(string)
+	if claims.username == "" {
+		return managerClaims{}, errors.New("Invalid jwt token, username is empty")
+	}
+	if claims.sid == "" || claims.jti == "" {
+		return managerClaims{}, errors.New("Invalid jwt token, session is empty")
+	}
+	if exp, err := mapClaims.GetExpirationTime(); err == nil && exp != nil {
+		claims.expireAt = exp.Time
+	}
+	return claims, nil
+}
+
+func newRevokedToken(id string, expireAt time.Time) wkdb.RevokedToken {
+	createdAt := time.Now()
+	return wkdb.RevokedToken{
+		Id:        id,
+		ExpireAt:  &expireAt,
+		CreatedAt: &createdAt,
+	}
 }
@@ -17,6 +17,7 @@ import (
 	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
 	"github.com/sendgrid/rest"
 	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
 )
 
 type request struct {
@@ -197,3 +198,22 @@ func handlerIMError(resp *rest.Response) error {
 	}
 	return nil
 }
+
+// 通过节点之间的rpc通知其他在线节点
+func notifyNodes(notify func(nodeId uint64) error) error {
+	nodes := service.Cluster.Nodes()
+	requestGroup, _ := errgroup.WithContext(context.Background())
+	for _, node := range nodes {
+		if node.Id == options.G.Cluster.NodeId {
+			continue
+		}
+		if !node.Online {
+			continue
+		}
+		nodeId := node.Id
+		requestGroup.Go(func() error {
+			return notify(nodeId)
+		})
+	}
+	return requestGroup.Wait()
+}
@@ -52,6 +52,7 @@ func (a *route) routeUserIMAddr(c *wkhttp.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"tcp_addr": tcpAddr,
+		"tcp_tls":  options.G.TCPTLS.On, // tcp长连接是否需要使用tls
 		"ws_addr":  wsAddr,
 		"wss_addr": wssAddr,
 	})
@@ -87,6 +88,7 @@ func (a *route) routeUserIMAddrOfBatch(c *wkhttp.Context) {
 		{
 			UIDs:    uids,
 			TCPAddr: tcpAddr,
+			TCPTLS:  options.G.TCPTLS.On,
 			WSAddr:  wsAddr,
 			WSSAddr: wssAddr,
 		},
@@ -96,6 +98,7 @@ func (a *route) routeUserIMAddrOfBatch(c *wkhttp.Context) {
 
 type userAddrResp struct {
 	TCPAddr string   `json:"tcp_addr"`
+	TCPTLS  bool     `json:"tcp_tls"` // tcp长连接是否需要使用tls
 	WSAddr  string   `json:"ws_addr"`
 	WSSAddr string   `json:"wss_addr"`
 	UIDs    []string `json:"uids"`
@@ -19,6 +19,7 @@ type Server struct {
 	migrateTask   *MigrateTask   // 迁移任务
 	apiServer     *apiServer     // api服务
 	managerServer *managerServer // api服务（管理）
+	auditLog      *auditLog      // 审计日志
 }
 
 func New() *Server {
@@ -29,6 +30,7 @@ func New() *Server {
 		uptime:      time.Now(),
 		client:      ingress.NewClient(),
 	}
+	s.auditLog = newAuditLog(s)
 	s.apiServer = newApiServer(s)
 	s.migrateTask = NewMigrateTask(s) // 迁移任务
 	s.managerServer = newManagerServer(s)
@@ -38,6 +40,7 @@ func New() *Server {
 func (s *Server) Start() error {
 
 	s.timingWheel.Start()
+	s.auditLog.start()
 	s.apiServer.start()
 
 	if options.G.Manager.On {
@@ -55,6 +58,7 @@ func (s *Server) Start() error {
 func (s *Server) Stop() {
 	s.timingWheel.Stop()
 	s.apiServer.stop()
+	s.auditLog.stop()
 	if options.G.Manager.On {
 		s.managerServer.stop()
 	}
@@ -44,18 +44,25 @@ func newApiServer(s *Server) *apiServer {
 // Start 开始
 func (s *apiServer) start() {
 
-	s.r.Use(func(c *wkhttp.Context) { // 管理者权限判断
-		if strings.TrimSpace(options.G.ManagerToken) == "" {
+	// 审计日志
+	s.r.Use(s.s.auditLog.middleware())
+
+	if options.G.ApiKey.On {
+		s.r.Use(apiKeyAuthMiddleware()) // api key权限判断（携带管理者token的请求拥有所有权限）
+	} else {
+		s.r.Use(func(c *wkhttp.Context) { // 管理者权限判断
+			if strings.TrimSpace(options.G.ManagerToken) == "" {
+				c.Next()
+				return
+			}
+			managerToken := c.GetHeader("token")
+			if managerToken != options.G.ManagerToken {
+				c.AbortWithStatus(http.StatusUnauthorized)
+				return
+			}
 			c.Next()
-			return
-		}
-		managerToken := c.GetHeader("token")
-		if managerToken != options.G.ManagerToken {
-			c.AbortWithStatus(http.StatusUnauthorized)
-			return
-		}
-		c.Next()
-	})
+		})
+	}
 
 	// 跨域
 	s.r.Use(wkhttp.CORSMiddleware())
@@ -9,6 +9,8 @@ import (
 
 	"github.com/WuKongIM/WuKongIM/internal/options"
 	"github.com/WuKongIM/WuKongIM/internal/service"
+	"github.com/WuKongIM/WuKongIM/pkg/auth"
+	"github.com/WuKongIM/WuKongIM/pkg/auth/resource"
 	cluster "github.com/WuKongIM/WuKongIM/pkg/cluster/cluster"
 	"github.com/WuKongIM/WuKongIM/pkg/trace"
 	"github.com/WuKongIM/WuKongIM/pkg/wkhttp"
@@ -16,7 +18,6 @@ import (
 	"github.com/WuKongIM/WuKongIM/version"
 	"github.com/gin-contrib/gzip"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"go.uber.org/zap"
 )
 
@@ -25,6 +26,8 @@ type managerServer struct {
 	r *wkhttp.WKHttp
 	wklog.Log
 	addr string
+
+	routePermissions auth.RoutePermissions // 接口权限表
 }
 
 func newManagerServer(s *Server) *managerServer {
@@ -37,6 +40,8 @@ func newManagerServer(s *Server) *managerServer {
 		s:    s,
 		r:    r,
 		Log:  log,
+
+		routePermissions: auth.RoutePermissions{},
 	}
 
 }
@@ -44,8 +49,12 @@ func newManagerServer(s *Server) *managerServer {
 func (m *managerServer) start() {
 
 	m.r.Use(wkhttp.CORSMiddleware())
+	// 审计日志
+	m.r.Use(m.s.auditLog.middleware())
 	// jwt和token认证中间件
 	m.r.Use(m.jwtAndTokenAuthMiddleware())
+	// 接口权限中间件
+	m.r.Use(options.G.Auth.Middleware(m.routePermissions, m.skipAuth))
 
 	m.r.GetGinRoute().Use(gzip.Gzip(gzip.DefaultCompression, gzip.WithExcludedPaths([]string{"/metrics"})))
 
@@ -108,6 +117,14 @@ func (m *managerServer) setRoutes() {
 	tag := newTag(m.s)
 	tag.route(m.r)
 
+	// api key管理
+	ak := newApiKey(m.s)
+	ak.route(m.r)
+
+	// 连接的ip规则管理
+	ipr := newIpRule(m.s)
+	ipr.route(m.r)
+
 	// // 系统api
 	// system := NewSystemAPI(s.s)
 	// system.Route(s.r)
@@ -116,17 +133,68 @@ func (m *managerServer) setRoutes() {
 	clusterServer, ok := service.Cluster.(*cluster.Server)
 	if ok {
 		clusterServer.ServerAPI(m.r, "/cluster")
+		m.routePermissions.Merge(clusterServer.RoutePermissions())
 	}
 	// 监控
 	trace.GlobalTrace.Route(m.r)
 
+	m.setRoutePermissions()
+}
+
+// 管理api的接口权限，没有登记的接口需要拥有所有资源（*）的权限
+func (m *managerServer) setRoutePermissions() {
+	routes := m.routePermissions
+
+	// 连接
+	routes.Add(http.MethodGet, "/connz", resource.Conn, auth.ActionRead)
+	routes.Add(http.MethodPost, "/conn/remove", resource.Conn, auth.ActionWrite)
+	routes.Add(http.MethodPost, "/conn/kick", resource.Conn, auth.ActionWrite)
+
+	// 系统变量
+	routes.Add(http.MethodGet, "/varz", resource.Varz, auth.ActionRead)
+	routes.Add(http.MethodGet, "/varz/setting", resource.Varz, auth.ActionRead)
+
+	// 标签
+	routes.Add(http.MethodGet, "/tag", resource.Tag, auth.ActionRead)
+	routes.Add(http.MethodPost, "/tag/remove", resource.Tag, auth.ActionWrite)
+	routes.Add(http.MethodGet, "/tags", resource.Tag, auth.ActionRead)
+
+	// 压测
+	routes.Add(http.MethodPost, "/stress/add", resource.Stress, auth.ActionWrite)
+	routes.Add(http.MethodPost, "/stress/remove", resource.Stress, auth.ActionWrite)
+	routes.Add(http.MethodPost, "/stress/start", resource.Stress, auth.ActionWrite)
+	routes.Add(http.MethodPost, "/stress/stop", resource.Stress, auth.ActionWrite)
+	routes.Add(http.MethodPost, "/stress/report", resource.Stress, auth.ActionRead)
+	routes.Add(http.MethodGet, "/stress/infoList", resource.Stress, auth.ActionRead)
+	routes.Add(http.MethodGet, "/stress/templates", resource.Stress, auth.ActionRead)
+
+	// api key
+	routes.Add(http.MethodGet, "/apikey/list", resource.ApiKey.Manage, auth.ActionRead)
+	routes.Add(http.MethodPost, "/apikey/add", resource.ApiKey.Manage, auth.ActionWrite)
+	routes.Add(http.MethodPost, "/apikey/remove", resource.ApiKey.Manage, auth.ActionWrite)
+
+	// 连接的ip规则
+	routes.Add(http.MethodGet, "/iprule/list", resource.IpRule, auth.ActionRead)
+	routes.Add(http.MethodPost, "/iprule/add", resource.IpRule, auth.ActionWrite)
+	routes.Add(http.MethodPost, "/iprule/remove", resource.IpRule, auth.ActionWrite)
+	routes.Add(http.MethodGet, "/iprule/bans", resource.IpRule, auth.ActionRead)
+	routes.Add(http.MethodPost, "/iprule/unban", resource.IpRule, auth.ActionWrite)
+}
+
+// 不需要判断接口权限的请求
+func (m *managerServer) skipAuth(c *wkhttp.Context) bool {
+	fpath := c.Request.URL.Path
+	if strings.HasPrefix(fpath, "/manager/login") || strings.HasPrefix(fpath, "/manager/refresh") || strings.HasPrefix(fpath, "/manager/logout") { // 登录的用户都可以刷新token和退出登录
+		return true
+	}
+	return strings.HasPrefix(fpath, "/web") || strings.HasPrefix(fpath, "/metrics")
 }
 
 func (m *managerServer) jwtAndTokenAuthMiddleware() wkhttp.HandlerFunc {
 	return func(c *wkhttp.Context) {
 
 		fpath := c.Request.URL.Path
-		if strings.HasPrefix(fpath, "/manager/login") { // 登录不需要认证
+		if strings.HasPrefix(fpath, "/manager/login") || strings.HasPrefix(fpath, "/manager/refresh") { // 登录和刷新token不需要认证
 			c.Next()
 			return
 		}
@@ -165,28 +233,34 @@ func (m *managerServer) jwtAndTokenAuthMiddleware() wkhttp.HandlerFunc {
 			return
 		}
 
-		jwtToken, err := jwt.ParseWithClaims(authorization, jwt.MapClaims{}, func(token *jwt.Token) (interface{}, error) {
-			return []byte(options.G.Jwt.Secret), nil
-		})
+		claims, err := parseManagerJwt(authorization)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 			c.Abort()
 			return
 		}
+		if claims.typ != jwtTypeAccess { // 刷新token不能用于访问接口
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid jwt token, not an access token"})
+			c.Abort()
+			return
+		}
 
-		if !jwtToken.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid jwt token"})
+		// 判断会话或token是否已被吊销
+		revoked, err := service.TokenRevokeManager.IsRevoked(claims.sid, claims.jti)
+		if err != nil {
+			m.Error("IsRevoked error", zap.Error(err))
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "check token revoked failed"})
 			c.Abort()
 			return
 		}
-		mapCaims := jwtToken.Claims.(jwt.MapClaims)
-		if mapCaims["username"] == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid jwt token, username is empty"})
+		if revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "jwt token is revoked"})
 			c.Abort()
 			return
 		}
 
-		c.Set("username", mapCaims["username"])
+		c.Set("username", claims.username)
+		c.Set("sid", claims.sid)
 		c.Next()
 	}
 }
@@ -4,10 +4,12 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/WuKongIM/WuKongIM/internal/eventbus"
+	"github.com/WuKongIM/WuKongIM/internal/ingress"
 	"github.com/WuKongIM/WuKongIM/internal/options"
 	"github.com/WuKongIM/WuKongIM/internal/service"
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/node/types"
@@ -25,29 +27,38 @@ import (
 // user 用户相关API
 type user struct {
 	wklog.Log
-	s *Server
+	s             *Server
+	ingressClient *ingress.Client
 }
 
 func newUser(s *Server) *user {
 	return &user{
-		Log: wklog.NewWKLog("user"),
-		s:   s,
+		Log:           wklog.NewWKLog("user"),
+		s:             s,
+		ingressClient: ingress.NewClient(),
 	}
 }
 
 // Route 用户相关路由配置
 func (u *user) route(r *wkhttp.WKHttp) {
 
-	r.POST("/user/token", u.updateToken)                  // 更新用户token
-	r.POST("/user/device_quit", u.deviceQuit)             // 强制设备退出
-	r.POST("/user/onlinestatus", u.getOnlineStatus)       // 获取用户在线状态
-	r.POST("/user/systemuids_add", u.systemUidsAdd)       // 添加系统uid
-	r.POST("/user/systemuids_remove", u.systemUidsRemove) // 移除系统uid
-	r.GET("/user/systemuids", u.getSystemUids)            // 获取系统uid
+	r.POST("/user/token", u.updateToken)                   // 更新用户token
+	r.POST("/user/device_quit", u.deviceQuit)              // 强制设备退出
+	r.POST("/user/device_quit_others", u.deviceQuitOthers) // 强制除指定设备外的其他设备退出
+	r.GET("/user/devices", u.getDevices)                   // 获取用户的设备列表（包含设备的在线连接）
+	r.GET("/user/login_logs", u.getLoginLogs)              // 获取用户的登录日志
+	r.POST("/user/onlinestatus", u.getOnlineStatus)        // 获取用户在线状态
+	r.POST("/user/lastseen", u.getLastSeen)                // 批量获取用户最后上线/离线时间
+	r.POST("/user/systemuids_add", u.systemUidsAdd)        // 添加系统uid
+	r.POST("/user/systemuids_remove", u.systemUidsRemove)  // 移除系统uid
+	r.GET("/user/systemuids", u.getSystemUids)             // 获取系统uid
 
 	r.POST("/user/systemuids_add_to_cache", u.systemUidsAddToCache)           // 仅仅添加系统账号至缓存
 	r.POST("/user/systemuids_remove_from_cache", u.systemUidsRemoveFromCache) // 仅仅从缓存中移除系统账号
 
+	r.POST("/user/presence/subscribe", u.presenceSubscribe)     // 订阅用户在线状态
+	r.POST("/user/presence/unsubscribe", u.presenceUnsubscribe) // 取消订阅用户在线状态
+
 }
 
 // 强制设备退出
@@ -87,6 +98,152 @@ func (u *user) deviceQuit(c *wkhttp.Context) {
 
 }
 
+// 强制除指定设备外的其他设备退出（比如：退出其他所有设备）
+func (u *user) deviceQuitOthers(c *wkhttp.Context) {
+	var req struct {
+		UID        string `json:"uid"`         // 用户uid
+		DeviceFlag uint8  `json:"device_flag"` // 保留的设备flag
+	}
+	bodyBytes, err := BindJSON(&req, c)
+	if err != nil {
+		u.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	if strings.TrimSpace(req.UID) == "" {
+		c.ResponseError(errors.New("uid不能为空！"))
+		return
+	}
+	leaderInfo, err := service.Cluster.SlotLeaderOfChannel(req.UID, wkproto.ChannelTypePerson) // 获取频道的领导节点
+	if err != nil {
+		u.Error("获取频道所在节点失败！", zap.Error(err), zap.String("channelID", req.UID), zap.Uint8("channelType", wkproto.ChannelTypePerson))
+		c.ResponseError(errors.New("获取频道所在节点失败！"))
+		return
+	}
+	leaderIsSelf := leaderInfo.Id == options.G.Cluster.NodeId
+	if !leaderIsSelf {
+		u.Debug("转发请求：", zap.String("url", fmt.Sprintf("%s%s", leaderInfo.ApiServerAddr, c.Request.URL.Path)))
+		c.ForwardWithBody(fmt.Sprintf("%s%s", leaderInfo.ApiServerAddr, c.Request.URL.Path), bodyBytes)
+		return
+	}
+
+	for _, deviceFlag := range []wkproto.DeviceFlag{wkproto.APP, wkproto.WEB, wkproto.PC} {
+		if deviceFlag.ToUint8() == req.DeviceFlag {
+			continue
+		}
+		_ = u.quitUserDevice(req.UID, deviceFlag)
+	}
+
+	c.ResponseOK()
+}
+
+// 获取用户的设备列表
+func (u *user) getDevices(c *wkhttp.Context) {
+	uid := strings.TrimSpace(c.Query("uid"))
+	if uid == "" {
+		c.ResponseError(errors.New("uid不能为空！"))
+		return
+	}
+	leaderInfo, err := service.Cluster.SlotLeaderOfChannel(uid, wkproto.ChannelTypePerson) // 获取频道的领导节点
+	if err != nil {
+		u.Error("获取频道所在节点失败！", zap.Error(err), zap.String("channelID", uid), zap.Uint8("channelType", wkproto.ChannelTypePerson))
+		c.ResponseError(errors.New("获取频道所在节点失败！"))
+		return
+	}
+	if leaderInfo.Id != options.G.Cluster.NodeId {
+		c.Forward(fmt.Sprintf("%s%s", leaderInfo.ApiServerAddr, c.Request.URL.Path))
+		return
+	}
+
+	devices, err := service.Store.GetDevices(uid)
+	if err != nil && err != wkdb.ErrNotFound {
+		u.Error("获取设备列表失败！", zap.Error(err), zap.String("uid", uid))
+		c.ResponseError(err)
+		return
+	}
+
+	resps := make([]*DeviceResp, 0, len(devices))
+	for _, device := range devices {
+		deviceFlag := wkproto.DeviceFlag(device.DeviceFlag)
+		resp := &DeviceResp{
+			DeviceFlag:    deviceFlag.ToUint8(),
+			DeviceLevel:   device.DeviceLevel,
+			LastOnlineAt:  timeToUnix(device.LastOnlineAt),
+			LastOfflineAt: timeToUnix(device.LastOfflineAt),
+			CreatedAt:     timeToUnix(device.CreatedAt),
+			UpdatedAt:     timeToUnix(device.UpdatedAt),
+			Conns:         make([]*DeviceConnResp, 0),
+		}
+		if device.Token != "" && (device.TokenExpireAt == nil || time.Now().Before(*device.TokenExpireAt)) {
+			resp.HasToken = 1
+		}
+		resp.TokenExpireAt = timeToUnix(device.TokenExpireAt)
+		conns := eventbus.User.ConnsByDeviceFlag(uid, deviceFlag)
+		for _, conn := range conns {
+			resp.Conns = append(resp.Conns, &DeviceConnResp{
+				ConnId:     conn.ConnId,
+				NodeId:     conn.NodeId,
+				DeviceId:   conn.DeviceId,
+				Ip:         conn.ClientIp,
+				Uptime:     int64(conn.Uptime),
+				LastActive: int64(conn.LastActive),
+				InMsgs:     conn.InMsgCount.Load(),
+				OutMsgs:    conn.OutMsgCount.Load(),
+				InBytes:    conn.InMsgByteCount.Load(),
+				OutBytes:   conn.OutMsgByteCount.Load(),
+			})
+		}
+		if len(conns) > 0 {
+			resp.Online = 1
+		}
+		resps = append(resps, resp)
+	}
+	c.JSON(http.StatusOK, resps)
+}
+
+// 获取用户的登录日志
+func (u *user) getLoginLogs(c *wkhttp.Context) {
+	uid := strings.TrimSpace(c.Query("uid"))
+	if uid == "" {
+		c.ResponseError(errors.New("uid不能为空！"))
+		return
+	}
+	limit := wkutil.ParseInt(c.Query("limit"))
+	if limit <= 0 || limit > options.G.LoginLog.MaxCount {
+		limit = options.G.LoginLog.MaxCount
+	}
+
+	leaderInfo, err := service.Cluster.SlotLeaderOfChannel(uid, wkproto.ChannelTypePerson) // 获取频道的领导节点
+	if err != nil {
+		u.Error("获取频道所在节点失败！", zap.Error(err), zap.String("channelID", uid), zap.Uint8("channelType", wkproto.ChannelTypePerson))
+		c.ResponseError(errors.New("获取频道所在节点失败！"))
+		return
+	}
+	if leaderInfo.Id != options.G.Cluster.NodeId {
+		c.Forward(fmt.Sprintf("%s%s", leaderInfo.ApiServerAddr, c.Request.URL.Path))
+		return
+	}
+
+	logs, err := service.Store.GetLoginLogs(uid, limit)
+	if err != nil {
+		u.Error("获取登录日志失败！", zap.Error(err), zap.String("uid", uid))
+		c.ResponseError(err)
+		return
+	}
+	resps := make([]*LoginLogResp, 0, len(logs))
+	for _, log := range logs {
+		resps = append(resps, &LoginLogResp{
+			DeviceFlag:  uint8(log.DeviceFlag),
+			DeviceLevel: log.DeviceLevel,
+			DeviceId:    log.DeviceId,
+			Ip:          log.Ip,
+			NodeId:      log.NodeId,
+			CreatedAt:   timeToUnix(log.CreatedAt),
+		})
+	}
+	c.JSON(http.StatusOK, resps)
+}
+
 // 这里清空token 让设备去重新登录 空token是不让登录的
 func (u *user) quitUserDevice(uid string, deviceFlag wkproto.DeviceFlag) error {
 
@@ -114,6 +271,19 @@ func (u *user) quitUserDevice(uid string, deviceFlag wkproto.DeviceFlag) error {
 		u.Error("清空用户token失败！", zap.Error(err), zap.String("uid", uid), zap.Uint8("deviceFlag", deviceFlag.ToUint8()))
 		return err
 	}
+	// 在这之前签发的jwt连接token也一起失效，吊销记录保留jwt的最长有效期
+	if options.G.TokenJwt.On {
+		expireAt := updatedAt.Add(options.G.TokenJwt.MaxAge)
+		err = service.TokenRevokeManager.Revoke(wkdb.RevokedToken{
+			Id:        service.DeviceRevokeId(uid, deviceFlag),
+			ExpireAt:  &expireAt,
+			CreatedAt: &updatedAt,
+		})
+		if err != nil {
+			u.Error("吊销设备jwt失败！", zap.Error(err), zap.String("uid", uid), zap.Uint8("deviceFlag", deviceFlag.ToUint8()))
+			return err
+		}
+	}
 	oldConns := eventbus.User.ConnsByDeviceFlag(uid, deviceFlag)
 	if len(oldConns) > 0 {
 		for _, oldConn := range oldConns {
@@ -210,7 +380,7 @@ func (u *user) requestOnlineStatus(nodeId uint64, uids []string) ([]*Onlinestatu
 		return nil, errors.New("节点信息不存在")
 	}
 	reqURL := fmt.Sprintf("%s/user/onlinestatus", nodeInfo.ApiServerAddr)
-	resp, err := network.Post(reqURL, []byte(wkutil.ToJSON(uids)), nil)
+	resp, err := network.Post(reqURL, []byte(wkutil.ToJSON(uids)), options.G.InternalRequestHeaders())
 	if err != nil {
 		u.Error("获取在线用户状态失败！", zap.Error(err), zap.String("reqURL", reqURL))
 		return nil, err
@@ -232,17 +402,272 @@ func (u *user) getOnlineConns(uids []string) []*OnlinestatusResp {
 	onlineStatusResps := make([]*OnlinestatusResp, 0)
 	for _, uid := range uids {
 		conns := eventbus.User.ConnsByUid(uid)
+		devices := make(map[wkproto.DeviceFlag]wkdb.Device)
 		for _, conn := range conns {
+			device, ok := devices[conn.DeviceFlag]
+			if !ok {
+				var err error
+				device, err = service.Store.GetDevice(uid, conn.DeviceFlag)
+				if err != nil && err != wkdb.ErrNotFound {
+					u.Warn("获取设备信息失败！", zap.Error(err), zap.String("uid", uid), zap.Uint8("deviceFlag", conn.DeviceFlag.ToUint8()))
+				}
+				devices[conn.DeviceFlag] = device
+			}
 			onlineStatusResps = append(onlineStatusResps, &OnlinestatusResp{
-				UID:        conn.Uid,
-				DeviceFlag: conn.DeviceFlag.ToUint8(),
-				Online:     1,
+				UID:           conn.Uid,
+				DeviceFlag:    conn.DeviceFlag.ToUint8(),
+				Online:        1,
+				LastOnlineAt:  timeToUnix(device.LastOnlineAt),
+				LastOfflineAt: timeToUnix(device.LastOfflineAt),
 			})
 		}
 	}
 	return onlineStatusResps
 }
 
+// 批量获取用户最后上线/离线时间
+func (u *user) getLastSeen(c *wkhttp.Context) {
+	var uids []string
+	err := c.BindJSON(&uids)
+	if err != nil {
+		u.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	if len(uids) == 0 {
+		c.JSON(http.StatusOK, []*LastSeenResp{})
+		return
+	}
+
+	uidInPeerMap := make(map[uint64][]string)
+	localUids := make([]string, 0)
+	for _, uid := range uids {
+		leaderInfo, err := service.Cluster.SlotLeaderOfChannel(uid, wkproto.ChannelTypePerson) // 获取频道的领导节点
+		if err != nil {
+			u.Error("获取频道所在节点失败！", zap.Error(err), zap.String("channelID", uid), zap.Uint8("channelType", wkproto.ChannelTypePerson))
+			c.ResponseError(errors.New("获取频道所在节点失败！"))
+			return
+		}
+		if leaderInfo.Id == options.G.Cluster.NodeId {
+			localUids = append(localUids, uid)
+			continue
+		}
+		uidInPeerMap[leaderInfo.Id] = append(uidInPeerMap[leaderInfo.Id], uid)
+	}
+
+	resps := make([]*LastSeenResp, 0, len(uids))
+	if len(localUids) > 0 {
+		localResps, err := u.getLocalLastSeen(localUids)
+		if err != nil {
+			u.Error("获取用户最后在线时间失败！", zap.Error(err))
+			c.ResponseError(err)
+			return
+		}
+		resps = append(resps, localResps...)
+	}
+
+	if len(uidInPeerMap) > 0 {
+		var lock sync.Mutex
+		timeoutCtx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		requestGroup, _ := errgroup.WithContext(timeoutCtx)
+		for nodeId, uidList := range uidInPeerMap {
+			nodeId, uidList := nodeId, uidList
+			requestGroup.Go(func() error {
+				results, err := u.requestLastSeen(nodeId, uidList)
+				if err != nil {
+					return err
+				}
+				lock.Lock()
+				resps = append(resps, results...)
+				lock.Unlock()
+				return nil
+			})
+		}
+		if err := requestGroup.Wait(); err != nil {
+			u.Error("请求用户最后在线时间失败！", zap.Error(err))
+			c.ResponseError(err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, resps)
+}
+
+func (u *user) requestLastSeen(nodeId uint64, uids []string) ([]*LastSeenResp, error) {
+	nodeInfo := service.Cluster.NodeInfoById(nodeId)
+	if nodeInfo == nil {
+		u.Error("节点信息不存在", zap.Uint64("nodeId", nodeId))
+		return nil, errors.New("节点信息不存在")
+	}
+	reqURL := fmt.Sprintf("%s/user/lastseen", nodeInfo.ApiServerAddr)
+	resp, err := network.Post(reqURL, []byte(wkutil.ToJSON(uids)), options.G.InternalRequestHeaders())
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("获取用户最后在线时间请求状态错误！[%d]", resp.StatusCode)
+	}
+	var resps []*LastSeenResp
+	err = wkutil.ReadJSONByByte([]byte(resp.Body), &resps)
+	if err != nil {
+		return nil, err
+	}
+	return resps, nil
+}
+
+// 获取本节点（用户的leader节点）上的用户最后上线/离线时间
+func (u *user) getLocalLastSeen(uids []string) ([]*LastSeenResp, error) {
+	resps := make([]*LastSeenResp, 0, len(uids))
+	for _, uid := range uids {
+		usr, err := service.Store.GetUser(uid)
+		if err != nil && err != wkdb.ErrNotFound {
+			return nil, err
+		}
+		devices, err := service.Store.GetDevices(uid)
+		if err != nil && err != wkdb.ErrNotFound {
+			return nil, err
+		}
+		resp := &LastSeenResp{
+			UID:           uid,
+			LastOnlineAt:  timeToUnix(usr.LastOnlineAt),
+			LastOfflineAt: timeToUnix(usr.LastOfflineAt),
+		}
+		if eventbus.User.ConnCountByUid(uid) > 0 {
+			resp.Online = 1
+		}
+		for _, device := range devices {
+			deviceFlag := wkproto.DeviceFlag(device.DeviceFlag)
+			deviceResp := &DeviceLastSeenResp{
+				DeviceFlag:    deviceFlag.ToUint8(),
+				LastOnlineAt:  timeToUnix(device.LastOnlineAt),
+				LastOfflineAt: timeToUnix(device.LastOfflineAt),
+			}
+			if eventbus.User.ConnCountByDeviceFlag(uid, deviceFlag) > 0 {
+				deviceResp.Online = 1
+			}
+			resp.Devices = append(resp.Devices, deviceResp)
+		}
+		resps = append(resps, resp)
+	}
+	return resps, nil
+}
+
+// 订阅用户在线状态
+func (u *user) presenceSubscribe(c *wkhttp.Context) {
+	u.handlePresence(c, true)
+}
+
+// 取消订阅用户在线状态
+func (u *user) presenceUnsubscribe(c *wkhttp.Context) {
+	u.handlePresence(c, false)
+}
+
+func (u *user) handlePresence(c *wkhttp.Context, subscribe bool) {
+	var req presenceReq
+	if err := c.BindJSON(&req); err != nil {
+		u.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	if err := req.Check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if !options.G.Presence.On {
+		c.ResponseError(errors.New("在线状态订阅未开启！"))
+		return
+	}
+
+	// 每个用户的订阅数量在订阅者的leader节点上统计，不是订阅者的leader节点则转发过去
+	watcherLeader, err := service.Cluster.SlotLeaderOfChannel(req.UID, wkproto.ChannelTypePerson)
+	if err != nil {
+		u.Error("获取频道所在节点失败！", zap.Error(err), zap.String("channelID", req.UID), zap.Uint8("channelType", wkproto.ChannelTypePerson))
+		c.ResponseError(errors.New("获取频道所在节点失败！"))
+		return
+	}
+	if watcherLeader.Id != options.G.Cluster.NodeId {
+		c.ForwardWithBody(fmt.Sprintf("%s%s", watcherLeader.ApiServerAddr, c.Request.URL.Path), []byte(wkutil.ToJSON(req)))
+		return
+	}
+
+	uids := req.Uids
+	if subscribe {
+		uids, err = service.PresenceManager.Reserve(req.UID, req.Uids)
+		if err != nil {
+			u.Error("订阅在线状态失败！", zap.Error(err), zap.String("uid", req.UID))
+			c.ResponseError(err)
+			return
+		}
+		if len(uids) == 0 { // 都已经订阅过了
+			c.ResponseOK()
+			return
+		}
+	} else {
+		service.PresenceManager.Release(req.UID, req.Uids)
+	}
+
+	// 订阅关系保存在被订阅用户的leader节点上，按leader节点分组
+	uidInPeerMap := make(map[uint64][]string)
+	localUids := make([]string, 0)
+	for _, uid := range uids {
+		leaderInfo, err := service.Cluster.SlotLeaderOfChannel(uid, wkproto.ChannelTypePerson) // 获取频道的领导节点
+		if err != nil {
+			u.Error("获取频道所在节点失败！", zap.Error(err), zap.String("channelID", uid), zap.Uint8("channelType", wkproto.ChannelTypePerson))
+			if subscribe {
+				service.PresenceManager.Release(req.UID, uids)
+			}
+			c.ResponseError(errors.New("获取频道所在节点失败！"))
+			return
+		}
+		if leaderInfo.Id == options.G.Cluster.NodeId {
+			localUids = append(localUids, uid)
+			continue
+		}
+		uidInPeerMap[leaderInfo.Id] = append(uidInPeerMap[leaderInfo.Id], uid)
+	}
+
+	if len(localUids) > 0 {
+		if subscribe {
+			if err := service.PresenceManager.Subscribe(req.UID, localUids); err != nil {
+				u.Error("订阅在线状态失败！", zap.Error(err), zap.String("uid", req.UID))
+				service.PresenceManager.Release(req.UID, uids)
+				c.ResponseError(err)
+				return
+			}
+		} else {
+			service.PresenceManager.Unsubscribe(req.UID, localUids)
+		}
+	}
+
+	if len(uidInPeerMap) > 0 {
+		requestGroup, _ := errgroup.WithContext(context.Background())
+		for nodeId, peerUids := range uidInPeerMap {
+			nodeId, peerUids := nodeId, peerUids
+			requestGroup.Go(func() error {
+				presenceReq := &ingress.PresenceReq{
+					Watcher: req.UID,
+					Uids:    peerUids,
+				}
+				if subscribe {
+					return u.ingressClient.PresenceSubscribe(nodeId, presenceReq)
+				}
+				return u.ingressClient.PresenceUnsubscribe(nodeId, presenceReq)
+			})
+		}
+		if err := requestGroup.Wait(); err != nil {
+			u.Error("请求在线状态订阅失败！", zap.Error(err), zap.String("uid", req.UID))
+			if subscribe {
+				// 部分节点可能已经订阅成功，订阅者下次订阅时会重新登记
+				service.PresenceManager.Release(req.UID, uids)
+			}
+			c.ResponseError(err)
+			return
+		}
+	}
+	c.ResponseOK()
+}
+
 // 更新用户的token
 func (u *user) updateToken(c *wkhttp.Context) {
 	var req UpdateTokenReq
@@ -323,17 +748,25 @@ func (u *user) updateToken(c *wkhttp.Context) {
 		return
 	}
 
+	// token过期时间
+	var tokenExpireAt *time.Time
+	if req.Expire > 0 {
+		expireAt := time.Now().Add(time.Duration(req.Expire) * time.Second)
+		tokenExpireAt = &expireAt
+	}
+
 	// 不存在设备则添加设备，存在则更新设备
 	if wkdb.IsEmptyDevice(device) {
 		createdAt := time.Now()
 		err = service.Store.AddDevice(wkdb.Device{
-			Id:          service.Store.NextPrimaryKey(),
-			Uid:         req.UID,
-			DeviceFlag:  uint64(req.DeviceFlag),
-			DeviceLevel: uint8(req.DeviceLevel),
-			Token:       req.Token,
-			CreatedAt:   &createdAt,
-			UpdatedAt:   &createdAt,
+			Id:            service.Store.NextPrimaryKey(),
+			Uid:           req.UID,
+			DeviceFlag:    uint64(req.DeviceFlag),
+			DeviceLevel:   uint8(req.DeviceLevel),
+			Token:         req.Token,
+			TokenExpireAt: tokenExpireAt,
+			CreatedAt:     &createdAt,
+			UpdatedAt:     &createdAt,
 		})
 		if err != nil {
 			u.Error("添加设备失败！", zap.Error(err), zap.String("uid", req.UID), zap.Uint8("deviceFlag", req.DeviceFlag.ToUint8()))
@@ -343,12 +776,13 @@ func (u *user) updateToken(c *wkhttp.Context) {
 	} else {
 		updatedAt := time.Now()
 		err = service.Store.UpdateDevice(wkdb.Device{
-			Id:          device.Id,
-			Uid:         req.UID,
-			DeviceFlag:  uint64(req.DeviceFlag),
-			DeviceLevel: uint8(req.DeviceLevel),
-			Token:       req.Token,
-			UpdatedAt:   &updatedAt,
+			Id:            device.Id,
+			Uid:           req.UID,
+			DeviceFlag:    uint64(req.DeviceFlag),
+			DeviceLevel:   uint8(req.DeviceLevel),
+			Token:         req.Token,
+			TokenExpireAt: tokenExpireAt,
+			UpdatedAt:     &updatedAt,
 		})
 		if err != nil {
 			u.Error("更新设备失败！", zap.Error(err), zap.String("uid", req.UID), zap.Uint8("deviceFlag", req.DeviceFlag.ToUint8()))
@@ -456,7 +890,7 @@ func (u *user) requestSystemUidsAddToCache(nodeInfo *types.Node, uids []string)
 	reqURL := fmt.Sprintf("%s/user/systemuids_add_to_cache", nodeInfo.ApiServerAddr)
 	resp, err := network.Post(reqURL, []byte(wkutil.ToJSON(map[string]interface{}{
 		"uids": uids,
-	})), nil)
+	})), options.G.InternalRequestHeaders())
 	if err != nil {
 		u.Error("添加系统账号到缓存失败！", zap.Error(err), zap.String("reqURL", reqURL))
 		return err
@@ -551,7 +985,7 @@ func (u *user) requestSystemUidsRemoveFromCache(nodeInfo *types.Node, uids []str
 	reqURL := fmt.Sprintf("%s/user/systemuids_remove_from_cache", nodeInfo.ApiServerAddr)
 	resp, err := network.Post(reqURL, []byte(wkutil.ToJSON(map[string]interface{}{
 		"uids": uids,
-	})), nil)
+	})), options.G.InternalRequestHeaders())
 	if err != nil {
 		u.Error("移除系统账号从缓存失败！", zap.Error(err), zap.String("reqURL", reqURL))
 		return err
@@ -609,6 +1043,7 @@ type UpdateTokenReq struct {
 	Token       string              `json:"token"`        // 用户的token
 	DeviceFlag  wkproto.DeviceFlag  `json:"device_flag"`  // 设备标识  0.app 1.web
 	DeviceLevel wkproto.DeviceLevel `json:"device_level"` // 设备等级 0.为从设备 1.为主设备
+	Expire      int64               `json:"expire"`       // token有效期（单位秒） 0表示永不过期
 }
 
 // Check 检查输入
@@ -622,6 +1057,9 @@ func (u UpdateTokenReq) Check() error {
 	if u.Token == "" {
 		return errors.New("token不能为空！")
 	}
+	if u.Expire < 0 {
+		return errors.New("expire不能小于0！")
+	}
 
 	if options.IsSpecialChar(u.UID) {
 		return errors.New("uid不能包含特殊字符！")
@@ -636,7 +1074,81 @@ func (u UpdateTokenReq) Check() error {
 }
 
 type OnlinestatusResp struct {
-	UID        string `json:"uid"`         // 在线用户uid
-	DeviceFlag uint8  `json:"device_flag"` // 设备标记 0. APP 1.web
-	Online     int    `json:"online"`      // 是否在线
+	UID           string `json:"uid"`                       // 在线用户uid
+	DeviceFlag    uint8  `json:"device_flag"`               // 设备标记 0. APP 1.web
+	Online        int    `json:"online"`                    // 是否在线
+	LastOnlineAt  int64  `json:"last_online_at,omitempty"`  // 设备最后一次上线时间（秒）
+	LastOfflineAt int64  `json:"last_offline_at,omitempty"` // 设备最后一次离线时间（秒）
+}
+
+type LastSeenResp struct {
+	UID           string                `json:"uid"`             // 用户uid
+	Online        int                   `json:"online"`          // 是否在线
+	LastOnlineAt  int64                 `json:"last_online_at"`  // 最后一次上线时间（秒） 0表示没有记录
+	LastOfflineAt int64                 `json:"last_offline_at"` // 最后一次离线时间（秒） 0表示没有记录
+	Devices       []*DeviceLastSeenResp `json:"devices,omitempty"`
+}
+
+type DeviceLastSeenResp struct {
+	DeviceFlag    uint8 `json:"device_flag"`     // 设备标记 0. APP 1.web 2.pc
+	Online        int   `json:"online"`          // 是否在线
+	LastOnlineAt  int64 `json:"last_online_at"`  // 最后一次上线时间（秒）
+	LastOfflineAt int64 `json:"last_offline_at"` // 最后一次离线时间（秒）
+}
+
+type DeviceResp struct {
+	DeviceFlag    uint8             `json:"device_flag"`     // 设备标记 0. APP 1.web 2.pc
+	DeviceLevel   uint8             `json:"device_level"`    // 设备等级 0.为从设备 1.为主设备
+	HasToken      int               `json:"has_token"`       // 是否有有效token 0.token已被清空（需要重新获取token才能登录）
+	TokenExpireAt int64             `json:"token_expire_at"` // token过期时间（秒） 0表示永不过期
+	Online        int               `json:"online"`          // 是否在线
+	LastOnlineAt  int64             `json:"last_online_at"`  // 最后一次上线时间（秒）
+	LastOfflineAt int64             `json:"last_offline_at"` // 最后一次离线时间（秒）
+	CreatedAt     int64             `json:"created_at"`      // 设备创建时间（秒）
+	UpdatedAt     int64             `json:"updated_at"`      // 设备更新时间（秒）
+	Conns         []*DeviceConnResp `json:"conns"`           // 设备的在线连接
+}
+
+type DeviceConnResp struct {
+	ConnId     int64  `json:"conn_id"`     // 连接id
+	NodeId     uint64 `json:"node_id"`     // 连接所在节点
+	DeviceId   string `json:"device_id"`   // 设备id
+	Ip         string `json:"ip"`          // 客户端ip
+	Uptime     int64  `json:"uptime"`      // 连接时间（秒）
+	LastActive int64  `json:"last_active"` // 最后一次活动时间（秒）
+	InMsgs     int64  `json:"in_msgs"`     // 收到的消息数量
+	OutMsgs    int64  `json:"out_msgs"`    // 发送的消息数量
+	InBytes    int64  `json:"in_bytes"`    // 收到的消息字节数
+	OutBytes   int64  `json:"out_bytes"`   // 发送的消息字节数
+}
+
+type LoginLogResp struct {
+	DeviceFlag  uint8  `json:"device_flag"`  // 设备标记 0. APP 1.web 2.pc
+	DeviceLevel uint8  `json:"device_level"` // 设备等级
+	DeviceId    string `json:"device_id"`    // 设备id
+	Ip          string `json:"ip"`           // 登录ip
+	NodeId      uint64 `json:"node_id"`      // 登录的节点
+	CreatedAt   int64  `json:"created_at"`   // 登录时间（秒）
+}
+
+func timeToUnix(t *time.Time) int64 {
+	if t == nil {
+		return 0
+	}
+	return t.Unix()
+}
+
+type presenceReq struct {
+	UID  string   `json:"uid"`  // 订阅者uid
+	Uids []string `json:"uids"` // 被订阅的用户uid集合
+}
+
+func (p presenceReq) Check() error {
+	if strings.TrimSpace(p.UID) == "" {
+		return errors.New("uid不能为空！")
+	}
+	if len(p.Uids) == 0 {
+		return errors.New("uids不能为空！")
+	}
+	return nil
 }
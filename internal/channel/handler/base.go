@@ -8,6 +8,7 @@ import (
 	"github.com/WuKongIM/WuKongIM/internal/ingress"
 	"github.com/WuKongIM/WuKongIM/internal/options"
 	"github.com/WuKongIM/WuKongIM/internal/service"
+	"github.com/WuKongIM/WuKongIM/pkg/ratelimit"
 	"github.com/WuKongIM/WuKongIM/pkg/wklog"
 	"github.com/WuKongIM/WuKongIM/pkg/wkserver/proto"
 	"go.uber.org/zap"
@@ -17,6 +18,7 @@ type Handler struct {
 	wklog.Log
 	client        *ingress.Client
 	commonService *common.Service
+	sendLimiter   *ratelimit.KeyLimiter // 频道发送速率限制
 }
 
 func NewHandler() *Handler {
@@ -24,6 +26,7 @@ func NewHandler() *Handler {
 		Log:           wklog.NewWKLog("handler"),
 		client:        ingress.NewClient(),
 		commonService: common.NewService(),
+		sendLimiter:   ratelimit.NewKeyLimiter(),
 	}
 	h.routes()
 	return h
@@ -7,7 +7,10 @@ import (
 	"github.com/WuKongIM/WuKongIM/internal/options"
 	"github.com/WuKongIM/WuKongIM/internal/service"
 	"github.com/WuKongIM/WuKongIM/internal/track"
+	"github.com/WuKongIM/WuKongIM/internal/types"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
 	"github.com/WuKongIM/WuKongIM/pkg/wkserver/proto"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
 	wkproto "github.com/WuKongIM/WuKongIMGoProto"
 	"go.uber.org/zap"
 )
@@ -24,7 +27,7 @@ func (h *Handler) permission(ctx *eventbus.ChannelContext) {
 	}
 
 	// --------------- 判断频道权限 ----------------
-	reasonCode, err := h.hasPermissionForChannel(channelId, channelType)
+	reasonCode, channelInfo, err := h.hasPermissionForChannel(channelId, channelType)
 	if err != nil {
 		h.Error("hasPermissionForChannel error", zap.Error(err))
 		reasonCode = wkproto.ReasonSystemError
@@ -49,35 +52,78 @@ func (h *Handler) permission(ctx *eventbus.ChannelContext) {
 		event.ReasonCode = reasonCode
 	}
 
+	// --------------- 判断发送速率 ----------------
+	if options.G.RateLimit.On {
+		for _, event := range events {
+			if event.ReasonCode != wkproto.ReasonSuccess {
+				continue
+			}
+			reasonCode = h.checkSendRateLimit(channelId, channelType, channelInfo, event)
+			if reasonCode != wkproto.ReasonSuccess {
+				h.Info("checkSendRateLimit failed", zap.String("fromUid", event.Conn.Uid), zap.String("channelId", channelId), zap.Uint8("channelType", channelType), zap.String("reasonCode", reasonCode.String()))
+				event.ReasonCode = reasonCode
+			}
+		}
+	}
+
 }
 
-func (h *Handler) hasPermissionForChannel(channelId string, channelType uint8) (wkproto.ReasonCode, error) {
+func (h *Handler) hasPermissionForChannel(channelId string, channelType uint8) (wkproto.ReasonCode, wkdb.ChannelInfo, error) {
 
 	// 资讯频道是公开的，直接通过
 	if channelType == wkproto.ChannelTypeInfo {
-		return wkproto.ReasonSuccess, nil
+		return wkproto.ReasonSuccess, wkdb.EmptyChannelInfo, nil
 	}
 	// 客服频道，直接通过
 	if channelType == wkproto.ChannelTypeCustomerService {
-		return wkproto.ReasonSuccess, nil
+		return wkproto.ReasonSuccess, wkdb.EmptyChannelInfo, nil
 	}
 
 	// 查询频道基本信息
 	channelInfo, err := service.Store.GetChannel(channelId, channelType)
 	if err != nil {
 		h.Error("hasPermission: GetChannel error", zap.Error(err))
-		return wkproto.ReasonSystemError, err
+		return wkproto.ReasonSystemError, channelInfo, err
 	}
 
 	// 频道被封禁
 	if channelInfo.Ban {
-		return wkproto.ReasonBan, nil
+		return wkproto.ReasonBan, channelInfo, nil
 	}
 	// 频道已解散
 	if channelInfo.Disband {
-		return wkproto.ReasonDisband, nil
+		return wkproto.ReasonDisband, channelInfo, nil
 	}
-	return wkproto.ReasonSuccess, nil
+	return wkproto.ReasonSuccess, channelInfo, nil
+}
+
+// 判断发送速率是否超过限制（系统账号和系统设备不限制）
+// 先判断频道的整体速率，再判断发送者在此频道的速率（慢速模式）
+func (h *Handler) checkSendRateLimit(channelId string, channelType uint8, channelInfo wkdb.ChannelInfo, e *eventbus.Event) wkproto.ReasonCode {
+	fromUid := e.Conn.Uid
+	if options.G.IsSystemDevice(e.Conn.DeviceId) || service.SystemAccountManager.IsSystemAccount(fromUid) {
+		return wkproto.ReasonSuccess
+	}
+	channelKey := wkutil.ChannelToKey(channelId, channelType)
+
+	// 频道的发送速率，频道配置优先
+	channelLimit := options.G.RateLimit.Channel
+	if channelInfo.SendRateLimit > 0 {
+		channelLimit = options.RateLimitConfig{Rate: float64(channelInfo.SendRateLimit)}
+	}
+	if !h.sendLimiter.Allow(channelKey, channelLimit.Rate, channelLimit.Burst) {
+		return types.ReasonChannelRateLimit
+	}
+
+	// 发送者在此频道的发送速率，频道开启慢速模式时每SlowMode秒只能发送一条
+	userChannelLimit := options.G.RateLimit.UserChannel
+	if channelInfo.SlowMode > 0 {
+		userChannelLimit = options.RateLimitConfig{Rate: 1 / float64(channelInfo.SlowMode), Burst: 1}
+	}
+	if !h.sendLimiter.Allow(fromUid+"@"+channelKey, userChannelLimit.Rate, userChannelLimit.Burst) {
+		return types.ReasonSlowMode
+	}
+	return wkproto.ReasonSuccess
 }
 
 // 判断发送者是否有权限
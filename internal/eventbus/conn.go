@@ -47,6 +47,8 @@ type Conn struct {
 	ProtoVersion uint8
 	// 启动时间
 	Uptime uint64
+	// 客户端ip
+	ClientIp string
 
 	// 不参与编码
 	LastActive uint64 // 最后一次活动时间单位秒
@@ -65,6 +67,7 @@ func (c *Conn) Encode() ([]byte, error) {
 	enc.WriteBinary(c.AesKey)
 	enc.WriteUint8(c.ProtoVersion)
 	enc.WriteUint64(c.Uptime)
+	enc.WriteString(c.ClientIp)
 	return enc.Bytes(), nil
 }
 
@@ -115,11 +118,18 @@ func (c *Conn) Decode(data []byte) error {
 		return err
 	}
 
+	// 兼容旧版本的数据
+	if dec.Len() > 0 {
+		if c.ClientIp, err = dec.String(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 func (c *Conn) Size() uint64 {
-	return uint64(8 + len(c.Uid) + len(c.DeviceId) + 1 + 1 + 8 + 1 + len(c.AesIV) + len(c.AesKey) + 1)
+	return uint64(8 + len(c.Uid) + len(c.DeviceId) + 1 + 1 + 8 + 1 + len(c.AesIV) + len(c.AesKey) + 1 + len(c.ClientIp))
 }
 
 func (c *Conn) Equal(cn *Conn) bool {
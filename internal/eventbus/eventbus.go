@@ -1,6 +1,8 @@
 package eventbus
 
 import (
+	"github.com/WuKongIM/WuKongIM/internal/wsjson"
+	"github.com/WuKongIM/WuKongIM/pkg/wknet"
 	wkproto "github.com/WuKongIM/WuKongIMGoProto"
 )
 
@@ -56,3 +58,11 @@ func ExecutePusherEvent(ctx *PushContext) {
 
 // 通讯协议
 var Proto wkproto.Protocol = wkproto.New()
+
+// ConnProto 连接使用的协议编解码，websocket连接协商了json子协议时使用json协议，否则使用悟空IM的二进制协议
+func ConnProto(conn wknet.Conn) wkproto.Protocol {
+	if wsjson.IsJsonConn(conn) {
+		return wsjson.Proto
+	}
+	return Proto
+}
@@ -2,11 +2,13 @@ package ingress
 
 import (
 	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"time"
 
 	"github.com/WuKongIM/WuKongIM/internal/service"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
 	"github.com/WuKongIM/WuKongIM/pkg/wklog"
 	"github.com/WuKongIM/WuKongIM/pkg/wkserver/proto"
 	"go.uber.org/zap"
@@ -121,6 +123,183 @@ func (c *Client) RequestSubscribers(toNodeId uint64, channelId string, channelTy
 	return subResp.Subscribers, nil
 }
 
+// PresenceSubscribe 在被订阅用户的leader节点上订阅在线状态
+func (c *Client) PresenceSubscribe(toNodeId uint64, req *PresenceReq) error {
+	return c.requestPresence(toNodeId, "/wk/ingress/presenceSubscribe", req)
+}
+
+// PresenceUnsubscribe 在被订阅用户的leader节点上取消订阅在线状态
+func (c *Client) PresenceUnsubscribe(toNodeId uint64, req *PresenceReq) error {
+	return c.requestPresence(toNodeId, "/wk/ingress/presenceUnsubscribe", req)
+}
+
+func (c *Client) requestPresence(toNodeId uint64, path string, req *PresenceReq) error {
+	data, err := req.Encode()
+	if err != nil {
+		return err
+	}
+	resp, err := c.request(toNodeId, path, data)
+	if err != nil {
+		return err
+	}
+	return c.handleRespError(resp)
+}
+
+// ReloadApiKeys 通知节点重新加载api key
+func (c *Client) ReloadApiKeys(toNodeId uint64) error {
+	resp, err := c.request(toNodeId, "/wk/ingress/reloadApiKeys", nil)
+	if err != nil {
+		return err
+	}
+	return c.handleRespError(resp)
+}
+
+// GetApiKeys 从slot 0的领导节点获取api key列表
+func (c *Client) GetApiKeys(toNodeId uint64) ([]wkdb.ApiKey, error) {
+	resp, err := c.request(toNodeId, "/wk/ingress/getApiKeys", nil)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.handleRespError(resp); err != nil {
+		return nil, err
+	}
+	apiKeysResp := &ApiKeysResp{}
+	if err = apiKeysResp.Decode(resp.Body); err != nil {
+		return nil, err
+	}
+	return apiKeysResp.ApiKeys, nil
+}
+
+// AddApiKey 请求slot 0的领导节点创建api key，返回api key和只返回一次的key明文
+func (c *Client) AddApiKey(toNodeId uint64, req *ApiKeyAddReq) (*ApiKeyAddResp, error) {
+	data, err := req.Encode()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.request(toNodeId, "/wk/ingress/addApiKey", data)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.handleRespError(resp); err != nil {
+		return nil, err
+	}
+	addResp := &ApiKeyAddResp{}
+	if err = addResp.Decode(resp.Body); err != nil {
+		return nil, err
+	}
+	return addResp, nil
+}
+
+// RemoveApiKey 请求slot 0的领导节点移除api key
+func (c *Client) RemoveApiKey(toNodeId uint64, id uint64) error {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, id)
+	resp, err := c.request(toNodeId, "/wk/ingress/removeApiKey", data)
+	if err != nil {
+		return err
+	}
+	return c.handleRespError(resp)
+}
+
+// RevokeToken 请求slot 0的领导节点吊销管理后台token
+func (c *Client) RevokeToken(toNodeId uint64, token wkdb.RevokedToken) error {
+	resp, err := c.request(toNodeId, "/wk/ingress/revokeToken", token.Encode())
+	if err != nil {
+		return err
+	}
+	return c.handleRespError(resp)
+}
+
+// AddRevokedToken 添加吊销记录到节点的缓存
+func (c *Client) AddRevokedToken(toNodeId uint64, token wkdb.RevokedToken) error {
+	resp, err := c.request(toNodeId, "/wk/ingress/addRevokedToken", token.Encode())
+	if err != nil {
+		return err
+	}
+	return c.handleRespError(resp)
+}
+
+// GetRevokedTokens 从slot 0的领导节点获取吊销列表
+func (c *Client) GetRevokedTokens(toNodeId uint64) ([]wkdb.RevokedToken, error) {
+	resp, err := c.request(toNodeId, "/wk/ingress/getRevokedTokens", nil)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.handleRespError(resp); err != nil {
+		return nil, err
+	}
+	tokensResp := &RevokedTokensResp{}
+	if err = tokensResp.Decode(resp.Body); err != nil {
+		return nil, err
+	}
+	return tokensResp.Tokens, nil
+}
+
+// ReloadIpRules 通知节点重新加载ip规则
+func (c *Client) ReloadIpRules(toNodeId uint64) error {
+	resp, err := c.request(toNodeId, "/wk/ingress/reloadIpRules", nil)
+	if err != nil {
+		return err
+	}
+	return c.handleRespError(resp)
+}
+
+// GetIpRules 从slot 0的领导节点获取ip规则
+func (c *Client) GetIpRules(toNodeId uint64) ([]wkdb.IpRule, error) {
+	resp, err := c.request(toNodeId, "/wk/ingress/getIpRules", nil)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.handleRespError(resp); err != nil {
+		return nil, err
+	}
+	rulesResp := &IpRulesResp{}
+	if err = rulesResp.Decode(resp.Body); err != nil {
+		return nil, err
+	}
+	return rulesResp.Rules, nil
+}
+
+// AddIpRule 请求slot 0的领导节点添加ip规则
+func (c *Client) AddIpRule(toNodeId uint64, req *IpRuleAddReq) (wkdb.IpRule, error) {
+	data, err := req.Encode()
+	if err != nil {
+		return wkdb.IpRule{}, err
+	}
+	resp, err := c.request(toNodeId, "/wk/ingress/addIpRule", data)
+	if err != nil {
+		return wkdb.IpRule{}, err
+	}
+	if err = c.handleRespError(resp); err != nil {
+		return wkdb.IpRule{}, err
+	}
+	var rule wkdb.IpRule
+	if err = rule.Decode(resp.Body); err != nil {
+		return wkdb.IpRule{}, err
+	}
+	return rule, nil
+}
+
+// RemoveIpRule 请求slot 0的领导节点移除ip规则
+func (c *Client) RemoveIpRule(toNodeId uint64, id uint64) error {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, id)
+	resp, err := c.request(toNodeId, "/wk/ingress/removeIpRule", data)
+	if err != nil {
+		return err
+	}
+	return c.handleRespError(resp)
+}
+
+// UnbanIp 解封节点自动封禁的ip
+func (c *Client) UnbanIp(toNodeId uint64, ip string) error {
+	resp, err := c.request(toNodeId, "/wk/ingress/unbanIp", []byte(ip))
+	if err != nil {
+		return err
+	}
+	return c.handleRespError(resp)
+}
+
 func (c *Client) request(toNodeId uint64, path string, body []byte) (*proto.Response, error) {
 	timeoutCtx, cancel := context.WithTimeout(context.Background(), time.Second*2)
 	defer cancel()
@@ -1,6 +1,7 @@
 package ingress
 
 import (
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
 	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
 	wkproto "github.com/WuKongIM/WuKongIMGoProto"
 )
@@ -252,3 +253,233 @@ func (s *SubscribersResp) Decode(data []byte) error {
 	}
 	return nil
 }
+
+type PresenceReq struct {
+	Watcher string   // 订阅者
+	Uids    []string // 被订阅的用户
+}
+
+func (p *PresenceReq) Encode() ([]byte, error) {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteString(p.Watcher)
+	enc.WriteUint32(uint32(len(p.Uids)))
+	for _, uid := range p.Uids {
+		enc.WriteString(uid)
+	}
+	return enc.Bytes(), nil
+}
+
+func (p *PresenceReq) Decode(data []byte) error {
+	dec := wkproto.NewDecoder(data)
+	var err error
+	if p.Watcher, err = dec.String(); err != nil {
+		return err
+	}
+	count, err := dec.Uint32()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < int(count); i++ {
+		uid, err := dec.String()
+		if err != nil {
+			return err
+		}
+		p.Uids = append(p.Uids, uid)
+	}
+	return nil
+}
+
+type RevokedTokensResp struct {
+	Tokens []wkdb.RevokedToken
+}
+
+func (r *RevokedTokensResp) Encode() ([]byte, error) {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteUint32(uint32(len(r.Tokens)))
+	for _, token := range r.Tokens {
+		enc.WriteBinary(token.Encode())
+	}
+	return enc.Bytes(), nil
+}
+
+func (r *RevokedTokensResp) Decode(data []byte) error {
+	dec := wkproto.NewDecoder(data)
+	count, err := dec.Uint32()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < int(count); i++ {
+		tokenData, err := dec.Binary()
+		if err != nil {
+			return err
+		}
+		var token wkdb.RevokedToken
+		if err = token.Decode(tokenData); err != nil {
+			return err
+		}
+		r.Tokens = append(r.Tokens, token)
+	}
+	return nil
+}
+
+type IpRulesResp struct {
+	Rules []wkdb.IpRule
+}
+
+func (r *IpRulesResp) Encode() ([]byte, error) {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteUint32(uint32(len(r.Rules)))
+	for _, rule := range r.Rules {
+		enc.WriteBinary(rule.Encode())
+	}
+	return enc.Bytes(), nil
+}
+
+func (r *IpRulesResp) Decode(data []byte) error {
+	dec := wkproto.NewDecoder(data)
+	count, err := dec.Uint32()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < int(count); i++ {
+		ruleData, err := dec.Binary()
+		if err != nil {
+			return err
+		}
+		var rule wkdb.IpRule
+		if err = rule.Decode(ruleData); err != nil {
+			return err
+		}
+		r.Rules = append(r.Rules, rule)
+	}
+	return nil
+}
+
+type IpRuleAddReq struct {
+	Cidr   string
+	Action wkdb.IpRuleAction
+	Remark string
+}
+
+func (r *IpRuleAddReq) Encode() ([]byte, error) {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteString(r.Cidr)
+	enc.WriteUint8(uint8(r.Action))
+	enc.WriteString(r.Remark)
+	return enc.Bytes(), nil
+}
+
+func (r *IpRuleAddReq) Decode(data []byte) error {
+	dec := wkproto.NewDecoder(data)
+	var err error
+	if r.Cidr, err = dec.String(); err != nil {
+		return err
+	}
+	action, err := dec.Uint8()
+	if err != nil {
+		return err
+	}
+	r.Action = wkdb.IpRuleAction(action)
+	r.Remark, err = dec.String()
+	return err
+}
+
+type ApiKeysResp struct {
+	ApiKeys []wkdb.ApiKey
+}
+
+func (r *ApiKeysResp) Encode() ([]byte, error) {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteUint32(uint32(len(r.ApiKeys)))
+	for _, apiKey := range r.ApiKeys {
+		enc.WriteBinary(apiKey.Encode())
+	}
+	return enc.Bytes(), nil
+}
+
+func (r *ApiKeysResp) Decode(data []byte) error {
+	dec := wkproto.NewDecoder(data)
+	count, err := dec.Uint32()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < int(count); i++ {
+		apiKeyData, err := dec.Binary()
+		if err != nil {
+			return err
+		}
+		var apiKey wkdb.ApiKey
+		if err = apiKey.Decode(apiKeyData); err != nil {
+			return err
+		}
+		r.ApiKeys = append(r.ApiKeys, apiKey)
+	}
+	return nil
+}
+
+type ApiKeyAddReq struct {
+	Name   string
+	Scopes []string
+}
+
+func (r *ApiKeyAddReq) Encode() ([]byte, error) {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteString(r.Name)
+	enc.WriteUint32(uint32(len(r.Scopes)))
+	for _, scope := range r.Scopes {
+		enc.WriteString(scope)
+	}
+	return enc.Bytes(), nil
+}
+
+func (r *ApiKeyAddReq) Decode(data []byte) error {
+	dec := wkproto.NewDecoder(data)
+	var err error
+	if r.Name, err = dec.String(); err != nil {
+		return err
+	}
+	count, err := dec.Uint32()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < int(count); i++ {
+		scope, err := dec.String()
+		if err != nil {
+			return err
+		}
+		r.Scopes = append(r.Scopes, scope)
+	}
+	return nil
+}
+
+type ApiKeyAddResp struct {
+	ApiKey wkdb.ApiKey
+	Key    string // key明文，只在创建时返回一次
+}
+
+func (r *ApiKeyAddResp) Encode() ([]byte, error) {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteBinary(r.ApiKey.Encode())
+	enc.WriteString(r.Key)
+	return enc.Bytes(), nil
+}
+
+func (r *ApiKeyAddResp) Decode(data []byte) error {
+	dec := wkproto.NewDecoder(data)
+	apiKeyData, err := dec.Binary()
+	if err != nil {
+		return err
+	}
+	if err = r.ApiKey.Decode(apiKeyData); err != nil {
+		return err
+	}
+	r.Key, err = dec.String()
+	return err
+}
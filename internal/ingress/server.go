@@ -1,9 +1,12 @@
 package ingress
 
 import (
+	"encoding/binary"
 	"errors"
+	"strings"
 
 	"github.com/WuKongIM/WuKongIM/internal/service"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
 	"github.com/WuKongIM/WuKongIM/pkg/wklog"
 	"github.com/WuKongIM/WuKongIM/pkg/wkserver"
 	"github.com/WuKongIM/WuKongIM/pkg/wkserver/proto"
@@ -34,6 +37,34 @@ func (i *Ingress) SetRoutes() {
 	service.Cluster.Route("/wk/ingress/addTag", i.handleAddTag)
 	// 获取订阅者
 	service.Cluster.Route("/wk/ingress/getSubscribers", i.handleGetSubscribers)
+	// 订阅在线状态（在被订阅用户的leader节点上保存订阅关系）
+	service.Cluster.Route("/wk/ingress/presenceSubscribe", i.handlePresenceSubscribe)
+	// 取消订阅在线状态
+	service.Cluster.Route("/wk/ingress/presenceUnsubscribe", i.handlePresenceUnsubscribe)
+	// api key变化，重新加载api key
+	service.Cluster.Route("/wk/ingress/reloadApiKeys", i.handleReloadApiKeys)
+	// 获取api key列表（在slot 0的领导节点上执行）
+	service.Cluster.Route("/wk/ingress/getApiKeys", i.handleGetApiKeys)
+	// 创建api key（在slot 0的领导节点上执行）
+	service.Cluster.Route("/wk/ingress/addApiKey", i.handleAddApiKey)
+	// 移除api key（在slot 0的领导节点上执行）
+	service.Cluster.Route("/wk/ingress/removeApiKey", i.handleRemoveApiKey)
+	// 吊销管理后台token（在slot 0的领导节点上执行）
+	service.Cluster.Route("/wk/ingress/revokeToken", i.handleRevokeToken)
+	// 添加吊销记录到缓存
+	service.Cluster.Route("/wk/ingress/addRevokedToken", i.handleAddRevokedToken)
+	// 获取吊销列表
+	service.Cluster.Route("/wk/ingress/getRevokedTokens", i.handleGetRevokedTokens)
+	// ip规则变化，重新加载ip规则
+	service.Cluster.Route("/wk/ingress/reloadIpRules", i.handleReloadIpRules)
+	// 获取ip规则
+	service.Cluster.Route("/wk/ingress/getIpRules", i.handleGetIpRules)
+	// 添加ip规则（在slot 0的领导节点上执行）
+	service.Cluster.Route("/wk/ingress/addIpRule", i.handleAddIpRule)
+	// 移除ip规则（在slot 0的领导节点上执行）
+	service.Cluster.Route("/wk/ingress/removeIpRule", i.handleRemoveIpRule)
+	// 解封节点自动封禁的ip
+	service.Cluster.Route("/wk/ingress/unbanIp", i.handleUnbanIp)
 
 }
 
@@ -233,3 +264,203 @@ func (i *Ingress) handleGetSubscribers(c *wkserver.Context) {
 	}
 	c.Write(data)
 }
+
+func (i *Ingress) handlePresenceSubscribe(c *wkserver.Context) {
+	req := &PresenceReq{}
+	err := req.Decode(c.Body())
+	if err != nil {
+		i.Error("handlePresenceSubscribe: decode failed", zap.Error(err))
+		c.WriteErr(err)
+		return
+	}
+	err = service.PresenceManager.Subscribe(req.Watcher, req.Uids)
+	if err != nil {
+		i.Error("handlePresenceSubscribe: subscribe failed", zap.Error(err), zap.String("watcher", req.Watcher))
+		c.WriteErr(err)
+		return
+	}
+	c.WriteOk()
+}
+
+func (i *Ingress) handlePresenceUnsubscribe(c *wkserver.Context) {
+	req := &PresenceReq{}
+	err := req.Decode(c.Body())
+	if err != nil {
+		i.Error("handlePresenceUnsubscribe: decode failed", zap.Error(err))
+		c.WriteErr(err)
+		return
+	}
+	service.PresenceManager.Unsubscribe(req.Watcher, req.Uids)
+	c.WriteOk()
+}
+
+// 不接收api key的数据，从slot 0的领导节点重新加载
+func (i *Ingress) handleReloadApiKeys(c *wkserver.Context) {
+	if err := service.ApiKeyManager.Reload(); err != nil {
+		i.Error("reloadApiKeys: reload failed", zap.Error(err))
+		c.WriteErr(err)
+		return
+	}
+	c.WriteOk()
+}
+
+func (i *Ingress) handleGetApiKeys(c *wkserver.Context) {
+	apiKeys, err := service.Store.GetApiKeys()
+	if err != nil {
+		i.Error("getApiKeys: get failed", zap.Error(err))
+		c.WriteErr(err)
+		return
+	}
+	resp := &ApiKeysResp{ApiKeys: apiKeys}
+	data, err := resp.Encode()
+	if err != nil {
+		c.WriteErr(err)
+		return
+	}
+	c.Write(data)
+}
+
+func (i *Ingress) handleAddApiKey(c *wkserver.Context) {
+	req := &ApiKeyAddReq{}
+	if err := req.Decode(c.Body()); err != nil {
+		i.Error("addApiKey decode err", zap.Error(err))
+		c.WriteErr(err)
+		return
+	}
+	apiKey, key, err := service.ApiKeyManager.AddApiKey(req.Name, req.Scopes)
+	if err != nil {
+		i.Error("addApiKey: add failed", zap.Error(err), zap.String("name", req.Name))
+		c.WriteErr(err)
+		return
+	}
+	resp := &ApiKeyAddResp{ApiKey: apiKey, Key: key}
+	data, err := resp.Encode()
+	if err != nil {
+		c.WriteErr(err)
+		return
+	}
+	c.Write(data)
+}
+
+func (i *Ingress) handleRemoveApiKey(c *wkserver.Context) {
+	if len(c.Body()) != 8 {
+		i.Error("removeApiKey: invalid id", zap.Int("len", len(c.Body())))
+		c.WriteErr(errors.New("invalid api key id"))
+		return
+	}
+	id := binary.BigEndian.Uint64(c.Body())
+	if err := service.ApiKeyManager.RemoveApiKey(id); err != nil {
+		i.Error("removeApiKey: remove failed", zap.Error(err), zap.Uint64("id", id))
+		c.WriteErr(err)
+		return
+	}
+	c.WriteOk()
+}
+
+func (i *Ingress) handleRevokeToken(c *wkserver.Context) {
+	var token wkdb.RevokedToken
+	if err := token.Decode(c.Body()); err != nil {
+		i.Error("revokeToken decode err", zap.Error(err))
+		c.WriteErr(err)
+		return
+	}
+	if err := service.TokenRevokeManager.Revoke(token); err != nil {
+		i.Error("revokeToken: revoke failed", zap.Error(err), zap.String("id", token.Id))
+		c.WriteErr(err)
+		return
+	}
+	c.WriteOk()
+}
+
+func (i *Ingress) handleAddRevokedToken(c *wkserver.Context) {
+	var token wkdb.RevokedToken
+	if err := token.Decode(c.Body()); err != nil {
+		i.Error("addRevokedToken decode err", zap.Error(err))
+		c.WriteErr(err)
+		return
+	}
+	service.TokenRevokeManager.AddRevokedToCache(token)
+	c.WriteOk()
+}
+
+func (i *Ingress) handleGetRevokedTokens(c *wkserver.Context) {
+	tokens, err := service.Store.GetRevokedTokens()
+	if err != nil {
+		i.Error("getRevokedTokens: get failed", zap.Error(err))
+		c.WriteErr(err)
+		return
+	}
+	resp := &RevokedTokensResp{Tokens: tokens}
+	data, err := resp.Encode()
+	if err != nil {
+		c.WriteErr(err)
+		return
+	}
+	c.Write(data)
+}
+
+func (i *Ingress) handleReloadIpRules(c *wkserver.Context) {
+	if err := service.AdmissionManager.ReloadIpRules(); err != nil {
+		i.Error("reloadIpRules: reload failed", zap.Error(err))
+		c.WriteErr(err)
+		return
+	}
+	c.WriteOk()
+}
+
+func (i *Ingress) handleGetIpRules(c *wkserver.Context) {
+	rules, err := service.Store.GetIpRules()
+	if err != nil {
+		i.Error("getIpRules: get failed", zap.Error(err))
+		c.WriteErr(err)
+		return
+	}
+	resp := &IpRulesResp{Rules: rules}
+	data, err := resp.Encode()
+	if err != nil {
+		c.WriteErr(err)
+		return
+	}
+	c.Write(data)
+}
+
+func (i *Ingress) handleAddIpRule(c *wkserver.Context) {
+	req := &IpRuleAddReq{}
+	if err := req.Decode(c.Body()); err != nil {
+		i.Error("addIpRule decode err", zap.Error(err))
+		c.WriteErr(err)
+		return
+	}
+	rule, err := service.AdmissionManager.AddIpRule(req.Cidr, req.Action, req.Remark)
+	if err != nil {
+		i.Error("addIpRule: add failed", zap.Error(err), zap.String("cidr", req.Cidr))
+		c.WriteErr(err)
+		return
+	}
+	c.Write(rule.Encode())
+}
+
+func (i *Ingress) handleRemoveIpRule(c *wkserver.Context) {
+	if len(c.Body()) != 8 {
+		i.Error("removeIpRule: invalid id", zap.Int("len", len(c.Body())))
+		c.WriteErr(errors.New("invalid ip rule id"))
+		return
+	}
+	id := binary.BigEndian.Uint64(c.Body())
+	if err := service.AdmissionManager.RemoveIpRule(id); err != nil {
+		i.Error("removeIpRule: remove failed", zap.Error(err), zap.Uint64("id", id))
+		c.WriteErr(err)
+		return
+	}
+	c.WriteOk()
+}
+
+func (i *Ingress) handleUnbanIp(c *wkserver.Context) {
+	ip := string(c.Body())
+	if strings.TrimSpace(ip) == "" {
+		c.WriteErr(errors.New("ip is empty"))
+		return
+	}
+	service.AdmissionManager.Unban(ip)
+	c.WriteOk()
+}
@@ -0,0 +1,495 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/internal/ingress"
+	"github.com/WuKongIM/WuKongIM/internal/options"
+	"github.com/WuKongIM/WuKongIM/internal/service"
+	"github.com/WuKongIM/WuKongIM/pkg/ratelimit"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb/key"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrIpBanned        = errors.New("ip is banned")
+	ErrIpDenied        = errors.New("ip is in deny list")
+	ErrIpNotAllowed    = errors.New("ip is not in allow list")
+	ErrConnRateLimit   = errors.New("connect rate limit")
+	ErrTooManyConns    = errors.New("too many connections from ip")
+	ErrInvalidIpRule   = errors.New("invalid ip rule")
+	ErrIpRuleNotLoaded = errors.New("ip rules not loaded")
+)
+
+// AdmissionManager 连接准入控制
+// 静态规则来自配置，动态规则（ip黑白名单）存储在slot 0上，每个节点缓存一份，添加和移除后通过节点之间的rpc通知各个节点重新加载
+// 每个ip的连接数、新建连接速率和认证失败的自动封禁只统计当前节点
+type AdmissionManager struct {
+	mu            sync.RWMutex
+	rules         map[uint64]ipRule // 动态规则 id -> rule
+	staticAllows  []netip.Prefix    // 静态白名单
+	staticDenies  []netip.Prefix    // 静态黑名单
+	trustedProxys []netip.Prefix    // 受信任的代理
+
+	connMu     sync.Mutex
+	connIps    map[int64]string // connId -> ip
+	ipConnNums map[string]int   // ip -> 连接数量
+	authFails  map[string]*authFail
+	bans       map[string]time.Time    // ip -> 解封时间
+	pendings   map[int64]*pendingAdmit // 等待代理协议头的连接 connId -> 待判断
+
+	connLimiter *ratelimit.KeyLimiter // 新建连接的速率限制
+
+	loaded  bool
+	loading bool
+	loadMu  sync.Mutex
+
+	client *ingress.Client
+	stopC  chan struct{}
+	wklog.Log
+}
+
+type ipRule struct {
+	wkdb.IpRule
+	prefix netip.Prefix
+}
+
+type pendingAdmit struct {
+	proxyIp string
+	timer   *time.Timer
+}
+
+type authFail struct {
+	count   int
+	startAt time.Time
+}
+
+func NewAdmissionManager() *AdmissionManager {
+	a := &AdmissionManager{
+		rules:       make(map[uint64]ipRule),
+		connIps:     make(map[int64]string),
+		ipConnNums:  make(map[string]int),
+		authFails:   make(map[string]*authFail),
+		bans:        make(map[string]time.Time),
+		pendings:    make(map[int64]*pendingAdmit),
+		connLimiter: ratelimit.NewKeyLimiter(),
+		client:      ingress.NewClient(),
+		stopC:       make(chan struct{}),
+		Log:         wklog.NewWKLog("AdmissionManager"),
+	}
+	a.staticAllows = a.parsePrefixes(options.G.Admission.AllowCidrs)
+	a.staticDenies = a.parsePrefixes(options.G.Admission.DenyCidrs)
+	a.trustedProxys = a.parsePrefixes(options.G.Admission.TrustedProxies)
+	return a
+}
+
+func (a *AdmissionManager) Start() error {
+	go a.loopClean()
+	return nil
+}
+
+func (a *AdmissionManager) Stop() {
+	close(a.stopC)
+}
+
+// Admit 判断ip是否允许建立连接
+func (a *AdmissionManager) Admit(connId int64, ip string) error {
+	if !options.G.Admission.On {
+		return nil
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil { // 不是ip地址（比如unix socket）不做判断
+		return nil
+	}
+	addr = addr.Unmap()
+	ip = addr.String() // 统计和封禁都以规范后的ip为key（ipv4映射的ipv6地址转为ipv4）
+	a.loadIfNeed()
+
+	a.connMu.Lock()
+	defer a.connMu.Unlock()
+
+	a.cancelPending(connId)
+	if oldIp, ok := a.connIps[connId]; ok { // 代理协议解析出真实ip后会重新判断
+		a.releaseIp(connId, oldIp)
+	}
+
+	if banAt, ok := a.bans[ip]; ok {
+		if time.Now().Before(banAt) {
+			return ErrIpBanned
+		}
+		delete(a.bans, ip)
+	}
+	if err = a.checkRules(addr); err != nil {
+		return err
+	}
+
+	cfg := options.G.Admission
+	if !a.connLimiter.Allow(ip, cfg.ConnRate, cfg.ConnBurst) {
+		return ErrConnRateLimit
+	}
+	if cfg.MaxConnPerIp > 0 && a.ipConnNums[ip] >= cfg.MaxConnPerIp {
+		return ErrTooManyConns
+	}
+	a.connIps[connId] = ip
+	a.ipConnNums[ip]++
+	return nil
+}
+
+// AdmitAfter 等待代理协议头，超时后以代理的ip做准入判断
+// 受信任的代理不一定会发送代理协议头，不能因为等待代理协议头而跳过准入判断
+func (a *AdmissionManager) AdmitAfter(connId int64, proxyIp string, timeout time.Duration, onReject func(err error)) {
+	if !options.G.Admission.On {
+		return
+	}
+	a.connMu.Lock()
+	defer a.connMu.Unlock()
+	a.cancelPending(connId)
+	pending := &pendingAdmit{proxyIp: proxyIp}
+	pending.timer = time.AfterFunc(timeout, func() {
+		if !a.takePending(connId, pending) { // 已经通过真实ip判断或连接已关闭
+			return
+		}
+		if err := a.Admit(connId, proxyIp); err != nil && onReject != nil {
+			onReject(err)
+		}
+	})
+	a.pendings[connId] = pending
+}
+
+// AdmitNow 立即以代理的ip做准入判断
+func (a *AdmissionManager) AdmitNow(connId int64) error {
+	a.connMu.Lock()
+	pending := a.pendings[connId]
+	a.connMu.Unlock()
+	if pending == nil || !a.takePending(connId, pending) {
+		return nil
+	}
+	pending.timer.Stop()
+	return a.Admit(connId, pending.proxyIp)
+}
+
+// 取出待判断的连接，返回false表示已经被取走
+func (a *AdmissionManager) takePending(connId int64, pending *pendingAdmit) bool {
+	a.connMu.Lock()
+	defer a.connMu.Unlock()
+	if a.pendings[connId] != pending {
+		return false
+	}
+	delete(a.pendings, connId)
+	return true
+}
+
+func (a *AdmissionManager) cancelPending(connId int64) {
+	if pending, ok := a.pendings[connId]; ok {
+		pending.timer.Stop()
+		delete(a.pendings, connId)
+	}
+}
+
+// Release 释放连接
+func (a *AdmissionManager) Release(connId int64) {
+	a.connMu.Lock()
+	defer a.connMu.Unlock()
+	a.cancelPending(connId)
+	if ip, ok := a.connIps[connId]; ok {
+		a.releaseIp(connId, ip)
+	}
+}
+
+func (a *AdmissionManager) releaseIp(connId int64, ip string) {
+	delete(a.connIps, connId)
+	a.ipConnNums[ip]--
+	if a.ipConnNums[ip] <= 0 {
+		delete(a.ipConnNums, ip)
+	}
+}
+
+// IsTrustedProxy 是否是受信任的代理
+func (a *AdmissionManager) IsTrustedProxy(ip string) bool {
+	if len(a.trustedProxys) == 0 {
+		return false
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	return containsAddr(a.trustedProxys, addr.Unmap())
+}
+
+// AuthFail 记录认证失败
+func (a *AdmissionManager) AuthFail(ip string) {
+	cfg := options.G.Admission
+	if !cfg.On || cfg.AuthFailMax <= 0 || strings.TrimSpace(ip) == "" {
+		return
+	}
+	if a.IsTrustedProxy(ip) { // 没有解析到真实ip的代理连接，不能封禁代理
+		return
+	}
+	ip = normalizeIp(ip)
+	now := time.Now()
+	a.connMu.Lock()
+	defer a.connMu.Unlock()
+	fail := a.authFails[ip]
+	if fail == nil || now.Sub(fail.startAt) > cfg.AuthFailWindow {
+		fail = &authFail{startAt: now}
+		a.authFails[ip] = fail
+	}
+	fail.count++
+	if fail.count >= cfg.AuthFailMax {
+		delete(a.authFails, ip)
+		a.bans[ip] = now.Add(cfg.BanDuration)
+		a.Warn("ip is banned because of too many auth failures", zap.String("ip", ip), zap.Int("authFails", fail.count), zap.Duration("banDuration", cfg.BanDuration))
+	}
+}
+
+// Bans 当前节点自动封禁的ip
+func (a *AdmissionManager) Bans() map[string]int64 {
+	now := time.Now()
+	a.connMu.Lock()
+	defer a.connMu.Unlock()
+	bans := make(map[string]int64, len(a.bans))
+	for ip, banAt := range a.bans {
+		if now.Before(banAt) {
+			bans[ip] = banAt.Unix()
+		}
+	}
+	return bans
+}
+
+// Unban 解封ip
+func (a *AdmissionManager) Unban(ip string) {
+	ip = normalizeIp(ip)
+	a.connMu.Lock()
+	defer a.connMu.Unlock()
+	delete(a.bans, ip)
+	delete(a.authFails, ip)
+}
+
+// AddIpRule 添加ip规则
+func (a *AdmissionManager) AddIpRule(cidr string, action wkdb.IpRuleAction, remark string) (wkdb.IpRule, error) {
+	prefix, err := parsePrefix(cidr)
+	if err != nil {
+		return wkdb.IpRule{}, err
+	}
+	if action != wkdb.IpRuleAllow && action != wkdb.IpRuleDeny {
+		return wkdb.IpRule{}, ErrInvalidIpRule
+	}
+	createdAt := time.Now()
+	rule := wkdb.IpRule{
+		Id:        key.HashWithString(fmt.Sprintf("%d:%s", action, prefix.String())),
+		Cidr:      prefix.String(),
+		Action:    action,
+		Remark:    remark,
+		CreatedAt: &createdAt,
+	}
+	if err = service.Store.AddOrUpdateIpRule(rule); err != nil {
+		return wkdb.IpRule{}, err
+	}
+	a.addToCache(rule)
+	return rule, nil
+}
+
+func (a *AdmissionManager) addToCache(rule wkdb.IpRule) {
+	prefix, err := parsePrefix(rule.Cidr)
+	if err != nil {
+		a.Warn("addToCache: invalid cidr", zap.String("cidr", rule.Cidr), zap.Error(err))
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.rules[rule.Id] = ipRule{IpRule: rule, prefix: prefix}
+}
+
+// RemoveIpRule 移除ip规则
+func (a *AdmissionManager) RemoveIpRule(id uint64) error {
+	if err := service.Store.RemoveIpRule(id); err != nil {
+		return err
+	}
+	a.removeFromCache(id)
+	return nil
+}
+
+func (a *AdmissionManager) removeFromCache(id uint64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.rules, id)
+}
+
+// ReloadIpRules 从slot 0的领导节点重新加载ip规则，替换掉缓存
+func (a *AdmissionManager) ReloadIpRules() error {
+	a.loadMu.Lock()
+	defer a.loadMu.Unlock()
+	rules, err := a.getOrRequestIpRules()
+	if err != nil {
+		return err
+	}
+	a.setRules(rules)
+	a.loaded = true
+	return nil
+}
+
+func (a *AdmissionManager) setRules(rules []wkdb.IpRule) {
+	cacheRules := make(map[uint64]ipRule, len(rules))
+	for _, rule := range rules {
+		prefix, err := parsePrefix(rule.Cidr)
+		if err != nil {
+			a.Warn("setRules: invalid cidr", zap.String("cidr", rule.Cidr), zap.Error(err))
+			continue
+		}
+		cacheRules[rule.Id] = ipRule{IpRule: rule, prefix: prefix}
+	}
+	a.mu.Lock()
+	a.rules = cacheRules
+	a.mu.Unlock()
+}
+
+// 判断ip规则，黑名单优先
+func (a *AdmissionManager) checkRules(addr netip.Addr) error {
+	if containsAddr(a.staticDenies, addr) {
+		return ErrIpDenied
+	}
+	hasAllow := len(a.staticAllows) > 0
+	inAllow := containsAddr(a.staticAllows, addr)
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	for _, rule := range a.rules {
+		switch rule.Action {
+		case wkdb.IpRuleDeny:
+			if rule.prefix.Contains(addr) {
+				return ErrIpDenied
+			}
+		case wkdb.IpRuleAllow:
+			hasAllow = true
+			if !inAllow && rule.prefix.Contains(addr) {
+				inAllow = true
+			}
+		}
+	}
+	if hasAllow && !inAllow {
+		return ErrIpNotAllowed
+	}
+	return nil
+}
+
+// 动态规则在后台从slot 0的领导节点加载，不阻塞连接（加载完成前只使用静态规则）
+func (a *AdmissionManager) loadIfNeed() {
+	a.loadMu.Lock()
+	if a.loaded || a.loading {
+		a.loadMu.Unlock()
+		return
+	}
+	a.loading = true
+	a.loadMu.Unlock()
+
+	go func() {
+		rules, err := a.getOrRequestIpRules()
+		a.loadMu.Lock()
+		defer a.loadMu.Unlock()
+		a.loading = false
+		if err != nil {
+			a.Warn("load ip rules failed", zap.Error(err))
+			return
+		}
+		if a.loaded { // 加载期间已经通过ReloadIpRules加载了最新的规则
+			return
+		}
+		a.setRules(rules)
+		a.loaded = true
+	}()
+}
+
+func (a *AdmissionManager) getOrRequestIpRules() ([]wkdb.IpRule, error) {
+	var slotId uint32 = 0
+	nodeInfo := service.Cluster.SlotLeaderNodeInfo(slotId)
+	if nodeInfo == nil {
+		return nil, errors.New("getOrRequestIpRules: slot leader node not found")
+	}
+	if nodeInfo.Id == options.G.Cluster.NodeId {
+		return service.Store.GetIpRules()
+	}
+	return a.client.GetIpRules(nodeInfo.Id)
+}
+
+// 定时清理过期的封禁和认证失败记录
+func (a *AdmissionManager) loopClean() {
+	tk := time.NewTicker(time.Minute)
+	defer tk.Stop()
+	for {
+		select {
+		case <-tk.C:
+			now := time.Now()
+			a.connMu.Lock()
+			for ip, banAt := range a.bans {
+				if now.After(banAt) {
+					delete(a.bans, ip)
+				}
+			}
+			for ip, fail := range a.authFails {
+				if now.Sub(fail.startAt) > options.G.Admission.AuthFailWindow {
+					delete(a.authFails, ip)
+				}
+			}
+			a.connMu.Unlock()
+		case <-a.stopC:
+			return
+		}
+	}
+}
+
+func (a *AdmissionManager) parsePrefixes(cidrs []string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		prefix, err := parsePrefix(cidr)
+		if err != nil {
+			a.Warn("invalid cidr", zap.String("cidr", cidr), zap.Error(err))
+			continue
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes
+}
+
+// 解析ip段，单个ip当作/32（ipv6为/128）
+func parsePrefix(cidr string) (netip.Prefix, error) {
+	cidr = strings.TrimSpace(cidr)
+	if !strings.Contains(cidr, "/") {
+		addr, err := netip.ParseAddr(cidr)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()).Masked(), nil
+}
+
+// 规范ip，ipv4映射的ipv6地址转为ipv4，不是ip地址的原样返回
+func normalizeIp(ip string) string {
+	ip = strings.TrimSpace(ip)
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ip
+	}
+	return addr.Unmap().String()
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
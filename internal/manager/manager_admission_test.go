@@ -0,0 +1,149 @@
+package manager
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/internal/options"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestAdmissionManager() *AdmissionManager {
+	options.G = options.New()
+	options.G.Admission.On = true
+	a := NewAdmissionManager()
+	a.loaded = true // 不从slot 0加载动态规则
+	return a
+}
+
+func TestAdmissionCheckRules(t *testing.T) {
+	a := newTestAdmissionManager()
+	a.staticDenies = a.parsePrefixes([]string{"10.0.0.1"})
+	a.setRules([]wkdb.IpRule{
+		{Id: 1, Cidr: "192.168.1.0/24", Action: wkdb.IpRuleDeny},
+	})
+
+	assert.ErrorIs(t, a.checkRules(netip.MustParseAddr("10.0.0.1")), ErrIpDenied)
+	assert.ErrorIs(t, a.checkRules(netip.MustParseAddr("192.168.1.8")), ErrIpDenied)
+	assert.NoError(t, a.checkRules(netip.MustParseAddr("192.168.2.8"))) // 没有白名单时不在黑名单的都允许
+
+	// 有白名单后只有白名单内的ip才能连接，黑名单优先
+	a.setRules([]wkdb.IpRule{
+		{Id: 1, Cidr: "192.168.1.0/24", Action: wkdb.IpRuleDeny},
+		{Id: 2, Cidr: "192.168.0.0/16", Action: wkdb.IpRuleAllow},
+	})
+	assert.NoError(t, a.checkRules(netip.MustParseAddr("192.168.2.8")))
+	assert.ErrorIs(t, a.checkRules(netip.MustParseAddr("192.168.1.8")), ErrIpDenied)
+	assert.ErrorIs(t, a.checkRules(netip.MustParseAddr("172.16.0.1")), ErrIpNotAllowed)
+
+	// 静态白名单
+	a.setRules(nil)
+	a.staticAllows = a.parsePrefixes([]string{"172.16.0.0/12"})
+	assert.NoError(t, a.checkRules(netip.MustParseAddr("172.16.0.1")))
+	assert.ErrorIs(t, a.checkRules(netip.MustParseAddr("192.168.2.8")), ErrIpNotAllowed)
+}
+
+func TestAdmissionAuthFail(t *testing.T) {
+	a := newTestAdmissionManager()
+	options.G.Admission.AuthFailMax = 3
+	options.G.Admission.BanDuration = time.Minute
+	a.trustedProxys = a.parsePrefixes([]string{"10.0.0.0/8"})
+
+	ip := "192.168.1.8"
+	a.AuthFail(ip)
+	a.AuthFail(ip)
+	assert.NoError(t, a.Admit(1, ip))
+	a.AuthFail(ip)
+	assert.Contains(t, a.Bans(), ip)
+	assert.ErrorIs(t, a.Admit(2, ip), ErrIpBanned)
+
+	a.Unban(ip)
+	assert.NoError(t, a.Admit(2, ip))
+
+	// ipv4映射的ipv6地址和ipv4地址是同一个ip
+	mappedIp := "::ffff:192.168.1.9"
+	for i := 0; i < 3; i++ {
+		a.AuthFail(mappedIp)
+	}
+	assert.Contains(t, a.Bans(), "192.168.1.9")
+	assert.ErrorIs(t, a.Admit(3, "192.168.1.9"), ErrIpBanned)
+	a.Unban(mappedIp)
+	assert.NoError(t, a.Admit(3, "192.168.1.9"))
+
+	// 受信任的代理不能被封禁
+	for i := 0; i < 5; i++ {
+		a.AuthFail("10.0.0.1")
+	}
+	assert.NotContains(t, a.Bans(), "10.0.0.1")
+}
+
+func TestAdmissionAdmit(t *testing.T) {
+	a := newTestAdmissionManager()
+	options.G.Admission.MaxConnPerIp = 2
+
+	ip := "192.168.1.8"
+	assert.NoError(t, a.Admit(1, ip))
+	assert.NoError(t, a.Admit(2, ip))
+	assert.ErrorIs(t, a.Admit(3, ip), ErrTooManyConns)
+
+	a.Release(1)
+	assert.NoError(t, a.Admit(3, ip))
+
+	// 同一个连接重复判断以最新的ip为准
+	assert.NoError(t, a.Admit(3, "192.168.1.9"))
+	assert.NoError(t, a.Admit(4, ip))
+
+	// 不是ip地址不做判断
+	assert.NoError(t, a.Admit(5, "unix"))
+
+	// 关闭准入控制
+	options.G.Admission.On = false
+	assert.NoError(t, a.Admit(6, ip))
+}
+
+func TestAdmissionAdmitAfter(t *testing.T) {
+	a := newTestAdmissionManager()
+	a.staticDenies = a.parsePrefixes([]string{"10.0.0.1"})
+
+	// 超时后以代理的ip做判断
+	rejectC := make(chan error, 1)
+	a.AdmitAfter(1, "10.0.0.1", time.Millisecond*10, func(err error) {
+		rejectC <- err
+	})
+	select {
+	case err := <-rejectC:
+		assert.ErrorIs(t, err, ErrIpDenied)
+	case <-time.After(time.Second):
+		t.Fatal("proxy header timeout not fired")
+	}
+
+	// 解析出真实ip后不再以代理的ip判断
+	a.AdmitAfter(2, "10.0.0.1", time.Millisecond*10, func(err error) {
+		rejectC <- err
+	})
+	assert.NoError(t, a.Admit(2, "192.168.1.8"))
+	assert.NoError(t, a.AdmitNow(2))
+	select {
+	case err := <-rejectC:
+		t.Fatalf("unexpected reject: %v", err)
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	// 没有代理协议头，立即判断
+	a.AdmitAfter(3, "10.0.0.1", time.Minute, nil)
+	assert.ErrorIs(t, a.AdmitNow(3), ErrIpDenied)
+	assert.NoError(t, a.AdmitNow(3))
+
+	// 连接关闭后取消判断
+	a.AdmitAfter(4, "10.0.0.1", time.Millisecond*10, func(err error) {
+		rejectC <- err
+	})
+	a.Release(4)
+	select {
+	case err := <-rejectC:
+		t.Fatalf("unexpected reject: %v", err)
+	case <-time.After(time.Millisecond * 50):
+	}
+}
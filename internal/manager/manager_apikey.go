@@ -0,0 +1,197 @@
+package manager
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/internal/ingress"
+	"github.com/WuKongIM/WuKongIM/internal/options"
+	"github.com/WuKongIM/WuKongIM/internal/service"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb/key"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrApiKeyInvalid = errors.New("api key invalid")
+	ErrApiKeyNoScope = errors.New("api key has no scope")
+)
+
+// api key的前缀
+const apiKeyPrefix = "wk_"
+
+// ApiKeyManager 业务api的api key管理
+// api key存储在slot 0上，每个节点缓存一份，添加和移除后通知各个节点从slot 0的领导节点重新加载
+type ApiKeyManager struct {
+	mu     sync.RWMutex
+	keys   map[string]wkdb.ApiKey // keyHash -> apiKey
+	loaded bool
+	loadMu sync.Mutex
+	client *ingress.Client
+	wklog.Log
+}
+
+func NewApiKeyManager() *ApiKeyManager {
+	return &ApiKeyManager{
+		keys:   make(map[string]wkdb.ApiKey),
+		client: ingress.NewClient(),
+		Log:    wklog.NewWKLog("ApiKeyManager"),
+	}
+}
+
+// LoadIfNeed 如果没有加载过则从slot 0的领导节点加载
+func (a *ApiKeyManager) LoadIfNeed() error {
+	a.loadMu.Lock()
+	defer a.loadMu.Unlock()
+	if a.loaded {
+		return nil
+	}
+	apiKeys, err := a.getOrRequestApiKeys()
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	for _, apiKey := range apiKeys {
+		a.keys[apiKey.KeyHash] = apiKey
+	}
+	a.mu.Unlock()
+	a.loaded = true
+	return nil
+}
+
+// Verify 验证api key是否有指定的权限范围
+func (a *ApiKeyManager) Verify(k string, scope string) (wkdb.ApiKey, error) {
+	if strings.TrimSpace(k) == "" {
+		return wkdb.ApiKey{}, ErrApiKeyInvalid
+	}
+	if err := a.LoadIfNeed(); err != nil {
+		a.Error("LoadIfNeed error", zap.Error(err))
+		return wkdb.ApiKey{}, err
+	}
+	a.mu.RLock()
+	apiKey, ok := a.keys[hashApiKey(k)]
+	a.mu.RUnlock()
+	if !ok {
+		return wkdb.ApiKey{}, ErrApiKeyInvalid
+	}
+	if !HasApiScope(apiKey.Scopes, scope) {
+		return wkdb.ApiKey{}, ErrApiKeyNoScope
+	}
+	return apiKey, nil
+}
+
+// AddApiKey 创建api key
+func (a *ApiKeyManager) AddApiKey(name string, scopes []string) (wkdb.ApiKey, string, error) {
+	k, err := genApiKey()
+	if err != nil {
+		return wkdb.ApiKey{}, "", err
+	}
+	keyHash := hashApiKey(k)
+	createdAt := time.Now()
+	apiKey := wkdb.ApiKey{
+		Id:        key.HashWithString(keyHash),
+		Name:      name,
+		KeyHash:   keyHash,
+		Scopes:    scopes,
+		CreatedAt: &createdAt,
+	}
+	if err = service.Store.AddOrUpdateApiKey(apiKey); err != nil {
+		return wkdb.ApiKey{}, "", err
+	}
+	a.addToCache(apiKey)
+	return apiKey, k, nil
+}
+
+func (a *ApiKeyManager) addToCache(apiKey wkdb.ApiKey) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.keys[apiKey.KeyHash] = apiKey
+}
+
+// RemoveApiKey 移除api key
+func (a *ApiKeyManager) RemoveApiKey(id uint64) error {
+	if err := service.Store.RemoveApiKey(id); err != nil {
+		return err
+	}
+	a.removeFromCache(id)
+	return nil
+}
+
+// Reload 从slot 0的领导节点重新加载api key，替换掉缓存
+func (a *ApiKeyManager) Reload() error {
+	a.loadMu.Lock()
+	defer a.loadMu.Unlock()
+	apiKeys, err := a.getOrRequestApiKeys()
+	if err != nil {
+		return err
+	}
+	keys := make(map[string]wkdb.ApiKey, len(apiKeys))
+	for _, apiKey := range apiKeys {
+		keys[apiKey.KeyHash] = apiKey
+	}
+	a.mu.Lock()
+	a.keys = keys
+	a.mu.Unlock()
+	a.loaded = true
+	return nil
+}
+
+func (a *ApiKeyManager) removeFromCache(id uint64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for keyHash, apiKey := range a.keys {
+		if apiKey.Id == id {
+			delete(a.keys, keyHash)
+		}
+	}
+}
+
+func (a *ApiKeyManager) getOrRequestApiKeys() ([]wkdb.ApiKey, error) {
+	var slotId uint32 = 0
+	nodeInfo := service.Cluster.SlotLeaderNodeInfo(slotId)
+	if nodeInfo == nil {
+		return nil, errors.New("getOrRequestApiKeys: slot leader node not found")
+	}
+	if nodeInfo.Id == options.G.Cluster.NodeId {
+		return service.Store.GetApiKeys()
+	}
+	return a.client.GetApiKeys(nodeInfo.Id)
+}
+
+// HasApiScope 权限范围是否包含scope
+// 支持通配符 * 表示所有权限，message:* 表示message资源的所有权限，资源的write权限包含read权限
+func HasApiScope(scopes []string, scope string) bool {
+	resource, action, _ := strings.Cut(scope, ":")
+	for _, s := range scopes {
+		if s == "*" || s == scope {
+			return true
+		}
+		res, act, _ := strings.Cut(s, ":")
+		if res != resource {
+			continue
+		}
+		if act == "*" || (act == "write" && action == "read") {
+			return true
+		}
+	}
+	return false
+}
+
+func hashApiKey(k string) string {
+	sum := sha256.Sum256([]byte(k))
+	return hex.EncodeToString(sum[:])
+}
+
+func genApiKey() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return apiKeyPrefix + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,374 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/internal/eventbus"
+	"github.com/WuKongIM/WuKongIM/internal/ingress"
+	"github.com/WuKongIM/WuKongIM/internal/options"
+	"github.com/WuKongIM/WuKongIM/internal/service"
+	"github.com/WuKongIM/WuKongIM/internal/track"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+	"go.uber.org/zap"
+)
+
+// 在线状态推送的cmd（客户端通过cmd消息的cmd字段识别）
+const PresenceCmd = "presence"
+
+// cmd消息的正文类型（与客户端sdk约定的cmd消息类型一致）
+const presenceCmdContentType = 99
+
+// PresenceManager 在线状态订阅管理
+// 订阅关系保存在被订阅用户(target)的slot leader节点上，因为用户的上下线（Webhook.Online/Offline）只在leader节点上触发
+// 订阅者订阅的所有用户（跨节点）记录在订阅者(watcher)的slot leader节点上，用于限制每个用户的订阅数量
+type PresenceManager struct {
+	mu       sync.RWMutex
+	watchers map[string]map[string]struct{} // target -> watchers
+	targets  map[string]map[string]struct{} // watcher -> targets（本节点上的订阅关系）
+
+	reservedMu sync.Mutex
+	reserved   map[string]map[string]struct{} // watcher -> targets（订阅者在所有节点上的订阅，只在订阅者的leader节点上记录）
+
+	notifyMu sync.Mutex
+	notifies map[string]*presenceNotify // target -> 推送状态（用于限速）
+
+	client *ingress.Client
+	wklog.Log
+}
+
+func NewPresenceManager() *PresenceManager {
+	return &PresenceManager{
+		watchers: make(map[string]map[string]struct{}),
+		targets:  make(map[string]map[string]struct{}),
+		reserved: make(map[string]map[string]struct{}),
+		notifies: make(map[string]*presenceNotify),
+		client:   ingress.NewClient(),
+		Log:      wklog.NewWKLog("PresenceManager"),
+	}
+}
+
+// Reserve 在订阅者的leader节点上登记watcher要订阅的targets，超过每个用户的订阅数量限制返回错误
+// 返回新登记的targets（订阅失败时用Release撤销）
+// 登记只保存在内存里，订阅者的leader节点变化后重新计数
+func (p *PresenceManager) Reserve(watcher string, targets []string) ([]string, error) {
+	p.reservedMu.Lock()
+	defer p.reservedMu.Unlock()
+
+	watcherTargets := p.reserved[watcher]
+	added := make([]string, 0, len(targets))
+	for _, target := range targets {
+		if target == watcher {
+			continue
+		}
+		if _, ok := watcherTargets[target]; ok {
+			continue
+		}
+		added = append(added, target)
+	}
+	if len(added) == 0 {
+		return nil, nil
+	}
+	if options.G.Presence.MaxSubscribeCount > 0 && len(watcherTargets)+len(added) > options.G.Presence.MaxSubscribeCount {
+		return nil, fmt.Errorf("订阅数量超过限制[%d]", options.G.Presence.MaxSubscribeCount)
+	}
+	if watcherTargets == nil {
+		watcherTargets = make(map[string]struct{}, len(added))
+		p.reserved[watcher] = watcherTargets
+	}
+	for _, target := range added {
+		watcherTargets[target] = struct{}{}
+	}
+	return added, nil
+}
+
+// Release 撤销watcher登记的targets
+func (p *PresenceManager) Release(watcher string, targets []string) {
+	p.reservedMu.Lock()
+	defer p.reservedMu.Unlock()
+	watcherTargets := p.reserved[watcher]
+	if watcherTargets == nil {
+		return
+	}
+	for _, target := range targets {
+		delete(watcherTargets, target)
+	}
+	if len(watcherTargets) == 0 {
+		delete(p.reserved, watcher)
+	}
+}
+
+// Subscribe watcher订阅targets的在线状态（订阅数量的限制由Reserve在订阅者的leader节点上检查）
+func (p *PresenceManager) Subscribe(watcher string, targets []string) error {
+	if len(targets) == 0 {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	watcherTargets := p.targets[watcher]
+	if watcherTargets == nil {
+		watcherTargets = make(map[string]struct{})
+		p.targets[watcher] = watcherTargets
+	}
+
+	for _, target := range targets {
+		if target == watcher {
+			continue
+		}
+		watcherTargets[target] = struct{}{}
+		targetWatchers := p.watchers[target]
+		if targetWatchers == nil {
+			targetWatchers = make(map[string]struct{})
+			p.watchers[target] = targetWatchers
+		}
+		targetWatchers[watcher] = struct{}{}
+	}
+	return nil
+}
+
+// Unsubscribe watcher取消订阅targets的在线状态
+func (p *PresenceManager) Unsubscribe(watcher string, targets []string) {
+	if len(targets) == 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, target := range targets {
+		p.removeNoLock(watcher, target)
+	}
+}
+
+// UnsubscribeAll 取消watcher的所有订阅
+func (p *PresenceManager) UnsubscribeAll(watcher string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for target := range p.targets[watcher] {
+		p.removeNoLock(watcher, target)
+	}
+	delete(p.targets, watcher)
+
+	p.reservedMu.Lock()
+	delete(p.reserved, watcher)
+	p.reservedMu.Unlock()
+}
+
+func (p *PresenceManager) removeNoLock(watcher, target string) {
+	if watcherTargets := p.targets[watcher]; watcherTargets != nil {
+		delete(watcherTargets, target)
+		if len(watcherTargets) == 0 {
+			delete(p.targets, watcher)
+		}
+	}
+	if targetWatchers := p.watchers[target]; targetWatchers != nil {
+		delete(targetWatchers, watcher)
+		if len(targetWatchers) == 0 {
+			delete(p.watchers, target)
+			p.removeNotify(target)
+		}
+	}
+}
+
+// Watchers 获取订阅了target在线状态的用户
+func (p *PresenceManager) Watchers(target string) []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	targetWatchers := p.watchers[target]
+	if len(targetWatchers) == 0 {
+		return nil
+	}
+	watchers := make([]string, 0, len(targetWatchers))
+	for watcher := range targetWatchers {
+		watchers = append(watchers, watcher)
+	}
+	return watchers
+}
+
+// Online 用户上线
+func (p *PresenceManager) Online(uid string, deviceFlag wkproto.DeviceFlag, deviceOnlineCount int, totalOnlineCount int) {
+	p.notify(&PresenceStatus{
+		Uid:               uid,
+		DeviceFlag:        deviceFlag.ToUint8(),
+		Online:            1,
+		DeviceOnlineCount: deviceOnlineCount,
+		TotalOnlineCount:  totalOnlineCount,
+		Timestamp:         time.Now().Unix(),
+	})
+}
+
+// Offline 用户下线
+func (p *PresenceManager) Offline(uid string, deviceFlag wkproto.DeviceFlag, deviceOnlineCount int, totalOnlineCount int) {
+	p.notify(&PresenceStatus{
+		Uid:               uid,
+		DeviceFlag:        deviceFlag.ToUint8(),
+		Online:            0,
+		DeviceOnlineCount: deviceOnlineCount,
+		TotalOnlineCount:  totalOnlineCount,
+		Timestamp:         time.Now().Unix(),
+	})
+}
+
+// 通知订阅者，同一个用户在NotifyInterval内只推送一次，间隔内的变化合并为最后一次状态延迟推送
+func (p *PresenceManager) notify(status *PresenceStatus) {
+	if !options.G.Presence.On {
+		return
+	}
+	p.mu.RLock()
+	hasWatcher := len(p.watchers[status.Uid]) > 0
+	p.mu.RUnlock()
+	if !hasWatcher {
+		return
+	}
+
+	interval := options.G.Presence.NotifyInterval
+	if interval <= 0 {
+		p.push(status)
+		return
+	}
+
+	p.notifyMu.Lock()
+	n := p.notifies[status.Uid]
+	if n == nil {
+		n = &presenceNotify{}
+		p.notifies[status.Uid] = n
+	}
+	elapsed := time.Since(n.lastPushAt)
+	if elapsed >= interval && !n.scheduled {
+		n.lastPushAt = time.Now()
+		p.notifyMu.Unlock()
+		p.push(status)
+		return
+	}
+	n.pending = status
+	if !n.scheduled {
+		n.scheduled = true
+		service.CommonService.AfterFunc(interval-elapsed, func() {
+			p.flush(status.Uid)
+		})
+	}
+	p.notifyMu.Unlock()
+}
+
+// 推送间隔内积累的最后一次状态
+func (p *PresenceManager) flush(uid string) {
+	// 等待推送期间订阅者都取消了订阅（removeNotify不会删除已安排推送的状态），在这里删除
+	p.mu.RLock()
+	hasWatcher := len(p.watchers[uid]) > 0
+	p.mu.RUnlock()
+
+	p.notifyMu.Lock()
+	n := p.notifies[uid]
+	if n == nil {
+		p.notifyMu.Unlock()
+		return
+	}
+	if !hasWatcher {
+		delete(p.notifies, uid)
+		p.notifyMu.Unlock()
+		return
+	}
+	status := n.pending
+	n.pending = nil
+	n.scheduled = false
+	n.lastPushAt = time.Now()
+	p.notifyMu.Unlock()
+
+	if status != nil {
+		p.push(status)
+	}
+}
+
+func (p *PresenceManager) removeNotify(uid string) {
+	p.notifyMu.Lock()
+	defer p.notifyMu.Unlock()
+	if n := p.notifies[uid]; n != nil && !n.scheduled {
+		delete(p.notifies, uid)
+	}
+}
+
+// 推送在线状态给订阅者（不存储的cmd消息，只推送给在线的订阅者）
+func (p *PresenceManager) push(status *PresenceStatus) {
+	watchers := p.Watchers(status.Uid)
+	if len(watchers) == 0 {
+		return
+	}
+	channelId := options.G.Channel.OnlineCmdChannelId
+	channelType := wkproto.ChannelTypeTemp
+
+	tagKey := fmt.Sprintf("%scmd", wkutil.GenUUID())
+	err := p.makeTag(channelId, channelType, tagKey, watchers)
+	if err != nil {
+		p.Error("push: make tag failed", zap.Error(err), zap.String("uid", status.Uid))
+		return
+	}
+
+	payload := []byte(wkutil.ToJSON(map[string]interface{}{
+		"type":  presenceCmdContentType,
+		"cmd":   PresenceCmd,
+		"param": status,
+	}))
+
+	event := &eventbus.Event{
+		Conn: &eventbus.Conn{
+			Uid:      options.G.SystemUID,
+			DeviceId: options.G.SystemDeviceId,
+		},
+		Type: eventbus.EventChannelOnSend,
+		Frame: &wkproto.SendPacket{
+			Framer: wkproto.Framer{
+				SyncOnce:  true,
+				NoPersist: true,
+			},
+			ClientMsgNo: fmt.Sprintf("%s0", wkutil.GenUUID()),
+			ChannelID:   channelId,
+			ChannelType: channelType,
+			Payload:     payload,
+		},
+		MessageId: options.G.GenMessageId(),
+		TagKey:    tagKey,
+		Track: track.Message{
+			PreStart: time.Now(),
+		},
+	}
+	eventbus.Channel.SendMessage(channelId, channelType, event)
+	eventbus.Channel.Advance(channelId, channelType)
+}
+
+// 在在线cmd频道的leader节点上生成tag
+func (p *PresenceManager) makeTag(channelId string, channelType uint8, tagKey string, uids []string) error {
+	nodeInfo, err := service.Cluster.LeaderOfChannel(channelId, channelType)
+	if err != nil {
+		return err
+	}
+	if nodeInfo == nil {
+		return errors.New("online cmd channel leader not found")
+	}
+	if options.G.IsLocalNode(nodeInfo.Id) {
+		_, err = service.TagManager.MakeTagWithTagKey(tagKey, uids)
+		return err
+	}
+	return p.client.AddTag(nodeInfo.Id, &ingress.TagAddReq{
+		TagKey: tagKey,
+		Uids:   uids,
+	})
+}
+
+// PresenceStatus 推送给订阅者的在线状态
+type PresenceStatus struct {
+	Uid               string `json:"uid"`                 // 用户uid
+	DeviceFlag        uint8  `json:"device_flag"`         // 设备标记
+	Online            int    `json:"online"`              // 是否在线 1.在线 0.离线
+	DeviceOnlineCount int    `json:"device_online_count"` // 当前设备标记下的在线数量
+	TotalOnlineCount  int    `json:"total_online_count"`  // 用户所有设备的在线数量
+	Timestamp         int64  `json:"timestamp"`           // 状态变化时间（秒）
+}
+
+type presenceNotify struct {
+	lastPushAt time.Time       // 最后一次推送时间
+	pending    *PresenceStatus // 等待推送的状态
+	scheduled  bool            // 是否已经安排了延迟推送
+}
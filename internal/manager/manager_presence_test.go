@@ -0,0 +1,74 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/internal/options"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPresenceReserve(t *testing.T) {
+	options.G = options.New()
+	options.G.Presence.MaxSubscribeCount = 3
+
+	p := NewPresenceManager()
+
+	added, err := p.Reserve("u1", []string{"u1", "a", "b"})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a", "b"}, added) // 不能订阅自己
+
+	// 已经登记的不重复计数
+	added, err = p.Reserve("u1", []string{"a", "c"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c"}, added)
+
+	// 超过限制
+	_, err = p.Reserve("u1", []string{"d"})
+	assert.Error(t, err)
+
+	// 其他用户单独计数
+	added, err = p.Reserve("u2", []string{"a", "b", "c"})
+	assert.NoError(t, err)
+	assert.Len(t, added, 3)
+
+	p.Release("u1", []string{"c"})
+	added, err = p.Reserve("u1", []string{"d"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"d"}, added)
+
+	p.UnsubscribeAll("u1")
+	added, err = p.Reserve("u1", []string{"e", "f", "g"})
+	assert.NoError(t, err)
+	assert.Len(t, added, 3)
+}
+
+func TestPresenceSubscribe(t *testing.T) {
+	options.G = options.New()
+
+	p := NewPresenceManager()
+	assert.NoError(t, p.Subscribe("u1", []string{"a", "b"}))
+	assert.NoError(t, p.Subscribe("u2", []string{"a"}))
+	assert.ElementsMatch(t, []string{"u1", "u2"}, p.Watchers("a"))
+
+	p.Unsubscribe("u1", []string{"a"})
+	assert.Equal(t, []string{"u2"}, p.Watchers("a"))
+
+	p.UnsubscribeAll("u1")
+	assert.Empty(t, p.Watchers("b"))
+}
+
+func TestPresenceFlushRemovesUnwatchedNotify(t *testing.T) {
+	options.G = options.New()
+
+	p := NewPresenceManager()
+	assert.NoError(t, p.Subscribe("u1", []string{"a"}))
+
+	// 已安排延迟推送时取消订阅，removeNotify不会删除
+	p.notifies["a"] = &presenceNotify{scheduled: true}
+	p.Unsubscribe("u1", []string{"a"})
+	assert.Contains(t, p.notifies, "a")
+
+	// 到期后没有订阅者，删除推送状态
+	p.flush("a")
+	assert.NotContains(t, p.notifies, "a")
+}
@@ -0,0 +1,163 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/internal/ingress"
+	"github.com/WuKongIM/WuKongIM/internal/options"
+	"github.com/WuKongIM/WuKongIM/internal/service"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"golang.org/x/sync/errgroup"
+)
+
+// TokenRevokeManager 管理后台token和设备jwt连接token的吊销列表
+// 吊销列表存储在slot 0上，每个节点缓存一份，吊销后由slot 0的领导节点通过节点之间的rpc同步到各个节点的缓存
+type TokenRevokeManager struct {
+	mu      sync.RWMutex
+	revoked map[string]revokedEntry // id -> 吊销记录
+	loaded  bool
+	loadMu  sync.Mutex
+	client  *ingress.Client
+	wklog.Log
+}
+
+type revokedEntry struct {
+	revokedAt time.Time // 吊销时间
+	expireAt  time.Time // 过期时间
+}
+
+func NewTokenRevokeManager() *TokenRevokeManager {
+	return &TokenRevokeManager{
+		revoked: make(map[string]revokedEntry),
+		client:  ingress.NewClient(),
+		Log:     wklog.NewWKLog("TokenRevokeManager"),
+	}
+}
+
+// LoadIfNeed 如果没有加载过则从slot 0的领导节点加载
+func (t *TokenRevokeManager) LoadIfNeed() error {
+	t.loadMu.Lock()
+	defer t.loadMu.Unlock()
+	if t.loaded {
+		return nil
+	}
+	tokens, err := t.getOrRequestRevokedTokens()
+	if err != nil {
+		return err
+	}
+	for _, token := range tokens {
+		t.AddRevokedToCache(token)
+	}
+	t.loaded = true
+	return nil
+}
+
+// IsRevoked 是否有id已被吊销
+func (t *TokenRevokeManager) IsRevoked(ids ...string) (bool, error) {
+	if err := t.LoadIfNeed(); err != nil {
+		return false, err
+	}
+	now := time.Now()
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, id := range ids {
+		if id == "" {
+			continue
+		}
+		entry, ok := t.revoked[id]
+		if ok && entry.expireAt.After(now) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RevokedAt 获取id的吊销时间，没有吊销或吊销记录已过期返回false
+func (t *TokenRevokeManager) RevokedAt(id string) (time.Time, bool, error) {
+	if err := t.LoadIfNeed(); err != nil {
+		return time.Time{}, false, err
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	entry, ok := t.revoked[id]
+	if !ok || !entry.expireAt.After(time.Now()) {
+		return time.Time{}, false, nil
+	}
+	return entry.revokedAt, true, nil
+}
+
+// Revoke 吊销token，不是slot 0的领导节点则请求领导节点吊销，领导节点保存后同步到各个节点的缓存
+func (t *TokenRevokeManager) Revoke(token wkdb.RevokedToken) error {
+	var slotId uint32 = 0
+	nodeInfo := service.Cluster.SlotLeaderNodeInfo(slotId)
+	if nodeInfo == nil {
+		return errors.New("revoke: slot leader node not found")
+	}
+	if nodeInfo.Id != options.G.Cluster.NodeId {
+		return t.client.RevokeToken(nodeInfo.Id, token)
+	}
+
+	if token.CreatedAt == nil {
+		now := time.Now()
+		token.CreatedAt = &now
+	}
+	if err := service.Store.AddRevokedToken(token); err != nil {
+		return err
+	}
+	t.AddRevokedToCache(token)
+
+	g, _ := errgroup.WithContext(context.Background())
+	for _, node := range service.Cluster.Nodes() {
+		if node.Id == options.G.Cluster.NodeId || !node.Online {
+			continue
+		}
+		nodeId := node.Id
+		g.Go(func() error {
+			return t.client.AddRevokedToken(nodeId, token)
+		})
+	}
+	return g.Wait()
+}
+
+// AddRevokedToCache 添加到缓存，同时清理已经过期的记录
+func (t *TokenRevokeManager) AddRevokedToCache(token wkdb.RevokedToken) {
+	if token.ExpireAt == nil {
+		return
+	}
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id, entry := range t.revoked {
+		if !entry.expireAt.After(now) {
+			delete(t.revoked, id)
+		}
+	}
+	if !token.ExpireAt.After(now) {
+		return
+	}
+	entry := revokedEntry{expireAt: *token.ExpireAt}
+	if token.CreatedAt != nil {
+		entry.revokedAt = *token.CreatedAt
+	}
+	// 同一个id多次吊销（比如设备多次退出）保留最新的吊销记录
+	if old, ok := t.revoked[token.Id]; ok && old.revokedAt.After(entry.revokedAt) {
+		return
+	}
+	t.revoked[token.Id] = entry
+}
+
+func (t *TokenRevokeManager) getOrRequestRevokedTokens() ([]wkdb.RevokedToken, error) {
+	var slotId uint32 = 0
+	nodeInfo := service.Cluster.SlotLeaderNodeInfo(slotId)
+	if nodeInfo == nil {
+		return nil, errors.New("getOrRequestRevokedTokens: slot leader node not found")
+	}
+	if nodeInfo.Id == options.G.Cluster.NodeId {
+		return service.Store.GetRevokedTokens()
+	}
+	return t.client.GetRevokedTokens(nodeInfo.Id)
+}
@@ -0,0 +1,217 @@
+package mqtt
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/mqtt"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+)
+
+// 主题前缀
+const (
+	topicPerson  = "person"  // person/{uid} 个人频道
+	topicGroup   = "group"   // group/{groupNo} 群频道
+	topicChannel = "channel" // channel/{channelType}/{channelId} 其他类型的频道
+)
+
+// 连接上的值的key
+const sessionKey = "mqtt_session"
+
+// 最多等待确认的消息数量（QoS 1）
+const maxInflight = 65535
+
+// 连接成功之前最多缓存的包数量
+const maxPendingPackets = 128
+
+// mqtt会话
+// CleanSession为false的会话在连接断开后保留订阅（sessionStore），重连后恢复
+type session struct {
+	mu sync.Mutex
+
+	version          byte          // mqtt协议版本
+	connecting       bool          // 是否已发起连接
+	authed           bool          // 是否已认证成功（收到成功的CONNACK）
+	connected        bool          // 是否已连接成功（连接成功之前缓存的包已处理完）
+	uid              string        // 用户uid
+	clientId         string        // 客户端标识（对应设备id）
+	assignedClientId bool          // 客户端标识是否是服务端分配的
+	keepAlive        uint16        // 保活时间（秒）
+	cleanSession     bool          // 是否清除会话（MQTT 5为Clean Start）
+	sessionExpiry    time.Duration // 断开后会话保留的时长 0表示不保留
+	will             *will         // 遗嘱消息，正常断开（DISCONNECT）时清除
+
+	pendings []mqtt.ControlPacket // 连接成功之前收到的包，连接成功后再处理
+
+	clientPrivKey [32]byte // 客户端的DH私钥
+	aesKey        []byte
+	aesIV         []byte
+
+	clientSeq     uint64            // 发送消息的客户端序号
+	subscriptions map[string]byte   // 主题过滤器 -> 授予的QoS
+	pendingPubs   map[uint64]uint16 // 等待发送回执的QoS 1消息 clientSeq -> packetId
+
+	lastPacketId uint16
+	inflight     map[uint16]*inflightMsg // 等待PUBACK的投递 packetId -> 消息
+	inflightIds  map[int64]uint16        // messageId -> packetId（重试时复用包标识）
+}
+
+// 遗嘱消息
+type will struct {
+	topic   string
+	payload []byte
+}
+
+// 等待确认的投递
+type inflightMsg struct {
+	framer     wkproto.Framer
+	messageId  int64
+	messageSeq uint32
+}
+
+func newSession() *session {
+	return &session{
+		subscriptions: make(map[string]byte),
+		pendingPubs:   make(map[uint64]uint16),
+		inflight:      make(map[uint16]*inflightMsg),
+		inflightIds:   make(map[int64]uint16),
+	}
+}
+
+// 保存会话的key
+func (s *session) storeKey() string {
+	return s.uid + "/" + s.clientId
+}
+
+// 匹配主题的订阅中最大的QoS，没有匹配的订阅返回false
+func (s *session) matchQoS(topic string) (byte, bool) {
+	var (
+		qos     byte
+		matched bool
+	)
+	for filter, granted := range s.subscriptions {
+		if !mqtt.MatchTopic(filter, topic) {
+			continue
+		}
+		matched = true
+		if granted > qos {
+			qos = granted
+		}
+	}
+	return qos, matched
+}
+
+// 分配下一个空闲的包标识
+func (s *session) nextPacketId() (uint16, bool) {
+	if len(s.inflight) >= maxInflight {
+		return 0, false
+	}
+	for {
+		s.lastPacketId++
+		if s.lastPacketId == 0 {
+			continue
+		}
+		if _, ok := s.inflight[s.lastPacketId]; !ok {
+			return s.lastPacketId, true
+		}
+	}
+}
+
+// 保留的会话，CleanSession为false的客户端断开后保留订阅，重连后恢复
+// 只保存在客户端连接的节点的内存里，客户端重连到其他节点或节点重启后会话不存在（CONNACK的SessionPresent为false）
+type sessionStore struct {
+	mu        sync.Mutex
+	sessions  map[string]*storedSession // uid/clientId -> 会话
+	lastSweep time.Time
+}
+
+type storedSession struct {
+	subscriptions map[string]byte
+	expireAt      time.Time
+}
+
+func newSessionStore() *sessionStore {
+	return &sessionStore{
+		sessions:  make(map[string]*storedSession),
+		lastSweep: time.Now(),
+	}
+}
+
+// 保存会话，expiry后过期
+func (s *sessionStore) save(key string, subscriptions map[string]byte, expiry time.Duration) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.lastSweep) > time.Minute { // 清理过期的会话
+		for k, stored := range s.sessions {
+			if now.After(stored.expireAt) {
+				delete(s.sessions, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	s.sessions[key] = &storedSession{
+		subscriptions: subscriptions,
+		expireAt:      now.Add(expiry),
+	}
+}
+
+// 取出会话，没有或已过期返回false
+func (s *sessionStore) take(key string) (map[string]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.sessions[key]
+	if !ok {
+		return nil, false
+	}
+	delete(s.sessions, key)
+	if time.Now().After(stored.expireAt) {
+		return nil, false
+	}
+	return stored.subscriptions, true
+}
+
+func (s *sessionStore) remove(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, key)
+}
+
+// 主题转换为频道
+func topicToChannel(topic string) (string, uint8, error) {
+	parts := strings.SplitN(topic, "/", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return "", 0, fmt.Errorf("invalid topic: %s", topic)
+	}
+	switch parts[0] {
+	case topicPerson:
+		return parts[1], wkproto.ChannelTypePerson, nil
+	case topicGroup:
+		return parts[1], wkproto.ChannelTypeGroup, nil
+	case topicChannel:
+		typeAndId := strings.SplitN(parts[1], "/", 2)
+		if len(typeAndId) != 2 || typeAndId[1] == "" {
+			return "", 0, fmt.Errorf("invalid topic: %s", topic)
+		}
+		channelType, err := strconv.ParseUint(typeAndId[0], 10, 8)
+		if err != nil {
+			return "", 0, fmt.Errorf("invalid channel type: %s", typeAndId[0])
+		}
+		return typeAndId[1], uint8(channelType), nil
+	}
+	return "", 0, fmt.Errorf("invalid topic: %s", topic)
+}
+
+// 频道转换为主题
+func channelToTopic(channelId string, channelType uint8) string {
+	switch channelType {
+	case wkproto.ChannelTypePerson:
+		return topicPerson + "/" + channelId
+	case wkproto.ChannelTypeGroup:
+		return topicGroup + "/" + channelId
+	}
+	return fmt.Sprintf("%s/%d/%s", topicChannel, channelType, channelId)
+}
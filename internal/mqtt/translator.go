@@ -0,0 +1,759 @@
+package mqtt
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/internal/eventbus"
+	"github.com/WuKongIM/WuKongIM/internal/options"
+	"github.com/WuKongIM/WuKongIM/pkg/mqtt"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/WuKongIM/WuKongIM/pkg/wknet"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrSessionNotExist = errors.New("mqtt session not exist")
+	ErrQoSNotSupported = errors.New("mqtt qos 2 not supported")
+	ErrConnNotAuthed   = errors.New("mqtt conn not authed")
+)
+
+// Translator mqtt协议与悟空IM协议的转换器
+// mqtt客户端对于服务端来说就是一个普通的悟空IM客户端，转换器负责完成悟空IM客户端需要做的事情（DH密钥交换，消息加解密，回执等）
+//
+// CONNECT: clientId为设备id，username为uid，password为token，认证流程与悟空IM的连接一致
+// 客户端不等CONNACK就发送的包先缓存在会话里，认证成功后再按顺序处理
+// KeepAlive: 超过1.5倍的保活时间没有收到客户端的包则断开连接（为0时使用服务端的连接空闲时间）
+// Will: 连接非正常断开（没有收到DISCONNECT，或MQTT 5的原因码为0x04）时以客户端的身份发送遗嘱消息到遗嘱主题对应的频道，不支持遗嘱延迟和保留
+// CleanSession: 为false时连接断开后在当前节点保留订阅，重连后恢复（消息不保留，断开期间的消息通过悟空IM的离线消息同步）
+// PUBLISH: 发送消息到主题对应的频道，QoS 1的消息在收到发送回执（SENDACK）后回复PUBACK
+// SUBSCRIBE: 只是连接上的过滤器，不会改变频道的订阅者，收到的消息匹配订阅的主题后才投递给客户端
+// 投递: QoS 0的消息投递后直接回执（RECVACK），QoS 1的消息收到PUBACK后才回执，没回执的消息由服务端重试（RetryManager）
+// 没有匹配订阅的消息直接回执后丢弃（和MQTT一样不投递没订阅的主题），避免服务端一直重试，需要的消息通过悟空IM的离线消息同步
+type Translator struct {
+	sessions *sessionStore
+
+	addEvents     func(uid string, events []*eventbus.Event)                           // 添加用户事件
+	sendToChannel func(fakeChannelId string, channelType uint8, event *eventbus.Event) // 发送消息到频道
+	genMessageId  func() int64                                                         // 生成消息id
+	wklog.Log
+}
+
+func NewTranslator() *Translator {
+	return &Translator{
+		sessions: newSessionStore(),
+		addEvents: func(uid string, events []*eventbus.Event) {
+			eventbus.User.AddEvents(uid, events)
+		},
+		sendToChannel: func(fakeChannelId string, channelType uint8, event *eventbus.Event) {
+			eventbus.Channel.SendMessage(fakeChannelId, channelType, event)
+			eventbus.Channel.Advance(fakeChannelId, channelType)
+		},
+		genMessageId: func() int64 {
+			return options.G.GenMessageId()
+		},
+		Log: wklog.NewWKLog("mqtt"),
+	}
+}
+
+// Inbound 将mqtt客户端发来的数据转换为悟空IM协议的数据
+func (t *Translator) Inbound(conn wknet.Conn, data []byte, w io.Writer) (int, error) {
+	sess := t.session(conn)
+	if sess == nil {
+		sess = newSession()
+		conn.SetValue(sessionKey, sess)
+	}
+
+	sess.mu.Lock()
+	version := sess.version
+	connecting := sess.connecting
+	sess.mu.Unlock()
+
+	packet, size, err := mqtt.DecodePacket(data, version)
+	if err != nil {
+		return 0, err
+	}
+	if packet == nil {
+		return 0, nil
+	}
+
+	if !connecting {
+		connectPacket, ok := packet.(*mqtt.ConnectPacket)
+		if !ok {
+			return 0, fmt.Errorf("%w: first packet must be CONNECT", mqtt.ErrProtocolViolation)
+		}
+		return size, t.handleConnect(conn, sess, connectPacket, w)
+	}
+
+	// 连接成功之前收到的包先缓存，连接成功后再处理
+	sess.mu.Lock()
+	if !sess.connected {
+		if len(sess.pendings) >= maxPendingPackets {
+			sess.mu.Unlock()
+			return 0, fmt.Errorf("%w: too many packets before CONNACK", mqtt.ErrProtocolViolation)
+		}
+		sess.pendings = append(sess.pendings, packet)
+		sess.mu.Unlock()
+		return size, nil
+	}
+	sess.mu.Unlock()
+
+	if err = t.handlePacket(conn, sess, packet, w); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// 处理连接成功后的包，转换出的悟空IM协议的数据写入w
+func (t *Translator) handlePacket(conn wknet.Conn, sess *session, packet mqtt.ControlPacket, w io.Writer) error {
+	var err error
+	switch p := packet.(type) {
+	case *mqtt.PublishPacket:
+		err = t.handlePublish(conn, sess, p, w)
+	case *mqtt.PubackPacket:
+		err = t.handlePuback(sess, p, w)
+	case *mqtt.SubscribePacket:
+		err = t.handleSubscribe(conn, sess, p)
+	case *mqtt.UnsubscribePacket:
+		err = t.handleUnsubscribe(conn, sess, p)
+	case *mqtt.PingreqPacket:
+		err = t.writeFrame(&wkproto.PingPacket{}, w)
+	case *mqtt.DisconnectPacket:
+		if p.ReasonCode != mqtt.DisconnectWithWillMessage { // 正常断开不发送遗嘱消息
+			sess.mu.Lock()
+			sess.will = nil
+			sess.mu.Unlock()
+		}
+		_ = conn.Close()
+	case *mqtt.ConnectPacket:
+		err = fmt.Errorf("%w: duplicate CONNECT", mqtt.ErrProtocolViolation)
+	default:
+		err = fmt.Errorf("%w: unexpected %s", mqtt.ErrProtocolViolation, packet.Header().Type)
+	}
+	return err
+}
+
+// Outbound 将服务端发出的悟空IM协议的数据转换为mqtt协议的数据
+func (t *Translator) Outbound(conn wknet.Conn, data []byte) ([]byte, error) {
+	sess := t.session(conn)
+	if sess == nil {
+		return nil, ErrSessionNotExist
+	}
+	var result []byte
+	offset := 0
+	for len(data) > offset {
+		frame, size, err := options.G.Proto.DecodeFrame(data[offset:], wkproto.LatestVersion)
+		if err != nil {
+			return nil, err
+		}
+		if frame == nil {
+			break
+		}
+		offset += size
+
+		var (
+			packet mqtt.ControlPacket
+			authed bool
+		)
+		switch f := frame.(type) {
+		case *wkproto.ConnackPacket:
+			packet, err = t.handleConnack(sess, f)
+			authed = err == nil && f.ReasonCode == wkproto.ReasonSuccess
+		case *wkproto.SendackPacket:
+			packet = t.handleSendack(sess, f)
+		case *wkproto.RecvPacket:
+			packet, err = t.handleRecv(conn, sess, f)
+		case *wkproto.PongPacket:
+			packet = &mqtt.PingrespPacket{}
+		case *wkproto.DisconnectPacket:
+			packet = t.handleDisconnect(sess, f)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if packet == nil {
+			continue
+		}
+		packetData, err := encodePacket(packet, sess)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, packetData...)
+
+		if authed {
+			// CONNACK需要在缓存的包的回复之前写出
+			t.writeMqttData(conn, result)
+			result = nil
+			t.onAuthed(conn, sess)
+		}
+	}
+	return result, nil
+}
+
+// 认证成功后设置保活时间，处理连接成功之前缓存的包
+func (t *Translator) onAuthed(conn wknet.Conn, sess *session) {
+	sess.mu.Lock()
+	keepAlive := sess.keepAlive
+	sess.mu.Unlock()
+	if keepAlive > 0 {
+		conn.SetMaxIdle(time.Duration(keepAlive) * time.Second * 3 / 2)
+	}
+
+	w := &eventWriter{t: t, conn: conn}
+	for {
+		sess.mu.Lock()
+		pendings := sess.pendings
+		sess.pendings = nil
+		if len(pendings) == 0 {
+			sess.connected = true
+			sess.mu.Unlock()
+			return
+		}
+		sess.mu.Unlock()
+
+		for _, packet := range pendings {
+			if err := t.handlePacket(conn, sess, packet, w); err != nil {
+				t.Warn("handle pending packet failed, conn will be closed", zap.Error(err), zap.String("type", packet.Header().Type.String()))
+				_ = conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// OnClose 连接关闭，非正常断开时发送遗嘱消息，CleanSession为false时保留订阅
+func (t *Translator) OnClose(conn wknet.Conn) {
+	sess := t.session(conn)
+	if sess == nil {
+		return
+	}
+	sess.mu.Lock()
+	authed := sess.authed
+	will := sess.will
+	sess.will = nil
+	uid, clientId := sess.uid, sess.clientId
+	storeKey := sess.storeKey()
+	sessionExpiry := sess.sessionExpiry
+	var subscriptions map[string]byte
+	if sessionExpiry > 0 {
+		subscriptions = make(map[string]byte, len(sess.subscriptions))
+		for filter, qos := range sess.subscriptions {
+			subscriptions[filter] = qos
+		}
+	}
+	sess.mu.Unlock()
+
+	if !authed {
+		return
+	}
+	if sessionExpiry > 0 {
+		t.sessions.save(storeKey, subscriptions, sessionExpiry)
+	}
+	if will != nil {
+		t.sendWill(uid, clientId, will)
+	}
+}
+
+// 以客户端的身份发送遗嘱消息
+func (t *Translator) sendWill(uid, clientId string, w *will) {
+	channelId, channelType, err := topicToChannel(w.topic)
+	if err != nil {
+		t.Warn("will topic is invalid", zap.String("topic", w.topic), zap.Error(err))
+		return
+	}
+	fakeChannelId := channelId
+	if channelType == wkproto.ChannelTypePerson {
+		fakeChannelId = options.GetFakeChannelIDWith(uid, channelId)
+	}
+	t.sendToChannel(fakeChannelId, channelType, &eventbus.Event{
+		Conn: &eventbus.Conn{
+			Uid:        uid,
+			DeviceId:   clientId,
+			DeviceFlag: wkproto.DeviceFlag(options.G.Mqtt.DeviceFlag),
+		},
+		Type: eventbus.EventChannelOnSend,
+		Frame: &wkproto.SendPacket{
+			Framer: wkproto.Framer{
+				RedDot: true,
+			},
+			ClientMsgNo: wkutil.GenUUID(),
+			ChannelID:   channelId,
+			ChannelType: channelType,
+			Payload:     w.payload,
+		},
+		MessageId: t.genMessageId(),
+	})
+}
+
+func (t *Translator) handleConnect(conn wknet.Conn, sess *session, p *mqtt.ConnectPacket, w io.Writer) error {
+	sess.mu.Lock()
+	sess.version = p.ProtocolVersion
+	sess.mu.Unlock()
+
+	uid := strings.TrimSpace(p.Username)
+	if uid == "" {
+		code := mqtt.ConnRefusedBadUsernamePassword
+		if p.ProtocolVersion == mqtt.Version5 {
+			code = byte(mqtt.BadUsernameOrPassword)
+		}
+		t.writeMqtt(conn, sess, &mqtt.ConnackPacket{ReasonCode: code})
+		return errors.New("mqtt username is empty")
+	}
+
+	clientId := p.ClientId
+	assigned := false
+	if clientId == "" {
+		if p.ProtocolVersion != mqtt.Version5 && !p.CleanSession { // 3.1.1要求保留会话的客户端必须提供客户端标识
+			t.writeMqtt(conn, sess, &mqtt.ConnackPacket{ReasonCode: mqtt.ConnRefusedIdentifierRejected})
+			return errors.New("mqtt client id is empty")
+		}
+		clientId = wkutil.GenUUID()
+		assigned = true
+	}
+
+	var willMsg *will
+	if p.WillFlag {
+		if _, _, err := topicToChannel(p.WillTopic); err != nil {
+			t.writeMqtt(conn, sess, &mqtt.ConnackPacket{ReasonCode: connackFailure(mqtt.TopicNameInvalid, mqtt.ConnRefusedNotAuthorized, p.ProtocolVersion)})
+			return err
+		}
+		if p.WillQoS > 1 {
+			t.writeMqtt(conn, sess, &mqtt.ConnackPacket{ReasonCode: connackFailure(mqtt.QoSNotSupported, mqtt.ConnRefusedNotAuthorized, p.ProtocolVersion)})
+			return ErrQoSNotSupported
+		}
+		if p.WillRetain && p.ProtocolVersion == mqtt.Version5 {
+			t.writeMqtt(conn, sess, &mqtt.ConnackPacket{ReasonCode: byte(mqtt.RetainNotSupported)})
+			return errors.New("mqtt will retain not supported")
+		}
+		willMsg = &will{topic: p.WillTopic, payload: p.WillPayload}
+	}
+
+	clientPrivKey, clientPubKey := wkutil.GetCurve25519KeypPair()
+
+	sess.mu.Lock()
+	sess.connecting = true
+	sess.uid = uid
+	sess.clientId = clientId
+	sess.assignedClientId = assigned
+	sess.keepAlive = p.KeepAlive
+	sess.cleanSession = p.CleanSession
+	sess.sessionExpiry = sessionExpiry(p)
+	sess.will = willMsg
+	sess.clientPrivKey = clientPrivKey
+	sess.mu.Unlock()
+
+	return t.writeFrame(&wkproto.ConnectPacket{
+		Version:         wkproto.LatestVersion,
+		DeviceID:        clientId,
+		DeviceFlag:      wkproto.DeviceFlag(options.G.Mqtt.DeviceFlag),
+		ClientKey:       base64.StdEncoding.EncodeToString(clientPubKey[:]),
+		ClientTimestamp: time.Now().UnixNano() / 1000 / 1000,
+		UID:             uid,
+		Token:           string(p.Password),
+	}, w)
+}
+
+func (t *Translator) handleConnack(sess *session, f *wkproto.ConnackPacket) (mqtt.ControlPacket, error) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	connack := &mqtt.ConnackPacket{}
+	if f.ReasonCode != wkproto.ReasonSuccess {
+		connack.ReasonCode = connackReasonCode(f.ReasonCode, sess.version)
+		return connack, nil
+	}
+
+	serverKey, err := base64.StdEncoding.DecodeString(f.ServerKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(serverKey) < 32 {
+		return nil, errors.New("invalid server key")
+	}
+	var serverPubKey [32]byte
+	copy(serverPubKey[:], serverKey[:32])
+	shareKey := wkutil.GetCurve25519Key(sess.clientPrivKey, serverPubKey) // 共享key
+	sess.aesKey = []byte(wkutil.MD5(base64.StdEncoding.EncodeToString(shareKey[:]))[:16])
+	sess.aesIV = []byte(f.Salt)
+	sess.authed = true
+
+	// 恢复或清除保留的会话（认证成功后才能操作，防止其他客户端冒用）
+	if sess.cleanSession {
+		t.sessions.remove(sess.storeKey())
+	} else if subscriptions, ok := t.sessions.take(sess.storeKey()); ok {
+		sess.subscriptions = subscriptions
+		connack.SessionPresent = true
+	}
+
+	if sess.version == mqtt.Version5 {
+		props := mqtt.Properties{}.
+			AddByte(mqtt.PropMaximumQoS, 1).
+			AddByte(mqtt.PropRetainAvailable, 0).
+			AddByte(mqtt.PropSharedSubscriptionAvailable, 0).
+			AddByte(mqtt.PropSubscriptionIdAvailable, 0)
+		if sess.assignedClientId {
+			props = props.AddString(mqtt.PropAssignedClientIdentifier, sess.clientId)
+		}
+		props = props.AddUint32(mqtt.PropSessionExpiryInterval, uint32(sess.sessionExpiry/time.Second))
+		connack.Properties = props
+	}
+	connack.ReasonCode = byte(mqtt.Success)
+	return connack, nil
+}
+
+func (t *Translator) handlePublish(conn wknet.Conn, sess *session, p *mqtt.PublishPacket, w io.Writer) error {
+	if p.QoS > 1 {
+		if sess.version == mqtt.Version5 {
+			t.writeMqtt(conn, sess, &mqtt.DisconnectPacket{ReasonCode: mqtt.QoSNotSupported})
+		}
+		return ErrQoSNotSupported
+	}
+	channelId, channelType, err := topicToChannel(p.TopicName)
+	if err != nil {
+		t.Info("publish topic is invalid", zap.String("topic", p.TopicName), zap.Error(err))
+		if p.QoS == 1 {
+			t.writeMqtt(conn, sess, &mqtt.PubackPacket{PacketId: p.PacketId, ReasonCode: mqtt.TopicNameInvalid})
+		}
+		return nil
+	}
+
+	sess.mu.Lock()
+	aesKey, aesIV := sess.aesKey, sess.aesIV
+	sess.clientSeq++
+	clientSeq := sess.clientSeq
+	if p.QoS == 1 {
+		sess.pendingPubs[clientSeq] = p.PacketId
+	}
+	sess.mu.Unlock()
+
+	payload, err := wkutil.AesEncryptPkcs7Base64(p.Payload, aesKey, aesIV)
+	if err != nil {
+		return err
+	}
+	sendPacket := &wkproto.SendPacket{
+		Framer: wkproto.Framer{
+			RedDot: true,
+		},
+		ClientSeq:   clientSeq,
+		ClientMsgNo: wkutil.GenUUID(),
+		ChannelID:   channelId,
+		ChannelType: channelType,
+		Payload:     payload,
+	}
+	msgKey, err := wkutil.AesEncryptPkcs7Base64([]byte(sendPacket.VerityString()), aesKey, aesIV)
+	if err != nil {
+		return err
+	}
+	sendPacket.MsgKey = wkutil.MD5Bytes(msgKey)
+	return t.writeFrame(sendPacket, w)
+}
+
+func (t *Translator) handleSendack(sess *session, f *wkproto.SendackPacket) mqtt.ControlPacket {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	packetId, ok := sess.pendingPubs[f.ClientSeq]
+	if !ok { // QoS 0
+		return nil
+	}
+	delete(sess.pendingPubs, f.ClientSeq)
+
+	puback := &mqtt.PubackPacket{PacketId: packetId}
+	switch f.ReasonCode {
+	case wkproto.ReasonSuccess:
+		puback.ReasonCode = mqtt.Success
+	case wkproto.ReasonRateLimit:
+		puback.ReasonCode = mqtt.QuotaExceeded
+	case wkproto.ReasonNotAllowSend, wkproto.ReasonNotInWhitelist, wkproto.ReasonInBlacklist, wkproto.ReasonSubscriberNotExist:
+		puback.ReasonCode = mqtt.NotAuthorized
+	default:
+		puback.ReasonCode = mqtt.UnspecifiedError
+	}
+	return puback
+}
+
+func (t *Translator) handlePuback(sess *session, p *mqtt.PubackPacket, w io.Writer) error {
+	sess.mu.Lock()
+	msg, ok := sess.inflight[p.PacketId]
+	if ok {
+		delete(sess.inflight, p.PacketId)
+		delete(sess.inflightIds, msg.messageId)
+	}
+	sess.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return t.writeFrame(&wkproto.RecvackPacket{
+		Framer:     msg.framer,
+		MessageID:  msg.messageId,
+		MessageSeq: msg.messageSeq,
+	}, w)
+}
+
+func (t *Translator) handleSubscribe(conn wknet.Conn, sess *session, p *mqtt.SubscribePacket) error {
+	sess.mu.Lock()
+	suback := &mqtt.SubackPacket{PacketId: p.PacketId}
+	for _, sub := range p.Subscriptions {
+		if strings.HasPrefix(sub.TopicFilter, "$share/") {
+			suback.ReasonCodes = append(suback.ReasonCodes, subackFailure(mqtt.SharedSubscriptionsNotSupported, sess.version))
+			continue
+		}
+		if !mqtt.ValidTopicFilter(sub.TopicFilter) {
+			suback.ReasonCodes = append(suback.ReasonCodes, subackFailure(mqtt.TopicFilterInvalid, sess.version))
+			continue
+		}
+		qos := sub.QoS
+		if qos > 1 {
+			qos = 1
+		}
+		sess.subscriptions[sub.TopicFilter] = qos
+		suback.ReasonCodes = append(suback.ReasonCodes, qos)
+	}
+	sess.mu.Unlock()
+
+	t.writeMqtt(conn, sess, suback)
+	return nil
+}
+
+func (t *Translator) handleUnsubscribe(conn wknet.Conn, sess *session, p *mqtt.UnsubscribePacket) error {
+	sess.mu.Lock()
+	unsuback := &mqtt.UnsubackPacket{PacketId: p.PacketId}
+	for _, filter := range p.TopicFilters {
+		delete(sess.subscriptions, filter)
+		unsuback.ReasonCodes = append(unsuback.ReasonCodes, byte(mqtt.Success))
+	}
+	sess.mu.Unlock()
+
+	t.writeMqtt(conn, sess, unsuback)
+	return nil
+}
+
+func (t *Translator) handleRecv(conn wknet.Conn, sess *session, f *wkproto.RecvPacket) (mqtt.ControlPacket, error) {
+	topic := channelToTopic(f.ChannelID, f.ChannelType)
+
+	sess.mu.Lock()
+	qos, matched := sess.matchQoS(topic)
+	aesKey, aesIV := sess.aesKey, sess.aesIV
+	var (
+		packetId uint16
+		dup      bool
+	)
+	if matched && qos == 1 {
+		var ok bool
+		packetId, dup = sess.inflightIds[f.MessageID]
+		if !dup { // 重试的消息复用之前的包标识
+			packetId, ok = sess.nextPacketId()
+			if !ok {
+				sess.mu.Unlock()
+				t.Warn("too many inflight messages, drop it and wait for retry", zap.Int64("messageId", f.MessageID))
+				return nil, nil
+			}
+			sess.inflight[packetId] = &inflightMsg{
+				framer:     f.Framer,
+				messageId:  f.MessageID,
+				messageSeq: f.MessageSeq,
+			}
+			sess.inflightIds[f.MessageID] = packetId
+		}
+	}
+	sess.mu.Unlock()
+
+	// 没有匹配的订阅，回执后丢弃
+	if !matched {
+		t.Debug("no matching subscription, drop it", zap.String("topic", topic), zap.Int64("messageId", f.MessageID))
+		t.recvack(conn, f)
+		return nil, nil
+	}
+	// QoS 0的消息直接回执
+	if qos == 0 {
+		t.recvack(conn, f)
+	}
+
+	payload := f.Payload
+	if !f.Setting.IsSet(wkproto.SettingNoEncrypt) {
+		var err error
+		payload, err = wkutil.AesDecryptPkcs7Base64(f.Payload, aesKey, aesIV)
+		if err != nil {
+			t.Warn("decrypt payload failed", zap.Error(err), zap.Int64("messageId", f.MessageID))
+			return nil, nil
+		}
+	}
+	return &mqtt.PublishPacket{
+		Dup:       dup,
+		QoS:       qos,
+		TopicName: topic,
+		PacketId:  packetId,
+		Payload:   payload,
+	}, nil
+}
+
+func (t *Translator) handleDisconnect(sess *session, f *wkproto.DisconnectPacket) mqtt.ControlPacket {
+	if sess.version != mqtt.Version5 { // 3.1.1没有服务端发出的DISCONNECT，直接等待连接关闭
+		return nil
+	}
+	disconnect := &mqtt.DisconnectPacket{ReasonCode: mqtt.UnspecifiedError}
+	if f.ReasonCode == wkproto.ReasonConnectKick {
+		disconnect.ReasonCode = mqtt.SessionTakenOver
+	}
+	if f.Reason != "" {
+		disconnect.Properties = mqtt.Properties{}.AddString(mqtt.PropReasonString, f.Reason)
+	}
+	return disconnect
+}
+
+// 回执消息（在用户事件里处理，与客户端发来的RECVACK一致）
+func (t *Translator) recvack(conn wknet.Conn, f *wkproto.RecvPacket) {
+	connCtxObj := conn.Context()
+	if connCtxObj == nil {
+		return
+	}
+	connCtx := connCtxObj.(*eventbus.Conn)
+	t.addEvents(connCtx.Uid, []*eventbus.Event{
+		{
+			Type: eventbus.EventOnSend,
+			Frame: &wkproto.RecvackPacket{
+				Framer:     f.Framer,
+				MessageID:  f.MessageID,
+				MessageSeq: f.MessageSeq,
+			},
+			Conn:         connCtx,
+			SourceNodeId: options.G.Cluster.NodeId,
+		},
+	})
+}
+
+func (t *Translator) session(conn wknet.Conn) *session {
+	sess, _ := conn.Value(sessionKey).(*session)
+	return sess
+}
+
+// 写入悟空IM协议的包
+func (t *Translator) writeFrame(frame wkproto.Frame, w io.Writer) error {
+	data, err := options.G.Proto.EncodeFrame(frame, wkproto.LatestVersion)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// 直接给客户端写入mqtt包
+func (t *Translator) writeMqtt(conn wknet.Conn, sess *session, packet mqtt.ControlPacket) {
+	data, err := encodePacket(packet, sess)
+	if err != nil {
+		t.Error("encode mqtt packet failed", zap.Error(err), zap.String("type", packet.Header().Type.String()))
+		return
+	}
+	t.writeMqttData(conn, data)
+}
+
+func (t *Translator) writeMqttData(conn wknet.Conn, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	mqttConn, ok := conn.(wknet.IMqttConn)
+	if !ok {
+		t.Error("conn is not mqtt conn", zap.Int64("connId", conn.ID()))
+		return
+	}
+	if err := mqttConn.WriteMqtt(data); err != nil {
+		t.Warn("write mqtt packet failed", zap.Error(err))
+	}
+}
+
+// 连接成功之前缓存的包在认证成功后处理时，转换出的悟空IM协议的包直接作为用户事件处理（与proto.go里处理客户端的包一致）
+type eventWriter struct {
+	t    *Translator
+	conn wknet.Conn
+}
+
+func (e *eventWriter) Write(data []byte) (int, error) {
+	connCtx, _ := e.conn.Context().(*eventbus.Conn)
+	if connCtx == nil {
+		return 0, ErrConnNotAuthed
+	}
+	frame, _, err := options.G.Proto.DecodeFrame(data, connCtx.ProtoVersion)
+	if err != nil {
+		return 0, err
+	}
+	if frame == nil {
+		return 0, io.ErrShortWrite
+	}
+	event := &eventbus.Event{
+		Type:         eventbus.EventOnSend,
+		Frame:        frame,
+		Conn:         connCtx,
+		SourceNodeId: options.G.Cluster.NodeId,
+	}
+	if frame.GetFrameType() == wkproto.SEND {
+		event.MessageId = e.t.genMessageId()
+	}
+	e.t.addEvents(connCtx.Uid, []*eventbus.Event{event})
+	return len(data), nil
+}
+
+func encodePacket(packet mqtt.ControlPacket, sess *session) ([]byte, error) {
+	sess.mu.Lock()
+	packet.Header().Version = sess.version
+	sess.mu.Unlock()
+	return mqtt.EncodePacket(packet)
+}
+
+// 悟空IM的连接原因码转换为mqtt的
+func connackReasonCode(reasonCode wkproto.ReasonCode, version byte) byte {
+	if version == mqtt.Version5 {
+		switch reasonCode {
+		case wkproto.ReasonAuthFail:
+			return byte(mqtt.BadUsernameOrPassword)
+		case wkproto.ReasonBan:
+			return byte(mqtt.Banned)
+		}
+		return byte(mqtt.UnspecifiedError)
+	}
+	switch reasonCode {
+	case wkproto.ReasonAuthFail:
+		return mqtt.ConnRefusedBadUsernamePassword
+	case wkproto.ReasonBan:
+		return mqtt.ConnRefusedNotAuthorized
+	}
+	return mqtt.ConnRefusedServerUnavailable
+}
+
+func subackFailure(reasonCode mqtt.ReasonCode, version byte) byte {
+	if version == mqtt.Version5 {
+		return byte(reasonCode)
+	}
+	return mqtt.SubackFailure
+}
+
+// 断开后会话保留的时长
+// 3.1.1: CleanSession为false时保留配置的时长
+// MQTT 5: 取客户端的Session Expiry Interval与配置的较小值
+func sessionExpiry(p *mqtt.ConnectPacket) time.Duration {
+	maxExpiry := options.G.Mqtt.SessionExpiry
+	if p.ProtocolVersion != mqtt.Version5 {
+		if p.CleanSession {
+			return 0
+		}
+		return maxExpiry
+	}
+	interval, _ := p.Properties.Uint32(mqtt.PropSessionExpiryInterval)
+	expiry := time.Duration(interval) * time.Second
+	if expiry > maxExpiry {
+		expiry = maxExpiry
+	}
+	return expiry
+}
+
+func connackFailure(reasonCode mqtt.ReasonCode, returnCode byte, version byte) byte {
+	if version == mqtt.Version5 {
+		return byte(reasonCode)
+	}
+	return returnCode
+}
@@ -0,0 +1,364 @@
+package mqtt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/internal/eventbus"
+	"github.com/WuKongIM/WuKongIM/internal/options"
+	"github.com/WuKongIM/WuKongIM/pkg/mqtt"
+	"github.com/WuKongIM/WuKongIM/pkg/wknet"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+	"github.com/stretchr/testify/assert"
+)
+
+type testConn struct {
+	wknet.Conn
+	mu      sync.Mutex
+	values  map[string]interface{}
+	ctx     interface{}
+	written []byte // 直接写给客户端的mqtt数据
+	maxIdle time.Duration
+	closed  bool
+}
+
+func newTestConn() *testConn {
+	return &testConn{values: make(map[string]interface{})}
+}
+
+func (c *testConn) ID() int64                              { return 1 }
+func (c *testConn) SetValue(key string, value interface{}) { c.values[key] = value }
+func (c *testConn) Value(key string) interface{}           { return c.values[key] }
+func (c *testConn) SetContext(ctx interface{})             { c.ctx = ctx }
+func (c *testConn) Context() interface{}                   { return c.ctx }
+func (c *testConn) SetMaxIdle(d time.Duration)             { c.maxIdle = d }
+
+func (c *testConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+func (c *testConn) WriteMqtt(data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.written = append(c.written, data...)
+	return nil
+}
+
+// 取出直接写给客户端的mqtt包
+func (c *testConn) takePackets(t *testing.T, version byte) []mqtt.ControlPacket {
+	c.mu.Lock()
+	data := c.written
+	c.written = nil
+	c.mu.Unlock()
+	return decodePackets(t, data, version)
+}
+
+func decodePackets(t *testing.T, data []byte, version byte) []mqtt.ControlPacket {
+	var packets []mqtt.ControlPacket
+	for len(data) > 0 {
+		packet, size, err := mqtt.DecodePacket(data, version)
+		assert.NoError(t, err)
+		if packet == nil {
+			break
+		}
+		packets = append(packets, packet)
+		data = data[size:]
+	}
+	return packets
+}
+
+type testRecorder struct {
+	mu     sync.Mutex
+	events []*eventbus.Event // 用户事件
+	sends  []*eventbus.Event // 发送到频道的消息
+}
+
+func newTestTranslator() (*Translator, *testRecorder) {
+	options.G = options.New()
+	r := &testRecorder{}
+	t := NewTranslator()
+	t.addEvents = func(uid string, events []*eventbus.Event) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.events = append(r.events, events...)
+	}
+	t.sendToChannel = func(fakeChannelId string, channelType uint8, event *eventbus.Event) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.sends = append(r.sends, event)
+	}
+	t.genMessageId = func() int64 { return 1 }
+	return t, r
+}
+
+func inbound(t *testing.T, tr *Translator, conn *testConn, packet mqtt.ControlPacket) []byte {
+	if sess := tr.session(conn); sess != nil { // 按连接的协议版本编码
+		packet.Header().Version = sess.version
+	}
+	data, err := mqtt.EncodePacket(packet)
+	assert.NoError(t, err)
+	w := &bytes.Buffer{}
+	size, err := tr.Inbound(conn, data, w)
+	assert.NoError(t, err)
+	assert.Equal(t, len(data), size)
+	return w.Bytes()
+}
+
+func outbound(t *testing.T, tr *Translator, conn *testConn, frame wkproto.Frame) []byte {
+	data, err := options.G.Proto.EncodeFrame(frame, wkproto.LatestVersion)
+	assert.NoError(t, err)
+	result, err := tr.Outbound(conn, data)
+	assert.NoError(t, err)
+	return result
+}
+
+// 发起连接并认证成功
+func connect(t *testing.T, tr *Translator, conn *testConn, p *mqtt.ConnectPacket) *mqtt.ConnackPacket {
+	p.ProtocolName = "MQTT"
+	p.UsernameFlag = true
+	p.Username = "u1"
+	p.PasswordFlag = true
+	p.Password = []byte("token")
+	data := inbound(t, tr, conn, p)
+	frame, _, err := options.G.Proto.DecodeFrame(data, wkproto.LatestVersion)
+	assert.NoError(t, err)
+	connectFrame := frame.(*wkproto.ConnectPacket)
+	assert.Equal(t, "u1", connectFrame.UID)
+
+	conn.SetContext(&eventbus.Conn{Uid: "u1", DeviceId: connectFrame.DeviceID, ProtoVersion: wkproto.LatestVersion})
+	_, serverPubKey := wkutil.GetCurve25519KeypPair()
+	result := outbound(t, tr, conn, &wkproto.ConnackPacket{
+		ServerKey:  base64.StdEncoding.EncodeToString(serverPubKey[:]),
+		Salt:       "0123456789abcdef",
+		ReasonCode: wkproto.ReasonSuccess,
+	})
+	assert.Empty(t, result) // CONNACK在处理缓存的包之前直接写出
+	packets := conn.takePackets(t, p.ProtocolVersion)
+	assert.NotEmpty(t, packets)
+	return packets[0].(*mqtt.ConnackPacket)
+}
+
+func TestTopicToChannel(t *testing.T) {
+	for _, topic := range []string{"person/u2", "group/g1", "channel/10/c1"} {
+		channelId, channelType, err := topicToChannel(topic)
+		assert.NoError(t, err)
+		assert.Equal(t, topic, channelToTopic(channelId, channelType))
+	}
+	for _, topic := range []string{"person", "person/", "unknown/u1", "channel/x/c1", "channel/10"} {
+		_, _, err := topicToChannel(topic)
+		assert.Error(t, err, topic)
+	}
+}
+
+func TestPendingPacketsAfterConnack(t *testing.T) {
+	tr, r := newTestTranslator()
+	conn := newTestConn()
+
+	data := inbound(t, tr, conn, &mqtt.ConnectPacket{
+		ProtocolName:    "MQTT",
+		ProtocolVersion: mqtt.Version311,
+		CleanSession:    true,
+		ClientId:        "d1",
+		UsernameFlag:    true,
+		Username:        "u1",
+		PasswordFlag:    true,
+		Password:        []byte("token"),
+	})
+	assert.NotEmpty(t, data)
+
+	// 不等CONNACK就发送的包先缓存
+	assert.Empty(t, inbound(t, tr, conn, &mqtt.SubscribePacket{PacketId: 1, Subscriptions: []mqtt.Subscription{{TopicFilter: "group/+", QoS: 1}}}))
+	assert.Empty(t, inbound(t, tr, conn, &mqtt.PublishPacket{QoS: 1, PacketId: 2, TopicName: "group/g1", Payload: []byte("hello")}))
+	assert.Empty(t, r.events)
+
+	conn.SetContext(&eventbus.Conn{Uid: "u1", DeviceId: "d1", ProtoVersion: wkproto.LatestVersion})
+	_, serverPubKey := wkutil.GetCurve25519KeypPair()
+	outbound(t, tr, conn, &wkproto.ConnackPacket{
+		ServerKey:  base64.StdEncoding.EncodeToString(serverPubKey[:]),
+		Salt:       "0123456789abcdef",
+		ReasonCode: wkproto.ReasonSuccess,
+	})
+
+	// CONNACK之后才是缓存的包的回复
+	packets := conn.takePackets(t, mqtt.Version311)
+	assert.Len(t, packets, 2)
+	assert.Equal(t, mqtt.CONNACK, packets[0].Header().Type)
+	assert.Equal(t, mqtt.SUBACK, packets[1].Header().Type)
+
+	// 缓存的PUBLISH转换为发送事件
+	assert.Len(t, r.events, 1)
+	send := r.events[0].Frame.(*wkproto.SendPacket)
+	assert.Equal(t, "g1", send.ChannelID)
+	assert.Equal(t, wkproto.ChannelTypeGroup, send.ChannelType)
+	assert.Equal(t, int64(1), r.events[0].MessageId)
+
+	// 连接成功后直接转换
+	data = inbound(t, tr, conn, &mqtt.PingreqPacket{})
+	frame, _, err := options.G.Proto.DecodeFrame(data, wkproto.LatestVersion)
+	assert.NoError(t, err)
+	assert.Equal(t, wkproto.PING, frame.GetFrameType())
+}
+
+func TestPendingPacketsOverflow(t *testing.T) {
+	tr, _ := newTestTranslator()
+	conn := newTestConn()
+	inbound(t, tr, conn, &mqtt.ConnectPacket{ProtocolName: "MQTT", ProtocolVersion: mqtt.Version311, CleanSession: true, UsernameFlag: true, Username: "u1"})
+	for i := 0; i < maxPendingPackets; i++ {
+		inbound(t, tr, conn, &mqtt.PingreqPacket{})
+	}
+	data, _ := mqtt.EncodePacket(&mqtt.PingreqPacket{})
+	_, err := tr.Inbound(conn, data, &bytes.Buffer{})
+	assert.ErrorIs(t, err, mqtt.ErrProtocolViolation)
+}
+
+func TestKeepAlive(t *testing.T) {
+	tr, _ := newTestTranslator()
+	conn := newTestConn()
+	connect(t, tr, conn, &mqtt.ConnectPacket{ProtocolVersion: mqtt.Version311, CleanSession: true, KeepAlive: 60})
+	assert.Equal(t, time.Second*90, conn.maxIdle)
+
+	// 没有保活时间使用服务端的连接空闲时间
+	conn = newTestConn()
+	connect(t, tr, conn, &mqtt.ConnectPacket{ProtocolVersion: mqtt.Version311, CleanSession: true})
+	assert.Equal(t, time.Duration(0), conn.maxIdle)
+}
+
+func TestRecvWithoutSubscription(t *testing.T) {
+	tr, r := newTestTranslator()
+	conn := newTestConn()
+	connect(t, tr, conn, &mqtt.ConnectPacket{ProtocolVersion: mqtt.Version311, CleanSession: true})
+
+	recv := &wkproto.RecvPacket{
+		Setting:     wkproto.SettingNoEncrypt,
+		MessageID:   100,
+		MessageSeq:  1,
+		ChannelID:   "g1",
+		ChannelType: wkproto.ChannelTypeGroup,
+		Payload:     []byte("hello"),
+	}
+	// 没有匹配的订阅，不投递但回执，服务端不再重试
+	assert.Empty(t, outbound(t, tr, conn, recv))
+	assert.Len(t, r.events, 1)
+	assert.Equal(t, wkproto.RECVACK, r.events[0].Frame.GetFrameType())
+	r.events = nil
+
+	// 订阅后投递，QoS 0直接回执
+	inbound(t, tr, conn, &mqtt.SubscribePacket{PacketId: 1, Subscriptions: []mqtt.Subscription{{TopicFilter: "group/g1", QoS: 0}}})
+	packets := decodePackets(t, outbound(t, tr, conn, recv), mqtt.Version311)
+	assert.Len(t, packets, 1)
+	publish := packets[0].(*mqtt.PublishPacket)
+	assert.Equal(t, "group/g1", publish.TopicName)
+	assert.Equal(t, []byte("hello"), publish.Payload)
+	assert.Len(t, r.events, 1)
+	assert.Equal(t, wkproto.RECVACK, r.events[0].Frame.GetFrameType())
+}
+
+func TestWill(t *testing.T) {
+	tr, r := newTestTranslator()
+	newWillConnect := func(version byte) *mqtt.ConnectPacket {
+		return &mqtt.ConnectPacket{
+			ProtocolVersion: version,
+			CleanSession:    true,
+			ClientId:        "d1",
+			WillFlag:        true,
+			WillTopic:       "person/u2",
+			WillPayload:     []byte("offline"),
+		}
+	}
+
+	// 非正常断开发送遗嘱消息
+	conn := newTestConn()
+	connect(t, tr, conn, newWillConnect(mqtt.Version311))
+	tr.OnClose(conn)
+	assert.Len(t, r.sends, 1)
+	send := r.sends[0].Frame.(*wkproto.SendPacket)
+	assert.Equal(t, "u2", send.ChannelID)
+	assert.Equal(t, wkproto.ChannelTypePerson, send.ChannelType)
+	assert.Equal(t, []byte("offline"), send.Payload)
+	assert.Equal(t, "u1", r.sends[0].Conn.Uid)
+
+	// 正常断开不发送
+	conn = newTestConn()
+	connect(t, tr, conn, newWillConnect(mqtt.Version311))
+	inbound(t, tr, conn, &mqtt.DisconnectPacket{})
+	assert.True(t, conn.closed)
+	tr.OnClose(conn)
+	assert.Len(t, r.sends, 1)
+
+	// MQTT 5 要求发送遗嘱消息的断开
+	conn = newTestConn()
+	connect(t, tr, conn, newWillConnect(mqtt.Version5))
+	inbound(t, tr, conn, &mqtt.DisconnectPacket{ReasonCode: mqtt.DisconnectWithWillMessage})
+	tr.OnClose(conn)
+	assert.Len(t, r.sends, 2)
+
+	// 没有认证成功不发送
+	conn = newTestConn()
+	p := newWillConnect(mqtt.Version311)
+	p.ProtocolName, p.UsernameFlag, p.Username = "MQTT", true, "u1"
+	inbound(t, tr, conn, p)
+	tr.OnClose(conn)
+	assert.Len(t, r.sends, 2)
+}
+
+func TestCleanSession(t *testing.T) {
+	tr, _ := newTestTranslator()
+	subscribe := &mqtt.SubscribePacket{PacketId: 1, Subscriptions: []mqtt.Subscription{{TopicFilter: "group/g1", QoS: 1}}}
+
+	conn := newTestConn()
+	connack := connect(t, tr, conn, &mqtt.ConnectPacket{ProtocolVersion: mqtt.Version311, ClientId: "d1"})
+	assert.False(t, connack.SessionPresent)
+	inbound(t, tr, conn, subscribe)
+	tr.OnClose(conn)
+
+	// 重连后恢复订阅
+	conn = newTestConn()
+	connack = connect(t, tr, conn, &mqtt.ConnectPacket{ProtocolVersion: mqtt.Version311, ClientId: "d1"})
+	assert.True(t, connack.SessionPresent)
+	assert.Equal(t, map[string]byte{"group/g1": 1}, tr.session(conn).subscriptions)
+	tr.OnClose(conn)
+
+	// 清除会话
+	conn = newTestConn()
+	connack = connect(t, tr, conn, &mqtt.ConnectPacket{ProtocolVersion: mqtt.Version311, ClientId: "d1", CleanSession: true})
+	assert.False(t, connack.SessionPresent)
+	assert.Empty(t, tr.session(conn).subscriptions)
+	tr.OnClose(conn)
+	_, ok := tr.sessions.take("u1/d1")
+	assert.False(t, ok)
+
+	// MQTT 5 会话过期时间为0不保留
+	conn = newTestConn()
+	connect(t, tr, conn, &mqtt.ConnectPacket{ProtocolVersion: mqtt.Version5, ClientId: "d1"})
+	inbound(t, tr, conn, subscribe)
+	tr.OnClose(conn)
+	_, ok = tr.sessions.take("u1/d1")
+	assert.False(t, ok)
+
+	// 3.1.1 保留会话必须提供客户端标识
+	conn = newTestConn()
+	data, _ := mqtt.EncodePacket(&mqtt.ConnectPacket{ProtocolName: "MQTT", ProtocolVersion: mqtt.Version311, UsernameFlag: true, Username: "u1"})
+	_, err := tr.Inbound(conn, data, &bytes.Buffer{})
+	assert.Error(t, err)
+	packets := conn.takePackets(t, mqtt.Version311)
+	assert.Equal(t, mqtt.ConnRefusedIdentifierRejected, packets[0].(*mqtt.ConnackPacket).ReasonCode)
+}
+
+func TestSessionStoreExpire(t *testing.T) {
+	s := newSessionStore()
+	s.save("u1/d1", map[string]byte{"group/g1": 0}, -time.Second)
+	_, ok := s.take("u1/d1")
+	assert.False(t, ok)
+
+	s.save("u1/d1", map[string]byte{"group/g1": 0}, time.Minute)
+	subscriptions, ok := s.take("u1/d1")
+	assert.True(t, ok)
+	assert.Equal(t, map[string]byte{"group/g1": 0}, subscriptions)
+	_, ok = s.take("u1/d1") // 取出后删除
+	assert.False(t, ok)
+}
@@ -69,6 +69,15 @@ type Options struct {
 		CertFile string // 证书文件
 		KeyFile  string // 私钥文件
 	}
+	TCPTLS struct { // tcp长连接（Addr）的tls配置，开启后tcp监听只接受tls连接
+		On             bool          // 是否开启
+		CertFile       string        // 证书文件
+		KeyFile        string        // 私钥文件
+		ClientCAFile   string        // 客户端证书的CA文件，配置后要求客户端提供证书（mTLS）
+		MinVersion     string        // 最低的tls版本 例如：1.2
+		ReloadInterval time.Duration // 检查证书文件变化的间隔，证书文件变化后自动加载新证书 0表示不检查
+	}
+	TCPTLSConfig *tls.Config
 
 	Logger struct {
 		Dir              string // 日志存储目录
@@ -157,7 +166,15 @@ type Options struct {
 	TimingWheelTick time.Duration // The time-round training interval must be 1ms or more
 	TimingWheelSize int64         // Time wheel size
 
-	UserMsgQueueMaxSize int // 用户消息队列最大大小，超过此大小此用户将被限速，0为不限制
+	UserMsgQueueMaxSize int // 已废弃，用户发送消息的限速请使用 RateLimit 配置
+
+	// 发送消息的速率限制（令牌桶，系统账号和系统设备不受限制）
+	RateLimit struct {
+		On          bool            // 是否开启发送速率限制
+		User        RateLimitConfig // 每个用户的发送速率（所有频道）
+		UserChannel RateLimitConfig // 每个用户在每个频道的发送速率，频道配置了慢速模式（slow_mode）时以频道配置为准
+		Channel     RateLimitConfig // 每个频道的发送速率（所有成员），频道配置了发送速率限制（send_rate_limit）时以频道配置为准
+	}
 
 	TokenAuthOn bool // 是否开启token验证 不配置将根据mode属性判断 debug模式下默认为false release模式为true
 
@@ -203,6 +220,73 @@ type Options struct {
 		WorkerCount  int           // worker数量
 	}
 
+	// 在线状态订阅
+	Presence struct {
+		On                bool          // 是否开启在线状态订阅
+		NotifyInterval    time.Duration // 同一个用户在线状态变化的最小推送间隔，间隔内的多次变化只推送最后一次状态（防止频繁上下线的用户刷屏）
+		MaxSubscribeCount int           // 每个用户最多能订阅的用户数量
+	}
+
+	LoginLog struct {
+		On       bool // 是否记录用户登录日志
+		MaxCount int  // 每个用户最多保留的登录日志数量，超过后最旧的日志将被删除
+	}
+
+	AuditLog struct {
+		On        bool          // 是否记录审计日志（修改类的api调用）
+		Retention time.Duration // 审计日志的保留时间，超过后将被删除
+	}
+
+	// 连接准入控制（在连接建立时判断，防止连接洪水耗尽文件描述符）
+	Admission struct {
+		On                 bool          // 是否开启连接准入控制
+		MaxConnPerIp       int           // 每个ip最大的连接数量 0表示不限制
+		ConnRate           float64       // 每个ip每秒允许新建的连接数量 0表示不限制
+		ConnBurst          int           // 每个ip允许突发新建的连接数量 0表示取max(1, ConnRate)
+		AuthFailMax        int           // 在AuthFailWindow时间内认证失败达到此次数后自动封禁ip 0表示不自动封禁
+		AuthFailWindow     time.Duration // 认证失败的统计窗口
+		BanDuration        time.Duration // 自动封禁的时长
+		AllowCidrs         []string      // 静态白名单（配置后只有白名单内的ip才能连接） 例如 10.0.0.0/8
+		DenyCidrs          []string      // 静态黑名单 例如 192.168.1.0/24
+		TrustedProxies     []string      // 受信任的代理（负载均衡）的ip段，来自代理的连接在解析代理协议（proxy protocol）获得真实ip后再做准入判断
+		ProxyHeaderTimeout time.Duration // 等待受信任代理发送代理协议头的时长，超时或第一个包不是代理协议头则以代理的ip做准入判断
+	}
+
+	// http长连接（SSE下行 + POST上行，也支持长轮询下行），用于不能使用websocket的网络环境
+	HttpConn struct {
+		On          bool          // 是否开启
+		Addr        string        // 监听地址 例如：0.0.0.0:5220
+		MaxFrames   int           // 每个会话最多缓存的未被客户端收到的下行包数量，客户端断开后可以从游标处恢复，超过后关闭会话
+		PollTimeout time.Duration // 长轮询最长的等待时间
+	}
+
+	// mqtt网关（mqtt 3.1.1/5的客户端通过mqtt协议接入，映射到悟空IM的频道）
+	Mqtt struct {
+		On            bool          // 是否开启mqtt网关
+		Addr          string        // mqtt监听地址 例如：tcp://0.0.0.0:1883
+		DeviceFlag    uint8         // mqtt客户端的设备标识 0.app 1.web 2.pc
+		SessionExpiry time.Duration // CleanSession为false的会话（订阅）在客户端断开后保留的最长时间（MQTT 5取客户端的Session Expiry Interval与此值的较小值）
+	}
+
+	// websocket的json协议（浏览器客户端连接wss时通过子协议wukongim.json协商后使用json文本帧通讯）
+	WSJson struct {
+		On         bool  // 是否开启
+		DeviceFlag uint8 // 客户端没有指定设备标识时使用的设备标识 0.app 1.web 2.pc
+	}
+
+	// websocket的permessage-deflate压缩（RFC 7692），客户端支持时才会使用
+	WSCompression struct {
+		On                      bool // 是否开启
+		ServerNoContextTakeover bool // 服务端每个消息单独压缩（压缩率低，但是每个连接不用保留压缩器的状态，连接多时节省内存）
+		ClientNoContextTakeover bool // 要求客户端每个消息单独压缩（服务端不用为每个连接保留解压的字典）
+		Threshold               int  // 大于等于此大小的消息才压缩（字节），小消息压缩收益小
+		Level                   int  // 压缩级别 1-9 越大压缩率越高越耗cpu
+	}
+
+	ApiKey struct {
+		On bool // 是否开启业务api的api key认证，开启后请求需要在header中携带X-Api-Key（需要配置managerToken，节点之间的api调用使用managerToken认证）
+	}
+
 	Cluster struct {
 		NodeId              uint64        // 节点ID,节点Id，必须小于或等于1023 （https://github.com/bwmarrin/snowflake 雪花算法的限制）
 		Addr                string        // 节点监听地址 例如：tcp://0.0.0.0:11110
@@ -225,6 +309,30 @@ type Options struct {
 		SlotReactorSubCount    int // 槽reactor sub的数量
 
 		PongMaxTick int // 节点超过多少tick没有回应心跳就认为是掉线
+
+		SlotReplicaBalanceOn          bool // 是否开启槽副本自动均衡（有节点加入后，将槽副本均匀分布到各个节点）
+		SlotReplicaBalanceConcurrency int  // 槽副本均衡（以及节点离开）时同时迁移的最大槽数量
+
+		Zone                        string        // 节点所在区域（槽和频道的副本会尽量分布在不同的区域）
+		ChannelPlacement            string        // 频道副本放置策略 random: 随机 leastLoaded: 负载最低优先 zoneSpread: 区域分散
+		ChannelBalanceOn            bool          // 是否开启频道副本自动均衡（将热点节点上的频道领导和副本迁移到负载低的节点）
+		ChannelBalanceInterval      time.Duration // 频道副本均衡检查间隔
+		ChannelBalanceThreshold     float64       // 节点负载超过平均负载多少比例视为热点节点
+		ChannelBalanceMaxMigrations int           // 每轮整个集群最多迁移的频道数量（按领导的槽数量分给各个槽领导）
+
+		SlotCompactLogThreshold uint64 // 槽已应用但未压缩的日志超过此数量时压缩日志，0表示不压缩
+
+		ConfigPreVote     bool // 集群配置raft是否开启预投票（所有节点升级到支持预投票的版本后才能开启）
+		ConfigCheckQuorum bool // 集群配置raft是否开启法定数检查（领导联系不上多数节点时退为追随者）
+
+		Secret string   // 节点之间通讯的共享密钥，配置后节点连接时校验（所有节点必须一致）
+		TLS    struct { // 节点之间通讯的tls配置（mTLS），开启后节点之间只接受集群CA签发的证书，证书的CommonName必须是节点id，开启后节点之间的吞吐量会明显下降（见wkserver的tlsProxy）
+			On         bool   // 是否开启
+			CertFile   string // 节点证书文件
+			KeyFile    string // 节点私钥文件
+			CAFile     string // 集群CA证书文件
+			MinVersion string // 最低的tls版本 例如：1.2
+		}
 	}
 
 	Trace struct {
@@ -287,9 +395,21 @@ type Options struct {
 	Auth auth.AuthConfig // 认证配置
 
 	Jwt struct {
-		Secret string        // jwt secret
-		Expire time.Duration // jwt expire
-		Issuer string        // jwt 发行者名字
+		Secret        string        // jwt secret
+		Expire        time.Duration // jwt expire 访问token的过期时间
+		RefreshExpire time.Duration // 刷新token的过期时间（登录会话的最长有效期）
+		Issuer        string        // jwt 发行者名字
+	}
+
+	// 连接token的jwt认证（业务服务签发jwt作为连接token，节点本地验签，不需要先调用/user/token）
+	TokenJwt struct {
+		On        bool   // 是否开启jwt连接token，开启后客户端可以直接使用jwt作为连接token（tokenAuthOn也需要开启）
+		Secret    string // HS256签名的密钥
+		PublicKey string // RS256/ES256签名的公钥（PEM格式的内容或者公钥文件路径），配置了公钥将优先使用公钥验签
+		Issuer    string // jwt签发者，不为空则校验jwt的iss字段
+		// MaxAge jwt的最长有效期（exp - iat），超过的jwt不接受。设备退出后的吊销记录保留这么久，
+		// 保证退出前签发的jwt在吊销记录删除前都已过期
+		MaxAge time.Duration
 	}
 	PprofOn          bool        // 是否开启pprof
 	OldV1Api         string      //旧v1版本的api地址，如果不为空则开启数据迁移任务，将v1的数据迁移到v2
@@ -303,6 +423,12 @@ type Options struct {
 	}
 }
 
+// RateLimitConfig 令牌桶的速率配置
+type RateLimitConfig struct {
+	Rate  float64 // 每秒允许发送的消息数量 0表示不限制
+	Burst int     // 允许突发的消息数量 0表示取max(1, Rate)
+}
+
 type MigrateStep string
 
 const (
@@ -365,6 +491,18 @@ func New(op ...Option) *Options {
 		WSSAddr:             "",
 		ConnIdleTime:        time.Minute * 3,
 		UserMsgQueueMaxSize: 0,
+		TCPTLS: struct {
+			On             bool
+			CertFile       string
+			KeyFile        string
+			ClientCAFile   string
+			MinVersion     string
+			ReloadInterval time.Duration
+		}{
+			On:             false,
+			MinVersion:     "1.2",
+			ReloadInterval: time.Second * 10,
+		},
 		TmpChannel: struct {
 			Suffix     string
 			CacheCount int
@@ -476,6 +614,95 @@ func New(op ...Option) *Options {
 			MaxCount:     5,
 			WorkerCount:  128,
 		},
+		Presence: struct {
+			On                bool
+			NotifyInterval    time.Duration
+			MaxSubscribeCount int
+		}{
+			On:                true,
+			NotifyInterval:    time.Second * 3,
+			MaxSubscribeCount: 5000,
+		},
+		LoginLog: struct {
+			On       bool
+			MaxCount int
+		}{
+			On:       true,
+			MaxCount: 20,
+		},
+		AuditLog: struct {
+			On        bool
+			Retention time.Duration
+		}{
+			On:        true,
+			Retention: time.Hour * 24 * 90,
+		},
+		Admission: struct {
+			On                 bool
+			MaxConnPerIp       int
+			ConnRate           float64
+			ConnBurst          int
+			AuthFailMax        int
+			AuthFailWindow     time.Duration
+			BanDuration        time.Duration
+			AllowCidrs         []string
+			DenyCidrs          []string
+			TrustedProxies     []string
+			ProxyHeaderTimeout time.Duration
+		}{
+			On:                 false,
+			MaxConnPerIp:       0,
+			ConnRate:           0,
+			AuthFailMax:        10,
+			AuthFailWindow:     time.Minute,
+			BanDuration:        time.Minute * 10,
+			ProxyHeaderTimeout: time.Second,
+		},
+		HttpConn: struct {
+			On          bool
+			Addr        string
+			MaxFrames   int
+			PollTimeout time.Duration
+		}{
+			On:          false,
+			Addr:        "0.0.0.0:5220",
+			MaxFrames:   512,
+			PollTimeout: time.Second * 25,
+		},
+		Mqtt: struct {
+			On            bool
+			Addr          string
+			DeviceFlag    uint8
+			SessionExpiry time.Duration
+		}{
+			On:            false,
+			Addr:          "tcp://0.0.0.0:1883",
+			DeviceFlag:    uint8(wkproto.APP),
+			SessionExpiry: time.Hour * 2,
+		},
+		WSJson: struct {
+			On         bool
+			DeviceFlag uint8
+		}{
+			On:         true,
+			DeviceFlag: uint8(wkproto.WEB),
+		},
+		WSCompression: struct {
+			On                      bool
+			ServerNoContextTakeover bool
+			ClientNoContextTakeover bool
+			Threshold               int
+			Level                   int
+		}{
+			On:        false,
+			Threshold: 512,
+			Level:     1,
+		},
+		ApiKey: struct {
+			On bool
+		}{
+			On: false,
+		},
 		Webhook: struct {
 			HTTPAddr                    string
 			GRPCAddr                    string
@@ -520,6 +747,30 @@ func New(op ...Option) *Options {
 			ChannelReactorSubCount int
 			SlotReactorSubCount    int
 			PongMaxTick            int
+
+			SlotReplicaBalanceOn          bool
+			SlotReplicaBalanceConcurrency int
+
+			Zone                        string
+			ChannelPlacement            string
+			ChannelBalanceOn            bool
+			ChannelBalanceInterval      time.Duration
+			ChannelBalanceThreshold     float64
+			ChannelBalanceMaxMigrations int
+
+			SlotCompactLogThreshold uint64
+
+			ConfigPreVote     bool
+			ConfigCheckQuorum bool
+
+			Secret string
+			TLS    struct {
+				On         bool
+				CertFile   string
+				KeyFile    string
+				CAFile     string
+				MinVersion string
+			}
 		}{
 			NodeId:                 1001,
 			Addr:                   "tcp://0.0.0.0:11110",
@@ -535,6 +786,25 @@ func New(op ...Option) *Options {
 			ChannelReactorSubCount: 128,
 			SlotReactorSubCount:    16,
 			PongMaxTick:            30,
+
+			SlotReplicaBalanceOn:          false,
+			SlotReplicaBalanceConcurrency: 2,
+
+			ChannelPlacement:            "random",
+			ChannelBalanceOn:            false,
+			ChannelBalanceInterval:      time.Minute,
+			ChannelBalanceThreshold:     0.2,
+			ChannelBalanceMaxMigrations: 10,
+
+			TLS: struct {
+				On         bool
+				CertFile   string
+				KeyFile    string
+				CAFile     string
+				MinVersion string
+			}{
+				MinVersion: "1.2",
+			},
 		},
 		Trace: struct {
 			ServiceName      string
@@ -646,13 +916,25 @@ func New(op ...Option) *Options {
 		},
 
 		Jwt: struct {
-			Secret string
-			Expire time.Duration
-			Issuer string
+			Secret        string
+			Expire        time.Duration
+			RefreshExpire time.Duration
+			Issuer        string
+		}{
+			Expire:        time.Hour * 24 * 30,
+			RefreshExpire: time.Hour * 24 * 30,
+			Secret:        "secret_wukongim",
+			Issuer:        "wukongim",
+		},
+		TokenJwt: struct {
+			On        bool
+			Secret    string
+			PublicKey string
+			Issuer    string
+			MaxAge    time.Duration
 		}{
-			Expire: time.Hour * 24 * 30,
-			Secret: "secret_wukongim",
-			Issuer: "wukongim",
+			On:     false,
+			MaxAge: time.Hour * 24 * 30,
 		},
 		MigrateStartStep: MigrateStepMessage,
 		Tag: struct {
@@ -735,6 +1017,13 @@ func (o *Options) ConfigureWithViper(vp *viper.Viper) {
 	o.WSSConfig.CertFile = o.getString("wssConfig.certFile", o.WSSConfig.CertFile)
 	o.WSSConfig.KeyFile = o.getString("wssConfig.keyFile", o.WSSConfig.KeyFile)
 
+	o.TCPTLS.On = o.getBool("tcpTLS.on", o.TCPTLS.On)
+	o.TCPTLS.CertFile = o.getString("tcpTLS.certFile", o.TCPTLS.CertFile)
+	o.TCPTLS.KeyFile = o.getString("tcpTLS.keyFile", o.TCPTLS.KeyFile)
+	o.TCPTLS.ClientCAFile = o.getString("tcpTLS.clientCAFile", o.TCPTLS.ClientCAFile)
+	o.TCPTLS.MinVersion = o.getString("tcpTLS.minVersion", o.TCPTLS.MinVersion)
+	o.TCPTLS.ReloadInterval = o.getDuration("tcpTLS.reloadInterval", o.TCPTLS.ReloadInterval)
+
 	o.Channel.CacheCount = o.getInt("channel.cacheCount", o.Channel.CacheCount)
 	o.Channel.CreateIfNoExist = o.getBool("channel.createIfNoExist", o.Channel.CreateIfNoExist)
 	o.Channel.SubscriberCompressOfCount = o.getInt("channel.subscriberCompressOfCount", o.Channel.SubscriberCompressOfCount)
@@ -747,6 +1036,14 @@ func (o *Options) ConfigureWithViper(vp *viper.Viper) {
 
 	o.UserMsgQueueMaxSize = o.getInt("userMsgQueueMaxSize", o.UserMsgQueueMaxSize)
 
+	o.RateLimit.On = o.getBool("rateLimit.on", o.RateLimit.On)
+	o.RateLimit.User.Rate = o.getFloat64("rateLimit.user.rate", o.RateLimit.User.Rate)
+	o.RateLimit.User.Burst = o.getInt("rateLimit.user.burst", o.RateLimit.User.Burst)
+	o.RateLimit.UserChannel.Rate = o.getFloat64("rateLimit.userChannel.rate", o.RateLimit.UserChannel.Rate)
+	o.RateLimit.UserChannel.Burst = o.getInt("rateLimit.userChannel.burst", o.RateLimit.UserChannel.Burst)
+	o.RateLimit.Channel.Rate = o.getFloat64("rateLimit.channel.rate", o.RateLimit.Channel.Rate)
+	o.RateLimit.Channel.Burst = o.getInt("rateLimit.channel.burst", o.RateLimit.Channel.Burst)
+
 	o.TokenAuthOn = o.getBool("tokenAuthOn", o.TokenAuthOn)
 
 	if o.Stress { // 开启了压测模式不能开启认证
@@ -782,6 +1079,55 @@ func (o *Options) ConfigureWithViper(vp *viper.Viper) {
 	o.MessageRetry.MaxCount = o.getInt("messageRetry.maxCount", o.MessageRetry.MaxCount)
 	o.MessageRetry.WorkerCount = o.getInt("messageRetry.workerCount", o.MessageRetry.WorkerCount)
 
+	o.Presence.On = o.getBool("presence.on", o.Presence.On)
+	o.Presence.NotifyInterval = o.getDuration("presence.notifyInterval", o.Presence.NotifyInterval)
+	o.Presence.MaxSubscribeCount = o.getInt("presence.maxSubscribeCount", o.Presence.MaxSubscribeCount)
+
+	o.LoginLog.On = o.getBool("loginLog.on", o.LoginLog.On)
+	o.LoginLog.MaxCount = o.getInt("loginLog.maxCount", o.LoginLog.MaxCount)
+
+	o.AuditLog.On = o.getBool("auditLog.on", o.AuditLog.On)
+	o.AuditLog.Retention = o.getDuration("auditLog.retention", o.AuditLog.Retention)
+
+	o.Admission.On = o.getBool("admission.on", o.Admission.On)
+	o.Admission.MaxConnPerIp = o.getInt("admission.maxConnPerIp", o.Admission.MaxConnPerIp)
+	o.Admission.ConnRate = o.getFloat64("admission.connRate", o.Admission.ConnRate)
+	o.Admission.ConnBurst = o.getInt("admission.connBurst", o.Admission.ConnBurst)
+	o.Admission.AuthFailMax = o.getInt("admission.authFailMax", o.Admission.AuthFailMax)
+	o.Admission.AuthFailWindow = o.getDuration("admission.authFailWindow", o.Admission.AuthFailWindow)
+	o.Admission.BanDuration = o.getDuration("admission.banDuration", o.Admission.BanDuration)
+	o.Admission.ProxyHeaderTimeout = o.getDuration("admission.proxyHeaderTimeout", o.Admission.ProxyHeaderTimeout)
+	if allowCidrs := o.getStringSlice("admission.allowCidrs"); len(allowCidrs) > 0 {
+		o.Admission.AllowCidrs = allowCidrs
+	}
+	if denyCidrs := o.getStringSlice("admission.denyCidrs"); len(denyCidrs) > 0 {
+		o.Admission.DenyCidrs = denyCidrs
+	}
+	if trustedProxies := o.getStringSlice("admission.trustedProxies"); len(trustedProxies) > 0 {
+		o.Admission.TrustedProxies = trustedProxies
+	}
+
+	o.HttpConn.On = o.getBool("httpConn.on", o.HttpConn.On)
+	o.HttpConn.Addr = o.getString("httpConn.addr", o.HttpConn.Addr)
+	o.HttpConn.MaxFrames = o.getInt("httpConn.maxFrames", o.HttpConn.MaxFrames)
+	o.HttpConn.PollTimeout = o.getDuration("httpConn.pollTimeout", o.HttpConn.PollTimeout)
+
+	o.Mqtt.On = o.getBool("mqtt.on", o.Mqtt.On)
+	o.Mqtt.Addr = o.getString("mqtt.addr", o.Mqtt.Addr)
+	o.Mqtt.DeviceFlag = uint8(o.getInt("mqtt.deviceFlag", int(o.Mqtt.DeviceFlag)))
+	o.Mqtt.SessionExpiry = o.getDuration("mqtt.sessionExpiry", o.Mqtt.SessionExpiry)
+
+	o.WSJson.On = o.getBool("wsJson.on", o.WSJson.On)
+	o.WSJson.DeviceFlag = uint8(o.getInt("wsJson.deviceFlag", int(o.WSJson.DeviceFlag)))
+
+	o.WSCompression.On = o.getBool("wsCompression.on", o.WSCompression.On)
+	o.WSCompression.ServerNoContextTakeover = o.getBool("wsCompression.serverNoContextTakeover", o.WSCompression.ServerNoContextTakeover)
+	o.WSCompression.ClientNoContextTakeover = o.getBool("wsCompression.clientNoContextTakeover", o.WSCompression.ClientNoContextTakeover)
+	o.WSCompression.Threshold = o.getInt("wsCompression.threshold", o.WSCompression.Threshold)
+	o.WSCompression.Level = o.getInt("wsCompression.level", o.WSCompression.Level)
+
+	o.ApiKey.On = o.getBool("apiKey.on", o.ApiKey.On)
+
 	o.Conversation.On = o.getBool("conversation.on", o.Conversation.On)
 	o.Conversation.CacheExpire = o.getDuration("conversation.cacheExpire", o.Conversation.CacheExpire)
 	o.Conversation.SyncInterval = o.getDuration("conversation.syncInterval", o.Conversation.SyncInterval)
@@ -807,6 +1153,10 @@ func (o *Options) ConfigureWithViper(vp *viper.Viper) {
 	o.ConfigureDataDir() // 数据目录
 	o.configureLog(vp)   // 日志配置
 
+	if o.TCPTLS.On {
+		o.TCPTLSConfig = o.newTCPTLSConfig()
+	}
+
 	externalIp := o.External.IP
 	var err error
 	if strings.TrimSpace(externalIp) == "" && o.External.AutoGetExternalIP { // 开启了自动获取外网ip并且没有配置外网ip
@@ -870,11 +1220,41 @@ func (o *Options) ConfigureWithViper(vp *viper.Viper) {
 	o.Cluster.ChannelReplicaCount = o.getInt("cluster.channelReplicaCount", o.Cluster.ChannelReplicaCount)
 	o.Cluster.ServerAddr = o.getString("cluster.serverAddr", o.Cluster.ServerAddr)
 	o.Cluster.PongMaxTick = o.getInt("cluster.pongMaxTick", o.Cluster.PongMaxTick)
+	o.Cluster.SlotReplicaBalanceOn = o.getBool("cluster.slotReplicaBalanceOn", o.Cluster.SlotReplicaBalanceOn)
+	o.Cluster.SlotReplicaBalanceConcurrency = o.getInt("cluster.slotReplicaBalanceConcurrency", o.Cluster.SlotReplicaBalanceConcurrency)
+	o.Cluster.Zone = o.getString("cluster.zone", o.Cluster.Zone)
+	o.Cluster.ChannelPlacement = o.getString("cluster.channelPlacement", o.Cluster.ChannelPlacement)
+	switch o.Cluster.ChannelPlacement {
+	case "random", "leastLoaded", "zoneSpread":
+	default:
+		wklog.Panic("cluster.channelPlacement must be random, leastLoaded or zoneSpread, but got " + o.Cluster.ChannelPlacement)
+	}
+	o.Cluster.ChannelBalanceOn = o.getBool("cluster.channelBalanceOn", o.Cluster.ChannelBalanceOn)
+	o.Cluster.ChannelBalanceInterval = o.getDuration("cluster.channelBalanceInterval", o.Cluster.ChannelBalanceInterval)
+	o.Cluster.ChannelBalanceThreshold = o.getFloat64("cluster.channelBalanceThreshold", o.Cluster.ChannelBalanceThreshold)
+	o.Cluster.ChannelBalanceMaxMigrations = o.getInt("cluster.channelBalanceMaxMigrations", o.Cluster.ChannelBalanceMaxMigrations)
+	o.Cluster.SlotCompactLogThreshold = o.getUint64("cluster.slotCompactLogThreshold", o.Cluster.SlotCompactLogThreshold)
+	o.Cluster.ConfigPreVote = o.getBool("cluster.configPreVote", o.Cluster.ConfigPreVote)
+	o.Cluster.ConfigCheckQuorum = o.getBool("cluster.configCheckQuorum", o.Cluster.ConfigCheckQuorum)
+	o.Cluster.Secret = o.getString("cluster.secret", o.Cluster.Secret)
+	o.Cluster.TLS.On = o.getBool("cluster.tls.on", o.Cluster.TLS.On)
+	o.Cluster.TLS.CertFile = o.getString("cluster.tls.certFile", o.Cluster.TLS.CertFile)
+	o.Cluster.TLS.KeyFile = o.getString("cluster.tls.keyFile", o.Cluster.TLS.KeyFile)
+	o.Cluster.TLS.CAFile = o.getString("cluster.tls.caFile", o.Cluster.TLS.CAFile)
+	o.Cluster.TLS.MinVersion = o.getString("cluster.tls.minVersion", o.Cluster.TLS.MinVersion)
+	if o.Cluster.TLS.On {
+		if o.Cluster.TLS.CertFile == "" || o.Cluster.TLS.KeyFile == "" || o.Cluster.TLS.CAFile == "" {
+			wklog.Panic("cluster.tls.certFile, cluster.tls.keyFile and cluster.tls.caFile must be set")
+		}
+		if _, err := wkutil.ParseTLSVersion(o.Cluster.TLS.MinVersion); err != nil {
+			wklog.Panic("cluster.tls.minVersion is invalid", zap.Error(err))
+		}
+	}
 
 	o.Cluster.ReqTimeout = o.getDuration("cluster.reqTimeout", o.Cluster.ReqTimeout)
 	o.Cluster.Seed = o.getString("cluster.seed", o.Cluster.Seed)
 	o.Cluster.SlotCount = o.getInt("cluster.slotCount", o.Cluster.SlotCount)
-	nodes := o.getStringSlice("cluster.initNodes") // 格式为： nodeID@addr 例如 1@localhost:11110
+	nodes := o.getStringSlice("cluster.initNodes") // 格式为： nodeID@addr[@zone] 例如 1@localhost:11110 或 1@localhost:11110@az1
 	if len(nodes) > 0 {
 		for _, nodeStr := range nodes {
 			if !strings.Contains(nodeStr, "@") {
@@ -892,10 +1272,23 @@ func (o *Options) ConfigureWithViper(vp *viper.Viper) {
 				addr = fmt.Sprintf("%s:%s", addr, defaultPort)
 			}
 
-			o.Cluster.InitNodes = append(o.Cluster.InitNodes, &Node{
+			node := &Node{
 				Id:         nodeID,
 				ServerAddr: addr,
-			})
+			}
+			if len(nodeStrs) > 2 {
+				node.Zone = nodeStrs[2]
+			}
+			o.Cluster.InitNodes = append(o.Cluster.InitNodes, node)
+		}
+	}
+	// 没有单独配置本节点的区域时，使用初始节点列表里的配置
+	for _, node := range o.Cluster.InitNodes {
+		if node.Id != o.Cluster.NodeId {
+			continue
+		}
+		if o.Cluster.Zone == "" {
+			o.Cluster.Zone = node.Zone
 		}
 	}
 	o.Cluster.TickInterval = o.getDuration("cluster.tickInterval", o.Cluster.TickInterval)
@@ -969,13 +1362,52 @@ func (o *Options) ConfigureWithViper(vp *viper.Viper) {
 }
 
 // 认证配置
+// 解析权限配置 格式为 [资源ID:权限,资源ID:权限]
+func parseAuthPermissions(permissionStr string) auth.PermissionConfigs {
+	permissionStr = strings.TrimSpace(permissionStr)
+	permissionStr = strings.Replace(permissionStr, "[", "", -1)
+	permissionStr = strings.Replace(permissionStr, "]", "", -1)
+	permissionArrays := strings.Split(permissionStr, ",")
+	permissionCfgs := make([]auth.PermissionConfig, 0)
+	for _, permission := range permissionArrays {
+		permission = strings.TrimSpace(permission)
+		if permission == "" {
+			continue
+		}
+		permissionSplits := strings.Split(permission, ":")
+		permissionCfg := auth.PermissionConfig{}
+		if len(permissionSplits) >= 2 {
+			rsc := permissionSplits[0]
+			actions := permissionSplits[1]
+
+			actionConfigs := make([]auth.Action, 0)
+			for _, r := range actions {
+				action := string(r)
+				actionConfigs = append(actionConfigs, auth.Action(action))
+			}
+			permissionCfg.Resource = resource.Id(rsc)
+			permissionCfg.Actions = actionConfigs
+
+			permissionCfgs = append(permissionCfgs, permissionCfg)
+		}
+	}
+	return permissionCfgs
+}
+
 func (o *Options) configureAuth() {
 
 	// =================== jwt ===================
 	o.Jwt.Secret = o.getString("jwt.secret", o.Jwt.Secret)
 	o.Jwt.Expire = o.getDuration("jwt.expire", o.Jwt.Expire)
+	o.Jwt.RefreshExpire = o.getDuration("jwt.refreshExpire", o.Jwt.RefreshExpire)
 	o.Jwt.Issuer = o.getString("jwt.issuer", o.Jwt.Issuer)
 
+	o.TokenJwt.On = o.getBool("tokenJwt.on", o.TokenJwt.On)
+	o.TokenJwt.Secret = o.getString("tokenJwt.secret", o.TokenJwt.Secret)
+	o.TokenJwt.PublicKey = o.getString("tokenJwt.publicKey", o.TokenJwt.PublicKey)
+	o.TokenJwt.Issuer = o.getString("tokenJwt.issuer", o.TokenJwt.Issuer)
+	o.TokenJwt.MaxAge = o.getDuration("tokenJwt.maxAge", o.TokenJwt.MaxAge)
+
 	// 如果没有配置jwt secret，则读取本地文件，如果没有本地文件则生成一个secret 保存到本地文件
 	if strings.TrimSpace(o.Jwt.Secret) == "" {
 		secretFile := filepath.Join(o.RootDir, "jwt.secret")
@@ -999,6 +1431,21 @@ func (o *Options) configureAuth() {
 	o.Auth.On = o.getBool("auth.on", o.Auth.On)
 	o.Auth.SuperToken = o.getString("auth.superToken", o.Auth.SuperToken)
 	o.Auth.Kind = auth.Kind(o.getString("auth.kind", string(o.Auth.Kind)))
+
+	// 自定义角色 格式为 角色名:[资源ID:权限,...]
+	authRoles := o.getStringSlice("auth.roles")
+	if len(authRoles) > 0 {
+		o.Auth.Roles = make(map[string]auth.PermissionConfigs, len(authRoles))
+		for _, authRoleStr := range authRoles {
+			idx := strings.Index(authRoleStr, ":")
+			if idx <= 0 || !strings.Contains(authRoleStr, "[") || !strings.Contains(authRoleStr, "]") {
+				wklog.Panic("auth role format error", zap.String("authRoleStr", authRoleStr))
+			}
+			roleName := strings.TrimSpace(authRoleStr[:idx])
+			o.Auth.Roles[roleName] = parseAuthPermissions(authRoleStr[idx+1:])
+		}
+	}
+
 	authUsers := o.getStringSlice("auth.users")
 
 	usersCfgs := make([]auth.UserConfig, 0)
@@ -1025,35 +1472,7 @@ func (o *Options) configureAuth() {
 				userCfg.Username = username
 				userCfg.Password = password
 
-				permissionStr = strings.Replace(permissionStr, "[", "", -1)
-				permissionStr = strings.Replace(permissionStr, "]", "", -1)
-				permissionArrays := strings.Split(permissionStr, ",")
-				if len(permissionArrays) > 0 {
-					permissionCfgs := make([]auth.PermissionConfig, 0)
-					for _, permission := range permissionArrays {
-						permission = strings.TrimSpace(permission)
-						if permission == "" {
-							continue
-						}
-						permissionSplits := strings.Split(permission, ":")
-						permissionCfg := auth.PermissionConfig{}
-						if len(permissionSplits) >= 2 {
-							rsc := permissionSplits[0]
-							actions := permissionSplits[1]
-
-							actionConfigs := make([]auth.Action, 0)
-							for _, r := range actions {
-								action := string(r)
-								actionConfigs = append(actionConfigs, auth.Action(action))
-							}
-							permissionCfg.Resource = resource.Id(rsc)
-							permissionCfg.Actions = actionConfigs
-
-							permissionCfgs = append(permissionCfgs, permissionCfg)
-						}
-					}
-					userCfg.Permissions = permissionCfgs
-				}
+				userCfg.Permissions = parseAuthPermissions(permissionStr)
 
 			} else {
 				wklog.Panic("auth user format error", zap.String("authUserStr", authUserStr))
@@ -1067,14 +1486,29 @@ func (o *Options) configureAuth() {
 			password := userStrs[1]
 			userCfg.Username = username
 			userCfg.Password = password
-			if userStrs[2] != string(resource.All) {
-				wklog.Panic("auth user permission format error", zap.String("authUserStr", authUserStr))
-			}
-			userCfg.Permissions = []auth.PermissionConfig{
-				{
-					Resource: resource.All,
-					Actions:  []auth.Action{auth.ActionAll},
-				},
+			if strings.HasPrefix(userStrs[2], "role=") { // 角色配置 例如 role=operator 多个角色用逗号隔开
+				for _, role := range strings.Split(strings.TrimPrefix(userStrs[2], "role="), ",") {
+					role = strings.TrimSpace(role)
+					if role == "" {
+						continue
+					}
+					if _, ok := o.Auth.Roles[role]; !ok {
+						if _, ok := auth.DefaultRoles()[role]; !ok {
+							wklog.Panic("auth user role not exist", zap.String("role", role), zap.String("authUserStr", authUserStr))
+						}
+					}
+					userCfg.Roles = append(userCfg.Roles, role)
+				}
+			} else {
+				if userStrs[2] != string(resource.All) {
+					wklog.Panic("auth user permission format error", zap.String("authUserStr", authUserStr))
+				}
+				userCfg.Permissions = []auth.PermissionConfig{
+					{
+						Resource: resource.All,
+						Actions:  []auth.Action{auth.ActionAll},
+					},
+				}
 			}
 		}
 		usersCfgs = append(usersCfgs, userCfg)
@@ -1115,6 +1549,41 @@ func (o *Options) configureAuth() {
 
 }
 
+// tcp长连接的tls配置（证书文件变化后自动加载）
+func (o *Options) newTCPTLSConfig() *tls.Config {
+	if o.TCPTLS.CertFile == "" || o.TCPTLS.KeyFile == "" {
+		wklog.Panic("tcpTLS.certFile and tcpTLS.keyFile must be set")
+	}
+	minVersion, err := wkutil.ParseTLSVersion(o.TCPTLS.MinVersion)
+	if err != nil {
+		wklog.Panic("tcpTLS.minVersion is invalid", zap.Error(err))
+	}
+	reloader, err := wkutil.NewCertReloader(o.TCPTLS.CertFile, o.TCPTLS.KeyFile, o.TCPTLS.ReloadInterval)
+	if err != nil {
+		wklog.Panic("load tcp tls certificate failed", zap.Error(err))
+	}
+	reloader.OnReload = func(err error) {
+		if err != nil {
+			wklog.Warn("reload tcp tls certificate failed, keep using the old one", zap.Error(err), zap.String("certFile", o.TCPTLS.CertFile))
+			return
+		}
+		wklog.Info("tcp tls certificate reloaded", zap.String("certFile", o.TCPTLS.CertFile))
+	}
+	tlsConfig := &tls.Config{
+		GetCertificate: reloader.GetCertificate,
+		MinVersion:     minVersion,
+	}
+	if o.TCPTLS.ClientCAFile != "" {
+		clientCAs, err := wkutil.LoadCertPool(o.TCPTLS.ClientCAFile)
+		if err != nil {
+			wklog.Panic("load tcp tls client ca failed", zap.Error(err))
+		}
+		tlsConfig.ClientCAs = clientCAs
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig
+}
+
 func (o *Options) ConfigureDataDir() {
 
 	// 数据目录
@@ -1133,6 +1602,9 @@ func (o *Options) Check() error {
 	if o.Cluster.NodeId == 0 {
 		return errors.New("cluster.nodeId must be set")
 	}
+	if o.ApiKey.On && strings.TrimSpace(o.ManagerToken) == "" {
+		return errors.New("managerToken must be set when apiKey.on is true")
+	}
 
 	return nil
 }
@@ -1301,6 +1773,16 @@ func (o *Options) WebhookGRPCOn() bool {
 	return strings.TrimSpace(o.Webhook.GRPCAddr) != ""
 }
 
+// InternalRequestHeaders 节点之间调用api时携带的请求头
+func (o *Options) InternalRequestHeaders() map[string]string {
+	if strings.TrimSpace(o.ManagerToken) == "" {
+		return nil
+	}
+	return map[string]string{
+		"token": o.ManagerToken,
+	}
+}
+
 // HasDatasource 是否有配置数据源
 func (o *Options) HasDatasource() bool {
 	return strings.TrimSpace(o.Datasource.Addr) != ""
@@ -1398,6 +1880,7 @@ func (o *Options) GenMessageId() int64 {
 type Node struct {
 	Id         uint64
 	ServerAddr string
+	Zone       string // 节点所在区域
 }
 
 type Option func(opts *Options)
@@ -1830,6 +2313,108 @@ func WithClusterPongMaxTick(pongMaxTick int) Option {
 	}
 }
 
+func WithClusterSlotReplicaBalanceOn(on bool) Option {
+	return func(opts *Options) {
+		opts.Cluster.SlotReplicaBalanceOn = on
+	}
+}
+
+func WithClusterSlotReplicaBalanceConcurrency(concurrency int) Option {
+	return func(opts *Options) {
+		opts.Cluster.SlotReplicaBalanceConcurrency = concurrency
+	}
+}
+
+func WithClusterZone(zone string) Option {
+	return func(opts *Options) {
+		opts.Cluster.Zone = zone
+	}
+}
+
+func WithClusterChannelPlacement(placement string) Option {
+	return func(opts *Options) {
+		opts.Cluster.ChannelPlacement = placement
+	}
+}
+
+func WithClusterChannelBalanceOn(on bool) Option {
+	return func(opts *Options) {
+		opts.Cluster.ChannelBalanceOn = on
+	}
+}
+
+func WithClusterChannelBalanceInterval(interval time.Duration) Option {
+	return func(opts *Options) {
+		opts.Cluster.ChannelBalanceInterval = interval
+	}
+}
+
+func WithClusterChannelBalanceThreshold(threshold float64) Option {
+	return func(opts *Options) {
+		opts.Cluster.ChannelBalanceThreshold = threshold
+	}
+}
+
+func WithClusterChannelBalanceMaxMigrations(maxMigrations int) Option {
+	return func(opts *Options) {
+		opts.Cluster.ChannelBalanceMaxMigrations = maxMigrations
+	}
+}
+
+func WithClusterSlotCompactLogThreshold(threshold uint64) Option {
+	return func(opts *Options) {
+		opts.Cluster.SlotCompactLogThreshold = threshold
+	}
+}
+
+func WithClusterConfigPreVote(preVote bool) Option {
+	return func(opts *Options) {
+		opts.Cluster.ConfigPreVote = preVote
+	}
+}
+
+func WithClusterConfigCheckQuorum(checkQuorum bool) Option {
+	return func(opts *Options) {
+		opts.Cluster.ConfigCheckQuorum = checkQuorum
+	}
+}
+
+func WithClusterSecret(secret string) Option {
+	return func(opts *Options) {
+		opts.Cluster.Secret = secret
+	}
+}
+
+func WithClusterTLSOn(on bool) Option {
+	return func(opts *Options) {
+		opts.Cluster.TLS.On = on
+	}
+}
+
+func WithClusterTLSCertFile(certFile string) Option {
+	return func(opts *Options) {
+		opts.Cluster.TLS.CertFile = certFile
+	}
+}
+
+func WithClusterTLSKeyFile(keyFile string) Option {
+	return func(opts *Options) {
+		opts.Cluster.TLS.KeyFile = keyFile
+	}
+}
+
+func WithClusterTLSCAFile(caFile string) Option {
+	return func(opts *Options) {
+		opts.Cluster.TLS.CAFile = caFile
+	}
+}
+
+func WithClusterTLSMinVersion(minVersion string) Option {
+	return func(opts *Options) {
+		opts.Cluster.TLS.MinVersion = minVersion
+	}
+}
+
 func WithClusterAPIURL(apiUrl string) Option {
 	return func(opts *Options) {
 		opts.Cluster.APIUrl = apiUrl
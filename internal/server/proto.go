@@ -1,12 +1,15 @@
 package server
 
 import (
+	"net"
 	"strings"
 	"time"
 
 	"github.com/WuKongIM/WuKongIM/internal/eventbus"
 	"github.com/WuKongIM/WuKongIM/internal/options"
+	"github.com/WuKongIM/WuKongIM/internal/service"
 	"github.com/WuKongIM/WuKongIM/internal/track"
+	"github.com/WuKongIM/WuKongIM/internal/wsjson"
 	"github.com/WuKongIM/WuKongIM/pkg/fasttime"
 	"github.com/WuKongIM/WuKongIM/pkg/wknet"
 	wkproto "github.com/WuKongIM/WuKongIMGoProto"
@@ -39,18 +42,44 @@ func (s *Server) onData(conn wknet.Conn) error {
 				s.Warn("Failed to parse proxy proto", zap.Error(err))
 			}
 			if remoteAddr != nil {
+				proxyIp := connIp(conn)
 				conn.SetRemoteAddr(remoteAddr)
 				s.Debug("parse proxy proto success", zap.String("remoteAddr", remoteAddr.String()))
+
+				// 受信任代理的连接使用真实ip做准入判断
+				if options.G.Admission.On && service.AdmissionManager.IsTrustedProxy(proxyIp) {
+					if ip := connIp(conn); ip != "" {
+						if err := service.AdmissionManager.Admit(conn.ID(), ip); err != nil {
+							s.Info("connection is rejected", zap.String("ip", ip), zap.String("proxyIp", proxyIp), zap.Error(err))
+							conn.Close()
+							return nil
+						}
+					}
+				}
 			}
 			if size > 0 {
 				_, _ = conn.Discard(size)
 				buff = buff[size:]
 			}
 		}
+		// 没有代理协议头，不用再等待，以代理的ip做准入判断
+		if err := service.AdmissionManager.AdmitNow(conn.ID()); err != nil {
+			s.Info("connection is rejected", zap.String("ip", connIp(conn)), zap.Error(err))
+			conn.Close()
+			return nil
+		}
 	}
 
-	// 解码协议包
-	data, _ := gnetUnpacket(buff)
+	// json协议的websocket连接使用json的编解码，websocket的消息都是完整的，不用拆包
+	proto := s.opts.Proto
+	isJson := wsjson.IsJsonConn(conn)
+	var data []byte
+	if isJson {
+		proto = wsjson.Proto
+		data = buff
+	} else {
+		data, _ = gnetUnpacket(buff) // 解码协议包
+	}
 	if len(data) == 0 {
 		return nil
 	}
@@ -58,7 +87,7 @@ func (s *Server) onData(conn wknet.Conn) error {
 	if !isAuth {
 
 		// 解析连接包
-		packet, _, err := s.opts.Proto.DecodeFrame(data, wkproto.LatestVersion)
+		packet, _, err := proto.DecodeFrame(data, wkproto.LatestVersion)
 		if err != nil {
 			s.Warn("Failed to decode the message,conn will be closed", zap.Error(err))
 			conn.Close()
@@ -96,6 +125,9 @@ func (s *Server) onData(conn wknet.Conn) error {
 			ProtoVersion: connectPacket.Version,
 			Uptime:       fasttime.UnixTimestamp(),
 		}
+		if remoteAddr := conn.RemoteAddr(); remoteAddr != nil {
+			connCtx.ClientIp, _, _ = net.SplitHostPort(remoteAddr.String())
+		}
 		conn.SetContext(connCtx)
 
 		conn.SetMaxIdle(time.Second * 4) // 给4秒的时间去认证
@@ -109,7 +141,7 @@ func (s *Server) onData(conn wknet.Conn) error {
 		offset := 0
 		var events []*eventbus.Event
 		for len(data) > offset {
-			frame, size, err := s.opts.Proto.DecodeFrame(data[offset:], connCtx.ProtoVersion)
+			frame, size, err := proto.DecodeFrame(data[offset:], connCtx.ProtoVersion)
 			if err != nil { //
 				s.Warn("Failed to decode the message", zap.Error(err))
 				conn.Close()
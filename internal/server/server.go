@@ -2,8 +2,10 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"math/rand"
+	"net"
 	"os"
 	"path"
 	"path/filepath"
@@ -18,6 +20,7 @@ import (
 	"github.com/WuKongIM/WuKongIM/internal/eventbus"
 	"github.com/WuKongIM/WuKongIM/internal/ingress"
 	"github.com/WuKongIM/WuKongIM/internal/manager"
+	"github.com/WuKongIM/WuKongIM/internal/mqtt"
 	"github.com/WuKongIM/WuKongIM/internal/options"
 	pusherevent "github.com/WuKongIM/WuKongIM/internal/pusher/event"
 	pusherhandler "github.com/WuKongIM/WuKongIM/internal/pusher/handler"
@@ -25,6 +28,7 @@ import (
 	userevent "github.com/WuKongIM/WuKongIM/internal/user/event"
 	userhandler "github.com/WuKongIM/WuKongIM/internal/user/handler"
 	"github.com/WuKongIM/WuKongIM/internal/webhook"
+	"github.com/WuKongIM/WuKongIM/internal/wsjson"
 	cluster "github.com/WuKongIM/WuKongIM/pkg/cluster/cluster"
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/node/clusterconfig"
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/node/types"
@@ -33,6 +37,7 @@ import (
 	"github.com/WuKongIM/WuKongIM/pkg/wklog"
 	"github.com/WuKongIM/WuKongIM/pkg/wknet"
 	"github.com/WuKongIM/WuKongIM/pkg/wkserver/proto"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
 	"github.com/WuKongIM/WuKongIM/version"
 	"github.com/gin-gonic/gin"
 	"github.com/judwhite/go-svc"
@@ -55,17 +60,20 @@ type Server struct {
 	store         *store.Store  // 存储相关接口
 	engine        *wknet.Engine // 长连接引擎
 	// userReactor    *userReactor    // 用户的reactor，用于处理用户的行为逻辑
-	trace      *trace.Trace // 监控
-	demoServer *DemoServer  // demo server
-	datasource IDatasource  // 数据源
-	apiServer  *api.Server  // api服务
-	ingress    *ingress.Ingress
+	trace          *trace.Trace     // 监控
+	demoServer     *DemoServer      // demo server
+	sseServer      *SSEServer       // http长连接服务（SSE/长轮询）
+	mqttTranslator *mqtt.Translator // mqtt网关的协议转换器
+	datasource     IDatasource      // 数据源
+	apiServer      *api.Server      // api服务
+	ingress        *ingress.Ingress
 
 	commonService *common.Service // 通用服务
 	// 管理者
 	retryManager        *manager.RetryManager        // 消息重试管理
 	conversationManager *manager.ConversationManager // 会话管理
 	tagManager          *manager.TagManager          // tag管理
+	admissionManager    *manager.AdmissionManager    // 连接准入控制
 	webhook             *webhook.Webhook
 
 	// 用户事件池
@@ -132,7 +140,7 @@ func New(opts *options.Options) *Server {
 	s.datasource = NewDatasource(s)
 
 	// 初始化长连接引擎
-	s.engine = wknet.NewEngine(
+	engineOpts := []wknet.Option{
 		wknet.WithAddr(s.opts.Addr),
 		wknet.WithWSAddr(s.opts.WSAddr),
 		wknet.WithWSSAddr(s.opts.WSSAddr),
@@ -143,9 +151,29 @@ func New(opts *options.Options) *Server {
 		wknet.WithOnWirteBytes(func(n int) {
 			trace.GlobalTrace.Metrics.System().ExtranetOutgoingAdd(int64(n))
 		}),
-	)
+	}
+	if s.opts.TCPTLSConfig != nil { // tcp长连接的tls
+		engineOpts = append(engineOpts, wknet.WithTCPTLSConfig(s.opts.TCPTLSConfig))
+	}
+	if s.opts.Mqtt.On { // mqtt网关
+		s.mqttTranslator = mqtt.NewTranslator()
+		engineOpts = append(engineOpts, wknet.WithMqttAddr(s.opts.Mqtt.Addr), wknet.WithMqttTranslator(s.mqttTranslator))
+	}
+	if s.opts.WSJson.On { // websocket的json协议
+		engineOpts = append(engineOpts, wknet.WithWSSSubprotocols(wsjson.Subprotocol))
+	}
+	if s.opts.WSCompression.On { // websocket的permessage-deflate压缩
+		engineOpts = append(engineOpts, wknet.WithWSCompression(&wknet.WSCompressionOptions{
+			ServerNoContextTakeover: s.opts.WSCompression.ServerNoContextTakeover,
+			ClientNoContextTakeover: s.opts.WSCompression.ClientNoContextTakeover,
+			Threshold:               s.opts.WSCompression.Threshold,
+			Level:                   s.opts.WSCompression.Level,
+		}))
+	}
+	s.engine = wknet.NewEngine(engineOpts...)
 
 	s.demoServer = NewDemoServer(s) // demo server
+	s.sseServer = NewSSEServer(s)   // http长连接服务
 
 	s.webhook = webhook.New()
 	service.Webhook = s.webhook
@@ -161,33 +189,58 @@ func New(opts *options.Options) *Server {
 	service.RetryManager = s.retryManager
 	service.TagManager = s.tagManager
 	service.SystemAccountManager = manager.NewSystemAccountManager() // 系统账号管理
+	service.PresenceManager = manager.NewPresenceManager()           // 在线状态订阅管理
+	service.ApiKeyManager = manager.NewApiKeyManager()               // 业务api的api key管理
+	service.TokenRevokeManager = manager.NewTokenRevokeManager()     // 管理后台token的吊销列表
+	s.admissionManager = manager.NewAdmissionManager()               // 连接准入控制
+	service.AdmissionManager = s.admissionManager
 
 	s.commonService = common.NewService()
 	service.CommonService = s.commonService
 
 	// 初始化分布式服务
 	initNodes := make(map[uint64]string)
+	initNodeLabels := make(map[uint64]clusterconfig.NodeLabel)
 	if len(s.opts.Cluster.InitNodes) > 0 {
 		for _, node := range s.opts.Cluster.InitNodes {
 			serverAddr := strings.ReplaceAll(node.ServerAddr, "tcp://", "")
 			initNodes[node.Id] = serverAddr
+			initNodeLabels[node.Id] = clusterconfig.NodeLabel{Zone: node.Zone}
 		}
 	}
 	role := types.NodeRole_NodeRoleReplica
 	if s.opts.Cluster.Role == options.RoleProxy {
 		role = types.NodeRole_NodeRoleProxy
 	}
+	channelPlacement, err := cluster.NewChannelPlacement(s.opts.Cluster.ChannelPlacement)
+	if err != nil {
+		s.Panic("channel placement error", zap.Error(err))
+	}
+	var clusterTLSConfig *tls.Config
+	if s.opts.Cluster.TLS.On { // 节点之间通讯的tls
+		minVersion, _ := wkutil.ParseTLSVersion(s.opts.Cluster.TLS.MinVersion)
+		clusterTLSConfig, err = cluster.NewTLSConfig(s.opts.Cluster.TLS.CertFile, s.opts.Cluster.TLS.KeyFile, s.opts.Cluster.TLS.CAFile, minVersion)
+		if err != nil {
+			s.Panic("cluster tls config error", zap.Error(err))
+		}
+	}
 	clusterServer := cluster.New(
 		cluster.NewOptions(
 			cluster.WithConfigOptions(clusterconfig.NewOptions(
 				clusterconfig.WithNodeId(s.opts.Cluster.NodeId),
 				clusterconfig.WithConfigPath(path.Join(s.opts.DataDir, "cluster", "config", "remote.json")),
 				clusterconfig.WithInitNodes(initNodes),
+				clusterconfig.WithInitNodeLabels(initNodeLabels),
+				clusterconfig.WithZone(s.opts.Cluster.Zone),
 				clusterconfig.WithSlotCount(uint32(s.opts.Cluster.SlotCount)),
 				clusterconfig.WithApiServerAddr(s.opts.Cluster.APIUrl),
 				clusterconfig.WithChannelMaxReplicaCount(uint32(s.opts.Cluster.ChannelReplicaCount)),
 				clusterconfig.WithSlotMaxReplicaCount(uint32(s.opts.Cluster.SlotReplicaCount)),
 				clusterconfig.WithPongMaxTick(s.opts.Cluster.PongMaxTick),
+				clusterconfig.WithSlotReplicaBalanceOn(s.opts.Cluster.SlotReplicaBalanceOn),
+				clusterconfig.WithSlotReplicaBalanceConcurrency(s.opts.Cluster.SlotReplicaBalanceConcurrency),
+				clusterconfig.WithPreVote(s.opts.Cluster.ConfigPreVote),
+				clusterconfig.WithCheckQuorum(s.opts.Cluster.ConfigCheckQuorum),
 			)),
 			cluster.WithAddr(s.opts.Cluster.Addr),
 			cluster.WithDataDir(path.Join(opts.DataDir)),
@@ -200,6 +253,15 @@ func New(opts *options.Options) *Server {
 			cluster.WithDBWKDbMemTableSize(s.opts.Db.MemTableSize),
 			cluster.WithAuth(s.opts.Auth),
 			cluster.WithIsCmdChannel(s.opts.IsCmdChannel),
+			cluster.WithOnNodeLeaving(s.onNodeLeaving),
+			cluster.WithChannelPlacement(channelPlacement),
+			cluster.WithChannelBalanceOn(s.opts.Cluster.ChannelBalanceOn),
+			cluster.WithChannelBalanceInterval(s.opts.Cluster.ChannelBalanceInterval),
+			cluster.WithChannelBalanceThreshold(s.opts.Cluster.ChannelBalanceThreshold),
+			cluster.WithChannelBalanceMaxMigrations(s.opts.Cluster.ChannelBalanceMaxMigrations),
+			cluster.WithSlotCompactLogThreshold(s.opts.Cluster.SlotCompactLogThreshold),
+			cluster.WithSecret(s.opts.Cluster.Secret),
+			cluster.WithTLSConfig(clusterTLSConfig),
 		),
 
 		// cluster.WithOnChannelMetaApply(func(channelID string, channelType uint8, logs []replica.Log) error {
@@ -279,6 +341,11 @@ func (s *Server) Start() error {
 		return err
 	}
 
+	// 连接准入控制
+	if err = s.admissionManager.Start(); err != nil {
+		return err
+	}
+
 	err = s.trace.Start()
 	if err != nil {
 		return err
@@ -303,6 +370,12 @@ func (s *Server) Start() error {
 		s.demoServer.Start()
 	}
 
+	if s.opts.HttpConn.On {
+		if err = s.sseServer.Start(); err != nil {
+			return err
+		}
+	}
+
 	if s.opts.Conversation.On {
 		err = s.conversationManager.Start()
 		if err != nil {
@@ -319,6 +392,10 @@ func (s *Server) Start() error {
 		return err
 	}
 
+	if err = s.userHandler.Start(); err != nil {
+		return err
+	}
+
 	err = s.userEventPool.Start()
 	if err != nil {
 		return err
@@ -350,6 +427,8 @@ func (s *Server) Stop() error {
 
 	s.userEventPool.Stop()
 
+	s.userHandler.Stop()
+
 	s.channelEventPool.Stop()
 
 	s.pushEventPool.Stop()
@@ -370,6 +449,10 @@ func (s *Server) Stop() error {
 		s.demoServer.Stop()
 	}
 
+	if s.opts.HttpConn.On {
+		s.sseServer.Stop()
+	}
+
 	err := s.engine.Stop()
 	if err != nil {
 		s.Error("engine stop error", zap.Error(err))
@@ -378,6 +461,8 @@ func (s *Server) Stop() error {
 
 	s.tagManager.Stop()
 
+	s.admissionManager.Stop()
+
 	s.webhook.Stop()
 
 	s.Info("Server is stopped")
@@ -416,6 +501,27 @@ func (s *Server) onConnect(conn wknet.Conn) error {
 
 	service.ConnManager.AddConn(conn)
 
+	// 节点正在离开集群，不再接受新连接
+	if s.clusterServer.IsLeaving() {
+		conn.Close()
+		return ErrNodeLeaving
+	}
+
+	// 连接准入判断，来自受信任代理的连接等待代理协议头解析出真实ip后再判断，超时则以代理的ip判断
+	if options.G.Admission.On {
+		ip := connIp(conn)
+		if service.AdmissionManager.IsTrustedProxy(ip) {
+			service.AdmissionManager.AdmitAfter(conn.ID(), ip, options.G.Admission.ProxyHeaderTimeout, func(err error) {
+				s.Info("connection is rejected, proxy header timeout", zap.String("proxyIp", ip), zap.Error(err))
+				conn.Close()
+			})
+		} else if err := service.AdmissionManager.Admit(conn.ID(), ip); err != nil {
+			s.Info("connection is rejected", zap.String("ip", ip), zap.Error(err))
+			conn.Close()
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -446,6 +552,21 @@ func (s *Server) onClose(conn wknet.Conn) {
 		}
 	}
 	service.ConnManager.RemoveConn(conn)
+	service.AdmissionManager.Release(conn.ID())
+
+	if s.mqttTranslator != nil { // mqtt连接的遗嘱消息和保留会话
+		s.mqttTranslator.OnClose(conn)
+	}
+}
+
+// 连接的来源ip
+func connIp(conn wknet.Conn) string {
+	remoteAddr := conn.RemoteAddr()
+	if remoteAddr == nil {
+		return ""
+	}
+	ip, _, _ := net.SplitHostPort(remoteAddr.String())
+	return ip
 }
 
 // 是否是用户的领导节点
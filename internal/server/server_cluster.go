@@ -1,10 +1,21 @@
 package server
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
 	"github.com/WuKongIM/WuKongIM/internal/eventbus"
+	"github.com/WuKongIM/WuKongIM/internal/options"
+	"github.com/WuKongIM/WuKongIM/internal/service"
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/node/types"
 	"github.com/WuKongIM/WuKongIM/pkg/wkserver/proto"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+	"go.uber.org/zap"
 )
 
+var ErrNodeLeaving = errors.New("node is leaving the cluster")
+
 // handleClusterMessage 处理分布式消息（注意：不要再此方法里做耗时操作，如果耗时操作另起协程）
 func (s *Server) handleClusterMessage(_ uint64, msg *proto.Message) {
 	if msg.MsgType >= uint32(eventbus.UserEventMsgMin) && msg.MsgType < uint32(eventbus.UserEventMsgMax) {
@@ -15,3 +26,45 @@ func (s *Server) handleClusterMessage(_ uint64, msg *proto.Message) {
 		s.pushHandler.OnMessage(msg)
 	}
 }
+
+// onNodeLeaving 当前节点开始离开集群（注意：不要阻塞此方法）
+func (s *Server) onNodeLeaving() {
+	go s.kickConnsForLeaving()
+}
+
+// 断开本节点上的所有客户端连接，并提示客户端重新获取连接地址
+func (s *Server) kickConnsForLeaving() {
+	reason := s.leavingRedirectHint()
+	conns := eventbus.User.AllConn()
+	count := 0
+	for _, conn := range conns {
+		if conn.NodeId != options.G.Cluster.NodeId {
+			continue
+		}
+		eventbus.User.ConnWrite(conn, &wkproto.DisconnectPacket{
+			ReasonCode: wkproto.ReasonNodeNotMatch,
+			Reason:     reason,
+		})
+		service.CommonService.AfterFunc(time.Second*2, func(od *eventbus.Conn) func() {
+			return func() {
+				eventbus.User.CloseConn(od)
+			}
+		}(conn))
+		count++
+	}
+	s.Info("node leaving, kick conns", zap.Int("count", count), zap.String("reason", reason))
+}
+
+// 重定向提示，告诉客户端从其他节点的/route接口获取新的连接地址
+func (s *Server) leavingRedirectHint() string {
+	for _, node := range service.Cluster.Nodes() {
+		if node.Id == options.G.Cluster.NodeId || !node.Online || node.Status != types.NodeStatus_NodeStatusJoined {
+			continue
+		}
+		if node.ApiServerAddr == "" {
+			continue
+		}
+		return fmt.Sprintf("node leaving, reconnect via %s/route", node.ApiServerAddr)
+	}
+	return "node leaving, reconnect via /route"
+}
@@ -0,0 +1,395 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/internal/options"
+	"github.com/WuKongIM/WuKongIM/internal/service"
+	"github.com/WuKongIM/WuKongIM/pkg/wkhttp"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/WuKongIM/WuKongIM/pkg/wknet"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// SSE的心跳间隔（保持代理不断开，同时保持会话活跃）
+const sseHeartbeatInterval = time.Second * 15
+
+// 上行数据的最大长度
+const sseMaxBodySize = 1024 * 1024
+
+var ErrHttpSessionNotExist = errors.New("session not exist")
+
+// SSEServer http长连接服务（SSE/长轮询下行，POST上行）
+// 每个会话对应一个wknet.HttpConn，上行的悟空IM协议包与tcp/websocket连接走相同的处理流程
+type SSEServer struct {
+	r          *wkhttp.WKHttp
+	addr       string
+	s          *Server
+	httpServer *http.Server
+
+	mu       sync.RWMutex
+	sessions map[string]*httpSession // sessionId -> 会话
+
+	wklog.Log
+}
+
+// http长连接会话
+type httpSession struct {
+	id   string
+	conn *wknet.HttpConn
+
+	mu      sync.Mutex
+	streamC chan struct{} // 当前下行请求的取消信号（同一个会话只保留最新的下行请求）
+}
+
+// 替换会话当前的下行请求，返回新请求的取消信号
+func (h *httpSession) attach() chan struct{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.streamC != nil {
+		close(h.streamC)
+	}
+	h.streamC = make(chan struct{})
+	return h.streamC
+}
+
+func (h *httpSession) detach(streamC chan struct{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.streamC == streamC {
+		close(h.streamC)
+		h.streamC = nil
+	}
+}
+
+// NewSSEServer new一个http长连接服务
+func NewSSEServer(s *Server) *SSEServer {
+	log := wklog.NewWKLog("SSEServer")
+	r := wkhttp.NewWithLogger(wkhttp.LoggerWithWklog(log))
+	r.Use(wkhttp.CORSMiddleware())
+
+	return &SSEServer{
+		r:        r,
+		addr:     s.opts.HttpConn.Addr,
+		s:        s,
+		sessions: make(map[string]*httpSession),
+		Log:      log,
+	}
+}
+
+// Start 开始，监听失败返回错误
+func (s *SSEServer) Start() error {
+	s.setRoutes()
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	s.httpServer = &http.Server{Handler: s.r}
+	go func() {
+		err := s.httpServer.Serve(ln)
+		if err != nil && err != http.ErrServerClosed {
+			s.Error("SSE server serve error", zap.Error(err), zap.String("addr", s.addr))
+		}
+	}()
+	s.Info("SSE server started", zap.String("addr", s.addr))
+	return nil
+}
+
+// Stop 停止服务
+// 先关闭所有会话，会话的下行请求随之结束，再关闭http服务
+func (s *SSEServer) Stop() {
+	s.mu.RLock()
+	sessions := make([]*httpSession, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		sessions = append(sessions, sess)
+	}
+	s.mu.RUnlock()
+
+	for _, sess := range sessions {
+		_ = sess.conn.Close()
+	}
+
+	if s.httpServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		if err := s.httpServer.Shutdown(ctx); err != nil {
+			s.Warn("SSE server shutdown error", zap.Error(err))
+		}
+	}
+}
+
+func (s *SSEServer) setRoutes() {
+	s.r.POST("/open", s.open)    // 创建会话
+	s.r.GET("/sse", s.sse)       // SSE下行
+	s.r.GET("/poll", s.poll)     // 长轮询下行
+	s.r.POST("/send", s.send)    // 上行悟空IM协议包
+	s.r.POST("/close", s.close_) // 关闭会话
+}
+
+// 创建会话（相当于建立连接），创建后需要尽快发送CONNECT包进行认证
+func (s *SSEServer) open(c *wkhttp.Context) {
+	sess, err := s.newSession(c)
+	if err != nil {
+		s.Info("open session failed", zap.Error(err), zap.String("remoteAddr", c.Request.RemoteAddr))
+		responseErrorWithStatus(c, http.StatusForbidden, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"session_id": sess.id,
+	})
+}
+
+// SSE下行
+// 每个事件为一个下行包（base64编码），事件id为下行序号
+// 断线后重连（EventSource会自动带上Last-Event-ID，也可以使用cursor参数）即可从断开的位置恢复
+func (s *SSEServer) sse(c *wkhttp.Context) {
+	sess, cursor, err := s.sessionFromRequest(c)
+	if err != nil {
+		responseErrorWithStatus(c, http.StatusNotFound, err)
+		return
+	}
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		c.ResponseError(errors.New("streaming unsupported"))
+		return
+	}
+	streamC := sess.attach()
+	defer sess.detach(streamC)
+
+	header := c.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no") // 关闭nginx的缓冲
+	c.Writer.WriteHeader(http.StatusOK)
+
+	if _, err = io.WriteString(c.Writer, ": open\n\n"); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		sess.conn.KeepLastActivity()
+		frames, notify := sess.conn.Frames(cursor)
+		for _, frame := range frames {
+			if _, err = fmt.Fprintf(c.Writer, "id: %d\ndata: %s\n\n", frame.Seq, base64.StdEncoding.EncodeToString(frame.Data)); err != nil {
+				return
+			}
+			cursor = frame.Seq
+		}
+		if len(frames) > 0 {
+			flusher.Flush()
+		}
+		select {
+		case <-notify:
+		case <-heartbeat.C:
+			if _, err = io.WriteString(c.Writer, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-sess.conn.Done():
+			_, _ = io.WriteString(c.Writer, "event: close\ndata: {}\n\n")
+			flusher.Flush()
+			return
+		case <-streamC: // 会话有了新的下行请求
+			return
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
+
+// 长轮询下行
+// 返回序号大于cursor的下行包，没有则等待直到有数据或者超时，下次请求带上返回的cursor
+func (s *SSEServer) poll(c *wkhttp.Context) {
+	sess, cursor, err := s.sessionFromRequest(c)
+	if err != nil {
+		responseErrorWithStatus(c, http.StatusNotFound, err)
+		return
+	}
+	streamC := sess.attach()
+	defer sess.detach(streamC)
+
+	sess.conn.KeepLastActivity()
+	frames, notify := sess.conn.Frames(cursor)
+	if len(frames) == 0 {
+		timer := time.NewTimer(options.G.HttpConn.PollTimeout)
+		select {
+		case <-notify:
+			frames, _ = sess.conn.Frames(cursor)
+		case <-timer.C:
+		case <-sess.conn.Done():
+		case <-streamC:
+		case <-c.Request.Context().Done():
+		}
+		timer.Stop()
+		sess.conn.KeepLastActivity()
+	}
+
+	resps := make([]*httpFrameResp, 0, len(frames))
+	for _, frame := range frames {
+		resps = append(resps, &httpFrameResp{
+			Id:   frame.Seq,
+			Data: base64.StdEncoding.EncodeToString(frame.Data),
+		})
+		cursor = frame.Seq
+	}
+	c.JSON(http.StatusOK, &httpPollResp{
+		SessionId: sess.id,
+		Cursor:    cursor,
+		Closed:    sess.conn.IsClosed(),
+		Frames:    resps,
+	})
+}
+
+// 上行悟空IM协议包
+// Content-Type为application/octet-stream时body为二进制数据，否则为base64编码的数据
+func (s *SSEServer) send(c *wkhttp.Context) {
+	sess := s.getSession(c.Query("session_id"))
+	if sess == nil {
+		responseErrorWithStatus(c, http.StatusNotFound, ErrHttpSessionNotExist)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, sseMaxBodySize+1))
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if len(body) > sseMaxBodySize {
+		responseErrorWithStatus(c, http.StatusRequestEntityTooLarge, errors.New("body too large"))
+		return
+	}
+	if !strings.HasPrefix(c.ContentType(), "application/octet-stream") {
+		body, err = base64.StdEncoding.DecodeString(strings.TrimSpace(string(body)))
+		if err != nil {
+			c.ResponseError(errors.New("body is not base64"))
+			return
+		}
+	}
+	if len(body) == 0 {
+		c.ResponseOK()
+		return
+	}
+	if err = sess.conn.Push(body); err != nil {
+		s.Warn("push data failed", zap.Error(err), zap.String("sessionId", sess.id))
+		_ = sess.conn.Close()
+		responseErrorWithStatus(c, http.StatusGone, err)
+		return
+	}
+	c.ResponseOK()
+}
+
+// 关闭会话
+func (s *SSEServer) close_(c *wkhttp.Context) {
+	sess := s.getSession(c.Query("session_id"))
+	if sess != nil {
+		_ = sess.conn.Close()
+	}
+	c.ResponseOK()
+}
+
+// 获取请求的会话和游标
+func (s *SSEServer) sessionFromRequest(c *wkhttp.Context) (*httpSession, uint64, error) {
+	sess := s.getSession(c.Query("session_id"))
+	if sess == nil {
+		return nil, 0, ErrHttpSessionNotExist
+	}
+	cursorStr := c.GetHeader("Last-Event-ID")
+	if cursorStr == "" {
+		cursorStr = c.Query("cursor")
+	}
+	var cursor uint64
+	if cursorStr != "" {
+		var err error
+		if cursor, err = strconv.ParseUint(cursorStr, 10, 64); err != nil {
+			return nil, 0, errors.New("cursor is invalid")
+		}
+	}
+	return sess, cursor, nil
+}
+
+func (s *SSEServer) newSession(c *wkhttp.Context) (*httpSession, error) {
+	localAddr, _ := c.Request.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	conn, err := s.s.engine.NewHttpConn(localAddr, s.remoteAddr(c), options.G.HttpConn.MaxFrames)
+	if err != nil {
+		return nil, err
+	}
+	sess := &httpSession{
+		id:   wkutil.GenUUID(),
+		conn: conn,
+	}
+	s.mu.Lock()
+	s.sessions[sess.id] = sess
+	s.mu.Unlock()
+
+	go func() {
+		<-conn.Done()
+		s.mu.Lock()
+		delete(s.sessions, sess.id)
+		s.mu.Unlock()
+	}()
+	return sess, nil
+}
+
+func (s *SSEServer) getSession(sessionId string) *httpSession {
+	if sessionId == "" {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sessions[sessionId]
+}
+
+// 客户端地址，来自受信任代理的请求使用X-Forwarded-For里的真实ip
+func (s *SSEServer) remoteAddr(c *wkhttp.Context) net.Addr {
+	addr, err := net.ResolveTCPAddr("tcp", c.Request.RemoteAddr)
+	if err != nil {
+		return &net.TCPAddr{}
+	}
+	if !service.AdmissionManager.IsTrustedProxy(addr.IP.String()) {
+		return addr
+	}
+	forwardedFor := c.GetHeader("X-Forwarded-For")
+	if forwardedFor == "" {
+		return addr
+	}
+	ips := strings.Split(forwardedFor, ",")
+	if ip := net.ParseIP(strings.TrimSpace(ips[len(ips)-1])); ip != nil { // 最后一个是直连代理看到的地址
+		return &net.TCPAddr{IP: ip}
+	}
+	return addr
+}
+
+func responseErrorWithStatus(c *wkhttp.Context, status int, err error) {
+	c.JSON(status, gin.H{
+		"msg":    err.Error(),
+		"status": status,
+	})
+}
+
+type httpFrameResp struct {
+	Id   uint64 `json:"id"`   // 下行序号
+	Data string `json:"data"` // base64编码的悟空IM协议包
+}
+
+type httpPollResp struct {
+	SessionId string           `json:"session_id"`
+	Cursor    uint64           `json:"cursor"` // 下次请求带上的游标
+	Closed    bool             `json:"closed"` // 会话是否已关闭
+	Frames    []*httpFrameResp `json:"frames"`
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/WuKongIM/WuKongIM/internal/options"
 	"github.com/WuKongIM/WuKongIM/internal/service"
 	"github.com/WuKongIM/WuKongIM/pkg/client"
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/cluster"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 )
@@ -189,6 +190,11 @@ func GetLeaderServer(ss ...*Server) *Server {
 	return nil
 }
 
+// ClusterServer 分布式服务（测试时用来查询领导、节点和槽状态）
+func (s *Server) ClusterServer() *cluster.Server {
+	return s.clusterServer
+}
+
 // func TestAddSubscriber(t *testing.T, s *Server, channelId string, channelType uint8, subscribers ...string) {
 // 	// 获取u1的最近会话列表
 // 	w := httptest.NewRecorder()
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+)
+
+var AdmissionManager IAdmissionManager
+
+type IAdmissionManager interface {
+	// Admit 判断ip是否允许建立连接，允许则记录连接（同一个连接重复调用会以最新的ip为准）
+	Admit(connId int64, ip string) error
+	// Release 连接关闭时释放连接的记录
+	Release(connId int64)
+	// AdmitAfter 来自受信任代理的连接先等待代理协议头，超过timeout还没有通过Admit以真实ip判断，则以代理的ip做准入判断，拒绝时调用onReject
+	AdmitAfter(connId int64, proxyIp string, timeout time.Duration, onReject func(err error))
+	// AdmitNow 连接不会再有代理协议头（比如第一个包就是连接包），立即以代理的ip做准入判断（没有等待中的判断则直接返回nil）
+	AdmitNow(connId int64) error
+	// IsTrustedProxy ip是否是受信任的代理
+	IsTrustedProxy(ip string) bool
+	// AuthFail 记录ip的认证失败，达到次数后将自动封禁此ip
+	AuthFail(ip string)
+
+	// AddIpRule 添加ip规则（需要在slot 0的领导节点上调用）
+	AddIpRule(cidr string, action wkdb.IpRuleAction, remark string) (wkdb.IpRule, error)
+	// RemoveIpRule 移除ip规则（需要在slot 0的领导节点上调用）
+	RemoveIpRule(id uint64) error
+	// ReloadIpRules 从slot 0的领导节点重新加载ip规则，替换掉缓存
+	ReloadIpRules() error
+
+	// Bans 当前节点自动封禁的ip ip -> 解封时间(秒)
+	Bans() map[string]int64
+	// Unban 解封当前节点自动封禁的ip
+	Unban(ip string)
+}
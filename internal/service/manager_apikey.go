@@ -0,0 +1,19 @@
+package service
+
+import "github.com/WuKongIM/WuKongIM/pkg/wkdb"
+
+var ApiKeyManager IApiKeyManager
+
+type IApiKeyManager interface {
+	// Verify 验证api key是否有指定的权限范围，验证通过返回api key的信息
+	Verify(key string, scope string) (wkdb.ApiKey, error)
+
+	// AddApiKey 创建api key，返回的key明文只在创建时返回一次
+	AddApiKey(name string, scopes []string) (apiKey wkdb.ApiKey, key string, err error)
+
+	// RemoveApiKey 移除api key
+	RemoveApiKey(id uint64) error
+
+	// Reload 从slot 0的领导节点重新加载api key到缓存（api key变化后由slot 0的领导节点通知各个节点）
+	Reload() error
+}
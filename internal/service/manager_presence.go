@@ -0,0 +1,24 @@
+package service
+
+import wkproto "github.com/WuKongIM/WuKongIMGoProto"
+
+var PresenceManager IPresenceManager
+
+type IPresenceManager interface {
+	// Reserve 登记watcher要订阅的targets并检查订阅数量限制（必须在watcher的slot leader节点上调用），返回新登记的targets
+	Reserve(watcher string, targets []string) ([]string, error)
+	// Release 撤销watcher登记的targets
+	Release(watcher string, targets []string)
+	// Subscribe watcher订阅targets的在线状态（targets必须是本节点为slot leader的用户）
+	Subscribe(watcher string, targets []string) error
+	// Unsubscribe watcher取消订阅targets的在线状态
+	Unsubscribe(watcher string, targets []string)
+	// UnsubscribeAll 取消watcher的所有订阅
+	UnsubscribeAll(watcher string)
+	// Watchers 获取订阅了target在线状态的用户
+	Watchers(target string) []string
+	// Online 用户上线
+	Online(uid string, deviceFlag wkproto.DeviceFlag, deviceOnlineCount int, totalOnlineCount int)
+	// Offline 用户下线
+	Offline(uid string, deviceFlag wkproto.DeviceFlag, deviceOnlineCount int, totalOnlineCount int)
+}
@@ -0,0 +1,28 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+)
+
+var TokenRevokeManager ITokenRevokeManager
+
+type ITokenRevokeManager interface {
+	// IsRevoked 是否有id（会话id或token id）已被吊销
+	IsRevoked(ids ...string) (bool, error)
+	// RevokedAt 获取id的吊销时间，没有吊销返回false
+	RevokedAt(id string) (time.Time, bool, error)
+
+	// Revoke 吊销token（不是slot 0的领导节点会请求领导节点吊销）
+	Revoke(token wkdb.RevokedToken) error
+	// AddRevokedToCache 仅仅添加到缓存内
+	AddRevokedToCache(token wkdb.RevokedToken)
+}
+
+// DeviceRevokeId 设备退出（device_quit）时吊销记录的id，在这之前签发的设备jwt连接token无效
+func DeviceRevokeId(uid string, deviceFlag wkproto.DeviceFlag) string {
+	return fmt.Sprintf("device:%s:%d", uid, deviceFlag)
+}
@@ -0,0 +1,121 @@
+package testcluster
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/client"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+)
+
+// Client 连接到测试集群某个节点的客户端，收到的消息和发送回执都会放进队列，方便测试里等待和断言
+type Client struct {
+	*client.Client
+	t        testing.TB
+	uid      string
+	recvC    chan *wkproto.RecvPacket
+	sendackC chan *wkproto.SendackPacket
+
+	sendMu    sync.Mutex
+	clientSeq uint64 // 和客户端内部的clientSeq保持一致（回执里只有clientSeq）
+}
+
+// NewClient 创建客户端并连接到指定节点，测试结束时自动关闭
+func (c *Cluster) NewClient(nodeId uint64, uid string, opt ...client.Option) *Client {
+	c.t.Helper()
+	n := c.Node(nodeId)
+
+	cli := &Client{
+		t:        c.t,
+		uid:      uid,
+		recvC:    make(chan *wkproto.RecvPacket, 1024),
+		sendackC: make(chan *wkproto.SendackPacket, 1024),
+	}
+	opts := append([]client.Option{client.WithUID(uid)}, opt...)
+	cli.Client = client.New(n.TCPAddr, opts...)
+	cli.SetOnRecv(func(recv *wkproto.RecvPacket) error {
+		cli.recvC <- recv
+		return nil
+	})
+	cli.SetOnSendack(func(sendack *wkproto.SendackPacket) {
+		cli.sendackC <- sendack
+	})
+	if err := cli.Connect(); err != nil {
+		c.t.Fatalf("client[%s] connect to node[%d] failed: %v", uid, nodeId, err)
+	}
+	c.t.Cleanup(cli.Close)
+	return cli
+}
+
+// UID 客户端的用户id
+func (c *Client) UID() string {
+	return c.uid
+}
+
+// SendMessage 发送消息，返回消息的clientSeq
+func (c *Client) SendMessage(channel *client.Channel, payload []byte, opt ...client.SendOption) (uint64, error) {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	c.clientSeq++
+	return c.clientSeq, c.Client.SendMessage(channel, payload, opt...)
+}
+
+// MustSend 发送消息并等待发送回执，回执的原因码不是成功时测试失败
+func (c *Client) MustSend(channel *client.Channel, payload []byte, timeout time.Duration) *wkproto.SendackPacket {
+	c.t.Helper()
+	clientSeq, err := c.SendMessage(channel, payload)
+	if err != nil {
+		c.t.Fatalf("client[%s] send message failed: %v", c.uid, err)
+	}
+
+	deadline := time.After(timeout)
+	for {
+		select {
+		case sendack := <-c.sendackC:
+			if sendack.ClientSeq != clientSeq {
+				continue
+			}
+			if sendack.ReasonCode != wkproto.ReasonSuccess {
+				c.t.Fatalf("client[%s] send message failed, reasonCode: %s", c.uid, sendack.ReasonCode)
+			}
+			return sendack
+		case <-deadline:
+			c.t.Fatalf("client[%s] wait sendack timeout after %s", c.uid, timeout)
+			return nil
+		}
+	}
+}
+
+// MustRecv 等待收到一条消息
+func (c *Client) MustRecv(timeout time.Duration) *wkproto.RecvPacket {
+	c.t.Helper()
+	select {
+	case recv := <-c.recvC:
+		return recv
+	case <-time.After(timeout):
+		c.t.Fatalf("client[%s] wait message timeout after %s", c.uid, timeout)
+		return nil
+	}
+}
+
+// MustRecvPayloads 按顺序收到指定内容的消息
+func (c *Client) MustRecvPayloads(timeout time.Duration, payloads ...string) {
+	c.t.Helper()
+	for _, payload := range payloads {
+		recv := c.MustRecv(timeout)
+		if string(recv.Payload) != payload {
+			c.t.Fatalf("client[%s] expect message %q, got %q", c.uid, payload, string(recv.Payload))
+		}
+	}
+}
+
+// MustNotRecv 在指定时间内没有收到消息
+func (c *Client) MustNotRecv(d time.Duration) {
+	c.t.Helper()
+	select {
+	case recv := <-c.recvC:
+		c.t.Fatalf("client[%s] expect no message, got %q", c.uid, string(recv.Payload))
+	case <-time.After(d):
+	}
+}
@@ -0,0 +1,212 @@
+package testcluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/internal/options"
+	"github.com/WuKongIM/WuKongIM/internal/server"
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/cluster"
+	"github.com/WuKongIM/WuKongIM/pkg/wkfailpoint"
+	"github.com/spf13/viper"
+)
+
+// 子进程通过这个环境变量拿到节点配置
+const nodeConfigEnv = "WK_TESTCLUSTER_NODE"
+
+// 服务内部有进程级的全局变量（options.G、service.*、eventbus），一个进程只能运行一个节点，
+// 所以每个节点都是重新执行当前测试程序得到的子进程，测试进程通过控制地址查询状态和开关故障注入点
+type nodeConfig struct {
+	Id                  uint64
+	RootDir             string
+	TCPAddr             string
+	WSAddr              string
+	HTTPAddr            string
+	ClusterAddr         string
+	ControlAddr         string
+	InitNodes           map[uint64]string
+	SlotCount           int
+	SlotReplicaCount    int
+	ChannelReplicaCount int
+	Configs             map[string]interface{}
+	Failpoints          map[string]string // 启动时开启的故障注入点
+}
+
+// nodeStatus 节点状态（子进程控制接口返回）
+type nodeStatus struct {
+	Id         uint64          `json:"id"`
+	LeaderId   uint64          `json:"leader_id"`   // 集群配置的领导
+	SlotsReady bool            `json:"slots_ready"` // 槽是否都已就绪
+	Online     map[string]bool `json:"online"`      // 各节点在线状态
+}
+
+// Main 需要在使用测试集群的包的TestMain里调用，当前进程是节点子进程时运行节点，否则运行测试
+//
+//	func TestMain(m *testing.M) {
+//		testcluster.Main(m)
+//	}
+func Main(m *testing.M) {
+	cfgStr := os.Getenv(nodeConfigEnv)
+	if cfgStr == "" {
+		os.Exit(m.Run())
+	}
+	var cfg nodeConfig
+	if err := json.Unmarshal([]byte(cfgStr), &cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "decode node config failed: %v\n", err)
+		os.Exit(2)
+	}
+	if err := runNode(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "run node[%d] failed: %v\n", cfg.Id, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func runNode(cfg nodeConfig) error {
+	for name, term := range cfg.Failpoints {
+		if err := wkfailpoint.Enable(name, term); err != nil {
+			return err
+		}
+	}
+
+	s := newNodeServer(cfg)
+	if err := s.Start(); err != nil {
+		return err
+	}
+	defer s.StopNoErr()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(getNodeStatus(cfg, s.ClusterServer()))
+	})
+	mux.HandleFunc("/failpoints/", func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/failpoints/")
+		var err error
+		switch r.Method {
+		case http.MethodPut:
+			var term []byte
+			term, err = io.ReadAll(r.Body)
+			if err == nil {
+				err = wkfailpoint.Enable(name, string(term))
+			}
+		case http.MethodDelete:
+			err = wkfailpoint.Disable(name)
+			if err == wkfailpoint.ErrNotExist {
+				err = nil
+			}
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	})
+	controlServer := &http.Server{Addr: cfg.ControlAddr, Handler: mux}
+	go func() {
+		_ = controlServer.ListenAndServe()
+	}()
+	defer controlServer.Close()
+
+	// 测试进程退出时标准输入会被关闭，节点跟着退出，避免残留进程
+	stdinClosed := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(io.Discard, os.Stdin)
+		close(stdinClosed)
+	}()
+	sigC := make(chan os.Signal, 1)
+	signal.Notify(sigC, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case <-sigC:
+	case <-stdinClosed:
+	}
+	return nil
+}
+
+func newNodeServer(cfg nodeConfig) *server.Server {
+	initNodes := make([]*options.Node, 0, len(cfg.InitNodes))
+	for id, addr := range cfg.InitNodes {
+		initNodes = append(initNodes, &options.Node{
+			Id:         id,
+			ServerAddr: addr,
+		})
+	}
+
+	opts := options.New(
+		options.WithRootDir(cfg.RootDir),
+		options.WithLoggerDir(filepath.Join(cfg.RootDir, "logs")),
+		options.WithMode(options.TestMode),
+		options.WithDemoOn(false),
+		options.WithManagerOn(false),
+		options.WithDbShardNum(2),
+		options.WithDbSlotShardNum(2),
+		options.WithAddr(fmt.Sprintf("tcp://%s", cfg.TCPAddr)),
+		options.WithWSAddr(fmt.Sprintf("ws://%s", cfg.WSAddr)),
+		options.WithHTTPAddr(cfg.HTTPAddr),
+		options.WithExternalTCPAddr(cfg.TCPAddr),
+		options.WithExternalWSAddr(fmt.Sprintf("ws://%s", cfg.WSAddr)),
+		options.WithClusterNodeId(cfg.Id),
+		options.WithClusterAddr(fmt.Sprintf("tcp://%s", cfg.ClusterAddr)),
+		options.WithClusterServerAddr(cfg.ClusterAddr),
+		options.WithClusterAPIURL(fmt.Sprintf("http://%s", cfg.HTTPAddr)),
+		options.WithClusterInitNodes(initNodes),
+		options.WithClusterSlotCount(cfg.SlotCount),
+		options.WithClusterSlotReplicaCount(cfg.SlotReplicaCount),
+		options.WithClusterChannelReplicaCount(cfg.ChannelReplicaCount),
+		options.WithClusterPongMaxTick(10),
+		options.WithClusterElectionIntervalTick(10),
+		options.WithClusterHeartbeatIntervalTick(1),
+		options.WithClusterTickInterval(time.Millisecond*50),
+		options.WithClusterChannelReactorSubCount(2),
+		options.WithClusterSlotReactorSubCount(2),
+	)
+
+	vp := viper.New()
+	for key, value := range cfg.Configs {
+		vp.Set(key, value)
+	}
+	opts.ConfigureWithViper(vp)
+	return server.New(opts)
+}
+
+func getNodeStatus(cfg nodeConfig, cs *cluster.Server) nodeStatus {
+	status := nodeStatus{
+		Id:       cfg.Id,
+		LeaderId: cs.LeaderId(),
+		Online:   make(map[string]bool, len(cfg.InitNodes)),
+	}
+	for id := range cfg.InitNodes {
+		status.Online[fmt.Sprintf("%d", id)] = cs.NodeIsOnline(id)
+	}
+	status.SlotsReady = slotsReady(cfg.Id, cs)
+	return status
+}
+
+// 节点上的槽是否都已就绪（没有迁移和领导转移，本地副本的领导和配置一致）
+func slotsReady(nodeId uint64, cs *cluster.Server) bool {
+	cfgServer := cs.GetConfigServer()
+	slots := cfgServer.Slots()
+	if len(slots) == 0 || len(slots) != int(cfgServer.SlotCount()) {
+		return false
+	}
+	for _, st := range slots {
+		if st.Leader == 0 || st.MigrateTo != 0 || (st.ExpectLeader != 0 && st.ExpectLeader != st.Leader) {
+			return false
+		}
+		for _, replicaId := range st.Replicas {
+			if replicaId == nodeId && cs.SlotLeaderId(st.Id) != st.Leader {
+				return false
+			}
+		}
+	}
+	return true
+}
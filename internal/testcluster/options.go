@@ -0,0 +1,100 @@
+package testcluster
+
+import (
+	"time"
+)
+
+type Options struct {
+	NodeCount           int                               // 节点数量
+	StartNodeId         uint64                            // 第一个节点的id，后续节点依次加1
+	SlotCount           int                               // 槽数量
+	SlotReplicaCount    int                               // 槽副本数量，默认等于节点数量
+	ChannelReplicaCount int                               // 频道副本数量，默认等于节点数量
+	ReadyTimeout        time.Duration                     // 等待集群就绪的超时时间
+	ReadyStable         time.Duration                     // 槽就绪状态需要持续的时长
+	Configs             map[string]interface{}            // 所有节点的配置（和配置文件的key一致，例如 cluster.pongMaxTick）
+	NodeConfigs         map[uint64]map[string]interface{} // 指定节点的配置，覆盖Configs
+}
+
+func NewOptions(opt ...Option) *Options {
+	opts := &Options{
+		NodeCount:    3,
+		StartNodeId:  1001,
+		SlotCount:    5,
+		ReadyTimeout: time.Second * 30,
+		ReadyStable:  time.Second,
+		Configs:      make(map[string]interface{}),
+		NodeConfigs:  make(map[uint64]map[string]interface{}),
+	}
+	for _, o := range opt {
+		o(opts)
+	}
+	if opts.SlotReplicaCount == 0 {
+		opts.SlotReplicaCount = opts.NodeCount
+	}
+	if opts.ChannelReplicaCount == 0 {
+		opts.ChannelReplicaCount = opts.NodeCount
+	}
+	return opts
+}
+
+type Option func(opts *Options)
+
+func WithNodeCount(nodeCount int) Option {
+	return func(opts *Options) {
+		opts.NodeCount = nodeCount
+	}
+}
+
+func WithStartNodeId(startNodeId uint64) Option {
+	return func(opts *Options) {
+		opts.StartNodeId = startNodeId
+	}
+}
+
+func WithSlotCount(slotCount int) Option {
+	return func(opts *Options) {
+		opts.SlotCount = slotCount
+	}
+}
+
+func WithSlotReplicaCount(slotReplicaCount int) Option {
+	return func(opts *Options) {
+		opts.SlotReplicaCount = slotReplicaCount
+	}
+}
+
+func WithChannelReplicaCount(channelReplicaCount int) Option {
+	return func(opts *Options) {
+		opts.ChannelReplicaCount = channelReplicaCount
+	}
+}
+
+func WithReadyTimeout(readyTimeout time.Duration) Option {
+	return func(opts *Options) {
+		opts.ReadyTimeout = readyTimeout
+	}
+}
+
+func WithReadyStable(readyStable time.Duration) Option {
+	return func(opts *Options) {
+		opts.ReadyStable = readyStable
+	}
+}
+
+// WithConfig 所有节点的配置
+func WithConfig(key string, value interface{}) Option {
+	return func(opts *Options) {
+		opts.Configs[key] = value
+	}
+}
+
+// WithNodeConfig 指定节点的配置
+func WithNodeConfig(nodeId uint64, key string, value interface{}) Option {
+	return func(opts *Options) {
+		if opts.NodeConfigs[nodeId] == nil {
+			opts.NodeConfigs[nodeId] = make(map[string]interface{})
+		}
+		opts.NodeConfigs[nodeId][key] = value
+	}
+}
@@ -0,0 +1,478 @@
+package testcluster
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/cluster"
+)
+
+const logTailLines = 30 // 测试失败时输出每个节点的日志行数
+
+// Cluster 多节点测试集群，每个节点使用随机端口和临时目录
+// 使用的包需要在TestMain里调用 Main（见 Main 的说明）
+type Cluster struct {
+	t          testing.TB
+	opts       *Options
+	nodes      []*Node
+	failpoints map[string]string // 开启中的故障注入点，重启的节点也会开启
+	mu         sync.Mutex
+	httpClient *http.Client
+}
+
+// Node 集群中的一个节点
+type Node struct {
+	Id          uint64
+	RootDir     string // 数据目录，重启时复用
+	LogFile     string // 节点的标准输出和错误输出
+	TCPAddr     string // 客户端tcp地址 host:port
+	WSAddr      string // 客户端websocket地址 host:port
+	HTTPAddr    string // api地址 host:port
+	ClusterAddr string // 节点通讯地址 host:port
+	controlAddr string // 子进程控制地址
+	cmd         *exec.Cmd
+	stdin       io.WriteCloser
+	exited      chan struct{}
+}
+
+// Running 节点是否在运行
+func (n *Node) Running() bool {
+	if n.cmd == nil {
+		return false
+	}
+	select {
+	case <-n.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// New 创建测试集群（未启动）
+func New(t testing.TB, opt ...Option) *Cluster {
+	opts := NewOptions(opt...)
+	c := &Cluster{
+		t:          t,
+		opts:       opts,
+		failpoints: make(map[string]string),
+		httpClient: &http.Client{Timeout: time.Second * 2},
+	}
+
+	rootDir := t.TempDir()
+	for i := 0; i < opts.NodeCount; i++ {
+		nodeId := opts.StartNodeId + uint64(i)
+		dir := filepath.Join(rootDir, fmt.Sprintf("%d", nodeId))
+		c.nodes = append(c.nodes, &Node{
+			Id:          nodeId,
+			RootDir:     dir,
+			LogFile:     dir + ".log",
+			TCPAddr:     freeAddr(t),
+			WSAddr:      freeAddr(t),
+			HTTPAddr:    freeAddr(t),
+			ClusterAddr: freeAddr(t),
+			controlAddr: freeAddr(t),
+		})
+	}
+	return c
+}
+
+// Start 启动所有节点，测试结束时自动停止
+func (c *Cluster) Start() {
+	c.t.Helper()
+	c.t.Cleanup(c.Stop)
+	for _, n := range c.nodes {
+		c.startNode(n)
+	}
+}
+
+// Stop 停止所有运行中的节点，测试失败时输出各节点最后的日志
+func (c *Cluster) Stop() {
+	for _, n := range c.nodes {
+		c.stopNode(n, false)
+	}
+	if c.t.Failed() {
+		for _, n := range c.nodes {
+			c.t.Logf("node[%d] log tail (%s):\n%s", n.Id, n.LogFile, logTail(n.LogFile, logTailLines))
+		}
+	}
+}
+
+// Nodes 所有节点
+func (c *Cluster) Nodes() []*Node {
+	return c.nodes
+}
+
+// Node 获取节点，不存在则测试失败
+func (c *Cluster) Node(nodeId uint64) *Node {
+	c.t.Helper()
+	for _, n := range c.nodes {
+		if n.Id == nodeId {
+			return n
+		}
+	}
+	c.t.Fatalf("node[%d] not found", nodeId)
+	return nil
+}
+
+// RunningNodes 运行中的节点
+func (c *Cluster) RunningNodes() []*Node {
+	nodes := make([]*Node, 0, len(c.nodes))
+	for _, n := range c.nodes {
+		if n.Running() {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+// Leader 获取集群配置的领导节点，没有则返回nil
+func (c *Cluster) Leader() *Node {
+	for _, n := range c.RunningNodes() {
+		status, err := c.status(n)
+		if err == nil && status.LeaderId == n.Id {
+			return n
+		}
+	}
+	return nil
+}
+
+// Kill 强杀节点（模拟宕机，保留数据目录）
+func (c *Cluster) Kill(nodeId uint64) {
+	c.t.Helper()
+	c.stopNode(c.Node(nodeId), true)
+}
+
+// Restart 优雅停止节点后使用原来的数据目录和端口重新启动
+func (c *Cluster) Restart(nodeId uint64) {
+	c.t.Helper()
+	n := c.Node(nodeId)
+	c.stopNode(n, false)
+	c.startNode(n)
+}
+
+// StartNode 启动已停止（或被强杀）的节点
+func (c *Cluster) StartNode(nodeId uint64) {
+	c.t.Helper()
+	n := c.Node(nodeId)
+	if n.Running() {
+		return
+	}
+	c.startNode(n)
+}
+
+// MustWaitClusterReady 等待所有运行中的节点认同同一个集群领导
+func (c *Cluster) MustWaitClusterReady() {
+	c.t.Helper()
+	c.mustWait("cluster ready", func() bool {
+		_, ok := c.clusterLeaderId()
+		return ok
+	})
+}
+
+// MustWaitAllSlotsReady 等待运行中的节点互相在线，所有槽都分配完成并且副本都选出了领导
+// 节点刚上线时槽领导可能还会切换，所以要求就绪状态持续 ReadyStable 时长
+func (c *Cluster) MustWaitAllSlotsReady() {
+	c.t.Helper()
+	c.mustWaitStable("all slots ready", c.opts.ReadyStable, func() bool {
+		if _, ok := c.clusterLeaderId(); !ok {
+			return false
+		}
+		nodes := c.RunningNodes()
+		for _, n := range nodes {
+			status, err := c.status(n)
+			if err != nil || !status.SlotsReady {
+				return false
+			}
+			// 节点上线后会触发槽领导的均衡迁移，需要等所有节点都在线后再判断槽
+			for _, other := range nodes {
+				if !status.Online[fmt.Sprintf("%d", other.Id)] {
+					return false
+				}
+			}
+		}
+		return true
+	})
+}
+
+// MustWaitNodeOffline 等待运行中的节点都认为某个节点已离线
+func (c *Cluster) MustWaitNodeOffline(nodeId uint64) {
+	c.t.Helper()
+	c.mustWaitNodeOnline(nodeId, false)
+}
+
+// MustWaitNodeOnline 等待运行中的节点都认为某个节点已在线
+func (c *Cluster) MustWaitNodeOnline(nodeId uint64) {
+	c.t.Helper()
+	c.mustWaitNodeOnline(nodeId, true)
+}
+
+// EnableFailpoint 在所有运行中的节点上开启故障注入点，之后启动的节点也会开启
+func (c *Cluster) EnableFailpoint(name string, term string) {
+	c.t.Helper()
+	c.mu.Lock()
+	c.failpoints[name] = term
+	c.mu.Unlock()
+	for _, n := range c.RunningNodes() {
+		if err := c.controlRequest(n, http.MethodPut, "/failpoints/"+name, []byte(term)); err != nil {
+			c.t.Fatalf("node[%d] enable failpoint %s(%s) failed: %v", n.Id, name, term, err)
+		}
+	}
+}
+
+// DisableFailpoint 在所有运行中的节点上关闭故障注入点
+func (c *Cluster) DisableFailpoint(name string) {
+	c.t.Helper()
+	c.mu.Lock()
+	delete(c.failpoints, name)
+	c.mu.Unlock()
+	for _, n := range c.RunningNodes() {
+		if err := c.controlRequest(n, http.MethodDelete, "/failpoints/"+name, nil); err != nil {
+			c.t.Fatalf("node[%d] disable failpoint %s failed: %v", n.Id, name, err)
+		}
+	}
+}
+
+// Isolate 丢弃其他节点发往某个节点的消息
+func (c *Cluster) Isolate(nodeId uint64) {
+	c.t.Helper()
+	c.EnableFailpoint(cluster.FailpointNodeSendDrop, fmt.Sprintf("return(%d)", nodeId))
+}
+
+// Heal 恢复节点间的通讯
+func (c *Cluster) Heal() {
+	c.t.Helper()
+	c.DisableFailpoint(cluster.FailpointNodeSendDrop)
+}
+
+func (c *Cluster) startNode(n *Node) {
+	c.t.Helper()
+
+	cfgData, err := json.Marshal(c.nodeConfig(n))
+	if err != nil {
+		c.t.Fatalf("encode node[%d] config failed: %v", n.Id, err)
+	}
+
+	logFile, err := os.OpenFile(n.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		c.t.Fatalf("open node[%d] log file failed: %v", n.Id, err)
+	}
+	defer logFile.Close()
+
+	// 重新执行当前测试程序，由 Main 识别环境变量后运行节点
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	cmd.Env = append(os.Environ(), fmt.Sprintf("%s=%s", nodeConfigEnv, cfgData))
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		c.t.Fatalf("node[%d] stdin pipe failed: %v", n.Id, err)
+	}
+	if err = cmd.Start(); err != nil {
+		c.t.Fatalf("start node[%d] failed: %v", n.Id, err)
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		_ = cmd.Wait()
+		close(exited)
+	}()
+	n.cmd = cmd
+	n.stdin = stdin
+	n.exited = exited
+
+	c.mustWait(fmt.Sprintf("node[%d] started", n.Id), func() bool {
+		if !n.Running() {
+			c.t.Fatalf("node[%d] exited, see %s", n.Id, n.LogFile)
+		}
+		_, err := c.status(n)
+		return err == nil
+	})
+}
+
+// 停止节点，kill为true时直接杀掉进程
+func (c *Cluster) stopNode(n *Node, kill bool) {
+	if !n.Running() {
+		return
+	}
+	if kill {
+		_ = n.cmd.Process.Kill()
+	} else {
+		_ = n.cmd.Process.Signal(os.Interrupt)
+	}
+	select {
+	case <-n.exited:
+	case <-time.After(time.Second * 10):
+		_ = n.cmd.Process.Kill()
+		<-n.exited
+	}
+	_ = n.stdin.Close()
+}
+
+func (c *Cluster) nodeConfig(n *Node) nodeConfig {
+	initNodes := make(map[uint64]string, len(c.nodes))
+	for _, node := range c.nodes {
+		initNodes[node.Id] = node.ClusterAddr
+	}
+	configs := make(map[string]interface{}, len(c.opts.Configs))
+	for k, v := range c.opts.Configs {
+		configs[k] = v
+	}
+	for k, v := range c.opts.NodeConfigs[n.Id] {
+		configs[k] = v
+	}
+	c.mu.Lock()
+	failpoints := make(map[string]string, len(c.failpoints))
+	for k, v := range c.failpoints {
+		failpoints[k] = v
+	}
+	c.mu.Unlock()
+	return nodeConfig{
+		Id:                  n.Id,
+		RootDir:             n.RootDir,
+		TCPAddr:             n.TCPAddr,
+		WSAddr:              n.WSAddr,
+		HTTPAddr:            n.HTTPAddr,
+		ClusterAddr:         n.ClusterAddr,
+		ControlAddr:         n.controlAddr,
+		InitNodes:           initNodes,
+		SlotCount:           c.opts.SlotCount,
+		SlotReplicaCount:    c.opts.SlotReplicaCount,
+		ChannelReplicaCount: c.opts.ChannelReplicaCount,
+		Configs:             configs,
+		Failpoints:          failpoints,
+	}
+}
+
+// 运行中的节点认同的集群领导
+func (c *Cluster) clusterLeaderId() (uint64, bool) {
+	var leaderId uint64
+	running := false
+	for _, n := range c.RunningNodes() {
+		status, err := c.status(n)
+		if err != nil || status.LeaderId == 0 {
+			return 0, false
+		}
+		if leaderId != 0 && status.LeaderId != leaderId {
+			return 0, false
+		}
+		leaderId = status.LeaderId
+	}
+	for _, n := range c.RunningNodes() {
+		if n.Id == leaderId {
+			running = true
+		}
+	}
+	return leaderId, running
+}
+
+func (c *Cluster) mustWaitNodeOnline(nodeId uint64, online bool) {
+	c.t.Helper()
+	c.mustWait(fmt.Sprintf("node[%d] online=%v", nodeId, online), func() bool {
+		for _, n := range c.RunningNodes() {
+			if n.Id == nodeId {
+				continue
+			}
+			status, err := c.status(n)
+			if err != nil || status.Online[fmt.Sprintf("%d", nodeId)] != online {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func (c *Cluster) mustWait(desc string, ready func() bool) {
+	c.t.Helper()
+	c.mustWaitStable(desc, 0, ready)
+}
+
+// 等待ready持续满足stable时长
+func (c *Cluster) mustWaitStable(desc string, stable time.Duration, ready func() bool) {
+	c.t.Helper()
+	tk := time.NewTicker(time.Millisecond * 50)
+	defer tk.Stop()
+	timeout := time.After(c.opts.ReadyTimeout)
+	var readyAt time.Time
+	for {
+		if ready() {
+			if readyAt.IsZero() {
+				readyAt = time.Now()
+			}
+			if time.Since(readyAt) >= stable {
+				return
+			}
+		} else {
+			readyAt = time.Time{}
+		}
+		select {
+		case <-tk.C:
+		case <-timeout:
+			c.t.Fatalf("wait %s timeout after %s", desc, c.opts.ReadyTimeout)
+			return
+		}
+	}
+}
+
+// 查询节点状态
+func (c *Cluster) status(n *Node) (nodeStatus, error) {
+	var status nodeStatus
+	resp, err := c.httpClient.Get(fmt.Sprintf("http://%s/status", n.controlAddr))
+	if err != nil {
+		return status, err
+	}
+	defer resp.Body.Close()
+	err = json.NewDecoder(resp.Body).Decode(&status)
+	return status, err
+}
+
+func (c *Cluster) controlRequest(n *Node, method string, path string, body []byte) error {
+	req, err := http.NewRequest(method, fmt.Sprintf("http://%s%s", n.controlAddr, path), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// 读取文件最后几行
+func logTail(file string, lines int) string {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err.Error()
+	}
+	all := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(all) > lines {
+		all = all[len(all)-lines:]
+	}
+	return strings.Join(all, "\n")
+}
+
+// 获取一个空闲的本地端口
+func freeAddr(t testing.TB) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("get free port failed: %v", err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
@@ -0,0 +1,172 @@
+package testcluster
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/client"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+)
+
+func TestMain(m *testing.M) {
+	Main(m)
+}
+
+func TestClusterSendMessage(t *testing.T) {
+	c := New(t)
+	c.Start()
+	c.MustWaitAllSlotsReady()
+
+	nodes := c.Nodes()
+	cli1 := c.NewClient(nodes[0].Id, "u1")
+	cli2 := c.NewClient(nodes[1].Id, "u2")
+
+	cli1.MustSend(client.NewChannel("u2", wkproto.ChannelTypePerson), []byte("hello"), time.Second*10)
+	cli2.MustRecvPayloads(time.Second*10, "hello")
+}
+
+func TestClusterKillAndRestart(t *testing.T) {
+	c := New(t)
+	c.Start()
+	c.MustWaitAllSlotsReady()
+
+	nodes := c.Nodes()
+	killed := nodes[2]
+
+	c.Kill(killed.Id)
+	c.MustWaitNodeOffline(killed.Id)
+	c.MustWaitAllSlotsReady()
+
+	cli1 := c.NewClient(nodes[0].Id, "u1")
+	cli2 := c.NewClient(nodes[1].Id, "u2")
+	cli1.MustSend(client.NewChannel("u2", wkproto.ChannelTypePerson), []byte("hello"), time.Second*10)
+	cli2.MustRecvPayloads(time.Second*10, "hello")
+
+	c.StartNode(killed.Id)
+	c.MustWaitNodeOnline(killed.Id)
+	c.MustWaitAllSlotsReady()
+
+	cli3 := c.NewClient(killed.Id, "u3")
+	cli1.MustSend(client.NewChannel("u3", wkproto.ChannelTypePerson), []byte("hello2"), time.Second*10)
+	cli3.MustRecvPayloads(time.Second*10, "hello2")
+}
+
+func TestClusterIsolate(t *testing.T) {
+	c := New(t)
+	c.Start()
+	c.MustWaitAllSlotsReady()
+
+	isolated := c.Nodes()[2]
+	if leader := c.Leader(); leader != nil && leader.Id == isolated.Id {
+		isolated = c.Nodes()[0]
+	}
+
+	c.Isolate(isolated.Id)
+	c.MustWaitNodeOffline(isolated.Id)
+
+	c.Heal()
+	c.MustWaitNodeOnline(isolated.Id)
+	c.MustWaitAllSlotsReady()
+}
+
+func TestClusterTLS(t *testing.T) {
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	caCert, caKey := writeTestCA(t, caFile)
+
+	opts := []Option{
+		WithConfig("cluster.secret", "test-secret"),
+		WithConfig("cluster.tls.on", true),
+		WithConfig("cluster.tls.caFile", caFile),
+	}
+	for i := 0; i < 3; i++ {
+		nodeId := uint64(1001 + i)
+		certFile := filepath.Join(dir, fmt.Sprintf("%d.pem", nodeId))
+		keyFile := filepath.Join(dir, fmt.Sprintf("%d-key.pem", nodeId))
+		writeTestNodeCert(t, caCert, caKey, nodeId, certFile, keyFile)
+		opts = append(opts,
+			WithNodeConfig(nodeId, "cluster.tls.certFile", certFile),
+			WithNodeConfig(nodeId, "cluster.tls.keyFile", keyFile),
+		)
+	}
+
+	c := New(t, opts...)
+	c.Start()
+	c.MustWaitAllSlotsReady()
+
+	nodes := c.Nodes()
+	cli1 := c.NewClient(nodes[0].Id, "u1")
+	cli2 := c.NewClient(nodes[1].Id, "u2")
+
+	cli1.MustSend(client.NewChannel("u2", wkproto.ChannelTypePerson), []byte("hello"), time.Second*10)
+	cli2.MustRecvPayloads(time.Second*10, "hello")
+}
+
+func writeTestCA(t *testing.T, caFile string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test cluster ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, caFile, "CERTIFICATE", der)
+	return cert, key
+}
+
+// 节点证书的CommonName是节点id
+func writeTestNodeCert(t *testing.T, caCert *x509.Certificate, caKey *ecdsa.PrivateKey, nodeId uint64, certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(int64(nodeId)),
+		Subject:      pkix.Name{CommonName: fmt.Sprintf("%d", nodeId)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDer)
+}
+
+func writePEM(t *testing.T, file, typ string, der []byte) {
+	data := pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der})
+	if err := os.WriteFile(file, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
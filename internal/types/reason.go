@@ -0,0 +1,11 @@
+package types
+
+import wkproto "github.com/WuKongIM/WuKongIMGoProto"
+
+// 服务端扩展的原因码（从100开始，避免与协议库的原因码冲突）
+const (
+	// ReasonChannelRateLimit 频道的发送速率超过限制（频道内所有成员共享）
+	ReasonChannelRateLimit wkproto.ReasonCode = 100
+	// ReasonSlowMode 频道开启了慢速模式，发送者在此频道的发送速率超过限制
+	ReasonSlowMode wkproto.ReasonCode = 101
+)
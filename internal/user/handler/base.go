@@ -4,6 +4,7 @@ import (
 	"github.com/WuKongIM/WuKongIM/internal/eventbus"
 	"github.com/WuKongIM/WuKongIM/internal/options"
 	"github.com/WuKongIM/WuKongIM/internal/service"
+	"github.com/WuKongIM/WuKongIM/pkg/ratelimit"
 	"github.com/WuKongIM/WuKongIM/pkg/wklog"
 	"github.com/WuKongIM/WuKongIM/pkg/wkserver/proto"
 	wkproto "github.com/WuKongIM/WuKongIMGoProto"
@@ -12,16 +13,30 @@ import (
 
 type Handler struct {
 	wklog.Log
+	tokenJwt     tokenJwt              // jwt连接token的验签配置
+	sendLimiter  *ratelimit.KeyLimiter // 用户发送速率限制
+	onlineStatus *onlineStatusBatcher  // 批量提交用户最后上线/离线时间和登录日志
 }
 
 func NewHandler() *Handler {
 	h := &Handler{
-		Log: wklog.NewWKLog("handler"),
+		Log:          wklog.NewWKLog("handler"),
+		sendLimiter:  ratelimit.NewKeyLimiter(),
+		onlineStatus: newOnlineStatusBatcher(),
 	}
 	h.routes()
 	return h
 }
 
+func (h *Handler) Start() error {
+	h.onlineStatus.start()
+	return nil
+}
+
+func (h *Handler) Stop() {
+	h.onlineStatus.stop()
+}
+
 func (h *Handler) routes() {
 	// 连接事件
 	eventbus.RegisterUserHandlers(eventbus.EventConnect, h.connect)
@@ -45,6 +60,8 @@ func (h *Handler) OnMessage(m *proto.Message) {
 	switch msgType(m.MsgType) {
 	case msgForwardUserEvent:
 		h.onForwardUserEvent(m)
+	case msgPresenceUnsubscribeAll:
+		h.onPresenceUnsubscribeAll(m)
 	}
 }
 
@@ -68,6 +68,12 @@ func (h *Handler) notifyUserOfflineIfNeed(conn *eventbus.Conn) {
 		deviceOnlineCount := eventbus.User.ConnCountByDeviceFlag(conn.Uid, conn.DeviceFlag)
 		totalOnlineCount := eventbus.User.ConnCountByUid(conn.Uid)
 		service.Webhook.Offline(conn.Uid, wkproto.DeviceFlag(conn.DeviceFlag), conn.ConnId, deviceOnlineCount, totalOnlineCount) // 触发离线webhook
+		service.PresenceManager.Offline(conn.Uid, wkproto.DeviceFlag(conn.DeviceFlag), deviceOnlineCount, totalOnlineCount)      // 通知在线状态订阅者
+		h.updateOnlineStatus(conn.Uid, wkproto.DeviceFlag(conn.DeviceFlag), false)                                               // 记录最后离线时间
+		if totalOnlineCount <= 0 {
+			// 用户所有设备都离线了，清除此用户的在线状态订阅
+			h.presenceUnsubscribeAll(conn.Uid)
+		}
 	}
 
 }
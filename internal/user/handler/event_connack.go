@@ -34,6 +34,9 @@ func (h *Handler) connack(ctx *eventbus.UserContext) {
 			connack.NodeId = options.G.Cluster.NodeId
 			// 更新连接
 			eventbus.User.UpdateConn(conn)
+		} else if connack.ReasonCode == wkproto.ReasonAuthFail && options.G.IsLocalNode(conn.NodeId) {
+			// 记录认证失败，多次失败后将自动封禁ip
+			service.AdmissionManager.AuthFail(conn.ClientIp)
 		}
 		eventbus.User.ConnWrite(conn, connack)
 	}
@@ -70,16 +70,12 @@ func (h *Handler) handleConnect(event *eventbus.Event) (wkproto.ReasonCode, *wkp
 			h.Error("token is empty")
 			return wkproto.ReasonAuthFail, nil, errors.New("token is empty")
 		}
-		device, err := service.Store.GetDevice(uid, connectPacket.DeviceFlag)
+		level, err := h.verifyToken(connectPacket)
 		if err != nil {
-			h.Error("get device token err", zap.Error(err))
+			h.Error("token verify fail", zap.Error(err), zap.String("uid", uid), zap.Uint8("deviceFlag", connectPacket.DeviceFlag.ToUint8()))
 			return wkproto.ReasonAuthFail, nil, err
 		}
-		if device.Token != connectPacket.Token {
-			h.Error("token verify fail", zap.String("expectToken", device.Token), zap.String("actToken", connectPacket.Token))
-			return wkproto.ReasonAuthFail, nil, errors.New("token verify fail")
-		}
-		devceLevel = wkproto.DeviceLevel(device.DeviceLevel)
+		devceLevel = level
 	} else {
 		devceLevel = wkproto.DeviceLevelSlave // 默认都是slave设备
 	}
@@ -205,6 +201,9 @@ func (h *Handler) handleConnect(event *eventbus.Event) (wkproto.ReasonCode, *wkp
 	deviceOnlineCount := eventbus.User.ConnCountByDeviceFlag(uid, connectPacket.DeviceFlag)
 	totalOnlineCount := eventbus.User.ConnCountByUid(uid)
 	service.Webhook.Online(uid, connectPacket.DeviceFlag, conn.ConnId, deviceOnlineCount, totalOnlineCount)
+	service.PresenceManager.Online(uid, connectPacket.DeviceFlag, deviceOnlineCount, totalOnlineCount) // 通知在线状态订阅者
+	h.updateOnlineStatus(uid, connectPacket.DeviceFlag, true)                                          // 记录最后上线时间
+	h.addLoginLog(conn)                                                                                // 记录登录日志
 
 	return wkproto.ReasonSuccess, connack, nil
 }
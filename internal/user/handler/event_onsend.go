@@ -5,6 +5,7 @@ import (
 
 	"github.com/WuKongIM/WuKongIM/internal/eventbus"
 	"github.com/WuKongIM/WuKongIM/internal/options"
+	"github.com/WuKongIM/WuKongIM/internal/service"
 	"github.com/WuKongIM/WuKongIM/internal/track"
 	"github.com/WuKongIM/WuKongIM/pkg/trace"
 	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
@@ -43,6 +44,20 @@ func (h *Handler) handleOnSend(event *eventbus.Event) {
 		fakeChannelId = options.GetFakeChannelIDWith(channelId, conn.Uid)
 	}
 
+	// 用户发送速率限制
+	if !h.allowSend(conn) {
+		h.Info("handleOnSend: rate limit！", zap.String("uid", conn.Uid), zap.String("channelId", channelId), zap.Uint8("channelType", channelType))
+		sendack := &wkproto.SendackPacket{
+			Framer:      sendPacket.Framer,
+			MessageID:   event.MessageId,
+			ClientSeq:   sendPacket.ClientSeq,
+			ClientMsgNo: sendPacket.ClientMsgNo,
+			ReasonCode:  wkproto.ReasonRateLimit,
+		}
+		eventbus.User.ConnWrite(conn, sendack)
+		return
+	}
+
 	// 解密消息
 	newPayload, err := h.decryptPayload(sendPacket, conn)
 	if err != nil {
@@ -74,6 +89,18 @@ func (h *Handler) handleOnSend(event *eventbus.Event) {
 
 }
 
+// 用户的发送速率是否在限制内（系统账号和系统设备不限制）
+func (h *Handler) allowSend(conn *eventbus.Conn) bool {
+	if !options.G.RateLimit.On {
+		return true
+	}
+	if options.G.IsSystemDevice(conn.DeviceId) || service.SystemAccountManager.IsSystemAccount(conn.Uid) {
+		return true
+	}
+	limit := options.G.RateLimit.User
+	return h.sendLimiter.Allow(conn.Uid, limit.Rate, limit.Burst)
+}
+
 // decode payload
 func (h *Handler) decryptPayload(sendPacket *wkproto.SendPacket, conn *eventbus.Conn) ([]byte, error) {
 
@@ -37,10 +37,6 @@ func (h *Handler) writeFrame(ctx *eventbus.UserContext) {
 func (h *Handler) writeLocalFrame(event *eventbus.Event) {
 	conn := event.Conn
 	frame := event.Frame
-	data, err := eventbus.Proto.EncodeFrame(frame, conn.ProtoVersion)
-	if err != nil {
-		h.Error("writeFrame: encode frame err", zap.Error(err))
-	}
 	// 统计
 	h.totalOut(conn, frame)
 	// 记录消息路径
@@ -62,9 +58,14 @@ func (h *Handler) writeLocalFrame(event *eventbus.Event) {
 		}
 		return
 	}
+	data, err := eventbus.ConnProto(realConn).EncodeFrame(frame, conn.ProtoVersion)
+	if err != nil {
+		h.Error("writeFrame: encode frame err", zap.Error(err))
+		return
+	}
 	wsConn, wsok := realConn.(wknet.IWSConn) // websocket连接
 	if wsok {
-		err := wsConn.WriteServerBinary(data)
+		err := wsConn.WriteServerMessage(data)
 		if err != nil {
 			h.Warn("writeFrame: Failed to ws write the message", zap.Error(err))
 		}
@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"sync"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/internal/eventbus"
+	"github.com/WuKongIM/WuKongIM/internal/options"
+	"github.com/WuKongIM/WuKongIM/internal/service"
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/store"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+	"github.com/lni/goutils/syncutil"
+	"go.uber.org/zap"
+)
+
+const (
+	onlineStatusFlushInterval = time.Second // 在线状态和登录日志批量提交的间隔
+	onlineStatusMaxBatch      = 1000        // 在线状态或登录日志积累到这个数量立即提交
+)
+
+type onlineStatusKey struct {
+	uid        string
+	deviceFlag wkproto.DeviceFlag
+	online     bool
+}
+
+// onlineStatusBatcher 合并用户的上线/离线时间和登录日志，定时按slot批量提交，避免每次连接和断开都提交一次slot的raft日志
+// 同一个设备在间隔内多次上线（或离线）只保留最后一次的时间，登录日志每次登录都保留
+type onlineStatusBatcher struct {
+	mu        sync.Mutex
+	pending   map[onlineStatusKey]time.Time
+	loginLogs []wkdb.LoginLog
+	flushC    chan struct{}
+	stopper   *syncutil.Stopper
+	wklog.Log
+}
+
+func newOnlineStatusBatcher() *onlineStatusBatcher {
+	return &onlineStatusBatcher{
+		pending: make(map[onlineStatusKey]time.Time),
+		flushC:  make(chan struct{}, 1),
+		stopper: syncutil.NewStopper(),
+		Log:     wklog.NewWKLog("onlineStatusBatcher"),
+	}
+}
+
+func (b *onlineStatusBatcher) start() {
+	b.stopper.RunWorker(b.loop)
+}
+
+// 停止前提交剩余的在线状态
+func (b *onlineStatusBatcher) stop() {
+	b.stopper.Stop()
+	b.flush()
+}
+
+func (b *onlineStatusBatcher) add(uid string, deviceFlag wkproto.DeviceFlag, online bool, at time.Time) {
+	b.mu.Lock()
+	b.pending[onlineStatusKey{uid: uid, deviceFlag: deviceFlag, online: online}] = at
+	full := len(b.pending) >= onlineStatusMaxBatch
+	b.mu.Unlock()
+	if full {
+		b.notifyFlush()
+	}
+}
+
+func (b *onlineStatusBatcher) addLoginLog(log wkdb.LoginLog) {
+	b.mu.Lock()
+	b.loginLogs = append(b.loginLogs, log)
+	full := len(b.loginLogs) >= onlineStatusMaxBatch
+	b.mu.Unlock()
+	if full {
+		b.notifyFlush()
+	}
+}
+
+func (b *onlineStatusBatcher) notifyFlush() {
+	select {
+	case b.flushC <- struct{}{}:
+	default:
+	}
+}
+
+func (b *onlineStatusBatcher) loop() {
+	tk := time.NewTicker(onlineStatusFlushInterval)
+	defer tk.Stop()
+	for {
+		select {
+		case <-tk.C:
+			b.flush()
+		case <-b.flushC:
+			b.flush()
+		case <-b.stopper.ShouldStop():
+			return
+		}
+	}
+}
+
+func (b *onlineStatusBatcher) take() []store.UserOnlineStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.pending) == 0 {
+		return nil
+	}
+	statuses := make([]store.UserOnlineStatus, 0, len(b.pending))
+	for key, at := range b.pending {
+		statuses = append(statuses, store.UserOnlineStatus{
+			Uid:        key.uid,
+			DeviceFlag: uint64(key.deviceFlag),
+			Online:     key.online,
+			At:         at,
+		})
+	}
+	b.pending = make(map[onlineStatusKey]time.Time)
+	return statuses
+}
+
+func (b *onlineStatusBatcher) takeLoginLogs() []wkdb.LoginLog {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	logs := b.loginLogs
+	b.loginLogs = nil
+	return logs
+}
+
+func (b *onlineStatusBatcher) flush() {
+	// 最后上线/离线时间和登录日志只用于展示，提交失败不重试
+	if statuses := b.take(); len(statuses) > 0 {
+		if err := service.Store.BatchUpdateUserOnlineStatus(statuses); err != nil {
+			b.Warn("flush: update online status failed", zap.Error(err), zap.Int("count", len(statuses)))
+		}
+	}
+	if logs := b.takeLoginLogs(); len(logs) > 0 {
+		if err := service.Store.BatchAddLoginLogs(logs, options.G.LoginLog.MaxCount); err != nil {
+			b.Warn("flush: add login logs failed", zap.Error(err), zap.Int("count", len(logs)))
+		}
+	}
+}
+
+// 记录用户和设备的最后上线/离线时间（只在用户的slot leader节点上执行），由onlineStatusBatcher批量提交
+func (h *Handler) updateOnlineStatus(uid string, deviceFlag wkproto.DeviceFlag, online bool) {
+	if uid == options.G.SystemUID || uid == options.G.ManagerUID {
+		return
+	}
+	h.onlineStatus.add(uid, deviceFlag, online, time.Now())
+}
+
+// 记录用户的登录日志（只在用户的slot leader节点上执行），由onlineStatusBatcher批量提交
+func (h *Handler) addLoginLog(conn *eventbus.Conn) {
+	if !options.G.LoginLog.On {
+		return
+	}
+	if conn.Uid == options.G.SystemUID || conn.Uid == options.G.ManagerUID {
+		return
+	}
+	createdAt := time.Now()
+	h.onlineStatus.addLoginLog(wkdb.LoginLog{
+		Uid:         conn.Uid,
+		DeviceFlag:  uint64(conn.DeviceFlag),
+		DeviceLevel: uint8(conn.DeviceLevel),
+		DeviceId:    conn.DeviceId,
+		Ip:          conn.ClientIp,
+		NodeId:      conn.NodeId,
+		CreatedAt:   &createdAt,
+	})
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOnlineStatusBatcherMerge(t *testing.T) {
+	b := newOnlineStatusBatcher()
+
+	t1 := time.Now()
+	t2 := t1.Add(time.Second)
+	b.add("u1", wkproto.APP, true, t1)
+	b.add("u1", wkproto.APP, false, t1)
+	b.add("u1", wkproto.APP, true, t2) // 同一设备多次上线只保留最后一次
+	b.add("u2", wkproto.PC, true, t1)
+
+	statuses := b.take()
+	assert.Len(t, statuses, 3)
+	for _, status := range statuses {
+		if status.Uid == "u1" && status.Online {
+			assert.Equal(t, t2, status.At)
+		}
+	}
+	assert.Empty(t, b.take())
+}
+
+func TestOnlineStatusBatcherLoginLogs(t *testing.T) {
+	b := newOnlineStatusBatcher()
+
+	b.addLoginLog(wkdb.LoginLog{Uid: "u1", Ip: "127.0.0.1"})
+	b.addLoginLog(wkdb.LoginLog{Uid: "u1", Ip: "127.0.0.2"}) // 每次登录都保留
+
+	logs := b.takeLoginLogs()
+	assert.Len(t, logs, 2)
+	assert.Equal(t, "127.0.0.2", logs[1].Ip)
+	assert.Empty(t, b.takeLoginLogs())
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"github.com/WuKongIM/WuKongIM/internal/options"
+	"github.com/WuKongIM/WuKongIM/internal/service"
+	"github.com/WuKongIM/WuKongIM/pkg/wkserver/proto"
+	"go.uber.org/zap"
+)
+
+// 取消用户的所有在线状态订阅
+// 订阅关系保存在被订阅用户的leader节点上，所以需要通知所有节点
+func (h *Handler) presenceUnsubscribeAll(uid string) {
+	if !options.G.Presence.On {
+		return
+	}
+	service.PresenceManager.UnsubscribeAll(uid)
+
+	msg := &proto.Message{
+		MsgType: uint32(msgPresenceUnsubscribeAll),
+		Content: []byte(uid),
+	}
+	for _, node := range service.Cluster.Nodes() {
+		if options.G.IsLocalNode(node.Id) || !node.Online {
+			continue
+		}
+		err := h.sendToNode(node.Id, msg)
+		if err != nil {
+			h.Warn("presenceUnsubscribeAll: send failed", zap.Error(err), zap.Uint64("nodeId", node.Id), zap.String("uid", uid))
+		}
+	}
+}
+
+// 收到取消在线状态订阅的消息
+func (h *Handler) onPresenceUnsubscribeAll(m *proto.Message) {
+	uid := string(m.Content)
+	if uid == "" {
+		return
+	}
+	service.PresenceManager.UnsubscribeAll(uid)
+}
@@ -13,6 +13,8 @@ type msgType uint32
 const (
 	// 转发用户事件
 	msgForwardUserEvent msgType = 2001
+	// 取消用户的所有在线状态订阅
+	msgPresenceUnsubscribeAll msgType = 2002
 )
 
 type forwardUserEventReq struct {
@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/internal/options"
+	"github.com/WuKongIM/WuKongIM/internal/service"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwt连接token
+type tokenJwt struct {
+	once      sync.Once
+	publicKey interface{} // 验签公钥
+	err       error       // 公钥解析错误
+}
+
+// 验证连接token，返回设备等级
+// 开启jwt连接token时先在本地验证jwt（验签、过期时间和设备退出的吊销时间，不读取设备数据），
+// 验证失败再按/user/token设置的设备token验证（设备token也可能包含"."）
+func (h *Handler) verifyToken(connectPacket *wkproto.ConnectPacket) (wkproto.DeviceLevel, error) {
+	var jwtErr error
+	if options.G.TokenJwt.On {
+		var level wkproto.DeviceLevel
+		level, jwtErr = h.verifyJwtConnectToken(connectPacket)
+		if jwtErr == nil {
+			return level, nil
+		}
+	}
+
+	device, err := service.Store.GetDevice(connectPacket.UID, connectPacket.DeviceFlag)
+	if err != nil && err != wkdb.ErrNotFound {
+		return 0, err
+	}
+	if device.Token == "" || device.Token != connectPacket.Token {
+		if jwtErr != nil {
+			return 0, fmt.Errorf("token verify fail, jwt: %w", jwtErr)
+		}
+		return 0, errors.New("token verify fail")
+	}
+	if device.TokenExpireAt != nil && time.Now().After(*device.TokenExpireAt) {
+		return 0, errors.New("token expired")
+	}
+	return wkproto.DeviceLevel(device.DeviceLevel), nil
+}
+
+// 验证jwt连接token，并检查设备退出（device_quit）的吊销时间，吊销时间在每个节点都有缓存
+func (h *Handler) verifyJwtConnectToken(connectPacket *wkproto.ConnectPacket) (wkproto.DeviceLevel, error) {
+	level, issuedAt, err := h.verifyJwtToken(connectPacket)
+	if err != nil {
+		return 0, err
+	}
+	revokedAt, ok, err := service.TokenRevokeManager.RevokedAt(service.DeviceRevokeId(connectPacket.UID, connectPacket.DeviceFlag))
+	if err != nil {
+		return 0, err
+	}
+	// 设备退出之前签发的jwt无效
+	if ok && !issuedAt.After(revokedAt) {
+		return 0, errors.New("jwt token is revoked")
+	}
+	return level, nil
+}
+
+// 验证jwt连接token，验证通过返回jwt中的设备等级和签发时间
+// jwt的payload需要包含 uid、device_flag、device_level、iat、exp，并且exp - iat不能超过配置的最长有效期
+func (h *Handler) verifyJwtToken(connectPacket *wkproto.ConnectPacket) (wkproto.DeviceLevel, time.Time, error) {
+	cfg := options.G.TokenJwt
+
+	var (
+		key     interface{}
+		methods []string
+	)
+	if strings.TrimSpace(cfg.PublicKey) != "" {
+		publicKey, err := h.jwtPublicKey()
+		if err != nil {
+			return 0, time.Time{}, err
+		}
+		key = publicKey
+		methods = []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "EdDSA"}
+	} else if cfg.Secret != "" {
+		key = []byte(cfg.Secret)
+		methods = []string{"HS256", "HS384", "HS512"}
+	} else {
+		return 0, time.Time{}, errors.New("tokenJwt secret or publicKey is empty")
+	}
+
+	parserOpts := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(), // 必须有过期时间
+		jwt.WithIssuedAt(),           // 签发时间不能晚于当前时间
+	}
+	if cfg.Issuer != "" {
+		parserOpts = append(parserOpts, jwt.WithIssuer(cfg.Issuer))
+	}
+
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(connectPacket.Token, claims, func(t *jwt.Token) (interface{}, error) {
+		return key, nil
+	}, parserOpts...)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	if !token.Valid {
+		return 0, time.Time{}, errors.New("invalid jwt token")
+	}
+
+	uid, _ := claims["uid"].(string)
+	if uid == "" || uid != connectPacket.UID {
+		return 0, time.Time{}, fmt.Errorf("jwt uid[%s] not match connect uid[%s]", uid, connectPacket.UID)
+	}
+
+	// json解析出来的数字类型为float64
+	deviceFlag, ok := claims["device_flag"].(float64)
+	if !ok || uint8(deviceFlag) != connectPacket.DeviceFlag.ToUint8() {
+		return 0, time.Time{}, fmt.Errorf("jwt device_flag not match connect device_flag[%d]", connectPacket.DeviceFlag)
+	}
+
+	deviceLevel := wkproto.DeviceLevelSlave
+	if level, ok := claims["device_level"].(float64); ok {
+		deviceLevel = wkproto.DeviceLevel(uint8(level))
+	}
+	// 必须有签发时间，否则没法判断是不是在设备退出之前签发的
+	iat, err := claims.GetIssuedAt()
+	if err != nil || iat == nil {
+		return 0, time.Time{}, errors.New("jwt iat is required")
+	}
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return 0, time.Time{}, errors.New("jwt exp is required")
+	}
+	// 吊销记录只保留MaxAge，有效期更长的jwt在吊销记录删除后会重新生效
+	if exp.Sub(iat.Time) > cfg.MaxAge {
+		return 0, time.Time{}, fmt.Errorf("jwt lifetime exceeds max age %s", cfg.MaxAge)
+	}
+	return deviceLevel, iat.Time, nil
+}
+
+// 解析验签公钥（只解析一次）
+func (h *Handler) jwtPublicKey() (interface{}, error) {
+	h.tokenJwt.once.Do(func() {
+		pemData := []byte(options.G.TokenJwt.PublicKey)
+		if !strings.Contains(options.G.TokenJwt.PublicKey, "-----BEGIN") { // 不是PEM内容则当作文件路径
+			pemData, h.tokenJwt.err = os.ReadFile(options.G.TokenJwt.PublicKey)
+			if h.tokenJwt.err != nil {
+				return
+			}
+		}
+		if key, err := jwt.ParseRSAPublicKeyFromPEM(pemData); err == nil {
+			h.tokenJwt.publicKey = key
+			return
+		}
+		if key, err := jwt.ParseECPublicKeyFromPEM(pemData); err == nil {
+			h.tokenJwt.publicKey = key
+			return
+		}
+		if key, err := jwt.ParseEdPublicKeyFromPEM(pemData); err == nil {
+			h.tokenJwt.publicKey = key
+			return
+		}
+		h.tokenJwt.err = errors.New("tokenJwt publicKey is invalid")
+	})
+	return h.tokenJwt.publicKey, h.tokenJwt.err
+}
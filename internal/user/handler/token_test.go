@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/internal/options"
+	"github.com/WuKongIM/WuKongIM/internal/service"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyJwtToken(t *testing.T) {
+	options.G = options.New()
+	options.G.TokenJwt.On = true
+	options.G.TokenJwt.Secret = "secret"
+
+	sign := func(claims jwt.MapClaims) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+		assert.NoError(t, err)
+		return token
+	}
+
+	h := &Handler{}
+	iat := time.Now().Add(-time.Minute).Truncate(time.Second)
+	connectPacket := &wkproto.ConnectPacket{
+		UID:        "u1",
+		DeviceFlag: wkproto.APP,
+		Token: sign(jwt.MapClaims{
+			"uid":          "u1",
+			"device_flag":  0,
+			"device_level": 1,
+			"iat":          iat.Unix(),
+			"exp":          time.Now().Add(time.Hour).Unix(),
+		}),
+	}
+	level, issuedAt, err := h.verifyJwtToken(connectPacket)
+	assert.NoError(t, err)
+	assert.Equal(t, wkproto.DeviceLevelMaster, level)
+	assert.True(t, iat.Equal(issuedAt))
+
+	// uid不一致
+	connectPacket.UID = "u2"
+	_, _, err = h.verifyJwtToken(connectPacket)
+	assert.Error(t, err)
+
+	// 没有过期时间
+	connectPacket.UID = "u1"
+	connectPacket.Token = sign(jwt.MapClaims{"uid": "u1", "device_flag": 0})
+	_, _, err = h.verifyJwtToken(connectPacket)
+	assert.Error(t, err)
+
+	// 没有签发时间
+	connectPacket.Token = sign(jwt.MapClaims{"uid": "u1", "device_flag": 0, "exp": time.Now().Add(time.Hour).Unix()})
+	_, _, err = h.verifyJwtToken(connectPacket)
+	assert.Error(t, err)
+
+	// 有效期超过最长有效期
+	connectPacket.Token = sign(jwt.MapClaims{
+		"uid":         "u1",
+		"device_flag": 0,
+		"iat":         iat.Unix(),
+		"exp":         iat.Add(options.G.TokenJwt.MaxAge + time.Hour).Unix(),
+	})
+	_, _, err = h.verifyJwtToken(connectPacket)
+	assert.Error(t, err)
+
+	// 包含"."的普通设备token不是jwt
+	connectPacket.Token = "a.b.c"
+	_, _, err = h.verifyJwtToken(connectPacket)
+	assert.Error(t, err)
+}
+
+func TestVerifyJwtConnectTokenRevoked(t *testing.T) {
+	options.G = options.New()
+	options.G.TokenJwt.On = true
+	options.G.TokenJwt.Secret = "secret"
+
+	revokeManager := &testTokenRevokeManager{revokedAt: map[string]time.Time{}}
+	service.TokenRevokeManager = revokeManager
+
+	sign := func(iat time.Time) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"uid":         "u1",
+			"device_flag": 0,
+			"iat":         iat.Unix(),
+			"exp":         time.Now().Add(time.Hour).Unix(),
+		}).SignedString([]byte("secret"))
+		assert.NoError(t, err)
+		return token
+	}
+
+	h := &Handler{}
+	revokedAt := time.Now().Add(-time.Minute)
+	connectPacket := &wkproto.ConnectPacket{UID: "u1", DeviceFlag: wkproto.APP, Token: sign(revokedAt.Add(-time.Minute))}
+
+	_, err := h.verifyJwtConnectToken(connectPacket)
+	assert.NoError(t, err)
+
+	// 设备退出之前签发的jwt失效
+	revokeManager.revokedAt[service.DeviceRevokeId("u1", wkproto.APP)] = revokedAt
+	_, err = h.verifyJwtConnectToken(connectPacket)
+	assert.Error(t, err)
+
+	// 设备退出之后签发的jwt有效
+	connectPacket.Token = sign(revokedAt.Add(time.Second * 2))
+	_, err = h.verifyJwtConnectToken(connectPacket)
+	assert.NoError(t, err)
+}
+
+type testTokenRevokeManager struct {
+	revokedAt map[string]time.Time
+}
+
+func (t *testTokenRevokeManager) IsRevoked(ids ...string) (bool, error) {
+	return false, nil
+}
+
+func (t *testTokenRevokeManager) RevokedAt(id string) (time.Time, bool, error) {
+	revokedAt, ok := t.revokedAt[id]
+	return revokedAt, ok, nil
+}
+
+func (t *testTokenRevokeManager) Revoke(token wkdb.RevokedToken) error {
+	return nil
+}
+
+func (t *testTokenRevokeManager) AddRevokedToCache(token wkdb.RevokedToken) {}
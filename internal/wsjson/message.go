@@ -0,0 +1,117 @@
+package wsjson
+
+import (
+	"strconv"
+)
+
+// 消息类型
+const (
+	typeConnect    = "connect"
+	typeConnack    = "connack"
+	typeSend       = "send"
+	typeSendack    = "sendack"
+	typeRecv       = "recv"
+	typeRecvack    = "recvack"
+	typePing       = "ping"
+	typePong       = "pong"
+	typeDisconnect = "disconnect"
+)
+
+// 客户端发来的消息（根据type使用对应的字段）
+type request struct {
+	Type string `json:"type"`
+
+	// connect
+	UID        string `json:"uid"`
+	Token      string `json:"token"`
+	DeviceID   string `json:"device_id"`
+	DeviceFlag *uint8 `json:"device_flag"`
+	ClientKey  string `json:"client_key"` // 客户端的DH公钥（base64编码）
+
+	// send
+	ClientSeq   uint64 `json:"client_seq"`
+	ClientMsgNo string `json:"client_msg_no"`
+	ChannelID   string `json:"channel_id"`
+	ChannelType uint8  `json:"channel_type"`
+	Topic       string `json:"topic"`
+	Expire      uint32 `json:"expire"`
+	MsgKey      string `json:"msg_key"`
+	Payload     string `json:"payload"` // 加密后的消息内容（和二进制协议一样是base64编码的密文）
+	NoPersist   bool   `json:"no_persist"`
+	RedDot      *bool  `json:"red_dot"` // 默认为true
+	SyncOnce    bool   `json:"sync_once"`
+
+	// recvack
+	MessageID  int64String `json:"message_id"`
+	MessageSeq uint32      `json:"message_seq"`
+}
+
+type connackResp struct {
+	Type          string `json:"type"`
+	ReasonCode    uint8  `json:"reason_code"`
+	ServerKey     string `json:"server_key,omitempty"` // 服务端的DH公钥（base64编码）
+	Salt          string `json:"salt,omitempty"`       // 加密的iv
+	TimeDiff      int64  `json:"time_diff"`
+	ServerVersion uint8  `json:"server_version,omitempty"`
+	NodeId        uint64 `json:"node_id,omitempty"`
+}
+
+type sendackResp struct {
+	Type        string      `json:"type"`
+	ClientSeq   uint64      `json:"client_seq"`
+	ClientMsgNo string      `json:"client_msg_no,omitempty"`
+	MessageID   int64String `json:"message_id"`
+	MessageSeq  uint32      `json:"message_seq"`
+	ReasonCode  uint8       `json:"reason_code"`
+}
+
+type recvResp struct {
+	Type        string      `json:"type"`
+	MessageID   int64String `json:"message_id"`
+	MessageSeq  uint32      `json:"message_seq"`
+	ClientMsgNo string      `json:"client_msg_no"`
+	Timestamp   int32       `json:"timestamp"`
+	ChannelID   string      `json:"channel_id"`
+	ChannelType uint8       `json:"channel_type"`
+	Topic       string      `json:"topic,omitempty"`
+	FromUID     string      `json:"from_uid"`
+	Expire      uint32      `json:"expire,omitempty"`
+	NoPersist   bool        `json:"no_persist,omitempty"`
+	RedDot      bool        `json:"red_dot,omitempty"`
+	SyncOnce    bool        `json:"sync_once,omitempty"`
+	MsgKey      string      `json:"msg_key"`
+	Payload     string      `json:"payload"` // 加密后的消息内容
+}
+
+type pongResp struct {
+	Type string `json:"type"`
+}
+
+type disconnectResp struct {
+	Type       string `json:"type"`
+	ReasonCode uint8  `json:"reason_code"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// int64String 64位整数以字符串输出（js的number只能精确表示53位），解析时字符串和数字都支持
+type int64String int64
+
+func (i int64String) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(strconv.FormatInt(int64(i), 10))), nil
+}
+
+func (i *int64String) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		s = s[1 : len(s)-1]
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	*i = int64String(v)
+	return nil
+}
@@ -0,0 +1,220 @@
+package wsjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/internal/options"
+	"github.com/WuKongIM/WuKongIM/pkg/wknet"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+)
+
+// Subprotocol json协议的websocket子协议，客户端通过Sec-WebSocket-Protocol协商后使用json文本帧通讯
+const Subprotocol = "wukongim.json"
+
+var (
+	ErrIncompleteMessage = errors.New("incomplete json message")
+	ErrUnsupportedFrame  = errors.New("unsupported frame")
+)
+
+// Proto json协议的编解码
+var Proto wkproto.Protocol = New()
+
+// Protocol websocket的json协议编解码，和悟空IM的二进制协议一样在连接的编解码层工作
+// 客户端每个文本帧是一个json消息，type字段为消息类型，解码后和二进制协议的包一样进入事件流程
+// 只是编解码不同，密钥交换和消息内容的加密和二进制协议一样（connect的client_key，connack的server_key和salt，send/recv的msg_key和加密的payload）
+//
+// 上行: connect, send, recvack, ping（客户端主动断开直接关闭websocket）
+// 下行: connack, sendack, recv, pong, disconnect
+// 消息id以字符串输出
+type Protocol struct {
+}
+
+func New() *Protocol {
+	return &Protocol{}
+}
+
+// IsJsonConn 连接是否协商了json协议
+func IsJsonConn(conn wknet.Conn) bool {
+	wsConn, ok := conn.(wknet.IWSConn)
+	return ok && wsConn.Subprotocol() == Subprotocol
+}
+
+// DecodeFrame 解码一个json消息，数据只有空白时返回nil
+func (p *Protocol) DecodeFrame(data []byte, version uint8) (wkproto.Frame, int, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, 0, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	req := &request{}
+	if err := dec.Decode(req); err != nil {
+		if err == io.ErrUnexpectedEOF { // websocket的消息都是完整的，不完整说明消息有问题
+			return nil, 0, ErrIncompleteMessage
+		}
+		return nil, 0, fmt.Errorf("invalid json message: %w", err)
+	}
+	size := int(dec.InputOffset())
+
+	var (
+		frame wkproto.Frame
+		err   error
+	)
+	switch req.Type {
+	case typeConnect:
+		frame, err = decodeConnect(req)
+	case typeSend:
+		frame, err = decodeSend(req)
+	case typeRecvack:
+		frame = &wkproto.RecvackPacket{
+			Framer: wkproto.Framer{
+				NoPersist: req.NoPersist,
+				SyncOnce:  req.SyncOnce,
+			},
+			MessageID:  int64(req.MessageID),
+			MessageSeq: req.MessageSeq,
+		}
+	case typePing:
+		frame = &wkproto.PingPacket{}
+	default:
+		err = fmt.Errorf("unknown message type: %s", req.Type)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return frame, size, nil
+}
+
+// EncodeFrame 将服务端的包编码为一个json消息
+func (p *Protocol) EncodeFrame(frame wkproto.Frame, version uint8) ([]byte, error) {
+	var msg interface{}
+	switch f := frame.(type) {
+	case *wkproto.ConnackPacket:
+		msg = &connackResp{
+			Type:          typeConnack,
+			ReasonCode:    f.ReasonCode.Byte(),
+			ServerKey:     f.ServerKey,
+			Salt:          f.Salt,
+			TimeDiff:      f.TimeDiff,
+			ServerVersion: f.ServerVersion,
+			NodeId:        f.NodeId,
+		}
+	case *wkproto.SendackPacket:
+		msg = &sendackResp{
+			Type:        typeSendack,
+			ClientSeq:   f.ClientSeq,
+			ClientMsgNo: f.ClientMsgNo,
+			MessageID:   int64String(f.MessageID),
+			MessageSeq:  f.MessageSeq,
+			ReasonCode:  f.ReasonCode.Byte(),
+		}
+	case *wkproto.RecvPacket:
+		msg = encodeRecv(f)
+	case *wkproto.PongPacket:
+		msg = &pongResp{Type: typePong}
+	case *wkproto.DisconnectPacket:
+		msg = &disconnectResp{
+			Type:       typeDisconnect,
+			ReasonCode: f.ReasonCode.Byte(),
+			Reason:     f.Reason,
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFrame, frame.GetFrameType().String())
+	}
+	return json.Marshal(msg)
+}
+
+// WriteFrame 编码并写入writer
+func (p *Protocol) WriteFrame(w wkproto.Writer, frame wkproto.Frame, version uint8) error {
+	data, err := p.EncodeFrame(frame, version)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func decodeConnect(req *request) (*wkproto.ConnectPacket, error) {
+	uid := strings.TrimSpace(req.UID)
+	if uid == "" {
+		return nil, errors.New("uid is empty")
+	}
+	deviceId := req.DeviceID
+	if deviceId == "" {
+		deviceId = wkutil.GenUUID()
+	}
+	deviceFlag := wkproto.DeviceFlag(options.G.WSJson.DeviceFlag)
+	if req.DeviceFlag != nil {
+		deviceFlag = wkproto.DeviceFlag(*req.DeviceFlag)
+	}
+	return &wkproto.ConnectPacket{
+		Version:         wkproto.LatestVersion,
+		DeviceID:        deviceId,
+		DeviceFlag:      deviceFlag,
+		ClientTimestamp: time.Now().UnixNano() / 1000 / 1000,
+		UID:             uid,
+		Token:           req.Token,
+		ClientKey:       req.ClientKey,
+	}, nil
+}
+
+func decodeSend(req *request) (*wkproto.SendPacket, error) {
+	if req.ChannelID == "" || req.ChannelType == 0 {
+		return nil, errors.New("channel_id or channel_type is empty")
+	}
+	if req.Payload == "" {
+		return nil, errors.New("payload is empty")
+	}
+	clientMsgNo := req.ClientMsgNo
+	if clientMsgNo == "" {
+		clientMsgNo = wkutil.GenUUID()
+	}
+	redDot := true
+	if req.RedDot != nil {
+		redDot = *req.RedDot
+	}
+	sendPacket := &wkproto.SendPacket{
+		Framer: wkproto.Framer{
+			NoPersist: req.NoPersist,
+			RedDot:    redDot,
+			SyncOnce:  req.SyncOnce,
+		},
+		Expire:      req.Expire,
+		ClientSeq:   req.ClientSeq,
+		ClientMsgNo: clientMsgNo,
+		ChannelID:   req.ChannelID,
+		ChannelType: req.ChannelType,
+		Topic:       req.Topic,
+		MsgKey:      req.MsgKey,
+		Payload:     []byte(req.Payload),
+	}
+	if req.Topic != "" {
+		sendPacket.Setting.Set(wkproto.SettingTopic)
+	}
+	return sendPacket, nil
+}
+
+func encodeRecv(f *wkproto.RecvPacket) *recvResp {
+	return &recvResp{
+		Type:        typeRecv,
+		MessageID:   int64String(f.MessageID),
+		MessageSeq:  f.MessageSeq,
+		ClientMsgNo: f.ClientMsgNo,
+		Timestamp:   f.Timestamp,
+		ChannelID:   f.ChannelID,
+		ChannelType: f.ChannelType,
+		Topic:       f.Topic,
+		FromUID:     f.FromUID,
+		Expire:      f.Expire,
+		NoPersist:   f.NoPersist,
+		RedDot:      f.RedDot,
+		SyncOnce:    f.SyncOnce,
+		MsgKey:      f.MsgKey,
+		Payload:     string(f.Payload),
+	}
+}
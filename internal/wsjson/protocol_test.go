@@ -0,0 +1,157 @@
+package wsjson
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/internal/options"
+	"github.com/WuKongIM/WuKongIM/pkg/wknet"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeConnect(t *testing.T) {
+	options.G = options.New()
+	p := New()
+
+	data := []byte(`{"type":"connect","uid":"u1","token":"t1","client_key":"a2V5"}`)
+	frame, size, err := p.DecodeFrame(data, wkproto.LatestVersion)
+	assert.NoError(t, err)
+	assert.Equal(t, len(data), size)
+	connect := frame.(*wkproto.ConnectPacket)
+	assert.Equal(t, "u1", connect.UID)
+	assert.Equal(t, "t1", connect.Token)
+	assert.Equal(t, uint8(wkproto.LatestVersion), connect.Version)
+	assert.Equal(t, wkproto.DeviceFlag(options.G.WSJson.DeviceFlag), connect.DeviceFlag)
+	assert.NotEmpty(t, connect.DeviceID)
+	assert.Equal(t, "a2V5", connect.ClientKey)
+
+	frame, _, err = p.DecodeFrame([]byte(`{"type":"connect","uid":"u1","device_id":"d1","device_flag":0}`), wkproto.LatestVersion)
+	assert.NoError(t, err)
+	connect = frame.(*wkproto.ConnectPacket)
+	assert.Equal(t, "d1", connect.DeviceID)
+	assert.Equal(t, wkproto.APP, connect.DeviceFlag)
+
+	_, _, err = p.DecodeFrame([]byte(`{"type":"connect","uid":" "}`), wkproto.LatestVersion)
+	assert.Error(t, err)
+}
+
+func TestDecodeSend(t *testing.T) {
+	p := New()
+
+	frame, _, err := p.DecodeFrame([]byte(`{"type":"send","client_seq":1,"channel_id":"u2","channel_type":1,"msg_key":"k1","payload":"aGVsbG8="}`), wkproto.LatestVersion)
+	assert.NoError(t, err)
+	send := frame.(*wkproto.SendPacket)
+	assert.Equal(t, uint64(1), send.ClientSeq)
+	assert.Equal(t, "u2", send.ChannelID)
+	assert.Equal(t, uint8(1), send.ChannelType)
+	assert.Equal(t, "aGVsbG8=", string(send.Payload)) // 加密的内容原样交给事件流程解密
+	assert.Equal(t, "k1", send.MsgKey)
+	assert.NotEmpty(t, send.ClientMsgNo)
+	assert.True(t, send.RedDot)
+
+	frame, _, err = p.DecodeFrame([]byte(`{"type":"send","channel_id":"g1","channel_type":2,"topic":"t","red_dot":false,"payload":"aGVsbG8="}`), wkproto.LatestVersion)
+	assert.NoError(t, err)
+	send = frame.(*wkproto.SendPacket)
+	assert.False(t, send.RedDot)
+	assert.True(t, send.Setting.IsSet(wkproto.SettingTopic))
+
+	_, _, err = p.DecodeFrame([]byte(`{"type":"send","channel_id":"u2","channel_type":1}`), wkproto.LatestVersion)
+	assert.Error(t, err) // 没有消息内容
+	_, _, err = p.DecodeFrame([]byte(`{"type":"send","payload":"aGVsbG8="}`), wkproto.LatestVersion)
+	assert.Error(t, err) // 没有频道
+}
+
+func TestDecodeMultiple(t *testing.T) {
+	p := New()
+
+	// 多个websocket消息在inboundBuffer里是连续的
+	data := []byte(`{"type":"ping"} {"type":"recvack","message_id":"9007199254740993","message_seq":2}` + "\n")
+	var frames []wkproto.Frame
+	offset := 0
+	for len(data) > offset {
+		frame, size, err := p.DecodeFrame(data[offset:], wkproto.LatestVersion)
+		assert.NoError(t, err)
+		if frame == nil {
+			break
+		}
+		frames = append(frames, frame)
+		offset += size
+	}
+	assert.Len(t, frames, 2)
+	assert.Equal(t, wkproto.PING, frames[0].GetFrameType())
+	recvack := frames[1].(*wkproto.RecvackPacket)
+	assert.Equal(t, int64(9007199254740993), recvack.MessageID)
+	assert.Equal(t, uint32(2), recvack.MessageSeq)
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	p := New()
+
+	_, _, err := p.DecodeFrame([]byte(`{"type":"ping"`), wkproto.LatestVersion)
+	assert.ErrorIs(t, err, ErrIncompleteMessage)
+	_, _, err = p.DecodeFrame([]byte(`hello`), wkproto.LatestVersion)
+	assert.Error(t, err)
+	_, _, err = p.DecodeFrame([]byte(`{"type":"unknown"}`), wkproto.LatestVersion)
+	assert.Error(t, err)
+}
+
+func TestEncodeFrame(t *testing.T) {
+	p := New()
+
+	data, err := p.EncodeFrame(&wkproto.ConnackPacket{ReasonCode: wkproto.ReasonSuccess, ServerKey: "a2V5", Salt: "salt", TimeDiff: 10, NodeId: 1}, wkproto.LatestVersion)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type":"connack","reason_code":1,"server_key":"a2V5","salt":"salt","time_diff":10,"node_id":1}`, string(data))
+
+	data, err = p.EncodeFrame(&wkproto.SendackPacket{ClientSeq: 1, MessageID: 9007199254740993, MessageSeq: 2, ReasonCode: wkproto.ReasonSuccess}, wkproto.LatestVersion)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type":"sendack","client_seq":1,"message_id":"9007199254740993","message_seq":2,"reason_code":1}`, string(data))
+
+	data, err = p.EncodeFrame(&wkproto.PongPacket{}, wkproto.LatestVersion)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type":"pong"}`, string(data))
+
+	data, err = p.EncodeFrame(&wkproto.DisconnectPacket{ReasonCode: wkproto.ReasonConnectKick, Reason: "kick"}, wkproto.LatestVersion)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type":"disconnect","reason_code":12,"reason":"kick"}`, string(data))
+
+	_, err = p.EncodeFrame(&wkproto.PingPacket{}, wkproto.LatestVersion)
+	assert.ErrorIs(t, err, ErrUnsupportedFrame)
+}
+
+func TestEncodeRecv(t *testing.T) {
+	p := New()
+
+	recv := &wkproto.RecvPacket{
+		MessageID:   9007199254740993,
+		MessageSeq:  2,
+		ChannelID:   "u1",
+		ChannelType: 1,
+		FromUID:     "u1",
+		MsgKey:      "k1",
+		Payload:     []byte("aGVsbG8="),
+	}
+	data, err := p.EncodeFrame(recv, wkproto.LatestVersion)
+	assert.NoError(t, err)
+	resp := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(data, &resp))
+	assert.Equal(t, "recv", resp["type"])
+	assert.Equal(t, "9007199254740993", resp["message_id"]) // 64位的消息id以字符串输出
+	assert.Equal(t, "k1", resp["msg_key"])
+	assert.Equal(t, "aGVsbG8=", resp["payload"])
+}
+
+type testWSConn struct {
+	wknet.Conn
+	subprotocol string
+}
+
+func (c *testWSConn) WriteServerBinary(data []byte) error  { return nil }
+func (c *testWSConn) WriteServerMessage(data []byte) error { return nil }
+func (c *testWSConn) Subprotocol() string                  { return c.subprotocol }
+
+func TestIsJsonConn(t *testing.T) {
+	assert.True(t, IsJsonConn(&testWSConn{subprotocol: Subprotocol}))
+	assert.False(t, IsJsonConn(&testWSConn{}))
+	assert.False(t, IsJsonConn(&wknet.DefaultConn{}))
+}
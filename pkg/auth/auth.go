@@ -1,10 +1,13 @@
 package auth
 
 import (
+	"crypto/subtle"
 	"fmt"
+	"strings"
 
 	"github.com/WuKongIM/WuKongIM/pkg/auth/resource"
 	"github.com/WuKongIM/WuKongIM/pkg/wkhttp"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type Kind string
@@ -28,6 +31,7 @@ type AuthConfig struct {
 	SuperToken string // 超级token
 	Kind       Kind   // 鉴权类型
 	Users      []UserConfig
+	Roles      map[string]PermissionConfigs // 自定义角色（角色名 -> 权限），同名会覆盖内置角色
 }
 
 func (a AuthConfig) Auth(username string, password string) error {
@@ -36,7 +40,7 @@ func (a AuthConfig) Auth(username string, password string) error {
 	}
 
 	for _, user := range a.Users {
-		if user.Username == username && user.Password == password {
+		if user.Username == username && user.Password != "" && ComparePassword(user.Password, password) {
 			return nil
 		}
 
@@ -44,6 +48,38 @@ func (a AuthConfig) Auth(username string, password string) error {
 	return ErrAuthFailed
 }
 
+// HasUser 是否存在用户
+func (a AuthConfig) HasUser(username string) bool {
+	for _, user := range a.Users {
+		if user.Username == username {
+			return true
+		}
+	}
+	return false
+}
+
+// HashPassword 使用bcrypt生成密码的hash值（可以直接配置在auth.users中）
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// IsHashedPassword 是否是bcrypt的hash密码
+func IsHashedPassword(password string) bool {
+	return strings.HasPrefix(password, "$2a$") || strings.HasPrefix(password, "$2b$") || strings.HasPrefix(password, "$2y$")
+}
+
+// ComparePassword 比较密码，配置的密码支持bcrypt的hash值和明文
+func ComparePassword(configPassword string, password string) bool {
+	if IsHashedPassword(configPassword) {
+		return bcrypt.CompareHashAndPassword([]byte(configPassword), []byte(password)) == nil
+	}
+	return subtle.ConstantTimeCompare([]byte(configPassword), []byte(password)) == 1
+}
+
 // HasPermission 是否有权限
 func (a AuthConfig) HasPermission(username string, rs resource.Id, action Action) bool {
 
@@ -56,15 +92,11 @@ func (a AuthConfig) HasPermission(username string, rs resource.Id, action Action
 	if len(a.Users) == 0 {
 		return false
 	}
-	for _, user := range a.Users {
-		if user.Username == username {
-			for _, permission := range user.Permissions {
-				if permission.Resource == rs || permission.Resource == resource.All {
-					for _, a := range permission.Actions {
-						if a == ActionAll || a == action {
-							return true
-						}
-					}
+	for _, permission := range a.Persmissions(username) {
+		if permission.Resource == rs || permission.Resource == resource.All {
+			for _, a := range permission.Actions {
+				if a == ActionAll || a == action {
+					return true
 				}
 			}
 		}
@@ -82,16 +114,30 @@ func (a AuthConfig) Persmissions(username string) PermissionConfigs {
 	}
 	for _, user := range a.Users {
 		if user.Username == username {
-			return user.Permissions
+			permissions := make(PermissionConfigs, 0, len(user.Permissions))
+			permissions = append(permissions, user.Permissions...)
+			for _, role := range user.Roles {
+				permissions = append(permissions, a.RolePermissions(role)...)
+			}
+			return permissions
 		}
 	}
 	return nil
 }
 
+// RolePermissions 获取角色的权限，优先使用自定义角色，其次是内置角色
+func (a AuthConfig) RolePermissions(role string) PermissionConfigs {
+	if permissions, ok := a.Roles[role]; ok {
+		return permissions
+	}
+	return DefaultRoles()[role]
+}
+
 type UserConfig struct {
 	Username    string
 	Password    string
 	Permissions PermissionConfigs
+	Roles       []string // 用户的角色，角色的权限会合并到用户的权限中
 }
 
 type PermissionConfig struct {
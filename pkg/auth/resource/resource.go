@@ -2,26 +2,98 @@ package resource
 
 type Id string
 
+// 节点资源
+var Node Id = "node"
+
 // 槽位资源
 var Slot = slot{
+	Info:    "slot",        // 槽位信息
 	Migrate: "slotMigrate", // 迁移槽位
 }
 
 // 频道资源
 var ClusterChannel = channel{
+	Config:  "clusterchannelConfig",  // 频道分布式配置（包含副本和状态信息）
 	Migrate: "clusterchannelMigrate", // 迁移频道
 	Start:   "clusterchannelStart",   // 启动频道
 	Stop:    "clusterchannelStop",    // 停止频道
 }
 
+// 频道数据资源
+var Channel = channelData{
+	Info:       "channel",           // 频道信息
+	Subscriber: "channelSubscriber", // 频道订阅者
+	Denylist:   "channelDenylist",   // 频道黑名单
+	Allowlist:  "channelAllowlist",  // 频道白名单
+}
+
+// 消息资源
+var Message Id = "message"
+
+// 用户资源
+var User Id = "user"
+
+// 设备资源
+var Device Id = "device"
+
+// 最近会话资源
+var Conversation Id = "conversation"
+
+// 集群资源
+var Cluster = cluster{
+	Info: "clusterInfo", // 集群信息
+	Log:  "clusterLog",  // 节点日志
+}
+
+// 连接资源（连接信息，踢出连接等）
+var Conn Id = "conn"
+
+// 系统变量资源
+var Varz Id = "varz"
+
+// 标签资源
+var Tag Id = "tag"
+
+// 审计日志资源
+var AuditLog Id = "auditLog"
+
+// 压测资源
+var Stress Id = "stress"
+
+// api key资源
+var ApiKey = apiKey{
+	Manage: "apikeyManage", // 管理api key
+}
+
+// 连接的ip规则资源（黑白名单）
+var IpRule Id = "ipRule"
+
 type slot struct {
+	Info    Id
 	Migrate Id
 }
 
 type channel struct {
+	Config  Id
 	Migrate Id
 	Start   Id
 	Stop    Id
 }
 
+type channelData struct {
+	Info       Id
+	Subscriber Id
+	Denylist   Id
+	Allowlist  Id
+}
+
+type cluster struct {
+	Info Id
+	Log  Id
+}
+
+type apiKey struct {
+	Manage Id
+}
+
 var All Id = "*"
@@ -0,0 +1,31 @@
+package auth
+
+import "github.com/WuKongIM/WuKongIM/pkg/auth/resource"
+
+// 内置角色
+const (
+	RoleViewer   = "viewer"   // 只读角色，可以查看所有资源
+	RoleOperator = "operator" // 运维角色，可以查看所有资源，并且可以执行迁移、启停频道、踢出连接等运维操作
+	RoleAdmin    = "admin"    // 管理员角色，拥有所有权限
+)
+
+// DefaultRoles 内置角色的权限
+func DefaultRoles() map[string]PermissionConfigs {
+	return map[string]PermissionConfigs{
+		RoleViewer: {
+			{Resource: resource.All, Actions: Actions{ActionRead}},
+		},
+		RoleOperator: {
+			{Resource: resource.All, Actions: Actions{ActionRead}},
+			{Resource: resource.Slot.Migrate, Actions: Actions{ActionWrite}},
+			{Resource: resource.ClusterChannel.Migrate, Actions: Actions{ActionWrite}},
+			{Resource: resource.ClusterChannel.Start, Actions: Actions{ActionWrite}},
+			{Resource: resource.ClusterChannel.Stop, Actions: Actions{ActionWrite}},
+			{Resource: resource.Conn, Actions: Actions{ActionWrite}},
+			{Resource: resource.Tag, Actions: Actions{ActionWrite}},
+		},
+		RoleAdmin: {
+			{Resource: resource.All, Actions: Actions{ActionAll}},
+		},
+	}
+}
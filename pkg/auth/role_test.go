@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/auth/resource"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRolePermissions(t *testing.T) {
+	a := AuthConfig{
+		On: true,
+		Roles: map[string]PermissionConfigs{
+			RoleViewer: {{Resource: resource.Conn, Actions: Actions{ActionRead}}}, // 覆盖内置角色
+			"tagger":   {{Resource: resource.Tag, Actions: Actions{ActionAll}}},
+		},
+	}
+
+	assert.Equal(t, PermissionConfigs{{Resource: resource.Conn, Actions: Actions{ActionRead}}}, a.RolePermissions(RoleViewer))
+	assert.Equal(t, DefaultRoles()[RoleAdmin], a.RolePermissions(RoleAdmin))
+	assert.Equal(t, PermissionConfigs{{Resource: resource.Tag, Actions: Actions{ActionAll}}}, a.RolePermissions("tagger"))
+	assert.Nil(t, a.RolePermissions("unknown"))
+}
+
+func TestHasPermissionWithRoles(t *testing.T) {
+	a := AuthConfig{
+		On: true,
+		Roles: map[string]PermissionConfigs{
+			"tagger": {{Resource: resource.Tag, Actions: Actions{ActionWrite}}},
+		},
+		Users: []UserConfig{
+			{Username: "viewer", Roles: []string{RoleViewer}},
+			{Username: "operator", Roles: []string{RoleOperator}},
+			{Username: "admin", Roles: []string{RoleAdmin}},
+			{
+				Username:    "mixed",
+				Roles:       []string{RoleViewer, "tagger", "unknown"},
+				Permissions: PermissionConfigs{{Resource: resource.Conn, Actions: Actions{ActionWrite}}},
+			},
+		},
+	}
+
+	tests := []struct {
+		username string
+		resource resource.Id
+		action   Action
+		want     bool
+	}{
+		{"viewer", resource.Node, ActionRead, true},
+		{"viewer", resource.Node, ActionWrite, false},
+		{"viewer", resource.All, ActionRead, true},
+		{"operator", resource.Slot.Migrate, ActionWrite, true},
+		{"operator", resource.ClusterChannel.Stop, ActionWrite, true},
+		{"operator", resource.Conn, ActionWrite, true},
+		{"operator", resource.ApiKey.Manage, ActionWrite, false},
+		{"operator", resource.All, ActionWrite, false},
+		{"admin", resource.ApiKey.Manage, ActionWrite, true},
+		{"admin", resource.All, ActionWrite, true},
+		{"mixed", resource.Tag, ActionWrite, true},  // 自定义角色
+		{"mixed", resource.Conn, ActionWrite, true}, // 用户自己的权限
+		{"mixed", resource.Varz, ActionRead, true},  // 内置角色
+		{"mixed", resource.Varz, ActionWrite, false},
+		{"nobody", resource.Node, ActionRead, false},
+		{"", resource.Node, ActionRead, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.username+" "+string(tt.resource)+" "+string(tt.action), func(t *testing.T) {
+			assert.Equal(t, tt.want, a.HasPermission(tt.username, tt.resource, tt.action))
+		})
+	}
+
+	// 没有开启鉴权时都有权限
+	a.On = false
+	assert.True(t, a.HasPermission("nobody", resource.All, ActionWrite))
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/WuKongIM/WuKongIM/pkg/auth/resource"
+	"github.com/WuKongIM/WuKongIM/pkg/wkhttp"
+)
+
+// RoutePermission 接口需要的权限
+type RoutePermission struct {
+	Resource resource.Id // 资源
+	Action   Action      // 操作
+}
+
+// RoutePermissions 接口权限表 key为 RouteKey(method, path)
+type RoutePermissions map[string]RoutePermission
+
+// RouteKey 接口权限表的key，path为路由注册时的路径（包含参数占位符，比如 /cluster/nodes/:id）
+func RouteKey(method string, path string) string {
+	return strings.ToUpper(method) + " " + path
+}
+
+// Add 添加接口权限
+func (r RoutePermissions) Add(method string, path string, rs resource.Id, action Action) {
+	r[RouteKey(method, path)] = RoutePermission{
+		Resource: rs,
+		Action:   action,
+	}
+}
+
+// Merge 合并接口权限表
+func (r RoutePermissions) Merge(other RoutePermissions) {
+	for k, v := range other {
+		r[k] = v
+	}
+}
+
+// Get 获取接口权限，没有登记的接口需要拥有所有资源（*）的权限，GET请求为读操作，其他请求为写操作
+func (r RoutePermissions) Get(method string, path string) RoutePermission {
+	if p, ok := r[RouteKey(method, path)]; ok {
+		return p
+	}
+	action := ActionWrite
+	if method == http.MethodGet {
+		action = ActionRead
+	}
+	return RoutePermission{
+		Resource: resource.All,
+		Action:   action,
+	}
+}
+
+// Middleware 接口鉴权中间件，根据接口权限表统一判断登录用户是否有权限
+// skip 返回true的请求不做判断（比如登录接口）
+func (a AuthConfig) Middleware(routes RoutePermissions, skip func(c *wkhttp.Context) bool) wkhttp.HandlerFunc {
+	return func(c *wkhttp.Context) {
+		if !a.On || (skip != nil && skip(c)) {
+			c.Next()
+			return
+		}
+		p := routes.Get(c.Request.Method, c.FullPath())
+		if !a.HasPermissionWithContext(c, p.Resource, p.Action) {
+			c.ResponseStatus(http.StatusUnauthorized)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
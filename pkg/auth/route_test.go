@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/auth/resource"
+	"github.com/WuKongIM/WuKongIM/pkg/wkhttp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoutePermissionsGet(t *testing.T) {
+	routes := RoutePermissions{}
+	routes.Add(http.MethodGet, "/connz", resource.Conn, ActionRead)
+	routes.Add("post", "/conn/kick", resource.Conn, ActionWrite)
+	routes.Add(http.MethodGet, "/cluster/nodes/:id", resource.Node, ActionRead)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		want   RoutePermission
+	}{
+		{"registered", http.MethodGet, "/connz", RoutePermission{resource.Conn, ActionRead}},
+		{"method case insensitive", http.MethodPost, "/conn/kick", RoutePermission{resource.Conn, ActionWrite}},
+		{"path with param", http.MethodGet, "/cluster/nodes/:id", RoutePermission{resource.Node, ActionRead}},
+		{"method not registered", http.MethodPost, "/connz", RoutePermission{resource.All, ActionWrite}},
+		{"unregistered get", http.MethodGet, "/unknown", RoutePermission{resource.All, ActionRead}},
+		{"unregistered post", http.MethodPost, "/unknown", RoutePermission{resource.All, ActionWrite}},
+		{"unregistered delete", http.MethodDelete, "/unknown", RoutePermission{resource.All, ActionWrite}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, routes.Get(tt.method, tt.path))
+		})
+	}
+}
+
+func TestRoutePermissionsMerge(t *testing.T) {
+	routes := RoutePermissions{}
+	routes.Add(http.MethodGet, "/a", resource.Conn, ActionRead)
+
+	other := RoutePermissions{}
+	other.Add(http.MethodGet, "/a", resource.Tag, ActionRead)
+	other.Add(http.MethodGet, "/b", resource.Varz, ActionRead)
+
+	routes.Merge(other)
+	assert.Equal(t, RoutePermission{resource.Tag, ActionRead}, routes.Get(http.MethodGet, "/a"))
+	assert.Equal(t, RoutePermission{resource.Varz, ActionRead}, routes.Get(http.MethodGet, "/b"))
+}
+
+func TestMiddleware(t *testing.T) {
+	a := AuthConfig{
+		On: true,
+		Users: []UserConfig{
+			{Username: "viewer", Roles: []string{RoleViewer}},
+			{Username: "operator", Roles: []string{RoleOperator}},
+			{Username: "admin", Roles: []string{RoleAdmin}},
+			{Username: "conn", Permissions: PermissionConfigs{{Resource: resource.Conn, Actions: Actions{ActionAll}}}},
+		},
+	}
+	routes := RoutePermissions{}
+	routes.Add(http.MethodGet, "/connz", resource.Conn, ActionRead)
+	routes.Add(http.MethodPost, "/conn/kick", resource.Conn, ActionWrite)
+	routes.Add(http.MethodPost, "/apikey/add", resource.ApiKey.Manage, ActionWrite)
+
+	newServer := func(username string) *wkhttp.WKHttp {
+		r := wkhttp.New()
+		r.Use(func(c *wkhttp.Context) {
+			c.Set("username", username)
+			c.Next()
+		})
+		r.Use(a.Middleware(routes, func(c *wkhttp.Context) bool {
+			return c.Request.URL.Path == "/login"
+		}))
+		ok := func(c *wkhttp.Context) { c.ResponseOK() }
+		r.GET("/connz", ok)
+		r.POST("/conn/kick", ok)
+		r.POST("/apikey/add", ok)
+		r.GET("/unregistered", ok)
+		r.POST("/unregistered", ok)
+		r.POST("/login", ok)
+		return r
+	}
+
+	tests := []struct {
+		username string
+		method   string
+		path     string
+		want     int
+	}{
+		{"viewer", http.MethodGet, "/connz", http.StatusOK},
+		{"viewer", http.MethodPost, "/conn/kick", http.StatusUnauthorized},
+		{"viewer", http.MethodGet, "/unregistered", http.StatusOK}, // 只读角色拥有所有资源的读权限
+		{"viewer", http.MethodPost, "/unregistered", http.StatusUnauthorized},
+		{"operator", http.MethodPost, "/conn/kick", http.StatusOK},
+		{"operator", http.MethodPost, "/apikey/add", http.StatusUnauthorized},
+		{"operator", http.MethodPost, "/unregistered", http.StatusUnauthorized},
+		{"admin", http.MethodPost, "/apikey/add", http.StatusOK},
+		{"admin", http.MethodPost, "/unregistered", http.StatusOK},
+		{"conn", http.MethodPost, "/conn/kick", http.StatusOK},
+		{"conn", http.MethodGet, "/unregistered", http.StatusUnauthorized}, // 没有登记的接口需要所有资源（*）的权限
+		{"unknown", http.MethodGet, "/connz", http.StatusUnauthorized},
+		{"", http.MethodPost, "/login", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.username+" "+tt.method+" "+tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			newServer(tt.username).ServeHTTP(w, req)
+			var resp struct {
+				Status int `json:"status"`
+			}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, tt.want, resp.Status)
+		})
+	}
+}
@@ -5,4 +5,6 @@ import "errors"
 var (
 	ErrNoAllowVoteNode = errors.New("no allow vote node")
 	ErrNoLeader        = errors.New("no leader")
+	// 频道的日志就是消息本身，不会被压缩，所以不支持快照
+	ErrSnapshotNotSupported = errors.New("channel does not support snapshot")
 )
@@ -28,6 +28,7 @@ func (s *Server) ProposeBatchUntilAppliedTimeout(ctx context.Context, channelId
 	// ========== 如果当前节点存在频道的raft，则直接提按 ==========
 	raft := rg.GetRaft(channelKey)
 	if raft != nil && raft.IsLeader() {
+		s.proposeCount.Add(int64(len(reqs)))
 		return rg.ProposeBatchUntilAppliedTimeout(ctx, channelKey, reqs)
 	}
 
@@ -44,6 +45,7 @@ func (s *Server) ProposeBatchUntilAppliedTimeout(ctx context.Context, channelId
 		if err != nil {
 			return nil, err
 		}
+		s.proposeCount.Add(int64(len(reqs)))
 		return rg.ProposeBatchUntilAppliedTimeout(ctx, channelKey, reqs)
 	}
 
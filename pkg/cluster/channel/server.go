@@ -1,7 +1,9 @@
 package channel
 
 import (
+	"context"
 	"sync"
+	"sync/atomic"
 
 	"github.com/WuKongIM/WuKongIM/pkg/fasthash"
 	"github.com/WuKongIM/WuKongIM/pkg/raft/raftgroup"
@@ -26,6 +28,8 @@ type Server struct {
 	}
 
 	wakeLeaderLock *ringlock.RingLock
+
+	proposeCount atomic.Int64 // 本节点作为频道领导提案的消息数量（用于统计节点负载）
 }
 
 func NewServer(opts *Options) *Server {
@@ -187,6 +191,12 @@ func (s *Server) AddEvent(channelKey string, e rafttype.Event) {
 
 }
 
+// TransferLeader 将频道的领导权转让给指定节点
+func (s *Server) TransferLeader(ctx context.Context, channelId string, channelType uint8, to uint64) error {
+	channelKey := wkutil.ChannelToKey(channelId, channelType)
+	return s.getRaftGroup(channelKey).TransferLeader(ctx, channelKey, to)
+}
+
 func (s *Server) getRaftGroup(channelKey string) *raftgroup.RaftGroup {
 	index := int(fasthash.Hash(channelKey) % uint32(s.opts.GroupCount))
 	return s.raftGroups[index]
@@ -201,6 +211,11 @@ func (s *Server) ChannelCount() int {
 	return count
 }
 
+// ProposeCount 本节点作为频道领导提案的消息总数量
+func (s *Server) ProposeCount() int64 {
+	return s.proposeCount.Load()
+}
+
 func (s *Server) ExistChannel(channelId string, channelType uint8) bool {
 	channelKey := wkutil.ChannelToKey(channelId, channelType)
 	rg := s.getRaftGroup(channelKey)
@@ -142,6 +142,19 @@ func (s *storage) DeleteLeaderTermStartIndexGreaterThanTerm(key string, term uin
 	return s.db.DeleteLeaderTermStartIndexGreaterThanTerm(key, term)
 }
 
+// GetSnapshot 频道的日志就是消息，不压缩日志，也就不需要快照
+func (s *storage) GetSnapshot(key string) (types.Snapshot, error) {
+	return types.Snapshot{}, ErrSnapshotNotSupported
+}
+
+func (s *storage) ApplySnapshot(key string, snapshot types.Snapshot) error {
+	return ErrSnapshotNotSupported
+}
+
+func (s *storage) CompactLogTo(key string, index uint64) error {
+	return ErrSnapshotNotSupported
+}
+
 func (s *storage) LastIndexAndAppendTime(shardNo string) (uint64, uint64, error) {
 	channelId, channelType := wkutil.ChannelFromlKey(shardNo)
 	lastMsgSeq, appendTime, err := s.db.GetChannelLastMessageSeq(channelId, channelType)
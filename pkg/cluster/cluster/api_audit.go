@@ -0,0 +1,179 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/network"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/WuKongIM/WuKongIM/pkg/wkhttp"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+// 搜索审计日志，审计日志通过slot复制到副本节点上，指定节点时只查询该节点上的日志，不指定节点时会查询所有在线节点
+func (s *Server) auditLogSearch(c *wkhttp.Context) {
+	// 搜索条件
+	limit := wkutil.ParseInt(c.Query("limit"))
+	actor := strings.TrimSpace(c.Query("actor"))
+	rsc := strings.TrimSpace(c.Query("resource"))
+	nodeId := wkutil.ParseUint64(c.Query("node_id"))
+	startAt := wkutil.ParseInt64(c.Query("start_at"))    // 开始时间（秒）
+	endAt := wkutil.ParseInt64(c.Query("end_at"))        // 结束时间（秒）
+	offsetId := wkutil.ParseUint64(c.Query("offset_id")) // 偏移id，查询比此id更早的日志
+
+	if limit <= 0 {
+		limit = s.opts.PageSize
+	}
+
+	var searchLocalAuditLogs = func() (*auditLogRespTotal, error) {
+		req := wkdb.AuditLogSearchReq{
+			Actor:    actor,
+			Resource: rsc,
+			OffsetId: offsetId,
+			Limit:    limit + 1, // 实际查询出来的数据比limit多1，用于判断是否有下一页
+		}
+		if startAt > 0 {
+			req.StartAt = time.Unix(startAt, 0).UnixNano()
+		}
+		if endAt > 0 {
+			req.EndAt = time.Unix(endAt, 0).UnixNano()
+		}
+		logs, err := s.db.SearchAuditLog(req)
+		if err != nil {
+			s.Error("search audit log failed", zap.Error(err))
+			return nil, err
+		}
+		resps := make([]*auditLogResp, 0, len(logs))
+		for _, log := range logs {
+			resps = append(resps, newAuditLogResp(log))
+		}
+		return &auditLogRespTotal{
+			Data: resps,
+		}, nil
+	}
+
+	if nodeId == s.opts.ConfigOptions.NodeId {
+		result, err := searchLocalAuditLogs()
+		if err != nil {
+			c.ResponseError(err)
+			return
+		}
+		c.JSON(http.StatusOK, result)
+		return
+	}
+
+	// 先查询本节点，失败时还没有发起其他节点的请求
+	localResult, err := searchLocalAuditLogs()
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	resps := localResult.Data
+
+	nodes := s.cfgServer.Nodes()
+	timeoutCtx, cancel := context.WithTimeout(s.cancelCtx, s.opts.ReqTimeout)
+	defer cancel()
+
+	requestGroup, _ := errgroup.WithContext(timeoutCtx)
+
+	var resultLock sync.Mutex
+	for _, node := range nodes {
+		if node.Id == s.opts.ConfigOptions.NodeId {
+			continue
+		}
+		if !s.NodeIsOnline(node.Id) {
+			continue
+		}
+		requestGroup.Go(func(nId uint64, queryValues url.Values) func() error {
+			return func() error {
+				queryMap := map[string]string{}
+				for key, values := range queryValues {
+					if len(values) > 0 {
+						queryMap[key] = values[0]
+					}
+				}
+				result, err := s.requestAuditLogSearch(c.Request.URL.Path, nId, queryMap, c.CopyRequestHeader(c.Request))
+				if err != nil {
+					return err
+				}
+				resultLock.Lock()
+				resps = append(resps, result.Data...)
+				resultLock.Unlock()
+				return nil
+			}
+		}(node.Id, c.Request.URL.Query()))
+	}
+
+	err = requestGroup.Wait()
+	if err != nil {
+		s.Error("search audit log request failed", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+
+	// 审计日志会复制到slot的多个副本上，按id去重
+	resps = uniqueAuditLogResps(resps)
+
+	// 雪花id按时间递增，按id倒序即按时间倒序
+	sort.Slice(resps, func(i, j int) bool {
+		return resps[i].Id > resps[j].Id
+	})
+
+	hasMore := false
+	if len(resps) > limit {
+		hasMore = true
+		resps = resps[:limit]
+	}
+
+	c.JSON(http.StatusOK, auditLogRespTotal{
+		More: wkutil.BoolToInt(hasMore),
+		Data: resps,
+	})
+}
+
+func (s *Server) requestAuditLogSearch(path string, nodeId uint64, queryMap map[string]string, headers map[string]string) (*auditLogRespTotal, error) {
+	node := s.cfgServer.Node(nodeId)
+	if node == nil {
+		s.Error("requestAuditLogSearch failed, node not found", zap.Uint64("nodeId", nodeId))
+		return nil, errors.New("node not found")
+	}
+	fullUrl := fmt.Sprintf("%s%s", node.ApiServerAddr, path)
+	queryMap["node_id"] = fmt.Sprintf("%d", nodeId)
+	resp, err := network.Get(fullUrl, queryMap, headers)
+	if err != nil {
+		return nil, err
+	}
+	err = handlerIMError(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	var result *auditLogRespTotal
+	err = wkutil.ReadJSONByByte([]byte(resp.Body), &result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func uniqueAuditLogResps(resps []*auditLogResp) []*auditLogResp {
+	exists := make(map[uint64]struct{}, len(resps))
+	uniqueResps := make([]*auditLogResp, 0, len(resps))
+	for _, resp := range resps {
+		if _, ok := exists[resp.Id]; ok {
+			continue
+		}
+		exists[resp.Id] = struct{}{}
+		uniqueResps = append(uniqueResps, resp)
+	}
+	return uniqueResps
+}
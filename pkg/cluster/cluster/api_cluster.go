@@ -7,8 +7,6 @@ import (
 	"net/http"
 	"time"
 
-	"github.com/WuKongIM/WuKongIM/pkg/auth"
-	"github.com/WuKongIM/WuKongIM/pkg/auth/resource"
 	"github.com/WuKongIM/WuKongIM/pkg/network"
 	rafttype "github.com/WuKongIM/WuKongIM/pkg/raft/types"
 	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
@@ -53,38 +51,114 @@ func (s *Server) channelMigrate(c *wkhttp.Context) {
 		c.ResponseError(err)
 		return
 	}
-	if !wkutil.ArrayContainsUint64(clusterConfig.Replicas, req.MigrateFrom) {
-		c.ResponseError(errors.New("MigrateFrom not in replicas"))
+
+	err = s.migrateChannel(clusterConfig, req.MigrateFrom, req.MigrateTo)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+
+	// 如果目标节点不是当前节点，则发送最新配置给目标节点
+	// if req.MigrateTo != s.opts.ConfigOptions.NodeId {
+	// 	err = s.SendChannelClusterConfigUpdate(channelId, channelType, req.MigrateTo)
+	// 	if err != nil {
+	// 		s.Error("channelMigrate: sendChannelClusterConfigUpdate error", zap.Error(err))
+	// 		c.ResponseError(err)
+	// 		return
+	// 	}
+	// }
+	c.ResponseOK()
+
+}
+
+// 将频道的领导权转让给指定的副本节点
+func (s *Server) channelLeaderTransfer(c *wkhttp.Context) {
+	var req struct {
+		TransferTo uint64 `json:"transfer_to"` // 转让的目标节点
+	}
+	bodyBytes, err := BindJSON(&req, c)
+	if err != nil {
+		s.Error("BindJSON error", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+
+	channelId := c.Param("channel_id")
+	channelType := wkutil.ParseUint8(c.Param("channel_type"))
+
+	cfg, err := s.LoadOnlyChannelClusterConfig(channelId, channelType)
+	if err != nil {
+		s.Error("channelLeaderTransfer: loadOnlyChannelClusterConfig error", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	if cfg.LeaderId == 0 {
+		s.Error("leader not found", zap.String("cfg", cfg.String()))
+		c.ResponseError(errors.New("leader not found"))
+		return
+	}
+	if !wkutil.ArrayContainsUint64(cfg.Replicas, req.TransferTo) {
+		c.ResponseError(errors.New("transfer_to not in replicas"))
+		return
+	}
+	if cfg.LeaderId == req.TransferTo {
+		c.ResponseOK()
+		return
+	}
+	if cfg.LeaderId != s.opts.ConfigOptions.NodeId {
+		c.ForwardWithBody(fmt.Sprintf("%s%s", s.cfgServer.Node(cfg.LeaderId).ApiServerAddr, c.Request.URL.Path), bodyBytes)
 		return
 	}
 
-	if wkutil.ArrayContainsUint64(clusterConfig.Replicas, req.MigrateTo) && req.MigrateFrom != clusterConfig.LeaderId {
-		c.ResponseError(errors.New("transition between followers is not supported"))
+	// 频道可能还没唤醒，先唤醒频道领导
+	err = s.channelServer.WakeLeaderIfNeed(cfg)
+	if err != nil {
+		s.Error("channelLeaderTransfer: WakeLeaderIfNeed error", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(s.cancelCtx, time.Second*10)
+	defer cancel()
+	err = s.channelServer.TransferLeader(timeoutCtx, channelId, channelType, req.TransferTo)
+	if err != nil {
+		s.Error("channelLeaderTransfer: TransferLeader error", zap.Error(err), zap.String("channelId", channelId), zap.Uint8("channelType", channelType), zap.Uint64("transferTo", req.TransferTo))
+		c.ResponseError(err)
 		return
 	}
+	c.ResponseOK()
+}
+
+// migrateChannel 迁移频道副本（必须在频道所属槽的领导节点上调用）
+func (s *Server) migrateChannel(clusterConfig wkdb.ChannelClusterConfig, migrateFrom, migrateTo uint64) error {
+	if !wkutil.ArrayContainsUint64(clusterConfig.Replicas, migrateFrom) {
+		return errors.New("MigrateFrom not in replicas")
+	}
+
+	if wkutil.ArrayContainsUint64(clusterConfig.Replicas, migrateTo) && migrateFrom != clusterConfig.LeaderId {
+		return errors.New("transition between followers is not supported")
+	}
 
 	newClusterConfig := clusterConfig.Clone()
 	if newClusterConfig.MigrateFrom != 0 || newClusterConfig.MigrateTo != 0 {
-		c.ResponseError(errors.New("migrate is in progress"))
-		return
+		return errors.New("migrate is in progress")
 	}
 
 	// 保存配置
-	newClusterConfig.MigrateFrom = req.MigrateFrom
-	newClusterConfig.MigrateTo = req.MigrateTo
+	newClusterConfig.MigrateFrom = migrateFrom
+	newClusterConfig.MigrateTo = migrateTo
 	newClusterConfig.ConfVersion = uint64(time.Now().UnixNano())
 
-	if !wkutil.ArrayContainsUint64(clusterConfig.Replicas, req.MigrateTo) {
+	if !wkutil.ArrayContainsUint64(clusterConfig.Replicas, migrateTo) {
 		// 将要目标节点加入学习者中
-		newClusterConfig.Learners = append(newClusterConfig.Learners, req.MigrateTo)
+		newClusterConfig.Learners = append(newClusterConfig.Learners, migrateTo)
 	}
 
 	// 提案保存配置
 	version, err := s.store.SaveChannelClusterConfig(newClusterConfig)
 	if err != nil {
-		s.Error("channelMigrate: Save error", zap.Error(err))
-		c.ResponseError(err)
-		return
+		s.Error("migrateChannel: Save error", zap.Error(err))
+		return err
 	}
 	newClusterConfig.ConfVersion = version
 
@@ -92,30 +166,17 @@ func (s *Server) channelMigrate(c *wkhttp.Context) {
 	if newClusterConfig.LeaderId != s.opts.ConfigOptions.NodeId {
 		err = s.rpcClient.RequestWakeLeaderIfNeed(newClusterConfig.LeaderId, newClusterConfig)
 		if err != nil {
-			s.Error("channelMigrate: RequestWakeLeaderIfNeed error", zap.Error(err))
-			c.ResponseError(err)
-			return
+			s.Error("migrateChannel: RequestWakeLeaderIfNeed error", zap.Error(err))
+			return err
 		}
 	} else {
 		err = s.channelServer.WakeLeaderIfNeed(newClusterConfig)
 		if err != nil {
-			s.Error("channelMigrate: WakeLeaderIfNeed error", zap.Error(err))
-			c.ResponseError(err)
-			return
+			s.Error("migrateChannel: WakeLeaderIfNeed error", zap.Error(err))
+			return err
 		}
 	}
-
-	// 如果目标节点不是当前节点，则发送最新配置给目标节点
-	// if req.MigrateTo != s.opts.ConfigOptions.NodeId {
-	// 	err = s.SendChannelClusterConfigUpdate(channelId, channelType, req.MigrateTo)
-	// 	if err != nil {
-	// 		s.Error("channelMigrate: sendChannelClusterConfigUpdate error", zap.Error(err))
-	// 		c.ResponseError(err)
-	// 		return
-	// 	}
-	// }
-	c.ResponseOK()
-
+	return nil
 }
 
 func (s *Server) channelClusterConfig(c *wkhttp.Context) {
@@ -164,11 +225,6 @@ func (s *Server) channelClusterConfig(c *wkhttp.Context) {
 
 func (s *Server) channelStart(c *wkhttp.Context) {
 
-	if !s.opts.Auth.HasPermissionWithContext(c, resource.ClusterChannel.Start, auth.ActionWrite) {
-		c.ResponseStatus(http.StatusUnauthorized)
-		return
-	}
-
 	channelId := c.Param("channel_id")
 	channelType := wkutil.ParseUint8(c.Param("channel_type"))
 
@@ -201,11 +257,6 @@ func (s *Server) channelStart(c *wkhttp.Context) {
 
 func (s *Server) channelStop(c *wkhttp.Context) {
 
-	if !s.opts.Auth.HasPermissionWithContext(c, resource.ClusterChannel.Stop, auth.ActionWrite) {
-		c.ResponseStatus(http.StatusUnauthorized)
-		return
-	}
-
 	channelId := c.Param("channel_id")
 	channelType := wkutil.ParseUint8(c.Param("channel_type"))
 
@@ -604,3 +655,105 @@ func (s *Server) clusterInfoGet(c *wkhttp.Context) {
 	cfg := s.cfgServer.GetClusterConfig()
 	c.JSON(http.StatusOK, cfg)
 }
+
+// 将集群配置的领导权转让给指定节点（滚动重启配置领导前调用，避免等待选举超时）
+func (s *Server) clusterLeaderTransfer(c *wkhttp.Context) {
+	var req struct {
+		TransferTo uint64 `json:"transfer_to"` // 转让的目标节点
+	}
+	bodyBytes, err := BindJSON(&req, c)
+	if err != nil {
+		s.Error("BindJSON error", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+
+	leaderId := s.cfgServer.LeaderId()
+	if leaderId == 0 {
+		c.ResponseError(errors.New("leader not found"))
+		return
+	}
+	if leaderId == req.TransferTo {
+		c.ResponseOK()
+		return
+	}
+	if leaderId != s.opts.ConfigOptions.NodeId {
+		leaderNode := s.cfgServer.Node(leaderId)
+		c.ForwardWithBody(fmt.Sprintf("%s%s", leaderNode.ApiServerAddr, c.Request.URL.Path), bodyBytes)
+		return
+	}
+	node := s.cfgServer.Node(req.TransferTo)
+	if node == nil || !node.AllowVote || !node.Online {
+		c.ResponseError(errors.New("transfer_to is not an online voter"))
+		return
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(s.cancelCtx, time.Second*10)
+	defer cancel()
+	err = s.cfgServer.TransferLeader(timeoutCtx, req.TransferTo)
+	if err != nil {
+		s.Error("clusterLeaderTransfer: TransferLeader error", zap.Error(err), zap.Uint64("transferTo", req.TransferTo))
+		c.ResponseError(err)
+		return
+	}
+	c.ResponseOK()
+}
+
+// 获取频道副本均衡状态和各节点的负载
+func (s *Server) channelBalanceGet(c *wkhttp.Context) {
+	loads := s.getNodeLoads()
+	nodes := make([]*NodeLoad, 0, len(loads))
+	for _, node := range s.cfgServer.AllowVoteNodes() {
+		if load := loads[node.Id]; load != nil {
+			nodes = append(nodes, load)
+		}
+	}
+	c.JSON(http.StatusOK, &channelBalanceResp{
+		On:            wkutil.BoolToInt(s.channelBalanceOn.Load()),
+		Threshold:     s.opts.ChannelBalance.Threshold,
+		MaxMigrations: s.opts.ChannelBalance.MaxMigrations,
+		Nodes:         nodes,
+	})
+}
+
+// 开启或关闭频道副本均衡（会同步到所有在线节点，重启后恢复为配置文件中的设置）
+func (s *Server) channelBalanceSet(c *wkhttp.Context) {
+	var req struct {
+		On    bool `json:"on"`    // 是否开启
+		Local int  `json:"local"` // 是否只设置本节点
+	}
+	if err := c.BindJSON(&req); err != nil {
+		s.Error("bind json error", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+
+	s.SetChannelBalance(req.On)
+	if req.Local == 1 {
+		c.ResponseOK()
+		return
+	}
+
+	headers := c.CopyRequestHeader(c.Request)
+	for _, node := range s.cfgServer.Nodes() {
+		if node.Id == s.opts.ConfigOptions.NodeId || !node.Online {
+			continue
+		}
+		fullUrl := fmt.Sprintf("%s%s", node.ApiServerAddr, s.formatPath("/channels/balance"))
+		resp, err := network.Post(fullUrl, []byte(wkutil.ToJSON(map[string]interface{}{
+			"on":    req.On,
+			"local": 1,
+		})), headers)
+		if err != nil {
+			s.Error("channelBalanceSet: request node error", zap.Error(err), zap.Uint64("nodeId", node.Id))
+			c.ResponseError(err)
+			return
+		}
+		if resp.StatusCode != http.StatusOK {
+			s.Error("channelBalanceSet: request node failed", zap.Int("statusCode", resp.StatusCode), zap.Uint64("nodeId", node.Id))
+			c.ResponseError(fmt.Errorf("set channel balance failed, nodeId: %d", node.Id))
+			return
+		}
+	}
+	c.ResponseOK()
+}
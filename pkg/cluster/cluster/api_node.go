@@ -11,6 +11,8 @@ import (
 	"sync"
 	"time"
 
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/node/types"
+	"github.com/WuKongIM/WuKongIM/pkg/network"
 	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
 	"github.com/WuKongIM/WuKongIM/pkg/wkhttp"
 	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
@@ -299,3 +301,218 @@ func (s *Server) nodeChannelsGet(c *wkhttp.Context) {
 		Data:    channelClusterConfigResps,
 	})
 }
+
+// 下线节点（将节点标记为离开中，之后节点上的槽和频道会逐步迁移到其他节点）
+func (s *Server) nodeDrain(c *wkhttp.Context) {
+	id := wkutil.ParseUint64(c.Param("id"))
+
+	node := s.cfgServer.Node(id)
+	if node == nil {
+		s.Error("nodeDrain: node not found", zap.Uint64("nodeId", id))
+		c.ResponseError(errors.New("node not found"))
+		return
+	}
+	if node.Status == types.NodeStatus_NodeStatusLeaving {
+		c.ResponseOK()
+		return
+	}
+	if node.Status != types.NodeStatus_NodeStatusJoined {
+		c.ResponseError(errors.New("node not joined"))
+		return
+	}
+
+	// 每个包含此节点的槽都必须有可迁入的节点
+	targetNodes := s.cfgServer.AllowVoteAndJoinedOnlineNodes()
+	for _, slot := range s.cfgServer.Slots() {
+		if !wkutil.ArrayContainsUint64(slot.Replicas, id) {
+			continue
+		}
+		hasTarget := false
+		for _, targetNode := range targetNodes {
+			if targetNode.Id != id && !wkutil.ArrayContainsUint64(slot.Replicas, targetNode.Id) {
+				hasTarget = true
+				break
+			}
+		}
+		if !hasTarget {
+			c.ResponseError(fmt.Errorf("slot[%d] has no node to migrate to", slot.Id))
+			return
+		}
+	}
+
+	err := s.cfgServer.ProposeNodeStatus(id, types.NodeStatus_NodeStatusLeaving)
+	if err != nil {
+		s.Error("nodeDrain: ProposeNodeStatus error", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	c.ResponseOK()
+}
+
+// 节点下线进度
+func (s *Server) nodeDrainGet(c *wkhttp.Context) {
+	id := wkutil.ParseUint64(c.Param("id"))
+
+	// 只查询本节点负责迁移的频道数量
+	if wkutil.ParseInt(c.Query("local")) == 1 {
+		channelCount, err := s.localDrainChannelCount(id)
+		if err != nil {
+			s.Error("nodeDrainGet: localDrainChannelCount error", zap.Error(err))
+			c.ResponseError(err)
+			return
+		}
+		c.JSON(http.StatusOK, NodeDrainResp{
+			NodeId:       id,
+			ChannelCount: channelCount,
+		})
+		return
+	}
+
+	resp, err := s.nodeDrainProgress(id, c.CopyRequestHeader(c.Request))
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// 移除节点（节点必须已经下线完成）
+func (s *Server) nodeRemove(c *wkhttp.Context) {
+	id := wkutil.ParseUint64(c.Param("id"))
+
+	if id == s.cfgServer.LeaderId() {
+		c.ResponseError(errors.New("node is the leader of cluster config, can not be removed"))
+		return
+	}
+
+	resp, err := s.nodeDrainProgress(id, c.CopyRequestHeader(c.Request))
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if resp.Status != types.NodeStatus_NodeStatusLeaving {
+		c.ResponseError(errors.New("node is not leaving, drain it first"))
+		return
+	}
+	if resp.Done != 1 {
+		c.ResponseError(fmt.Errorf("node is draining, remaining slots: %d, remaining channels: %d, slots with unknown channel count: %d", resp.SlotCount, resp.ChannelCount, resp.UnknownSlotCount))
+		return
+	}
+
+	err = s.cfgServer.ProposeNodeRemove(id)
+	if err != nil {
+		s.Error("nodeRemove: ProposeNodeRemove error", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	c.ResponseOK()
+}
+
+// 获取节点的下线进度（频道数量需要汇总所有槽领导节点的数据）
+func (s *Server) nodeDrainProgress(nodeId uint64, headers map[string]string) (*NodeDrainResp, error) {
+	node := s.cfgServer.Node(nodeId)
+	if node == nil {
+		s.Error("node not found", zap.Uint64("nodeId", nodeId))
+		return nil, errors.New("node not found")
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(s.cancelCtx, time.Second*10)
+	defer cancel()
+	requestGroup, _ := errgroup.WithContext(timeoutCtx)
+
+	cfg := s.cfgServer.GetClusterConfig()
+	leaderIds, unknownSlotCount := drainSlotLeaders(s.opts.ConfigOptions.NodeId, cfg)
+
+	var (
+		channelCount     int
+		channelCountLock sync.Mutex
+	)
+	for _, leaderId := range leaderIds {
+		requestGroup.Go(func(nId uint64) func() error {
+			return func() error {
+				var (
+					count int
+					err   error
+				)
+				if nId == s.opts.ConfigOptions.NodeId {
+					count, err = s.localDrainChannelCount(nodeId)
+				} else {
+					count, err = s.requestDrainChannelCount(nId, nodeId, headers)
+				}
+				if err != nil {
+					return err
+				}
+				channelCountLock.Lock()
+				channelCount += count
+				channelCountLock.Unlock()
+				return nil
+			}
+		}(leaderId))
+	}
+	err := requestGroup.Wait()
+	if err != nil {
+		s.Error("nodeDrainProgress: request channel count error", zap.Error(err))
+		return nil, err
+	}
+
+	nodeCfg := NewNodeConfigFromNode(node)
+	slotCount := s.drainSlotCount(nodeId, cfg)
+	return &NodeDrainResp{
+		NodeId:           nodeId,
+		Status:           node.Status,
+		StatusFormat:     nodeCfg.StatusFormat,
+		SlotCount:        slotCount,
+		ChannelCount:     channelCount,
+		UnknownSlotCount: unknownSlotCount,
+		Done:             wkutil.BoolToInt(drainDone(node.Status, slotCount, channelCount, unknownSlotCount)),
+	}, nil
+}
+
+// 频道由所属槽的槽领导负责迁移，所以需要向每个槽领导查询频道数量
+// 返回需要查询的槽领导，和槽领导离线（或者没有领导）的槽数量，这些槽里还有多少频道是未知的，不能认为已迁移完成
+func drainSlotLeaders(localNodeId uint64, cfg *types.Config) ([]uint64, int) {
+	onlineMap := make(map[uint64]bool, len(cfg.Nodes))
+	for _, n := range cfg.Nodes {
+		onlineMap[n.Id] = n.Online
+	}
+	leaderIds := make([]uint64, 0)
+	unknownSlotCount := 0
+	for _, st := range cfg.Slots {
+		if st.Leader == 0 || (st.Leader != localNodeId && !onlineMap[st.Leader]) {
+			unknownSlotCount++
+			continue
+		}
+		if !wkutil.ArrayContainsUint64(leaderIds, st.Leader) {
+			leaderIds = append(leaderIds, st.Leader)
+		}
+	}
+	return leaderIds, unknownSlotCount
+}
+
+// 下线是否完成，有槽的频道数量未知时不算完成
+func drainDone(status types.NodeStatus, slotCount, channelCount, unknownSlotCount int) bool {
+	return status == types.NodeStatus_NodeStatusLeaving && slotCount == 0 && channelCount == 0 && unknownSlotCount == 0
+}
+
+// 请求指定节点负责迁移的频道数量
+func (s *Server) requestDrainChannelCount(toNodeId uint64, nodeId uint64, headers map[string]string) (int, error) {
+	node := s.cfgServer.Node(toNodeId)
+	if node == nil {
+		return 0, errors.New("node not found")
+	}
+	resp, err := network.Get(fmt.Sprintf("%s%s", node.ApiServerAddr, s.formatPath(fmt.Sprintf("/nodes/%d/drain", nodeId))), map[string]string{
+		"local": "1",
+	}, headers)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("request drain channel count failed, nodeId: %d, body: %s", toNodeId, resp.Body)
+	}
+	drainResp := &NodeDrainResp{}
+	err = wkutil.ReadJSONByByte([]byte(resp.Body), drainResp)
+	if err != nil {
+		return 0, err
+	}
+	return drainResp.ChannelCount, nil
+}
@@ -10,8 +10,7 @@ import (
 	"strings"
 	"time"
 
-	"github.com/WuKongIM/WuKongIM/pkg/auth"
-	"github.com/WuKongIM/WuKongIM/pkg/auth/resource"
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/node/types"
 	"github.com/WuKongIM/WuKongIM/pkg/wkhttp"
 	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
 	"go.uber.org/zap"
@@ -137,17 +136,14 @@ func (s *Server) slotClusterConfigGet(c *wkhttp.Context) {
 // 	})
 // }
 
+// 迁移槽副本
+// 注意：以前此接口只校验参数，并不会真正迁移；现在校验通过后会提案CMDTypeSlotMigrate，槽会被实际迁移（管理后台的槽迁移操作也会生效）
 func (s *Server) slotMigrate(c *wkhttp.Context) {
 	var req struct {
 		MigrateFrom uint64 `json:"migrate_from"` // 迁移的原节点
 		MigrateTo   uint64 `json:"migrate_to"`   // 迁移的目标节点
 	}
 
-	if !s.opts.Auth.HasPermissionWithContext(c, resource.Slot.Migrate, auth.ActionWrite) {
-		c.ResponseStatus(http.StatusUnauthorized)
-		return
-	}
-
 	bodyBytes, err := BindJSON(&req, c)
 	if err != nil {
 		s.Error("bind json error", zap.Error(err))
@@ -176,34 +172,89 @@ func (s *Server) slotMigrate(c *wkhttp.Context) {
 		return
 	}
 
-	if !wkutil.ArrayContainsUint64(slot.Replicas, req.MigrateFrom) {
-		c.ResponseError(errors.New("MigrateFrom not in replicas"))
+	err = s.migrateSlot(slot, req.MigrateFrom, req.MigrateTo)
+	if err != nil {
+		s.Error("slotMigrate: migrateSlot error", zap.Error(err))
+		c.ResponseError(err)
 		return
 	}
-	if wkutil.ArrayContainsUint64(slot.Replicas, req.MigrateTo) && req.MigrateFrom != slot.Leader {
-		c.ResponseError(errors.New("transition between followers is not supported"))
+
+	c.ResponseOK()
+
+}
+
+// 将槽的领导权转让给指定的副本节点（领导等目标副本追上日志后再移交，不需要等选举超时）
+func (s *Server) slotLeaderTransfer(c *wkhttp.Context) {
+	var req struct {
+		TransferTo uint64 `json:"transfer_to"` // 转让的目标节点
+	}
+	bodyBytes, err := BindJSON(&req, c)
+	if err != nil {
+		s.Error("bind json error", zap.Error(err))
+		c.ResponseError(err)
 		return
 	}
+	id := wkutil.ParseUint32(c.Param("id"))
 
-	if req.MigrateFrom == 0 || req.MigrateTo == 0 {
-		c.ResponseError(errors.New("migrateFrom or migrateTo is 0"))
+	slot := s.cfgServer.Slot(id)
+	if slot == nil {
+		s.Error("slot not found", zap.Uint32("slotId", id))
+		c.ResponseError(errors.New("slot not found"))
 		return
 	}
-
-	if req.MigrateFrom == req.MigrateTo {
-		c.ResponseError(errors.New("migrateFrom is equal to migrateTo"))
+	if !wkutil.ArrayContainsUint64(slot.Replicas, req.TransferTo) {
+		c.ResponseError(errors.New("transfer_to not in replicas"))
+		return
+	}
+	if slot.Leader == req.TransferTo {
+		c.ResponseOK()
 		return
 	}
 
-	// err = s.clusterEventServer.ProposeMigrateSlot(id, req.MigrateFrom, req.MigrateTo)
-	// if err != nil {
-	// 	s.Error("slotMigrate: ProposeMigrateSlot error", zap.Error(err))
-	// 	c.ResponseError(err)
-	// 	return
-	// }
+	if slot.Leader != s.opts.ConfigOptions.NodeId {
+		node := s.cfgServer.Node(slot.Leader)
+		if node == nil {
+			s.Error("leader not found", zap.Uint64("leaderId", slot.Leader))
+			c.ResponseError(errors.New("leader not found"))
+			return
+		}
+		c.ForwardWithBody(fmt.Sprintf("%s%s", node.ApiServerAddr, c.Request.URL.Path), bodyBytes)
+		return
+	}
 
+	timeoutCtx, cancel := context.WithTimeout(s.cancelCtx, time.Second*10)
+	defer cancel()
+	err = s.slotServer.TransferLeader(timeoutCtx, id, req.TransferTo)
+	if err != nil {
+		s.Error("slotLeaderTransfer: TransferLeader error", zap.Error(err), zap.Uint32("slotId", id), zap.Uint64("transferTo", req.TransferTo))
+		c.ResponseError(err)
+		return
+	}
 	c.ResponseOK()
+}
+
+// migrateSlot 迁移槽副本（如果迁移的源节点是槽领导，迁移完成后目标节点将成为新的槽领导）
+func (s *Server) migrateSlot(slot *types.Slot, migrateFrom, migrateTo uint64) error {
+	if !wkutil.ArrayContainsUint64(slot.Replicas, migrateFrom) {
+		return errors.New("MigrateFrom not in replicas")
+	}
+	if wkutil.ArrayContainsUint64(slot.Replicas, migrateTo) && migrateFrom != slot.Leader {
+		return errors.New("transition between followers is not supported")
+	}
+
+	if migrateFrom == 0 || migrateTo == 0 {
+		return errors.New("migrateFrom or migrateTo is 0")
+	}
+
+	if migrateFrom == migrateTo {
+		return errors.New("migrateFrom is equal to migrateTo")
+	}
 
+	if slot.MigrateFrom != 0 || slot.MigrateTo != 0 {
+		return errors.New("migrate is in progress")
+	}
+
+	return s.cfgServer.ProposeMigrateSlot(slot.Id, migrateFrom, migrateTo)
 }
 
 func (s *Server) allSlotsGet(c *wkhttp.Context) {
@@ -325,3 +376,53 @@ func (s *Server) getSlotMaxLogIndex(slotId uint32) (uint64, error) {
 	}
 	return 0, nil
 }
+
+// 获取槽副本均衡状态
+func (s *Server) slotBalanceGet(c *wkhttp.Context) {
+	cfg := s.cfgServer.GetClusterConfig()
+
+	migratingCount := 0
+	for _, st := range cfg.Slots {
+		if st.MigrateFrom != 0 || st.MigrateTo != 0 {
+			migratingCount++
+		}
+	}
+
+	nodes := make([]*slotBalanceNodeResp, 0, len(cfg.Nodes))
+	for _, node := range cfg.Nodes {
+		if !node.AllowVote {
+			continue
+		}
+		nodes = append(nodes, &slotBalanceNodeResp{
+			NodeId:    node.Id,
+			SlotCount: s.getNodeSlotCount(node.Id, cfg),
+		})
+	}
+
+	c.JSON(http.StatusOK, &slotBalanceResp{
+		On:             wkutil.BoolToInt(s.cfgServer.SlotReplicaBalanceOn()),
+		Concurrency:    s.opts.ConfigOptions.SlotReplicaBalanceConcurrency,
+		MigratingCount: migratingCount,
+		Nodes:          nodes,
+	})
+}
+
+// 开启或关闭槽副本均衡（提案到集群配置，所有节点生效，重启后依然有效）
+func (s *Server) slotBalanceSet(c *wkhttp.Context) {
+	var req struct {
+		On bool `json:"on"` // 是否开启
+	}
+	if err := c.BindJSON(&req); err != nil {
+		s.Error("bind json error", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+
+	err := s.cfgServer.ProposeSlotReplicaBalance(req.On)
+	if err != nil {
+		s.Error("slotBalanceSet: ProposeSlotReplicaBalance error", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	c.ResponseOK()
+}
@@ -3,12 +3,9 @@ package cluster
 import (
 	"context"
 	"errors"
-	"math/rand/v2"
 	"time"
 
-	"github.com/WuKongIM/WuKongIM/pkg/cluster/node/types"
 	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
-	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
 	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
 )
@@ -90,16 +87,9 @@ func (s *Server) joinNewRepliceIfNeed(cfg *wkdb.ChannelClusterConfig) (bool, err
 		return false, errors.New("no allow vote nodes")
 	}
 
-	newReplicaIds := make([]uint64, 0, len(allowVoteNodes)-len(cfg.Replicas))
-	for _, node := range allowVoteNodes {
-		if !wkutil.ArrayContainsUint64(cfg.Replicas, node.Id) {
-			newReplicaIds = append(newReplicaIds, node.Id)
-		}
-	}
-	// 打乱顺序，防止每次都是相同的节点加入
-	rand.Shuffle(len(newReplicaIds), func(i, j int) {
-		newReplicaIds[i], newReplicaIds[j] = newReplicaIds[j], newReplicaIds[i]
-	})
+	// 按放置策略选择新的副本（副本尽量分布在不同的区域）
+	replicaIds := s.opts.ChannelPlacement.Select(allowVoteNodes, cfg.Replicas, int(cfg.ReplicaMaxCount), s.getNodeLoads(), s.nodeZones())
+	newReplicaIds := replicaIds[len(cfg.Replicas):]
 
 	// 将新节点加入到学习者列表
 	for _, newReplicaId := range newReplicaIds {
@@ -253,26 +243,8 @@ func (s *Server) createChannelClusterConfig(channelId string, channelType uint8)
 		CreatedAt:       &createdAt,
 		UpdatedAt:       &updatedAt,
 	}
-	replicaIds := make([]uint64, 0, s.opts.ConfigOptions.ChannelMaxReplicaCount)
-	replicaIds = append(replicaIds, s.opts.ConfigOptions.NodeId) // 默认当前节点是领导，所以加入到副本列表中
-
-	// 随机选择副本
-	newAllowVoteNodes := make([]*types.Node, 0, len(allowVoteNodes))
-	newAllowVoteNodes = append(newAllowVoteNodes, allowVoteNodes...)
-	rand.Shuffle(len(newAllowVoteNodes), func(i, j int) {
-		newAllowVoteNodes[i], newAllowVoteNodes[j] = newAllowVoteNodes[j], newAllowVoteNodes[i]
-	})
-
-	for _, allowVoteNode := range newAllowVoteNodes {
-		if allowVoteNode.Id == s.opts.ConfigOptions.NodeId {
-			continue
-		}
-		if len(replicaIds) >= int(s.opts.ConfigOptions.ChannelMaxReplicaCount) {
-			break
-		}
-		replicaIds = append(replicaIds, allowVoteNode.Id)
-
-	}
+	// 按放置策略选择副本，默认当前节点是领导，所以一定在副本列表中
+	replicaIds := s.opts.ChannelPlacement.Select(allowVoteNodes, []uint64{s.opts.ConfigOptions.NodeId}, int(s.opts.ConfigOptions.ChannelMaxReplicaCount), s.getNodeLoads(), s.nodeZones())
 	clusterConfig.Replicas = replicaIds
 	return clusterConfig, nil
 }
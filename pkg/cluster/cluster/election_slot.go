@@ -228,8 +228,11 @@ func (s *Server) electionSlotLeaderBySlot(slotLogInfos map[uint64]map[uint32]Slo
 	var leaderTerm uint32   // 领导任期
 	st := s.cfgServer.Slot(slotId)
 
+	transfer := st != nil && st.ExpectLeader != 0 && st.ExpectLeader != st.Leader // 是否正在进行领导者转移
+	zoneLeaderCountMap := s.zoneSlotLeaderCount()
+
 	// 如果槽正在进行领导者转移，则优先计算转移节点的日志信息
-	if st != nil && st.ExpectLeader != 0 && st.ExpectLeader != st.Leader {
+	if transfer {
 		for replicaId, logIndexMap := range slotLogInfos {
 			slotInfo, ok := logIndexMap[slotId]
 			if !ok {
@@ -282,9 +285,36 @@ func (s *Server) electionSlotLeaderBySlot(slotLogInfos map[uint64]map[uint32]Slo
 				lastLogIndex = candidate.LogIndex
 				lastLogTerm = candidate.LogTerm
 				leaderTerm = candidate.Term
+			} else if candidate.LogIndex == lastLogIndex && !transfer {
+				// 日志一样新时，优先选择槽领导较少的区域的节点，使槽领导尽量分布在不同的区域
+				if zoneLeaderCountMap[s.nodeZone(replicaId)] < zoneLeaderCountMap[s.nodeZone(expectLeader)] {
+					expectLeader = replicaId
+					leaderTerm = candidate.Term
+				}
 			}
 		}
 
 	}
 	return expectLeader
 }
+
+// 节点所在的区域
+func (s *Server) nodeZone(nodeId uint64) string {
+	node := s.cfgServer.Node(nodeId)
+	if node == nil {
+		return ""
+	}
+	return node.Zone
+}
+
+// 每个区域的槽领导数量
+func (s *Server) zoneSlotLeaderCount() map[string]int {
+	zoneLeaderCountMap := make(map[string]int)
+	for _, st := range s.cfgServer.Slots() {
+		if st.Leader == 0 {
+			continue
+		}
+		zoneLeaderCountMap[s.nodeZone(st.Leader)]++
+	}
+	return zoneLeaderCountMap
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"strconv"
 	"strings"
 	"time"
@@ -612,6 +613,7 @@ type ClusterJoinReq struct {
 	NodeId     uint64
 	ServerAddr string
 	Role       types.NodeRole
+	Zone       string // 节点所在区域
 }
 
 func (c *ClusterJoinReq) Marshal() ([]byte, error) {
@@ -620,6 +622,7 @@ func (c *ClusterJoinReq) Marshal() ([]byte, error) {
 	enc.WriteUint64(c.NodeId)
 	enc.WriteString(c.ServerAddr)
 	enc.WriteUint32(uint32(c.Role))
+	enc.WriteString(c.Zone)
 	return enc.Bytes(), nil
 
 }
@@ -638,6 +641,13 @@ func (c *ClusterJoinReq) Unmarshal(data []byte) error {
 		return err
 	}
 	c.Role = types.NodeRole(role)
+
+	// 兼容旧版本没有区域标签的请求
+	if dec.Len() > 0 {
+		if c.Zone, err = dec.String(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -1056,6 +1066,7 @@ type NodeConfig struct {
 	ConfigVersion   uint64           `json:"config_version,omitempty"`    // 配置版本
 	Status          types.NodeStatus `json:"status,omitempty"`            // 状态
 	StatusFormat    string           `json:"status_format,omitempty"`     // 状态格式化
+	Zone            string           `json:"zone,omitempty"`              // 所在区域
 }
 
 func NewNodeConfigFromNode(n *types.Node) *NodeConfig {
@@ -1072,6 +1083,8 @@ func NewNodeConfigFromNode(n *types.Node) *NodeConfig {
 		status = "加入中"
 	} else if n.Status == types.NodeStatus_NodeStatusWillJoin {
 		status = "将加入"
+	} else if n.Status == types.NodeStatus_NodeStatusLeaving {
+		status = "离开中"
 	}
 	return &NodeConfig{
 		Id:            n.Id,
@@ -1084,6 +1097,7 @@ func NewNodeConfigFromNode(n *types.Node) *NodeConfig {
 		AllowVote:     wkutil.BoolToInt(n.AllowVote),
 		Status:        n.Status,
 		StatusFormat:  status,
+		Zone:          n.Zone,
 	}
 }
 
@@ -1094,11 +1108,42 @@ type SlotMigrate struct {
 	Status types.MigrateStatus `json:"status"`
 }
 
+type slotBalanceResp struct {
+	On             int                    `json:"on"`              // 是否开启槽副本均衡
+	Concurrency    int                    `json:"concurrency"`     // 同时迁移的最大槽数量
+	MigratingCount int                    `json:"migrating_count"` // 正在迁移的槽数量
+	Nodes          []*slotBalanceNodeResp `json:"nodes"`           // 每个节点的槽副本数量
+}
+
+type slotBalanceNodeResp struct {
+	NodeId    uint64 `json:"node_id"`    // 节点ID
+	SlotCount int    `json:"slot_count"` // 槽副本数量
+}
+
+type channelBalanceResp struct {
+	On            int         `json:"on"`             // 是否开启频道副本均衡
+	Threshold     float64     `json:"threshold"`      // 节点负载超过平均负载的比例阈值
+	MaxMigrations int         `json:"max_migrations"` // 每轮最多迁移的频道数量
+	Nodes         []*NodeLoad `json:"nodes"`          // 每个节点的负载
+}
+
 type NodeConfigTotal struct {
 	Total int           `json:"total"` // 总数
 	Data  []*NodeConfig `json:"data"`
 }
 
+// NodeDrainResp 节点下线进度
+type NodeDrainResp struct {
+	NodeId       uint64           `json:"node_id"`       // 节点ID
+	Status       types.NodeStatus `json:"status"`        // 节点状态
+	StatusFormat string           `json:"status_format"` // 状态格式化
+	SlotCount    int              `json:"slot_count"`    // 还未迁出的槽数量
+	ChannelCount int              `json:"channel_count"` // 还未迁出的频道数量
+	// 槽领导离线或没有领导的槽数量（这些槽里的频道数量未知，不为0时不算迁移完成）
+	UnknownSlotCount int `json:"unknown_slot_count"`
+	Done             int `json:"done"` // 是否已迁移完成（完成后可以移除节点）
+}
+
 type channelInfoResp struct {
 	Id                uint64 `json:"id"`                   // 主键
 	Slot              uint32 `json:"slot"`                 // 槽位ID
@@ -1231,6 +1276,57 @@ type userRespTotal struct {
 	Data  []*userResp `json:"data"`
 }
 
+type auditLogResp struct {
+	Id              uint64 `json:"id,string"`         // 主键（雪花id，字符串格式防止前端精度丢失）
+	Actor           string `json:"actor"`             // 操作者
+	ActorType       string `json:"actor_type"`        // 操作者类型
+	Ip              string `json:"ip"`                // 来源ip
+	Method          string `json:"method"`            // 请求方法
+	Path            string `json:"path"`              // 请求路径
+	Resource        string `json:"resource"`          // 资源
+	Params          string `json:"params"`            // 请求参数（已脱敏）
+	Status          int    `json:"status"`            // 响应的http状态码
+	Result          string `json:"result"`            // 结果 success: 成功 fail: 失败
+	NodeId          uint64 `json:"node_id"`           // 处理请求的节点
+	CreatedAt       int64  `json:"created_at"`        // 创建时间
+	CreatedAtFormat string `json:"created_at_format"` // 创建时间格式化
+}
+
+func newAuditLogResp(a wkdb.AuditLog) *auditLogResp {
+	result := "success"
+	if a.Status >= http.StatusBadRequest {
+		result = "fail"
+	}
+	var (
+		createdAt       int64
+		createdAtFormat string
+	)
+	if a.CreatedAt != nil {
+		createdAt = a.CreatedAt.UnixNano()
+		createdAtFormat = wkutil.ToyyyyMMddHHmm(*a.CreatedAt)
+	}
+	return &auditLogResp{
+		Id:              a.Id,
+		Actor:           a.Actor,
+		ActorType:       a.ActorType,
+		Ip:              a.Ip,
+		Method:          a.Method,
+		Path:            a.Path,
+		Resource:        a.Resource,
+		Params:          a.Params,
+		Status:          a.Status,
+		Result:          result,
+		NodeId:          a.NodeId,
+		CreatedAt:       createdAt,
+		CreatedAtFormat: createdAtFormat,
+	}
+}
+
+type auditLogRespTotal struct {
+	More int             `json:"more"` // 是否还有更多
+	Data []*auditLogResp `json:"data"`
+}
+
 type deviceResp struct {
 	Id                uint64 `json:"id"`                  // 主键
 	Uid               string `json:"uid"`                 // 用户唯一uid
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/WuKongIM/WuKongIM/pkg/wkfailpoint"
 	"github.com/WuKongIM/WuKongIM/pkg/wklog"
 	"github.com/WuKongIM/WuKongIM/pkg/wkserver/client"
 	"github.com/WuKongIM/WuKongIM/pkg/wkserver/proto"
@@ -39,7 +40,15 @@ func newNode(id uint64, uid string, addr string, opts *Options) *node {
 			rl: NewRateLimiter(opts.MaxSendQueueSize),
 		},
 	}
-	n.client = client.New(addr, client.WithUid(uid))
+	clientOpts := []client.Option{client.WithUid(uid)}
+	if opts.Secret != "" {
+		clientOpts = append(clientOpts, client.WithSecret(opts.Secret))
+	}
+	if opts.TLSConfig != nil {
+		// 校验对方证书的CommonName是目标节点的id
+		clientOpts = append(clientOpts, client.WithTLSConfig(opts.TLSConfig), client.WithServerUid(fmt.Sprintf("%d", id)))
+	}
+	n.client = client.New(addr, clientOpts...)
 	return n
 }
 
@@ -57,7 +66,15 @@ func (n *node) stop() {
 	n.client.Stop()
 }
 
+// FailpointNodeSendDrop 丢弃发往节点的消息（模拟网络分区），return(节点id)只丢弃发往该节点的，return(true)丢弃全部
+const FailpointNodeSendDrop = "cluster/nodeSendDrop"
+
 func (n *node) send(msg *proto.Message) error {
+	if v, ok := wkfailpoint.Eval(FailpointNodeSendDrop); ok {
+		if drop, isBool := v.(bool); (isBool && drop) || v == int(n.id) {
+			return nil
+		}
+	}
 	if n.sendQueue.rateLimited() { // 发送队列限流
 		n.Error("sendQueue is rateLimited")
 		return ErrRateLimited
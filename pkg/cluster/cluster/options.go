@@ -1,6 +1,7 @@
 package cluster
 
 import (
+	"crypto/tls"
 	"time"
 
 	"github.com/WuKongIM/WuKongIM/pkg/auth"
@@ -58,6 +59,28 @@ type Options struct {
 	AppVersion string
 
 	IsCmdChannel func(channel string) bool
+
+	// OnNodeLeaving 当前节点开始离开集群时回调（不要阻塞此方法）
+	OnNodeLeaving func()
+
+	// ChannelPlacement 频道副本放置策略
+	ChannelPlacement ChannelPlacement
+
+	// 频道副本均衡
+	ChannelBalance struct {
+		On            bool          // 是否开启频道副本均衡
+		Interval      time.Duration // 均衡检查间隔（同时也是节点负载的采集间隔）
+		Threshold     float64       // 节点负载超过平均负载的比例，超过则认为是热点节点，例如0.2表示超过平均负载20%
+		MaxMigrations int           // 每轮整个集群最多迁移的频道数量
+	}
+
+	// SlotCompactLogThreshold 槽已应用但未压缩的日志超过此数量时压缩日志（落后太多的副本通过快照同步），0表示不压缩
+	SlotCompactLogThreshold uint64
+
+	// Secret 节点之间通讯的共享密钥，配置后节点连接时校验
+	Secret string
+	// TLSConfig 节点之间通讯的tls配置（NewTLSConfig创建），证书的CommonName必须是节点id
+	TLSConfig *tls.Config
 }
 
 func NewOptions(opt ...Option) *Options {
@@ -79,6 +102,19 @@ func NewOptions(opt ...Option) *Options {
 			SlotMemTableSize: 16 * 1024 * 1024,
 		},
 		PageSize: 20,
+
+		ChannelPlacement: randomPlacement{},
+		ChannelBalance: struct {
+			On            bool
+			Interval      time.Duration
+			Threshold     float64
+			MaxMigrations int
+		}{
+			On:            false,
+			Interval:      time.Minute,
+			Threshold:     0.2,
+			MaxMigrations: 10,
+		},
 	}
 	for _, o := range opt {
 		o(opts)
@@ -213,3 +249,57 @@ func WithIsCmdChannel(isCmdChannel func(channel string) bool) Option {
 		o.IsCmdChannel = isCmdChannel
 	}
 }
+
+func WithOnNodeLeaving(f func()) Option {
+	return func(o *Options) {
+		o.OnNodeLeaving = f
+	}
+}
+
+func WithChannelPlacement(placement ChannelPlacement) Option {
+	return func(o *Options) {
+		o.ChannelPlacement = placement
+	}
+}
+
+func WithChannelBalanceOn(on bool) Option {
+	return func(o *Options) {
+		o.ChannelBalance.On = on
+	}
+}
+
+func WithChannelBalanceInterval(interval time.Duration) Option {
+	return func(o *Options) {
+		o.ChannelBalance.Interval = interval
+	}
+}
+
+func WithChannelBalanceThreshold(threshold float64) Option {
+	return func(o *Options) {
+		o.ChannelBalance.Threshold = threshold
+	}
+}
+
+func WithChannelBalanceMaxMigrations(maxMigrations int) Option {
+	return func(o *Options) {
+		o.ChannelBalance.MaxMigrations = maxMigrations
+	}
+}
+
+func WithSlotCompactLogThreshold(threshold uint64) Option {
+	return func(o *Options) {
+		o.SlotCompactLogThreshold = threshold
+	}
+}
+
+func WithSecret(secret string) Option {
+	return func(o *Options) {
+		o.Secret = secret
+	}
+}
+
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(o *Options) {
+		o.TLSConfig = tlsConfig
+	}
+}
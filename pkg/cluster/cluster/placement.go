@@ -0,0 +1,169 @@
+package cluster
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/node/types"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+)
+
+const (
+	ChannelPlacementRandom      = "random"      // 随机选择副本（副本尽量分布在不同的区域）
+	ChannelPlacementLeastLoaded = "leastLoaded" // 优先选择负载最低的节点
+	ChannelPlacementZoneSpread  = "zoneSpread"  // 副本尽量分布在不同的区域，同区域内优先选择负载最低的节点
+)
+
+// ChannelPlacement 频道副本放置策略
+type ChannelPlacement interface {
+	// Select 从候选节点中选出count个副本节点，must中的节点一定会被选中并且排在最前面
+	// zones为节点所在的区域（取自集群配置的节点区域标签）
+	Select(candidates []*types.Node, must []uint64, count int, loads map[uint64]*NodeLoad, zones map[uint64]string) []uint64
+}
+
+// NewChannelPlacement 根据名称创建频道副本放置策略
+func NewChannelPlacement(name string) (ChannelPlacement, error) {
+	switch name {
+	case "", ChannelPlacementRandom:
+		return randomPlacement{}, nil
+	case ChannelPlacementLeastLoaded:
+		return leastLoadedPlacement{}, nil
+	case ChannelPlacementZoneSpread:
+		return zoneSpreadPlacement{}, nil
+	}
+	return nil, fmt.Errorf("unknown channel placement: %s", name)
+}
+
+// NodeLoad 节点负载
+type NodeLoad struct {
+	NodeId       uint64 `json:"node_id"`       // 节点ID
+	ChannelCount int    `json:"channel_count"` // 运行中的频道数量
+	MessageRate  int64  `json:"message_rate"`  // 每秒作为频道领导提案的消息数量
+}
+
+// Score 负载分数，越大负载越高
+func (n *NodeLoad) Score() int64 {
+	return int64(n.ChannelCount) + n.MessageRate
+}
+
+func (n *NodeLoad) Marshal() ([]byte, error) {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteUint64(n.NodeId)
+	enc.WriteUint32(uint32(n.ChannelCount))
+	enc.WriteInt64(n.MessageRate)
+	return enc.Bytes(), nil
+}
+
+func (n *NodeLoad) Unmarshal(data []byte) error {
+	dec := wkproto.NewDecoder(data)
+	var err error
+	if n.NodeId, err = dec.Uint64(); err != nil {
+		return err
+	}
+	var channelCount uint32
+	if channelCount, err = dec.Uint32(); err != nil {
+		return err
+	}
+	n.ChannelCount = int(channelCount)
+	if n.MessageRate, err = dec.Int64(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// 随机选择副本（副本尽量分布在不同的区域）
+type randomPlacement struct{}
+
+func (randomPlacement) Select(candidates []*types.Node, must []uint64, count int, loads map[uint64]*NodeLoad, zones map[uint64]string) []uint64 {
+	newCandidates := make([]*types.Node, 0, len(candidates))
+	newCandidates = append(newCandidates, candidates...)
+	rand.Shuffle(len(newCandidates), func(i, j int) {
+		newCandidates[i], newCandidates[j] = newCandidates[j], newCandidates[i]
+	})
+	return selectZoneSpread(newCandidates, must, count, zones)
+}
+
+// 优先选择负载最低的节点
+type leastLoadedPlacement struct{}
+
+func (leastLoadedPlacement) Select(candidates []*types.Node, must []uint64, count int, loads map[uint64]*NodeLoad, zones map[uint64]string) []uint64 {
+	replicaIds := make([]uint64, 0, count)
+	replicaIds = append(replicaIds, must...)
+
+	for _, node := range sortNodesByLoad(candidates, loads) {
+		if len(replicaIds) >= count {
+			break
+		}
+		if wkutil.ArrayContainsUint64(replicaIds, node.Id) {
+			continue
+		}
+		replicaIds = append(replicaIds, node.Id)
+	}
+	return replicaIds
+}
+
+// 副本尽量分布在不同的区域，同区域内优先选择负载最低的节点
+type zoneSpreadPlacement struct{}
+
+func (zoneSpreadPlacement) Select(candidates []*types.Node, must []uint64, count int, loads map[uint64]*NodeLoad, zones map[uint64]string) []uint64 {
+	return selectZoneSpread(sortNodesByLoad(candidates, loads), must, count, zones)
+}
+
+// 按nodes的顺序选择副本，每次优先选择已选副本最少的区域的节点
+func selectZoneSpread(nodes []*types.Node, must []uint64, count int, zones map[uint64]string) []uint64 {
+	replicaIds := make([]uint64, 0, count)
+	replicaIds = append(replicaIds, must...)
+
+	zoneCountMap := make(map[string]int) // 每个区域已选择的副本数量
+	for _, nodeId := range must {
+		zoneCountMap[zones[nodeId]]++
+	}
+
+	for len(replicaIds) < count {
+		var (
+			selected     *types.Node
+			minZoneCount int
+		)
+		for _, node := range nodes { // 同区域数量相同时选中的是排在前面的节点
+			if wkutil.ArrayContainsUint64(replicaIds, node.Id) {
+				continue
+			}
+			zoneCount := zoneCountMap[zones[node.Id]]
+			if selected == nil || zoneCount < minZoneCount {
+				selected = node
+				minZoneCount = zoneCount
+			}
+		}
+		if selected == nil {
+			break
+		}
+		replicaIds = append(replicaIds, selected.Id)
+		zoneCountMap[zones[selected.Id]]++
+	}
+	return replicaIds
+}
+
+// 按负载从低到高排序（没有负载信息的节点视为负载为0）
+func sortNodesByLoad(nodes []*types.Node, loads map[uint64]*NodeLoad) []*types.Node {
+	sortedNodes := make([]*types.Node, 0, len(nodes))
+	sortedNodes = append(sortedNodes, nodes...)
+	sort.SliceStable(sortedNodes, func(i, j int) bool {
+		si, sj := nodeScore(sortedNodes[i].Id, loads), nodeScore(sortedNodes[j].Id, loads)
+		if si != sj {
+			return si < sj
+		}
+		return sortedNodes[i].Id < sortedNodes[j].Id
+	})
+	return sortedNodes
+}
+
+func nodeScore(nodeId uint64, loads map[uint64]*NodeLoad) int64 {
+	load := loads[nodeId]
+	if load == nil {
+		return 0
+	}
+	return load.Score()
+}
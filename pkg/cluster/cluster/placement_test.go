@@ -0,0 +1,98 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/node/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// 测试负载最低优先的放置策略
+func TestLeastLoadedPlacement(t *testing.T) {
+	nodes := []*types.Node{{Id: 1}, {Id: 2}, {Id: 3}, {Id: 4}}
+	loads := map[uint64]*NodeLoad{
+		1: {NodeId: 1, ChannelCount: 10},
+		2: {NodeId: 2, ChannelCount: 30},
+		3: {NodeId: 3, ChannelCount: 5},
+		4: {NodeId: 4, ChannelCount: 20},
+	}
+	placement, err := NewChannelPlacement(ChannelPlacementLeastLoaded)
+	assert.NoError(t, err)
+
+	replicaIds := placement.Select(nodes, []uint64{2}, 3, loads, nil)
+	assert.Equal(t, []uint64{2, 3, 1}, replicaIds)
+
+	_, err = NewChannelPlacement("unknown")
+	assert.Error(t, err)
+}
+
+// 测试区域分散的放置策略，同区域内优先选择负载最低的节点
+func TestZoneSpreadPlacement(t *testing.T) {
+	nodes := []*types.Node{{Id: 1}, {Id: 2}, {Id: 3}, {Id: 4}}
+	loads := map[uint64]*NodeLoad{
+		1: {NodeId: 1, ChannelCount: 10},
+		2: {NodeId: 2, ChannelCount: 1},
+		3: {NodeId: 3, ChannelCount: 20},
+		4: {NodeId: 4, ChannelCount: 30},
+	}
+	zones := map[uint64]string{1: "a", 2: "a", 3: "b", 4: "c"}
+	placement, err := NewChannelPlacement(ChannelPlacementZoneSpread)
+	assert.NoError(t, err)
+
+	// 节点2负载最低，但和必选的节点1在同一个区域
+	replicaIds := placement.Select(nodes, []uint64{1}, 3, loads, zones)
+	assert.Equal(t, []uint64{1, 3, 4}, replicaIds)
+
+	// 必选节点不在候选节点中时，也按它的区域分散
+	replicaIds = placement.Select(nodes[1:], []uint64{1}, 2, loads, zones)
+	assert.Equal(t, []uint64{1, 3}, replicaIds)
+
+	// 负载最低优先的策略不考虑区域
+	placement, err = NewChannelPlacement(ChannelPlacementLeastLoaded)
+	assert.NoError(t, err)
+	replicaIds = placement.Select(nodes, []uint64{1}, 3, loads, zones)
+	assert.Equal(t, []uint64{1, 2, 3}, replicaIds)
+}
+
+// 测试随机的放置策略，副本尽量分布在不同的区域
+func TestRandomPlacementZones(t *testing.T) {
+	nodes := []*types.Node{{Id: 1}, {Id: 2}, {Id: 3}, {Id: 4}, {Id: 5}}
+	zones := map[uint64]string{1: "a", 2: "a", 3: "a", 4: "b", 5: "c"}
+	placement, err := NewChannelPlacement(ChannelPlacementRandom)
+	assert.NoError(t, err)
+
+	for i := 0; i < 20; i++ {
+		replicaIds := placement.Select(nodes, []uint64{1}, 3, nil, zones)
+		assert.Equal(t, uint64(1), replicaIds[0])
+		assert.ElementsMatch(t, []uint64{1, 4, 5}, replicaIds)
+	}
+}
+
+// 测试每轮迁移数量按领导的槽数量分给各个槽领导
+func TestChannelBalanceQuota(t *testing.T) {
+	slots := []*types.Slot{{Id: 1, Leader: 1}, {Id: 2, Leader: 1}, {Id: 3, Leader: 2}, {Id: 4, Leader: 3}}
+
+	// 10*2/4=5 10*1/4=2余2 10*1/4=2余2，余下的1个分给节点id小的
+	assert.Equal(t, 5, channelBalanceQuota(slots, 1, 10))
+	assert.Equal(t, 3, channelBalanceQuota(slots, 2, 10))
+	assert.Equal(t, 2, channelBalanceQuota(slots, 3, 10))
+	assert.Equal(t, 0, channelBalanceQuota(slots, 4, 10)) // 不是槽领导
+
+	// 所有槽领导的数量之和等于MaxMigrations
+	total := 0
+	for nodeId := uint64(1); nodeId <= 3; nodeId++ {
+		total += channelBalanceQuota(slots, nodeId, 3)
+	}
+	assert.Equal(t, 3, total)
+}
+
+func TestNodeLoadMarshal(t *testing.T) {
+	load := &NodeLoad{NodeId: 1, ChannelCount: 100, MessageRate: 2000}
+	data, err := load.Marshal()
+	assert.NoError(t, err)
+
+	newLoad := &NodeLoad{}
+	err = newLoad.Unmarshal(data)
+	assert.NoError(t, err)
+	assert.Equal(t, load, newLoad)
+}
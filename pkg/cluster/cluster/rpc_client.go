@@ -168,6 +168,19 @@ func (r *rpcClient) RequestClusterJoin(nodeId uint64, req *ClusterJoinReq) (*Clu
 	return resp, nil
 }
 
+// 获取节点负载
+func (r *rpcClient) RequestNodeLoad(nodeId uint64) (*NodeLoad, error) {
+	body, err := r.request(nodeId, "/rpc/node/load", nil)
+	if err != nil {
+		return nil, err
+	}
+	load := &NodeLoad{}
+	if err := load.Unmarshal(body); err != nil {
+		return nil, err
+	}
+	return load, nil
+}
+
 func (r *rpcClient) request(nodeId uint64, path string, body []byte) ([]byte, error) {
 
 	node := r.s.nodeManager.node(nodeId)
@@ -48,6 +48,9 @@ func (r *rpcServer) setRoutes() {
 
 	// 节点加入
 	r.s.netServer.Route("/rpc/cluster/join", r.handleClusterJoin)
+
+	// 获取节点负载
+	r.s.netServer.Route("/rpc/node/load", r.handleNodeLoad)
 }
 
 func (r *rpcServer) handleChannelPropose(c *wkserver.Context) {
@@ -335,6 +338,7 @@ func (r *rpcServer) handleClusterJoin(c *wkserver.Context) {
 		AllowVote:   allowVote,
 		CreatedAt:   time.Now().Unix(),
 		Status:      types.NodeStatus_NodeStatusWillJoin,
+		Zone:        req.Zone,
 	})
 	if err != nil {
 		r.Error("proposeJoin failed", zap.Error(err))
@@ -350,3 +354,14 @@ func (r *rpcServer) handleClusterJoin(c *wkserver.Context) {
 	}
 	c.Write(result)
 }
+
+func (r *rpcServer) handleNodeLoad(c *wkserver.Context) {
+	load := r.s.localNodeLoad()
+	data, err := load.Marshal()
+	if err != nil {
+		r.Error("node load marshal failed", zap.Error(err))
+		c.WriteErr(err)
+		return
+	}
+	c.Write(data)
+}
@@ -4,10 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"path"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/channel"
@@ -63,6 +65,11 @@ type Server struct {
 
 	channelKeyLock *keylock.KeyLock // 频道锁
 
+	leaving atomic.Bool // 当前节点是否正在离开集群
+
+	channelBalanceOn atomic.Bool // 是否开启频道副本均衡
+	nodeLoads        nodeLoads   // 节点负载
+
 	sync.Mutex
 }
 
@@ -75,6 +82,7 @@ func New(opts *Options) *Server {
 		uptime:         time.Now(),
 		stopper:        syncutil.NewStopper(),
 	}
+	s.channelBalanceOn.Store(opts.ChannelBalance.On)
 	s.cancelCtx, s.cancelFnc = context.WithCancel(context.Background())
 	// 初始化传输层
 	if opts.SlotTransport == nil {
@@ -114,6 +122,8 @@ func New(opts *Options) *Server {
 		wkserver.WithOnResponse(func(conn gnet.Conn, resp *proto.Response) {
 			trace.GlobalTrace.Metrics.System().IntranetOutgoingAdd(int64(len(resp.Body)))
 		}),
+		wkserver.WithSecret(opts.Secret),
+		wkserver.WithTLSConfig(opts.TLSConfig),
 	)
 	s.netServer.OnMessage(s.onMessage)
 
@@ -131,6 +141,10 @@ func New(opts *Options) *Server {
 		slot.WithNode(s.cfgServer),
 		slot.WithOnApply(s.slotApplyLogs),
 		slot.WithOnSaveConfig(s.onSaveSlotConfig),
+		slot.WithOnSnapshot(s.slotSnapshot),
+		slot.WithOnApplySnapshot(s.slotApplySnapshot),
+		slot.WithOnIndexLogs(s.slotIndexLogs),
+		slot.WithCompactLogThreshold(opts.SlotCompactLogThreshold),
 	))
 
 	// 频道分布式服务
@@ -234,10 +248,17 @@ func (s *Server) Start() error {
 		s.stopper.RunWorker(s.joinLoop)
 	}
 
+	// 迁移离开节点上的频道
+	s.stopper.RunWorker(s.drainLoop)
+
+	// 采集节点负载并均衡频道副本
+	s.stopper.RunWorker(s.channelBalanceLoop)
+
 	return nil
 }
 
 func (s *Server) Stop() {
+	s.stopper.Stop()
 	s.eventServer.Stop()
 	s.cfgServer.Stop()
 	s.slotServer.Stop()
@@ -282,6 +303,9 @@ func (s *Server) OnConfigChange(cfg *types.Config) {
 		nodeMap[node.Id] = node.ClusterAddr
 	}
 	s.addOrUpdateNodes(nodeMap)
+	s.removeNodesNotIn(nodeMap)
+
+	s.checkLocalNodeLeaving(cfg)
 }
 
 func (s *Server) slotApplyLogs(slotId uint32, logs []rafttype.Log) error {
@@ -293,6 +317,25 @@ func (s *Server) slotApplyLogs(slotId uint32, logs []rafttype.Log) error {
 	return nil
 }
 
+// slotSnapshot 从分布式存储导出槽的快照数据
+func (s *Server) slotSnapshot(slotId uint32, w io.Writer) error {
+	return s.store.GetSlotSnapshot(slotId, w)
+}
+
+// slotApplySnapshot 用快照数据恢复槽的分布式存储
+func (s *Server) slotApplySnapshot(slotId uint32, r io.Reader) error {
+	err := s.store.ApplySlotSnapshot(slotId, r)
+	if err != nil {
+		s.Error("apply slot snapshot failed", zap.Uint32("slotId", slotId), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (s *Server) slotIndexLogs(slotId uint32, logs []rafttype.Log) error {
+	return s.store.IndexSlotLogs(slotId, logs)
+}
+
 func (s *Server) addOrUpdateNodes(nodeMap map[uint64]string) {
 	s.Lock()
 	defer s.Unlock()
@@ -323,6 +366,23 @@ func (s *Server) addOrUpdateNodes(nodeMap map[uint64]string) {
 	}
 }
 
+// 移除已经不在集群配置中的节点
+func (s *Server) removeNodesNotIn(nodeMap map[uint64]string) {
+	s.Lock()
+	defer s.Unlock()
+
+	if len(nodeMap) == 0 {
+		return
+	}
+	for _, n := range s.nodeManager.nodes() {
+		if _, ok := nodeMap[n.id]; ok {
+			continue
+		}
+		n.stop()
+		s.nodeManager.removeNode(n.id)
+	}
+}
+
 func (s *Server) serverUid(id uint64) string {
 	return fmt.Sprintf("%d", id)
 }
@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/WuKongIM/WuKongIM/pkg/auth"
+	"github.com/WuKongIM/WuKongIM/pkg/auth/resource"
 	"github.com/WuKongIM/WuKongIM/pkg/wkhttp"
 	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
 	"github.com/sendgrid/rest"
@@ -18,6 +20,9 @@ func (s *Server) ServerAPI(route *wkhttp.WKHttp, prefix string) {
 	route.GET(s.formatPath("/node"), s.nodeGet)                       // 获取当前节点信息
 	route.GET(s.formatPath("/simpleNodes"), s.simpleNodesGet)         // 获取简单节点信息
 	route.GET(s.formatPath("/nodes/:id/channels"), s.nodeChannelsGet) // 获取节点的所有频道信息
+	route.POST(s.formatPath("/nodes/:id/drain"), s.nodeDrain)         // 下线节点（迁移节点上的槽和频道）
+	route.GET(s.formatPath("/nodes/:id/drain"), s.nodeDrainGet)       // 获取节点下线进度
+	route.POST(s.formatPath("/nodes/:id/remove"), s.nodeRemove)       // 移除已下线的节点
 
 	// ================== slot ==================
 	// route.GET(s.formatPath("/channels/:channel_id/:channel_type/config"), s.channelClusterConfigGet) // 获取频道分布式配置
@@ -25,7 +30,10 @@ func (s *Server) ServerAPI(route *wkhttp.WKHttp, prefix string) {
 	route.GET(s.formatPath("/allslot"), s.allSlotsGet)                   // 获取所有槽信息
 	route.GET(s.formatPath("/slots/:id/config"), s.slotClusterConfigGet) // 槽分布式配置
 	// route.GET(s.formatPath("/slots/:id/channels"), s.slotChannelsGet)    // 获取某个槽的所有频道信息
-	route.POST(s.formatPath("/slots/:id/migrate"), s.slotMigrate) // 迁移槽
+	route.POST(s.formatPath("/slots/:id/migrate"), s.slotMigrate)                // 迁移槽
+	route.POST(s.formatPath("/slots/:id/leader_transfer"), s.slotLeaderTransfer) // 转让槽领导
+	route.GET(s.formatPath("/slots/balance"), s.slotBalanceGet)                  // 获取槽副本均衡状态
+	route.POST(s.formatPath("/slots/balance"), s.slotBalanceSet)                 // 开启或关闭槽副本均衡
 
 	// ================== message ==================
 	route.GET(s.formatPath("/messages"), s.messageSearch) // 搜索消息
@@ -45,19 +53,88 @@ func (s *Server) ServerAPI(route *wkhttp.WKHttp, prefix string) {
 
 	// ================== cluster ==================
 
-	route.GET(s.formatPath("/info"), s.clusterInfoGet) // 获取集群信息
-	route.GET(s.formatPath("/logs"), s.clusterLogs)    // 获取节点日志
+	route.GET(s.formatPath("/info"), s.clusterInfoGet)                    // 获取集群信息
+	route.GET(s.formatPath("/logs"), s.clusterLogs)                       // 获取节点日志
+	route.POST(s.formatPath("/leader_transfer"), s.clusterLeaderTransfer) // 转让集群配置领导
+
+	// ================== 审计日志 ==================
+	route.GET(s.formatPath("/auditlogs"), s.auditLogSearch) // 搜索审计日志
 
 	// ================== cluster channel ==================
-	route.POST(s.formatPath("/channels/:channel_id/:channel_type/migrate"), s.channelMigrate)          // 迁移频道
-	route.GET(s.formatPath("/channels/:channel_id/:channel_type/config"), s.channelClusterConfig)      // 获取频道的分布式配置
-	route.POST(s.formatPath("/channels/:channel_id/:channel_type/start"), s.channelStart)              // 开始频道
-	route.POST(s.formatPath("/channels/:channel_id/:channel_type/stop"), s.channelStop)                // 停止频道
-	route.POST(s.formatPath("/channel/status"), s.channelStatus)                                       // 获取频道状态
-	route.GET(s.formatPath("/channels/:channel_id/:channel_type/replicas"), s.channelReplicas)         // 获取频道副本信息
-	route.GET(s.formatPath("/channels/:channel_id/:channel_type/localReplica"), s.channelLocalReplica) // 获取频道在本节点的副本信息
+	route.POST(s.formatPath("/channels/:channel_id/:channel_type/migrate"), s.channelMigrate)                // 迁移频道
+	route.POST(s.formatPath("/channels/:channel_id/:channel_type/leader_transfer"), s.channelLeaderTransfer) // 转让频道领导
+	route.GET(s.formatPath("/channels/:channel_id/:channel_type/config"), s.channelClusterConfig)            // 获取频道的分布式配置
+	route.POST(s.formatPath("/channels/:channel_id/:channel_type/start"), s.channelStart)                    // 开始频道
+	route.POST(s.formatPath("/channels/:channel_id/:channel_type/stop"), s.channelStop)                      // 停止频道
+	route.POST(s.formatPath("/channel/status"), s.channelStatus)                                             // 获取频道状态
+	route.GET(s.formatPath("/channels/:channel_id/:channel_type/replicas"), s.channelReplicas)               // 获取频道副本信息
+	route.GET(s.formatPath("/channels/:channel_id/:channel_type/localReplica"), s.channelLocalReplica)       // 获取频道在本节点的副本信息
+	route.GET(s.formatPath("/channels/balance"), s.channelBalanceGet)                                        // 获取频道副本均衡状态
+	route.POST(s.formatPath("/channels/balance"), s.channelBalanceSet)                                       // 开启或关闭频道副本均衡
 
 }
+
+// RoutePermissions 分布式api的接口权限（需要在ServerAPI之后调用）
+func (s *Server) RoutePermissions() auth.RoutePermissions {
+	routes := auth.RoutePermissions{}
+
+	// ================== 节点 ==================
+	routes.Add(http.MethodGet, s.formatPath("/nodes"), resource.Node, auth.ActionRead)
+	routes.Add(http.MethodGet, s.formatPath("/node"), resource.Node, auth.ActionRead)
+	routes.Add(http.MethodGet, s.formatPath("/simpleNodes"), resource.Node, auth.ActionRead)
+	routes.Add(http.MethodGet, s.formatPath("/nodes/:id/channels"), resource.Node, auth.ActionRead)
+	routes.Add(http.MethodPost, s.formatPath("/nodes/:id/drain"), resource.Node, auth.ActionWrite)
+	routes.Add(http.MethodGet, s.formatPath("/nodes/:id/drain"), resource.Node, auth.ActionRead)
+	routes.Add(http.MethodPost, s.formatPath("/nodes/:id/remove"), resource.Node, auth.ActionWrite)
+
+	// ================== 槽 ==================
+	routes.Add(http.MethodGet, s.formatPath("/slots"), resource.Slot.Info, auth.ActionRead)
+	routes.Add(http.MethodGet, s.formatPath("/allslot"), resource.Slot.Info, auth.ActionRead)
+	routes.Add(http.MethodGet, s.formatPath("/slots/:id/config"), resource.Slot.Info, auth.ActionRead)
+	routes.Add(http.MethodPost, s.formatPath("/slots/:id/migrate"), resource.Slot.Migrate, auth.ActionWrite)
+	routes.Add(http.MethodPost, s.formatPath("/slots/:id/leader_transfer"), resource.Slot.Migrate, auth.ActionWrite)
+	routes.Add(http.MethodGet, s.formatPath("/slots/balance"), resource.Slot.Info, auth.ActionRead)
+	routes.Add(http.MethodPost, s.formatPath("/slots/balance"), resource.Slot.Migrate, auth.ActionWrite)
+
+	// ================== 消息 ==================
+	routes.Add(http.MethodGet, s.formatPath("/messages"), resource.Message, auth.ActionRead)
+
+	// ================== 频道 ==================
+	routes.Add(http.MethodGet, s.formatPath("/channels"), resource.Channel.Info, auth.ActionRead)
+	routes.Add(http.MethodGet, s.formatPath("/channels/:channel_id/:channel_type/subscribers"), resource.Channel.Subscriber, auth.ActionRead)
+	routes.Add(http.MethodGet, s.formatPath("/channels/:channel_id/:channel_type/denylist"), resource.Channel.Denylist, auth.ActionRead)
+	routes.Add(http.MethodGet, s.formatPath("/channels/:channel_id/:channel_type/allowlist"), resource.Channel.Allowlist, auth.ActionRead)
+
+	// ================== 用户 ==================
+	routes.Add(http.MethodGet, s.formatPath("/users"), resource.User, auth.ActionRead)
+	routes.Add(http.MethodGet, s.formatPath("/devices"), resource.Device, auth.ActionRead)
+
+	// ================== 最近会话 ==================
+	routes.Add(http.MethodGet, s.formatPath("/conversations"), resource.Conversation, auth.ActionRead)
+
+	// ================== 集群 ==================
+	routes.Add(http.MethodGet, s.formatPath("/info"), resource.Cluster.Info, auth.ActionRead)
+	routes.Add(http.MethodGet, s.formatPath("/logs"), resource.Cluster.Log, auth.ActionRead)
+	routes.Add(http.MethodPost, s.formatPath("/leader_transfer"), resource.Node, auth.ActionWrite)
+
+	// ================== 审计日志 ==================
+	routes.Add(http.MethodGet, s.formatPath("/auditlogs"), resource.AuditLog, auth.ActionRead)
+
+	// ================== 频道分布式 ==================
+	routes.Add(http.MethodPost, s.formatPath("/channels/:channel_id/:channel_type/migrate"), resource.ClusterChannel.Migrate, auth.ActionWrite)
+	routes.Add(http.MethodPost, s.formatPath("/channels/:channel_id/:channel_type/leader_transfer"), resource.ClusterChannel.Migrate, auth.ActionWrite)
+	routes.Add(http.MethodGet, s.formatPath("/channels/:channel_id/:channel_type/config"), resource.ClusterChannel.Config, auth.ActionRead)
+	routes.Add(http.MethodPost, s.formatPath("/channels/:channel_id/:channel_type/start"), resource.ClusterChannel.Start, auth.ActionWrite)
+	routes.Add(http.MethodPost, s.formatPath("/channels/:channel_id/:channel_type/stop"), resource.ClusterChannel.Stop, auth.ActionWrite)
+	routes.Add(http.MethodPost, s.formatPath("/channel/status"), resource.ClusterChannel.Config, auth.ActionRead) // 查询接口，只需要读权限
+	routes.Add(http.MethodGet, s.formatPath("/channels/:channel_id/:channel_type/replicas"), resource.ClusterChannel.Config, auth.ActionRead)
+	routes.Add(http.MethodGet, s.formatPath("/channels/:channel_id/:channel_type/localReplica"), resource.ClusterChannel.Config, auth.ActionRead)
+	routes.Add(http.MethodGet, s.formatPath("/channels/balance"), resource.ClusterChannel.Config, auth.ActionRead)
+	routes.Add(http.MethodPost, s.formatPath("/channels/balance"), resource.ClusterChannel.Migrate, auth.ActionWrite)
+
+	return routes
+}
+
 func (s *Server) formatPath(path string) string {
 	var prefix = s.apiPrefix
 	if !strings.HasPrefix(prefix, "/") {
@@ -0,0 +1,322 @@
+package cluster
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/node/types"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	"go.uber.org/zap"
+)
+
+// 节点负载
+type nodeLoads struct {
+	sync.RWMutex
+	local            *NodeLoad            // 本节点的负载
+	loads            map[uint64]*NodeLoad // 所有节点的负载
+	lastProposeCount int64                // 上次采集时的提案数量
+	lastCollectAt    time.Time            // 上次采集时间
+}
+
+// 定时采集节点负载，并均衡频道副本
+// 槽的槽领导负责均衡属于此槽的频道，每轮的迁移数量按领导的槽数量分给各个槽领导
+func (s *Server) channelBalanceLoop() {
+	tk := time.NewTicker(s.opts.ChannelBalance.Interval)
+	defer tk.Stop()
+	for {
+		select {
+		case <-tk.C:
+			s.refreshLocalLoad()
+			s.collectNodeLoads()
+			if s.channelBalanceOn.Load() {
+				s.balanceChannels()
+			}
+		case <-s.stopper.ShouldStop():
+			return
+		}
+	}
+}
+
+// 刷新本节点的负载
+func (s *Server) refreshLocalLoad() {
+	now := time.Now()
+	proposeCount := s.channelServer.ProposeCount()
+
+	s.nodeLoads.Lock()
+	defer s.nodeLoads.Unlock()
+
+	var messageRate int64
+	if !s.nodeLoads.lastCollectAt.IsZero() {
+		seconds := now.Sub(s.nodeLoads.lastCollectAt).Seconds()
+		if seconds > 0 {
+			messageRate = int64(float64(proposeCount-s.nodeLoads.lastProposeCount) / seconds)
+		}
+	}
+	s.nodeLoads.lastProposeCount = proposeCount
+	s.nodeLoads.lastCollectAt = now
+	s.nodeLoads.local = &NodeLoad{
+		NodeId:       s.opts.ConfigOptions.NodeId,
+		ChannelCount: s.channelServer.ChannelCount(),
+		MessageRate:  messageRate,
+	}
+}
+
+// 本节点的负载
+func (s *Server) localNodeLoad() *NodeLoad {
+	s.nodeLoads.RLock()
+	defer s.nodeLoads.RUnlock()
+	if s.nodeLoads.local == nil {
+		return &NodeLoad{
+			NodeId:       s.opts.ConfigOptions.NodeId,
+			ChannelCount: s.channelServer.ChannelCount(),
+		}
+	}
+	return s.nodeLoads.local
+}
+
+// 采集所有在线节点的负载
+func (s *Server) collectNodeLoads() {
+	loads := make(map[uint64]*NodeLoad)
+	for _, node := range s.cfgServer.AllowVoteAndJoinedOnlineNodes() {
+		if node.Id == s.opts.ConfigOptions.NodeId {
+			loads[node.Id] = s.localNodeLoad()
+			continue
+		}
+		load, err := s.rpcClient.RequestNodeLoad(node.Id)
+		if err != nil {
+			s.Warn("collectNodeLoads: RequestNodeLoad failed", zap.Error(err), zap.Uint64("nodeId", node.Id))
+			continue
+		}
+		loads[node.Id] = load
+	}
+
+	s.nodeLoads.Lock()
+	s.nodeLoads.loads = loads
+	s.nodeLoads.Unlock()
+}
+
+// 获取所有节点负载的副本
+func (s *Server) getNodeLoads() map[uint64]*NodeLoad {
+	s.nodeLoads.RLock()
+	defer s.nodeLoads.RUnlock()
+	loads := make(map[uint64]*NodeLoad, len(s.nodeLoads.loads))
+	for nodeId, load := range s.nodeLoads.loads {
+		newLoad := *load
+		loads[nodeId] = &newLoad
+	}
+	return loads
+}
+
+// 所有节点所在的区域（取自集群配置）
+func (s *Server) nodeZones() map[uint64]string {
+	nodes := s.cfgServer.Nodes()
+	zones := make(map[uint64]string, len(nodes))
+	for _, node := range nodes {
+		zones[node.Id] = node.Zone
+	}
+	return zones
+}
+
+// 均衡频道副本，将热点节点上的频道领导和副本迁移到负载低的节点
+func (s *Server) balanceChannels() {
+	cfg := s.cfgServer.GetClusterConfig()
+
+	// 有节点离线或者有未加入（加入中/离开中）的节点，则暂停均衡
+	for _, node := range cfg.Nodes {
+		if !node.Online || node.Status != types.NodeStatus_NodeStatusJoined {
+			return
+		}
+	}
+
+	candidates := s.cfgServer.AllowVoteAndJoinedOnlineNodes()
+	loads := s.getNodeLoads()
+	if len(candidates) < 2 {
+		return
+	}
+	for _, node := range candidates {
+		if loads[node.Id] == nil { // 负载信息不完整
+			return
+		}
+	}
+
+	var totalScore int64
+	for _, node := range candidates {
+		totalScore += loads[node.Id].Score()
+	}
+	avgScore := float64(totalScore) / float64(len(candidates))
+	hotScore := int64(avgScore * (1 + s.opts.ChannelBalance.Threshold)) // 超过此分数则是热点节点
+	if hotScore <= 0 {
+		return
+	}
+
+	quota := channelBalanceQuota(cfg.Slots, s.opts.ConfigOptions.NodeId, s.opts.ChannelBalance.MaxMigrations)
+	if quota <= 0 {
+		return
+	}
+
+	count := 0
+	for _, slot := range cfg.Slots {
+		if slot.Leader != s.opts.ConfigOptions.NodeId {
+			continue
+		}
+		clusterConfigs, err := s.db.GetChannelClusterConfigWithSlotId(slot.Id)
+		if err != nil {
+			s.Error("balanceChannels: GetChannelClusterConfigWithSlotId error", zap.Error(err), zap.Uint32("slotId", slot.Id))
+			continue
+		}
+		for _, clusterConfig := range clusterConfigs {
+			if clusterConfig.MigrateFrom != 0 || clusterConfig.MigrateTo != 0 { // 迁移中
+				continue
+			}
+			if s.balanceChannel(clusterConfig, candidates, loads, hotScore, int64(avgScore)) {
+				count++
+				if count >= quota {
+					return
+				}
+			}
+		}
+	}
+}
+
+// 本节点每轮最多迁移的频道数量
+// MaxMigrations是整个集群每轮的限制，按领导的槽数量分给各个槽领导，余数按分配的余数从大到小分（余数相同时节点id小的优先）
+// 每个节点按相同的槽分布计算，分配的结果一致
+func channelBalanceQuota(slots []*types.Slot, nodeId uint64, maxMigrations int) int {
+	slotCountMap := make(map[uint64]int) // 每个节点领导的槽数量
+	total := 0
+	for _, slot := range slots {
+		if slot.Leader == 0 {
+			continue
+		}
+		slotCountMap[slot.Leader]++
+		total++
+	}
+	if slotCountMap[nodeId] == 0 {
+		return 0
+	}
+
+	type share struct {
+		nodeId    uint64
+		quota     int
+		remainder int
+	}
+	shares := make([]*share, 0, len(slotCountMap))
+	assigned := 0
+	for leaderId, slotCount := range slotCountMap {
+		sh := &share{
+			nodeId:    leaderId,
+			quota:     maxMigrations * slotCount / total,
+			remainder: maxMigrations * slotCount % total,
+		}
+		assigned += sh.quota
+		shares = append(shares, sh)
+	}
+	sort.Slice(shares, func(i, j int) bool {
+		if shares[i].remainder != shares[j].remainder {
+			return shares[i].remainder > shares[j].remainder
+		}
+		return shares[i].nodeId < shares[j].nodeId
+	})
+	for i := 0; i < maxMigrations-assigned; i++ {
+		shares[i].quota++
+	}
+	for _, sh := range shares {
+		if sh.nodeId == nodeId {
+			return sh.quota
+		}
+	}
+	return 0
+}
+
+// 均衡单个频道，返回是否发起了迁移
+func (s *Server) balanceChannel(clusterConfig wkdb.ChannelClusterConfig, candidates []*types.Node, loads map[uint64]*NodeLoad, hotScore, avgScore int64) bool {
+
+	// 找出副本中负载最高的热点节点
+	var hotNodeId uint64
+	for _, replicaId := range clusterConfig.Replicas {
+		load := loads[replicaId]
+		if load == nil || load.Score() <= hotScore {
+			continue
+		}
+		if hotNodeId == 0 || load.Score() > loads[hotNodeId].Score() {
+			hotNodeId = replicaId
+		}
+	}
+	if hotNodeId == 0 {
+		return false
+	}
+	hotLoad := loads[hotNodeId]
+
+	// 每个频道平均的消息速率，用于估算迁移后节点的负载
+	var channelMessageRate int64
+	if hotLoad.ChannelCount > 0 {
+		channelMessageRate = hotLoad.MessageRate / int64(hotLoad.ChannelCount)
+	}
+
+	// 热点节点是频道领导，优先将领导转移给负载低的副本（不需要同步数据）
+	if clusterConfig.LeaderId == hotNodeId {
+		var toNodeId uint64
+		for _, replicaId := range clusterConfig.Replicas {
+			load := loads[replicaId]
+			if replicaId == hotNodeId || load == nil || load.Score() >= avgScore {
+				continue
+			}
+			if toNodeId == 0 || load.Score() < loads[toNodeId].Score() {
+				toNodeId = replicaId
+			}
+		}
+		if toNodeId != 0 {
+			err := s.migrateChannel(clusterConfig, hotNodeId, toNodeId)
+			if err != nil {
+				s.Warn("balanceChannel: migrate leader failed", zap.Error(err), zap.String("channelId", clusterConfig.ChannelId), zap.Uint8("channelType", clusterConfig.ChannelType))
+				return false
+			}
+			hotLoad.MessageRate -= channelMessageRate
+			loads[toNodeId].MessageRate += channelMessageRate
+			return true
+		}
+	}
+
+	// 将热点节点上的副本迁移到负载低的节点上
+	targets := make([]*types.Node, 0, len(candidates))
+	for _, node := range candidates {
+		load := loads[node.Id]
+		if wkutil.ArrayContainsUint64(clusterConfig.Replicas, node.Id) || load == nil || load.Score() >= avgScore {
+			continue
+		}
+		targets = append(targets, node)
+	}
+	if len(targets) == 0 {
+		return false
+	}
+	must := wkutil.RemoveUint64(append([]uint64{}, clusterConfig.Replicas...), hotNodeId)
+	replicaIds := s.opts.ChannelPlacement.Select(targets, must, len(must)+1, loads, s.nodeZones())
+	if len(replicaIds) <= len(must) {
+		return false
+	}
+	toNodeId := replicaIds[len(must)]
+	err := s.migrateChannel(clusterConfig, hotNodeId, toNodeId)
+	if err != nil {
+		s.Warn("balanceChannel: migrate replica failed", zap.Error(err), zap.String("channelId", clusterConfig.ChannelId), zap.Uint8("channelType", clusterConfig.ChannelType))
+		return false
+	}
+	hotLoad.ChannelCount--
+	loads[toNodeId].ChannelCount++
+	if clusterConfig.LeaderId == hotNodeId { // 领导也会随之迁移
+		hotLoad.MessageRate -= channelMessageRate
+		loads[toNodeId].MessageRate += channelMessageRate
+	}
+	return true
+}
+
+// SetChannelBalance 开启或关闭频道副本均衡
+func (s *Server) SetChannelBalance(on bool) {
+	s.channelBalanceOn.Store(on)
+}
+
+// ChannelBalanceOn 频道副本均衡是否开启
+func (s *Server) ChannelBalanceOn() bool {
+	return s.channelBalanceOn.Load()
+}
@@ -0,0 +1,138 @@
+package cluster
+
+import (
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/node/types"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	"go.uber.org/zap"
+)
+
+// 每轮最多迁移的频道数量
+const drainChannelBatchSize = 100
+
+// 迁移离开节点上的频道副本
+// 槽的槽领导负责迁移属于此槽的频道，槽副本的迁移由配置领导负责（见event.handleNodeLeaving）
+func (s *Server) drainLoop() {
+	tk := time.NewTicker(time.Second * 2)
+	defer tk.Stop()
+	for {
+		select {
+		case <-tk.C:
+			leavingNodes := s.cfgServer.LeavingNodes()
+			if len(leavingNodes) == 0 {
+				continue
+			}
+			s.drainChannels(leavingNodes[0].Id)
+		case <-s.stopper.ShouldStop():
+			return
+		}
+	}
+}
+
+func (s *Server) drainChannels(leavingNodeId uint64) {
+	targetNodes := s.cfgServer.AllowVoteAndJoinedOnlineNodes()
+	if len(targetNodes) == 0 {
+		return
+	}
+	migrateCountMap := make(map[uint64]int) // 本轮每个节点迁入的频道数量
+
+	count := 0
+	for _, slot := range s.cfgServer.Slots() {
+		if slot.Leader != s.opts.ConfigOptions.NodeId {
+			continue
+		}
+		clusterConfigs, err := s.db.GetChannelClusterConfigWithSlotId(slot.Id)
+		if err != nil {
+			s.Error("drainChannels: GetChannelClusterConfigWithSlotId error", zap.Error(err), zap.Uint32("slotId", slot.Id))
+			continue
+		}
+		for _, clusterConfig := range clusterConfigs {
+			if !wkutil.ArrayContainsUint64(clusterConfig.Replicas, leavingNodeId) {
+				continue
+			}
+			if clusterConfig.MigrateFrom != 0 || clusterConfig.MigrateTo != 0 { // 迁移中
+				continue
+			}
+
+			// 选出不在副本中且本轮迁入最少的节点
+			var migrateTo uint64
+			for _, node := range targetNodes {
+				if wkutil.ArrayContainsUint64(clusterConfig.Replicas, node.Id) {
+					continue
+				}
+				if migrateTo == 0 || migrateCountMap[node.Id] < migrateCountMap[migrateTo] {
+					migrateTo = node.Id
+				}
+			}
+			if migrateTo == 0 {
+				s.Warn("drainChannels: no node can migrate to", zap.String("channelId", clusterConfig.ChannelId), zap.Uint8("channelType", clusterConfig.ChannelType))
+				continue
+			}
+
+			err = s.migrateChannel(clusterConfig, leavingNodeId, migrateTo)
+			if err != nil {
+				s.Error("drainChannels: migrateChannel error", zap.Error(err), zap.String("channelId", clusterConfig.ChannelId), zap.Uint8("channelType", clusterConfig.ChannelType))
+				continue
+			}
+			migrateCountMap[migrateTo]++
+
+			count++
+			if count >= drainChannelBatchSize {
+				return
+			}
+		}
+	}
+}
+
+// 本节点负责的（本节点为槽领导的）还包含指定节点的频道数量
+func (s *Server) localDrainChannelCount(nodeId uint64) (int, error) {
+	count := 0
+	for _, slot := range s.cfgServer.Slots() {
+		if slot.Leader != s.opts.ConfigOptions.NodeId {
+			continue
+		}
+		clusterConfigs, err := s.db.GetChannelClusterConfigWithSlotId(slot.Id)
+		if err != nil {
+			return 0, err
+		}
+		for _, clusterConfig := range clusterConfigs {
+			if wkutil.ArrayContainsUint64(clusterConfig.Replicas, nodeId) || wkutil.ArrayContainsUint64(clusterConfig.Learners, nodeId) {
+				count++
+			}
+		}
+	}
+	return count, nil
+}
+
+// 还包含指定节点的槽数量
+func (s *Server) drainSlotCount(nodeId uint64, cfg *types.Config) int {
+	count := 0
+	for _, st := range cfg.Slots {
+		if wkutil.ArrayContainsUint64(st.Replicas, nodeId) || wkutil.ArrayContainsUint64(st.Learners, nodeId) {
+			count++
+		}
+	}
+	return count
+}
+
+// 检查当前节点是否开始离开集群
+func (s *Server) checkLocalNodeLeaving(cfg *types.Config) {
+	for _, node := range cfg.Nodes {
+		if node.Id != s.opts.ConfigOptions.NodeId {
+			continue
+		}
+		if node.Status == types.NodeStatus_NodeStatusLeaving && s.leaving.CompareAndSwap(false, true) {
+			s.Info("local node is leaving the cluster")
+			if s.opts.OnNodeLeaving != nil {
+				s.opts.OnNodeLeaving()
+			}
+		}
+		return
+	}
+}
+
+// IsLeaving 当前节点是否正在离开集群
+func (s *Server) IsLeaving() bool {
+	return s.leaving.Load()
+}
@@ -0,0 +1,107 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/node/types"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDrainChannels(t *testing.T) {
+	s1, s2, s3 := newThreeBootstrap(t)
+	start(t, s1, s2, s3)
+	defer s1.Stop()
+	defer s2.Stop()
+	defer s3.Stop()
+
+	waitAllSlotReady(s1, s2, s3)
+	waitApiServerAddr(s1, s2, s3)
+
+	servers := map[uint64]*Server{1: s1, 2: s2, 3: s3}
+
+	channelId, channelType := "drain", wkproto.ChannelTypeGroup
+	slotLeaderId, err := s1.SlotLeaderIdOfChannel(channelId, channelType)
+	assert.NoError(t, err)
+	slotLeader := servers[slotLeaderId]
+
+	_, err = slotLeader.store.SaveChannelClusterConfig(wkdb.ChannelClusterConfig{
+		ChannelId:       channelId,
+		ChannelType:     channelType,
+		ReplicaMaxCount: 2,
+		Replicas:        []uint64{1, 3},
+		LeaderId:        1,
+		Term:            1,
+	})
+	assert.NoError(t, err)
+
+	count, err := slotLeader.localDrainChannelCount(3)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	// 节点3开始离开
+	cfgLeader := servers[s1.cfgServer.LeaderId()]
+	err = cfgLeader.cfgServer.ProposeNodeStatus(3, types.NodeStatus_NodeStatusLeaving)
+	assert.NoError(t, err)
+
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	for slotLeader.cfgServer.Node(3).Status != types.NodeStatus_NodeStatusLeaving {
+		select {
+		case <-timeoutCtx.Done():
+			t.Fatal("wait node leaving timeout")
+		case <-time.After(time.Millisecond * 10):
+		}
+	}
+
+	slotLeader.drainChannels(3)
+
+	// 迁到唯一不在副本里的节点2，drainLoop也可能已经完成了迁移
+	clusterConfig, err := slotLeader.db.GetChannelClusterConfig(channelId, channelType)
+	assert.NoError(t, err)
+	if clusterConfig.MigrateFrom != 0 {
+		assert.Equal(t, uint64(3), clusterConfig.MigrateFrom)
+		assert.Equal(t, uint64(2), clusterConfig.MigrateTo)
+		assert.Contains(t, clusterConfig.Learners, uint64(2))
+	} else {
+		assert.Contains(t, clusterConfig.Replicas, uint64(2))
+		assert.NotContains(t, clusterConfig.Replicas, uint64(3))
+	}
+}
+
+func TestDrainSlotLeaders(t *testing.T) {
+	cfg := &types.Config{
+		Nodes: []*types.Node{
+			{Id: 1, Online: true},
+			{Id: 2, Online: true},
+			{Id: 3, Online: false},
+		},
+		Slots: []*types.Slot{
+			{Id: 1, Leader: 1},
+			{Id: 2, Leader: 2},
+			{Id: 3, Leader: 2},
+			{Id: 4, Leader: 3}, // 领导离线
+			{Id: 5, Leader: 0}, // 没有领导
+		},
+	}
+	leaderIds, unknownSlotCount := drainSlotLeaders(1, cfg)
+	assert.Equal(t, []uint64{1, 2}, leaderIds)
+	assert.Equal(t, 2, unknownSlotCount)
+
+	// 本节点是领导时，即使配置里是离线也直接查本地
+	leaderIds, unknownSlotCount = drainSlotLeaders(3, cfg)
+	assert.Equal(t, []uint64{1, 2, 3}, leaderIds)
+	assert.Equal(t, 1, unknownSlotCount)
+}
+
+func TestDrainDone(t *testing.T) {
+	assert.True(t, drainDone(types.NodeStatus_NodeStatusLeaving, 0, 0, 0))
+	assert.False(t, drainDone(types.NodeStatus_NodeStatusJoined, 0, 0, 0))
+	assert.False(t, drainDone(types.NodeStatus_NodeStatusLeaving, 1, 0, 0))
+	assert.False(t, drainDone(types.NodeStatus_NodeStatusLeaving, 0, 1, 0))
+	// 有槽的频道数量未知，不能算完成
+	assert.False(t, drainDone(types.NodeStatus_NodeStatusLeaving, 0, 0, 1))
+}
@@ -15,6 +15,7 @@ func (s *Server) joinLoop() {
 		NodeId:     s.opts.ConfigOptions.NodeId,
 		ServerAddr: s.opts.ServerAddr,
 		Role:       s.opts.Role,
+		Zone:       s.opts.ConfigOptions.Zone,
 	}
 	for {
 		select {
@@ -0,0 +1,32 @@
+package cluster
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+)
+
+// NewTLSConfig 创建节点之间通讯的tls配置（mTLS）
+// 节点证书需要由集群CA签发，CommonName为节点id，同时包含serverAuth和clientAuth用途
+func NewTLSConfig(certFile, keyFile, caFile string, minVersion uint16) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	caData, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	caPool := x509.NewCertPool()
+	if !caPool.AppendCertsFromPEM(caData) {
+		return nil, errors.New("no valid certificate in ca file")
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      caPool, // 作为客户端时校验对方节点的证书
+		ClientCAs:    caPool, // 作为服务端时校验对方节点的证书
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   minVersion,
+	}, nil
+}
@@ -21,6 +21,9 @@ const (
 	CMDTypeSlotMigrate                       // 槽迁移
 	CMDTypeSlotUpdate                        // 槽更新
 	CMDTypeNodeStatusChange                  // 节点状态改变
+	CMDTypeNodeRemove                        // 节点移除
+	CMDTypeNodeLabelChange                   // 节点区域标签变更
+	CMDTypeSlotReplicaBalanceChange          // 槽副本均衡开关变更
 
 )
 
@@ -48,6 +51,12 @@ func (c CMDType) String() string {
 		return "CMDTypeSlotUpdate"
 	case CMDTypeNodeStatusChange:
 		return "CMDTypeNodeStatusChange"
+	case CMDTypeNodeRemove:
+		return "CMDTypeNodeRemove"
+	case CMDTypeNodeLabelChange:
+		return "CMDTypeNodeLabelChange"
+	case CMDTypeSlotReplicaBalanceChange:
+		return "CMDTypeSlotReplicaBalanceChange"
 	}
 	return "CMDTypeUnknown"
 }
@@ -169,6 +178,28 @@ func (c *CMD) CMDContent() (string, error) {
 			"nodeId": nodeId,
 			"status": status,
 		}), nil
+	case CMDTypeNodeRemove:
+		nodeId := binary.BigEndian.Uint64(c.Data)
+		return wkutil.ToJSON(map[string]interface{}{
+			"nodeId": nodeId,
+		}), nil
+	case CMDTypeNodeLabelChange:
+		nodeId, label, err := DecodeNodeLabelChange(c.Data)
+		if err != nil {
+			return "", err
+		}
+		return wkutil.ToJSON(map[string]interface{}{
+			"nodeId": nodeId,
+			"zone":   label.Zone,
+		}), nil
+	case CMDTypeSlotReplicaBalanceChange:
+		balance, err := DecodeSlotReplicaBalanceChange(c.Data)
+		if err != nil {
+			return "", err
+		}
+		return wkutil.ToJSON(map[string]interface{}{
+			"slotReplicaBalance": balance,
+		}), nil
 	}
 
 	return "", nil
@@ -311,3 +342,37 @@ func DecodeLeaderChange(data []byte) (uint64, error) {
 	}
 	return leaderId, nil
 }
+
+func EncodeNodeLabelChange(nodeId uint64, label NodeLabel) ([]byte, error) {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteUint64(nodeId)
+	enc.WriteString(label.Zone)
+	return enc.Bytes(), nil
+}
+
+func DecodeNodeLabelChange(data []byte) (uint64, NodeLabel, error) {
+	dec := wkproto.NewDecoder(data)
+	var err error
+	var nodeId uint64
+	var label NodeLabel
+	if nodeId, err = dec.Uint64(); err != nil {
+		return 0, label, err
+	}
+	if label.Zone, err = dec.String(); err != nil {
+		return 0, label, err
+	}
+	return nodeId, label, nil
+}
+
+func EncodeSlotReplicaBalanceChange(balance uint32) ([]byte, error) {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteUint32(balance)
+	return enc.Bytes(), nil
+}
+
+func DecodeSlotReplicaBalanceChange(data []byte) (uint32, error) {
+	dec := wkproto.NewDecoder(data)
+	return dec.Uint32()
+}
@@ -12,6 +12,13 @@ import (
 	"go.uber.org/zap"
 )
 
+// 槽副本均衡开关（types.Config.SlotReplicaBalance）
+const (
+	SlotReplicaBalanceUnset uint32 = iota // 未设置，使用启动配置（Options.SlotReplicaBalanceOn）
+	SlotReplicaBalanceOn                  // 开启
+	SlotReplicaBalanceOff                 // 关闭
+)
+
 type Config struct {
 	cfg     *types.Config // 配置文件
 	cfgFile *os.File
@@ -104,6 +111,17 @@ func (c *Config) data() ([]byte, error) {
 	return c.cfg.Marshal()
 }
 
+// 配置快照，返回配置版本（也就是已应用的日志下标）、任期和配置数据
+func (c *Config) snapshot() (uint64, uint32, []byte, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	data, err := c.cfg.Marshal()
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	return c.cfg.Version, c.cfg.Term, data, nil
+}
+
 func (c *Config) update(cfg *types.Config) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -182,6 +200,23 @@ func (c *Config) updateApiServerAddr(nodeId uint64, addr string) {
 	}
 }
 
+func (c *Config) updateNodeLabel(nodeId uint64, label NodeLabel) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, node := range c.cfg.Nodes {
+		if node.Id == nodeId {
+			node.Zone = label.Zone
+			return
+		}
+	}
+}
+
+func (c *Config) updateSlotReplicaBalance(balance uint32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cfg.SlotReplicaBalance = balance
+}
+
 func (c *Config) updateNodeOnlineStatus(nodeId uint64, online bool) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -280,6 +315,23 @@ func (c *Config) updateNodeStatus(nodeId uint64, status types.NodeStatus) {
 	}
 }
 
+// 移除节点
+func (c *Config) removeNode(nodeId uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, node := range c.cfg.Nodes {
+		if node.Id == nodeId {
+			c.cfg.Nodes = append(c.cfg.Nodes[:i], c.cfg.Nodes[i+1:]...)
+			break
+		}
+	}
+	c.cfg.Learners = wkutil.RemoveUint64(c.cfg.Learners, nodeId)
+	if c.cfg.MigrateFrom == nodeId || c.cfg.MigrateTo == nodeId {
+		c.cfg.MigrateFrom = 0
+		c.cfg.MigrateTo = 0
+	}
+}
+
 func (c *Config) config() *types.Config {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -299,6 +351,19 @@ func (c *Config) slotReplicaCount() uint32 {
 	return c.cfg.SlotReplicaCount
 }
 
+// 槽副本均衡是否开启，集群配置中未设置时使用启动配置
+func (c *Config) slotReplicaBalanceOn() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	switch c.cfg.SlotReplicaBalance {
+	case SlotReplicaBalanceOn:
+		return true
+	case SlotReplicaBalanceOff:
+		return false
+	}
+	return c.opts.SlotReplicaBalanceOn
+}
+
 // 根据id获取slot信息
 func (c *Config) slot(id uint32) *types.Slot {
 	c.mu.RLock()
@@ -447,6 +512,19 @@ func (c *Config) willJoinNodes() []*types.Node {
 	return nodes
 }
 
+// 离开中的节点
+func (c *Config) leavingNodes() []*types.Node {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var nodes []*types.Node
+	for _, n := range c.cfg.Nodes {
+		if n.Status == types.NodeStatus_NodeStatusLeaving {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
 func (c *Config) saveConfig() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -9,6 +9,8 @@ const (
 	maxIndexKeySize             uint64 = 4
 	appliedIndexKeySize         uint64 = 4
 	leaderTermStartIndexKeySize uint64 = 12
+	compactedKeySize            uint64 = 4
+	termVoteKeySize             uint64 = 4
 )
 
 var (
@@ -16,6 +18,8 @@ var (
 	appliedIndexKey               = [2]byte{0x2, 0x2}
 	maxIndexKeyHeader             = [2]byte{0x3, 0x3}
 	leaderTermStartIndexKeyHeader = [2]byte{0x4, 0x4}
+	compactedKey                  = [2]byte{0x5, 0x5}
+	termVoteKey                   = [2]byte{0x6, 0x6}
 )
 
 func NewLogKey(index uint64) []byte {
@@ -59,3 +63,23 @@ func NewAppliedIndexKey() []byte {
 	key[3] = 0
 	return key
 }
+
+// NewCompactedKey 已压缩的日志下标和任期
+func NewCompactedKey() []byte {
+	key := make([]byte, compactedKeySize)
+	key[0] = compactedKey[0]
+	key[1] = compactedKey[1]
+	key[2] = 0
+	key[3] = 0
+	return key
+}
+
+// NewTermVoteKey 当前任期和投票记录
+func NewTermVoteKey() []byte {
+	key := make([]byte, termVoteKeySize)
+	key[0] = termVoteKey[0]
+	key[1] = termVoteKey[1]
+	key[2] = 0
+	key[3] = 0
+	return key
+}
@@ -23,6 +23,18 @@ type Options struct {
 
 	PongMaxTick int // pongMaxTick 节点超过多少tick没有回应心跳就认为是掉线
 
+	SlotReplicaBalanceOn          bool // 是否开启槽副本自动均衡
+	SlotReplicaBalanceConcurrency int  // 槽副本均衡（以及节点离开）时同时迁移的最大槽数量
+
+	Zone           string               // 本节点所在区域（可用区）
+	InitNodeLabels map[uint64]NodeLabel // 初始化节点的区域标签 key为节点id
+
+	CompactLogThreshold uint64 // 已应用但未压缩的配置日志超过此数量时压缩日志，0表示不压缩
+	CompactLogRetain    uint64 // 压缩日志时保留最近的日志数量
+
+	PreVote     bool // 配置raft是否开启预投票（所有节点升级到支持预投票的版本后才能开启）
+	CheckQuorum bool // 配置raft是否开启法定数检查
+
 	// Seed  种子节点，可以引导新节点加入集群  格式：nodeId@ip:port （nodeId为种子节点的nodeId）
 	Seed string
 }
@@ -35,6 +47,12 @@ func NewOptions(opts ...Option) *Options {
 		ChannelMaxReplicaCount: 3,
 		ConfigPath:             "clusterconfig.json",
 		PongMaxTick:            30,
+
+		SlotReplicaBalanceOn:          false,
+		SlotReplicaBalanceConcurrency: 2,
+
+		CompactLogThreshold: 10000,
+		CompactLogRetain:    1000,
 	}
 	for _, opt := range opts {
 		opt(o)
@@ -45,6 +63,11 @@ func NewOptions(opts ...Option) *Options {
 
 type Option func(*Options)
 
+// NodeLabel 节点的位置标签
+type NodeLabel struct {
+	Zone string // 区域（可用区）
+}
+
 func WithNodeId(nodeId uint64) Option {
 	return func(o *Options) {
 		o.NodeId = nodeId
@@ -109,3 +132,51 @@ func WithSeed(seed string) Option {
 		o.Seed = seed
 	}
 }
+
+func WithSlotReplicaBalanceOn(on bool) Option {
+	return func(o *Options) {
+		o.SlotReplicaBalanceOn = on
+	}
+}
+
+func WithSlotReplicaBalanceConcurrency(concurrency int) Option {
+	return func(o *Options) {
+		o.SlotReplicaBalanceConcurrency = concurrency
+	}
+}
+
+func WithInitNodeLabels(labels map[uint64]NodeLabel) Option {
+	return func(o *Options) {
+		o.InitNodeLabels = labels
+	}
+}
+
+func WithZone(zone string) Option {
+	return func(o *Options) {
+		o.Zone = zone
+	}
+}
+
+func WithPreVote(preVote bool) Option {
+	return func(o *Options) {
+		o.PreVote = preVote
+	}
+}
+
+func WithCheckQuorum(checkQuorum bool) Option {
+	return func(o *Options) {
+		o.CheckQuorum = checkQuorum
+	}
+}
+
+func WithCompactLogThreshold(threshold uint64) Option {
+	return func(o *Options) {
+		o.CompactLogThreshold = threshold
+	}
+}
+
+func WithCompactLogRetain(retain uint64) Option {
+	return func(o *Options) {
+		o.CompactLogRetain = retain
+	}
+}
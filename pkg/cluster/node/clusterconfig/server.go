@@ -107,6 +107,10 @@ func (s *Server) initRaft() error {
 		raft.WithTransport(newRaftTransport(s)),
 		raft.WithStorage(s.storage),
 		raft.WithElectionOn(true),
+		raft.WithPreVote(s.opts.PreVote),
+		raft.WithCheckQuorum(s.opts.CheckQuorum),
+		raft.WithCompactLogThreshold(s.opts.CompactLogThreshold),
+		raft.WithCompactLogRetain(s.opts.CompactLogRetain),
 	))
 	s.raft.Step(rafttypes.Event{
 		Type:   rafttypes.ConfChange,
@@ -193,6 +197,14 @@ func (s *Server) LeaderId() uint64 {
 	return s.raft.LeaderId()
 }
 
+// TransferLeader 将配置的领导权转让给指定节点
+func (s *Server) TransferLeader(ctx context.Context, to uint64) error {
+	if s.raft == nil {
+		return raft.ErrNotLeader
+	}
+	return s.raft.TransferLeader(ctx, to)
+}
+
 func (s *Server) GetClusterConfig() *types.Config {
 	if s.config.cfg == nil {
 		return nil
@@ -232,6 +244,11 @@ func (s *Server) SlotLeaderId(id uint32) uint64 {
 	return st.Leader
 }
 
+// SlotReplicaBalanceOn 槽副本均衡是否开启
+func (s *Server) SlotReplicaBalanceOn() bool {
+	return s.config.slotReplicaBalanceOn()
+}
+
 // AllowVoteNodes 获取允许投票的节点
 func (s *Server) AllowVoteNodes() []*pb.Node {
 	return s.config.allowVoteNodes()
@@ -258,6 +275,11 @@ func (s *Server) AllowVoteAndJoinedOnlineNodes() []*pb.Node {
 	return s.config.allowVoteAndJoinedOnlineNodes()
 }
 
+// 获取离开中的节点
+func (s *Server) LeavingNodes() []*pb.Node {
+	return s.config.leavingNodes()
+}
+
 // 节点是否在线
 func (s *Server) NodeIsOnline(nodeId uint64) bool {
 	return s.config.nodeOnline(nodeId)
@@ -61,6 +61,16 @@ func (s *Server) handleCmd(cmd *CMD) error {
 		return s.handleNodeJoining(cmd)
 	case CMDTypeNodeJoined: // 节点加入完成
 		return s.handleNodeJoined(cmd)
+	case CMDTypeNodeStatusChange: // 节点状态改变
+		return s.handleNodeStatusChange(cmd)
+	case CMDTypeSlotMigrate: // 槽迁移
+		return s.handleSlotMigrate(cmd)
+	case CMDTypeNodeRemove: // 节点移除
+		return s.handleNodeRemove(cmd)
+	case CMDTypeNodeLabelChange: // 节点区域/机架标签变更
+		return s.handleNodeLabelChange(cmd)
+	case CMDTypeSlotReplicaBalanceChange: // 槽副本均衡开关变更
+		return s.handleSlotReplicaBalanceChange(cmd)
 	}
 	return nil
 }
@@ -148,3 +158,50 @@ func (s *Server) handleNodeJoined(cmd *CMD) error {
 	s.switchConfig(s.config)
 	return nil
 }
+
+func (s *Server) handleNodeStatusChange(cmd *CMD) error {
+	nodeId, status, err := DecodeNodeStatusChange(cmd.Data)
+	if err != nil {
+		s.Error("decode node status change err", zap.Error(err))
+		return err
+	}
+	s.config.updateNodeStatus(nodeId, status)
+	return nil
+}
+
+func (s *Server) handleSlotMigrate(cmd *CMD) error {
+	slotId, fromNodeId, toNodeId, err := DecodeMigrateSlot(cmd.Data)
+	if err != nil {
+		s.Error("decode slot migrate err", zap.Error(err))
+		return err
+	}
+	s.config.updateSlotMigrate(slotId, fromNodeId, toNodeId)
+	return nil
+}
+
+func (s *Server) handleNodeRemove(cmd *CMD) error {
+	nodeId := binary.BigEndian.Uint64(cmd.Data)
+	s.config.removeNode(nodeId)
+	s.switchConfig(s.config)
+	return nil
+}
+
+func (s *Server) handleNodeLabelChange(cmd *CMD) error {
+	nodeId, label, err := DecodeNodeLabelChange(cmd.Data)
+	if err != nil {
+		s.Error("decode node label change err", zap.Error(err))
+		return err
+	}
+	s.config.updateNodeLabel(nodeId, label)
+	return nil
+}
+
+func (s *Server) handleSlotReplicaBalanceChange(cmd *CMD) error {
+	balance, err := DecodeSlotReplicaBalanceChange(cmd.Data)
+	if err != nil {
+		s.Error("decode slot replica balance change err", zap.Error(err))
+		return err
+	}
+	s.config.updateSlotReplicaBalance(balance)
+	return nil
+}
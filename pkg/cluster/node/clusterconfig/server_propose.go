@@ -156,3 +156,115 @@ func (s *Server) ProposeJoin(node *pb.Node) error {
 	}
 	return nil
 }
+
+// ProposeNodeStatus 提案节点状态变更
+func (s *Server) ProposeNodeStatus(nodeId uint64, status pb.NodeStatus) error {
+	data, err := EncodeNodeStatusChange(nodeId, status)
+	if err != nil {
+		return err
+	}
+
+	cmd := NewCMD(CMDTypeNodeStatusChange, data)
+	cmdBytes, err := cmd.Marshal()
+	if err != nil {
+		return err
+	}
+
+	logId := s.genConfigId()
+	_, err = s.ProposeUntilApplied(logId, cmdBytes)
+	if err != nil {
+		s.Error("ProposeNodeStatus failed", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// ProposeMigrateSlot 提案槽迁移
+func (s *Server) ProposeMigrateSlot(slotId uint32, fromNodeId, toNodeId uint64) error {
+	data, err := EncodeMigrateSlot(slotId, fromNodeId, toNodeId)
+	if err != nil {
+		return err
+	}
+
+	cmd := NewCMD(CMDTypeSlotMigrate, data)
+	cmdBytes, err := cmd.Marshal()
+	if err != nil {
+		return err
+	}
+
+	logId := s.genConfigId()
+	_, err = s.ProposeUntilApplied(logId, cmdBytes)
+	if err != nil {
+		s.Error("ProposeMigrateSlot failed", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// ProposeNodeRemove 提案移除节点（节点必须已经没有任何槽副本）
+func (s *Server) ProposeNodeRemove(nodeId uint64) error {
+
+	nodeIdBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(nodeIdBytes, nodeId)
+
+	cmd := NewCMD(CMDTypeNodeRemove, nodeIdBytes)
+	cmdBytes, err := cmd.Marshal()
+	if err != nil {
+		s.Error("ProposeNodeRemove cmd marshal failed", zap.Error(err))
+		return err
+	}
+
+	logId := s.genConfigId()
+	_, err = s.ProposeUntilApplied(logId, cmdBytes)
+	if err != nil {
+		s.Error("ProposeNodeRemove failed", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// ProposeNodeLabel 提案节点区域/机架标签变更
+func (s *Server) ProposeNodeLabel(nodeId uint64, label NodeLabel) error {
+	data, err := EncodeNodeLabelChange(nodeId, label)
+	if err != nil {
+		return err
+	}
+
+	cmd := NewCMD(CMDTypeNodeLabelChange, data)
+	cmdBytes, err := cmd.Marshal()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.ProposeUntilApplied(s.genConfigId(), cmdBytes)
+	if err != nil {
+		s.Error("ProposeNodeLabel failed", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// ProposeSlotReplicaBalance 提案开启或关闭槽副本均衡（保存在集群配置中，所有节点生效，重启后依然有效）
+func (s *Server) ProposeSlotReplicaBalance(on bool) error {
+	balance := SlotReplicaBalanceOff
+	if on {
+		balance = SlotReplicaBalanceOn
+	}
+	data, err := EncodeSlotReplicaBalanceChange(balance)
+	if err != nil {
+		return err
+	}
+
+	cmd := NewCMD(CMDTypeSlotReplicaBalanceChange, data)
+	cmdBytes, err := cmd.Marshal()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.ProposeUntilApplied(s.genConfigId(), cmdBytes)
+	if err != nil {
+		s.Error("ProposeSlotReplicaBalance failed", zap.Error(err))
+		return err
+	}
+	return nil
+}
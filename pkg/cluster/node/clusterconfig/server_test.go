@@ -2,10 +2,12 @@ package clusterconfig_test
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/node/clusterconfig"
+	pb "github.com/WuKongIM/WuKongIM/pkg/cluster/node/types"
 	"github.com/WuKongIM/WuKongIM/pkg/raft/types"
 	"github.com/stretchr/testify/assert"
 )
@@ -38,6 +40,252 @@ func TestServerPropose(t *testing.T) {
 
 }
 
+func TestServerProposeNodeLeavingAndRemove(t *testing.T) {
+	s1, s2 := newTwoNodes(t)
+	err := s1.Start()
+	assert.NoError(t, err)
+	err = s2.Start()
+	assert.NoError(t, err)
+
+	defer s1.Stop()
+	defer s2.Stop()
+
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	waitHasLeader(timeoutCtx, s1, s2)
+
+	leader := getLeader(s1, s2)
+	assert.NotNil(t, leader)
+
+	err = leader.ProposeConfig(&pb.Config{
+		Nodes: []*pb.Node{
+			{Id: 1, Status: pb.NodeStatus_NodeStatusJoined},
+			{Id: 2, Status: pb.NodeStatus_NodeStatusJoined},
+			{Id: 3, Status: pb.NodeStatus_NodeStatusJoined},
+		},
+	})
+	assert.NoError(t, err)
+
+	// 标记节点3离开中
+	err = leader.ProposeNodeStatus(3, pb.NodeStatus_NodeStatusLeaving)
+	assert.NoError(t, err)
+	assert.Equal(t, pb.NodeStatus_NodeStatusLeaving, leader.Node(3).Status)
+	assert.Equal(t, 1, len(leader.LeavingNodes()))
+
+	// 移除节点3
+	err = leader.ProposeNodeRemove(3)
+	assert.NoError(t, err)
+	assert.Nil(t, leader.Node(3))
+	assert.Equal(t, 2, len(leader.Nodes()))
+	assert.Equal(t, 0, len(leader.LeavingNodes()))
+}
+
+func TestServerProposeNodeLabel(t *testing.T) {
+	s1, s2 := newTwoNodes(t)
+	err := s1.Start()
+	assert.NoError(t, err)
+	err = s2.Start()
+	assert.NoError(t, err)
+
+	defer s1.Stop()
+	defer s2.Stop()
+
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	waitHasLeader(timeoutCtx, s1, s2)
+
+	leader := getLeader(s1, s2)
+	assert.NotNil(t, leader)
+
+	err = leader.ProposeConfig(&pb.Config{
+		Nodes: []*pb.Node{
+			{Id: 1, Status: pb.NodeStatus_NodeStatusJoined},
+			{Id: 2, Status: pb.NodeStatus_NodeStatusJoined},
+		},
+	})
+	assert.NoError(t, err)
+
+	err = leader.ProposeNodeLabel(2, clusterconfig.NodeLabel{Zone: "az2"})
+	assert.NoError(t, err)
+	assert.Equal(t, "az2", leader.Node(2).Zone)
+	assert.Equal(t, "", leader.Node(1).Zone)
+}
+
+func TestServerProposeSlotReplicaBalance(t *testing.T) {
+	tt := newTestTransport()
+	// 开启预投票和法定数检查，下面重启的节点不会发起选举打断现有领导，避免重启后的提案因为换领导而超时
+	opts1 := newTestOptions(t, 1, map[uint64]string{1: "", 2: ""}, clusterconfig.WithTransport(tt), clusterconfig.WithPreVote(true), clusterconfig.WithCheckQuorum(true))
+	opts2 := newTestOptions(t, 2, map[uint64]string{1: "", 2: ""}, clusterconfig.WithTransport(tt), clusterconfig.WithPreVote(true), clusterconfig.WithCheckQuorum(true))
+	s1 := clusterconfig.New(opts1)
+	s2 := clusterconfig.New(opts2)
+	tt.serverMap[1] = s1
+	tt.serverMap[2] = s2
+
+	err := s1.Start()
+	assert.NoError(t, err)
+	err = s2.Start()
+	assert.NoError(t, err)
+	defer s2.Stop()
+
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	waitHasLeader(timeoutCtx, s1, s2)
+
+	// 默认关闭
+	assert.False(t, s1.SlotReplicaBalanceOn())
+	assert.False(t, s2.SlotReplicaBalanceOn())
+
+	// 从节点提案会转发给领导，所有节点生效
+	follower := s1
+	if s1.IsLeader() {
+		follower = s2
+	}
+	err = follower.ProposeSlotReplicaBalance(true)
+	assert.NoError(t, err)
+	for !s1.SlotReplicaBalanceOn() || !s2.SlotReplicaBalanceOn() {
+		select {
+		case <-timeoutCtx.Done():
+			t.Fatal("wait slot replica balance on timeout")
+		case <-time.After(time.Millisecond * 10):
+		}
+	}
+
+	// 重启后依然有效
+	s1.Stop()
+	s1 = clusterconfig.New(opts1)
+	tt.serverMap[1] = s1
+	err = s1.Start()
+	assert.NoError(t, err)
+	defer s1.Stop()
+	assert.True(t, s1.SlotReplicaBalanceOn())
+
+	// 集群配置中的开关优先于启动配置
+	timeoutCtx, cancel = context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	waitHasLeader(timeoutCtx, s1, s2)
+	err = s1.ProposeSlotReplicaBalance(false)
+	assert.NoError(t, err)
+	assert.False(t, s1.SlotReplicaBalanceOn())
+
+	// 集群配置中未设置时使用启动配置
+	assert.True(t, clusterconfig.New(newTestOptions(t, 3, nil, clusterconfig.WithSlotReplicaBalanceOn(true))).SlotReplicaBalanceOn())
+}
+
+// 测试落后太多的节点通过快照追上领导
+func TestServerSnapshot(t *testing.T) {
+	tt := newTestTransport()
+	initNodes := map[uint64]string{1: "", 2: "", 3: ""}
+	opts := []clusterconfig.Option{clusterconfig.WithTransport(tt), clusterconfig.WithCompactLogThreshold(5), clusterconfig.WithCompactLogRetain(2)}
+	s1 := clusterconfig.New(newTestOptions(t, 1, initNodes, opts...))
+	s2 := clusterconfig.New(newTestOptions(t, 2, initNodes, opts...))
+	s3 := clusterconfig.New(newTestOptions(t, 3, initNodes, opts...))
+	tt.serverMap[1] = s1
+	tt.serverMap[2] = s2
+
+	err := s1.Start()
+	assert.NoError(t, err)
+	err = s2.Start()
+	assert.NoError(t, err)
+	defer s1.Stop()
+	defer s2.Stop()
+
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	waitHasLeader(timeoutCtx, s1, s2)
+
+	leader := getLeader(s1, s2)
+	assert.NotNil(t, leader)
+
+	err = leader.ProposeConfig(&pb.Config{
+		Nodes: []*pb.Node{
+			{Id: 1, Status: pb.NodeStatus_NodeStatusJoined},
+			{Id: 2, Status: pb.NodeStatus_NodeStatusJoined},
+			{Id: 3, Status: pb.NodeStatus_NodeStatusJoined},
+		},
+	})
+	assert.NoError(t, err)
+	for i := 0; i < 20; i++ {
+		err = leader.ProposeNodeLabel(1, clusterconfig.NodeLabel{Zone: fmt.Sprintf("az%d", i)})
+		assert.NoError(t, err)
+	}
+
+	// 等待领导压缩日志
+	timeoutCtx, cancel = context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	for {
+		logs, err := leader.GetLogsInReverseOrder(1, 2, 1)
+		assert.NoError(t, err)
+		if len(logs) == 0 {
+			break
+		}
+		select {
+		case <-timeoutCtx.Done():
+			t.Fatal("wait compact timeout")
+		case <-time.After(time.Millisecond * 10):
+		}
+	}
+
+	// 新节点启动后通过快照追上领导
+	tt.serverMap[3] = s3
+	err = s3.Start()
+	assert.NoError(t, err)
+	defer s3.Stop()
+
+	leaderAppliedIndex, err := leader.AppliedLogIndex()
+	assert.NoError(t, err)
+	for {
+		appliedIndex, err := s3.AppliedLogIndex()
+		assert.NoError(t, err)
+		if appliedIndex >= leaderAppliedIndex {
+			break
+		}
+		select {
+		case <-timeoutCtx.Done():
+			t.Fatal("wait snapshot timeout")
+		case <-time.After(time.Millisecond * 10):
+		}
+	}
+	assert.Equal(t, "az19", s3.Node(1).Zone)
+	assert.Equal(t, 3, len(s3.Nodes()))
+}
+
+func TestServerTransferLeader(t *testing.T) {
+	s1, s2 := newTwoNodes(t)
+	err := s1.Start()
+	assert.NoError(t, err)
+	err = s2.Start()
+	assert.NoError(t, err)
+
+	defer s1.Stop()
+	defer s2.Stop()
+
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	waitHasLeader(timeoutCtx, s1, s2)
+
+	leader := getLeader(s1, s2)
+	assert.NotNil(t, leader)
+
+	_, err = leader.ProposeUntilAppliedTimeout(timeoutCtx, 1, []byte("test"))
+	assert.NoError(t, err)
+
+	follower := s1
+	if leader == s1 {
+		follower = s2
+	}
+	followerId := follower.Options().NodeId
+
+	// 转让领导给追随者
+	err = leader.TransferLeader(timeoutCtx, followerId)
+	assert.NoError(t, err)
+	assert.True(t, follower.IsLeader())
+	assert.False(t, leader.IsLeader())
+
+	// 新领导可以正常提案
+	_, err = follower.ProposeUntilAppliedTimeout(timeoutCtx, 2, []byte("test2"))
+	assert.NoError(t, err)
+}
+
 func newTwoNodes(t *testing.T) (*clusterconfig.Server, *clusterconfig.Server) {
 
 	tt := newTestTransport()
@@ -3,10 +3,12 @@ package clusterconfig
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"math"
 	"time"
 
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/node/clusterconfig/key"
+	pb "github.com/WuKongIM/WuKongIM/pkg/cluster/node/types"
 	"github.com/WuKongIM/WuKongIM/pkg/raft/types"
 	"github.com/WuKongIM/WuKongIM/pkg/wklog"
 	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
@@ -21,6 +23,8 @@ type PebbleShardLogStorage struct {
 	noSync *pebble.WriteOptions
 	s      *Server
 	wklog.Log
+
+	snapshotReceiver types.SnapshotReceiver // 正在接收的快照，集群配置很小，在内存里拼接
 }
 
 func NewPebbleShardLogStorage(path string, s *Server) *PebbleShardLogStorage {
@@ -173,12 +177,42 @@ func (p *PebbleShardLogStorage) GetState() (types.RaftState, error) {
 		return types.RaftState{}, err
 	}
 
+	term, voteFor, err := p.termAndVote()
+	if err != nil {
+		return types.RaftState{}, err
+	}
+
 	return types.RaftState{
 		LastLogIndex: lastIndex,
 		LastTerm:     lastTerm,
 		AppliedIndex: applied,
+		Term:         term,
+		VoteFor:      voteFor,
 	}, nil
 }
+
+// SaveTermAndVote 保存当前任期和投票记录
+func (p *PebbleShardLogStorage) SaveTermAndVote(term uint32, voteFor uint64) error {
+	data := make([]byte, 12)
+	binary.BigEndian.PutUint32(data, term)
+	binary.BigEndian.PutUint64(data[4:], voteFor)
+	return p.db.Set(key.NewTermVoteKey(), data, p.wo)
+}
+
+func (p *PebbleShardLogStorage) termAndVote() (uint32, uint64, error) {
+	data, closer, err := p.db.Get(key.NewTermVoteKey())
+	if err != nil {
+		if err == pebble.ErrNotFound {
+			return 0, 0, nil
+		}
+		return 0, 0, err
+	}
+	defer closer.Close()
+	if len(data) < 12 {
+		return 0, 0, nil
+	}
+	return binary.BigEndian.Uint32(data), binary.BigEndian.Uint64(data[4:]), nil
+}
 func (p *PebbleShardLogStorage) GetLogs(startLogIndex uint64, endLogIndex uint64, limitSize uint64) ([]types.Log, error) {
 
 	// lastIndex, err := p.LastIndex()
@@ -186,6 +220,14 @@ func (p *PebbleShardLogStorage) GetLogs(startLogIndex uint64, endLogIndex uint64
 	// 	return nil, err
 	// }
 
+	compactedIndex, _, err := p.compacted()
+	if err != nil {
+		return nil, err
+	}
+	if compactedIndex > 0 && startLogIndex <= compactedIndex {
+		return nil, types.ErrCompacted
+	}
+
 	lowKey := key.NewLogKey(startLogIndex)
 	if endLogIndex == 0 {
 		endLogIndex = math.MaxUint64
@@ -271,11 +313,8 @@ func (p *PebbleShardLogStorage) FirstIndex() (uint64, error) {
 }
 
 func (p *PebbleShardLogStorage) LastIndex() (uint64, error) {
-	log, err := p.LastLog()
-	if err != nil {
-		return 0, err
-	}
-	return log.Index, err
+	lastIndex, _, err := p.LastIndexAndTerm()
+	return lastIndex, err
 }
 
 func (p *PebbleShardLogStorage) LastIndexAndTerm() (uint64, uint32, error) {
@@ -283,6 +322,9 @@ func (p *PebbleShardLogStorage) LastIndexAndTerm() (uint64, uint32, error) {
 	if err != nil {
 		return 0, 0, err
 	}
+	if log.Index == 0 { // 日志都被压缩了，以快照的下标为准
+		return p.compacted()
+	}
 	return log.Index, log.Term, nil
 }
 
@@ -338,6 +380,134 @@ func (p *PebbleShardLogStorage) getLog(index uint64) (types.Log, error) {
 
 }
 
+// GetSnapshot 获取集群配置的快照
+func (p *PebbleShardLogStorage) GetSnapshot() (types.Snapshot, error) {
+	index, term, data, err := p.s.config.snapshot()
+	if err != nil {
+		return types.Snapshot{}, err
+	}
+	return types.Snapshot{
+		Index: index,
+		Term:  term,
+		Data:  data,
+	}, nil
+}
+
+// ApplySnapshot 安装领导发过来的集群配置快照
+func (p *PebbleShardLogStorage) ApplySnapshot(chunk types.Snapshot) error {
+	snapshot, ok, err := p.snapshotReceiver.Append(chunk)
+	if err != nil || !ok {
+		return err
+	}
+	cfg := &pb.Config{}
+	err = cfg.Unmarshal(snapshot.Data)
+	if err != nil {
+		return err
+	}
+
+	// 先保存配置，再删除日志，避免删除日志后配置没保存成功导致配置丢失
+	p.s.config.update(cfg)
+	err = p.s.config.saveConfig()
+	if err != nil {
+		return err
+	}
+
+	batch := p.db.NewBatch()
+	defer batch.Close()
+	err = batch.DeleteRange(key.NewLogKey(0), key.NewLogKey(math.MaxUint64), p.noSync)
+	if err != nil {
+		return err
+	}
+	err = batch.DeleteRange(key.NewLeaderTermStartIndexKey(snapshot.Term), key.NewLeaderTermStartIndexKey(math.MaxUint32), p.noSync)
+	if err != nil {
+		return err
+	}
+	indexData := make([]byte, 8)
+	binary.BigEndian.PutUint64(indexData, snapshot.TermStartIndex)
+	err = batch.Set(key.NewLeaderTermStartIndexKey(snapshot.Term), indexData, p.noSync)
+	if err != nil {
+		return err
+	}
+	err = p.setCompactedWithWriter(snapshot.Index, snapshot.Term, batch, p.noSync)
+	if err != nil {
+		return err
+	}
+	appliedData := make([]byte, 16)
+	binary.BigEndian.PutUint64(appliedData, snapshot.Index)
+	binary.BigEndian.PutUint64(appliedData[8:], uint64(time.Now().UnixNano()))
+	err = batch.Set(key.NewAppliedIndexKey(), appliedData, p.noSync)
+	if err != nil {
+		return err
+	}
+	err = batch.Commit(p.wo)
+	if err != nil {
+		return err
+	}
+
+	p.s.NotifyConfigChangeEvent()
+	return nil
+}
+
+// CompactLogTo 压缩日志，删除index及之前的日志
+func (p *PebbleShardLogStorage) CompactLogTo(index uint64) error {
+	appliedIdx, err := p.AppliedIndex()
+	if err != nil {
+		return err
+	}
+	if index > appliedIdx {
+		return fmt.Errorf("compact index[%d] is greater than applied index[%d]", index, appliedIdx)
+	}
+	compactedIndex, _, err := p.compacted()
+	if err != nil {
+		return err
+	}
+	if index <= compactedIndex {
+		return nil
+	}
+	log, err := p.getLog(index)
+	if err != nil {
+		return err
+	}
+	if log.Index == 0 {
+		return fmt.Errorf("compact log[%d] not found", index)
+	}
+
+	batch := p.db.NewBatch()
+	defer batch.Close()
+	err = batch.DeleteRange(key.NewLogKey(0), key.NewLogKey(index+1), p.noSync)
+	if err != nil {
+		return err
+	}
+	err = p.setCompactedWithWriter(index, log.Term, batch, p.noSync)
+	if err != nil {
+		return err
+	}
+	return batch.Commit(p.wo)
+}
+
+// 已压缩的日志下标和任期
+func (p *PebbleShardLogStorage) compacted() (uint64, uint32, error) {
+	data, closer, err := p.db.Get(key.NewCompactedKey())
+	if err != nil {
+		if err == pebble.ErrNotFound {
+			return 0, 0, nil
+		}
+		return 0, 0, err
+	}
+	defer closer.Close()
+	if len(data) < 12 {
+		return 0, 0, nil
+	}
+	return binary.BigEndian.Uint64(data[:8]), binary.BigEndian.Uint32(data[8:12]), nil
+}
+
+func (p *PebbleShardLogStorage) setCompactedWithWriter(index uint64, term uint32, w pebble.Writer, o *pebble.WriteOptions) error {
+	data := make([]byte, 12)
+	binary.BigEndian.PutUint64(data, index)
+	binary.BigEndian.PutUint32(data[8:], term)
+	return w.Set(key.NewCompactedKey(), data, o)
+}
+
 func (p *PebbleShardLogStorage) setAppliedIndex(index uint64) error {
 	maxIndexKeyData := key.NewAppliedIndexKey()
 	maxIndexdata := make([]byte, 8)
@@ -0,0 +1,109 @@
+package event
+
+import (
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/node/types"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	"go.uber.org/zap"
+)
+
+// 处理离开中的节点，将离开节点上的槽副本（包括槽领导）迁移到其他节点
+// 和槽副本均衡一样，同时迁移的槽数量不超过SlotReplicaBalanceConcurrency
+func (s *Server) handleNodeLeaving() error {
+	leavingNodes := s.cfgServer.LeavingNodes()
+	if len(leavingNodes) == 0 {
+		return nil
+	}
+
+	cfg := s.cfgServer.GetClusterConfig()
+
+	// 正在迁移的槽数量
+	migratingCount := 0
+	for _, slot := range cfg.Slots {
+		if slot.MigrateFrom != 0 || slot.MigrateTo != 0 {
+			migratingCount++
+		}
+	}
+	limit := s.cfgOptions.SlotReplicaBalanceConcurrency - migratingCount
+	if limit <= 0 {
+		return nil
+	}
+
+	// 可迁入的节点（离开中的节点不在此列表中）
+	targetNodes := s.cfgServer.AllowVoteAndJoinedOnlineNodes()
+	if len(targetNodes) == 0 {
+		return nil
+	}
+
+	// 每个可迁入节点目前的槽数量
+	nodeSlotCountMap := make(map[uint64]uint32)
+	for _, node := range targetNodes {
+		nodeSlotCountMap[node.Id] = 0
+	}
+	for _, slot := range cfg.Slots {
+		for _, replicaId := range slot.Replicas {
+			if _, ok := nodeSlotCountMap[replicaId]; ok {
+				nodeSlotCountMap[replicaId]++
+			}
+		}
+	}
+
+	leavingNode := leavingNodes[0] // 一次只处理一个离开的节点
+	zoneMap := nodeZoneMap(cfg.Nodes)
+
+	var migrateSlots []*types.Slot
+	for _, slot := range cfg.Slots {
+		if len(migrateSlots) >= limit {
+			break
+		}
+		if slot.MigrateFrom != 0 || slot.MigrateTo != 0 { // 迁移中
+			continue
+		}
+		if !wkutil.ArrayContainsUint64(slot.Replicas, leavingNode.Id) {
+			continue
+		}
+
+		// 选出不在副本中的节点作为迁入节点，优先保证副本分布的区域数量，其次选择槽数量最少的节点
+		var (
+			migrateTo    uint64
+			minSlotCount uint32
+			maxZoneCount int
+		)
+		for _, node := range targetNodes {
+			if wkutil.ArrayContainsUint64(slot.Replicas, node.Id) {
+				continue
+			}
+			slotCount := nodeSlotCountMap[node.Id]
+			zoneCount := replaceZoneCount(slot.Replicas, leavingNode.Id, node.Id, zoneMap)
+			if migrateTo == 0 || zoneCount > maxZoneCount || (zoneCount == maxZoneCount && slotCount < minSlotCount) {
+				migrateTo = node.Id
+				minSlotCount = slotCount
+				maxZoneCount = zoneCount
+			}
+		}
+		if migrateTo == 0 {
+			s.Warn("no node can migrate to", zap.Uint32("slotId", slot.Id), zap.Uint64("leavingNodeId", leavingNode.Id))
+			continue
+		}
+		nodeSlotCountMap[migrateTo]++
+
+		// 如果离开节点是槽领导，迁移完成后迁入节点将成为新的槽领导
+		newSlot := slot.Clone()
+		newSlot.MigrateFrom = leavingNode.Id
+		newSlot.MigrateTo = migrateTo
+		newSlot.Learners = append(newSlot.Learners, migrateTo)
+		migrateSlots = append(migrateSlots, newSlot)
+	}
+
+	if len(migrateSlots) == 0 {
+		return nil
+	}
+
+	s.Info("node leaving, migrate slots", zap.Uint64("nodeId", leavingNode.Id), zap.Int("slotCount", len(migrateSlots)))
+
+	err := s.cfgServer.ProposeSlots(migrateSlots)
+	if err != nil {
+		s.Error("handleNodeLeaving failed,ProposeSlots failed", zap.Error(err))
+		return err
+	}
+	return nil
+}
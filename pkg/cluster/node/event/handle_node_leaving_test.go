@@ -0,0 +1,152 @@
+package event
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/node/clusterconfig"
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/node/types"
+	rafttypes "github.com/WuKongIM/WuKongIM/pkg/raft/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// 单节点启动的配置服务，节点1为配置领导
+func newTestServer(t *testing.T) *Server {
+	opts := clusterconfig.NewOptions(
+		clusterconfig.WithNodeId(1),
+		clusterconfig.WithConfigPath(t.TempDir()+"/cluster.json"),
+		clusterconfig.WithTransport(&testTransport{}),
+	)
+	cfgServer := clusterconfig.New(opts)
+	assert.NoError(t, cfgServer.Start())
+	t.Cleanup(cfgServer.Stop)
+
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	for cfgServer.LeaderId() == 0 {
+		select {
+		case <-timeoutCtx.Done():
+			t.Fatal("wait leader timeout")
+		case <-time.After(time.Millisecond * 10):
+		}
+	}
+	return NewServer(nil, opts, cfgServer)
+}
+
+type testTransport struct{}
+
+func (t *testTransport) Send(event rafttypes.Event) {}
+
+func testNode(id uint64, zone string) *types.Node {
+	return &types.Node{
+		Id:        id,
+		Online:    true,
+		AllowVote: true,
+		Status:    types.NodeStatus_NodeStatusJoined,
+		Zone:      zone,
+	}
+}
+
+func TestHandleNodeLeaving(t *testing.T) {
+	s := newTestServer(t)
+
+	err := s.cfgServer.ProposeConfig(&types.Config{
+		Nodes: []*types.Node{
+			testNode(1, "a"),
+			testNode(2, "b"),
+			testNode(3, "a"),
+			testNode(4, "a"),
+		},
+		Slots: []*types.Slot{
+			{Id: 1, Leader: 3, Term: 1, Replicas: []uint64{1, 3}},
+			{Id: 2, Leader: 1, Term: 1, Replicas: []uint64{1, 2}},
+			{Id: 3, Leader: 2, Term: 1, Replicas: []uint64{2, 3}},
+		},
+	})
+	assert.NoError(t, err)
+
+	// 没有离开的节点不处理
+	assert.NoError(t, s.handleNodeLeaving())
+	assert.Equal(t, uint64(0), s.cfgServer.Slot(1).MigrateTo)
+
+	err = s.cfgServer.ProposeNodeStatus(3, types.NodeStatus_NodeStatusLeaving)
+	assert.NoError(t, err)
+	assert.NoError(t, s.handleNodeLeaving())
+
+	// 槽1迁到区域b的节点2（副本分布在两个区域），离开的节点是槽领导，迁移完成后节点2会成为槽领导
+	slot1 := s.cfgServer.Slot(1)
+	assert.Equal(t, uint64(3), slot1.MigrateFrom)
+	assert.Equal(t, uint64(2), slot1.MigrateTo)
+	assert.Equal(t, []uint64{2}, slot1.Learners)
+
+	// 槽2不包含离开的节点
+	slot2 := s.cfgServer.Slot(2)
+	assert.Equal(t, uint64(0), slot2.MigrateFrom)
+	assert.Equal(t, uint64(0), slot2.MigrateTo)
+
+	// 槽3迁到节点1和节点4区域一样，选槽数量少的节点4
+	slot3 := s.cfgServer.Slot(3)
+	assert.Equal(t, uint64(3), slot3.MigrateFrom)
+	assert.Equal(t, uint64(4), slot3.MigrateTo)
+	assert.Equal(t, []uint64{4}, slot3.Learners)
+
+	// 正在迁移的槽达到并发数量时不再处理
+	err = s.cfgServer.ProposeSlots([]*types.Slot{{Id: 3, Leader: 2, Term: 1, Replicas: []uint64{2, 3}}})
+	assert.NoError(t, err)
+	s.cfgOptions.SlotReplicaBalanceConcurrency = 1
+	assert.NoError(t, s.handleNodeLeaving())
+	assert.Equal(t, uint64(0), s.cfgServer.Slot(3).MigrateTo)
+
+	// 低于并发数量时继续迁移没有迁移中的槽
+	s.cfgOptions.SlotReplicaBalanceConcurrency = 2
+	assert.NoError(t, s.handleNodeLeaving())
+	assert.Equal(t, uint64(4), s.cfgServer.Slot(3).MigrateTo)
+	assert.Equal(t, []uint64{2}, s.cfgServer.Slot(1).Learners) // 迁移中的槽不重复处理
+}
+
+func TestHandleNodeLeavingConcurrency(t *testing.T) {
+	s := newTestServer(t)
+	s.cfgOptions.SlotReplicaBalanceConcurrency = 1
+
+	err := s.cfgServer.ProposeConfig(&types.Config{
+		Nodes: []*types.Node{
+			testNode(1, ""),
+			testNode(2, ""),
+			testNode(3, ""),
+		},
+		Slots: []*types.Slot{
+			{Id: 1, Leader: 1, Term: 1, Replicas: []uint64{1, 2}},
+			{Id: 2, Leader: 2, Term: 1, Replicas: []uint64{1, 2}},
+		},
+	})
+	assert.NoError(t, err)
+	err = s.cfgServer.ProposeNodeStatus(2, types.NodeStatus_NodeStatusLeaving)
+	assert.NoError(t, err)
+
+	// 每次最多同时迁移SlotReplicaBalanceConcurrency个槽
+	assert.NoError(t, s.handleNodeLeaving())
+	assert.Equal(t, uint64(3), s.cfgServer.Slot(1).MigrateTo)
+	assert.Equal(t, uint64(0), s.cfgServer.Slot(2).MigrateTo)
+}
+
+func TestHandleNodeLeavingNoTarget(t *testing.T) {
+	s := newTestServer(t)
+
+	err := s.cfgServer.ProposeConfig(&types.Config{
+		Nodes: []*types.Node{
+			testNode(1, ""),
+			testNode(2, ""),
+		},
+		Slots: []*types.Slot{
+			{Id: 1, Leader: 1, Term: 1, Replicas: []uint64{1, 2}},
+		},
+	})
+	assert.NoError(t, err)
+	err = s.cfgServer.ProposeNodeStatus(2, types.NodeStatus_NodeStatusLeaving)
+	assert.NoError(t, err)
+
+	// 剩下的节点都已经是副本，没有节点可以迁入
+	assert.NoError(t, s.handleNodeLeaving())
+	assert.Equal(t, uint64(0), s.cfgServer.Slot(1).MigrateTo)
+}
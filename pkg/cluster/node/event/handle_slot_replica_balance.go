@@ -0,0 +1,226 @@
+package event
+
+import (
+	"sort"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/node/types"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	"go.uber.org/zap"
+)
+
+// 均衡槽副本，使槽副本尽量分布在不同的区域，并且每个节点的槽副本数量尽量一致
+func (s *Server) handleSlotReplicaBalance() error {
+	if !s.cfgServer.SlotReplicaBalanceOn() {
+		return nil
+	}
+
+	cfg := s.cfgServer.GetClusterConfig()
+	if len(cfg.Slots) == 0 {
+		return nil
+	}
+
+	// 有节点离线或者有未加入（加入中/离开中）的节点，则暂停均衡
+	for _, node := range cfg.Nodes {
+		if !node.Online || node.Status != types.NodeStatus_NodeStatusJoined {
+			return nil
+		}
+	}
+
+	// 正在迁移的槽数量
+	migratingCount := 0
+	for _, slot := range cfg.Slots {
+		if slot.MigrateFrom != 0 || slot.MigrateTo != 0 {
+			migratingCount++
+		}
+		if slot.Status == types.SlotStatus_SlotStatusCandidate {
+			return nil
+		}
+	}
+	limit := s.cfgOptions.SlotReplicaBalanceConcurrency - migratingCount
+	if limit <= 0 {
+		return nil
+	}
+
+	migrateSlots := s.balanceSlotReplicas(cfg, limit)
+	if len(migrateSlots) == 0 {
+		return nil
+	}
+
+	s.Info("balance slot replicas", zap.Int("slotCount", len(migrateSlots)))
+
+	err := s.cfgServer.ProposeSlots(migrateSlots)
+	if err != nil {
+		s.Error("handleSlotReplicaBalance failed,ProposeSlots failed", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// 计算需要迁移的槽（最多limit个），每次从槽副本最多的节点迁移一个槽副本到槽副本最少的节点
+func (s *Server) balanceSlotReplicas(cfg *types.Config, limit int) []*types.Slot {
+
+	// 每个节点的槽副本数量
+	nodeSlotCountMap := make(map[uint64]int)
+	for _, node := range cfg.Nodes {
+		if node.AllowVote && node.Status == types.NodeStatus_NodeStatusJoined {
+			nodeSlotCountMap[node.Id] = 0
+		}
+	}
+	if len(nodeSlotCountMap) < 2 {
+		return nil
+	}
+	for _, slot := range cfg.Slots {
+		for _, replicaId := range slot.Replicas {
+			if _, ok := nodeSlotCountMap[replicaId]; ok {
+				nodeSlotCountMap[replicaId]++
+			}
+		}
+	}
+
+	nodeIds := make([]uint64, 0, len(nodeSlotCountMap))
+	for nodeId := range nodeSlotCountMap {
+		nodeIds = append(nodeIds, nodeId)
+	}
+
+	migratedSlotMap := make(map[uint32]bool) // 已经在迁移中的槽
+	for _, slot := range cfg.Slots {
+		if slot.MigrateFrom != 0 || slot.MigrateTo != 0 {
+			migratedSlotMap[slot.Id] = true
+		}
+	}
+
+	// 优先修复副本区域分布（副本集中在少数区域的槽，单个区域故障可能导致槽不可用）
+	zoneMap := nodeZoneMap(cfg.Nodes)
+	migrateSlots := s.spreadSlotZones(cfg.Slots, zoneMap, nodeSlotCountMap, migratedSlotMap, limit)
+
+	for len(migrateSlots) < limit {
+		// 按槽副本数量从多到少排序（数量相同按节点id排序，保证结果稳定）
+		sort.Slice(nodeIds, func(i, j int) bool {
+			ci, cj := nodeSlotCountMap[nodeIds[i]], nodeSlotCountMap[nodeIds[j]]
+			if ci != cj {
+				return ci > cj
+			}
+			return nodeIds[i] < nodeIds[j]
+		})
+
+		fromNodeId := nodeIds[0]
+		toNodeId := nodeIds[len(nodeIds)-1]
+		if nodeSlotCountMap[fromNodeId]-nodeSlotCountMap[toNodeId] <= 1 { // 已经均衡
+			break
+		}
+
+		slot := s.pickSlotToMigrate(cfg.Slots, migratedSlotMap, zoneMap, fromNodeId, toNodeId)
+		if slot == nil {
+			break
+		}
+		newSlot := slot.Clone()
+		newSlot.MigrateFrom = fromNodeId
+		newSlot.MigrateTo = toNodeId
+		newSlot.Learners = append(newSlot.Learners, toNodeId)
+		migrateSlots = append(migrateSlots, newSlot)
+
+		migratedSlotMap[slot.Id] = true
+		nodeSlotCountMap[fromNodeId]--
+		nodeSlotCountMap[toNodeId]++
+	}
+	return migrateSlots
+}
+
+// 选出一个可以从fromNodeId迁移到toNodeId的槽（优先选择fromNodeId不是领导的槽，避免领导切换）
+// 迁移后副本分布的区域数量不能减少
+func (s *Server) pickSlotToMigrate(slots []*types.Slot, migratedSlotMap map[uint32]bool, zoneMap map[uint64]string, fromNodeId, toNodeId uint64) *types.Slot {
+	var leaderSlot *types.Slot
+	for _, slot := range slots {
+		if migratedSlotMap[slot.Id] {
+			continue
+		}
+		if !wkutil.ArrayContainsUint64(slot.Replicas, fromNodeId) || wkutil.ArrayContainsUint64(slot.Replicas, toNodeId) {
+			continue
+		}
+		if replaceZoneCount(slot.Replicas, fromNodeId, toNodeId, zoneMap) < replicaZoneCount(slot.Replicas, zoneMap) {
+			continue
+		}
+		if slot.Leader != fromNodeId {
+			return slot
+		}
+		if leaderSlot == nil {
+			leaderSlot = slot
+		}
+	}
+	return leaderSlot
+}
+
+// 计算需要修复区域分布的槽（最多limit个），将重复区域上的副本迁移到副本还未覆盖的区域
+func (s *Server) spreadSlotZones(slots []*types.Slot, zoneMap map[uint64]string, nodeSlotCountMap map[uint64]int, migratedSlotMap map[uint32]bool, limit int) []*types.Slot {
+	// 可迁入节点的区域
+	voteZoneMap := make(map[uint64]string, len(nodeSlotCountMap))
+	for nodeId := range nodeSlotCountMap {
+		voteZoneMap[nodeId] = zoneMap[nodeId]
+	}
+	totalZones := totalZoneCount(voteZoneMap)
+	if totalZones <= 1 {
+		return nil
+	}
+
+	var migrateSlots []*types.Slot
+	for _, slot := range slots {
+		if len(migrateSlots) >= limit {
+			break
+		}
+		if migratedSlotMap[slot.Id] {
+			continue
+		}
+		wantZoneCount := len(slot.Replicas)
+		if wantZoneCount > totalZones {
+			wantZoneCount = totalZones
+		}
+		if replicaZoneCount(slot.Replicas, zoneMap) >= wantZoneCount {
+			continue
+		}
+
+		// 迁出节点：所在区域有多个副本，优先选择非领导且槽副本最多的节点
+		replicaZoneNum := make(map[string]int)
+		for _, replicaId := range slot.Replicas {
+			replicaZoneNum[zoneMap[replicaId]]++
+		}
+		var fromNodeId uint64
+		for _, replicaId := range slot.Replicas {
+			if replicaZoneNum[zoneMap[replicaId]] <= 1 {
+				continue
+			}
+			if fromNodeId == 0 {
+				fromNodeId = replicaId
+				continue
+			}
+			if (fromNodeId == slot.Leader && replicaId != slot.Leader) ||
+				((fromNodeId == slot.Leader) == (replicaId == slot.Leader) && nodeSlotCountMap[replicaId] > nodeSlotCountMap[fromNodeId]) {
+				fromNodeId = replicaId
+			}
+		}
+
+		// 迁入节点：所在区域没有副本，优先选择槽副本最少的节点
+		var toNodeId uint64
+		for nodeId, zone := range voteZoneMap {
+			if replicaZoneNum[zone] > 0 {
+				continue
+			}
+			if toNodeId == 0 || nodeSlotCountMap[nodeId] < nodeSlotCountMap[toNodeId] || (nodeSlotCountMap[nodeId] == nodeSlotCountMap[toNodeId] && nodeId < toNodeId) {
+				toNodeId = nodeId
+			}
+		}
+		if fromNodeId == 0 || toNodeId == 0 {
+			continue
+		}
+
+		newSlot := slot.Clone()
+		newSlot.MigrateFrom = fromNodeId
+		newSlot.MigrateTo = toNodeId
+		newSlot.Learners = append(newSlot.Learners, toNodeId)
+		migrateSlots = append(migrateSlots, newSlot)
+
+		migratedSlotMap[slot.Id] = true
+		nodeSlotCountMap[fromNodeId]--
+		nodeSlotCountMap[toNodeId]++
+	}
+	return migrateSlots
+}
@@ -0,0 +1,154 @@
+package event
+
+import (
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/node/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleSlotReplicaBalance(t *testing.T) {
+	s := newTestServer(t)
+
+	err := s.cfgServer.ProposeConfig(&types.Config{
+		Nodes: []*types.Node{
+			testNode(1, ""),
+			testNode(2, ""),
+			testNode(3, ""),
+		},
+		Slots: []*types.Slot{
+			{Id: 1, Leader: 1, Term: 1, Replicas: []uint64{1, 2}},
+			{Id: 2, Leader: 2, Term: 1, Replicas: []uint64{1, 2}},
+		},
+	})
+	assert.NoError(t, err)
+
+	// 默认关闭
+	assert.NoError(t, s.handleSlotReplicaBalance())
+	assert.Equal(t, uint64(0), s.cfgServer.Slot(1).MigrateTo)
+	assert.Equal(t, uint64(0), s.cfgServer.Slot(2).MigrateTo)
+
+	// 有节点离线时暂停均衡
+	err = s.cfgServer.ProposeSlotReplicaBalance(true)
+	assert.NoError(t, err)
+	err = s.cfgServer.ProposeNodeOnlineStatus(3, false)
+	assert.NoError(t, err)
+	assert.NoError(t, s.handleSlotReplicaBalance())
+	assert.Equal(t, uint64(0), s.cfgServer.Slot(1).MigrateTo)
+	assert.Equal(t, uint64(0), s.cfgServer.Slot(2).MigrateTo)
+
+	err = s.cfgServer.ProposeNodeOnlineStatus(3, true)
+	assert.NoError(t, err)
+	assert.NoError(t, s.handleSlotReplicaBalance())
+
+	// 节点1迁出它不是领导的槽2
+	slot2 := s.cfgServer.Slot(2)
+	assert.Equal(t, uint64(1), slot2.MigrateFrom)
+	assert.Equal(t, uint64(3), slot2.MigrateTo)
+	assert.Equal(t, []uint64{3}, slot2.Learners)
+	assert.Equal(t, uint64(0), s.cfgServer.Slot(1).MigrateTo)
+}
+
+func TestBalanceSlotReplicas(t *testing.T) {
+	s := newTestServer(t)
+
+	cfg := &types.Config{
+		Nodes: []*types.Node{
+			testNode(1, ""),
+			testNode(2, ""),
+			testNode(3, ""),
+		},
+		Slots: []*types.Slot{
+			{Id: 1, Leader: 1, Replicas: []uint64{1, 2}},
+			{Id: 2, Leader: 2, Replicas: []uint64{1, 2}},
+			{Id: 3, Leader: 1, Replicas: []uint64{1, 2}},
+			{Id: 4, Leader: 2, Replicas: []uint64{1, 2}},
+		},
+	}
+
+	// 每次从槽副本最多的节点迁一个不是领导的槽副本到最少的节点
+	migrateSlots := s.balanceSlotReplicas(cfg, 1)
+	assert.Len(t, migrateSlots, 1)
+	assert.Equal(t, uint32(2), migrateSlots[0].Id)
+	assert.Equal(t, uint64(1), migrateSlots[0].MigrateFrom)
+	assert.Equal(t, uint64(3), migrateSlots[0].MigrateTo)
+	assert.Equal(t, []uint64{3}, migrateSlots[0].Learners)
+	assert.Equal(t, uint64(0), cfg.Slots[1].MigrateTo) // 不修改原配置
+
+	// 节点副本数量为 1:3 2:3 3:2 时已经均衡，不会超过limit
+	migrateSlots = s.balanceSlotReplicas(cfg, 10)
+	assert.Len(t, migrateSlots, 2)
+	assert.Equal(t, uint32(2), migrateSlots[0].Id)
+	assert.Equal(t, uint32(1), migrateSlots[1].Id)
+	assert.Equal(t, uint64(2), migrateSlots[1].MigrateFrom)
+	assert.Equal(t, uint64(3), migrateSlots[1].MigrateTo)
+
+	// 迁移中的槽不再迁移
+	cfg.Slots[1].MigrateFrom = 1
+	cfg.Slots[1].MigrateTo = 3
+	migrateSlots = s.balanceSlotReplicas(cfg, 10)
+	for _, slot := range migrateSlots {
+		assert.NotEqual(t, uint32(2), slot.Id)
+	}
+
+	// 可迁入的节点少于2个
+	cfg.Nodes[1].Status = types.NodeStatus_NodeStatusLeaving
+	cfg.Nodes[2].AllowVote = false
+	assert.Empty(t, s.balanceSlotReplicas(cfg, 10))
+}
+
+func TestBalanceSlotReplicasBalanced(t *testing.T) {
+	s := newTestServer(t)
+
+	cfg := &types.Config{
+		Nodes: []*types.Node{
+			testNode(1, ""),
+			testNode(2, ""),
+			testNode(3, ""),
+		},
+		Slots: []*types.Slot{
+			{Id: 1, Leader: 1, Replicas: []uint64{1, 2}},
+			{Id: 2, Leader: 2, Replicas: []uint64{2, 3}},
+			{Id: 3, Leader: 3, Replicas: []uint64{3, 1}},
+		},
+	}
+	assert.Empty(t, s.balanceSlotReplicas(cfg, 10))
+}
+
+func TestPickSlotToMigrate(t *testing.T) {
+	s := newTestServer(t)
+
+	nodes := []*types.Node{
+		testNode(1, "a"),
+		testNode(2, "b"),
+		testNode(3, "a"),
+		testNode(4, "b"),
+	}
+	zoneMap := nodeZoneMap(nodes)
+
+	slots := []*types.Slot{
+		{Id: 1, Leader: 1, Replicas: []uint64{1, 2}},
+		{Id: 2, Leader: 2, Replicas: []uint64{1, 2}},
+		{Id: 3, Leader: 2, Replicas: []uint64{1, 2, 3}},
+	}
+
+	// 优先选择迁出节点不是领导的槽
+	slot := s.pickSlotToMigrate(slots, map[uint32]bool{}, zoneMap, 1, 3)
+	assert.Equal(t, uint32(2), slot.Id)
+
+	// 只剩迁出节点是领导的槽
+	slot = s.pickSlotToMigrate(slots, map[uint32]bool{2: true}, zoneMap, 1, 3)
+	assert.Equal(t, uint32(1), slot.Id)
+
+	// 迁移中的槽和已经包含迁入节点的槽不选
+	slot = s.pickSlotToMigrate(slots, map[uint32]bool{1: true, 2: true}, zoneMap, 1, 3)
+	assert.Nil(t, slot)
+
+	// 迁移后副本覆盖的区域变少（a,b -> b,b）的槽不选
+	slot = s.pickSlotToMigrate(slots, map[uint32]bool{3: true}, zoneMap, 1, 4)
+	assert.Nil(t, slot)
+
+	// 槽3有两个区域a的副本，迁走一个到区域b不会减少区域
+	slot = s.pickSlotToMigrate(slots[2:], map[uint32]bool{}, zoneMap, 3, 4)
+	assert.Equal(t, uint32(3), slot.Id)
+}
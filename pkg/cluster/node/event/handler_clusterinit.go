@@ -3,6 +3,7 @@ package event
 import (
 	"time"
 
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/node/clusterconfig"
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/node/types"
 	"go.uber.org/zap"
 )
@@ -25,8 +26,10 @@ func (h *handler) handleClusterInit() {
 	if len(opts.InitNodes) > 0 {
 		for nodeId, addr := range opts.InitNodes {
 			apiAddr := ""
+			label := opts.InitNodeLabels[nodeId]
 			if nodeId == opts.NodeId {
 				apiAddr = opts.ApiServerAddr
+				label = clusterconfig.NodeLabel{Zone: opts.Zone}
 			}
 			nodes = append(nodes, &types.Node{
 				Id:            nodeId,
@@ -37,6 +40,7 @@ func (h *handler) handleClusterInit() {
 				Role:          types.NodeRole_NodeRoleReplica,
 				Status:        types.NodeStatus_NodeStatusJoined,
 				CreatedAt:     time.Now().Unix(),
+				Zone:          label.Zone,
 			})
 			replicas = append(replicas, nodeId)
 		}
@@ -49,6 +53,7 @@ func (h *handler) handleClusterInit() {
 			Role:          types.NodeRole_NodeRoleReplica,
 			Status:        types.NodeStatus_NodeStatusJoined,
 			CreatedAt:     time.Now().Unix(),
+			Zone:          opts.Zone,
 		})
 		replicas = append(replicas, opts.NodeId)
 	}
@@ -56,6 +61,9 @@ func (h *handler) handleClusterInit() {
 	cfg.Nodes = nodes
 
 	if len(replicas) > 0 {
+		// 按区域交叉排列，使每个槽的副本尽量分布在不同的区域
+		zoneMap := nodeZoneMap(nodes)
+		replicas = interleaveByZone(replicas, zoneMap)
 		offset := 0
 		replicaCount := opts.SlotMaxReplicaCount
 		for i := uint32(0); i < opts.SlotCount; i++ {
@@ -65,11 +73,12 @@ func (h *handler) handleClusterInit() {
 			if len(replicas) <= int(replicaCount) {
 				slot.Replicas = replicas
 			} else {
-				slot.Replicas = make([]uint64, 0, replicaCount)
-				for i := uint32(0); i < replicaCount; i++ {
-					idx := (offset + int(i)) % len(replicas)
-					slot.Replicas = append(slot.Replicas, replicas[idx])
+				orderedReplicas := make([]uint64, 0, len(replicas))
+				for i := 0; i < len(replicas); i++ {
+					idx := (offset + i) % len(replicas)
+					orderedReplicas = append(orderedReplicas, replicas[idx])
 				}
+				slot.Replicas = selectZoneSpreadReplicas(orderedReplicas, int(replicaCount), zoneMap)
 			}
 			offset++
 			// 随机选举一个领导者
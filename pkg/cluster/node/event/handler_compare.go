@@ -3,6 +3,7 @@ package event
 import (
 	"strings"
 
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/node/clusterconfig"
 	"go.uber.org/zap"
 )
 
@@ -19,4 +20,16 @@ func (h *handler) handleCompare() {
 			}
 		}
 	}
+
+	// 如果配置里自己节点的区域标签与本地配置不同，则提案标签变更
+	if strings.TrimSpace(h.cfgOptions.Zone) != "" {
+		localNode := h.cfgServer.Node(h.cfgOptions.NodeId)
+		if localNode != nil && localNode.Zone != h.cfgOptions.Zone {
+			err := h.cfgServer.ProposeNodeLabel(h.cfgOptions.NodeId, clusterconfig.NodeLabel{Zone: h.cfgOptions.Zone})
+			if err != nil {
+				h.Error("ProposeNodeLabel failed", zap.Error(err))
+				return
+			}
+		}
+	}
 }
@@ -104,6 +104,20 @@ func (s *Server) handleEvents() {
 			return
 		}
 
+		// 处理节点离开
+		err = s.handleNodeLeaving()
+		if err != nil {
+			s.Error("handleNodeLeaving failed", zap.Error(err))
+			return
+		}
+
+		// 均衡槽副本
+		err = s.handleSlotReplicaBalance()
+		if err != nil {
+			s.Error("handleSlotReplicaBalance failed", zap.Error(err))
+			return
+		}
+
 		// 检查和均衡槽领导
 		err = s.handleSlotLeaderAutoBalance()
 		if err != nil {
@@ -0,0 +1,109 @@
+package event
+
+import (
+	"sort"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/node/types"
+)
+
+// 节点id对应的区域
+func nodeZoneMap(nodes []*types.Node) map[uint64]string {
+	zoneMap := make(map[uint64]string, len(nodes))
+	for _, node := range nodes {
+		zoneMap[node.Id] = node.Zone
+	}
+	return zoneMap
+}
+
+// 副本分布的区域数量
+func replicaZoneCount(replicas []uint64, zoneMap map[uint64]string) int {
+	zones := make(map[string]struct{}, len(replicas))
+	for _, replicaId := range replicas {
+		zones[zoneMap[replicaId]] = struct{}{}
+	}
+	return len(zones)
+}
+
+// 所有节点的区域数量
+func totalZoneCount(zoneMap map[uint64]string) int {
+	zones := make(map[string]struct{}, len(zoneMap))
+	for _, zone := range zoneMap {
+		zones[zone] = struct{}{}
+	}
+	return len(zones)
+}
+
+// 将replicaId替换为toNodeId后，副本分布的区域数量
+func replaceZoneCount(replicas []uint64, replicaId, toNodeId uint64, zoneMap map[uint64]string) int {
+	newReplicas := make([]uint64, 0, len(replicas))
+	for _, id := range replicas {
+		if id == replicaId {
+			newReplicas = append(newReplicas, toNodeId)
+			continue
+		}
+		newReplicas = append(newReplicas, id)
+	}
+	return replicaZoneCount(newReplicas, zoneMap)
+}
+
+// 按区域交叉排列节点（每个区域内按节点id排序），
+// 这样连续取的副本会尽量分布在不同的区域
+func interleaveByZone(nodeIds []uint64, zoneMap map[uint64]string) []uint64 {
+	zoneNodeMap := make(map[string][]uint64)
+	zones := make([]string, 0)
+	for _, nodeId := range nodeIds {
+		zone := zoneMap[nodeId]
+		if _, ok := zoneNodeMap[zone]; !ok {
+			zones = append(zones, zone)
+		}
+		zoneNodeMap[zone] = append(zoneNodeMap[zone], nodeId)
+	}
+	sort.Strings(zones)
+	for _, zone := range zones {
+		ids := zoneNodeMap[zone]
+		sort.Slice(ids, func(i, j int) bool {
+			return ids[i] < ids[j]
+		})
+	}
+
+	result := make([]uint64, 0, len(nodeIds))
+	for i := 0; len(result) < len(nodeIds); i++ {
+		for _, zone := range zones {
+			ids := zoneNodeMap[zone]
+			if i < len(ids) {
+				result = append(result, ids[i])
+			}
+		}
+	}
+	return result
+}
+
+// 按顺序从nodeIds中选出count个副本，优先选择还未被选中的区域的节点
+func selectZoneSpreadReplicas(nodeIds []uint64, count int, zoneMap map[uint64]string) []uint64 {
+	replicas := make([]uint64, 0, count)
+	selected := make(map[uint64]bool, count)
+	usedZones := make(map[string]bool)
+	for _, nodeId := range nodeIds { // 第一轮只选新区域的节点
+		if len(replicas) >= count {
+			return replicas
+		}
+		zone := zoneMap[nodeId]
+		if usedZones[zone] {
+			continue
+		}
+		usedZones[zone] = true
+		selected[nodeId] = true
+		replicas = append(replicas, nodeId)
+	}
+	for _, nodeId := range nodeIds { // 区域不够时，按顺序补齐
+		if len(replicas) >= count {
+			break
+		}
+		if selected[nodeId] {
+			continue
+		}
+		selected[nodeId] = true
+		replicas = append(replicas, nodeId)
+	}
+	return replicas
+}
@@ -0,0 +1,62 @@
+package event
+
+import (
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/node/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeZoneMap(t *testing.T) {
+	zoneMap := nodeZoneMap([]*types.Node{testNode(1, "a"), testNode(2, "b"), testNode(3, "")})
+	assert.Equal(t, map[uint64]string{1: "a", 2: "b", 3: ""}, zoneMap)
+}
+
+func TestReplicaZoneCount(t *testing.T) {
+	zoneMap := map[uint64]string{1: "a", 2: "a", 3: "b", 4: "c"}
+
+	assert.Equal(t, 1, replicaZoneCount([]uint64{1, 2}, zoneMap))
+	assert.Equal(t, 2, replicaZoneCount([]uint64{1, 2, 3}, zoneMap))
+	assert.Equal(t, 3, replicaZoneCount([]uint64{1, 3, 4}, zoneMap))
+	assert.Equal(t, 0, replicaZoneCount(nil, zoneMap))
+	// 没有区域的节点算作同一个区域
+	assert.Equal(t, 1, replicaZoneCount([]uint64{5, 6}, zoneMap))
+}
+
+func TestTotalZoneCount(t *testing.T) {
+	assert.Equal(t, 3, totalZoneCount(map[uint64]string{1: "a", 2: "a", 3: "b", 4: "c"}))
+	assert.Equal(t, 1, totalZoneCount(map[uint64]string{1: "", 2: ""}))
+	assert.Equal(t, 0, totalZoneCount(nil))
+}
+
+func TestReplaceZoneCount(t *testing.T) {
+	zoneMap := map[uint64]string{1: "a", 2: "a", 3: "b", 4: "c"}
+
+	assert.Equal(t, 2, replaceZoneCount([]uint64{1, 2}, 2, 3, zoneMap))    // a,a -> a,b
+	assert.Equal(t, 1, replaceZoneCount([]uint64{1, 3}, 3, 2, zoneMap))    // a,b -> a,a
+	assert.Equal(t, 3, replaceZoneCount([]uint64{1, 2, 3}, 1, 4, zoneMap)) // a,a,b -> c,a,b
+	assert.Equal(t, 2, replaceZoneCount([]uint64{1, 3}, 5, 4, zoneMap))    // 不在副本中，不替换
+}
+
+func TestInterleaveByZone(t *testing.T) {
+	zoneMap := map[uint64]string{1: "b", 2: "a", 3: "a", 4: "b", 5: "c", 6: "a"}
+
+	// 区域按名称排序，区域内按节点id排序，然后每个区域轮流取一个
+	result := interleaveByZone([]uint64{6, 5, 4, 3, 2, 1}, zoneMap)
+	assert.Equal(t, []uint64{2, 1, 5, 3, 4, 6}, result)
+
+	assert.Empty(t, interleaveByZone(nil, zoneMap))
+}
+
+func TestSelectZoneSpreadReplicas(t *testing.T) {
+	zoneMap := map[uint64]string{1: "a", 2: "a", 3: "b", 4: "b", 5: "c"}
+
+	// 先每个区域选一个
+	assert.Equal(t, []uint64{1, 3, 5}, selectZoneSpreadReplicas([]uint64{1, 2, 3, 4, 5}, 3, zoneMap))
+	// 区域不够时按顺序补齐
+	assert.Equal(t, []uint64{1, 3, 5, 2}, selectZoneSpreadReplicas([]uint64{1, 2, 3, 4, 5}, 4, zoneMap))
+	// 节点不够时返回全部
+	assert.Equal(t, []uint64{2, 4, 1}, selectZoneSpreadReplicas([]uint64{2, 4, 1}, 5, zoneMap))
+	// 不超过count
+	assert.Equal(t, []uint64{4}, selectZoneSpreadReplicas([]uint64{4, 3, 5}, 1, zoneMap))
+}
@@ -145,6 +145,10 @@ func (n *Node) Equal(v *Node) bool {
 	if n.ClusterAddr != v.ClusterAddr {
 		return false
 	}
+
+	if n.Zone != v.Zone {
+		return false
+	}
 	return true
 }
 
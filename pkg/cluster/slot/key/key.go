@@ -10,6 +10,8 @@ const (
 	maxIndexKeySize             uint64 = 12
 	appliedIndexKeySize         uint64 = 12
 	leaderTermStartIndexKeySize uint64 = 16
+	compactedKeySize            uint64 = 12
+	indexedKeySize              uint64 = 12
 )
 
 var (
@@ -17,6 +19,8 @@ var (
 	appliedIndexKey               = [2]byte{0x2, 0x2}
 	maxIndexKeyHeader             = [2]byte{0x3, 0x3}
 	leaderTermStartIndexKeyHeader = [2]byte{0x4, 0x4}
+	compactedKey                  = [2]byte{0x5, 0x5}
+	indexedKey                    = [2]byte{0x6, 0x6}
 )
 
 func NewLogKey(shardNo string, index uint64) []byte {
@@ -69,6 +73,30 @@ func NewAppliedIndexKey(shardNo string) []byte {
 	return key
 }
 
+// NewCompactedKey 已压缩的日志下标和任期
+func NewCompactedKey(shardNo string) []byte {
+	key := make([]byte, compactedKeySize)
+	shardID := shardNoToShardID(shardNo)
+	key[0] = compactedKey[0]
+	key[1] = compactedKey[1]
+	key[2] = 0
+	key[3] = 0
+	binary.BigEndian.PutUint64(key[4:], shardID)
+	return key
+}
+
+// NewIndexedKey 已补齐索引的日志下标
+func NewIndexedKey(shardNo string) []byte {
+	key := make([]byte, indexedKeySize)
+	shardID := shardNoToShardID(shardNo)
+	key[0] = indexedKey[0]
+	key[1] = indexedKey[1]
+	key[2] = 0
+	key[3] = 0
+	binary.BigEndian.PutUint64(key[4:], shardID)
+	return key
+}
+
 func shardNoToShardID(shardNo string) uint64 {
 	h := fnv.New64a()
 	_, err := h.Write([]byte(shardNo))
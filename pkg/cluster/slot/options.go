@@ -1,6 +1,8 @@
 package slot
 
 import (
+	"io"
+
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/icluster"
 	"github.com/WuKongIM/WuKongIM/pkg/raft/raftgroup"
 	"github.com/WuKongIM/WuKongIM/pkg/raft/types"
@@ -25,12 +27,27 @@ type Options struct {
 
 	// OnSaveConfig 保存槽配置
 	OnSaveConfig func(slotId uint32, cfg types.Config) error
+
+	// OnSnapshot 把槽状态机的快照数据写入w，数据需要包含所有已应用的日志
+	OnSnapshot func(slotId uint32, w io.Writer) error
+	// OnApplySnapshot 从r读取快照数据恢复槽状态机
+	OnApplySnapshot func(slotId uint32, r io.Reader) error
+	// OnIndexLogs 第一次生成快照或压缩日志前，把已应用的日志分批交给状态机补齐生成快照需要的索引（升级前应用的日志没有索引），需要是幂等的
+	OnIndexLogs func(slotId uint32, logs []types.Log) error
+
+	// CompactLogThreshold 已应用但未压缩的日志超过此数量时压缩日志，0表示不压缩，默认不压缩（需要设置OnSnapshot和OnApplySnapshot才生效）
+	CompactLogThreshold uint64
+	// CompactLogRetain 压缩日志时保留最近的日志数量
+	CompactLogRetain uint64
 }
 
 func NewOptions(opt ...Option) *Options {
 	defaultOpts := &Options{
 		DataDir:        "clusterdata",
 		SlotDbShardNum: 8,
+
+		CompactLogThreshold: 0,
+		CompactLogRetain:    1000,
 	}
 	for _, o := range opt {
 		o(defaultOpts)
@@ -88,3 +105,33 @@ func WithOnSaveConfig(onSaveConfig func(slotId uint32, cfg types.Config) error)
 		o.OnSaveConfig = onSaveConfig
 	}
 }
+
+func WithOnSnapshot(onSnapshot func(slotId uint32, w io.Writer) error) Option {
+	return func(o *Options) {
+		o.OnSnapshot = onSnapshot
+	}
+}
+
+func WithOnApplySnapshot(onApplySnapshot func(slotId uint32, r io.Reader) error) Option {
+	return func(o *Options) {
+		o.OnApplySnapshot = onApplySnapshot
+	}
+}
+
+func WithOnIndexLogs(onIndexLogs func(slotId uint32, logs []types.Log) error) Option {
+	return func(o *Options) {
+		o.OnIndexLogs = onIndexLogs
+	}
+}
+
+func WithCompactLogThreshold(threshold uint64) Option {
+	return func(o *Options) {
+		o.CompactLogThreshold = threshold
+	}
+}
+
+func WithCompactLogRetain(retain uint64) Option {
+	return func(o *Options) {
+		o.CompactLogRetain = retain
+	}
+}
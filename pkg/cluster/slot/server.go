@@ -141,6 +141,11 @@ func (s *Server) GetSlotRaft(slotId uint32) *Slot {
 	return raft.(*Slot)
 }
 
+// TransferLeader 将槽的领导权转让给指定节点
+func (s *Server) TransferLeader(ctx context.Context, slotId uint32, to uint64) error {
+	return s.raftGroup.TransferLeader(ctx, SlotIdToKey(slotId), to)
+}
+
 func (s *Server) GetLogsInReverseOrder(slotId uint32, startLogIndex uint64, endLogIndex uint64, limit int) ([]types.Log, error) {
 	shardNo := SlotIdToKey(slotId)
 	return s.storage.GetLogsInReverseOrder(shardNo, startLogIndex, endLogIndex, limit)
@@ -29,7 +29,12 @@ func newSlot(slot *types.Slot, s *Server) *Slot {
 	if err != nil {
 		st.Panic("get last term failed", zap.Error(err))
 	}
-	node := raft.NewNode(lastLogIndex, state, raft.NewOptions(raft.WithKey(shardNo), raft.WithNodeId(s.opts.NodeId)))
+	raftOpts := []raft.Option{raft.WithKey(shardNo), raft.WithNodeId(s.opts.NodeId)}
+	// 槽的状态机在外部，只有提供了快照回调才能压缩日志
+	if s.opts.OnSnapshot != nil && s.opts.OnApplySnapshot != nil {
+		raftOpts = append(raftOpts, raft.WithCompactLogThreshold(s.opts.CompactLogThreshold), raft.WithCompactLogRetain(s.opts.CompactLogRetain))
+	}
+	node := raft.NewNode(lastLogIndex, state, raft.NewOptions(raftOpts...))
 	st.Node = node
 
 	return st
@@ -5,10 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"hash/fnv"
+	"io"
 	"math"
+	"os"
+	"path"
 	"time"
 
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/slot/key"
+	"github.com/WuKongIM/WuKongIM/pkg/keylock"
 	"github.com/WuKongIM/WuKongIM/pkg/raft/types"
 	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
 	"github.com/WuKongIM/WuKongIM/pkg/wklog"
@@ -28,6 +32,11 @@ type PebbleShardLogStorage struct {
 
 	stopper syncutil.Stopper
 	s       *Server
+
+	// 应用日志和生成、安装快照按槽互斥，保证快照数据和快照下标一致（槽数量有限，不需要清理锁）
+	applyLock *keylock.KeyLock
+	// 补齐日志索引按槽互斥，不阻塞应用日志
+	indexLock *keylock.KeyLock
 }
 
 func NewPebbleShardLogStorage(s *Server, path string, shardNum uint32) *PebbleShardLogStorage {
@@ -40,9 +49,11 @@ func NewPebbleShardLogStorage(s *Server, path string, shardNum uint32) *PebbleSh
 		noSync: &pebble.WriteOptions{
 			Sync: false,
 		},
-		Log:     wklog.NewWKLog(fmt.Sprintf("PebbleShardLogStorage[%s]", path)),
-		stopper: *syncutil.NewStopper(),
-		s:       s,
+		Log:       wklog.NewWKLog(fmt.Sprintf("PebbleShardLogStorage[%s]", path)),
+		stopper:   *syncutil.NewStopper(),
+		s:         s,
+		applyLock: keylock.NewKeyLock(),
+		indexLock: keylock.NewKeyLock(),
 	}
 }
 
@@ -80,6 +91,14 @@ func (p *PebbleShardLogStorage) defaultPebbleOptions() *pebble.Options {
 
 func (p *PebbleShardLogStorage) Open() error {
 
+	// 快照文件只在发送和接收快照期间使用，重启后残留的都没用了
+	if err := os.RemoveAll(p.snapshotDir()); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(p.snapshotDir(), 0755); err != nil {
+		return err
+	}
+
 	opts := p.defaultPebbleOptions()
 	for i := 0; i < int(p.shardNum); i++ {
 		db, err := pebble.Open(fmt.Sprintf("%s/shard%03d", p.path, i), opts)
@@ -254,6 +273,14 @@ func (p *PebbleShardLogStorage) GetLogs(shardNo string, startLogIndex uint64, en
 	// 	return nil, err
 	// }
 
+	compactedIndex, _, err := p.compacted(shardNo)
+	if err != nil {
+		return nil, err
+	}
+	if compactedIndex > 0 && startLogIndex <= compactedIndex {
+		return nil, types.ErrCompacted
+	}
+
 	lowKey := key.NewLogKey(shardNo, startLogIndex)
 	if endLogIndex == 0 {
 		endLogIndex = math.MaxUint64
@@ -297,6 +324,9 @@ func (p *PebbleShardLogStorage) GetLogs(shardNo string, startLogIndex uint64, en
 }
 
 func (p *PebbleShardLogStorage) Apply(shardNo string, logs []types.Log) error {
+	p.applyLock.Lock(shardNo)
+	defer p.applyLock.Unlock(shardNo)
+
 	if p.s.opts.OnApply != nil {
 		slotId := KeyToSlotId(shardNo)
 		err := p.s.opts.OnApply(slotId, logs)
@@ -385,8 +415,8 @@ func (p *PebbleShardLogStorage) LastIndexAndTerm(shardNo string) (uint64, uint32
 	if err != nil {
 		return 0, 0, err
 	}
-	if lastIndex == 0 {
-		return 0, 0, nil
+	if lastIndex == 0 { // 没有日志或日志都被压缩了，以快照的下标为准
+		return p.compacted(shardNo)
 	}
 	log, err := p.getLog(shardNo, lastIndex)
 	if err != nil {
@@ -453,6 +483,315 @@ func (p *PebbleShardLogStorage) lastLog(shardNo string) (types.Log, error) {
 	return types.EmptyLog, nil
 }
 
+// GetSnapshot 获取槽状态机的快照，快照数据导出到本地文件，发送时按块从文件读取
+func (p *PebbleShardLogStorage) GetSnapshot(shardNo string) (types.Snapshot, error) {
+	if p.s.opts.OnSnapshot == nil {
+		return types.Snapshot{}, errors.New("slot snapshot is not supported")
+	}
+	// 快照按索引导出数据，先补齐索引
+	err := p.indexLogs(shardNo)
+	if err != nil {
+		return types.Snapshot{}, err
+	}
+	// 生成快照期间不能应用日志，否则快照数据会比快照下标新
+	p.applyLock.Lock(shardNo)
+	defer p.applyLock.Unlock(shardNo)
+
+	appliedIdx, err := p.AppliedIndex(shardNo)
+	if err != nil {
+		return types.Snapshot{}, err
+	}
+	term, err := p.termOf(shardNo, appliedIdx)
+	if err != nil {
+		return types.Snapshot{}, err
+	}
+
+	f, err := os.CreateTemp(p.snapshotDir(), fmt.Sprintf("send-%s-*.snap", shardNo))
+	if err != nil {
+		return types.Snapshot{}, err
+	}
+	source := &snapshotFile{File: f}
+	err = p.s.opts.OnSnapshot(KeyToSlotId(shardNo), f)
+	if err != nil {
+		_ = source.Close()
+		return types.Snapshot{}, err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		_ = source.Close()
+		return types.Snapshot{}, err
+	}
+	return types.Snapshot{
+		Index:  appliedIdx,
+		Term:   term,
+		Total:  uint64(stat.Size()),
+		Source: source,
+	}, nil
+}
+
+// ApplySnapshot 按块接收领导发过来的快照，先把块追加到本地文件，收到最后一块后再安装
+func (p *PebbleShardLogStorage) ApplySnapshot(shardNo string, chunk types.Snapshot) error {
+	if p.s.opts.OnApplySnapshot == nil {
+		return errors.New("slot snapshot is not supported")
+	}
+	filePath := path.Join(p.snapshotDir(), fmt.Sprintf("recv-%s.snap", shardNo))
+	err := p.saveSnapshotChunk(filePath, chunk)
+	if err != nil {
+		return err
+	}
+	if !chunk.IsLastChunk() {
+		return nil
+	}
+	defer os.Remove(filePath)
+
+	// 状态机恢复快照时要清除快照里没有的本地数据，需要本地数据的索引是完整的
+	err = p.indexLogs(shardNo)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return p.installSnapshot(shardNo, chunk, f)
+}
+
+// saveSnapshotChunk 把快照块追加到文件，第一块时清空文件
+func (p *PebbleShardLogStorage) saveSnapshotChunk(filePath string, chunk types.Snapshot) error {
+	flag := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	if chunk.Offset == 0 {
+		flag |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(filePath, flag, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if uint64(stat.Size()) != chunk.Offset {
+		return fmt.Errorf("snapshot chunk is discontinuous, saved size[%d] offset[%d]", stat.Size(), chunk.Offset)
+	}
+	_, err = f.Write(chunk.Data)
+	return err
+}
+
+// installSnapshot 用接收完的快照数据恢复状态机，并删除本地所有日志
+func (p *PebbleShardLogStorage) installSnapshot(shardNo string, snapshot types.Snapshot, r io.Reader) error {
+	p.applyLock.Lock(shardNo)
+	defer p.applyLock.Unlock(shardNo)
+
+	err := p.s.opts.OnApplySnapshot(KeyToSlotId(shardNo), r)
+	if err != nil {
+		return err
+	}
+
+	db := p.shardDB(shardNo)
+	batch := db.NewBatch()
+	defer batch.Close()
+	err = batch.DeleteRange(key.NewLogKey(shardNo, 0), key.NewLogKey(shardNo, math.MaxUint64), p.noSync)
+	if err != nil {
+		return err
+	}
+	err = batch.DeleteRange(key.NewLeaderTermStartIndexKey(shardNo, snapshot.Term), key.NewLeaderTermStartIndexKey(shardNo, math.MaxUint32), p.noSync)
+	if err != nil {
+		return err
+	}
+	indexData := make([]byte, 8)
+	binary.BigEndian.PutUint64(indexData, snapshot.TermStartIndex)
+	err = batch.Set(key.NewLeaderTermStartIndexKey(shardNo, snapshot.Term), indexData, p.noSync)
+	if err != nil {
+		return err
+	}
+	err = p.setCompactedWithWriter(shardNo, snapshot.Index, snapshot.Term, batch, p.noSync)
+	if err != nil {
+		return err
+	}
+	// 快照恢复的数据已由状态机建好索引
+	err = p.setIndexedIndexWithWriter(shardNo, indexedDone, batch, p.noSync)
+	if err != nil {
+		return err
+	}
+	appliedData := make([]byte, 16)
+	binary.BigEndian.PutUint64(appliedData, snapshot.Index)
+	binary.BigEndian.PutUint64(appliedData[8:], uint64(time.Now().UnixNano()))
+	err = batch.Set(key.NewAppliedIndexKey(shardNo), appliedData, p.noSync)
+	if err != nil {
+		return err
+	}
+	return batch.Commit(p.sync)
+}
+
+func (p *PebbleShardLogStorage) snapshotDir() string {
+	return path.Join(p.path, "snapshots")
+}
+
+// CompactLogTo 压缩日志，删除index及之前的日志
+func (p *PebbleShardLogStorage) CompactLogTo(shardNo string, index uint64) error {
+	appliedIdx, err := p.AppliedIndex(shardNo)
+	if err != nil {
+		return err
+	}
+	if index > appliedIdx {
+		return fmt.Errorf("compact index[%d] is greater than applied index[%d]", index, appliedIdx)
+	}
+	compactedIndex, _, err := p.compacted(shardNo)
+	if err != nil {
+		return err
+	}
+	if index <= compactedIndex {
+		return nil
+	}
+	// 压缩后就没法再补索引了，压缩前先补齐
+	err = p.indexLogs(shardNo)
+	if err != nil {
+		return err
+	}
+	log, err := p.getLog(shardNo, index)
+	if err != nil {
+		return err
+	}
+	if log.Index == 0 {
+		return fmt.Errorf("compact log[%d] not found", index)
+	}
+
+	batch := p.shardDB(shardNo).NewBatch()
+	defer batch.Close()
+	err = batch.DeleteRange(key.NewLogKey(shardNo, 0), key.NewLogKey(shardNo, index+1), p.noSync)
+	if err != nil {
+		return err
+	}
+	err = p.setCompactedWithWriter(shardNo, index, log.Term, batch, p.noSync)
+	if err != nil {
+		return err
+	}
+	return batch.Commit(p.sync)
+}
+
+// indexLogs 日志从未压缩过时，把升级前已应用的日志分批交给状态机补齐索引（日志压缩后就没法再补了）
+// 补索引期间不持有applyLock，新应用的日志由状态机应用时建索引，重复建索引不影响结果
+// 每批补完后记录进度，中途失败或重启后从进度处继续
+func (p *PebbleShardLogStorage) indexLogs(shardNo string) error {
+	if p.s.opts.OnIndexLogs == nil {
+		return nil
+	}
+	p.indexLock.Lock(shardNo)
+	defer p.indexLock.Unlock(shardNo)
+
+	indexedIdx, err := p.indexedIndex(shardNo)
+	if err != nil {
+		return err
+	}
+	if indexedIdx == indexedDone {
+		return nil
+	}
+	compactedIndex, _, err := p.compacted(shardNo)
+	if err != nil {
+		return err
+	}
+	if compactedIndex > 0 {
+		return p.setIndexedIndexWithWriter(shardNo, indexedDone, p.shardDB(shardNo), p.sync)
+	}
+	appliedIdx, err := p.AppliedIndex(shardNo)
+	if err != nil {
+		return err
+	}
+	slotId := KeyToSlotId(shardNo)
+	startIndex := indexedIdx + 1
+	for startIndex <= appliedIdx {
+		logs, err := p.GetLogs(shardNo, startIndex, appliedIdx+1, 1024*1024*4)
+		if err != nil {
+			return err
+		}
+		if len(logs) == 0 {
+			break
+		}
+		err = p.s.opts.OnIndexLogs(slotId, logs)
+		if err != nil {
+			return err
+		}
+		startIndex = logs[len(logs)-1].Index + 1
+		err = p.setIndexedIndexWithWriter(shardNo, startIndex-1, p.shardDB(shardNo), p.sync)
+		if err != nil {
+			return err
+		}
+	}
+	return p.setIndexedIndexWithWriter(shardNo, indexedDone, p.shardDB(shardNo), p.sync)
+}
+
+// indexLogs补齐后的进度，之后应用的日志都由状态机应用时建索引
+const indexedDone uint64 = math.MaxUint64
+
+// 已补齐索引的日志下标
+func (p *PebbleShardLogStorage) indexedIndex(shardNo string) (uint64, error) {
+	data, closer, err := p.shardDB(shardNo).Get(key.NewIndexedKey(shardNo))
+	if err != nil {
+		if err == pebble.ErrNotFound {
+			return 0, nil
+		}
+		return 0, err
+	}
+	defer closer.Close()
+	if len(data) < 8 {
+		return 0, nil
+	}
+	return binary.BigEndian.Uint64(data), nil
+}
+
+func (p *PebbleShardLogStorage) setIndexedIndexWithWriter(shardNo string, index uint64, w pebble.Writer, o *pebble.WriteOptions) error {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, index)
+	return w.Set(key.NewIndexedKey(shardNo), data, o)
+}
+
+// 获取指定日志下标的任期，日志被压缩了则用压缩记录的任期
+func (p *PebbleShardLogStorage) termOf(shardNo string, index uint64) (uint32, error) {
+	if index == 0 {
+		return 0, nil
+	}
+	compactedIndex, compactedTerm, err := p.compacted(shardNo)
+	if err != nil {
+		return 0, err
+	}
+	if index == compactedIndex {
+		return compactedTerm, nil
+	}
+	log, err := p.getLog(shardNo, index)
+	if err != nil {
+		return 0, err
+	}
+	if log.Index == 0 {
+		return 0, fmt.Errorf("log[%d] not found", index)
+	}
+	return log.Term, nil
+}
+
+// 已压缩的日志下标和任期
+func (p *PebbleShardLogStorage) compacted(shardNo string) (uint64, uint32, error) {
+	data, closer, err := p.shardDB(shardNo).Get(key.NewCompactedKey(shardNo))
+	if err != nil {
+		if err == pebble.ErrNotFound {
+			return 0, 0, nil
+		}
+		return 0, 0, err
+	}
+	defer closer.Close()
+	if len(data) < 12 {
+		return 0, 0, nil
+	}
+	return binary.BigEndian.Uint64(data[:8]), binary.BigEndian.Uint32(data[8:12]), nil
+}
+
+func (p *PebbleShardLogStorage) setCompactedWithWriter(shardNo string, index uint64, term uint32, w pebble.Writer, o *pebble.WriteOptions) error {
+	data := make([]byte, 12)
+	binary.BigEndian.PutUint64(data, index)
+	binary.BigEndian.PutUint32(data[8:], term)
+	return w.Set(key.NewCompactedKey(shardNo), data, o)
+}
+
 func (p *PebbleShardLogStorage) SetAppliedIndex(shardNo string, index uint64) error {
 	maxIndexKeyData := key.NewAppliedIndexKey(shardNo)
 	maxIndexdata := make([]byte, 8)
@@ -709,3 +1048,16 @@ func (p *PebbleShardLogStorage) DeleteLeaderTermStartIndexGreaterThanTerm(shardN
 // func (l *localStorage) getChannelSlotId(channelId string) uint32 {
 // 	return wkutil.GetSlotNum(int(l.opts.SlotCount), channelId)
 // }
+
+// snapshotFile 导出到本地的快照文件，发送完后删除
+type snapshotFile struct {
+	*os.File
+}
+
+func (f *snapshotFile) Close() error {
+	err := f.File.Close()
+	if removeErr := os.Remove(f.Name()); removeErr != nil && err == nil {
+		err = removeErr
+	}
+	return err
+}
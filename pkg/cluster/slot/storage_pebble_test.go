@@ -0,0 +1,139 @@
+package slot
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/raft/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotChunks(t *testing.T) {
+	snapshotData := []byte("0123456789abcdef")
+	leader := newTestStorage(t, WithOnApply(func(slotId uint32, logs []types.Log) error {
+		return nil
+	}), WithOnSnapshot(func(slotId uint32, w io.Writer) error {
+		_, err := w.Write(snapshotData)
+		return err
+	}))
+	var applied []byte
+	follower := newTestStorage(t, WithOnApplySnapshot(func(slotId uint32, r io.Reader) error {
+		var err error
+		applied, err = io.ReadAll(r)
+		return err
+	}))
+
+	logs := []types.Log{{Index: 1, Term: 1}, {Index: 2, Term: 1}, {Index: 3, Term: 2}}
+	err := leader.AppendLogs("1", logs, nil)
+	assert.NoError(t, err)
+	err = leader.Apply("1", logs)
+	assert.NoError(t, err)
+
+	// 快照导出到文件，按块从文件读取
+	cache := types.NewSnapshotCache()
+	getSnapshot := func() (types.Snapshot, error) {
+		return leader.GetSnapshot("1")
+	}
+	var req types.Snapshot
+	for {
+		chunk, err := cache.Chunk("2", req, 5, getSnapshot)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(3), chunk.Index)
+		assert.Equal(t, uint32(2), chunk.Term)
+		assert.Equal(t, uint64(len(snapshotData)), chunk.Total)
+
+		// 副本每块先保存到文件，最后一块时安装
+		err = follower.ApplySnapshot("1", chunk)
+		assert.NoError(t, err)
+		if chunk.IsLastChunk() {
+			break
+		}
+		assert.Nil(t, applied)
+		req = types.Snapshot{Index: chunk.Index, Offset: chunk.Offset + uint64(len(chunk.Data))}
+	}
+	assert.Equal(t, snapshotData, applied)
+
+	appliedIdx, err := follower.AppliedIndex("1")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), appliedIdx)
+
+	// 发送和接收完后快照文件都已删除
+	assertEmptyDir(t, leader.snapshotDir())
+	assertEmptyDir(t, follower.snapshotDir())
+}
+
+func TestSnapshotChunkDiscontinuous(t *testing.T) {
+	follower := newTestStorage(t, WithOnApplySnapshot(func(slotId uint32, r io.Reader) error {
+		return nil
+	}))
+	snapshot := types.Snapshot{Index: 3, Term: 1, Data: []byte("0123456789")}
+
+	err := follower.ApplySnapshot("1", snapshot.Chunk(0, 4))
+	assert.NoError(t, err)
+	err = follower.ApplySnapshot("1", snapshot.Chunk(8, 4))
+	assert.Error(t, err)
+}
+
+func TestIndexLogsBeforeCompact(t *testing.T) {
+	var (
+		indexed   []uint64
+		indexErr  = errors.New("index failed")
+		failIndex = true
+	)
+	storage := newTestStorage(t, WithOnApply(func(slotId uint32, logs []types.Log) error {
+		return nil
+	}), WithOnIndexLogs(func(slotId uint32, logs []types.Log) error {
+		if failIndex {
+			return indexErr
+		}
+		for _, log := range logs {
+			indexed = append(indexed, log.Index)
+		}
+		return nil
+	}))
+
+	logs := []types.Log{{Index: 1, Term: 1}, {Index: 2, Term: 1}, {Index: 3, Term: 1}}
+	err := storage.AppendLogs("1", logs, nil)
+	assert.NoError(t, err)
+	err = storage.Apply("1", logs)
+	assert.NoError(t, err)
+
+	// 索引没补齐前不能压缩日志
+	err = storage.CompactLogTo("1", 2)
+	assert.ErrorIs(t, err, indexErr)
+	compactedIndex, _, err := storage.compacted("1")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), compactedIndex)
+
+	failIndex = false
+	err = storage.CompactLogTo("1", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{1, 2, 3}, indexed)
+	indexedIdx, err := storage.indexedIndex("1")
+	assert.NoError(t, err)
+	assert.Equal(t, indexedDone, indexedIdx)
+
+	// 补齐后不再补索引
+	err = storage.CompactLogTo("1", 3)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{1, 2, 3}, indexed)
+}
+
+func newTestStorage(t *testing.T, opt ...Option) *PebbleShardLogStorage {
+	s := &Server{opts: NewOptions(opt...)}
+	storage := NewPebbleShardLogStorage(s, t.TempDir(), 1)
+	err := storage.Open()
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		_ = storage.Close()
+	})
+	return storage
+}
+
+func assertEmptyDir(t *testing.T, dir string) {
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(entries))
+}
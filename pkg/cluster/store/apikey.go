@@ -0,0 +1,36 @@
+package store
+
+import (
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"go.uber.org/zap"
+)
+
+func (s *Store) AddOrUpdateApiKey(apiKey wkdb.ApiKey) error {
+	data := EncodeCMDApiKey(apiKey)
+	cmd := NewCMD(CMDAddOrUpdateApiKey, data)
+	cmdData, err := cmd.Marshal()
+	if err != nil {
+		s.Error("AddOrUpdateApiKey: marshal cmd failed", zap.Error(err))
+		return err
+	}
+	var slotId uint32 = 0 // 默认数据在0槽位上
+	_, err = s.opts.Slot.ProposeUntilApplied(slotId, cmdData)
+	return err
+}
+
+func (s *Store) RemoveApiKey(id uint64) error {
+	data := EncodeCMDRemoveApiKey(id)
+	cmd := NewCMD(CMDRemoveApiKey, data)
+	cmdData, err := cmd.Marshal()
+	if err != nil {
+		s.Error("RemoveApiKey: marshal cmd failed", zap.Error(err))
+		return err
+	}
+	var slotId uint32 = 0 // 默认数据在0槽位上
+	_, err = s.opts.Slot.ProposeUntilApplied(slotId, cmdData)
+	return err
+}
+
+func (s *Store) GetApiKeys() ([]wkdb.ApiKey, error) {
+	return s.wdb.GetApiKeys()
+}
@@ -0,0 +1,53 @@
+package store
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+// AppendAuditLogs 批量追加审计日志，按日志id分散到各个slot，通过slot的副本复制，节点宕机后日志不会丢失
+func (s *Store) AppendAuditLogs(logs []wkdb.AuditLog) error {
+	slotLogsMap := make(map[uint32][]wkdb.AuditLog)
+	for _, log := range logs {
+		slotId := s.opts.Slot.GetSlotId(strconv.FormatUint(log.Id, 10))
+		slotLogsMap[slotId] = append(slotLogsMap[slotId], log)
+	}
+
+	timeoutctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	g, _ := errgroup.WithContext(timeoutctx)
+	g.SetLimit(100)
+	for slotId, logs := range slotLogsMap {
+		slotId, logs := slotId, logs
+		g.Go(func() error {
+			cmd := NewCMD(CMDAppendAuditLogs, EncodeCMDAppendAuditLogs(logs))
+			cmdData, err := cmd.Marshal()
+			if err != nil {
+				return err
+			}
+			_, err = s.opts.Slot.ProposeUntilAppliedTimeout(timeoutctx, slotId, cmdData)
+			if err != nil {
+				s.Error("ProposeUntilAppliedTimeout failed", zap.Error(err), zap.Uint32("slotId", slotId), zap.Int("logs", len(logs)))
+				return err
+			}
+			return nil
+		})
+	}
+	return g.Wait()
+}
+
+// SearchAuditLog 搜索本节点上的审计日志（本节点是副本的slot上的日志）
+func (s *Store) SearchAuditLog(req wkdb.AuditLogSearchReq) ([]wkdb.AuditLog, error) {
+	return s.wdb.SearchAuditLog(req)
+}
+
+// RemoveAuditLogsBefore 删除本节点上超过保留时间的审计日志（每个节点各自清理）
+func (s *Store) RemoveAuditLogsBefore(t time.Time) error {
+	return s.wdb.RemoveAuditLogsBefore(t)
+}
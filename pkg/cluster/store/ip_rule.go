@@ -0,0 +1,36 @@
+package store
+
+import (
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"go.uber.org/zap"
+)
+
+func (s *Store) AddOrUpdateIpRule(rule wkdb.IpRule) error {
+	data := EncodeCMDIpRule(rule)
+	cmd := NewCMD(CMDAddOrUpdateIpRule, data)
+	cmdData, err := cmd.Marshal()
+	if err != nil {
+		s.Error("AddOrUpdateIpRule: marshal cmd failed", zap.Error(err))
+		return err
+	}
+	var slotId uint32 = 0 // 默认数据在0槽位上
+	_, err = s.opts.Slot.ProposeUntilApplied(slotId, cmdData)
+	return err
+}
+
+func (s *Store) RemoveIpRule(id uint64) error {
+	data := EncodeCMDRemoveIpRule(id)
+	cmd := NewCMD(CMDRemoveIpRule, data)
+	cmdData, err := cmd.Marshal()
+	if err != nil {
+		s.Error("RemoveIpRule: marshal cmd failed", zap.Error(err))
+		return err
+	}
+	var slotId uint32 = 0 // 默认数据在0槽位上
+	_, err = s.opts.Slot.ProposeUntilApplied(slotId, cmdData)
+	return err
+}
+
+func (s *Store) GetIpRules() ([]wkdb.IpRule, error) {
+	return s.wdb.GetIpRules()
+}
@@ -89,6 +89,26 @@ const (
 	CMDAddOrUpdateTester
 	// 移除测试机
 	CMDRemoveTester
+	// 更新用户在线状态（最后上线/离线时间）
+	CMDUpdateUserOnlineStatus
+	// 添加登录日志
+	CMDAddLoginLog
+	// 添加或更新api key
+	CMDAddOrUpdateApiKey
+	// 移除api key
+	CMDRemoveApiKey
+	// 添加吊销的管理后台token
+	CMDAddRevokedToken
+	// 添加或更新连接的ip规则
+	CMDAddOrUpdateIpRule
+	// 移除连接的ip规则
+	CMDRemoveIpRule
+	// 批量更新用户在线状态（最后上线/离线时间）
+	CMDBatchUpdateUserOnlineStatus
+	// 批量追加审计日志
+	CMDAppendAuditLogs
+	// 批量添加登录日志
+	CMDBatchAddLoginLogs
 )
 
 func (c CMDType) Uint16() uint16 {
@@ -171,6 +191,26 @@ func (c CMDType) String() string {
 		return "CMDAddOrUpdateTester"
 	case CMDRemoveTester:
 		return "CMDRemoveTester"
+	case CMDUpdateUserOnlineStatus:
+		return "CMDUpdateUserOnlineStatus"
+	case CMDAddLoginLog:
+		return "CMDAddLoginLog"
+	case CMDAddOrUpdateApiKey:
+		return "CMDAddOrUpdateApiKey"
+	case CMDRemoveApiKey:
+		return "CMDRemoveApiKey"
+	case CMDAddRevokedToken:
+		return "CMDAddRevokedToken"
+	case CMDAddOrUpdateIpRule:
+		return "CMDAddOrUpdateIpRule"
+	case CMDRemoveIpRule:
+		return "CMDRemoveIpRule"
+	case CMDBatchUpdateUserOnlineStatus:
+		return "CMDBatchUpdateUserOnlineStatus"
+	case CMDAppendAuditLogs:
+		return "CMDAppendAuditLogs"
+	case CMDBatchAddLoginLogs:
+		return "CMDBatchAddLoginLogs"
 	default:
 		return fmt.Sprintf("CMDUnknown[%d]", c)
 	}
@@ -460,6 +500,85 @@ func (c *CMD) CMDContent() (string, error) {
 			return "", err
 		}
 		return wkutil.ToJSON(conversations), nil
+	case CMDUpdateUserOnlineStatus:
+		uid, deviceFlag, online, at, err := c.DecodeCMDUpdateUserOnlineStatus()
+		if err != nil {
+			return "", err
+		}
+		return wkutil.ToJSON(map[string]interface{}{
+			"uid":         uid,
+			"device_flag": deviceFlag,
+			"online":      online,
+			"at":          at,
+		}), nil
+	case CMDBatchUpdateUserOnlineStatus:
+		statuses, err := c.DecodeCMDBatchUpdateUserOnlineStatus()
+		if err != nil {
+			return "", err
+		}
+		return wkutil.ToJSON(statuses), nil
+	case CMDAppendAuditLogs:
+		logs, err := c.DecodeCMDAppendAuditLogs()
+		if err != nil {
+			return "", err
+		}
+		return wkutil.ToJSON(logs), nil
+	case CMDAddLoginLog:
+		log, maxCount, err := c.DecodeCMDAddLoginLog()
+		if err != nil {
+			return "", err
+		}
+		return wkutil.ToJSON(map[string]interface{}{
+			"log":       log,
+			"max_count": maxCount,
+		}), nil
+	case CMDBatchAddLoginLogs:
+		logs, maxCount, err := c.DecodeCMDBatchAddLoginLogs()
+		if err != nil {
+			return "", err
+		}
+		return wkutil.ToJSON(map[string]interface{}{
+			"logs":      logs,
+			"max_count": maxCount,
+		}), nil
+	case CMDAddOrUpdateApiKey:
+		apiKey, err := c.DecodeCMDApiKey()
+		if err != nil {
+			return "", err
+		}
+		return wkutil.ToJSON(map[string]interface{}{
+			"id":     apiKey.Id,
+			"name":   apiKey.Name,
+			"scopes": apiKey.Scopes,
+		}), nil
+	case CMDRemoveApiKey:
+		id, err := c.DecodeCMDRemoveApiKey()
+		if err != nil {
+			return "", err
+		}
+		return wkutil.ToJSON(map[string]interface{}{
+			"id": id,
+		}), nil
+	case CMDAddRevokedToken:
+		token, err := c.DecodeCMDRevokedToken()
+		if err != nil {
+			return "", err
+		}
+		return wkutil.ToJSON(token), nil
+	case CMDAddOrUpdateIpRule:
+		rule, err := c.DecodeCMDIpRule()
+		if err != nil {
+			return "", err
+		}
+		return wkutil.ToJSON(rule), nil
+	case CMDRemoveIpRule:
+		id, err := c.DecodeCMDRemoveIpRule()
+		if err != nil {
+			return "", err
+		}
+		return wkutil.ToJSON(map[string]interface{}{
+			"id": id,
+		}), nil
 
 	}
 
@@ -640,6 +759,12 @@ func EncodeCMDDevice(d wkdb.Device) []byte {
 		enc.WriteUint64(0)
 	}
 
+	if d.TokenExpireAt != nil {
+		enc.WriteUint64(uint64(d.TokenExpireAt.UnixNano()))
+	} else {
+		enc.WriteUint64(0)
+	}
+
 	return enc.Bytes()
 }
 
@@ -682,6 +807,18 @@ func (c *CMD) DecodeCMDDevice() (d wkdb.Device, err error) {
 		d.UpdatedAt = &ct
 	}
 
+	// 兼容旧版本没有token过期时间的数据
+	if decoder.Len() > 0 {
+		var tokenExpireAtUnixNano uint64
+		if tokenExpireAtUnixNano, err = decoder.Uint64(); err != nil {
+			return
+		}
+		if tokenExpireAtUnixNano > 0 {
+			ct := time.Unix(int64(tokenExpireAtUnixNano/1e9), int64(tokenExpireAtUnixNano%1e9))
+			d.TokenExpireAt = &ct
+		}
+	}
+
 	return
 }
 
@@ -796,6 +933,10 @@ func EncodeChannelInfo(c wkdb.ChannelInfo, version CmdVersion) ([]byte, error) {
 	if version > 0 {
 		enc.WriteString(c.Webhook)
 	}
+	if version > 2 {
+		enc.WriteUint32(c.SendRateLimit)
+		enc.WriteUint32(c.SlowMode)
+	}
 	return enc.Bytes(), nil
 }
 
@@ -850,6 +991,14 @@ func (c *CMD) DecodeChannelInfo() (wkdb.ChannelInfo, error) {
 			return channelInfo, err
 		}
 	}
+	if c.version > 2 {
+		if channelInfo.SendRateLimit, err = dec.Uint32(); err != nil {
+			return channelInfo, err
+		}
+		if channelInfo.SlowMode, err = dec.Uint32(); err != nil {
+			return channelInfo, err
+		}
+	}
 
 	return channelInfo, err
 }
@@ -1289,6 +1438,232 @@ func (c *CMD) DecodeCMDRemoveTester() (no string, err error) {
 	return
 }
 
+func EncodeCMDUpdateUserOnlineStatus(uid string, deviceFlag uint64, online bool, at time.Time) []byte {
+	encoder := wkproto.NewEncoder()
+	defer encoder.End()
+	encoder.WriteString(uid)
+	encoder.WriteUint64(deviceFlag)
+	encoder.WriteUint8(wkutil.BoolToUint8(online))
+	encoder.WriteUint64(uint64(at.UnixNano()))
+	return encoder.Bytes()
+}
+
+func (c *CMD) DecodeCMDUpdateUserOnlineStatus() (uid string, deviceFlag uint64, online bool, at time.Time, err error) {
+	decoder := wkproto.NewDecoder(c.Data)
+	if uid, err = decoder.String(); err != nil {
+		return
+	}
+	if deviceFlag, err = decoder.Uint64(); err != nil {
+		return
+	}
+	var onlineUint8 uint8
+	if onlineUint8, err = decoder.Uint8(); err != nil {
+		return
+	}
+	online = wkutil.Uint8ToBool(onlineUint8)
+	var atUnixNano uint64
+	if atUnixNano, err = decoder.Uint64(); err != nil {
+		return
+	}
+	at = time.Unix(int64(atUnixNano/1e9), int64(atUnixNano%1e9))
+	return
+}
+
+// UserOnlineStatus 用户设备的上线/离线时间
+type UserOnlineStatus struct {
+	Uid        string    `json:"uid"`
+	DeviceFlag uint64    `json:"device_flag"`
+	Online     bool      `json:"online"`
+	At         time.Time `json:"at"`
+}
+
+func EncodeCMDBatchUpdateUserOnlineStatus(statuses []UserOnlineStatus) []byte {
+	encoder := wkproto.NewEncoder()
+	defer encoder.End()
+	encoder.WriteUint32(uint32(len(statuses)))
+	for _, status := range statuses {
+		encoder.WriteString(status.Uid)
+		encoder.WriteUint64(status.DeviceFlag)
+		encoder.WriteUint8(wkutil.BoolToUint8(status.Online))
+		encoder.WriteUint64(uint64(status.At.UnixNano()))
+	}
+	return encoder.Bytes()
+}
+
+func (c *CMD) DecodeCMDBatchUpdateUserOnlineStatus() ([]UserOnlineStatus, error) {
+	decoder := wkproto.NewDecoder(c.Data)
+	count, err := decoder.Uint32()
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]UserOnlineStatus, 0, count)
+	for i := 0; i < int(count); i++ {
+		var status UserOnlineStatus
+		if status.Uid, err = decoder.String(); err != nil {
+			return nil, err
+		}
+		if status.DeviceFlag, err = decoder.Uint64(); err != nil {
+			return nil, err
+		}
+		var onlineUint8 uint8
+		if onlineUint8, err = decoder.Uint8(); err != nil {
+			return nil, err
+		}
+		status.Online = wkutil.Uint8ToBool(onlineUint8)
+		var atUnixNano uint64
+		if atUnixNano, err = decoder.Uint64(); err != nil {
+			return nil, err
+		}
+		status.At = time.Unix(0, int64(atUnixNano))
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func EncodeCMDAppendAuditLogs(logs []wkdb.AuditLog) []byte {
+	encoder := wkproto.NewEncoder()
+	defer encoder.End()
+	encoder.WriteUint32(uint32(len(logs)))
+	for _, log := range logs {
+		encoder.WriteBinary(log.Encode())
+	}
+	return encoder.Bytes()
+}
+
+func (c *CMD) DecodeCMDAppendAuditLogs() ([]wkdb.AuditLog, error) {
+	decoder := wkproto.NewDecoder(c.Data)
+	count, err := decoder.Uint32()
+	if err != nil {
+		return nil, err
+	}
+	logs := make([]wkdb.AuditLog, 0, count)
+	for i := 0; i < int(count); i++ {
+		data, err := decoder.Binary()
+		if err != nil {
+			return nil, err
+		}
+		var log wkdb.AuditLog
+		if err = log.Decode(data); err != nil {
+			return nil, err
+		}
+		logs = append(logs, log)
+	}
+	return logs, nil
+}
+
+func EncodeCMDAddLoginLog(log wkdb.LoginLog, maxCount int) []byte {
+	encoder := wkproto.NewEncoder()
+	defer encoder.End()
+	encoder.WriteUint32(uint32(maxCount))
+	encoder.WriteBytes(log.Encode())
+	return encoder.Bytes()
+}
+
+func (c *CMD) DecodeCMDAddLoginLog() (log wkdb.LoginLog, maxCount int, err error) {
+	decoder := wkproto.NewDecoder(c.Data)
+	var maxCountUint32 uint32
+	if maxCountUint32, err = decoder.Uint32(); err != nil {
+		return
+	}
+	maxCount = int(maxCountUint32)
+	var logData []byte
+	if logData, err = decoder.BinaryAll(); err != nil {
+		return
+	}
+	err = log.Decode(logData)
+	return
+}
+
+func EncodeCMDBatchAddLoginLogs(logs []wkdb.LoginLog, maxCount int) []byte {
+	encoder := wkproto.NewEncoder()
+	defer encoder.End()
+	encoder.WriteUint32(uint32(maxCount))
+	encoder.WriteUint32(uint32(len(logs)))
+	for _, log := range logs {
+		encoder.WriteBinary(log.Encode())
+	}
+	return encoder.Bytes()
+}
+
+func (c *CMD) DecodeCMDBatchAddLoginLogs() (logs []wkdb.LoginLog, maxCount int, err error) {
+	decoder := wkproto.NewDecoder(c.Data)
+	var maxCountUint32 uint32
+	if maxCountUint32, err = decoder.Uint32(); err != nil {
+		return
+	}
+	maxCount = int(maxCountUint32)
+	var count uint32
+	if count, err = decoder.Uint32(); err != nil {
+		return
+	}
+	logs = make([]wkdb.LoginLog, 0, count)
+	for i := 0; i < int(count); i++ {
+		var data []byte
+		if data, err = decoder.Binary(); err != nil {
+			return
+		}
+		var log wkdb.LoginLog
+		if err = log.Decode(data); err != nil {
+			return
+		}
+		logs = append(logs, log)
+	}
+	return
+}
+
+func EncodeCMDApiKey(apiKey wkdb.ApiKey) []byte {
+	return apiKey.Encode()
+}
+
+func (c *CMD) DecodeCMDApiKey() (apiKey wkdb.ApiKey, err error) {
+	err = apiKey.Decode(c.Data)
+	return
+}
+
+func EncodeCMDRemoveApiKey(id uint64) []byte {
+	encoder := wkproto.NewEncoder()
+	defer encoder.End()
+	encoder.WriteUint64(id)
+	return encoder.Bytes()
+}
+
+func (c *CMD) DecodeCMDRemoveApiKey() (id uint64, err error) {
+	decoder := wkproto.NewDecoder(c.Data)
+	id, err = decoder.Uint64()
+	return
+}
+
+func EncodeCMDRevokedToken(token wkdb.RevokedToken) []byte {
+	return token.Encode()
+}
+
+func (c *CMD) DecodeCMDRevokedToken() (token wkdb.RevokedToken, err error) {
+	err = token.Decode(c.Data)
+	return
+}
+
+func EncodeCMDIpRule(rule wkdb.IpRule) []byte {
+	return rule.Encode()
+}
+
+func (c *CMD) DecodeCMDIpRule() (rule wkdb.IpRule, err error) {
+	err = rule.Decode(c.Data)
+	return
+}
+
+func EncodeCMDRemoveIpRule(id uint64) []byte {
+	encoder := wkproto.NewEncoder()
+	defer encoder.End()
+	encoder.WriteUint64(id)
+	return encoder.Bytes()
+}
+
+func (c *CMD) DecodeCMDRemoveIpRule() (id uint64, err error) {
+	decoder := wkproto.NewDecoder(c.Data)
+	id, err = decoder.Uint64()
+	return
+}
+
 var ErrStoreStopped = fmt.Errorf("store stopped")
 
 type applyReq struct {
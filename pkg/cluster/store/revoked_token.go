@@ -0,0 +1,23 @@
+package store
+
+import (
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"go.uber.org/zap"
+)
+
+func (s *Store) AddRevokedToken(token wkdb.RevokedToken) error {
+	data := EncodeCMDRevokedToken(token)
+	cmd := NewCMD(CMDAddRevokedToken, data)
+	cmdData, err := cmd.Marshal()
+	if err != nil {
+		s.Error("AddRevokedToken: marshal cmd failed", zap.Error(err))
+		return err
+	}
+	var slotId uint32 = 0 // 默认数据在0槽位上
+	_, err = s.opts.Slot.ProposeUntilApplied(slotId, cmdData)
+	return err
+}
+
+func (s *Store) GetRevokedTokens() ([]wkdb.RevokedToken, error) {
+	return s.wdb.GetRevokedTokens()
+}
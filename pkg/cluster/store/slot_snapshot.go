@@ -0,0 +1,754 @@
+package store
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/WuKongIM/WuKongIM/pkg/raft/types"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+)
+
+// 导出快照时每次读取的审计日志数量
+const slotSnapshotAuditLogBatch = 1000
+
+// 槽快照数据是一串记录，每条记录为 类型(1字节) + 数据长度(uvarint) + json数据
+// 按数据对象逐条导出和恢复，不需要把整个槽的数据放到内存里
+const (
+	slotSnapshotRecordChannel  byte = iota + 1 // 频道
+	slotSnapshotRecordUser                     // 用户
+	slotSnapshotRecordStream                   // 流
+	slotSnapshotRecordAuditLog                 // 审计日志
+	slotSnapshotRecordGlobals                  // 全局数据，只在0槽位上
+)
+
+// slotGlobalsSnapshot 全局数据的快照，只在0槽位上
+type slotGlobalsSnapshot struct {
+	SystemUids    []string            `json:"system_uids,omitempty"`
+	Testers       []wkdb.Tester       `json:"testers,omitempty"`
+	ApiKeys       []wkdb.ApiKey       `json:"api_keys,omitempty"`
+	RevokedTokens []wkdb.RevokedToken `json:"revoked_tokens,omitempty"`
+	IpRules       []wkdb.IpRule       `json:"ip_rules,omitempty"`
+}
+
+type slotChannelSnapshot struct {
+	ChannelId     string                     `json:"channel_id"`
+	ChannelType   uint8                      `json:"channel_type"`
+	Info          *wkdb.ChannelInfo          `json:"info,omitempty"` // 为空表示频道信息不存在（没有创建或者已删除）
+	Subscribers   []wkdb.Member              `json:"subscribers,omitempty"`
+	Denylist      []wkdb.Member              `json:"denylist,omitempty"`
+	Allowlist     []wkdb.Member              `json:"allowlist,omitempty"`
+	ClusterConfig *wkdb.ChannelClusterConfig `json:"cluster_config,omitempty"`
+}
+
+type slotUserSnapshot struct {
+	Uid           string              `json:"uid"`
+	User          *wkdb.User          `json:"user,omitempty"` // 为空表示用户信息不存在
+	Devices       []wkdb.Device       `json:"devices,omitempty"`
+	Conversations []wkdb.Conversation `json:"conversations,omitempty"`
+	LoginLogs     []wkdb.LoginLog     `json:"login_logs,omitempty"` // 按登录时间倒序
+}
+
+type slotStreamSnapshot struct {
+	StreamNo string           `json:"stream_no"`
+	Meta     *wkdb.StreamMeta `json:"meta,omitempty"`
+	Streams  []*wkdb.Stream   `json:"streams,omitempty"`
+}
+
+// IndexSlotLogs 记录日志涉及的槽数据对象，生成槽快照时按数据对象导出
+func (s *Store) IndexSlotLogs(slotId uint32, logs []types.Log) error {
+	entitySet := make(map[wkdb.SlotEntity]struct{})
+	entities := make([]wkdb.SlotEntity, 0)
+	for _, log := range logs {
+		cmd := &CMD{}
+		err := cmd.Unmarshal(log.Data)
+		if err != nil {
+			return err
+		}
+		cmdEntities, err := s.slotEntities(slotId, cmd)
+		if err != nil {
+			return err
+		}
+		for _, entity := range cmdEntities {
+			if _, ok := entitySet[entity]; ok {
+				continue
+			}
+			entitySet[entity] = struct{}{}
+			entities = append(entities, entity)
+		}
+	}
+	return s.wdb.AddSlotEntities(slotId, entities)
+}
+
+// slotEntities 获取命令涉及的槽数据对象
+// 全局数据和审计日志不需要记录，生成快照时直接从对应的表里导出
+func (s *Store) slotEntities(slotId uint32, cmd *CMD) ([]wkdb.SlotEntity, error) {
+	var entities []wkdb.SlotEntity
+	// 只记录属于本槽的频道和用户，批量命令里可能带有其他槽的用户
+	addChannel := func(channelId string, channelType uint8) {
+		if s.opts.Slot.GetSlotId(channelId) == slotId {
+			entities = append(entities, wkdb.NewSlotChannelEntity(channelId, channelType))
+		}
+	}
+	addUser := func(uid string) {
+		if s.opts.Slot.GetSlotId(uid) == slotId {
+			entities = append(entities, wkdb.NewSlotUserEntity(uid))
+		}
+	}
+
+	switch cmd.CmdType {
+	case CMDAddChannelInfo, CMDUpdateChannelInfo:
+		channelInfo, err := cmd.DecodeChannelInfo()
+		if err != nil {
+			return nil, err
+		}
+		addChannel(channelInfo.ChannelId, channelInfo.ChannelType)
+	case CMDAddSubscribers, CMDAddDenylist, CMDAddAllowlist:
+		channelId, channelType, _, err := cmd.DecodeMembers()
+		if err != nil {
+			return nil, err
+		}
+		addChannel(channelId, channelType)
+	case CMDRemoveSubscribers, CMDRemoveDenylist, CMDRemoveAllowlist:
+		channelId, channelType, _, err := cmd.DecodeChannelUids()
+		if err != nil {
+			return nil, err
+		}
+		addChannel(channelId, channelType)
+	case CMDRemoveAllSubscriber, CMDDeleteChannel, CMDRemoveAllDenylist, CMDRemoveAllAllowlist:
+		channelId, channelType, err := cmd.DecodeChannel()
+		if err != nil {
+			return nil, err
+		}
+		addChannel(channelId, channelType)
+	case CMDChannelClusterConfigSave:
+		channelId, channelType, _, err := cmd.DecodeCMDChannelClusterConfigSave()
+		if err != nil {
+			return nil, err
+		}
+		addChannel(channelId, channelType)
+	case CMDAddUser, CMDUpdateUser:
+		u, err := cmd.DecodeCMDUser()
+		if err != nil {
+			return nil, err
+		}
+		addUser(u.Uid)
+	case CMDAddDevice, CMDUpdateDevice:
+		d, err := cmd.DecodeCMDDevice()
+		if err != nil {
+			return nil, err
+		}
+		addUser(d.Uid)
+	case CMDUpdateUserOnlineStatus:
+		uid, _, _, _, err := cmd.DecodeCMDUpdateUserOnlineStatus()
+		if err != nil {
+			return nil, err
+		}
+		addUser(uid)
+	case CMDBatchUpdateUserOnlineStatus:
+		statuses, err := cmd.DecodeCMDBatchUpdateUserOnlineStatus()
+		if err != nil {
+			return nil, err
+		}
+		for _, status := range statuses {
+			addUser(status.Uid)
+		}
+	case CMDAddLoginLog:
+		log, _, err := cmd.DecodeCMDAddLoginLog()
+		if err != nil {
+			return nil, err
+		}
+		addUser(log.Uid)
+	case CMDBatchAddLoginLogs:
+		logs, _, err := cmd.DecodeCMDBatchAddLoginLogs()
+		if err != nil {
+			return nil, err
+		}
+		for _, log := range logs {
+			addUser(log.Uid)
+		}
+	case CMDAddOrUpdateUserConversations:
+		uid, _, err := cmd.DecodeCMDAddOrUpdateUserConversations()
+		if err != nil {
+			return nil, err
+		}
+		addUser(uid)
+	case CMDDeleteConversation:
+		uid, _, _, err := cmd.DecodeCMDDeleteConversation()
+		if err != nil {
+			return nil, err
+		}
+		addUser(uid)
+	case CMDDeleteConversations:
+		uid, _, err := cmd.DecodeCMDDeleteConversations()
+		if err != nil {
+			return nil, err
+		}
+		addUser(uid)
+	case CMDAddOrUpdateConversations:
+		conversations, err := cmd.DecodeCMDAddOrUpdateConversations()
+		if err != nil {
+			return nil, err
+		}
+		for _, conversation := range conversations {
+			addUser(conversation.Uid)
+		}
+	case CMDBatchUpdateConversation:
+		models, err := cmd.DecodeCMDBatchUpdateConversation()
+		if err != nil {
+			return nil, err
+		}
+		for _, model := range models {
+			for uid := range model.Uids {
+				addUser(uid)
+			}
+		}
+	// 流数据里没有频道信息，按提案的槽记录
+	case CMDAddStreamMeta:
+		streamMeta, err := cmd.DecodeCMDAddStreamMeta()
+		if err != nil {
+			return nil, err
+		}
+		entities = append(entities, wkdb.NewSlotStreamEntity(streamMeta.StreamNo))
+	case CMDAddStreams:
+		streams, err := cmd.DecodeCMDAddStreams()
+		if err != nil {
+			return nil, err
+		}
+		for _, stream := range streams {
+			entities = append(entities, wkdb.NewSlotStreamEntity(stream.StreamNo))
+		}
+	}
+	return entities, nil
+}
+
+// GetSlotSnapshot 导出槽状态机的快照数据，按数据对象逐条写入w
+// 调用方需要保证导出期间不会应用这个槽的日志
+func (s *Store) GetSlotSnapshot(slotId uint32, w io.Writer) error {
+	sw := newSlotSnapshotWriter(w)
+	entities, err := s.wdb.GetSlotEntities(slotId)
+	if err != nil {
+		return err
+	}
+	for _, entity := range entities {
+		switch entity.Type {
+		case wkdb.SlotEntityChannel:
+			channel, err := s.getChannelSnapshot(entity.Id, entity.ChannelType)
+			if err != nil {
+				return err
+			}
+			err = sw.write(slotSnapshotRecordChannel, channel)
+		case wkdb.SlotEntityUser:
+			user, err := s.getUserSnapshot(entity.Id)
+			if err != nil {
+				return err
+			}
+			err = sw.write(slotSnapshotRecordUser, user)
+		case wkdb.SlotEntityStream:
+			stream, err := s.getStreamSnapshot(entity.Id)
+			if err != nil {
+				return err
+			}
+			err = sw.write(slotSnapshotRecordStream, stream)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// 审计日志按日志id分布在各个槽上，通过槽索引只导出本槽的日志
+	var offsetId uint64
+	for {
+		auditLogs, err := s.wdb.GetAuditLogsBySlot(slotId, offsetId, slotSnapshotAuditLogBatch)
+		if err != nil {
+			return err
+		}
+		for _, log := range auditLogs {
+			if err = sw.write(slotSnapshotRecordAuditLog, log); err != nil {
+				return err
+			}
+		}
+		if len(auditLogs) < slotSnapshotAuditLogBatch {
+			break
+		}
+		offsetId = auditLogs[len(auditLogs)-1].Id
+	}
+
+	if slotId == 0 {
+		globals := &slotGlobalsSnapshot{}
+		if globals.SystemUids, err = s.wdb.GetSystemUids(); err != nil {
+			return err
+		}
+		if globals.Testers, err = s.wdb.GetTesters(); err != nil {
+			return err
+		}
+		if globals.ApiKeys, err = s.wdb.GetApiKeys(); err != nil {
+			return err
+		}
+		if globals.RevokedTokens, err = s.wdb.GetRevokedTokens(); err != nil {
+			return err
+		}
+		if globals.IpRules, err = s.wdb.GetIpRules(); err != nil {
+			return err
+		}
+		if err = sw.write(slotSnapshotRecordGlobals, globals); err != nil {
+			return err
+		}
+	}
+	return sw.flush()
+}
+
+func (s *Store) getChannelSnapshot(channelId string, channelType uint8) (slotChannelSnapshot, error) {
+	channel := slotChannelSnapshot{
+		ChannelId:   channelId,
+		ChannelType: channelType,
+	}
+	channelInfo, err := s.wdb.GetChannel(channelId, channelType)
+	if err != nil {
+		return channel, err
+	}
+	if !wkdb.IsEmptyChannelInfo(channelInfo) {
+		channel.Info = &channelInfo
+	}
+	if channel.Subscribers, err = s.wdb.GetSubscribers(channelId, channelType); err != nil {
+		return channel, err
+	}
+	if channel.Denylist, err = s.wdb.GetDenylist(channelId, channelType); err != nil {
+		return channel, err
+	}
+	if channel.Allowlist, err = s.wdb.GetAllowlist(channelId, channelType); err != nil {
+		return channel, err
+	}
+	cfg, err := s.wdb.GetChannelClusterConfig(channelId, channelType)
+	if err != nil && err != wkdb.ErrNotFound {
+		return channel, err
+	}
+	if err == nil {
+		channel.ClusterConfig = &cfg
+	}
+	return channel, nil
+}
+
+func (s *Store) getUserSnapshot(uid string) (slotUserSnapshot, error) {
+	user := slotUserSnapshot{
+		Uid: uid,
+	}
+	u, err := s.wdb.GetUser(uid)
+	if err != nil && err != wkdb.ErrNotFound {
+		return user, err
+	}
+	if !wkdb.IsEmptyUser(u) {
+		user.User = &u
+	}
+	if user.Devices, err = s.wdb.GetDevices(uid); err != nil {
+		return user, err
+	}
+	if user.Conversations, err = s.wdb.GetConversations(uid); err != nil {
+		return user, err
+	}
+	if user.LoginLogs, err = s.wdb.GetLoginLogs(uid, 0); err != nil {
+		return user, err
+	}
+	return user, nil
+}
+
+func (s *Store) getStreamSnapshot(streamNo string) (slotStreamSnapshot, error) {
+	stream := slotStreamSnapshot{
+		StreamNo: streamNo,
+	}
+	var err error
+	if stream.Meta, err = s.wdb.GetStreamMeta(streamNo); err != nil {
+		return stream, err
+	}
+	if stream.Streams, err = s.wdb.GetStreams(streamNo); err != nil {
+		return stream, err
+	}
+	return stream, nil
+}
+
+// ApplySlotSnapshot 用快照数据恢复槽状态机，从r逐条读取数据对象
+// 本地数据是快照之前某个时刻的状态，快照里没有的数据对象说明已经被删除
+func (s *Store) ApplySlotSnapshot(slotId uint32, r io.Reader) error {
+	localEntities, err := s.wdb.GetSlotEntities(slotId)
+	if err != nil {
+		return err
+	}
+
+	sr := bufio.NewReader(r)
+	var (
+		entities  = make([]wkdb.SlotEntity, 0)
+		entitySet = make(map[wkdb.SlotEntity]struct{})
+		globals   = &slotGlobalsSnapshot{}
+	)
+	for {
+		recordType, data, err := readSlotSnapshotRecord(sr)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		var entity wkdb.SlotEntity
+		switch recordType {
+		case slotSnapshotRecordChannel:
+			var channel slotChannelSnapshot
+			if err = json.Unmarshal(data, &channel); err != nil {
+				return err
+			}
+			if err = s.restoreChannel(channel); err != nil {
+				return err
+			}
+			entity = wkdb.NewSlotChannelEntity(channel.ChannelId, channel.ChannelType)
+		case slotSnapshotRecordUser:
+			var user slotUserSnapshot
+			if err = json.Unmarshal(data, &user); err != nil {
+				return err
+			}
+			if err = s.restoreUser(user); err != nil {
+				return err
+			}
+			entity = wkdb.NewSlotUserEntity(user.Uid)
+		case slotSnapshotRecordStream:
+			var stream slotStreamSnapshot
+			if err = json.Unmarshal(data, &stream); err != nil {
+				return err
+			}
+			if err = s.restoreStream(stream); err != nil {
+				return err
+			}
+			entity = wkdb.NewSlotStreamEntity(stream.StreamNo)
+		case slotSnapshotRecordAuditLog:
+			var log wkdb.AuditLog
+			if err = json.Unmarshal(data, &log); err != nil {
+				return err
+			}
+			if err = s.wdb.AppendAuditLog(slotId, log); err != nil {
+				return err
+			}
+			continue
+		case slotSnapshotRecordGlobals:
+			if err = json.Unmarshal(data, globals); err != nil {
+				return err
+			}
+			continue
+		default:
+			return fmt.Errorf("unknown slot snapshot record type[%d]", recordType)
+		}
+		entitySet[entity] = struct{}{}
+		entities = append(entities, entity)
+	}
+
+	// 清除快照里没有的数据对象（用户和流不会被删除，只需要清除最近会话）
+	for _, entity := range localEntities {
+		if _, ok := entitySet[entity]; ok {
+			continue
+		}
+		switch entity.Type {
+		case wkdb.SlotEntityChannel:
+			err = s.restoreChannel(slotChannelSnapshot{ChannelId: entity.Id, ChannelType: entity.ChannelType})
+		case wkdb.SlotEntityUser:
+			err = s.restoreConversations(entity.Id, nil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if slotId == 0 {
+		if err = s.restoreGlobals(globals); err != nil {
+			return err
+		}
+	}
+
+	if err = s.wdb.RemoveSlotEntities(slotId); err != nil {
+		return err
+	}
+	return s.wdb.AddSlotEntities(slotId, entities)
+}
+
+func (s *Store) restoreChannel(channel slotChannelSnapshot) error {
+	channelId, channelType := channel.ChannelId, channel.ChannelType
+
+	if err := s.wdb.RemoveAllSubscriber(channelId, channelType); err != nil {
+		return err
+	}
+	if err := s.wdb.RemoveAllDenylist(channelId, channelType); err != nil {
+		return err
+	}
+	if err := s.wdb.RemoveAllAllowlist(channelId, channelType); err != nil {
+		return err
+	}
+	if len(channel.Subscribers) > 0 {
+		if err := s.wdb.AddSubscribers(channelId, channelType, channel.Subscribers); err != nil {
+			return err
+		}
+	}
+	if len(channel.Denylist) > 0 {
+		if err := s.wdb.AddDenylist(channelId, channelType, channel.Denylist); err != nil {
+			return err
+		}
+	}
+	if len(channel.Allowlist) > 0 {
+		if err := s.wdb.AddAllowlist(channelId, channelType, channel.Allowlist); err != nil {
+			return err
+		}
+	}
+
+	// 频道信息最后写，订阅者等数量以快照为准
+	exist, err := s.wdb.ExistChannel(channelId, channelType)
+	if err != nil {
+		return err
+	}
+	if channel.Info == nil {
+		if exist {
+			if err = s.wdb.DeleteChannel(channelId, channelType); err != nil {
+				return err
+			}
+		}
+	} else if exist {
+		if err = s.wdb.UpdateChannel(*channel.Info); err != nil {
+			return err
+		}
+	} else {
+		if _, err = s.wdb.AddChannel(*channel.Info); err != nil {
+			return err
+		}
+	}
+
+	if channel.ClusterConfig != nil {
+		if err = s.wdb.SaveChannelClusterConfig(*channel.ClusterConfig); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) restoreUser(user slotUserSnapshot) error {
+	if user.User != nil {
+		if err := s.wdb.AddUser(*user.User); err != nil {
+			return err
+		}
+	}
+	for _, device := range user.Devices {
+		old, err := s.wdb.GetDevice(user.Uid, device.DeviceFlag)
+		if err != nil && err != wkdb.ErrNotFound {
+			return err
+		}
+		if wkdb.IsEmptyDevice(old) {
+			err = s.wdb.AddDevice(device)
+		} else {
+			err = s.wdb.UpdateDevice(device)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := s.restoreConversations(user.Uid, user.Conversations); err != nil {
+		return err
+	}
+	// 从旧到新添加，最多保留快照里的数量，本地多出来的旧日志会被删除
+	for i := len(user.LoginLogs) - 1; i >= 0; i-- {
+		if err := s.wdb.AddLoginLog(user.LoginLogs[i], len(user.LoginLogs)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreConversations 把用户的最近会话恢复成快照里的会话
+func (s *Store) restoreConversations(uid string, conversations []wkdb.Conversation) error {
+	localConversations, err := s.wdb.GetConversations(uid)
+	if err != nil {
+		return err
+	}
+	keep := make(map[wkdb.Channel]struct{}, len(conversations))
+	for _, conversation := range conversations {
+		keep[wkdb.Channel{ChannelId: conversation.ChannelId, ChannelType: conversation.ChannelType}] = struct{}{}
+	}
+	var deleteChannels []wkdb.Channel
+	for _, conversation := range localConversations {
+		channel := wkdb.Channel{ChannelId: conversation.ChannelId, ChannelType: conversation.ChannelType}
+		if _, ok := keep[channel]; !ok {
+			deleteChannels = append(deleteChannels, channel)
+		}
+	}
+	if len(deleteChannels) > 0 {
+		if err = s.wdb.DeleteConversations(uid, deleteChannels); err != nil {
+			return err
+		}
+	}
+	if len(conversations) > 0 {
+		return s.wdb.AddOrUpdateConversationsWithUser(uid, conversations)
+	}
+	return nil
+}
+
+func (s *Store) restoreStream(stream slotStreamSnapshot) error {
+	if stream.Meta != nil {
+		if err := s.wdb.AddStreamMeta(stream.Meta); err != nil {
+			return err
+		}
+	}
+	if len(stream.Streams) > 0 {
+		return s.wdb.AddStreams(stream.Streams)
+	}
+	return nil
+}
+
+// restoreGlobals 把全局数据恢复成快照里的数据
+// 吊销的token只会过期删除，直接添加即可
+func (s *Store) restoreGlobals(snapshot *slotGlobalsSnapshot) error {
+	localUids, err := s.wdb.GetSystemUids()
+	if err != nil {
+		return err
+	}
+	uidSet := make(map[string]struct{}, len(snapshot.SystemUids))
+	for _, uid := range snapshot.SystemUids {
+		uidSet[uid] = struct{}{}
+	}
+	var removeUids []string
+	for _, uid := range localUids {
+		if _, ok := uidSet[uid]; !ok {
+			removeUids = append(removeUids, uid)
+		}
+	}
+	if len(removeUids) > 0 {
+		if err = s.wdb.RemoveSystemUids(removeUids); err != nil {
+			return err
+		}
+	}
+	if len(snapshot.SystemUids) > 0 {
+		if err = s.wdb.AddSystemUids(snapshot.SystemUids); err != nil {
+			return err
+		}
+	}
+
+	localTesters, err := s.wdb.GetTesters()
+	if err != nil {
+		return err
+	}
+	testerSet := make(map[string]struct{}, len(snapshot.Testers))
+	for _, tester := range snapshot.Testers {
+		testerSet[tester.No] = struct{}{}
+	}
+	for _, tester := range localTesters {
+		if _, ok := testerSet[tester.No]; !ok {
+			if err = s.wdb.RemoveTester(tester.No); err != nil {
+				return err
+			}
+		}
+	}
+	for _, tester := range snapshot.Testers {
+		if err = s.wdb.AddOrUpdateTester(tester); err != nil {
+			return err
+		}
+	}
+
+	localApiKeys, err := s.wdb.GetApiKeys()
+	if err != nil {
+		return err
+	}
+	apiKeySet := make(map[uint64]struct{}, len(snapshot.ApiKeys))
+	for _, apiKey := range snapshot.ApiKeys {
+		apiKeySet[apiKey.Id] = struct{}{}
+	}
+	for _, apiKey := range localApiKeys {
+		if _, ok := apiKeySet[apiKey.Id]; !ok {
+			if err = s.wdb.RemoveApiKey(apiKey.Id); err != nil {
+				return err
+			}
+		}
+	}
+	for _, apiKey := range snapshot.ApiKeys {
+		if err = s.wdb.AddOrUpdateApiKey(apiKey); err != nil {
+			return err
+		}
+	}
+
+	localIpRules, err := s.wdb.GetIpRules()
+	if err != nil {
+		return err
+	}
+	ipRuleSet := make(map[uint64]struct{}, len(snapshot.IpRules))
+	for _, rule := range snapshot.IpRules {
+		ipRuleSet[rule.Id] = struct{}{}
+	}
+	for _, rule := range localIpRules {
+		if _, ok := ipRuleSet[rule.Id]; !ok {
+			if err = s.wdb.RemoveIpRule(rule.Id); err != nil {
+				return err
+			}
+		}
+	}
+	for _, rule := range snapshot.IpRules {
+		if err = s.wdb.AddOrUpdateIpRule(rule); err != nil {
+			return err
+		}
+	}
+
+	for _, token := range snapshot.RevokedTokens {
+		if err = s.wdb.AddRevokedToken(token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type slotSnapshotWriter struct {
+	w   *bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+}
+
+func newSlotSnapshotWriter(w io.Writer) *slotSnapshotWriter {
+	return &slotSnapshotWriter{
+		w: bufio.NewWriter(w),
+	}
+}
+
+// write 写入一条快照记录
+func (sw *slotSnapshotWriter) write(recordType byte, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err = sw.w.WriteByte(recordType); err != nil {
+		return err
+	}
+	n := binary.PutUvarint(sw.buf[:], uint64(len(data)))
+	if _, err = sw.w.Write(sw.buf[:n]); err != nil {
+		return err
+	}
+	_, err = sw.w.Write(data)
+	return err
+}
+
+func (sw *slotSnapshotWriter) flush() error {
+	return sw.w.Flush()
+}
+
+// readSlotSnapshotRecord 读取一条快照记录，读完所有记录后返回io.EOF
+func readSlotSnapshotRecord(r *bufio.Reader) (byte, []byte, error) {
+	recordType, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, nil, unexpectedEOF(err)
+	}
+	data := make([]byte, size)
+	if _, err = io.ReadFull(r, data); err != nil {
+		return 0, nil, unexpectedEOF(err)
+	}
+	return recordType, data, nil
+}
+
+// 记录读到一半就结束了，说明快照数据不完整
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
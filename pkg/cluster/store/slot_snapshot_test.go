@@ -0,0 +1,226 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/raft/types"
+	"github.com/WuKongIM/WuKongIM/pkg/trace"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlotSnapshot(t *testing.T) {
+	leader := newTestStore(t)
+	follower := newTestStore(t)
+
+	tm := time.Unix(1700000000, 0)
+	prefix := [][]byte{
+		channelInfoCmd(t, wkdb.ChannelInfo{ChannelId: "g2", ChannelType: 2}),
+		NewCMD(CMDAddSubscribers, EncodeMembers("g2", 2, []wkdb.Member{{Uid: "u1"}})).mustMarshal(t),
+		conversationsCmd(t, "u1", []wkdb.Conversation{{Uid: "u1", ChannelId: "old", ChannelType: 2, CreatedAt: &tm, UpdatedAt: &tm}}),
+		NewCMD(CMDSystemUIDsAdd, EncodeCMDSystemUIDs([]string{"sys_old"})).mustMarshal(t),
+		NewCMD(CMDAddOrUpdateApiKey, EncodeCMDApiKey(wkdb.ApiKey{Id: 9, Name: "old"})).mustMarshal(t),
+	}
+	cfg := wkdb.ChannelClusterConfig{ChannelId: "g1", ChannelType: 2, Replicas: []uint64{1, 2, 3}, LeaderId: 1, Term: 1}
+	cfgData, err := cfg.Marshal()
+	assert.NoError(t, err)
+	cfgCmd, err := EncodeCMDChannelClusterConfigSave("g1", 2, cfgData)
+	assert.NoError(t, err)
+	rest := [][]byte{
+		// 删除的数据快照里没有，恢复快照时要从副本上删除
+		NewCMD(CMDRemoveAllSubscriber, EncodeChannel("g2", 2)).mustMarshal(t),
+		NewCMD(CMDDeleteChannel, EncodeChannel("g2", 2)).mustMarshal(t),
+		NewCMD(CMDDeleteConversation, EncodeCMDDeleteConversation("u1", "old", 2)).mustMarshal(t),
+		NewCMD(CMDSystemUIDsRemove, EncodeCMDSystemUIDs([]string{"sys_old"})).mustMarshal(t),
+		NewCMD(CMDRemoveApiKey, EncodeCMDRemoveApiKey(9)).mustMarshal(t),
+
+		channelInfoCmd(t, wkdb.ChannelInfo{ChannelId: "g1", ChannelType: 2, Ban: true, CreatedAt: &tm, UpdatedAt: &tm}),
+		NewCMD(CMDAddSubscribers, EncodeMembers("g1", 2, []wkdb.Member{{Uid: "u1"}, {Uid: "u2"}})).mustMarshal(t),
+		NewCMD(CMDAddDenylist, EncodeMembers("g1", 2, []wkdb.Member{{Uid: "u3"}})).mustMarshal(t),
+		NewCMD(CMDChannelClusterConfigSave, cfgCmd).mustMarshal(t),
+		NewCMD(CMDAddUser, EncodeCMDUser(wkdb.User{Uid: "u1", CreatedAt: &tm, UpdatedAt: &tm})).mustMarshal(t),
+		NewCMD(CMDAddDevice, EncodeCMDDevice(wkdb.Device{Id: 1, Uid: "u1", Token: "token", DeviceFlag: 1, CreatedAt: &tm, UpdatedAt: &tm})).mustMarshal(t),
+		conversationsCmd(t, "u1", []wkdb.Conversation{{Uid: "u1", ChannelId: "g1", ChannelType: 2, ReadToMsgSeq: 10, CreatedAt: &tm, UpdatedAt: &tm}}),
+		NewCMD(CMDAddLoginLog, EncodeCMDAddLoginLog(wkdb.LoginLog{Uid: "u1", Ip: "127.0.0.1", CreatedAt: &tm}, 10)).mustMarshal(t),
+		NewCMD(CMDBatchAddLoginLogs, EncodeCMDBatchAddLoginLogs([]wkdb.LoginLog{{Uid: "u1", Ip: "127.0.0.2", CreatedAt: &tm}, {Uid: "u2", Ip: "127.0.0.3", CreatedAt: &tm}}, 10)).mustMarshal(t),
+		NewCMD(CMDAddStreamMeta, EncodeCMDAddStreamMeta(&wkdb.StreamMeta{StreamNo: "s1", ChannelId: "g1", ChannelType: 2})).mustMarshal(t),
+		NewCMD(CMDAddStreams, EncodeCMDAddStreams([]*wkdb.Stream{{StreamNo: "s1", StreamId: 1, Payload: []byte("hello")}})).mustMarshal(t),
+		NewCMD(CMDSystemUIDsAdd, EncodeCMDSystemUIDs([]string{"sys"})).mustMarshal(t),
+		NewCMD(CMDAddOrUpdateIpRule, EncodeCMDIpRule(wkdb.IpRule{Id: 1, Cidr: "10.0.0.0/8", Action: wkdb.IpRuleDeny})).mustMarshal(t),
+		NewCMD(CMDAppendAuditLogs, EncodeCMDAppendAuditLogs([]wkdb.AuditLog{{Id: 1, Actor: "admin", CreatedAt: &tm}})).mustMarshal(t),
+	}
+
+	// 副本只应用了前面一部分日志
+	applyTestLogs(t, leader, append(prefix, rest...))
+	applyTestLogs(t, follower, prefix)
+
+	data := &bytes.Buffer{}
+	err = leader.GetSlotSnapshot(0, data)
+	assert.NoError(t, err)
+	records := decodeTestSnapshot(t, data.Bytes())
+	err = follower.ApplySlotSnapshot(0, data)
+	assert.NoError(t, err)
+
+	// 副本恢复快照后导出的快照和领导一致
+	followerData := &bytes.Buffer{}
+	err = follower.GetSlotSnapshot(0, followerData)
+	assert.NoError(t, err)
+	assert.Equal(t, records, decodeTestSnapshot(t, followerData.Bytes()))
+
+	channelInfo, err := follower.wdb.GetChannel("g2", 2)
+	assert.NoError(t, err)
+	assert.True(t, wkdb.IsEmptyChannelInfo(channelInfo))
+	subscribers, err := follower.wdb.GetSubscribers("g2", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(subscribers))
+
+	channelInfo, err = follower.wdb.GetChannel("g1", 2)
+	assert.NoError(t, err)
+	assert.True(t, channelInfo.Ban)
+	subscribers, err = follower.wdb.GetSubscribers("g1", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(subscribers))
+	followerCfg, err := follower.wdb.GetChannelClusterConfig("g1", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{1, 2, 3}, followerCfg.Replicas)
+
+	conversations, err := follower.wdb.GetConversations("u1")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(conversations))
+	assert.Equal(t, "g1", conversations[0].ChannelId)
+
+	uids, err := follower.wdb.GetSystemUids()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"sys"}, uids)
+	apiKeys, err := follower.wdb.GetApiKeys()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(apiKeys))
+
+	streams, err := follower.wdb.GetStreams("s1")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(streams))
+	assert.Equal(t, []byte("hello"), streams[0].Payload)
+}
+
+func TestSlotEntitiesOnlyOwnSlot(t *testing.T) {
+	s := newTestStore(t)
+	s.opts.Slot = &testSlot{slotIds: map[string]uint32{"u2": 1}}
+
+	// 批量命令里其他槽的用户不记录到本槽
+	data, err := EncodeCMDAddOrUpdateConversations(wkdb.ConversationSet{
+		{Uid: "u1", ChannelId: "g1", ChannelType: 2},
+		{Uid: "u2", ChannelId: "g1", ChannelType: 2},
+	})
+	assert.NoError(t, err)
+	applyTestLogs(t, s, [][]byte{NewCMD(CMDAddOrUpdateConversations, data).mustMarshal(t)})
+
+	entities, err := s.wdb.GetSlotEntities(0)
+	assert.NoError(t, err)
+	assert.Equal(t, []wkdb.SlotEntity{wkdb.NewSlotUserEntity("u1")}, entities)
+}
+
+type testSlot struct {
+	slotIds map[string]uint32
+}
+
+func (t *testSlot) SlotLeaderId(slotId uint32) uint64 {
+	return 1
+}
+
+func (t *testSlot) GetSlotId(v string) uint32 {
+	return t.slotIds[v]
+}
+
+func (t *testSlot) ProposeUntilApplied(slotId uint32, data []byte) (*types.ProposeResp, error) {
+	return nil, nil
+}
+
+func (t *testSlot) ProposeUntilAppliedTimeout(ctx context.Context, slotId uint32, data []byte) (*types.ProposeResp, error) {
+	return nil, nil
+}
+
+func newTestStore(t *testing.T) *Store {
+	trace.SetGlobalTrace(trace.New(context.Background(), trace.NewOptions(trace.WithServiceName("test"), trace.WithServiceHostName("host"))))
+
+	db := wkdb.NewWukongDB(wkdb.NewOptions(wkdb.WithDir(t.TempDir()), wkdb.WithShardNum(1)))
+	err := db.Open()
+	assert.NoError(t, err)
+	s := New(NewOptions(WithDB(db), WithSlot(&testSlot{}), WithIsCmdChannel(func(string) bool { return false })))
+	err = s.Start()
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		s.Stop()
+		_ = db.Close()
+	})
+	return s
+}
+
+func applyTestLogs(t *testing.T, s *Store, cmds [][]byte) {
+	logs := make([]types.Log, 0, len(cmds))
+	for i, data := range cmds {
+		logs = append(logs, types.Log{Index: uint64(i + 1), Term: 1, Data: data})
+	}
+	err := s.ApplySlotLogs(0, logs)
+	assert.NoError(t, err)
+}
+
+func channelInfoCmd(t *testing.T, channelInfo wkdb.ChannelInfo) []byte {
+	data, err := EncodeChannelInfo(channelInfo, CmdVersionChannelInfo)
+	assert.NoError(t, err)
+	return NewCMDWithVersion(CMDAddChannelInfo, data, CmdVersionChannelInfo).mustMarshal(t)
+}
+
+func conversationsCmd(t *testing.T, uid string, conversations []wkdb.Conversation) []byte {
+	data, err := EncodeCMDAddOrUpdateUserConversations(uid, conversations)
+	assert.NoError(t, err)
+	return NewCMD(CMDAddOrUpdateUserConversations, data).mustMarshal(t)
+}
+
+func (c *CMD) mustMarshal(t *testing.T) []byte {
+	data, err := c.Marshal()
+	assert.NoError(t, err)
+	return data
+}
+
+type testSnapshotRecord struct {
+	Type byte
+	Data string
+}
+
+func decodeTestSnapshot(t *testing.T, data []byte) []testSnapshotRecord {
+	r := bufio.NewReader(bytes.NewReader(data))
+	var records []testSnapshotRecord
+	for {
+		recordType, recordData, err := readSlotSnapshotRecord(r)
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		if err != nil {
+			break
+		}
+		records = append(records, testSnapshotRecord{Type: recordType, Data: string(recordData)})
+	}
+	return records
+}
+
+func TestSlotSnapshotTruncated(t *testing.T) {
+	leader := newTestStore(t)
+	follower := newTestStore(t)
+	applyTestLogs(t, leader, [][]byte{
+		channelInfoCmd(t, wkdb.ChannelInfo{ChannelId: "g1", ChannelType: 2}),
+	})
+
+	data := &bytes.Buffer{}
+	err := leader.GetSlotSnapshot(0, data)
+	assert.NoError(t, err)
+
+	// 快照数据不完整时返回错误
+	err = follower.ApplySlotSnapshot(0, bytes.NewReader(data.Bytes()[:data.Len()-1]))
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
@@ -17,10 +17,16 @@ func (s *Store) ApplySlotLogs(slotId uint32, logs []types.Log) error {
 			return err
 		}
 	}
+	// 记录日志涉及的数据对象，生成槽快照时使用
+	err := s.IndexSlotLogs(slotId, logs)
+	if err != nil {
+		s.Error("index slot logs err", zap.Error(err), zap.Uint32("slotId", slotId))
+		return err
+	}
 	return nil
 }
 
-func (s *Store) applyLog(_ uint32, log types.Log) error {
+func (s *Store) applyLog(slotId uint32, log types.Log) error {
 	cmd := &CMD{}
 	err := cmd.Unmarshal(log.Data)
 	if err != nil {
@@ -89,6 +95,26 @@ func (s *Store) applyLog(_ uint32, log types.Log) error {
 		return s.handleAddOrUpdateTester(cmd)
 	case CMDRemoveTester: // 移除测试机
 		return s.handleRemoveTester(cmd)
+	case CMDUpdateUserOnlineStatus: // 更新用户在线状态
+		return s.handleUpdateUserOnlineStatus(cmd)
+	case CMDAddLoginLog: // 添加登录日志
+		return s.handleAddLoginLog(cmd)
+	case CMDAddOrUpdateApiKey: // 添加或更新api key
+		return s.handleAddOrUpdateApiKey(cmd)
+	case CMDRemoveApiKey: // 移除api key
+		return s.handleRemoveApiKey(cmd)
+	case CMDBatchUpdateUserOnlineStatus: // 批量更新用户在线状态
+		return s.handleBatchUpdateUserOnlineStatus(cmd)
+	case CMDAppendAuditLogs: // 批量追加审计日志
+		return s.handleAppendAuditLogs(slotId, cmd)
+	case CMDBatchAddLoginLogs: // 批量添加登录日志
+		return s.handleBatchAddLoginLogs(cmd)
+	case CMDAddRevokedToken: // 添加吊销的管理后台token
+		return s.handleAddRevokedToken(cmd)
+	case CMDAddOrUpdateIpRule: // 添加或更新连接的ip规则
+		return s.handleAddOrUpdateIpRule(cmd)
+	case CMDRemoveIpRule: // 移除连接的ip规则
+		return s.handleRemoveIpRule(cmd)
 
 	}
 	return nil
@@ -204,6 +230,93 @@ func (s *Store) handleUpdateDevice(cmd *CMD) error {
 	return s.wdb.UpdateDevice(u)
 }
 
+func (s *Store) handleUpdateUserOnlineStatus(cmd *CMD) error {
+	uid, deviceFlag, online, at, err := cmd.DecodeCMDUpdateUserOnlineStatus()
+	if err != nil {
+		return err
+	}
+	return s.wdb.UpdateUserOnlineStatus(uid, deviceFlag, online, at)
+}
+
+func (s *Store) handleBatchUpdateUserOnlineStatus(cmd *CMD) error {
+	statuses, err := cmd.DecodeCMDBatchUpdateUserOnlineStatus()
+	if err != nil {
+		return err
+	}
+	for _, status := range statuses {
+		if err = s.wdb.UpdateUserOnlineStatus(status.Uid, status.DeviceFlag, status.Online, status.At); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) handleAppendAuditLogs(slotId uint32, cmd *CMD) error {
+	logs, err := cmd.DecodeCMDAppendAuditLogs()
+	if err != nil {
+		return err
+	}
+	for _, log := range logs {
+		if err = s.wdb.AppendAuditLog(slotId, log); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) handleAddLoginLog(cmd *CMD) error {
+	log, maxCount, err := cmd.DecodeCMDAddLoginLog()
+	if err != nil {
+		return err
+	}
+	return s.wdb.AddLoginLog(log, maxCount)
+}
+
+func (s *Store) handleBatchAddLoginLogs(cmd *CMD) error {
+	logs, maxCount, err := cmd.DecodeCMDBatchAddLoginLogs()
+	if err != nil {
+		return err
+	}
+	for _, log := range logs {
+		if err = s.wdb.AddLoginLog(log, maxCount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) handleAddOrUpdateApiKey(cmd *CMD) error {
+	apiKey, err := cmd.DecodeCMDApiKey()
+	if err != nil {
+		return err
+	}
+	return s.wdb.AddOrUpdateApiKey(apiKey)
+}
+
+func (s *Store) handleRemoveApiKey(cmd *CMD) error {
+	id, err := cmd.DecodeCMDRemoveApiKey()
+	if err != nil {
+		return err
+	}
+	return s.wdb.RemoveApiKey(id)
+}
+
+func (s *Store) handleAddOrUpdateIpRule(cmd *CMD) error {
+	rule, err := cmd.DecodeCMDIpRule()
+	if err != nil {
+		return err
+	}
+	return s.wdb.AddOrUpdateIpRule(rule)
+}
+
+func (s *Store) handleRemoveIpRule(cmd *CMD) error {
+	id, err := cmd.DecodeCMDRemoveIpRule()
+	if err != nil {
+		return err
+	}
+	return s.wdb.RemoveIpRule(id)
+}
+
 func (s *Store) handleAddChannelInfo(cmd *CMD) error {
 	channelInfo, err := cmd.DecodeChannelInfo()
 	if err != nil {
@@ -419,3 +532,11 @@ func (s *Store) handleRemoveTester(cmd *CMD) error {
 	}
 	return s.wdb.RemoveTester(no)
 }
+
+func (s *Store) handleAddRevokedToken(cmd *CMD) error {
+	token, err := cmd.DecodeCMDRevokedToken()
+	if err != nil {
+		return err
+	}
+	return s.wdb.AddRevokedToken(token)
+}
@@ -1,9 +1,13 @@
 package store
 
 import (
+	"context"
+	"time"
+
 	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
 	wkproto "github.com/WuKongIM/WuKongIMGoProto"
 	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
 )
 
 func (s *Store) AddUser(u wkdb.User) error {
@@ -66,6 +70,78 @@ func (s *Store) AddDevice(d wkdb.Device) error {
 	return err
 }
 
+// BatchUpdateUserOnlineStatus 批量更新用户和设备的最后上线/离线时间（按slot分组，每个slot提交一次）
+func (s *Store) BatchUpdateUserOnlineStatus(statuses []UserOnlineStatus) error {
+	slotStatusesMap := make(map[uint32][]UserOnlineStatus)
+	for _, status := range statuses {
+		slotId := s.opts.Slot.GetSlotId(status.Uid)
+		slotStatusesMap[slotId] = append(slotStatusesMap[slotId], status)
+	}
+
+	timeoutctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	g, _ := errgroup.WithContext(timeoutctx)
+	g.SetLimit(100)
+	for slotId, statuses := range slotStatusesMap {
+		slotId, statuses := slotId, statuses
+		g.Go(func() error {
+			cmd := NewCMD(CMDBatchUpdateUserOnlineStatus, EncodeCMDBatchUpdateUserOnlineStatus(statuses))
+			cmdData, err := cmd.Marshal()
+			if err != nil {
+				return err
+			}
+			_, err = s.opts.Slot.ProposeUntilAppliedTimeout(timeoutctx, slotId, cmdData)
+			if err != nil {
+				s.Error("ProposeUntilAppliedTimeout failed", zap.Error(err), zap.Uint32("slotId", slotId), zap.Int("statuses", len(statuses)))
+				return err
+			}
+			return nil
+		})
+	}
+	return g.Wait()
+}
+
+// BatchAddLoginLogs 批量添加用户登录日志（按slot分组，每个slot提交一次） maxCount为每个用户最多保留的日志数量
+func (s *Store) BatchAddLoginLogs(logs []wkdb.LoginLog, maxCount int) error {
+	slotLogsMap := make(map[uint32][]wkdb.LoginLog)
+	for _, log := range logs {
+		slotId := s.opts.Slot.GetSlotId(log.Uid)
+		slotLogsMap[slotId] = append(slotLogsMap[slotId], log)
+	}
+
+	timeoutctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	g, _ := errgroup.WithContext(timeoutctx)
+	g.SetLimit(100)
+	for slotId, logs := range slotLogsMap {
+		slotId, logs := slotId, logs
+		g.Go(func() error {
+			cmd := NewCMD(CMDBatchAddLoginLogs, EncodeCMDBatchAddLoginLogs(logs, maxCount))
+			cmdData, err := cmd.Marshal()
+			if err != nil {
+				return err
+			}
+			_, err = s.opts.Slot.ProposeUntilAppliedTimeout(timeoutctx, slotId, cmdData)
+			if err != nil {
+				s.Error("ProposeUntilAppliedTimeout failed", zap.Error(err), zap.Uint32("slotId", slotId), zap.Int("logs", len(logs)))
+				return err
+			}
+			return nil
+		})
+	}
+	return g.Wait()
+}
+
+func (s *Store) GetLoginLogs(uid string, limit int) ([]wkdb.LoginLog, error) {
+	return s.wdb.GetLoginLogs(uid, limit)
+}
+
+func (s *Store) GetDevices(uid string) ([]wkdb.Device, error) {
+	return s.wdb.GetDevices(uid)
+}
+
 func (s *Store) GetDevice(uid string, deviceFlag wkproto.DeviceFlag) (wkdb.Device, error) {
 	return s.wdb.GetDevice(uid, uint64(deviceFlag))
 }
@@ -4,7 +4,8 @@ type CmdVersion uint16
 
 const (
 	// CmdVersionChannelInfo is the version of the command that contains channel info
-	CmdVersionChannelInfo CmdVersion = 2
+	// version 3: 增加频道的发送速率限制和慢速模式
+	CmdVersionChannelInfo CmdVersion = 3
 )
 
 func (c CmdVersion) Uint16() uint16 {
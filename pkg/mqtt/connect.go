@@ -1,4 +1,169 @@
 package mqtt
 
+import (
+	"fmt"
+	"io"
+)
+
+// ConnectPacket 连接包
 type ConnectPacket struct {
+	FixedHeader
+	ProtocolName    string     // 协议名 MQTT（3.1为MQIsdp）
+	ProtocolVersion byte       // 协议版本
+	CleanSession    bool       // 清除会话（MQTT 5为Clean Start）
+	KeepAlive       uint16     // 保活时间（秒）
+	Properties      Properties // 属性（MQTT 5）
+	ClientId        string     // 客户端标识
+
+	WillFlag       bool       // 是否有遗嘱消息
+	WillQoS        byte       // 遗嘱消息的QoS
+	WillRetain     bool       // 遗嘱消息是否保留
+	WillProperties Properties // 遗嘱属性（MQTT 5）
+	WillTopic      string     // 遗嘱主题
+	WillPayload    []byte     // 遗嘱消息
+
+	UsernameFlag bool   // 是否有用户名
+	Username     string // 用户名
+	PasswordFlag bool   // 是否有密码
+	Password     []byte // 密码
+}
+
+func (c *ConnectPacket) Encode(w io.Writer) error {
+	c.Type = CONNECT
+	c.Flags = 0
+	var flags byte
+	if c.CleanSession {
+		flags |= 0x02
+	}
+	if c.WillFlag {
+		flags |= 0x04 | (c.WillQoS&0x03)<<3
+		if c.WillRetain {
+			flags |= 0x20
+		}
+	}
+	if c.PasswordFlag {
+		flags |= 0x40
+	}
+	if c.UsernameFlag {
+		flags |= 0x80
+	}
+	body := appendString(nil, c.ProtocolName)
+	body = append(body, c.ProtocolVersion, flags)
+	body = appendUint16(body, c.KeepAlive)
+	body = appendProperties(body, c.Properties, c.ProtocolVersion)
+	body = appendString(body, c.ClientId)
+	if c.WillFlag {
+		body = appendProperties(body, c.WillProperties, c.ProtocolVersion)
+		body = appendString(body, c.WillTopic)
+		body = appendBinary(body, c.WillPayload)
+	}
+	if c.UsernameFlag {
+		body = appendString(body, c.Username)
+	}
+	if c.PasswordFlag {
+		body = appendBinary(body, c.Password)
+	}
+	return c.write(w, body)
+}
+
+func (c *ConnectPacket) Decode(r io.Reader, remainingLen uint32) error {
+	d, err := newDecoder(r, remainingLen)
+	if err != nil {
+		return err
+	}
+	if c.ProtocolName, err = d.string(); err != nil {
+		return err
+	}
+	if c.ProtocolVersion, err = d.byte(); err != nil {
+		return err
+	}
+	switch c.ProtocolVersion {
+	case Version31, Version311, Version5:
+	default:
+		return fmt.Errorf("%w: %d", ErrUnsupportedVersion, c.ProtocolVersion)
+	}
+	c.Version = c.ProtocolVersion
+	flags, err := d.byte()
+	if err != nil {
+		return err
+	}
+	if flags&0x01 != 0 { // 保留位必须为0
+		return ErrProtocolViolation
+	}
+	c.CleanSession = flags&0x02 != 0
+	c.WillFlag = flags&0x04 != 0
+	c.WillQoS = (flags >> 3) & 0x03
+	c.WillRetain = flags&0x20 != 0
+	c.PasswordFlag = flags&0x40 != 0
+	c.UsernameFlag = flags&0x80 != 0
+	if c.KeepAlive, err = d.uint16(); err != nil {
+		return err
+	}
+	if c.Properties, err = d.properties(c.ProtocolVersion); err != nil {
+		return err
+	}
+	if c.ClientId, err = d.string(); err != nil {
+		return err
+	}
+	if c.WillFlag {
+		if c.WillProperties, err = d.properties(c.ProtocolVersion); err != nil {
+			return err
+		}
+		if c.WillTopic, err = d.string(); err != nil {
+			return err
+		}
+		if c.WillPayload, err = d.binary(); err != nil {
+			return err
+		}
+	}
+	if c.UsernameFlag {
+		if c.Username, err = d.string(); err != nil {
+			return err
+		}
+	}
+	if c.PasswordFlag {
+		if c.Password, err = d.binary(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConnackPacket 连接回执
+type ConnackPacket struct {
+	FixedHeader
+	SessionPresent bool       // 是否存在会话
+	ReasonCode     byte       // 3.1.1为返回码，MQTT 5为原因码
+	Properties     Properties // 属性（MQTT 5）
+}
+
+func (c *ConnackPacket) Encode(w io.Writer) error {
+	c.Type = CONNACK
+	c.Flags = 0
+	var flags byte
+	if c.SessionPresent {
+		flags = 0x01
+	}
+	body := []byte{flags, c.ReasonCode}
+	body = appendProperties(body, c.Properties, c.Version)
+	return c.write(w, body)
+}
+
+func (c *ConnackPacket) Decode(r io.Reader, remainingLen uint32) error {
+	d, err := newDecoder(r, remainingLen)
+	if err != nil {
+		return err
+	}
+	flags, err := d.byte()
+	if err != nil {
+		return err
+	}
+	c.SessionPresent = flags&0x01 != 0
+	if c.ReasonCode, err = d.byte(); err != nil {
+		return err
+	}
+	if d.len() > 0 {
+		c.Properties, err = d.properties(c.Version)
+	}
+	return err
 }
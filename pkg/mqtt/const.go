@@ -1,17 +1,101 @@
 package mqtt
 
+// PacketType 控制包类型
+type PacketType byte
+
+const (
+	CONNECT     PacketType = 1
+	CONNACK     PacketType = 2
+	PUBLISH     PacketType = 3
+	PUBACK      PacketType = 4
+	PUBREC      PacketType = 5
+	PUBREL      PacketType = 6
+	PUBCOMP     PacketType = 7
+	SUBSCRIBE   PacketType = 8
+	SUBACK      PacketType = 9
+	UNSUBSCRIBE PacketType = 10
+	UNSUBACK    PacketType = 11
+	PINGREQ     PacketType = 12
+	PINGRESP    PacketType = 13
+	DISCONNECT  PacketType = 14
+	AUTH        PacketType = 15
+)
+
+func (p PacketType) String() string {
+	switch p {
+	case CONNECT:
+		return "CONNECT"
+	case CONNACK:
+		return "CONNACK"
+	case PUBLISH:
+		return "PUBLISH"
+	case PUBACK:
+		return "PUBACK"
+	case PUBREC:
+		return "PUBREC"
+	case PUBREL:
+		return "PUBREL"
+	case PUBCOMP:
+		return "PUBCOMP"
+	case SUBSCRIBE:
+		return "SUBSCRIBE"
+	case SUBACK:
+		return "SUBACK"
+	case UNSUBSCRIBE:
+		return "UNSUBSCRIBE"
+	case UNSUBACK:
+		return "UNSUBACK"
+	case PINGREQ:
+		return "PINGREQ"
+	case PINGRESP:
+		return "PINGRESP"
+	case DISCONNECT:
+		return "DISCONNECT"
+	case AUTH:
+		return "AUTH"
+	}
+	return "UNKNOWN"
+}
+
+// 协议版本
+const (
+	Version31  byte = 3 // MQTT 3.1
+	Version311 byte = 4 // MQTT 3.1.1
+	Version5   byte = 5 // MQTT 5
+)
+
+// MQTT 3.1.1 CONNACK的返回码
+const (
+	ConnAccepted                   byte = 0x00
+	ConnRefusedBadProtocolVersion  byte = 0x01
+	ConnRefusedIdentifierRejected  byte = 0x02
+	ConnRefusedServerUnavailable   byte = 0x03
+	ConnRefusedBadUsernamePassword byte = 0x04
+	ConnRefusedNotAuthorized       byte = 0x05
+	SubackFailure                  byte = 0x80 // SUBACK的失败返回码（3.1.1）
+)
+
 type ReasonCode byte
 
 const (
 	Success                           ReasonCode = 0x00 // CONNACK, PUBACK, PUBREC, PUBREL, PUBCOMP, UNSUBACK, AUTH
+	NormalDisconnection               ReasonCode = 0x00 // DISCONNECT
+	DisconnectWithWillMessage         ReasonCode = 0x04 // DISCONNECT
+	GrantedQoS0                       ReasonCode = 0x00 // SUBACK
+	GrantedQoS1                       ReasonCode = 0x01 // SUBACK
 	NoMatchingSubscribers             ReasonCode = 0x10 // PUBACK, PUBREC
 	UnspecifiedError                  ReasonCode = 0x80 // CONNACK, PUBACK, PUBREC, SUBACK, UNSUBACK, DISCONNECT
 	MalformedPacket                   ReasonCode = 0x81 // CONNACK, DISCONNECT
 	ProtocolError                     ReasonCode = 0x82 // CONNACK, DISCONNECT
 	ImplSpecificError                 ReasonCode = 0x83 // CONNACK, PUBACK, PUBREC, SUBACK, UNSUBACK, DISCONNECT
+	UnsupportedProtocolVersion        ReasonCode = 0x84 // CONNACK
+	ClientIdentifierNotValid          ReasonCode = 0x85 // CONNACK
+	BadUsernameOrPassword             ReasonCode = 0x86 // CONNACK
 	NotAuthorized                     ReasonCode = 0x87 // CONNACK, PUBACK, PUBREC, SUBACK, UNSUBACK, DISCONNECT
 	ServerBusy                        ReasonCode = 0x89 // CONNACK, DISCONNECT
+	Banned                            ReasonCode = 0x8A // CONNACK
 	BadAuthMethod                     ReasonCode = 0x8C // CONNACK, DISCONNECT
+	SessionTakenOver                  ReasonCode = 0x8E // DISCONNECT
 	TopicFilterInvalid                ReasonCode = 0x8F // SUBACK, UNSUBACK, DISCONNECT
 	TopicNameInvalid                  ReasonCode = 0x90 // CONNACK, PUBACK, PUBREC, DISCONNECT
 	PacketIdentifierInUse             ReasonCode = 0x91 // PUBACK, SUBACK, UNSUBACK
@@ -4,6 +4,36 @@ import "io"
 
 // controlPacket MQTT control packet codec interface
 type ControlPacket interface {
+	// Header 固定头
+	Header() *FixedHeader
+	// Encode 编码整个数据包（包含固定头）
 	Encode(w io.Writer) error
+	// Decode 解码可变头和载荷，remainingLen为固定头内的剩余长度
 	Decode(r io.Reader, remainingLen uint32) error
 }
+
+// FixedHeader 固定头
+type FixedHeader struct {
+	Type    PacketType // 包类型
+	Flags   byte       // 标志位（低4位）
+	Version byte       // 协议版本（3.MQTT 3.1 4.MQTT 3.1.1 5.MQTT 5），编解码可变头时使用
+}
+
+func (f *FixedHeader) Header() *FixedHeader {
+	return f
+}
+
+// 写入固定头和剩余数据
+func (f *FixedHeader) write(w io.Writer, body []byte) error {
+	header := make([]byte, 0, 5)
+	header = append(header, byte(f.Type)<<4|f.Flags&0x0F)
+	header = appendVarint(header, uint32(len(body)))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if len(body) == 0 {
+		return nil
+	}
+	_, err := w.Write(body)
+	return err
+}
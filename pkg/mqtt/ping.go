@@ -0,0 +1,71 @@
+package mqtt
+
+import "io"
+
+// PingreqPacket 心跳请求
+type PingreqPacket struct {
+	FixedHeader
+}
+
+func (p *PingreqPacket) Encode(w io.Writer) error {
+	p.Type = PINGREQ
+	p.Flags = 0
+	return p.write(w, nil)
+}
+
+func (p *PingreqPacket) Decode(r io.Reader, remainingLen uint32) error {
+	_, err := newDecoder(r, remainingLen)
+	return err
+}
+
+// PingrespPacket 心跳回复
+type PingrespPacket struct {
+	FixedHeader
+}
+
+func (p *PingrespPacket) Encode(w io.Writer) error {
+	p.Type = PINGRESP
+	p.Flags = 0
+	return p.write(w, nil)
+}
+
+func (p *PingrespPacket) Decode(r io.Reader, remainingLen uint32) error {
+	_, err := newDecoder(r, remainingLen)
+	return err
+}
+
+// DisconnectPacket 断开连接
+type DisconnectPacket struct {
+	FixedHeader
+	ReasonCode ReasonCode // 原因码（MQTT 5）
+	Properties Properties // 属性（MQTT 5）
+}
+
+func (p *DisconnectPacket) Encode(w io.Writer) error {
+	p.Type = DISCONNECT
+	p.Flags = 0
+	var body []byte
+	if p.Version >= Version5 && (p.ReasonCode != NormalDisconnection || len(p.Properties) > 0) {
+		body = append(body, byte(p.ReasonCode))
+		body = appendProperties(body, p.Properties, p.Version)
+	}
+	return p.write(w, body)
+}
+
+func (p *DisconnectPacket) Decode(r io.Reader, remainingLen uint32) error {
+	d, err := newDecoder(r, remainingLen)
+	if err != nil {
+		return err
+	}
+	if p.Version >= Version5 && d.len() > 0 {
+		var reasonCode byte
+		if reasonCode, err = d.byte(); err != nil {
+			return err
+		}
+		p.ReasonCode = ReasonCode(reasonCode)
+		if d.len() > 0 {
+			p.Properties, err = d.properties(p.Version)
+		}
+	}
+	return err
+}
@@ -0,0 +1,122 @@
+package mqtt
+
+import "encoding/binary"
+
+// 属性标识（MQTT 5）
+const (
+	PropPayloadFormatIndicator        byte = 0x01
+	PropMessageExpiryInterval         byte = 0x02
+	PropContentType                   byte = 0x03
+	PropResponseTopic                 byte = 0x08
+	PropCorrelationData               byte = 0x09
+	PropSubscriptionIdentifier        byte = 0x0B
+	PropSessionExpiryInterval         byte = 0x11
+	PropReceiveMaximum                byte = 0x21
+	PropMaximumQoS                    byte = 0x24
+	PropRetainAvailable               byte = 0x25
+	PropMaximumPacketSize             byte = 0x27
+	PropAssignedClientIdentifier      byte = 0x12
+	PropTopicAliasMaximum             byte = 0x22
+	PropReasonString                  byte = 0x1F
+	PropWildcardSubscriptionAvailable byte = 0x28
+	PropSubscriptionIdAvailable       byte = 0x29
+	PropSharedSubscriptionAvailable   byte = 0x2A
+	PropServerKeepAlive               byte = 0x13
+	PropAuthenticationMethod          byte = 0x15
+	PropAuthenticationData            byte = 0x16
+	PropRequestProblemInformation     byte = 0x17
+	PropWillDelayInterval             byte = 0x18
+	PropRequestResponseInformation    byte = 0x19
+	PropResponseInformation           byte = 0x1A
+	PropServerReference               byte = 0x1C
+	PropTopicAlias                    byte = 0x23
+	PropUserProperty                  byte = 0x26
+)
+
+// Properties MQTT 5的属性（原始编码，不包含属性长度）
+type Properties []byte
+
+// AddByte 添加单字节属性
+func (p Properties) AddByte(id byte, v byte) Properties {
+	return append(p, id, v)
+}
+
+// AddUint16 添加两字节整数属性
+func (p Properties) AddUint16(id byte, v uint16) Properties {
+	p = append(p, id)
+	return binary.BigEndian.AppendUint16(p, v)
+}
+
+// AddUint32 添加四字节整数属性
+func (p Properties) AddUint32(id byte, v uint32) Properties {
+	p = append(p, id)
+	return binary.BigEndian.AppendUint32(p, v)
+}
+
+// AddString 添加字符串属性
+func (p Properties) AddString(id byte, v string) Properties {
+	p = append(p, id)
+	return appendString(p, v)
+}
+
+// Uint32 读取四字节整数属性，没有此属性或属性格式有误时返回false
+func (p Properties) Uint32(id byte) (uint32, bool) {
+	v, ok := p.find(id)
+	if !ok || len(v) != 4 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint32(v), true
+}
+
+// 查找属性的值（不包含属性标识）
+func (p Properties) find(id byte) ([]byte, bool) {
+	offset := 0
+	for offset < len(p) {
+		propId := p[offset]
+		offset++
+		size, err := propertySize(propId, p[offset:])
+		if err != nil || size == 0 || offset+size > len(p) {
+			return nil, false
+		}
+		if propId == id {
+			return p[offset : offset+size], true
+		}
+		offset += size
+	}
+	return nil, false
+}
+
+// 属性值占用的字节数
+func propertySize(id byte, data []byte) (int, error) {
+	switch id {
+	case PropPayloadFormatIndicator, PropRequestProblemInformation, PropRequestResponseInformation,
+		PropMaximumQoS, PropRetainAvailable, PropWildcardSubscriptionAvailable,
+		PropSubscriptionIdAvailable, PropSharedSubscriptionAvailable:
+		return 1, nil
+	case PropServerKeepAlive, PropReceiveMaximum, PropTopicAliasMaximum, PropTopicAlias:
+		return 2, nil
+	case PropMessageExpiryInterval, PropSessionExpiryInterval, PropWillDelayInterval, PropMaximumPacketSize:
+		return 4, nil
+	case PropSubscriptionIdentifier:
+		_, size, err := decodeVarint(data)
+		return size, err
+	case PropContentType, PropResponseTopic, PropCorrelationData, PropAssignedClientIdentifier,
+		PropAuthenticationMethod, PropAuthenticationData, PropResponseInformation,
+		PropServerReference, PropReasonString:
+		return lengthPrefixedSize(data, 0)
+	case PropUserProperty: // 字符串对
+		keySize, err := lengthPrefixedSize(data, 0)
+		if err != nil || keySize == 0 {
+			return 0, err
+		}
+		return lengthPrefixedSize(data, keySize)
+	}
+	return 0, ErrMalformedPacket
+}
+
+func lengthPrefixedSize(data []byte, offset int) (int, error) {
+	if len(data) < offset+2 {
+		return 0, ErrMalformedPacket
+	}
+	return offset + 2 + int(binary.BigEndian.Uint16(data[offset:])), nil
+}
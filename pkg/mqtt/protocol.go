@@ -1,8 +1,283 @@
 package mqtt
 
-import "io"
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
 
-func ReadFrom(r io.Reader) (ControlPacket, error) {
+var (
+	ErrMalformedPacket    = errors.New("mqtt: malformed packet")
+	ErrMalformedVarint    = errors.New("mqtt: malformed variable byte integer")
+	ErrUnknownPacketType  = errors.New("mqtt: unknown packet type")
+	ErrProtocolViolation  = errors.New("mqtt: protocol violation")
+	ErrUnsupportedVersion = errors.New("mqtt: unsupported protocol version")
+)
 
-	return nil, nil
+// 剩余长度的最大值 256MB
+const maxRemainingLength = 268435455
+
+// ReadFrom 从r中读取一个完整的控制包，version为连接的协议版本（CONNECT包不需要）
+func ReadFrom(r io.Reader, version byte) (ControlPacket, error) {
+	var b [1]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return nil, err
+	}
+	remainingLen, err := readVarintFrom(r)
+	if err != nil {
+		return nil, err
+	}
+	packet, err := NewControlPacket(PacketType(b[0]>>4), b[0]&0x0F, version)
+	if err != nil {
+		return nil, err
+	}
+	if err = packet.Decode(r, remainingLen); err != nil {
+		return nil, err
+	}
+	return packet, nil
+}
+
+// DecodePacket 从data中解码一个控制包，返回控制包和消费的字节数
+// 数据不完整时返回 nil, 0, nil
+func DecodePacket(data []byte, version byte) (ControlPacket, int, error) {
+	if len(data) < 2 {
+		return nil, 0, nil
+	}
+	remainingLen, size, err := decodeVarint(data[1:])
+	if err != nil {
+		return nil, 0, err
+	}
+	if size == 0 { // 剩余长度不完整
+		return nil, 0, nil
+	}
+	total := 1 + size + int(remainingLen)
+	if len(data) < total {
+		return nil, 0, nil
+	}
+	packet, err := NewControlPacket(PacketType(data[0]>>4), data[0]&0x0F, version)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err = packet.Decode(bytes.NewReader(data[1+size:total]), remainingLen); err != nil {
+		return nil, 0, err
+	}
+	return packet, total, nil
+}
+
+// EncodePacket 编码控制包
+func EncodePacket(packet ControlPacket) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := packet.Encode(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// NewControlPacket 根据包类型创建控制包
+func NewControlPacket(packetType PacketType, flags byte, version byte) (ControlPacket, error) {
+	header := FixedHeader{Type: packetType, Flags: flags, Version: version}
+	var packet ControlPacket
+	switch packetType {
+	case CONNECT:
+		packet = &ConnectPacket{FixedHeader: header}
+	case CONNACK:
+		packet = &ConnackPacket{FixedHeader: header}
+	case PUBLISH:
+		packet = &PublishPacket{FixedHeader: header}
+	case PUBACK:
+		packet = &PubackPacket{FixedHeader: header}
+	case SUBSCRIBE:
+		packet = &SubscribePacket{FixedHeader: header}
+	case SUBACK:
+		packet = &SubackPacket{FixedHeader: header}
+	case UNSUBSCRIBE:
+		packet = &UnsubscribePacket{FixedHeader: header}
+	case UNSUBACK:
+		packet = &UnsubackPacket{FixedHeader: header}
+	case PINGREQ:
+		packet = &PingreqPacket{FixedHeader: header}
+	case PINGRESP:
+		packet = &PingrespPacket{FixedHeader: header}
+	case DISCONNECT:
+		packet = &DisconnectPacket{FixedHeader: header}
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrUnknownPacketType, packetType)
+	}
+	return packet, nil
+}
+
+// ---------------------- 编码 ----------------------
+
+func appendVarint(b []byte, v uint32) []byte {
+	for {
+		digit := byte(v % 128)
+		v /= 128
+		if v > 0 {
+			digit |= 0x80
+		}
+		b = append(b, digit)
+		if v == 0 {
+			return b
+		}
+	}
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return binary.BigEndian.AppendUint16(b, v)
+}
+
+func appendString(b []byte, s string) []byte {
+	return appendBinary(b, []byte(s))
+}
+
+func appendBinary(b []byte, v []byte) []byte {
+	b = appendUint16(b, uint16(len(v)))
+	return append(b, v...)
+}
+
+// 追加属性（MQTT 5）
+func appendProperties(b []byte, props Properties, version byte) []byte {
+	if version < Version5 {
+		return b
+	}
+	b = appendVarint(b, uint32(len(props)))
+	return append(b, props...)
+}
+
+// ---------------------- 解码 ----------------------
+
+// 解码变长整数，返回值和占用的字节数，数据不完整时字节数为0
+func decodeVarint(data []byte) (uint32, int, error) {
+	var (
+		value      uint32
+		multiplier uint32 = 1
+	)
+	for i := 0; i < 4; i++ {
+		if i >= len(data) {
+			return 0, 0, nil
+		}
+		digit := data[i]
+		value += uint32(digit&0x7F) * multiplier
+		if digit&0x80 == 0 {
+			return value, i + 1, nil
+		}
+		multiplier *= 128
+	}
+	return 0, 0, ErrMalformedVarint
+}
+
+func readVarintFrom(r io.Reader) (uint32, error) {
+	var (
+		value      uint32
+		multiplier uint32 = 1
+		b          [1]byte
+	)
+	for i := 0; i < 4; i++ {
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return 0, err
+		}
+		value += uint32(b[0]&0x7F) * multiplier
+		if b[0]&0x80 == 0 {
+			return value, nil
+		}
+		multiplier *= 128
+	}
+	return 0, ErrMalformedVarint
+}
+
+// 可变头和载荷的解码器
+type decoder struct {
+	data   []byte
+	offset int
+}
+
+func newDecoder(r io.Reader, remainingLen uint32) (*decoder, error) {
+	if remainingLen > maxRemainingLength {
+		return nil, ErrMalformedPacket
+	}
+	data := make([]byte, remainingLen)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return &decoder{data: data}, nil
+}
+
+func (d *decoder) len() int {
+	return len(d.data) - d.offset
+}
+
+func (d *decoder) byte() (byte, error) {
+	if d.len() < 1 {
+		return 0, ErrMalformedPacket
+	}
+	b := d.data[d.offset]
+	d.offset++
+	return b, nil
+}
+
+func (d *decoder) uint16() (uint16, error) {
+	if d.len() < 2 {
+		return 0, ErrMalformedPacket
+	}
+	v := binary.BigEndian.Uint16(d.data[d.offset:])
+	d.offset += 2
+	return v, nil
+}
+
+func (d *decoder) binary() ([]byte, error) {
+	l, err := d.uint16()
+	if err != nil {
+		return nil, err
+	}
+	if d.len() < int(l) {
+		return nil, ErrMalformedPacket
+	}
+	v := d.data[d.offset : d.offset+int(l)]
+	d.offset += int(l)
+	return v, nil
+}
+
+func (d *decoder) string() (string, error) {
+	v, err := d.binary()
+	if err != nil {
+		return "", err
+	}
+	return string(v), nil
+}
+
+func (d *decoder) varint() (uint32, error) {
+	v, size, err := decodeVarint(d.data[d.offset:])
+	if err != nil {
+		return 0, err
+	}
+	if size == 0 {
+		return 0, ErrMalformedPacket
+	}
+	d.offset += size
+	return v, nil
+}
+
+// 读取属性（MQTT 5）
+func (d *decoder) properties(version byte) (Properties, error) {
+	if version < Version5 {
+		return nil, nil
+	}
+	l, err := d.varint()
+	if err != nil {
+		return nil, err
+	}
+	if d.len() < int(l) {
+		return nil, ErrMalformedPacket
+	}
+	props := Properties(d.data[d.offset : d.offset+int(l)])
+	d.offset += int(l)
+	return props, nil
+}
+
+func (d *decoder) rest() []byte {
+	v := d.data[d.offset:]
+	d.offset = len(d.data)
+	return v
 }
@@ -0,0 +1,154 @@
+package mqtt_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/mqtt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectPacket(t *testing.T) {
+	for _, version := range []byte{mqtt.Version311, mqtt.Version5} {
+		packet := &mqtt.ConnectPacket{
+			ProtocolName:    "MQTT",
+			ProtocolVersion: version,
+			CleanSession:    true,
+			KeepAlive:       60,
+			ClientId:        "device1",
+			WillFlag:        true,
+			WillQoS:         1,
+			WillTopic:       "will",
+			WillPayload:     []byte("bye"),
+			UsernameFlag:    true,
+			Username:        "u1",
+			PasswordFlag:    true,
+			Password:        []byte("token"),
+		}
+		if version == mqtt.Version5 {
+			packet.Properties = mqtt.Properties{}.AddUint32(mqtt.PropSessionExpiryInterval, 10)
+		}
+		data, err := mqtt.EncodePacket(packet)
+		assert.NoError(t, err)
+
+		p, size, err := mqtt.DecodePacket(data, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, len(data), size)
+		connect := p.(*mqtt.ConnectPacket)
+		assert.Equal(t, version, connect.ProtocolVersion)
+		assert.Equal(t, version, connect.Version)
+		assert.True(t, connect.CleanSession)
+		assert.Equal(t, uint16(60), connect.KeepAlive)
+		assert.Equal(t, "device1", connect.ClientId)
+		assert.Equal(t, byte(1), connect.WillQoS)
+		assert.Equal(t, "will", connect.WillTopic)
+		assert.Equal(t, []byte("bye"), connect.WillPayload)
+		assert.Equal(t, "u1", connect.Username)
+		assert.Equal(t, []byte("token"), connect.Password)
+		assert.Equal(t, packet.Properties, connect.Properties)
+	}
+}
+
+func TestPublishPacket(t *testing.T) {
+	for _, version := range []byte{mqtt.Version311, mqtt.Version5} {
+		packet := &mqtt.PublishPacket{
+			QoS:       1,
+			Dup:       true,
+			TopicName: "group/g1",
+			PacketId:  10,
+			Payload:   []byte("hello"),
+		}
+		packet.Version = version
+		data, err := mqtt.EncodePacket(packet)
+		assert.NoError(t, err)
+
+		p, err := mqtt.ReadFrom(bytes.NewReader(data), version)
+		assert.NoError(t, err)
+		publish := p.(*mqtt.PublishPacket)
+		assert.Equal(t, byte(1), publish.QoS)
+		assert.True(t, publish.Dup)
+		assert.False(t, publish.Retain)
+		assert.Equal(t, "group/g1", publish.TopicName)
+		assert.Equal(t, uint16(10), publish.PacketId)
+		assert.Equal(t, []byte("hello"), publish.Payload)
+	}
+}
+
+func TestDecodePacketNotEnough(t *testing.T) {
+	packet := &mqtt.PublishPacket{
+		TopicName: "person/u1",
+		Payload:   bytes.Repeat([]byte("a"), 200), // 剩余长度需要两个字节表示
+	}
+	data, err := mqtt.EncodePacket(packet)
+	assert.NoError(t, err)
+
+	for _, n := range []int{0, 1, 2, len(data) - 1} {
+		p, size, err := mqtt.DecodePacket(data[:n], mqtt.Version311)
+		assert.NoError(t, err)
+		assert.Nil(t, p)
+		assert.Equal(t, 0, size)
+	}
+
+	// 两个包粘在一起
+	data = append(data, data...)
+	p, size, err := mqtt.DecodePacket(data, mqtt.Version311)
+	assert.NoError(t, err)
+	assert.NotNil(t, p)
+	assert.Equal(t, len(data)/2, size)
+}
+
+func TestSubscribePacket(t *testing.T) {
+	packet := &mqtt.SubscribePacket{
+		PacketId: 1,
+		Subscriptions: []mqtt.Subscription{
+			{TopicFilter: "person/+", QoS: 1},
+			{TopicFilter: "group/#", QoS: 0},
+		},
+	}
+	packet.Version = mqtt.Version5
+	data, err := mqtt.EncodePacket(packet)
+	assert.NoError(t, err)
+
+	p, _, err := mqtt.DecodePacket(data, mqtt.Version5)
+	assert.NoError(t, err)
+	subscribe := p.(*mqtt.SubscribePacket)
+	assert.Equal(t, uint16(1), subscribe.PacketId)
+	assert.Equal(t, packet.Subscriptions, subscribe.Subscriptions)
+
+	// 订阅包的标志位必须为0x02
+	data[0] = byte(mqtt.SUBSCRIBE) << 4
+	_, _, err = mqtt.DecodePacket(data, mqtt.Version5)
+	assert.Error(t, err)
+}
+
+func TestMatchTopic(t *testing.T) {
+	assert.True(t, mqtt.MatchTopic("group/+", "group/g1"))
+	assert.True(t, mqtt.MatchTopic("#", "group/g1"))
+	assert.True(t, mqtt.MatchTopic("group/#", "group"))
+	assert.True(t, mqtt.MatchTopic("person/u1", "person/u1"))
+	assert.False(t, mqtt.MatchTopic("group/+", "person/u1"))
+	assert.False(t, mqtt.MatchTopic("group/+", "group/g1/x"))
+
+	assert.True(t, mqtt.ValidTopicFilter("group/+"))
+	assert.True(t, mqtt.ValidTopicFilter("#"))
+	assert.False(t, mqtt.ValidTopicFilter("group/#/x"))
+	assert.False(t, mqtt.ValidTopicFilter("group/g+"))
+	assert.False(t, mqtt.ValidTopicName("group/+"))
+}
+
+func TestPropertiesUint32(t *testing.T) {
+	props := mqtt.Properties{}.
+		AddString(mqtt.PropAuthenticationMethod, "m").
+		AddUint16(mqtt.PropReceiveMaximum, 10).
+		AddUint32(mqtt.PropSessionExpiryInterval, 3600)
+	v, ok := props.Uint32(mqtt.PropSessionExpiryInterval)
+	assert.True(t, ok)
+	assert.Equal(t, uint32(3600), v)
+
+	_, ok = props.Uint32(mqtt.PropWillDelayInterval)
+	assert.False(t, ok)
+
+	// 格式有误
+	_, ok = mqtt.Properties{0x11, 0x00}.Uint32(mqtt.PropSessionExpiryInterval)
+	assert.False(t, ok)
+}
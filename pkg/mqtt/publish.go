@@ -0,0 +1,99 @@
+package mqtt
+
+import "io"
+
+// PublishPacket 发布消息
+type PublishPacket struct {
+	FixedHeader
+	Dup        bool       // 是否是重发
+	QoS        byte       // 服务质量等级
+	Retain     bool       // 是否保留
+	TopicName  string     // 主题
+	PacketId   uint16     // 包标识（QoS大于0时才有）
+	Properties Properties // 属性（MQTT 5）
+	Payload    []byte     // 消息内容
+}
+
+func (p *PublishPacket) Encode(w io.Writer) error {
+	p.Type = PUBLISH
+	p.Flags = (p.QoS & 0x03) << 1
+	if p.Dup {
+		p.Flags |= 0x08
+	}
+	if p.Retain {
+		p.Flags |= 0x01
+	}
+	body := appendString(nil, p.TopicName)
+	if p.QoS > 0 {
+		body = appendUint16(body, p.PacketId)
+	}
+	body = appendProperties(body, p.Properties, p.Version)
+	body = append(body, p.Payload...)
+	return p.write(w, body)
+}
+
+func (p *PublishPacket) Decode(r io.Reader, remainingLen uint32) error {
+	p.Dup = p.Flags&0x08 != 0
+	p.QoS = (p.Flags >> 1) & 0x03
+	p.Retain = p.Flags&0x01 != 0
+	if p.QoS > 2 {
+		return ErrProtocolViolation
+	}
+	d, err := newDecoder(r, remainingLen)
+	if err != nil {
+		return err
+	}
+	if p.TopicName, err = d.string(); err != nil {
+		return err
+	}
+	if p.QoS > 0 {
+		if p.PacketId, err = d.uint16(); err != nil {
+			return err
+		}
+	}
+	if p.Properties, err = d.properties(p.Version); err != nil {
+		return err
+	}
+	p.Payload = append([]byte(nil), d.rest()...)
+	return nil
+}
+
+// PubackPacket 发布回执（QoS 1）
+type PubackPacket struct {
+	FixedHeader
+	PacketId   uint16     // 包标识
+	ReasonCode ReasonCode // 原因码（MQTT 5）
+	Properties Properties // 属性（MQTT 5）
+}
+
+func (p *PubackPacket) Encode(w io.Writer) error {
+	p.Type = PUBACK
+	p.Flags = 0
+	body := appendUint16(nil, p.PacketId)
+	if p.Version >= Version5 && (p.ReasonCode != Success || len(p.Properties) > 0) {
+		body = append(body, byte(p.ReasonCode))
+		body = appendProperties(body, p.Properties, p.Version)
+	}
+	return p.write(w, body)
+}
+
+func (p *PubackPacket) Decode(r io.Reader, remainingLen uint32) error {
+	d, err := newDecoder(r, remainingLen)
+	if err != nil {
+		return err
+	}
+	if p.PacketId, err = d.uint16(); err != nil {
+		return err
+	}
+	if p.Version >= Version5 && d.len() > 0 {
+		var reasonCode byte
+		if reasonCode, err = d.byte(); err != nil {
+			return err
+		}
+		p.ReasonCode = ReasonCode(reasonCode)
+		if d.len() > 0 {
+			p.Properties, err = d.properties(p.Version)
+		}
+	}
+	return err
+}
@@ -0,0 +1,177 @@
+package mqtt
+
+import "io"
+
+// Subscription 订阅的主题过滤器
+type Subscription struct {
+	TopicFilter string // 主题过滤器 支持通配符 + 和 #
+	QoS         byte   // 请求的最大QoS
+	Options     byte   // 订阅选项的其他位（MQTT 5 No Local、Retain As Published、Retain Handling）
+}
+
+// SubscribePacket 订阅
+type SubscribePacket struct {
+	FixedHeader
+	PacketId      uint16
+	Properties    Properties
+	Subscriptions []Subscription
+}
+
+func (s *SubscribePacket) Encode(w io.Writer) error {
+	s.Type = SUBSCRIBE
+	s.Flags = 0x02
+	body := appendUint16(nil, s.PacketId)
+	body = appendProperties(body, s.Properties, s.Version)
+	for _, sub := range s.Subscriptions {
+		body = appendString(body, sub.TopicFilter)
+		body = append(body, sub.QoS&0x03|sub.Options&0xFC)
+	}
+	return s.write(w, body)
+}
+
+func (s *SubscribePacket) Decode(r io.Reader, remainingLen uint32) error {
+	if s.Flags != 0x02 {
+		return ErrMalformedPacket
+	}
+	d, err := newDecoder(r, remainingLen)
+	if err != nil {
+		return err
+	}
+	if s.PacketId, err = d.uint16(); err != nil {
+		return err
+	}
+	if s.Properties, err = d.properties(s.Version); err != nil {
+		return err
+	}
+	for d.len() > 0 {
+		var sub Subscription
+		if sub.TopicFilter, err = d.string(); err != nil {
+			return err
+		}
+		var opts byte
+		if opts, err = d.byte(); err != nil {
+			return err
+		}
+		sub.QoS = opts & 0x03
+		sub.Options = opts & 0xFC
+		s.Subscriptions = append(s.Subscriptions, sub)
+	}
+	if len(s.Subscriptions) == 0 { // 至少需要一个主题过滤器
+		return ErrProtocolViolation
+	}
+	return nil
+}
+
+// SubackPacket 订阅回执
+type SubackPacket struct {
+	FixedHeader
+	PacketId    uint16
+	Properties  Properties
+	ReasonCodes []byte // 每个主题过滤器的结果（授予的QoS或者失败的原因码）
+}
+
+func (s *SubackPacket) Encode(w io.Writer) error {
+	s.Type = SUBACK
+	s.Flags = 0
+	body := appendUint16(nil, s.PacketId)
+	body = appendProperties(body, s.Properties, s.Version)
+	body = append(body, s.ReasonCodes...)
+	return s.write(w, body)
+}
+
+func (s *SubackPacket) Decode(r io.Reader, remainingLen uint32) error {
+	d, err := newDecoder(r, remainingLen)
+	if err != nil {
+		return err
+	}
+	if s.PacketId, err = d.uint16(); err != nil {
+		return err
+	}
+	if s.Properties, err = d.properties(s.Version); err != nil {
+		return err
+	}
+	s.ReasonCodes = append([]byte(nil), d.rest()...)
+	return nil
+}
+
+// UnsubscribePacket 取消订阅
+type UnsubscribePacket struct {
+	FixedHeader
+	PacketId     uint16
+	Properties   Properties
+	TopicFilters []string
+}
+
+func (u *UnsubscribePacket) Encode(w io.Writer) error {
+	u.Type = UNSUBSCRIBE
+	u.Flags = 0x02
+	body := appendUint16(nil, u.PacketId)
+	body = appendProperties(body, u.Properties, u.Version)
+	for _, topicFilter := range u.TopicFilters {
+		body = appendString(body, topicFilter)
+	}
+	return u.write(w, body)
+}
+
+func (u *UnsubscribePacket) Decode(r io.Reader, remainingLen uint32) error {
+	if u.Flags != 0x02 {
+		return ErrMalformedPacket
+	}
+	d, err := newDecoder(r, remainingLen)
+	if err != nil {
+		return err
+	}
+	if u.PacketId, err = d.uint16(); err != nil {
+		return err
+	}
+	if u.Properties, err = d.properties(u.Version); err != nil {
+		return err
+	}
+	for d.len() > 0 {
+		var topicFilter string
+		if topicFilter, err = d.string(); err != nil {
+			return err
+		}
+		u.TopicFilters = append(u.TopicFilters, topicFilter)
+	}
+	if len(u.TopicFilters) == 0 {
+		return ErrProtocolViolation
+	}
+	return nil
+}
+
+// UnsubackPacket 取消订阅回执
+type UnsubackPacket struct {
+	FixedHeader
+	PacketId    uint16
+	Properties  Properties
+	ReasonCodes []byte // 每个主题过滤器的结果（MQTT 5）
+}
+
+func (u *UnsubackPacket) Encode(w io.Writer) error {
+	u.Type = UNSUBACK
+	u.Flags = 0
+	body := appendUint16(nil, u.PacketId)
+	if u.Version >= Version5 {
+		body = appendProperties(body, u.Properties, u.Version)
+		body = append(body, u.ReasonCodes...)
+	}
+	return u.write(w, body)
+}
+
+func (u *UnsubackPacket) Decode(r io.Reader, remainingLen uint32) error {
+	d, err := newDecoder(r, remainingLen)
+	if err != nil {
+		return err
+	}
+	if u.PacketId, err = d.uint16(); err != nil {
+		return err
+	}
+	if u.Version >= Version5 {
+		if u.Properties, err = d.properties(u.Version); err != nil {
+			return err
+		}
+		u.ReasonCodes = append([]byte(nil), d.rest()...)
+	}
+	return nil
+}
@@ -33,4 +33,5 @@ type SyncInfo struct {
 	roleSwitching bool   // 角色切换中
 	emptySyncTick int    // 空同步计时器(连续多少次tick没有同步到数据)
 	suspend       bool   // 是否挂起
+	active        bool   // 最近一个选举周期内是否有同步过（用于法定数检查）
 }
@@ -8,4 +8,7 @@ var (
 	ErrNotLeader             = errors.New("not leader")
 	ErrPaused                = errors.New("raft is paused")
 	ErrProposalDropped       = errors.New("proposal dropped")
+	ErrTransferNotReplica    = errors.New("transfer leader target is not a replica")
+	ErrLeaderTransferring    = errors.New("leader is transferring")
+	ErrConfigMigrating       = errors.New("config is migrating")
 )
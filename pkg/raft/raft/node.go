@@ -50,6 +50,15 @@ type Node struct {
 
 	syncing             bool // 正在同步
 	syncRespTimeoutTick int  // 同步响应超时计数
+
+	compactedIndex     uint64          // 已压缩的日志下标
+	compacting         bool            // 压缩日志中
+	installingSnapshot bool            // 安装快照中
+	needSnapshot       bool            // 日志和领导从第一条开始就不一致，需要领导发送快照
+	recvSnapshot       *types.Snapshot // 正在分块接收的快照，Offset为存储已保存的大小，Data为还没交给存储的块
+
+	leadTransferee      uint64 // 领导权转让的目标节点
+	leadTransferElapsed int    // 领导权转让计时
 }
 
 func NewNode(lastTermStartLogIndex uint64, raftState types.RaftState, opts *Options) *Node {
@@ -78,7 +87,17 @@ func NewNode(lastTermStartLogIndex uint64, raftState types.RaftState, opts *Opti
 	n.resetRandomizedElectionTimeout()
 
 	n.lastTermStartIndex.Index = lastTermStartLogIndex
-	n.lastTermStartIndex.Term = n.cfg.Term
+	if raftState.LastLogIndex > 0 { // 没有日志时最新日志任期为0，领导不需要判断是否裁剪
+		n.lastTermStartIndex.Term = n.cfg.Term
+	}
+
+	// 恢复保存的任期和投票记录
+	if raftState.Term > n.cfg.Term {
+		n.cfg.Term = raftState.Term
+	}
+	if raftState.Term == n.cfg.Term {
+		n.voteFor = raftState.VoteFor
+	}
 
 	onlySelf := false
 	if len(n.cfg.Replicas) == 1 {
@@ -115,6 +134,11 @@ func (n *Node) LastLogTerm() uint32 {
 	return n.lastTermStartIndex.Term
 }
 
+// VoteFor 当前任期投票给了谁
+func (n *Node) VoteFor() uint64 {
+	return n.voteFor
+}
+
 // LastTerm 当前领导任期
 func (n *Node) LastTerm() uint32 {
 	return n.cfg.Term
@@ -125,7 +149,10 @@ func (n *Node) HasReady() bool {
 	if n.queue.hasNextStoreLogs() {
 		return true
 	}
-	if n.queue.hasNextApplyLogs() {
+	if n.queue.hasNextApplyLogs() && !n.installingSnapshot {
+		return true
+	}
+	if n.needCompact() {
 		return true
 	}
 	return len(n.events) > 0
@@ -143,27 +170,39 @@ func (n *Node) Ready() []types.Event {
 		logs := n.queue.nextStoreLogs(0)
 		if len(logs) > 0 {
 			var termStartIndexInfo *types.TermStartIndexInfo
-			for _, log := range logs {
+			for i, log := range logs {
+				if termStartIndexInfo != nil {
+					// 一次只能保存一个任期的开始下标，出现第二个新任期的日志留到下次存储
+					if log.Term != termStartIndexInfo.Term {
+						logs = logs[:i]
+						break
+					}
+					continue
+				}
 				if n.lastTermStartIndex.Term != log.Term || log.Index == 1 {
 					termStartIndexInfo = &types.TermStartIndexInfo{
 						Term:  log.Term,
 						Index: log.Index,
 					}
 					n.updateLastTermStartIndex(log.Term, log.Index)
-					break
 				}
 			}
 			n.sendStoreReq(logs, termStartIndexInfo)
 		}
 	}
 
-	if n.queue.hasNextApplyLogs() {
+	if n.queue.hasNextApplyLogs() && !n.installingSnapshot {
 		start, end := n.queue.nextApplyLogs()
 		if start > 0 {
 			n.sendApplyReq(start, end)
 		}
 	}
 
+	if n.needCompact() {
+		n.compacting = true
+		n.sendCompactReq(n.queue.appliedIndex - n.opts.CompactLogRetain)
+	}
+
 	events := n.events
 	n.events = n.events[:0]
 	return events
@@ -173,6 +212,10 @@ func (n *Node) LeaderId() uint64 {
 	return n.cfg.Leader
 }
 
+func (n *Node) Term() uint32 {
+	return n.cfg.Term
+}
+
 func (n *Node) Config() types.Config {
 	return n.cfg
 }
@@ -237,6 +280,14 @@ func (n *Node) NewPropose(data []byte) types.Event {
 	}
 }
 
+// 是否需要压缩日志
+func (n *Node) needCompact() bool {
+	if n.opts.CompactLogThreshold == 0 || n.compacting || n.installingSnapshot {
+		return false
+	}
+	return n.queue.appliedIndex > n.compactedIndex+n.opts.CompactLogThreshold+n.opts.CompactLogRetain
+}
+
 func (n *Node) updateLastTermStartIndex(term uint32, index uint64) {
 	n.lastTermStartIndex.Term = term
 	n.lastTermStartIndex.Index = index
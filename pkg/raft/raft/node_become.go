@@ -19,12 +19,28 @@ func (n *Node) BecomeCandidate() {
 	n.Info("become candidate", zap.Uint32("term", n.cfg.Term), zap.Int("nextElectionTimeout", n.randomizedElectionTimeout))
 }
 
+// BecomePreCandidate 成为预候选人，预投票不增加任期也不改变投票记录
+func (n *Node) BecomePreCandidate() {
+	if n.cfg.Role == types.RoleLeader {
+		n.Panic("invalid transition [leader -> pre-candidate]")
+	}
+	n.stepFunc = n.stepPreCandidate
+	n.reset()
+	n.tickFnc = n.tickCandidate
+	n.cfg.Leader = 0
+	n.cfg.Role = types.RolePreCandidate
+	n.Info("become pre-candidate", zap.Uint32("term", n.cfg.Term), zap.Int("nextElectionTimeout", n.randomizedElectionTimeout))
+}
+
 func (n *Node) BecomeFollower(term uint32, leaderId uint64) {
+	if term != n.cfg.Term {
+		// 任期变化才清空投票记录，同一任期内只能投一次票
+		n.voteFor = None
+	}
 	n.cfg.Term = term
 	n.stepFunc = n.stepFollower
 	n.reset()
 	n.tickFnc = n.tickFollower
-	n.voteFor = None
 	n.cfg.Leader = leaderId
 	n.cfg.Role = types.RoleFollower
 	n.Debug("become follower", zap.Uint32("term", n.cfg.Term), zap.Uint64("leaderId", leaderId))
@@ -41,24 +57,32 @@ func (n *Node) BecomeLeader(term uint32) {
 }
 
 func (n *Node) BecomeLearner(term uint32, leaderId uint64) {
+	if term != n.cfg.Term {
+		// 任期变化才清空投票记录，同一任期内只能投一次票
+		n.voteFor = None
+	}
 	n.cfg.Term = term
 	n.stepFunc = n.stepLearner
 	n.reset()
 	n.tickFnc = n.tickLearner
-	n.voteFor = None
 	n.cfg.Leader = leaderId
 	n.cfg.Role = types.RoleLearner
 	n.Info("become learner", zap.Uint32("term", n.cfg.Term), zap.Uint64("leaderId", leaderId))
 }
 
 func (n *Node) reset() {
-	// 重置选举状态
+	// 重置选举状态（投票记录跟随任期，由调用方决定是否清空）
 	n.electionState = electionState{
-		votes: make(map[uint64]bool),
+		voteFor: n.voteFor,
+		votes:   make(map[uint64]bool),
 	}
 	n.stopPropose = false
+	n.leadTransferee = None
+	n.leadTransferElapsed = 0
 	n.syncState.replicaSync = make(map[uint64]*SyncInfo)
 	n.onlySync = false
+	n.needSnapshot = false
+	n.recvSnapshot = nil
 	n.suspend = false
 	// 重置选举超时时间
 	n.resetRandomizedElectionTimeout()
@@ -42,14 +42,29 @@ func (n *Node) sendNotifySync(to uint64) {
 
 func (n *Node) sendSyncReq() {
 
-	if n.truncating || n.syncing { // 如果在截断中，则发起同步没用
+	if n.truncating || n.syncing || n.installingSnapshot { // 如果在截断中，则发起同步没用
+		return
+	}
+	if n.recvSnapshot != nil && n.recvSnapshot.IsLastChunk() { // 快照已接收完，还没能安装
+		n.installSnapshot(*n.recvSnapshot)
 		return
 	}
 	n.syncing = true
 	n.syncElapsed = 0
-	var reason types.Reason
-	if n.onlySync {
+	var (
+		reason   types.Reason
+		snapshot types.Snapshot
+	)
+	if n.recvSnapshot != nil { // 请求快照的下一块
+		reason = types.ReasonSnapshot
+		snapshot = types.Snapshot{
+			Index:  n.recvSnapshot.Index,
+			Offset: n.recvSnapshot.Offset,
+		}
+	} else if n.onlySync {
 		reason = types.ReasonOnlySync
+	} else if n.needSnapshot {
+		reason = types.ReasonSnapshot
 	}
 	n.events = append(n.events, types.Event{
 		Type:        types.SyncReq,
@@ -60,15 +75,18 @@ func (n *Node) sendSyncReq() {
 		Index:       n.queue.lastLogIndex + 1,
 		LastLogTerm: n.lastTermStartIndex.Term,
 		Reason:      reason,
+		Snapshot:    snapshot,
+		SyncVersion: types.SyncVersionTermTruncate,
 	})
 }
 
-func (n *Node) sendVoteReq(to uint64) {
+func (n *Node) sendVoteReq(to uint64, reason types.Reason) {
 	n.events = append(n.events, types.Event{
-		Type: types.VoteReq,
-		From: n.opts.NodeId,
-		To:   to,
-		Term: n.cfg.Term,
+		Type:   types.VoteReq,
+		From:   n.opts.NodeId,
+		To:     to,
+		Term:   n.cfg.Term,
+		Reason: reason,
 		Logs: []types.Log{
 			{
 				Term:  n.lastTermStartIndex.Term,
@@ -89,6 +107,33 @@ func (n *Node) sendVoteResp(to uint64, reason types.Reason) {
 	})
 }
 
+// 发送预投票请求，任期为下一次选举的任期
+func (n *Node) sendPreVoteReq(to uint64) {
+	n.events = append(n.events, types.Event{
+		Type: types.PreVoteReq,
+		From: n.opts.NodeId,
+		To:   to,
+		Term: n.cfg.Term + 1,
+		Logs: []types.Log{
+			{
+				Term:  n.lastTermStartIndex.Term,
+				Index: n.queue.lastLogIndex,
+			},
+		},
+	})
+}
+
+// 发送预投票响应，同意时返回请求的任期，拒绝时返回本节点的任期
+func (n *Node) sendPreVoteResp(to uint64, term uint32, reason types.Reason) {
+	n.events = append(n.events, types.Event{
+		Type:   types.PreVoteResp,
+		From:   n.opts.NodeId,
+		To:     to,
+		Term:   term,
+		Reason: reason,
+	})
+}
+
 func (n *Node) sendPing(to uint64) {
 	if !n.IsLeader() {
 		return
@@ -167,6 +212,8 @@ func (n *Node) sendGetLogsReq(syncEvent types.Event) {
 		StoredIndex: n.queue.storedIndex,
 		LastLogTerm: syncEvent.LastLogTerm,
 		Reason:      syncEvent.Reason,
+		Snapshot:    syncEvent.Snapshot,
+		SyncVersion: syncEvent.SyncVersion,
 	})
 }
 
@@ -181,6 +228,53 @@ func (n *Node) sendSyncResp(to uint64, syncIndex uint64, logs []types.Log, reson
 		CommittedIndex: n.queue.committedIndex,
 		Reason:         reson,
 		Speed:          speed,
+		SyncVersion:    types.SyncVersionTermTruncate,
+	})
+}
+
+// 通知副本裁剪日志，lastLogTerm为领导也有的副本最新日志任期，为0表示领导没有这个任期的日志
+func (n *Node) sendTruncateSyncResp(to uint64, trunctIndex uint64, lastLogTerm uint32) {
+	n.events = append(n.events, types.Event{
+		Type:           types.SyncResp,
+		From:           n.opts.NodeId,
+		To:             to,
+		Term:           n.cfg.Term,
+		Index:          trunctIndex,
+		LastLogTerm:    lastLogTerm,
+		CommittedIndex: n.queue.committedIndex,
+		Reason:         types.ReasonTruncate,
+		SyncVersion:    types.SyncVersionTermTruncate,
+	})
+}
+
+// 发送快照给副本（副本需要的日志已被压缩）
+func (n *Node) sendSnapshotSyncResp(to uint64, syncIndex uint64, snapshot types.Snapshot) {
+	n.events = append(n.events, types.Event{
+		Type:           types.SyncResp,
+		From:           n.opts.NodeId,
+		To:             to,
+		Term:           n.cfg.Term,
+		Index:          syncIndex,
+		CommittedIndex: n.queue.committedIndex,
+		Reason:         types.ReasonSnapshot,
+		Snapshot:       snapshot,
+		SyncVersion:    types.SyncVersionTermTruncate,
+	})
+}
+
+func (n *Node) sendInstallSnapshotReq(snapshot types.Snapshot) {
+	n.events = append(n.events, types.Event{
+		Type:     types.InstallSnapshotReq,
+		To:       types.LocalNode,
+		Snapshot: snapshot,
+	})
+}
+
+func (n *Node) sendCompactReq(index uint64) {
+	n.events = append(n.events, types.Event{
+		Type:  types.CompactReq,
+		To:    types.LocalNode,
+		Index: index,
 	})
 }
 
@@ -215,6 +309,16 @@ func (n *Node) sendFollowToLeaderReq(from uint64) {
 	})
 }
 
+// 通知转让目标立即发起选举
+func (n *Node) sendTimeoutNow(to uint64) {
+	n.events = append(n.events, types.Event{
+		Type: types.TimeoutNow,
+		From: n.opts.NodeId,
+		To:   to,
+		Term: n.cfg.Term,
+	})
+}
+
 func (n *Node) sendConfigReq() {
 	n.events = append(n.events, types.Event{
 		Type: types.ConfigReq,
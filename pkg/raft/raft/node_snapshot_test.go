@@ -0,0 +1,151 @@
+package raft
+
+import (
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/raft/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeRecvSnapshotChunks(t *testing.T) {
+	n := newTestFollower()
+	snapshot := types.Snapshot{Index: 100, Term: 2, TermStartIndex: 90, Data: []byte("0123456789")}
+
+	// 收到的块马上交给存储保存，不在内存里累积
+	stepSnapshotChunk(t, n, snapshot.Chunk(0, 4))
+	install := findEvent(n.Ready(), types.InstallSnapshotReq)
+	assert.Equal(t, []byte("0123"), install.Snapshot.Data)
+	assert.Equal(t, uint64(0), install.Snapshot.Offset)
+	assert.Nil(t, n.recvSnapshot.Data)
+
+	// 保存完后请求下一块
+	stepInstallSnapshotResp(t, n, install.Snapshot)
+	req := findEvent(n.Ready(), types.SyncReq)
+	assert.Equal(t, types.ReasonSnapshot, req.Reason)
+	assert.Equal(t, uint64(100), req.Snapshot.Index)
+	assert.Equal(t, uint64(4), req.Snapshot.Offset)
+
+	stepSnapshotChunk(t, n, snapshot.Chunk(4, 4))
+	install = findEvent(n.Ready(), types.InstallSnapshotReq)
+	stepInstallSnapshotResp(t, n, install.Snapshot)
+	req = findEvent(n.Ready(), types.SyncReq)
+	assert.Equal(t, uint64(8), req.Snapshot.Offset)
+
+	// 最后一块保存完后安装快照
+	stepSnapshotChunk(t, n, snapshot.Chunk(8, 4))
+	install = findEvent(n.Ready(), types.InstallSnapshotReq)
+	assert.True(t, install.Snapshot.IsLastChunk())
+	assert.Equal(t, uint64(100), install.Snapshot.Index)
+	assert.Equal(t, uint64(90), install.Snapshot.TermStartIndex)
+
+	stepInstallSnapshotResp(t, n, install.Snapshot)
+	assert.Nil(t, n.recvSnapshot)
+	assert.Equal(t, uint64(100), n.queue.appliedIndex)
+	assert.Equal(t, uint64(100), n.compactedIndex)
+}
+
+func TestNodeRecvSnapshotDiscontinuous(t *testing.T) {
+	n := newTestFollower()
+	snapshot := types.Snapshot{Index: 100, Term: 2, Data: []byte("0123456789")}
+
+	stepSnapshotChunk(t, n, snapshot.Chunk(0, 4))
+	stepInstallSnapshotResp(t, n, findEvent(n.Ready(), types.InstallSnapshotReq).Snapshot)
+	n.Ready()
+
+	// 跳过了一块，丢弃已接收的数据重新请求整个快照
+	stepSnapshotChunk(t, n, snapshot.Chunk(8, 4))
+	assert.Nil(t, n.recvSnapshot)
+	assert.True(t, n.needSnapshot)
+
+	n.syncing = false
+	n.sendSyncReq()
+	req := findEvent(n.Ready(), types.SyncReq)
+	assert.Equal(t, types.ReasonSnapshot, req.Reason)
+	assert.True(t, req.Snapshot.IsEmpty())
+
+	// 领导重新生成了快照，从第一块开始接收
+	newSnapshot := types.Snapshot{Index: 120, Term: 2, Data: []byte("abcdef")}
+	stepSnapshotChunk(t, n, newSnapshot.Chunk(0, 4))
+	install := findEvent(n.Ready(), types.InstallSnapshotReq)
+	assert.Equal(t, uint64(120), install.Snapshot.Index)
+	assert.Equal(t, uint64(0), install.Snapshot.Offset)
+}
+
+func TestNodeRecvSnapshotSaveFailed(t *testing.T) {
+	n := newTestFollower()
+	snapshot := types.Snapshot{Index: 100, Term: 2, Data: []byte("0123456789")}
+
+	stepSnapshotChunk(t, n, snapshot.Chunk(0, 4))
+	n.Ready()
+
+	// 保存失败，重新请求整个快照
+	err := n.Step(types.Event{Type: types.InstallSnapshotResp, Reason: types.ReasonError})
+	assert.NoError(t, err)
+	assert.Nil(t, n.recvSnapshot)
+	req := findEvent(n.Ready(), types.SyncReq)
+	assert.Equal(t, types.Snapshot{}, req.Snapshot)
+}
+
+func TestNodeRecvSnapshotWhileApplying(t *testing.T) {
+	n := newTestFollower()
+	snapshot := types.Snapshot{Index: 100, Term: 2, Data: []byte("0123")}
+
+	// 正在应用日志时收完的快照先保留
+	n.queue.applying = true
+	stepSnapshotChunk(t, n, snapshot.Chunk(0, 4))
+	assert.Equal(t, types.Event{}, findEvent(n.Ready(), types.InstallSnapshotReq))
+	assert.NotNil(t, n.recvSnapshot)
+
+	// 应用完成后下次发起同步时安装
+	n.queue.applying = false
+	n.syncing = false
+	n.sendSyncReq()
+	install := findEvent(n.Ready(), types.InstallSnapshotReq)
+	assert.Equal(t, snapshot.Data, install.Snapshot.Data)
+	assert.True(t, n.installingSnapshot)
+}
+
+func newTestFollower() *Node {
+	n := NewNode(0, types.RaftState{}, NewOptions(WithKey("test"), WithNodeId(2), WithReplicas([]uint64{1, 2})))
+	n.BecomeFollower(2, 1)
+	n.Ready()
+	return n
+}
+
+func stepSnapshotChunk(t *testing.T, n *Node, chunk types.Snapshot) {
+	err := n.Step(types.Event{
+		Type:        types.SyncResp,
+		From:        1,
+		To:          2,
+		Term:        2,
+		Reason:      types.ReasonSnapshot,
+		Snapshot:    chunk,
+		SyncVersion: types.SyncVersionTermTruncate,
+	})
+	assert.NoError(t, err)
+}
+
+// 模拟存储保存完快照块后的响应
+func stepInstallSnapshotResp(t *testing.T, n *Node, chunk types.Snapshot) {
+	err := n.Step(types.Event{
+		Type:   types.InstallSnapshotResp,
+		Reason: types.ReasonOk,
+		Snapshot: types.Snapshot{
+			Index:          chunk.Index,
+			Term:           chunk.Term,
+			TermStartIndex: chunk.TermStartIndex,
+			Offset:         chunk.Offset + uint64(len(chunk.Data)),
+			Total:          chunk.Total,
+		},
+	})
+	assert.NoError(t, err)
+}
+
+func findEvent(events []types.Event, tp types.EventType) types.Event {
+	for _, e := range events {
+		if e.Type == tp {
+			return e
+		}
+	}
+	return types.Event{}
+}
@@ -3,6 +3,7 @@ package raft
 import (
 	"github.com/WuKongIM/WuKongIM/pkg/raft/track"
 	"github.com/WuKongIM/WuKongIM/pkg/raft/types"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
 	"go.uber.org/zap"
 )
 
@@ -14,10 +15,17 @@ func (n *Node) Step(e types.Event) error {
 		n.Info("received event with lower term", zap.Uint32("term", e.Term), zap.Uint32("currentTerm", n.cfg.Term), zap.Uint64("from", e.From), zap.Uint64("to", e.To), zap.String("type", e.Type.String()))
 		return nil
 	case e.Term > n.cfg.Term: // 高于当前任期
+		if (e.Type == types.VoteReq || e.Type == types.PreVoteReq) && e.Reason != types.ReasonLeaderTransfer && n.inLease() {
+			// 领导租约内忽略更高任期的投票请求，避免分区恢复的节点打断正常的领导
+			n.Info("ignore vote request in lease", zap.Uint64("from", e.From), zap.Uint32("term", e.Term), zap.Uint32("currentTerm", n.cfg.Term), zap.Uint64("leader", n.cfg.Leader), zap.String("type", e.Type.String()))
+			return nil
+		}
 		if n.cfg.Term > 0 {
 			n.Info("received event with higher term", zap.Uint32("term", n.cfg.Term), zap.Uint32("currentTerm", e.Term), zap.Uint64("from", e.From), zap.Uint64("to", e.To), zap.String("type", e.Type.String()))
 		}
-		if e.Type == types.Ping || e.Type == types.SyncResp {
+		if e.Type == types.PreVoteReq || (e.Type == types.PreVoteResp && e.Reason == types.ReasonOk) {
+			// 预投票请求和同意的预投票响应携带的是下一次选举的任期，不改变本节点的任期
+		} else if e.Type == types.Ping || e.Type == types.SyncResp {
 			if n.cfg.Role == types.RoleLearner {
 				n.BecomeLearner(e.Term, e.From)
 			} else {
@@ -38,7 +46,18 @@ func (n *Node) Step(e types.Event) error {
 	case types.ConfChange: // 配置变更
 		n.switchConfig(e.Config)
 	case types.Campaign:
-		n.campaign()
+		n.campaign(false)
+	case types.PreVoteReq: // 预投票请求
+		to := e.From
+		if e.From == n.opts.NodeId {
+			to = types.LocalNode
+		}
+		if n.canPreVote(e) {
+			n.sendPreVoteResp(to, e.Term, types.ReasonOk)
+		} else {
+			n.Info("reject pre-vote", zap.Uint64("from", e.From), zap.Uint32("term", e.Term), zap.Uint64("index", e.Logs[0].Index))
+			n.sendPreVoteResp(to, n.cfg.Term, types.ReasonError)
+		}
 	case types.VoteReq: // 投票请求
 		if n.canVote(e) {
 			if e.From == n.opts.NodeId {
@@ -69,6 +88,30 @@ func (n *Node) Step(e types.Event) error {
 		} else {
 			n.queue.applying = false
 		}
+	case types.CompactResp: // 压缩日志返回
+		n.compacting = false
+		if e.Reason == types.ReasonOk && e.Index > n.compactedIndex {
+			n.compactedIndex = e.Index
+		}
+	case types.InstallSnapshotResp: // 安装快照返回
+		n.installingSnapshot = false
+		if e.Reason == types.ReasonOk && !e.Snapshot.IsLastChunk() { // 快照块已保存，请求下一块
+			if n.recvSnapshot != nil && n.recvSnapshot.Index == e.Snapshot.Index {
+				n.recvSnapshot.Offset = e.Snapshot.Offset
+			}
+		} else if e.Reason == types.ReasonOk {
+			n.recvSnapshot = nil
+			n.needSnapshot = false
+			n.queue.resetTo(e.Snapshot.Index)
+			n.compactedIndex = e.Snapshot.Index
+			n.updateLastTermStartIndex(e.Snapshot.Term, e.Snapshot.TermStartIndex)
+			n.Info("install snapshot success", zap.Uint64("index", e.Snapshot.Index), zap.Uint32("term", e.Snapshot.Term))
+		} else {
+			// 保存或安装失败，重新请求整个快照
+			n.recvSnapshot = nil
+		}
+		n.sendSyncReq()
+		n.advance()
 	default:
 		if n.stepFunc != nil {
 			return n.stepFunc(e)
@@ -110,8 +153,13 @@ func (n *Node) stepLeader(e types.Event) error {
 		// 根据需要切换角色
 		n.roleSwitchIfNeed(e)
 
+		// 转让目标的日志追上了，移交领导权
+		if n.leadTransferee == e.From && e.Index >= n.queue.lastLogIndex+1 {
+			n.handOverLeader()
+		}
+
 		// 无数据可同步
-		if (e.Reason == types.ReasonOnlySync && e.Index > n.queue.storedIndex) || n.queue.storedIndex == 0 {
+		if (e.Reason == types.ReasonOnlySync && e.Index > n.queue.storedIndex) || (n.queue.storedIndex == 0 && e.LastLogTerm == 0) {
 
 			var speed types.Speed
 			if n.opts.AutoSuspend {
@@ -162,7 +210,13 @@ func (n *Node) stepLeader(e types.Event) error {
 			e.Logs[i].Record.Add(track.PositionSyncResp)
 		}
 
-		n.sendSyncResp(e.To, e.Index, e.Logs, e.Reason, types.SpeedFast)
+		if e.Reason == types.ReasonSnapshot { // 副本需要的日志已被压缩，发送快照
+			n.sendSnapshotSyncResp(e.To, e.Index, e.Snapshot)
+		} else if e.Reason == types.ReasonTruncate {
+			n.sendTruncateSyncResp(e.To, e.Index, e.LastLogTerm)
+		} else {
+			n.sendSyncResp(e.To, e.Index, e.Logs, e.Reason, types.SpeedFast)
+		}
 		n.advance()
 
 		// 角色转换
@@ -171,6 +225,10 @@ func (n *Node) stepLeader(e types.Event) error {
 		types.FollowerToLeaderResp:
 
 		n.stopPropose = false
+		if n.leadTransferee == e.From {
+			n.leadTransferee = None
+			n.leadTransferElapsed = 0
+		}
 		syncInfo := n.replicaSync[e.From]
 		if syncInfo == nil {
 			n.Error("role switch error,syncInfo not exist", zap.Uint64("from", e.From), zap.Uint64("to", e.To), zap.String("type", e.Type.String()))
@@ -180,6 +238,8 @@ func (n *Node) stepLeader(e types.Event) error {
 
 	case types.ConfigReq: // 配置请求
 		n.sendConfigResp(e.From, n.cfg.Clone())
+	case types.TransferLeaderReq: // 领导权转让
+		return n.transferLeader(e.From)
 	}
 
 	return nil
@@ -194,7 +254,9 @@ func (n *Node) stepFollower(e types.Event) error {
 		if n.cfg.Leader == None {
 			n.BecomeFollower(e.Term, e.From)
 		}
-		n.updateFollowCommittedIndex(e.CommittedIndex) // 更新提交索引
+		if n.onlySync { // 日志经过领导确认后才能更新提交索引，否则本地未截断的日志可能和领导的不一致
+			n.updateFollowCommittedIndex(e.CommittedIndex) // 更新提交索引
+		}
 		n.idleTick = 0
 		// 如果领导的配置版本大于本地配置版本，那么请求配置
 		if e.ConfigVersion > n.cfg.Version {
@@ -213,7 +275,9 @@ func (n *Node) stepFollower(e types.Event) error {
 		n.idleTick = 0
 		n.syncRespTimeoutTick = 0
 		n.syncing = false
-		if !n.onlySync {
+		if !n.onlySync && (e.Reason == types.ReasonOk || e.SyncVersion < types.SyncVersionTermTruncate) {
+			// 领导已确认过本节点的日志不需要截断，后续只需同步。
+			// 旧版本领导不会按任期协商裁剪，和以前一样收到第一个响应后就只同步，否则会反复收到同样的裁剪响应
 			n.onlySync = true
 		}
 		if e.Reason == types.ReasonOk {
@@ -241,9 +305,17 @@ func (n *Node) stepFollower(e types.Event) error {
 			if n.truncating {
 				return nil
 			}
+			index := n.truncateIndex(e)
+			if index == 0 {
+				// 日志从第一条开始就和领导不一致，日志不能截断到0，请求领导的快照覆盖本地日志
+				n.needSnapshot = true
+				return nil
+			}
 			n.truncating = true
-			n.sendTruncateReq(e.Index)
+			n.sendTruncateReq(index)
 			n.advance()
+		} else if e.Reason == types.ReasonSnapshot {
+			n.recvSnapshotChunk(e.Snapshot)
 		} else {
 			n.Error("sync error", zap.Uint64("from", e.From), zap.Uint64("index", e.Index), zap.String("reason", e.Reason.String()))
 		}
@@ -253,6 +325,9 @@ func (n *Node) stepFollower(e types.Event) error {
 			if e.Index < n.queue.lastLogIndex {
 				n.queue.truncateLogTo(e.Index)
 			}
+			if e.TermStartIndexInfo != nil && e.TermStartIndexInfo.Term > 0 { // 截断后最后一条日志的任期
+				n.updateLastTermStartIndex(e.TermStartIndexInfo.Term, e.TermStartIndexInfo.Index)
+			}
 			n.sendSyncReq()
 			n.advance()
 		}
@@ -271,7 +346,11 @@ func (n *Node) stepFollower(e types.Event) error {
 		// 切换配置
 		e.Config.Term = n.cfg.Term
 		n.switchConfig(e.Config)
-
+	case types.TimeoutNow: // 领导权转让给本节点，立即发起选举
+		if n.opts.ElectionOn && e.From == n.cfg.Leader {
+			n.Info("received timeout now, campaign", zap.Uint64("from", e.From), zap.Uint32("term", n.cfg.Term))
+			n.campaign(true)
+		}
 	}
 	return nil
 }
@@ -289,6 +368,21 @@ func (n *Node) stepCandidate(e types.Event) error {
 	return nil
 }
 
+func (n *Node) stepPreCandidate(e types.Event) error {
+	switch e.Type {
+	case types.Propose:
+		n.Foucs("pre-candidate not allow propose", zap.String("key", n.Key()), zap.Int("logs", len(e.Logs)))
+	case types.Ping, types.NotifySync: // 领导还在，放弃预选举
+		n.BecomeFollower(e.Term, e.From)
+	case types.PreVoteResp: // 预投票返回
+		if e.From != n.opts.NodeId {
+			n.Info("received pre-vote response", zap.Uint8("reason", e.Reason.Uint8()), zap.Uint64("from", e.From), zap.Uint32("term", e.Term))
+		}
+		n.pollPreVote(e)
+	}
+	return nil
+}
+
 func (n *Node) stepLearner(e types.Event) error {
 	switch e.Type {
 	case types.Propose:
@@ -312,7 +406,9 @@ func (n *Node) stepLearner(e types.Event) error {
 		n.idleTick = 0
 		n.syncRespTimeoutTick = 0
 		n.syncing = false
-		if !n.onlySync {
+		if !n.onlySync && (e.Reason == types.ReasonOk || e.SyncVersion < types.SyncVersionTermTruncate) {
+			// 领导已确认过本节点的日志不需要截断，后续只需同步。
+			// 旧版本领导不会按任期协商裁剪，和以前一样收到第一个响应后就只同步，否则会反复收到同样的裁剪响应
 			n.onlySync = true
 		}
 
@@ -338,9 +434,17 @@ func (n *Node) stepLearner(e types.Event) error {
 			if n.truncating {
 				return nil
 			}
+			index := n.truncateIndex(e)
+			if index == 0 {
+				// 日志从第一条开始就和领导不一致，日志不能截断到0，请求领导的快照覆盖本地日志
+				n.needSnapshot = true
+				return nil
+			}
 			n.truncating = true
-			n.sendTruncateReq(e.Index)
+			n.sendTruncateReq(index)
 			n.advance()
+		} else if e.Reason == types.ReasonSnapshot {
+			n.recvSnapshotChunk(e.Snapshot)
 		} else {
 			n.Error("sync error", zap.Uint64("from", e.From), zap.Uint64("index", e.Index), zap.String("reason", e.Reason.String()))
 		}
@@ -350,6 +454,9 @@ func (n *Node) stepLearner(e types.Event) error {
 			if e.Index < n.queue.lastLogIndex {
 				n.queue.truncateLogTo(e.Index)
 			}
+			if e.TermStartIndexInfo != nil && e.TermStartIndexInfo.Term > 0 { // 截断后最后一条日志的任期
+				n.updateLastTermStartIndex(e.TermStartIndexInfo.Term, e.TermStartIndexInfo.Index)
+			}
 		}
 	case types.StoreResp: // 异步存储日志返回
 		n.queue.appending = false
@@ -369,6 +476,47 @@ func (n *Node) stepLearner(e types.Event) error {
 	return nil
 }
 
+// 接收领导发过来的快照块，每块收到后马上交给存储保存，最后一块保存完后安装快照
+func (n *Node) recvSnapshotChunk(chunk types.Snapshot) {
+	if n.installingSnapshot {
+		return
+	}
+	if chunk.Offset == 0 {
+		n.recvSnapshot = &types.Snapshot{
+			Index:          chunk.Index,
+			Term:           chunk.Term,
+			TermStartIndex: chunk.TermStartIndex,
+			Total:          chunk.Total,
+		}
+	} else if n.recvSnapshot == nil || n.recvSnapshot.Index != chunk.Index || n.recvSnapshot.Offset != chunk.Offset {
+		// 块不连续（领导切换或者领导重新生成了快照），重新请求整个快照
+		n.Warn("snapshot chunk is discontinuous", zap.Uint64("index", chunk.Index), zap.Uint64("offset", chunk.Offset))
+		n.recvSnapshot = nil
+		n.needSnapshot = true
+		return
+	}
+	n.recvSnapshot.Data = chunk.Data
+	n.installSnapshot(*n.recvSnapshot)
+}
+
+// 把收到的快照块交给存储，存储保存完最后一块后安装快照
+func (n *Node) installSnapshot(chunk types.Snapshot) {
+	if n.installingSnapshot {
+		return
+	}
+	// 正在存储或应用日志时不能安装快照，最后一块先保留，下次发起同步时再安装
+	if chunk.IsLastChunk() {
+		if n.queue.appending || n.queue.applying {
+			return
+		}
+		n.Info("install snapshot", zap.Uint64("index", chunk.Index), zap.Uint32("term", chunk.Term), zap.Uint64("lastLogIndex", n.queue.lastLogIndex), zap.Uint64("size", chunk.Total))
+	}
+	n.recvSnapshot.Data = nil
+	n.installingSnapshot = true
+	n.sendInstallSnapshotReq(chunk)
+	n.advance()
+}
+
 // 统计投票
 func (n *Node) poll(e types.Event) {
 	n.votes[e.From] = e.Reason == types.ReasonOk
@@ -390,6 +538,24 @@ func (n *Node) poll(e types.Event) {
 	}
 }
 
+// 统计预投票，获得法定数量的同意票后发起真正的选举
+func (n *Node) pollPreVote(e types.Event) {
+	n.votes[e.From] = e.Reason == types.ReasonOk
+	var granted, rejected int
+	for _, v := range n.votes {
+		if v {
+			granted++
+		} else {
+			rejected++
+		}
+	}
+	if granted >= n.quorum() {
+		n.campaign(false)
+	} else if rejected >= n.quorum() {
+		n.BecomeFollower(n.cfg.Term, None)
+	}
+}
+
 // 合法投票数
 func (n *Node) quorum() int {
 	return len(n.cfg.Replicas)/2 + 1 //  n.cfg.Replicas 包含本节点
@@ -422,6 +588,20 @@ func (n *Node) canVote(e types.Event) bool {
 	return true
 }
 
+// 是否可以预投票，预投票不记录投票，只检查任期和日志
+func (n *Node) canPreVote(e types.Event) bool {
+	if e.Term <= n.cfg.Term {
+		return false
+	}
+	lastLogIndex := n.queue.lastLogIndex
+	lastLogTerm := n.lastTermStartIndex.Term
+	candidateLog := e.Logs[0]
+	if candidateLog.Term < lastLogTerm || candidateLog.Term == lastLogTerm && candidateLog.Index < lastLogIndex {
+		return false
+	}
+	return true
+}
+
 // 更新同步信息
 func (n *Node) updateSyncInfo(e types.Event) {
 	syncInfo := n.replicaSync[e.From]
@@ -430,8 +610,12 @@ func (n *Node) updateSyncInfo(e types.Event) {
 		n.replicaSync[e.From] = syncInfo
 	}
 	syncInfo.SyncTick = 0
+	syncInfo.active = true
 	syncInfo.LastSyncIndex = e.Index
-	syncInfo.StoredIndex = e.StoredIndex
+	if e.Reason == types.ReasonOnlySync {
+		// 副本的日志经过领导确认后，存储下标才能用于计算提交下标（未确认的日志可能需要截断）
+		syncInfo.StoredIndex = e.StoredIndex
+	}
 }
 
 // 更新领导的提交索引
@@ -496,6 +680,11 @@ func (n *Node) committedIndexForLeader() uint64 {
 		}
 	}
 	if newCommitted > committed {
+		// 只能通过提交当前任期的日志来提交之前任期的日志，之前任期的日志即使已复制到多数副本，
+		// 也可能被没有这些日志但任期更大的新领导覆盖
+		if n.lastTermStartIndex.Term != n.cfg.Term || newCommitted < n.lastTermStartIndex.Index {
+			return committed
+		}
 		return min(newCommitted, n.queue.storedIndex)
 	}
 	return committed
@@ -565,3 +754,76 @@ func (n *Node) roleSwitchIfNeed(e types.Event) {
 		}
 	}
 }
+
+// 将领导权转让给指定的副本，先停止提案等待目标副本追上日志，再移交领导权
+func (n *Node) transferLeader(to uint64) error {
+	if to == n.opts.NodeId {
+		return nil
+	}
+	if !wkutil.ArrayContainsUint64(n.cfg.Replicas, to) || n.isLearner(to) {
+		return ErrTransferNotReplica
+	}
+	if n.cfg.MigrateFrom != 0 || n.cfg.MigrateTo != 0 { // 迁移中的配置也会切换领导，不能同时转让
+		return ErrConfigMigrating
+	}
+	if n.leadTransferee != None {
+		if n.leadTransferee == to {
+			return nil
+		}
+		return ErrLeaderTransferring
+	}
+	n.Info("transfer leader", zap.Uint64("to", to), zap.Uint32("term", n.cfg.Term))
+	n.leadTransferee = to
+	n.leadTransferElapsed = 0
+	n.stopPropose = true // 停止提案，让目标副本尽快追上日志
+
+	syncInfo := n.replicaSync[to]
+	if syncInfo != nil && syncInfo.LastSyncIndex >= n.queue.lastLogIndex+1 {
+		n.handOverLeader()
+	} else {
+		n.sendNotifySync(to)
+	}
+	return nil
+}
+
+// 移交领导权给转让目标
+func (n *Node) handOverLeader() {
+	syncInfo := n.replicaSync[n.leadTransferee]
+	if syncInfo == nil {
+		syncInfo = &SyncInfo{}
+		n.replicaSync[n.leadTransferee] = syncInfo
+	}
+	if syncInfo.roleSwitching {
+		return
+	}
+	syncInfo.roleSwitching = true
+	if n.opts.ElectionOn { // 开启选举的由目标节点发起选举
+		n.sendTimeoutNow(n.leadTransferee)
+	} else { // 没有开启选举的通过配置切换领导
+		n.sendFollowToLeaderReq(n.leadTransferee)
+	}
+}
+
+// 放弃领导权转让
+func (n *Node) abortLeaderTransfer() {
+	n.Warn("leader transfer timeout, abort", zap.Uint64("to", n.leadTransferee))
+	if syncInfo := n.replicaSync[n.leadTransferee]; syncInfo != nil {
+		syncInfo.roleSwitching = false
+	}
+	n.leadTransferee = None
+	n.leadTransferElapsed = 0
+	n.stopPropose = false
+}
+
+// 副本需要裁剪到的日志下标，领导没有副本最新日志的任期时，这个任期的日志都需要裁剪掉，
+// 裁剪后副本用更早的任期再次和领导协商，直到领导确认日志不需要再裁剪
+func (n *Node) truncateIndex(e types.Event) uint64 {
+	index := e.Index
+	if e.SyncVersion < types.SyncVersionTermTruncate { // 旧版本领导的裁剪响应不带任期，按领导给的下标裁剪
+		return index
+	}
+	if e.LastLogTerm != n.lastTermStartIndex.Term && n.lastTermStartIndex.Index > 0 && index >= n.lastTermStartIndex.Index {
+		index = n.lastTermStartIndex.Index - 1
+	}
+	return index
+}
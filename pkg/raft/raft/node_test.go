@@ -0,0 +1,299 @@
+package raft
+
+import (
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/raft/types"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/stretchr/testify/assert"
+)
+
+// 之前任期的日志即使已复制到多数副本，也要等当前任期的日志复制到多数副本后才能一起提交
+func TestLeaderCommitPreviousTermLogs(t *testing.T) {
+	// 节点1有两条任期1的日志，在任期2成为领导
+	n := NewNode(1, types.RaftState{LastLogIndex: 2, LastTerm: 1}, NewOptions(WithKey("test"), WithNodeId(1), WithReplicas([]uint64{1, 2, 3})))
+	n.BecomeLeader(2)
+	n.Ready()
+
+	// 副本2已经存储了之前任期的两条日志，但不能提交
+	stepNodeSyncReq(t, n, 2, 2)
+	assert.Equal(t, uint64(0), n.queue.committedIndex)
+
+	// 当前任期的日志存储到多数副本后，之前任期的日志一起提交
+	err := n.Step(n.NewPropose([]byte("hello")))
+	assert.NoError(t, err)
+	n.Ready()
+	err = n.Step(types.Event{Type: types.StoreResp, Index: 3, Reason: types.ReasonOk})
+	assert.NoError(t, err)
+	n.Ready()
+	stepNodeSyncReq(t, n, 2, 3)
+	assert.Equal(t, uint64(3), n.queue.committedIndex)
+}
+
+// 同一任期只能投一次票，收到这个任期领导的消息后也不能清空投票记录
+func TestVoteOncePerTerm(t *testing.T) {
+	n := NewNode(0, types.RaftState{}, NewOptions(WithKey("test"), WithNodeId(1), WithReplicas([]uint64{1, 2, 3}), WithElectionOn(true)))
+	n.Ready()
+
+	stepNodeVoteReq(t, n, 2, 2)
+	resp := findNodeEvent(n.Ready(), types.VoteResp)
+	assert.Equal(t, types.ReasonOk, resp.Reason)
+
+	// 节点2成为领导后发来心跳
+	err := n.Step(types.Event{Type: types.Ping, From: 2, To: 1, Term: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), n.LeaderId())
+	n.Ready()
+
+	// 同一任期节点3的投票请求被拒绝
+	stepNodeVoteReq(t, n, 3, 2)
+	resp = findNodeEvent(n.Ready(), types.VoteResp)
+	assert.Equal(t, types.ReasonError, resp.Reason)
+
+	// 新任期可以重新投票
+	stepNodeVoteReq(t, n, 3, 3)
+	resp = findNodeEvent(n.Ready(), types.VoteResp)
+	assert.Equal(t, types.ReasonOk, resp.Reason)
+}
+
+// 领导没有副本最新日志的任期时，副本要把这个任期的日志都裁剪掉
+func TestFollowerTruncateByTerm(t *testing.T) {
+	n := newTestTruncateFollower()
+
+	// 领导没有任期3的日志，副本裁剪到任期3开始之前
+	stepNodeTruncateResp(t, n, 4, 0)
+	req := findNodeEvent(n.Ready(), types.TruncateReq)
+	assert.Equal(t, uint64(2), req.Index)
+
+	// 截断后用截断位置的任期再和领导协商
+	err := n.Step(types.Event{Type: types.TruncateResp, Index: 2, Reason: types.ReasonOk, TermStartIndexInfo: &types.TermStartIndexInfo{Term: 1, Index: 1}})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), n.LastLogIndex())
+	assert.Equal(t, uint32(1), n.LastLogTerm())
+	sync := findNodeEvent(n.Ready(), types.SyncReq)
+	assert.Equal(t, uint32(1), sync.LastLogTerm)
+	assert.Equal(t, types.ReasonUnknown, sync.Reason)
+}
+
+// 领导也有副本最新日志的任期时，按领导给的下标裁剪
+func TestFollowerTruncateInSameTerm(t *testing.T) {
+	n := newTestTruncateFollower()
+
+	stepNodeTruncateResp(t, n, 4, 3)
+	req := findNodeEvent(n.Ready(), types.TruncateReq)
+	assert.Equal(t, uint64(4), req.Index)
+}
+
+// 副本的日志经过领导确认前，不能根据领导的提交下标提交本地日志
+func TestFollowerCommitAfterLogsConfirmed(t *testing.T) {
+	n := newTestTruncateFollower()
+
+	err := n.Step(types.Event{Type: types.Ping, From: 1, To: 2, Term: 3, CommittedIndex: 5})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), n.queue.committedIndex)
+
+	// 收到裁剪响应也不算确认
+	stepNodeTruncateResp(t, n, 4, 3)
+	err = n.Step(types.Event{Type: types.Ping, From: 1, To: 2, Term: 3, CommittedIndex: 5})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), n.queue.committedIndex)
+
+	n.truncating = false
+	n.syncing = false
+	err = n.Step(types.Event{Type: types.SyncResp, From: 1, To: 2, Term: 3, Index: 6, CommittedIndex: 4, Reason: types.ReasonOk})
+	assert.NoError(t, err)
+	err = n.Step(types.Event{Type: types.Ping, From: 1, To: 2, Term: 3, CommittedIndex: 5})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(5), n.queue.committedIndex)
+}
+
+// 一次存储的日志跨两个新任期时分两次存储，保证每个任期的开始下标都被保存
+func TestFollowerStoreLogsPerTerm(t *testing.T) {
+	n := NewNode(0, types.RaftState{}, NewOptions(WithKey("test"), WithNodeId(2), WithReplicas([]uint64{1, 2})))
+	n.BecomeFollower(3, 1)
+	n.Ready()
+
+	err := n.Step(types.Event{Type: types.SyncResp, From: 1, To: 2, Term: 3, Index: 1, Reason: types.ReasonOk, Logs: []types.Log{
+		{Index: 1, Term: 1, Data: []byte("1")},
+		{Index: 2, Term: 2, Data: []byte("2")},
+		{Index: 3, Term: 2, Data: []byte("3")},
+	}})
+	assert.NoError(t, err)
+
+	req := findNodeEvent(n.Ready(), types.StoreReq)
+	assert.Equal(t, 1, len(req.Logs))
+	assert.Equal(t, &types.TermStartIndexInfo{Term: 1, Index: 1}, req.TermStartIndexInfo)
+
+	err = n.Step(types.Event{Type: types.StoreResp, Index: 1, Reason: types.ReasonOk})
+	assert.NoError(t, err)
+	req = findNodeEvent(n.Ready(), types.StoreReq)
+	assert.Equal(t, 2, len(req.Logs))
+	assert.Equal(t, &types.TermStartIndexInfo{Term: 2, Index: 2}, req.TermStartIndexInfo)
+}
+
+// 副本的日志从第一条开始就和领导不一致时不能截断到0，改为请求领导的快照
+func TestFollowerRequestSnapshotWhenFirstLogDiverges(t *testing.T) {
+	// 副本2的3条日志都是任期2的日志
+	n := NewNode(1, types.RaftState{LastLogIndex: 3, LastTerm: 2}, NewOptions(WithKey("test"), WithNodeId(2), WithReplicas([]uint64{1, 2})))
+	n.BecomeFollower(3, 1)
+	n.Ready()
+
+	// 领导没有任期2的日志
+	stepNodeTruncateResp(t, n, 2, 0)
+	events := n.Ready()
+	assert.Equal(t, types.Event{}, findNodeEvent(events, types.TruncateReq))
+
+	err := n.Step(types.Event{Type: types.NotifySync, From: 1, To: 2, Term: 3})
+	assert.NoError(t, err)
+	sync := findNodeEvent(n.Ready(), types.SyncReq)
+	assert.Equal(t, types.ReasonSnapshot, sync.Reason)
+
+	// 安装快照后恢复正常同步
+	err = n.Step(types.Event{Type: types.InstallSnapshotResp, Reason: types.ReasonOk, Snapshot: types.Snapshot{Index: 5, Term: 3, TermStartIndex: 4}})
+	assert.NoError(t, err)
+	assert.False(t, n.needSnapshot)
+	assert.Equal(t, uint64(5), n.LastLogIndex())
+	assert.Equal(t, uint32(3), n.LastLogTerm())
+}
+
+// 旧版本领导的裁剪响应不带任期，副本按领导给的下标裁剪，并且和以前一样收到响应后就只同步
+func TestFollowerTruncateWithLegacyLeader(t *testing.T) {
+	n := newTestTruncateFollower()
+
+	err := n.Step(types.Event{Type: types.SyncResp, From: 1, To: 2, Term: 3, Index: 4, Reason: types.ReasonTruncate})
+	assert.NoError(t, err)
+	req := findNodeEvent(n.Ready(), types.TruncateReq)
+	assert.Equal(t, uint64(4), req.Index)
+	assert.True(t, n.onlySync)
+}
+
+// 同步请求带上副本的协议版本，领导获取日志时按副本的版本计算裁剪位置
+func TestLeaderPassSyncVersionToGetLogs(t *testing.T) {
+	n := NewNode(1, types.RaftState{LastLogIndex: 2, LastTerm: 1}, NewOptions(WithKey("test"), WithNodeId(1), WithReplicas([]uint64{1, 2, 3})))
+	n.BecomeLeader(2)
+	n.Ready()
+
+	for _, version := range []uint8{0, types.SyncVersionTermTruncate} {
+		err := n.Step(types.Event{Type: types.SyncReq, From: 2, To: 1, Term: 2, Index: 1, LastLogTerm: 1, SyncVersion: version})
+		assert.NoError(t, err)
+		req := findNodeEvent(n.Ready(), types.GetLogsReq)
+		assert.Equal(t, types.GetLogsReq, req.Type)
+		assert.Equal(t, version, req.SyncVersion)
+		n.replicaSync[2].GetingLogs = false
+	}
+
+	n.sendTruncateSyncResp(2, 1, 0)
+	resp := findNodeEvent(n.Ready(), types.SyncResp)
+	assert.Equal(t, types.SyncVersionTermTruncate, resp.SyncVersion)
+}
+
+// 旧版本副本不会按任期再次协商，领导按旧的方式计算裁剪位置
+func TestTrunctLogIndexForLegacyFollower(t *testing.T) {
+	// 领导的日志1-2是任期1的日志，3-4是任期2的日志
+	storage := NewMemoryStorage()
+	err := storage.AppendLogs([]types.Log{{Index: 1, Term: 1}, {Index: 2, Term: 1}}, &types.TermStartIndexInfo{Term: 1, Index: 1})
+	assert.NoError(t, err)
+	err = storage.AppendLogs([]types.Log{{Index: 3, Term: 2}, {Index: 4, Term: 2}}, &types.TermStartIndexInfo{Term: 2, Index: 3})
+	assert.NoError(t, err)
+	log := wklog.NewWKLog("test")
+
+	// 副本有领导没有的任期3的日志
+	e := types.Event{From: 2, Index: 6, LastLogTerm: 3, StoredIndex: 4, SyncVersion: types.SyncVersionTermTruncate}
+	index, reason := getTrunctLogIndex(storage, log, e, 2)
+	assert.Equal(t, types.ReasonOk, reason)
+	assert.Equal(t, uint64(4), index)
+
+	e.SyncVersion = 0
+	index, reason = getTrunctLogIndex(storage, log, e, 2)
+	assert.Equal(t, types.ReasonOk, reason)
+	assert.Equal(t, uint64(2), index)
+
+	// 领导从第一条日志开始就是任期2的日志，副本的日志都是任期1的日志
+	storage = NewMemoryStorage()
+	err = storage.AppendLogs([]types.Log{{Index: 1, Term: 2}, {Index: 2, Term: 2}}, &types.TermStartIndexInfo{Term: 2, Index: 1})
+	assert.NoError(t, err)
+
+	e = types.Event{From: 2, Index: 3, LastLogTerm: 1, StoredIndex: 2, SyncVersion: types.SyncVersionTermTruncate}
+	_, reason = getTrunctLogIndex(storage, log, e, 2)
+	assert.Equal(t, types.ReasonSnapshot, reason)
+
+	// 旧版本副本不能按新的方式分块安装快照
+	e.SyncVersion = 0
+	index, reason = getTrunctLogIndex(storage, log, e, 2)
+	assert.Equal(t, types.ReasonOk, reason)
+	assert.Equal(t, uint64(0), index)
+}
+
+// 副本2有5条日志，1-2是任期1的日志，3-5是任期3的日志
+func newTestTruncateFollower() *Node {
+	n := NewNode(3, types.RaftState{LastLogIndex: 5, LastTerm: 3}, NewOptions(WithKey("test"), WithNodeId(2), WithReplicas([]uint64{1, 2})))
+	n.BecomeFollower(3, 1)
+	n.Ready()
+	return n
+}
+
+func stepNodeTruncateResp(t *testing.T, n *Node, index uint64, lastLogTerm uint32) {
+	err := n.Step(types.Event{
+		Type:        types.SyncResp,
+		From:        1,
+		To:          n.opts.NodeId,
+		Term:        n.cfg.Term,
+		Index:       index,
+		LastLogTerm: lastLogTerm,
+		Reason:      types.ReasonTruncate,
+		SyncVersion: types.SyncVersionTermTruncate,
+	})
+	assert.NoError(t, err)
+}
+
+func stepNodeVoteReq(t *testing.T, n *Node, from uint64, term uint32) {
+	err := n.Step(types.Event{
+		Type: types.VoteReq,
+		From: from,
+		To:   n.opts.NodeId,
+		Term: term,
+		Logs: []types.Log{{Index: n.queue.lastLogIndex, Term: n.lastTermStartIndex.Term}},
+	})
+	assert.NoError(t, err)
+}
+
+func findNodeEvent(events []types.Event, tp types.EventType) types.Event {
+	for _, e := range events {
+		if e.Type == tp {
+			return e
+		}
+	}
+	return types.Event{}
+}
+
+// 副本from已存储到storedIndex，向领导发起同步
+func stepNodeSyncReq(t *testing.T, n *Node, from uint64, storedIndex uint64) {
+	err := n.Step(types.Event{
+		Type:        types.SyncReq,
+		From:        from,
+		To:          n.opts.NodeId,
+		Term:        n.cfg.Term,
+		Index:       storedIndex + 1,
+		StoredIndex: storedIndex + 1,
+		LastLogTerm: n.lastTermStartIndex.Term,
+		Reason:      types.ReasonOnlySync,
+	})
+	assert.NoError(t, err)
+}
+
+// 重启后恢复保存的任期和投票记录，同一任期不能再投给别的节点
+func TestNodeRestoreTermAndVote(t *testing.T) {
+	n := NewNode(0, types.RaftState{Term: 2, VoteFor: 2}, NewOptions(WithKey("test"), WithNodeId(1), WithReplicas([]uint64{1, 2, 3}), WithElectionOn(true)))
+	n.Ready()
+	assert.Equal(t, uint32(2), n.Term())
+	assert.Equal(t, uint64(2), n.VoteFor())
+
+	stepNodeVoteReq(t, n, 3, 2)
+	resp := findNodeEvent(n.Ready(), types.VoteResp)
+	assert.Equal(t, types.ReasonError, resp.Reason)
+
+	// 已投过票的节点可以再次得到投票
+	stepNodeVoteReq(t, n, 2, 2)
+	resp = findNodeEvent(n.Ready(), types.VoteResp)
+	assert.Equal(t, types.ReasonOk, resp.Reason)
+}
@@ -22,6 +22,25 @@ func (n *Node) Tick() {
 
 func (n *Node) tickLeader() {
 	n.tickHeartbeat()
+
+	if n.opts.ElectionOn && n.opts.CheckQuorum {
+		n.electionElapsed++
+		if n.electionElapsed >= n.opts.ElectionInterval {
+			n.electionElapsed = 0
+			if !n.quorumActive() { // 一个选举周期内联系不上法定数量的副本，退为追随者
+				n.Warn("leader can not reach quorum, step down", zap.Uint32("term", n.cfg.Term))
+				n.BecomeFollower(n.cfg.Term, None)
+				return
+			}
+		}
+	}
+
+	if n.leadTransferee != None {
+		n.leadTransferElapsed++
+		if n.leadTransferElapsed >= n.opts.LeaderTransferTimeoutTick {
+			n.abortLeaderTransfer()
+		}
+	}
 }
 
 func (n *Node) tickFollower() {
@@ -71,7 +90,11 @@ func (n *Node) tickElection() {
 	n.electionElapsed++
 	if n.pastElectionTimeout() {
 		n.electionElapsed = 0
-		n.campaign()
+		if n.opts.PreVote {
+			n.preCampaign()
+		} else {
+			n.campaign(false)
+		}
 	}
 }
 
@@ -124,24 +147,73 @@ func (n *Node) pastElectionTimeout() bool {
 
 // 重置随机选举超时时间
 func (n *Node) resetRandomizedElectionTimeout() {
+	if n.opts.Rand != nil {
+		n.randomizedElectionTimeout = n.opts.ElectionInterval + n.opts.Rand.Intn(n.opts.ElectionInterval)
+		return
+	}
 	n.randomizedElectionTimeout = n.opts.ElectionInterval + globalRand.Intn(n.opts.ElectionInterval)
 }
 
-// 开始选举
-func (n *Node) campaign() {
+// 开始选举，transfer为true表示是领导权转让发起的选举，投票节点不检查领导租约
+func (n *Node) campaign(transfer bool) {
 	if n.IsLeader() {
 		// 如果当前是领导，先变成follower
 		n.BecomeFollower(n.cfg.Term, 0)
 	} else {
+		reason := types.ReasonOk
+		if transfer {
+			reason = types.ReasonLeaderTransfer
+		}
 		n.BecomeCandidate()
 		for _, nodeId := range n.cfg.Replicas {
 			if nodeId == n.opts.NodeId {
 				// 自己给自己投一票
-				n.sendVoteReq(types.LocalNode)
+				n.sendVoteReq(types.LocalNode, reason)
 				continue
 			}
 			n.Info("sent vote request", zap.Uint64("from", n.opts.NodeId), zap.Uint64("to", nodeId), zap.Uint32("term", n.cfg.Term))
-			n.sendVoteReq(nodeId)
+			n.sendVoteReq(nodeId, reason)
+		}
+	}
+}
+
+// 开始预选举，确认能获得法定数量的选票后才真正发起选举
+func (n *Node) preCampaign() {
+	if n.IsLeader() {
+		return
+	}
+	n.BecomePreCandidate()
+	for _, nodeId := range n.cfg.Replicas {
+		if nodeId == n.opts.NodeId {
+			n.sendPreVoteReq(types.LocalNode)
+			continue
+		}
+		n.Info("sent pre-vote request", zap.Uint64("from", n.opts.NodeId), zap.Uint64("to", nodeId), zap.Uint32("term", n.cfg.Term+1))
+		n.sendPreVoteReq(nodeId)
+	}
+}
+
+// 是否在领导租约内（最近一个选举周期内收到过领导的消息）
+func (n *Node) inLease() bool {
+	return n.opts.CheckQuorum && n.cfg.Leader != None && n.electionElapsed < n.opts.ElectionInterval
+}
+
+// 最近一个选举周期内是否有法定数量的副本（包括自己）和领导保持同步，检查后重置副本的活跃状态
+func (n *Node) quorumActive() bool {
+	active := 0
+	for _, replicaId := range n.cfg.Replicas {
+		if replicaId == n.opts.NodeId {
+			active++
+			continue
+		}
+		syncInfo := n.replicaSync[replicaId]
+		if syncInfo == nil {
+			continue
+		}
+		if syncInfo.active {
+			active++
 		}
+		syncInfo.active = false
 	}
+	return active >= n.quorum()
 }
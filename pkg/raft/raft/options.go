@@ -1,6 +1,7 @@
 package raft
 
 import (
+	"math/rand"
 	"time"
 )
 
@@ -60,6 +61,25 @@ type Options struct {
 
 	// 空闲多久后销毁
 	DestoryAfterIdleTick int
+
+	// CompactLogThreshold 已应用但未压缩的日志数量超过此值时压缩日志，0表示不压缩
+	CompactLogThreshold uint64
+	// CompactLogRetain 压缩日志时保留最近已应用的日志数量，让落后不多的副本可以直接同步日志而不用安装快照
+	CompactLogRetain uint64
+	// MaxSnapshotChunkSize 发送快照时每块数据的最大大小 单位byte
+	MaxSnapshotChunkSize uint64
+
+	// LeaderTransferTimeoutTick 领导权转让超时tick次数，超过此tick数转让还未完成则放弃转让
+	LeaderTransferTimeoutTick int
+
+	// PreVote 是否开启预投票，开启后选举超时的节点先确认能赢得选举再增加任期，避免分区恢复的节点打断正常的领导（需要开启选举）
+	PreVote bool
+	// CheckQuorum 是否开启法定数检查，开启后领导在一个选举周期内联系不上法定数量的副本则退为追随者，
+	// 追随者在领导租约内（一个选举周期内收到过领导的消息）拒绝投票（需要开启选举）
+	CheckQuorum bool
+
+	// Rand 随机选举超时使用的随机数生成器，为空则使用全局随机数（确定性模拟测试时指定种子）
+	Rand *rand.Rand
 }
 
 func NewOptions(opt ...Option) *Options {
@@ -80,6 +100,10 @@ func NewOptions(opt ...Option) *Options {
 		AutoSuspend:                false,
 		AutoDestory:                false,
 		DestoryAfterIdleTick:       10 * 60 * 30, // 如果TickInterval是100ms, 那么10 * 60 * 30这个值是30分钟，具体时间根据TickInterval来定
+		CompactLogThreshold:        0,
+		CompactLogRetain:           1000,
+		MaxSnapshotChunkSize:       1024 * 1024 * 4,
+		LeaderTransferTimeoutTick:  50,
 	}
 
 	for _, o := range opt {
@@ -216,3 +240,45 @@ func WithAutoDestory(autoDestory bool) Option {
 		opts.AutoDestory = autoDestory
 	}
 }
+
+func WithCompactLogThreshold(threshold uint64) Option {
+	return func(opts *Options) {
+		opts.CompactLogThreshold = threshold
+	}
+}
+
+func WithCompactLogRetain(retain uint64) Option {
+	return func(opts *Options) {
+		opts.CompactLogRetain = retain
+	}
+}
+
+func WithMaxSnapshotChunkSize(size uint64) Option {
+	return func(opts *Options) {
+		opts.MaxSnapshotChunkSize = size
+	}
+}
+
+func WithLeaderTransferTimeoutTick(tick int) Option {
+	return func(opts *Options) {
+		opts.LeaderTransferTimeoutTick = tick
+	}
+}
+
+func WithPreVote(preVote bool) Option {
+	return func(opts *Options) {
+		opts.PreVote = preVote
+	}
+}
+
+func WithCheckQuorum(checkQuorum bool) Option {
+	return func(opts *Options) {
+		opts.CheckQuorum = checkQuorum
+	}
+}
+
+func WithRand(r *rand.Rand) Option {
+	return func(opts *Options) {
+		opts.Rand = r
+	}
+}
@@ -117,6 +117,7 @@ func (r *queue) truncateLogTo(logIndex uint64) {
 	}
 	if logIndex < r.storedIndex {
 		r.storedIndex = logIndex
+		r.logs = r.logs[:0] // 未存储的日志都在截断位置之后
 	} else {
 		r.logs = r.logs[:logIndex-r.storedIndex]
 	}
@@ -124,3 +125,14 @@ func (r *queue) truncateLogTo(logIndex uint64) {
 	r.lastLogIndex = logIndex
 
 }
+
+// resetTo 安装快照后重置日志队列，快照下标及之前的日志都视为已存储、已提交、已应用
+func (r *queue) resetTo(logIndex uint64) {
+	r.logs = r.logs[:0]
+	r.storedIndex = logIndex
+	r.lastLogIndex = logIndex
+	r.committedIndex = logIndex
+	r.appliedIndex = logIndex
+	r.appending = false
+	r.applying = false
+}
@@ -50,3 +50,41 @@ func TestQueueStorageToOutOfBound(t *testing.T) {
 		q.storeTo(3)
 	})
 }
+
+func TestQueueResetTo(t *testing.T) {
+	q := newQueue("test", 0, 0)
+
+	log1 := types.Log{Id: 1, Index: 1, Term: 1, Data: []byte("log1"), Time: time.Now()}
+	q.append(log1)
+
+	q.resetTo(100)
+
+	assert.Equal(t, 0, len(q.logs))
+	assert.Equal(t, uint64(100), q.lastLogIndex)
+	assert.Equal(t, uint64(100), q.storedIndex)
+	assert.Equal(t, uint64(100), q.committedIndex)
+	assert.Equal(t, uint64(100), q.appliedIndex)
+
+	log101 := types.Log{Id: 101, Index: 101, Term: 2, Data: []byte("log101"), Time: time.Now()}
+	q.append(log101)
+	assert.Equal(t, uint64(101), q.lastLogIndex)
+}
+
+func TestQueueTruncateBeforeStored(t *testing.T) {
+	q := newQueue("test", 0, 0)
+
+	log1 := types.Log{Id: 1, Index: 1, Term: 1, Data: []byte("log1"), Time: time.Now()}
+	log2 := types.Log{Id: 2, Index: 2, Term: 1, Data: []byte("log2"), Time: time.Now()}
+	log3 := types.Log{Id: 3, Index: 3, Term: 1, Data: []byte("log3"), Time: time.Now()}
+	log4 := types.Log{Id: 4, Index: 4, Term: 1, Data: []byte("log4"), Time: time.Now()}
+
+	q.append(log1, log2, log3, log4)
+	q.storeTo(3)
+
+	// 截断到已存储的位置之前，未存储的日志也要丢弃
+	q.truncateLogTo(2)
+
+	assert.Equal(t, uint64(2), q.lastLogIndex)
+	assert.Equal(t, uint64(2), q.storedIndex)
+	assert.Equal(t, 0, len(q.logs))
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
 	"sync"
 	"time"
 
@@ -32,6 +33,11 @@ type Raft struct {
 	fowardProposeWait wt.Wait // 转发提按给领导等待领导的回应
 
 	pool *ants.Pool
+
+	snapshotCache *types.SnapshotCache // 正在发给副本的快照
+
+	savedTerm    uint32 // 已保存的任期
+	savedVoteFor uint64 // 已保存的投票记录
 }
 
 func New(opts *Options) *Raft {
@@ -62,6 +68,9 @@ func New(opts *Options) *Raft {
 		wait:              newWait("raft"),
 		fowardProposeWait: wt.New(),
 		pool:              pool,
+		snapshotCache:     types.NewSnapshotCache(),
+		savedTerm:         raftState.Term,
+		savedVoteFor:      raftState.VoteFor,
 	}
 	opts.Advance = r.advance
 
@@ -154,6 +163,10 @@ func (r *Raft) LeaderId() uint64 {
 	return r.node.LeaderId()
 }
 
+func (r *Raft) Term() uint32 {
+	return r.node.Term()
+}
+
 func (r *Raft) GetReplicaLastLogIndex(replicaId uint64) uint64 {
 	return r.node.GetReplicaLastLogIndex(replicaId)
 }
@@ -162,6 +175,32 @@ func (r *Raft) Options() *Options {
 	return r.opts
 }
 
+// TransferLeader 将领导权转让给指定的副本，等待目标副本成为领导
+func (r *Raft) TransferLeader(ctx context.Context, to uint64) error {
+	if !r.IsLeader() {
+		return ErrNotLeader
+	}
+	err := r.StepWait(ctx, types.Event{
+		Type: types.TransferLeaderReq,
+		From: to,
+	})
+	if err != nil {
+		return err
+	}
+	for {
+		if r.LeaderId() == to {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-r.stopper.ShouldStop():
+			return ErrStopped
+		case <-time.After(time.Millisecond * 10):
+		}
+	}
+}
+
 // WaitUtilCommit 等待日志提交到指定的下标
 func (r *Raft) WaitUtilCommit(ctx context.Context, index uint64) error {
 	for {
@@ -214,6 +253,7 @@ func (r *Raft) loop() {
 
 func (r *Raft) readyEvents() {
 	events := r.node.Ready()
+	events = r.saveTermAndVote(events)
 	for _, e := range events {
 		switch e.Type {
 		case types.StoreReq: // 处理存储请求
@@ -228,6 +268,12 @@ func (r *Raft) readyEvents() {
 		case types.ApplyReq: // 处理应用请求
 			r.handleApplyReq(e)
 			continue
+		case types.InstallSnapshotReq: // 安装快照请求
+			r.handleInstallSnapshotReq(e)
+			continue
+		case types.CompactReq: // 压缩日志请求
+			r.handleCompactReq(e)
+			continue
 			// 角色转换
 		case types.LearnerToFollowerReq,
 			types.LearnerToLeaderReq,
@@ -282,6 +328,30 @@ func (r *Raft) handleStoreReq(e types.Event) {
 
 }
 
+// 任期或投票记录变化后先保存再发送事件，保存失败则不发送投票相关的消息（下次Ready时重试），
+// 否则节点重启后任期和投票记录丢失，可能在同一任期投两次票
+func (r *Raft) saveTermAndVote(events []types.Event) []types.Event {
+	term, voteFor := r.node.Term(), r.node.VoteFor()
+	if term == r.savedTerm && voteFor == r.savedVoteFor {
+		return events
+	}
+	err := r.opts.Storage.SaveTermAndVote(term, voteFor)
+	if err != nil {
+		r.Error("save term and vote failed", zap.Error(err), zap.Uint32("term", term), zap.Uint64("voteFor", voteFor))
+		filtered := events[:0]
+		for _, e := range events {
+			if e.Type == types.VoteReq || e.Type == types.VoteResp {
+				continue
+			}
+			filtered = append(filtered, e)
+		}
+		return filtered
+	}
+	r.savedTerm = term
+	r.savedVoteFor = voteFor
+	return events
+}
+
 func (r *Raft) handleGetLogsReq(e types.Event) {
 
 	var leaderLastLogTerm = r.node.lastTermStartIndex.Term
@@ -291,9 +361,17 @@ func (r *Raft) handleGetLogsReq(e types.Event) {
 		var (
 			trunctIndex uint64
 		)
+		if e.Reason == types.ReasonSnapshot { // 副本的日志不能截断，请求快照
+			r.stepC <- stepReq{event: r.snapshotResp(e)}
+			return
+		}
 		if e.Reason != types.ReasonOnlySync {
 			var treason types.Reason
 			trunctIndex, treason = r.getTrunctLogIndex(e, leaderLastLogTerm)
+			if treason == types.ReasonSnapshot {
+				r.stepC <- stepReq{event: r.snapshotResp(e)}
+				return
+			}
 			if treason != types.ReasonOk {
 				r.stepC <- stepReq{event: types.Event{
 					To:     e.From,
@@ -307,17 +385,18 @@ func (r *Raft) handleGetLogsReq(e types.Event) {
 
 		// 需要裁剪
 		if trunctIndex > 0 {
-			r.stepC <- stepReq{event: types.Event{
-				To:     e.From,
-				Type:   types.GetLogsResp,
-				Index:  trunctIndex,
-				Reason: types.ReasonTruncate,
-			}}
-			return
+			if resp, ok := truncateSyncResp(r.opts.Storage, r.Log, e, trunctIndex); ok {
+				r.stepC <- stepReq{event: resp}
+				return
+			}
 		}
 
 		// 获取日志数据
 		logs, err := r.opts.Storage.GetLogs(e.Index, e.StoredIndex+1, r.opts.MaxLogCountPerBatch)
+		if errors.Is(err, types.ErrCompacted) { // 副本需要的日志已被压缩，发送快照
+			r.stepC <- stepReq{event: r.snapshotResp(e)}
+			return
+		}
 		if err != nil {
 			r.node.Error("get logs failed", zap.Error(err))
 			r.stepC <- stepReq{event: types.Event{
@@ -349,30 +428,7 @@ func (r *Raft) handleGetLogsReq(e types.Event) {
 
 func (r *Raft) handleTruncateReq(e types.Event) {
 	err := r.pool.Submit(func() {
-		err := r.opts.Storage.TruncateLogTo(e.Index)
-		if err != nil {
-			r.Error("truncate logs failed", zap.Error(err))
-			r.stepC <- stepReq{event: types.Event{
-				Type:   types.TruncateResp,
-				Reason: types.ReasonError,
-			}}
-			return
-		}
-		// 删除本地的leader term start index
-		err = r.opts.Storage.DeleteLeaderTermStartIndexGreaterThanTerm(e.LastLogTerm)
-		if err != nil {
-			r.Error("delete leader term start index failed", zap.Error(err), zap.Uint32("LastLogTerm", e.LastLogTerm))
-			r.stepC <- stepReq{event: types.Event{
-				Type:   types.TruncateResp,
-				Reason: types.ReasonError,
-			}}
-			return
-		}
-		r.stepC <- stepReq{event: types.Event{
-			Type:   types.TruncateResp,
-			Index:  e.Index,
-			Reason: types.ReasonOk,
-		}}
+		r.stepC <- stepReq{event: truncateResp(r.opts.Storage, r.Log, e)}
 	})
 	if err != nil {
 		r.Error("submit truncate logs failed", zap.Error(err))
@@ -383,6 +439,51 @@ func (r *Raft) handleTruncateReq(e types.Event) {
 	}
 }
 
+// 截断日志，返回截断后最后一条日志的任期和任期开始下标
+func truncateResp(storage Storage, log wklog.Log, e types.Event) types.Event {
+	err := storage.TruncateLogTo(e.Index)
+	if err != nil {
+		log.Error("truncate logs failed", zap.Error(err))
+		return types.Event{
+			Type:   types.TruncateResp,
+			Reason: types.ReasonError,
+		}
+	}
+	// 截断后最后一条日志的任期，获取不到（比如已被压缩）则沿用请求的任期
+	lastLogTerm := e.LastLogTerm
+	logs, err := storage.GetLogs(e.Index, e.Index+1, 0)
+	if err == nil && len(logs) > 0 {
+		lastLogTerm = logs[0].Term
+	}
+	// 删除本地的leader term start index
+	err = storage.DeleteLeaderTermStartIndexGreaterThanTerm(lastLogTerm)
+	if err != nil {
+		log.Error("delete leader term start index failed", zap.Error(err), zap.Uint32("LastLogTerm", lastLogTerm))
+		return types.Event{
+			Type:   types.TruncateResp,
+			Reason: types.ReasonError,
+		}
+	}
+	termStartIndex, err := storage.GetTermStartIndex(lastLogTerm)
+	if err != nil {
+		log.Error("get term start index failed", zap.Error(err), zap.Uint32("LastLogTerm", lastLogTerm))
+		return types.Event{
+			Type:   types.TruncateResp,
+			Reason: types.ReasonError,
+		}
+	}
+	return types.Event{
+		Type:        types.TruncateResp,
+		Index:       e.Index,
+		LastLogTerm: lastLogTerm,
+		TermStartIndexInfo: &types.TermStartIndexInfo{
+			Term:  lastLogTerm,
+			Index: termStartIndex,
+		},
+		Reason: types.ReasonOk,
+	}
+}
+
 func (r *Raft) handleApplyReq(e types.Event) {
 	err := r.pool.Submit(func() {
 
@@ -433,33 +534,166 @@ func (r *Raft) handleApplyReq(e types.Event) {
 	}
 }
 
+// 获取快照作为同步日志的响应
+func (r *Raft) snapshotResp(e types.Event) types.Event {
+	return snapshotResp(r.opts.Storage, r.Log, r.snapshotCache, r.opts.MaxSnapshotChunkSize, e)
+}
+
+// 生成让副本裁剪日志的响应，如果领导有副本最新日志的任期则带上这个任期，
+// 否则副本这个任期的日志都和领导不一致，副本需要裁剪到这个任期开始之前。
+// 领导有这个任期并且副本的日志没有超过裁剪位置时不需要裁剪，返回false
+func truncateSyncResp(storage Storage, log wklog.Log, e types.Event, trunctIndex uint64) (types.Event, bool) {
+	var lastLogTerm uint32
+	termStartIndex, err := storage.GetTermStartIndex(e.LastLogTerm)
+	if err != nil {
+		log.Error("get term start index failed", zap.Error(err))
+	} else if termStartIndex > 0 {
+		lastLogTerm = e.LastLogTerm
+	}
+	if lastLogTerm != 0 && e.Index > 0 && trunctIndex >= e.Index-1 {
+		return types.Event{}, false
+	}
+	return types.Event{
+		To:          e.From,
+		Type:        types.GetLogsResp,
+		Index:       trunctIndex,
+		LastLogTerm: lastLogTerm,
+		Reason:      types.ReasonTruncate,
+	}, true
+}
+
+// 按块发送快照，e.Snapshot为副本请求的块，副本第一次请求时为空
+func snapshotResp(storage Storage, log wklog.Log, cache *types.SnapshotCache, maxChunkSize uint64, e types.Event) types.Event {
+	chunk, err := cache.Chunk(strconv.FormatUint(e.From, 10), e.Snapshot, maxChunkSize, func() (types.Snapshot, error) {
+		snapshot, err := storage.GetSnapshot()
+		if err != nil {
+			return types.Snapshot{}, err
+		}
+		termStartIndex, err := storage.GetTermStartIndex(snapshot.Term)
+		if err != nil {
+			return types.Snapshot{}, err
+		}
+		if termStartIndex == 0 || termStartIndex > snapshot.Index {
+			termStartIndex = snapshot.Index
+		}
+		snapshot.TermStartIndex = termStartIndex
+		log.Info("create snapshot", zap.Uint64("to", e.From), zap.Uint64("syncIndex", e.Index), zap.Uint64("snapshotIndex", snapshot.Index), zap.Uint64("size", snapshot.DataSize()))
+		return snapshot, nil
+	})
+	if err != nil {
+		log.Error("get snapshot failed", zap.Error(err))
+		return types.Event{
+			To:     e.From,
+			Type:   types.GetLogsResp,
+			Index:  e.Index,
+			Reason: types.ReasonError,
+		}
+	}
+	return types.Event{
+		To:       e.From,
+		Type:     types.GetLogsResp,
+		Index:    e.Index,
+		Reason:   types.ReasonSnapshot,
+		Snapshot: chunk,
+	}
+}
+
+func (r *Raft) handleInstallSnapshotReq(e types.Event) {
+	err := r.pool.Submit(func() {
+		err := r.opts.Storage.ApplySnapshot(e.Snapshot)
+		if err != nil {
+			r.Error("apply snapshot failed", zap.Error(err), zap.Uint64("index", e.Snapshot.Index))
+			r.stepC <- stepReq{event: types.Event{
+				Type:   types.InstallSnapshotResp,
+				Reason: types.ReasonError,
+			}}
+			return
+		}
+		r.stepC <- stepReq{event: types.Event{
+			Type:   types.InstallSnapshotResp,
+			Reason: types.ReasonOk,
+			Snapshot: types.Snapshot{
+				Index:          e.Snapshot.Index,
+				Term:           e.Snapshot.Term,
+				TermStartIndex: e.Snapshot.TermStartIndex,
+				Offset:         e.Snapshot.Offset + uint64(len(e.Snapshot.Data)), // 已保存的大小
+				Total:          e.Snapshot.Total,
+			},
+		}}
+	})
+	if err != nil {
+		r.Error("submit install snapshot failed", zap.Error(err))
+		r.stepC <- stepReq{event: types.Event{
+			Type:   types.InstallSnapshotResp,
+			Reason: types.ReasonError,
+		}}
+	}
+}
+
+func (r *Raft) handleCompactReq(e types.Event) {
+	err := r.pool.Submit(func() {
+		err := r.opts.Storage.CompactLogTo(e.Index)
+		if err != nil {
+			r.Error("compact logs failed", zap.Error(err), zap.Uint64("index", e.Index))
+			r.stepC <- stepReq{event: types.Event{
+				Type:   types.CompactResp,
+				Reason: types.ReasonError,
+			}}
+			return
+		}
+		r.stepC <- stepReq{event: types.Event{
+			Type:   types.CompactResp,
+			Index:  e.Index,
+			Reason: types.ReasonOk,
+		}}
+	})
+	if err != nil {
+		r.Error("submit compact logs failed", zap.Error(err))
+		r.stepC <- stepReq{event: types.Event{
+			Type:   types.CompactResp,
+			Reason: types.ReasonError,
+		}}
+	}
+}
+
 // 根据副本的同步数据，来获取副本的需要裁剪的日志下标，如果不需要裁剪，则返回0
 func (r *Raft) getTrunctLogIndex(e types.Event, leaderLastLogTerm uint32) (uint64, types.Reason) {
+	return getTrunctLogIndex(r.opts.Storage, r.Log, e, leaderLastLogTerm)
+}
+
+func getTrunctLogIndex(storage Storage, log wklog.Log, e types.Event, leaderLastLogTerm uint32) (uint64, types.Reason) {
 
 	// 副本的最新日志任期为0，说明副本没有日志，不需要裁剪
 	if e.LastLogTerm == 0 {
 		return 0, types.ReasonOk
 	}
-	// 如果副本的最新日志任期等于当前领导的最新日志任期，则不需要裁剪
+	// 如果副本的最新日志任期等于当前领导的最新日志任期，副本多出来的日志（领导没有的）需要裁剪
 	if e.LastLogTerm == leaderLastLogTerm {
+		if e.StoredIndex > 0 && e.Index > e.StoredIndex+1 {
+			return e.StoredIndex, types.ReasonOk
+		}
 		return 0, types.ReasonOk
 	}
 
 	// 如果副本的最新日志任期小于当前领导的最新日志任期，则需要裁剪
 	if e.LastLogTerm < leaderLastLogTerm {
 
-		term, err := r.opts.Storage.LeaderTermGreaterEqThan(e.LastLogTerm + 1)
+		term, err := storage.LeaderTermGreaterEqThan(e.LastLogTerm + 1)
 		if err != nil {
-			r.Error("LeaderTermGreaterEqThan: get leader last term failed", zap.Error(err))
+			log.Error("LeaderTermGreaterEqThan: get leader last term failed", zap.Error(err))
 			return 0, types.ReasonError
 		}
 
 		// 获取副本的最新日志任期+1的开始日志下标
-		termStartIndex, err := r.opts.Storage.GetTermStartIndex(term)
+		termStartIndex, err := storage.GetTermStartIndex(term)
 		if err != nil {
-			r.Error("get term start index failed", zap.Error(err))
+			log.Error("get term start index failed", zap.Error(err))
 			return 0, types.ReasonError
 		}
+		if termStartIndex == 1 && e.SyncVersion >= types.SyncVersionTermTruncate {
+			// 副本从第一条日志开始就和领导不一致，日志不能截断到0，用领导的快照覆盖副本的日志
+			return 0, types.ReasonSnapshot
+		}
 		if termStartIndex > 0 {
 			return termStartIndex - 1, types.ReasonOk
 		}
@@ -468,22 +702,37 @@ func (r *Raft) getTrunctLogIndex(e types.Event, leaderLastLogTerm uint32) (uint6
 		if e.LastLogTerm <= 1 {
 			return 0, types.ReasonOk
 		}
-		term, err := r.opts.Storage.LeaderLastTerm()
-		if err != nil {
-			r.Error("LeaderLastTerm: get leader last term failed", zap.Error(err))
-			return 0, types.ReasonError
+		log.Foucs("getTrunctLogIndex: lastLogTerm > leaderLastLogTerm", zap.Uint64("from", e.From), zap.Uint32("e.LastLogTerm", e.LastLogTerm), zap.Uint32("leaderLastLogTerm", leaderLastLogTerm), zap.Uint64("leaderStoredIndex", e.StoredIndex))
+		if e.SyncVersion < types.SyncVersionTermTruncate {
+			// 旧版本副本不会按任期再次协商，和以前一样裁剪到领导最后一个任期开始之前
+			return legacyTrunctLogIndex(storage, log)
 		}
-		termStartIndex, err := r.opts.Storage.GetTermStartIndex(term)
-		if err != nil {
-			r.Error("get term start index failed", zap.Error(err))
-			return 0, types.ReasonError
+		if e.StoredIndex == 0 {
+			// 领导没有日志，用领导的快照覆盖副本的日志
+			return 0, types.ReasonSnapshot
 		}
-		r.Foucs("getTrunctLogIndex: lastLogTerm > leaderLastLogTerm", zap.Uint64("from", e.From), zap.Uint32("e.LastLogTerm", e.LastLogTerm), zap.Uint32("leaderLastLogTerm", leaderLastLogTerm), zap.Uint64("termStartIndex", termStartIndex))
-		if termStartIndex > 0 {
-			return termStartIndex - 1, types.ReasonOk
-		}
-		return termStartIndex, types.ReasonOk
+		// 领导没有副本最新日志的任期，副本最多保留到领导的最后一条日志，
+		// 副本还会把领导没有的任期的日志裁剪掉（见truncateSyncResp）
+		return e.StoredIndex, types.ReasonOk
+	}
+}
+
+// 旧版本的裁剪位置：领导最后一个任期的开始下标-1
+func legacyTrunctLogIndex(storage Storage, log wklog.Log) (uint64, types.Reason) {
+	term, err := storage.LeaderLastTerm()
+	if err != nil {
+		log.Error("LeaderLastTerm: get leader last term failed", zap.Error(err))
+		return 0, types.ReasonError
+	}
+	termStartIndex, err := storage.GetTermStartIndex(term)
+	if err != nil {
+		log.Error("get term start index failed", zap.Error(err))
+		return 0, types.ReasonError
+	}
+	if termStartIndex > 0 {
+		return termStartIndex - 1, types.ReasonOk
 	}
+	return termStartIndex, types.ReasonOk
 }
 
 func (r *Raft) advance() {
@@ -2,6 +2,7 @@ package raft_test
 
 import (
 	"context"
+	"sync"
 	"testing"
 	"time"
 
@@ -325,6 +326,275 @@ func TestProposeUntilApplied(t *testing.T) {
 	assert.Equal(t, node1Logs[0].Data, node2Logs[0].Data, node3Logs[0].Data)
 }
 
+// 测试预投票：被隔离的追随者不会增加任期，恢复网络后不会打断正常的领导
+func TestPreVote(t *testing.T) {
+	opts1, opts2, opts3 := newThreeOptions(raft.WithPreVote(true), raft.WithCheckQuorum(true))
+	s1, s2, s3, tt := newThreeRaftWithOptions(opts1, opts2, opts3)
+
+	raftStart(t, s1, s2, s3)
+	defer raftStop(s1, s2, s3)
+
+	waitBecomeLeader(s1, s2, s3)
+	leader := getLeader(s1, s2, s3)
+	term := leader.Term()
+
+	var follower *raft.Raft
+	for _, r := range []*raft.Raft{s1, s2, s3} {
+		if r != leader {
+			follower = r
+			break
+		}
+	}
+
+	// 隔离追随者，等待多个选举周期
+	tt.isolate(follower.Options().NodeId, true)
+	time.Sleep(time.Second * 3)
+	assert.Equal(t, term, follower.Term())
+	assert.True(t, leader.IsLeader())
+
+	// 恢复网络，领导和任期不变
+	tt.isolate(follower.Options().NodeId, false)
+	time.Sleep(time.Second * 2)
+	assert.True(t, leader.IsLeader())
+	assert.Equal(t, term, leader.Term())
+	assert.Equal(t, leader.LeaderId(), follower.LeaderId())
+}
+
+// 测试法定数检查：被隔离的领导会退为追随者，其他节点选出新的领导
+func TestCheckQuorum(t *testing.T) {
+	opts1, opts2, opts3 := newThreeOptions(raft.WithPreVote(true), raft.WithCheckQuorum(true))
+	s1, s2, s3, tt := newThreeRaftWithOptions(opts1, opts2, opts3)
+
+	raftStart(t, s1, s2, s3)
+	defer raftStop(s1, s2, s3)
+
+	waitBecomeLeader(s1, s2, s3)
+	leader := getLeader(s1, s2, s3)
+	others := make([]*raft.Raft, 0, 2)
+	for _, r := range []*raft.Raft{s1, s2, s3} {
+		if r != leader {
+			others = append(others, r)
+		}
+	}
+
+	// 隔离领导
+	tt.isolate(leader.Options().NodeId, true)
+	time.Sleep(time.Second * 3)
+	assert.False(t, leader.IsLeader())
+
+	newLeader := getLeader(others...)
+	assert.NotNil(t, newLeader)
+	assert.Greater(t, newLeader.Term(), leader.Term())
+}
+
+// 测试开启选举时转让领导权：目标节点收到TimeoutNow后发起选举成为新领导
+func TestTransferLeader(t *testing.T) {
+	s1, s2, s3 := newThreeRaft()
+	raftStart(t, s1, s2, s3)
+	defer raftStop(s1, s2, s3)
+
+	waitBecomeLeader(s1, s2, s3)
+	leader := getLeader(s1, s2, s3)
+	term := leader.Term()
+
+	var target *raft.Raft
+	for _, r := range []*raft.Raft{s1, s2, s3} {
+		if r != leader {
+			target = r
+			break
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	err := leader.TransferLeader(ctx, target.Options().NodeId)
+	assert.NoError(t, err)
+
+	for !target.IsLeader() {
+		select {
+		case <-ctx.Done():
+			t.Fatal("wait target become leader timeout")
+		case <-time.After(time.Millisecond * 10):
+		}
+	}
+	assert.False(t, leader.IsLeader())
+	assert.Greater(t, target.Term(), term)
+
+	// 新领导可以正常提案
+	_, err = target.ProposeUntilAppliedTimeout(ctx, 1, []byte("hello"))
+	assert.NoError(t, err)
+}
+
+// 测试开启选举时，目标副本的日志追上后才通知它发起选举
+func TestTransferLeaderWaitCatchUp(t *testing.T) {
+	n := newTransferTestLeader(true)
+
+	err := n.Step(types.Event{Type: types.TransferLeaderReq, From: 2})
+	assert.NoError(t, err)
+	events := n.Ready()
+	assert.True(t, hasEvent(events, types.NotifySync, 2))
+	assert.False(t, hasEvent(events, types.TimeoutNow, 2))
+
+	// 转让期间停止提案
+	err = n.Step(n.NewPropose([]byte("world")))
+	assert.Equal(t, raft.ErrProposalDropped, err)
+
+	// 目标副本还没追上
+	stepTransferSyncReq(t, n, 2, n.LastLogIndex())
+	assert.False(t, hasEvent(n.Ready(), types.TimeoutNow, 2))
+
+	// 目标副本追上后发送TimeoutNow
+	stepTransferSyncReq(t, n, 2, n.LastLogIndex()+1)
+	events = n.Ready()
+	assert.True(t, hasEvent(events, types.TimeoutNow, 2))
+	assert.False(t, hasEvent(events, types.FollowerToLeaderReq, 2))
+}
+
+// 测试没有开启选举时，通过配置切换把领导权交给目标副本，切换完成后恢复提案
+func TestTransferLeaderWithoutElection(t *testing.T) {
+	n := newTransferTestLeader(false)
+
+	// 目标副本已经追上，直接移交
+	stepTransferSyncReq(t, n, 2, n.LastLogIndex()+1)
+	n.Ready()
+	err := n.Step(types.Event{Type: types.TransferLeaderReq, From: 2})
+	assert.NoError(t, err)
+	events := n.Ready()
+	assert.True(t, hasEvent(events, types.FollowerToLeaderReq, 2))
+	assert.False(t, hasEvent(events, types.TimeoutNow, 2))
+
+	err = n.Step(n.NewPropose([]byte("world")))
+	assert.Equal(t, raft.ErrProposalDropped, err)
+
+	// 移交中重复的同步请求不会再次发起切换
+	stepTransferSyncReq(t, n, 2, n.LastLogIndex()+1)
+	assert.False(t, hasEvent(n.Ready(), types.FollowerToLeaderReq, 2))
+
+	err = n.Step(types.Event{Type: types.FollowerToLeaderResp, From: 2, Reason: types.ReasonOk})
+	assert.NoError(t, err)
+	err = n.Step(n.NewPropose([]byte("world")))
+	assert.NoError(t, err)
+}
+
+// 测试配置切换失败后恢复提案，并且可以重新转让
+func TestTransferLeaderRoleSwitchFailed(t *testing.T) {
+	n := newTransferTestLeader(false)
+	stepTransferSyncReq(t, n, 2, n.LastLogIndex()+1)
+	n.Ready()
+
+	err := n.Step(types.Event{Type: types.TransferLeaderReq, From: 2})
+	assert.NoError(t, err)
+	assert.True(t, hasEvent(n.Ready(), types.FollowerToLeaderReq, 2))
+
+	err = n.Step(types.Event{Type: types.FollowerToLeaderResp, From: 2, Reason: types.ReasonError})
+	assert.NoError(t, err)
+	err = n.Step(n.NewPropose([]byte("world")))
+	assert.NoError(t, err)
+
+	stepTransferSyncReq(t, n, 2, n.LastLogIndex()+1)
+	n.Ready()
+	err = n.Step(types.Event{Type: types.TransferLeaderReq, From: 2})
+	assert.NoError(t, err)
+	assert.True(t, hasEvent(n.Ready(), types.FollowerToLeaderReq, 2))
+}
+
+// 测试转让超时后放弃转让，恢复提案
+func TestTransferLeaderTimeout(t *testing.T) {
+	n := newTransferTestLeader(true)
+
+	err := n.Step(types.Event{Type: types.TransferLeaderReq, From: 2})
+	assert.NoError(t, err)
+	n.Ready()
+
+	// 转让中不能转给其他副本，重复转给同一个副本直接返回
+	err = n.Step(types.Event{Type: types.TransferLeaderReq, From: 3})
+	assert.Equal(t, raft.ErrLeaderTransferring, err)
+	err = n.Step(types.Event{Type: types.TransferLeaderReq, From: 2})
+	assert.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		n.Tick()
+	}
+	n.Ready()
+	assert.True(t, n.IsLeader())
+	err = n.Step(n.NewPropose([]byte("world")))
+	assert.NoError(t, err)
+
+	// 放弃后可以转给其他副本
+	err = n.Step(types.Event{Type: types.TransferLeaderReq, From: 3})
+	assert.NoError(t, err)
+	assert.True(t, hasEvent(n.Ready(), types.NotifySync, 3))
+}
+
+// 测试不能转让的情况
+func TestTransferLeaderInvalid(t *testing.T) {
+	n := newTransferTestLeader(true)
+
+	// 转给自己不做任何事
+	err := n.Step(types.Event{Type: types.TransferLeaderReq, From: 1})
+	assert.NoError(t, err)
+	err = n.Step(n.NewPropose([]byte("world")))
+	assert.NoError(t, err)
+
+	err = n.Step(types.Event{Type: types.TransferLeaderReq, From: 4})
+	assert.Equal(t, raft.ErrTransferNotReplica, err)
+
+	cfg := n.Config().Clone()
+	cfg.Version++
+	cfg.Learners = []uint64{4}
+	cfg.MigrateFrom = 3
+	cfg.MigrateTo = 4
+	err = n.Step(types.Event{Type: types.ConfChange, Config: cfg})
+	assert.NoError(t, err)
+	assert.True(t, n.IsLeader())
+
+	err = n.Step(types.Event{Type: types.TransferLeaderReq, From: 4})
+	assert.Equal(t, raft.ErrTransferNotReplica, err)
+	err = n.Step(types.Event{Type: types.TransferLeaderReq, From: 2})
+	assert.Equal(t, raft.ErrConfigMigrating, err)
+}
+
+// 创建一个三副本的领导节点，已经有一条日志
+func newTransferTestLeader(electionOn bool) *raft.Node {
+	n := raft.NewNode(0, types.RaftState{}, raft.NewOptions(
+		raft.WithKey("transfer"),
+		raft.WithNodeId(1),
+		raft.WithReplicas([]uint64{1, 2, 3}),
+		raft.WithElectionOn(electionOn),
+		raft.WithLeaderTransferTimeoutTick(5),
+	))
+	n.BecomeLeader(1)
+	_ = n.Step(n.NewPropose([]byte("hello")))
+	n.Ready()
+	return n
+}
+
+func stepTransferSyncReq(t *testing.T, n *raft.Node, from uint64, index uint64) {
+	err := n.Step(types.Event{
+		Type:        types.SyncReq,
+		From:        from,
+		To:          1,
+		Term:        n.Term(),
+		Index:       index,
+		LastLogTerm: n.Term(),
+		Reason:      types.ReasonOk,
+	})
+	assert.NoError(t, err)
+}
+
+func hasEvent(events []types.Event, tp types.EventType, from uint64) bool {
+	for _, e := range events {
+		if e.Type != tp {
+			continue
+		}
+		// TimeoutNow和NotifySync发给目标，角色切换请求的From是目标
+		if e.To == from || e.From == from {
+			return true
+		}
+	}
+	return false
+}
+
 func newTestOptions(nodeId uint64, replicas []uint64, opt ...raft.Option) *raft.Options {
 	optList := make([]raft.Option, 0)
 	optList = append(optList, raft.WithElectionInterval(5), raft.WithNodeId(nodeId), raft.WithReplicas(replicas), raft.WithTransport(&testTransport{}), raft.WithStorage(newTestStorage(nodeId)))
@@ -335,9 +605,18 @@ func newTestOptions(nodeId uint64, replicas []uint64, opt ...raft.Option) *raft.
 
 type testTransport struct {
 	raftMap map[uint64]*raft.Raft
+
+	mu       sync.RWMutex
+	isolated map[uint64]bool // 被隔离的节点（模拟网络分区）
 }
 
 func (t *testTransport) Send(event types.Event) {
+	t.mu.RLock()
+	isolated := t.isolated[event.From] || t.isolated[event.To]
+	t.mu.RUnlock()
+	if isolated {
+		return
+	}
 	r, ok := t.raftMap[event.To]
 	if !ok {
 		return
@@ -345,10 +624,22 @@ func (t *testTransport) Send(event types.Event) {
 	r.Step(event)
 }
 
+// 隔离或恢复节点的网络
+func (t *testTransport) isolate(nodeId uint64, isolated bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.isolated == nil {
+		t.isolated = make(map[uint64]bool)
+	}
+	t.isolated[nodeId] = isolated
+}
+
 type testStorage struct {
 	nodeId          uint64
 	logs            []types.Log
 	termStartIndexs []*types.TermStartIndexInfo
+	term            uint32
+	voteFor         uint64
 }
 
 func newTestStorage(nodeId uint64) *testStorage {
@@ -375,16 +666,24 @@ func (s *testStorage) GetLogs(start, end uint64, limitSize uint64) ([]types.Log,
 
 func (s *testStorage) GetState() (types.RaftState, error) {
 	if len(s.logs) == 0 {
-		return types.RaftState{}, nil
+		return types.RaftState{Term: s.term, VoteFor: s.voteFor}, nil
 	}
 	lastLog := s.logs[len(s.logs)-1]
 	return types.RaftState{
 		LastLogIndex: lastLog.Index,
 		LastTerm:     lastLog.Term,
 		AppliedIndex: lastLog.Index,
+		Term:         s.term,
+		VoteFor:      s.voteFor,
 	}, nil
 }
 
+func (s *testStorage) SaveTermAndVote(term uint32, voteFor uint64) error {
+	s.term = term
+	s.voteFor = voteFor
+	return nil
+}
+
 func (s *testStorage) GetTermStartIndex(term uint32) (uint64, error) {
 	for _, tsi := range s.termStartIndexs {
 		if tsi.Term == term {
@@ -436,6 +735,18 @@ func (s *testStorage) SaveConfig(cfg types.Config) error {
 	return nil
 }
 
+func (s *testStorage) GetSnapshot() (types.Snapshot, error) {
+	return types.Snapshot{}, nil
+}
+
+func (s *testStorage) ApplySnapshot(snapshot types.Snapshot) error {
+	return nil
+}
+
+func (s *testStorage) CompactLogTo(index uint64) error {
+	return nil
+}
+
 // 等到某个节点成为领导者
 func waitBecomeLeader(rr ...*raft.Raft) {
 	for {
@@ -485,6 +796,24 @@ func newThreeRaft() (*raft.Raft, *raft.Raft, *raft.Raft) {
 	return raft1, raft2, raft3
 }
 
+func newThreeRaftWithOptions(opts1, opts2, opts3 *raft.Options) (*raft.Raft, *raft.Raft, *raft.Raft, *testTransport) {
+	s1 := raft.New(opts1)
+	s2 := raft.New(opts2)
+	s3 := raft.New(opts3)
+
+	tt := &testTransport{
+		raftMap: map[uint64]*raft.Raft{
+			1: s1,
+			2: s2,
+			3: s3,
+		},
+	}
+	opts1.Transport = tt
+	opts2.Transport = tt
+	opts3.Transport = tt
+	return s1, s2, s3, tt
+}
+
 func newThreeOptions(opt ...raft.Option) (*raft.Options, *raft.Options, *raft.Options) {
 
 	defaultOpts := make([]raft.Option, 0)
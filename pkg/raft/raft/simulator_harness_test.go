@@ -0,0 +1,624 @@
+package raft
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+
+	"github.com/WuKongIM/WuKongIM/pkg/raft/types"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"go.uber.org/zap"
+)
+
+// Simulator 确定性的多节点raft模拟器
+// 所有节点在同一个协程内按虚拟时间（tick）推进，本地事件（存储、应用、截断、快照等）同步执行，
+// 节点之间的消息经过模拟网络传递，网络分区、丢包、乱序、延迟以及节点崩溃重启都由随机种子决定，
+// 同一个种子的运行结果完全相同，出现问题时可以用种子复现
+type Simulator struct {
+	opts  *SimulatorOptions
+	rand  *rand.Rand
+	now   uint64 // 虚拟时间（tick数）
+	seq   uint64 // 消息序号
+	nodes []*simNode
+	msgs  []simMessage // 网络中还未送达的消息
+
+	dropRate     float64 // 当前的丢包概率
+	maxDelayTick int     // 当前的消息最大延迟
+
+	partitions map[uint64]int    // 节点所在的分区，不同分区之间的消息会丢失
+	leaders    map[uint32]uint64 // 每个任期的领导
+	committed  []types.Log       // 已提交的日志，下标0对应日志下标1
+	proposeNum uint64            // 提案序号
+	err        error             // 第一次违反的安全性
+
+	digest uint64 // 已送达消息的摘要（用于检查确定性）
+	wklog.Log
+}
+
+type simNode struct {
+	id            uint64
+	node          *Node // 为nil表示节点已崩溃
+	storage       *MemoryStorage
+	checkedIndex  uint64               // 已检查过的提交日志下标
+	snapshotCache *types.SnapshotCache // 节点作为领导时正在发给副本的快照
+}
+
+type simMessage struct {
+	deliverAt uint64 // 送达时间
+	seq       uint64
+	event     types.Event
+}
+
+// NewSimulator 创建模拟器，节点id从1开始
+func NewSimulator(opts *SimulatorOptions) *Simulator {
+	s := &Simulator{
+		opts:         opts,
+		rand:         rand.New(rand.NewSource(opts.Seed)),
+		dropRate:     opts.DropRate,
+		maxDelayTick: opts.MaxDelayTick,
+		partitions:   make(map[uint64]int),
+		leaders:      make(map[uint32]uint64),
+		Log:          wklog.NewWKLog(fmt.Sprintf("raft.simulator[%d]", opts.Seed)),
+	}
+	for i := 1; i <= opts.NodeCount; i++ {
+		sn := &simNode{
+			id:      uint64(i),
+			storage: NewMemoryStorage(),
+		}
+		s.nodes = append(s.nodes, sn)
+		s.startNode(sn)
+	}
+	return s
+}
+
+// 启动节点（和raft.New一样从存储恢复状态）
+func (s *Simulator) startNode(sn *simNode) {
+	raftState, err := sn.storage.GetState()
+	if err != nil {
+		panic(err)
+	}
+	lastTermStartLogIndex, err := sn.storage.GetTermStartIndex(raftState.LastTerm)
+	if err != nil {
+		panic(err)
+	}
+	replicas := make([]uint64, 0, len(s.nodes))
+	for i := 1; i <= s.opts.NodeCount; i++ {
+		replicas = append(replicas, uint64(i))
+	}
+	opts := NewOptions(
+		WithKey(fmt.Sprintf("sim-%d", sn.id)),
+		WithNodeId(sn.id),
+		WithReplicas(replicas),
+		WithElectionOn(true),
+		WithElectionInterval(s.opts.ElectionInterval),
+		WithPreVote(s.opts.PreVote),
+		WithCheckQuorum(s.opts.CheckQuorum),
+		WithCompactLogThreshold(s.opts.CompactLogThreshold),
+		WithCompactLogRetain(s.opts.CompactLogRetain),
+		WithStorage(sn.storage),
+		WithMaxSnapshotChunkSize(64), // 快照分成很多小块发送，覆盖分块传输的各种情况
+		WithRand(rand.New(rand.NewSource(s.rand.Int63()))),
+	)
+	sn.node = NewNode(lastTermStartLogIndex, raftState, opts)
+	sn.checkedIndex = 0
+	sn.snapshotCache = types.NewSnapshotCache()
+}
+
+// Tick 推进一个虚拟时间单位，返回false表示已经发现违反安全性的情况
+func (s *Simulator) Tick() bool {
+	if s.err != nil {
+		return false
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			s.fail(fmt.Errorf("panic at tick %d: %v", s.now, r))
+		}
+	}()
+	s.now++
+	for _, sn := range s.nodes {
+		if sn.node != nil {
+			sn.node.Tick()
+		}
+	}
+	s.process()
+	s.check()
+	return s.err == nil
+}
+
+// Run 推进指定的tick数
+func (s *Simulator) Run(ticks int) bool {
+	for i := 0; i < ticks; i++ {
+		if !s.Tick() {
+			return false
+		}
+	}
+	return true
+}
+
+// Propose 向当前领导提交一条日志，没有领导返回false
+func (s *Simulator) Propose() bool {
+	leader := s.leaderNode()
+	if leader == nil {
+		return false
+	}
+	s.proposeNum++
+	err := leader.node.Step(leader.node.NewPropose([]byte(fmt.Sprintf("p-%d", s.proposeNum))))
+	if err != nil {
+		return false
+	}
+	return true
+}
+
+// Crash 节点崩溃，未送达该节点的消息全部丢失，存储保留
+func (s *Simulator) Crash(id uint64) {
+	sn := s.nodes[id-1]
+	if sn.node == nil {
+		return
+	}
+	sn.node = nil
+	msgs := s.msgs[:0]
+	for _, m := range s.msgs {
+		if m.event.To != id {
+			msgs = append(msgs, m)
+		}
+	}
+	s.msgs = msgs
+}
+
+// Restart 从存储重启崩溃的节点
+func (s *Simulator) Restart(id uint64) {
+	sn := s.nodes[id-1]
+	if sn.node != nil {
+		return
+	}
+	s.startNode(sn)
+}
+
+// Crashed 节点是否已崩溃
+func (s *Simulator) Crashed(id uint64) bool {
+	return s.nodes[id-1].node == nil
+}
+
+// Partition 将节点划分到不同的分区，未指定的节点单独在一个分区
+func (s *Simulator) Partition(groups ...[]uint64) {
+	s.partitions = make(map[uint64]int)
+	for i, group := range groups {
+		for _, id := range group {
+			s.partitions[id] = i + 1
+		}
+	}
+	for _, sn := range s.nodes {
+		if _, ok := s.partitions[sn.id]; !ok {
+			s.partitions[sn.id] = len(groups) + int(sn.id)
+		}
+	}
+}
+
+// Heal 恢复网络分区
+func (s *Simulator) Heal() {
+	s.partitions = make(map[uint64]int)
+}
+
+// SetNetwork 设置丢包概率和消息最大延迟tick数
+func (s *Simulator) SetNetwork(dropRate float64, maxDelayTick int) {
+	s.dropRate = dropRate
+	s.maxDelayTick = maxDelayTick
+}
+
+// Leader 当前领导，没有返回0（多个节点都认为自己是领导时返回任期最大的）
+func (s *Simulator) Leader() uint64 {
+	leader := s.leaderNode()
+	if leader == nil {
+		return 0
+	}
+	return leader.id
+}
+
+// CommittedIndex 节点的已提交日志下标
+func (s *Simulator) CommittedIndex(id uint64) uint64 {
+	sn := s.nodes[id-1]
+	if sn.node == nil {
+		return 0
+	}
+	return sn.node.CommittedIndex()
+}
+
+// Committed 全局已提交的日志数量
+func (s *Simulator) Committed() uint64 {
+	return uint64(len(s.committed))
+}
+
+// Storage 节点的存储
+func (s *Simulator) Storage(id uint64) *MemoryStorage {
+	return s.nodes[id-1].storage
+}
+
+// Rand 模拟器的随机数生成器，测试场景使用它来保证确定性
+func (s *Simulator) Rand() *rand.Rand {
+	return s.rand
+}
+
+// Now 当前虚拟时间
+func (s *Simulator) Now() uint64 {
+	return s.now
+}
+
+// Err 第一次违反安全性的错误
+func (s *Simulator) Err() error {
+	return s.err
+}
+
+// Digest 已送达消息的摘要，同一个种子和场景的摘要相同
+func (s *Simulator) Digest() uint64 {
+	return s.digest
+}
+
+func (s *Simulator) leaderNode() *simNode {
+	var leader *simNode
+	for _, sn := range s.nodes {
+		if sn.node == nil || !sn.node.IsLeader() {
+			continue
+		}
+		if leader == nil || sn.node.Term() > leader.node.Term() {
+			leader = sn
+		}
+	}
+	return leader
+}
+
+func (s *Simulator) fail(err error) {
+	if s.err == nil {
+		s.err = err
+		s.Error("safety violated", zap.Int64("seed", s.opts.Seed), zap.Error(err))
+	}
+}
+
+// 处理所有节点的事件和已到期的消息，直到没有可处理的事件
+func (s *Simulator) process() {
+	for round := 0; round < 1000; round++ {
+		busy := false
+		for _, sn := range s.nodes {
+			if sn.node == nil || !sn.node.HasReady() {
+				continue
+			}
+			busy = true
+			// Ready返回的事件和节点共用底层数组，处理事件时会继续产生新事件，所以先复制
+			events := append([]types.Event{}, sn.node.Ready()...)
+			// 和Raft一样，先保存任期和投票记录再发送消息
+			_ = sn.storage.SaveTermAndVote(sn.node.Term(), sn.node.VoteFor())
+			for _, e := range events {
+				s.handleEvent(sn, e)
+				if sn.node == nil {
+					break
+				}
+			}
+		}
+		if s.deliver() {
+			busy = true
+		}
+		if !busy {
+			return
+		}
+	}
+}
+
+// 送达已到期的消息，同一时间的消息按发送顺序送达
+func (s *Simulator) deliver() bool {
+	var due, rest []simMessage
+	for _, m := range s.msgs {
+		if m.deliverAt <= s.now {
+			due = append(due, m)
+		} else {
+			rest = append(rest, m)
+		}
+	}
+	if len(due) == 0 {
+		return false
+	}
+	s.msgs = rest
+	sort.Slice(due, func(i, j int) bool {
+		if due[i].deliverAt != due[j].deliverAt {
+			return due[i].deliverAt < due[j].deliverAt
+		}
+		return due[i].seq < due[j].seq
+	})
+	for _, m := range due {
+		sn := s.nodes[m.event.To-1]
+		if sn.node == nil {
+			continue
+		}
+		s.record(m.event)
+		s.step(sn, m.event)
+	}
+	return true
+}
+
+// 发送消息到模拟网络
+func (s *Simulator) send(e types.Event) {
+	if e.To == 0 || e.To > uint64(len(s.nodes)) {
+		return
+	}
+	if s.nodes[e.To-1].node == nil {
+		return
+	}
+	if s.partitions[e.From] != s.partitions[e.To] {
+		return
+	}
+	if s.dropRate > 0 && s.rand.Float64() < s.dropRate {
+		return
+	}
+	var delay uint64
+	if s.maxDelayTick > 0 {
+		delay = uint64(s.rand.Intn(s.maxDelayTick + 1))
+	}
+	s.seq++
+	s.msgs = append(s.msgs, simMessage{
+		deliverAt: s.now + delay,
+		seq:       s.seq,
+		event:     e,
+	})
+}
+
+func (s *Simulator) step(sn *simNode, e types.Event) {
+	if err := sn.node.Step(e); err != nil {
+		s.Debug("step error", zap.Uint64("node", sn.id), zap.String("type", e.Type.String()), zap.Error(err))
+	}
+}
+
+// 和Raft.readyEvents一样处理节点的事件，只是本地事件同步执行
+func (s *Simulator) handleEvent(sn *simNode, e types.Event) {
+	switch e.Type {
+	case types.StoreReq:
+		reason := types.ReasonOk
+		if err := sn.storage.AppendLogs(e.Logs, e.TermStartIndexInfo); err != nil {
+			reason = types.ReasonError
+		}
+		s.step(sn, types.Event{
+			Type:   types.StoreResp,
+			Index:  e.Logs[len(e.Logs)-1].Index,
+			Logs:   e.Logs,
+			Reason: reason,
+		})
+	case types.GetLogsReq:
+		s.step(sn, s.getLogsResp(sn, e))
+	case types.TruncateReq:
+		s.step(sn, truncateResp(sn.storage, s.Log, e))
+	case types.ApplyReq:
+		resp := types.Event{Type: types.ApplyResp, Reason: types.ReasonError}
+		logs, err := sn.storage.GetLogs(e.StartIndex, e.EndIndex, 0)
+		if err == nil && len(logs) > 0 {
+			if err = sn.storage.Apply(logs); err == nil {
+				resp = types.Event{Type: types.ApplyResp, Reason: types.ReasonOk, Index: logs[len(logs)-1].Index}
+			}
+		}
+		s.step(sn, resp)
+	case types.InstallSnapshotReq:
+		resp := types.Event{Type: types.InstallSnapshotResp, Reason: types.ReasonError}
+		if err := sn.storage.ApplySnapshot(e.Snapshot); err == nil {
+			resp = types.Event{
+				Type:   types.InstallSnapshotResp,
+				Reason: types.ReasonOk,
+				Snapshot: types.Snapshot{
+					Index:          e.Snapshot.Index,
+					Term:           e.Snapshot.Term,
+					TermStartIndex: e.Snapshot.TermStartIndex,
+					Offset:         e.Snapshot.Offset + uint64(len(e.Snapshot.Data)),
+					Total:          e.Snapshot.Total,
+				},
+			}
+		}
+		s.step(sn, resp)
+	case types.CompactReq:
+		resp := types.Event{Type: types.CompactResp, Index: e.Index, Reason: types.ReasonOk}
+		if err := sn.storage.CompactLogTo(e.Index); err != nil {
+			resp = types.Event{Type: types.CompactResp, Reason: types.ReasonError}
+		}
+		s.step(sn, resp)
+	case types.LearnerToFollowerReq: // 模拟器的副本固定，不支持角色转换
+		s.step(sn, types.Event{Type: types.LearnerToFollowerResp, From: e.From, Reason: types.ReasonError})
+	case types.LearnerToLeaderReq:
+		s.step(sn, types.Event{Type: types.LearnerToLeaderResp, From: e.From, Reason: types.ReasonError})
+	case types.FollowerToLeaderReq:
+		s.step(sn, types.Event{Type: types.FollowerToLeaderResp, From: e.From, Reason: types.ReasonError})
+	case types.Destory:
+	default:
+		if e.To == None {
+			return
+		}
+		if e.To == types.LocalNode {
+			s.step(sn, e)
+			return
+		}
+		s.send(e)
+	}
+}
+
+// 和Raft.handleGetLogsReq一样生成同步日志的响应
+func (s *Simulator) getLogsResp(sn *simNode, e types.Event) types.Event {
+	if e.Reason == types.ReasonSnapshot {
+		return snapshotResp(sn.storage, s.Log, sn.snapshotCache, sn.node.opts.MaxSnapshotChunkSize, e)
+	}
+	if e.Reason != types.ReasonOnlySync {
+		trunctIndex, reason := getTrunctLogIndex(sn.storage, s.Log, e, sn.node.lastTermStartIndex.Term)
+		if reason == types.ReasonSnapshot {
+			return snapshotResp(sn.storage, s.Log, sn.snapshotCache, sn.node.opts.MaxSnapshotChunkSize, e)
+		}
+		if reason != types.ReasonOk {
+			return types.Event{To: e.From, Type: types.GetLogsResp, Index: e.Index, Reason: reason}
+		}
+		if trunctIndex > 0 {
+			if resp, ok := truncateSyncResp(sn.storage, s.Log, e, trunctIndex); ok {
+				return resp
+			}
+		}
+	}
+	logs, err := sn.storage.GetLogs(e.Index, e.StoredIndex+1, sn.node.opts.MaxLogCountPerBatch)
+	if errors.Is(err, types.ErrCompacted) {
+		return snapshotResp(sn.storage, s.Log, sn.snapshotCache, sn.node.opts.MaxSnapshotChunkSize, e)
+	}
+	if err != nil {
+		return types.Event{To: e.From, Type: types.GetLogsResp, Index: e.Index, Reason: types.ReasonError}
+	}
+	return types.Event{To: e.From, Type: types.GetLogsResp, Index: e.Index, Logs: logs, Reason: types.ReasonOk}
+}
+
+// 检查安全性：每个任期最多一个领导，所有节点已提交的日志一致，状态机是已提交日志的前缀
+func (s *Simulator) check() {
+	for _, sn := range s.nodes {
+		if sn.node == nil {
+			continue
+		}
+		if sn.node.IsLeader() {
+			term := sn.node.Term()
+			if leader, ok := s.leaders[term]; ok && leader != sn.id {
+				s.fail(fmt.Errorf("tick %d: two leaders in term %d: %d and %d", s.now, term, leader, sn.id))
+				return
+			}
+			s.leaders[term] = sn.id
+		}
+
+		// 已提交的日志
+		committedIndex := sn.node.CommittedIndex()
+		if committedIndex > sn.node.LastLogIndex() {
+			committedIndex = sn.node.LastLogIndex()
+		}
+		for index := sn.checkedIndex + 1; index <= committedIndex; index++ {
+			log, ok := sn.storage.Log(index)
+			if !ok {
+				// 日志已被压缩，从状态机中取数据（任期未知）
+				applied := sn.storage.Applied()
+				if index > uint64(len(applied)) {
+					break
+				}
+				log = types.Log{Index: index, Data: applied[index-1]}
+			}
+			if index <= uint64(len(s.committed)) {
+				expect := s.committed[index-1]
+				if expect.Term == 0 && log.Term != 0 {
+					s.committed[index-1].Term = log.Term
+				} else if log.Term != 0 && expect.Term != log.Term {
+					s.fail(fmt.Errorf("tick %d: node %d committed log %d (term %d, %q) conflicts with committed (term %d, %q)", s.now, sn.id, index, log.Term, log.Data, expect.Term, expect.Data))
+					return
+				}
+				if !bytes.Equal(expect.Data, log.Data) {
+					s.fail(fmt.Errorf("tick %d: node %d committed log %d (term %d, %q) conflicts with committed (term %d, %q)", s.now, sn.id, index, log.Term, log.Data, expect.Term, expect.Data))
+					return
+				}
+			} else if index == uint64(len(s.committed))+1 {
+				s.committed = append(s.committed, log)
+			} else {
+				break
+			}
+			sn.checkedIndex = index
+		}
+	}
+
+	for _, sn := range s.nodes {
+		// 状态机
+		applied := sn.storage.Applied()
+		if len(applied) > len(s.committed) {
+			s.fail(fmt.Errorf("tick %d: node %d applied %d logs but only %d committed", s.now, sn.id, len(applied), len(s.committed)))
+			return
+		}
+		for i, data := range applied {
+			if !bytes.Equal(data, s.committed[i].Data) {
+				s.fail(fmt.Errorf("tick %d: node %d applied log %d (%q) conflicts with committed (%q)", s.now, sn.id, i+1, data, s.committed[i].Data))
+				return
+			}
+		}
+	}
+}
+
+// 记录已送达的消息到摘要
+func (s *Simulator) record(e types.Event) {
+	h := fnv.New64a()
+	_, _ = fmt.Fprintf(h, "%d|%d|%s|%d|%d|%d|%d|%d|%d|%d", s.digest, s.now, e.Type.String(), e.From, e.To, e.Term, e.Index, e.StoredIndex, e.Reason, len(e.Logs))
+	s.digest = h.Sum64()
+}
+
+type SimulatorOptions struct {
+	// Seed 随机种子，决定选举超时、网络和场景
+	Seed int64
+	// NodeCount 节点数量
+	NodeCount int
+	// ElectionInterval 选举间隔tick次数
+	ElectionInterval int
+	// DropRate 丢包概率 0-1
+	DropRate float64
+	// MaxDelayTick 消息最大延迟tick数，大于0时消息会乱序
+	MaxDelayTick int
+	// PreVote 是否开启预投票
+	PreVote bool
+	// CheckQuorum 是否开启法定数检查
+	CheckQuorum bool
+	// CompactLogThreshold 日志压缩阈值，0表示不压缩
+	CompactLogThreshold uint64
+	// CompactLogRetain 压缩日志时保留的日志数量
+	CompactLogRetain uint64
+}
+
+func NewSimulatorOptions(opt ...SimulatorOption) *SimulatorOptions {
+	opts := &SimulatorOptions{
+		NodeCount:        3,
+		ElectionInterval: 10,
+		CompactLogRetain: 1000,
+	}
+	for _, o := range opt {
+		o(opts)
+	}
+	return opts
+}
+
+type SimulatorOption func(opts *SimulatorOptions)
+
+func WithSimSeed(seed int64) SimulatorOption {
+	return func(opts *SimulatorOptions) {
+		opts.Seed = seed
+	}
+}
+
+func WithSimNodeCount(count int) SimulatorOption {
+	return func(opts *SimulatorOptions) {
+		opts.NodeCount = count
+	}
+}
+
+func WithSimElectionInterval(interval int) SimulatorOption {
+	return func(opts *SimulatorOptions) {
+		opts.ElectionInterval = interval
+	}
+}
+
+func WithSimDropRate(rate float64) SimulatorOption {
+	return func(opts *SimulatorOptions) {
+		opts.DropRate = rate
+	}
+}
+
+func WithSimMaxDelayTick(tick int) SimulatorOption {
+	return func(opts *SimulatorOptions) {
+		opts.MaxDelayTick = tick
+	}
+}
+
+func WithSimPreVote(preVote bool) SimulatorOption {
+	return func(opts *SimulatorOptions) {
+		opts.PreVote = preVote
+	}
+}
+
+func WithSimCheckQuorum(checkQuorum bool) SimulatorOption {
+	return func(opts *SimulatorOptions) {
+		opts.CheckQuorum = checkQuorum
+	}
+}
+
+func WithSimCompactLog(threshold, retain uint64) SimulatorOption {
+	return func(opts *SimulatorOptions) {
+		opts.CompactLogThreshold = threshold
+		opts.CompactLogRetain = retain
+	}
+}
@@ -0,0 +1,132 @@
+package raft_test
+
+import (
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/raft/raft"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestSimulatorDeterministic(t *testing.T) {
+	quietLog(t)
+
+	digest1, committed1 := runSimScenario(t, 7, 2000, true)
+	digest2, committed2 := runSimScenario(t, 7, 2000, true)
+	assert.Equal(t, digest1, digest2)
+	assert.Equal(t, committed1, committed2)
+	assert.Greater(t, committed1, uint64(0))
+}
+
+func TestSimulatorSafety(t *testing.T) {
+	quietLog(t)
+
+	seeds := 1000
+	if testing.Short() {
+		seeds = 100
+	}
+	for seed := 1; seed <= seeds; seed++ {
+		runSimScenario(t, int64(seed), 1000, false)
+		if t.Failed() {
+			return
+		}
+	}
+}
+
+func TestSimulatorSafetyWithCompact(t *testing.T) {
+	quietLog(t)
+
+	for seed := 1; seed <= 200; seed++ {
+		runSimScenario(t, int64(seed), 1000, false, raft.WithSimCompactLog(10, 5))
+		if t.Failed() {
+			return
+		}
+	}
+}
+
+func TestSimulatorSafetyWithCrash(t *testing.T) {
+	quietLog(t)
+
+	for seed := 1; seed <= 1000; seed++ {
+		runSimScenario(t, int64(seed), 1000, true)
+		if t.Failed() {
+			return
+		}
+	}
+}
+
+// 按种子随机注入网络分区、丢包、延迟（crash为true时还有节点崩溃重启），最后恢复网络检查是否能继续提交
+func runSimScenario(t *testing.T, seed int64, ticks int, crash bool, opt ...raft.SimulatorOption) (uint64, uint64) {
+	opts := raft.NewSimulatorOptions(append([]raft.SimulatorOption{
+		raft.WithSimSeed(seed),
+		raft.WithSimNodeCount(3 + int(seed%2)*2),
+		raft.WithSimPreVote(seed%3 != 0),
+		raft.WithSimCheckQuorum(seed%4 != 0),
+	}, opt...)...)
+	s := raft.NewSimulator(opts)
+	r := s.Rand()
+
+	for i := 0; i < ticks; i++ {
+		switch n := r.Intn(100); {
+		case n < 30:
+			s.Propose()
+		case n < 32:
+			s.SetNetwork(r.Float64()*0.3, r.Intn(5))
+		case n < 33:
+			// 随机分区
+			var a, b []uint64
+			for id := uint64(1); id <= uint64(opts.NodeCount); id++ {
+				if r.Intn(2) == 0 {
+					a = append(a, id)
+				} else {
+					b = append(b, id)
+				}
+			}
+			s.Partition(a, b)
+		case n < 34:
+			s.Heal()
+		case n < 35 && crash:
+			s.Crash(uint64(r.Intn(opts.NodeCount) + 1))
+		case n < 37 && crash:
+			s.Restart(uint64(r.Intn(opts.NodeCount) + 1))
+		}
+		if !s.Tick() {
+			break
+		}
+	}
+	if !assert.NoError(t, s.Err(), "seed %d", seed) {
+		return s.Digest(), s.Committed()
+	}
+
+	// 恢复网络和所有节点，集群应该能选出领导并继续提交
+	s.Heal()
+	s.SetNetwork(0, 0)
+	for id := uint64(1); id <= uint64(opts.NodeCount); id++ {
+		s.Restart(id)
+	}
+	s.Run(opts.ElectionInterval * 20)
+	committed := s.Committed()
+	proposed := false
+	for i := 0; i < 200 && s.Err() == nil; i++ {
+		if !proposed {
+			proposed = s.Propose()
+		}
+		s.Tick()
+		if proposed && s.Committed() > committed {
+			break
+		}
+	}
+	assert.NoError(t, s.Err(), "seed %d", seed)
+	assert.NotEqual(t, uint64(0), s.Leader(), "seed %d", seed)
+	assert.Greater(t, s.Committed(), committed, "seed %d", seed)
+	return s.Digest(), s.Committed()
+}
+
+func quietLog(t *testing.T) {
+	wklog.Configure(&wklog.Options{
+		Level:    zapcore.ErrorLevel,
+		LogDir:   t.TempDir(),
+		NoStdout: true,
+	})
+}
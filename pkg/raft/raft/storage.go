@@ -6,9 +6,12 @@ type Storage interface {
 	// AppendLogs 追加日志, 如果termStartIndex不为nil, 则需要保存termStartIndex，最好确保原子性
 	AppendLogs(logs []types.Log, termStartIndex *types.TermStartIndexInfo) error
 	// GetLogs 获取日志 startLogIndex日志开始下标,endLogIndex结束日志下标 limitSize限制每次查询日志大小，0表示不限制，结果包含startLogIndex不包含 endLogIndex
+	// 如果startLogIndex的日志已被压缩，则返回types.ErrCompacted
 	GetLogs(startLogIndex uint64, endLogIndex uint64, limitSize uint64) ([]types.Log, error)
-	// GetState 获取状态
+	// GetState 获取状态（包括SaveTermAndVote保存的任期和投票记录）
 	GetState() (types.RaftState, error)
+	// SaveTermAndVote 保存当前任期和投票记录，必须持久化后才返回，否则重启后可能在同一任期投两次票
+	SaveTermAndVote(term uint32, voteFor uint64) error
 	// GetTermStartIndex 获取指定任期的开始日志下标
 	GetTermStartIndex(term uint32) (uint64, error)
 
@@ -26,4 +29,12 @@ type Storage interface {
 	Apply(logs []types.Log) error
 	// SaveConfig 保存配置
 	SaveConfig(cfg types.Config) error
+
+	// GetSnapshot 获取状态机快照，快照下标为已应用的日志下标，数据量大时可以通过Source按块读取
+	GetSnapshot() (types.Snapshot, error)
+	// ApplySnapshot 按块安装快照，块按顺序传入（Offset为0表示新快照的第一块），先保存收到的块，
+	// 收到最后一块后安装整个快照，会删除本地所有日志，并将已应用下标设置为快照下标
+	ApplySnapshot(snapshot types.Snapshot) error
+	// CompactLogTo 压缩日志，删除index及之前的日志（index必须已应用）
+	CompactLogTo(index uint64) error
 }
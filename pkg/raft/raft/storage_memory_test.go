@@ -0,0 +1,313 @@
+package raft
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+
+	"github.com/WuKongIM/WuKongIM/pkg/raft/types"
+)
+
+// MemoryStorage 内存存储，日志、任期开始下标和状态机（已应用的日志数据）都保存在内存中，
+// 节点崩溃重启时可以继续使用同一个存储，用于模拟测试
+type MemoryStorage struct {
+	mu sync.RWMutex
+
+	logs            []types.Log       // 未压缩的日志，第一条日志的下标为compactedIndex+1
+	termStartIndexs map[uint32]uint64 // 领导任期对应的开始日志下标
+	appliedIndex    uint64            // 已应用的日志下标
+	compactedIndex  uint64            // 已压缩的日志下标
+	compactedTerm   uint32            // 已压缩的最后一条日志的任期
+	cfg             types.Config      // 最后保存的配置
+	term            uint32            // 保存的当前任期
+	voteFor         uint64            // 保存的投票记录
+
+	applied [][]byte // 状态机，已应用的日志数据
+
+	snapshotReceiver types.SnapshotReceiver // 正在接收的快照
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		termStartIndexs: make(map[uint32]uint64),
+	}
+}
+
+func (m *MemoryStorage) AppendLogs(logs []types.Log, termStartIndex *types.TermStartIndexInfo) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, log := range logs {
+		if log.Index <= m.compactedIndex {
+			continue
+		}
+		pos := int(log.Index - m.compactedIndex - 1)
+		if pos < len(m.logs) { // 覆盖已存在的日志
+			m.logs[pos] = log
+			continue
+		}
+		if pos > len(m.logs) {
+			return errors.New("log index is not continuous")
+		}
+		m.logs = append(m.logs, log)
+	}
+	if termStartIndex != nil {
+		m.termStartIndexs[termStartIndex.Term] = termStartIndex.Index
+	}
+	return nil
+}
+
+func (m *MemoryStorage) GetLogs(startLogIndex uint64, endLogIndex uint64, limitSize uint64) ([]types.Log, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.compactedIndex > 0 && startLogIndex <= m.compactedIndex {
+		return nil, types.ErrCompacted
+	}
+	if startLogIndex <= m.compactedIndex {
+		startLogIndex = m.compactedIndex + 1
+	}
+	lastIndex := m.compactedIndex + uint64(len(m.logs))
+	if endLogIndex == 0 || endLogIndex > lastIndex+1 {
+		endLogIndex = lastIndex + 1
+	}
+	var (
+		logs []types.Log
+		size uint64
+	)
+	for index := startLogIndex; index < endLogIndex; index++ {
+		log := m.logs[index-m.compactedIndex-1]
+		logs = append(logs, log)
+		size += uint64(log.LogSize())
+		if limitSize != 0 && size >= limitSize {
+			break
+		}
+	}
+	return logs, nil
+}
+
+func (m *MemoryStorage) GetState() (types.RaftState, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	lastIndex, lastTerm := m.compactedIndex, m.compactedTerm
+	if len(m.logs) > 0 {
+		lastLog := m.logs[len(m.logs)-1]
+		lastIndex, lastTerm = lastLog.Index, lastLog.Term
+	}
+	return types.RaftState{
+		LastLogIndex: lastIndex,
+		LastTerm:     lastTerm,
+		AppliedIndex: m.appliedIndex,
+		Term:         m.term,
+		VoteFor:      m.voteFor,
+	}, nil
+}
+
+func (m *MemoryStorage) SaveTermAndVote(term uint32, voteFor uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.term = term
+	m.voteFor = voteFor
+	return nil
+}
+
+func (m *MemoryStorage) GetTermStartIndex(term uint32) (uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.termStartIndexs[term], nil
+}
+
+func (m *MemoryStorage) LeaderTermGreaterEqThan(term uint32) (uint32, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var minTerm uint32
+	for t := range m.termStartIndexs {
+		if t >= term && (minTerm == 0 || t < minTerm) {
+			minTerm = t
+		}
+	}
+	if minTerm == 0 {
+		return term, nil
+	}
+	return minTerm, nil
+}
+
+func (m *MemoryStorage) LeaderLastTerm() (uint32, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var maxTerm uint32
+	for t := range m.termStartIndexs {
+		if t > maxTerm {
+			maxTerm = t
+		}
+	}
+	return maxTerm, nil
+}
+
+func (m *MemoryStorage) TruncateLogTo(index uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if index == 0 {
+		return errors.New("index must be greater than 0")
+	}
+	if index < m.appliedIndex { // 已应用的日志不能截断
+		return nil
+	}
+	if index < m.compactedIndex {
+		return nil
+	}
+	pos := index - m.compactedIndex
+	if pos < uint64(len(m.logs)) {
+		m.logs = m.logs[:pos]
+	}
+	return nil
+}
+
+func (m *MemoryStorage) DeleteLeaderTermStartIndexGreaterThanTerm(term uint32) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for t := range m.termStartIndexs {
+		if t > term {
+			delete(m.termStartIndexs, t)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStorage) Apply(logs []types.Log) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, log := range logs {
+		if log.Index <= m.appliedIndex {
+			continue
+		}
+		m.applied = append(m.applied, log.Data)
+		m.appliedIndex = log.Index
+	}
+	return nil
+}
+
+func (m *MemoryStorage) SaveConfig(cfg types.Config) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cfg = cfg.Clone()
+	return nil
+}
+
+func (m *MemoryStorage) GetSnapshot() (types.Snapshot, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	term := m.compactedTerm
+	if m.appliedIndex > m.compactedIndex {
+		term = m.logs[m.appliedIndex-m.compactedIndex-1].Term
+	}
+	return types.Snapshot{
+		Index: m.appliedIndex,
+		Term:  term,
+		Data:  encodeApplied(m.applied),
+	}, nil
+}
+
+func (m *MemoryStorage) ApplySnapshot(chunk types.Snapshot) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot, ok, err := m.snapshotReceiver.Append(chunk)
+	if err != nil || !ok {
+		return err
+	}
+	applied, err := decodeApplied(snapshot.Data)
+	if err != nil {
+		return err
+	}
+	m.applied = applied
+	m.logs = nil
+	m.compactedIndex = snapshot.Index
+	m.compactedTerm = snapshot.Term
+	m.appliedIndex = snapshot.Index
+	for t := range m.termStartIndexs {
+		if t >= snapshot.Term {
+			delete(m.termStartIndexs, t)
+		}
+	}
+	m.termStartIndexs[snapshot.Term] = snapshot.TermStartIndex
+	return nil
+}
+
+func (m *MemoryStorage) CompactLogTo(index uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if index > m.appliedIndex {
+		return errors.New("compact index is greater than applied index")
+	}
+	if index <= m.compactedIndex {
+		return nil
+	}
+	pos := index - m.compactedIndex
+	m.compactedTerm = m.logs[pos-1].Term
+	m.logs = append([]types.Log{}, m.logs[pos:]...)
+	m.compactedIndex = index
+	return nil
+}
+
+// AppliedIndex 已应用的日志下标
+func (m *MemoryStorage) AppliedIndex() uint64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.appliedIndex
+}
+
+// Applied 状态机中已应用的日志数据
+func (m *MemoryStorage) Applied() [][]byte {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([][]byte{}, m.applied...)
+}
+
+// Log 获取指定下标的日志，日志不存在或已被压缩返回false
+func (m *MemoryStorage) Log(index uint64) (types.Log, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if index <= m.compactedIndex || index > m.compactedIndex+uint64(len(m.logs)) {
+		return types.Log{}, false
+	}
+	return m.logs[index-m.compactedIndex-1], true
+}
+
+// 编码状态机数据 格式：数量(uvarint) + [长度(uvarint) + 数据]...
+func encodeApplied(applied [][]byte) []byte {
+	data := binary.AppendUvarint(nil, uint64(len(applied)))
+	for _, d := range applied {
+		data = binary.AppendUvarint(data, uint64(len(d)))
+		data = append(data, d...)
+	}
+	return data
+}
+
+func decodeApplied(data []byte) ([][]byte, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	count, n := binary.Uvarint(data)
+	if n <= 0 {
+		return nil, errors.New("invalid snapshot data")
+	}
+	data = data[n:]
+	applied := make([][]byte, 0, count)
+	for i := uint64(0); i < count; i++ {
+		size, n := binary.Uvarint(data)
+		if n <= 0 || uint64(len(data)-n) < size {
+			return nil, errors.New("invalid snapshot data")
+		}
+		data = data[n:]
+		applied = append(applied, append([]byte{}, data[:size]...))
+		data = data[size:]
+	}
+	return applied, nil
+}
@@ -46,6 +46,11 @@ func (rg *RaftGroup) handleGetLogsReq(r IRaft, e types.Event) {
 		var (
 			trunctIndex uint64
 		)
+		if e.Reason == types.ReasonSnapshot { // 副本的日志不能截断，请求快照
+			rg.AddEvent(r.Key(), rg.snapshotResp(r, e))
+			rg.Advance()
+			return
+		}
 		if e.Reason != types.ReasonOnlySync {
 			var treason types.Reason
 			trunctIndex, treason = rg.getTrunctLogIndex(r, e)
@@ -60,14 +65,11 @@ func (rg *RaftGroup) handleGetLogsReq(r IRaft, e types.Event) {
 		}
 		// 需要裁剪
 		if trunctIndex > 0 {
-			rg.AddEvent(r.Key(), types.Event{
-				To:     e.From,
-				Type:   types.GetLogsResp,
-				Index:  trunctIndex,
-				Reason: types.ReasonTruncate,
-			})
-			rg.Advance()
-			return
+			if resp, ok := rg.truncateSyncResp(r, e, trunctIndex); ok {
+				rg.AddEvent(r.Key(), resp)
+				rg.Advance()
+				return
+			}
 		}
 		if e.Reason != types.ReasonOnlySync && e.Index > e.StoredIndex {
 			rg.Debug("index is greater than stored index", zap.String("key", r.Key()), zap.Uint64("index", e.Index), zap.Uint64("storedIndex", e.StoredIndex))
@@ -82,6 +84,11 @@ func (rg *RaftGroup) handleGetLogsReq(r IRaft, e types.Event) {
 		}
 		// 获取日志数据
 		logs, err := rg.opts.Storage.GetLogs(r.Key(), e.Index, e.StoredIndex+1, rg.opts.MaxLogSizePerBatch)
+		if errors.Is(err, types.ErrCompacted) { // 副本需要的日志已被压缩，发送快照
+			rg.AddEvent(r.Key(), rg.snapshotResp(r, e))
+			rg.Advance()
+			return
+		}
 		if err != nil {
 			rg.Error("get logs failed", zap.Error(err))
 			rg.AddEvent(r.Key(), types.Event{
@@ -124,10 +131,25 @@ func (rg *RaftGroup) handleTruncateReq(r IRaft, e types.Event) {
 			})
 			return
 		}
+		// 截断后最后一条日志的任期，获取不到（比如已被压缩）则沿用请求的任期
+		lastLogTerm := e.LastLogTerm
+		logs, err := rg.opts.Storage.GetLogs(r.Key(), e.Index, e.Index+1, 0)
+		if err == nil && len(logs) > 0 {
+			lastLogTerm = logs[0].Term
+		}
 		// 删除本地的leader term start index
-		err = rg.opts.Storage.DeleteLeaderTermStartIndexGreaterThanTerm(r.Key(), e.LastLogTerm)
+		err = rg.opts.Storage.DeleteLeaderTermStartIndexGreaterThanTerm(r.Key(), lastLogTerm)
+		if err != nil {
+			rg.Error("delete leader term start index failed", zap.Error(err), zap.Uint32("LastLogTerm", lastLogTerm))
+			rg.AddEvent(r.Key(), types.Event{
+				Type:   types.TruncateResp,
+				Reason: types.ReasonError,
+			})
+			return
+		}
+		termStartIndex, err := rg.opts.Storage.GetTermStartIndex(r.Key(), lastLogTerm)
 		if err != nil {
-			rg.Error("delete leader term start index failed", zap.Error(err), zap.Uint32("LastLogTerm", e.LastLogTerm))
+			rg.Error("get term start index failed", zap.Error(err), zap.Uint32("LastLogTerm", lastLogTerm))
 			rg.AddEvent(r.Key(), types.Event{
 				Type:   types.TruncateResp,
 				Reason: types.ReasonError,
@@ -135,8 +157,13 @@ func (rg *RaftGroup) handleTruncateReq(r IRaft, e types.Event) {
 			return
 		}
 		rg.AddEvent(r.Key(), types.Event{
-			Type:   types.TruncateResp,
-			Index:  e.Index,
+			Type:        types.TruncateResp,
+			Index:       e.Index,
+			LastLogTerm: lastLogTerm,
+			TermStartIndexInfo: &types.TermStartIndexInfo{
+				Term:  lastLogTerm,
+				Index: termStartIndex,
+			},
 			Reason: types.ReasonOk,
 		})
 	})
@@ -204,6 +231,125 @@ func (rg *RaftGroup) handleApplyReq(r IRaft, e types.Event) {
 	}
 }
 
+// 生成让副本裁剪日志的响应，如果领导有副本最新日志的任期则带上这个任期，
+// 否则副本需要裁剪到这个任期开始之前。领导有这个任期并且副本的日志没有超过裁剪位置时不需要裁剪，返回false
+func (rg *RaftGroup) truncateSyncResp(r IRaft, e types.Event, trunctIndex uint64) (types.Event, bool) {
+	var lastLogTerm uint32
+	termStartIndex, err := rg.opts.Storage.GetTermStartIndex(r.Key(), e.LastLogTerm)
+	if err != nil {
+		rg.Error("get term start index failed", zap.Error(err), zap.String("key", r.Key()))
+	} else if termStartIndex > 0 {
+		lastLogTerm = e.LastLogTerm
+	}
+	if lastLogTerm != 0 && e.Index > 0 && trunctIndex >= e.Index-1 {
+		return types.Event{}, false
+	}
+	return types.Event{
+		To:          e.From,
+		Type:        types.GetLogsResp,
+		Index:       trunctIndex,
+		LastLogTerm: lastLogTerm,
+		Reason:      types.ReasonTruncate,
+	}, true
+}
+
+// 获取快照作为同步日志的响应，快照按块发送，e.Snapshot为副本请求的块，副本第一次请求时为空
+func (rg *RaftGroup) snapshotResp(r IRaft, e types.Event) types.Event {
+	cacheKey := fmt.Sprintf("%s-%d", r.Key(), e.From)
+	chunk, err := rg.snapshotCache.Chunk(cacheKey, e.Snapshot, rg.opts.MaxSnapshotChunkSize, func() (types.Snapshot, error) {
+		snapshot, err := rg.opts.Storage.GetSnapshot(r.Key())
+		if err != nil {
+			return types.Snapshot{}, err
+		}
+		termStartIndex, err := rg.opts.Storage.GetTermStartIndex(r.Key(), snapshot.Term)
+		if err != nil {
+			return types.Snapshot{}, err
+		}
+		if termStartIndex == 0 || termStartIndex > snapshot.Index {
+			termStartIndex = snapshot.Index
+		}
+		snapshot.TermStartIndex = termStartIndex
+		rg.Info("create snapshot", zap.String("key", r.Key()), zap.Uint64("to", e.From), zap.Uint64("syncIndex", e.Index), zap.Uint64("snapshotIndex", snapshot.Index), zap.Uint64("size", snapshot.DataSize()))
+		return snapshot, nil
+	})
+	if err != nil {
+		rg.Error("get snapshot failed", zap.Error(err), zap.String("key", r.Key()))
+		return types.Event{
+			To:     e.From,
+			Type:   types.GetLogsResp,
+			Index:  e.Index,
+			Reason: types.ReasonError,
+		}
+	}
+	return types.Event{
+		To:       e.From,
+		Type:     types.GetLogsResp,
+		Index:    e.Index,
+		Reason:   types.ReasonSnapshot,
+		Snapshot: chunk,
+	}
+}
+
+func (rg *RaftGroup) handleInstallSnapshotReq(r IRaft, e types.Event) {
+	err := rg.goPool.Submit(func() {
+		err := rg.opts.Storage.ApplySnapshot(r.Key(), e.Snapshot)
+		if err != nil {
+			rg.Error("apply snapshot failed", zap.Error(err), zap.String("key", r.Key()), zap.Uint64("index", e.Snapshot.Index))
+			rg.AddEvent(r.Key(), types.Event{
+				Type:   types.InstallSnapshotResp,
+				Reason: types.ReasonError,
+			})
+			rg.Advance()
+			return
+		}
+		rg.AddEvent(r.Key(), types.Event{
+			Type:   types.InstallSnapshotResp,
+			Reason: types.ReasonOk,
+			Snapshot: types.Snapshot{
+				Index:          e.Snapshot.Index,
+				Term:           e.Snapshot.Term,
+				TermStartIndex: e.Snapshot.TermStartIndex,
+				Offset:         e.Snapshot.Offset + uint64(len(e.Snapshot.Data)), // 已保存的大小
+				Total:          e.Snapshot.Total,
+			},
+		})
+		rg.Advance()
+	})
+	if err != nil {
+		rg.Error("submit install snapshot req failed", zap.Error(err))
+		rg.AddEvent(r.Key(), types.Event{
+			Type:   types.InstallSnapshotResp,
+			Reason: types.ReasonError,
+		})
+	}
+}
+
+func (rg *RaftGroup) handleCompactReq(r IRaft, e types.Event) {
+	err := rg.goPool.Submit(func() {
+		err := rg.opts.Storage.CompactLogTo(r.Key(), e.Index)
+		if err != nil {
+			rg.Error("compact logs failed", zap.Error(err), zap.String("key", r.Key()), zap.Uint64("index", e.Index))
+			rg.AddEvent(r.Key(), types.Event{
+				Type:   types.CompactResp,
+				Reason: types.ReasonError,
+			})
+			return
+		}
+		rg.AddEvent(r.Key(), types.Event{
+			Type:   types.CompactResp,
+			Index:  e.Index,
+			Reason: types.ReasonOk,
+		})
+	})
+	if err != nil {
+		rg.Error("submit compact req failed", zap.Error(err))
+		rg.AddEvent(r.Key(), types.Event{
+			Type:   types.CompactResp,
+			Reason: types.ReasonError,
+		})
+	}
+}
+
 // 根据副本的同步数据，来获取副本的需要裁剪的日志下标，如果不需要裁剪，则返回0
 func (rg *RaftGroup) getTrunctLogIndex(r IRaft, e types.Event) (uint64, types.Reason) {
 	leaderLastLogTerm, err := rg.opts.Storage.LeaderLastLogTerm(r.Key())
@@ -238,16 +384,22 @@ func (rg *RaftGroup) getTrunctLogIndex(r IRaft, e types.Event) (uint64, types.Re
 		}
 		return termStartIndex, types.ReasonOk
 	} else {
-		termStartIndex, err := rg.opts.Storage.GetTermStartIndex(r.Key(), leaderLastLogTerm)
-		if err != nil {
-			rg.Error("get term start index failed", zap.Error(err))
-			return 0, types.ReasonError
-		}
-		rg.Foucs("getTrunctLogIndex: lastLogTerm > leaderLastLogTerm", zap.Uint32("e.LastLogTerm", e.LastLogTerm), zap.Uint32("leaderLastLogTerm", leaderLastLogTerm), zap.Uint64("termStartIndex", termStartIndex))
-		if termStartIndex > 0 {
-			return termStartIndex - 1, types.ReasonOk
+		rg.Foucs("getTrunctLogIndex: lastLogTerm > leaderLastLogTerm", zap.Uint32("e.LastLogTerm", e.LastLogTerm), zap.Uint32("leaderLastLogTerm", leaderLastLogTerm), zap.Uint64("leaderStoredIndex", e.StoredIndex))
+		if e.SyncVersion < types.SyncVersionTermTruncate {
+			// 旧版本副本不会按任期再次协商，和以前一样裁剪到领导最后一个任期开始之前
+			termStartIndex, err := rg.opts.Storage.GetTermStartIndex(r.Key(), leaderLastLogTerm)
+			if err != nil {
+				rg.Error("get term start index failed", zap.Error(err))
+				return 0, types.ReasonError
+			}
+			if termStartIndex > 0 {
+				return termStartIndex - 1, types.ReasonOk
+			}
+			return termStartIndex, types.ReasonOk
 		}
-		return termStartIndex, types.ReasonOk
+		// 领导没有副本最新日志的任期，副本最多保留到领导的最后一条日志，
+		// 副本还会把领导没有的任期的日志裁剪掉（见truncateSyncResp）
+		return e.StoredIndex, types.ReasonOk
 	}
 }
 
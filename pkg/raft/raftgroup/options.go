@@ -15,6 +15,8 @@ type Options struct {
 	ReceiveQueueLength uint64
 	// MaxLogSizePerBatch 每次同步的最大日志大小 单位byte
 	MaxLogSizePerBatch uint64
+	// MaxSnapshotChunkSize 发送快照时每块数据的最大大小 单位byte
+	MaxSnapshotChunkSize uint64
 	// ProposeTimeout 提案超时时间
 	ProposeTimeout time.Duration
 	// LogPrefix 日志前缀
@@ -28,12 +30,13 @@ type Options struct {
 
 func NewOptions(opt ...Option) *Options {
 	os := &Options{
-		TickInterval:       100 * time.Millisecond,
-		GoPoolSize:         6000,
-		ReceiveQueueLength: 1024,
-		MaxLogSizePerBatch: 1024 * 1024 * 10,
-		ProposeTimeout:     5 * time.Second,
-		NotNeedApplied:     false,
+		TickInterval:         100 * time.Millisecond,
+		GoPoolSize:           6000,
+		ReceiveQueueLength:   1024,
+		MaxLogSizePerBatch:   1024 * 1024 * 10,
+		MaxSnapshotChunkSize: 1024 * 1024 * 4,
+		ProposeTimeout:       5 * time.Second,
+		NotNeedApplied:       false,
 	}
 	for _, o := range opt {
 		o(os)
@@ -74,6 +77,12 @@ func WithMaxLogSizePerBatch(size uint64) Option {
 	}
 }
 
+func WithMaxSnapshotChunkSize(size uint64) Option {
+	return func(o *Options) {
+		o.MaxSnapshotChunkSize = size
+	}
+}
+
 func WithTransport(transport ITransport) Option {
 	return func(o *Options) {
 		o.Transport = transport
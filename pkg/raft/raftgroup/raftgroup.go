@@ -1,9 +1,11 @@
 package raftgroup
 
 import (
+	"context"
 	"fmt"
 	"time"
 
+	"github.com/WuKongIM/WuKongIM/pkg/raft/raft"
 	"github.com/WuKongIM/WuKongIM/pkg/raft/track"
 	"github.com/WuKongIM/WuKongIM/pkg/raft/types"
 	wt "github.com/WuKongIM/WuKongIM/pkg/wait"
@@ -30,6 +32,8 @@ type RaftGroup struct {
 	wait *wait
 
 	fowardProposeWait wt.Wait // 转发提按给领导等待领导的回应
+
+	snapshotCache *types.SnapshotCache // 正在发给副本的快照
 }
 
 func New(opts *Options) *RaftGroup {
@@ -42,6 +46,7 @@ func New(opts *Options) *RaftGroup {
 		mq:                NewEventQueue(opts.ReceiveQueueLength, false, 0, 0),
 		wait:              newWait(),
 		fowardProposeWait: wt.New(),
+		snapshotCache:     types.NewSnapshotCache(),
 	}
 	var err error
 	rg.goPool, err = ants.NewPool(opts.GoPoolSize, ants.WithNonblocking(true))
@@ -90,6 +95,36 @@ func (rg *RaftGroup) IsLeader(raftKey string) bool {
 	return raft.IsLeader()
 }
 
+// TransferLeader 将领导权转让给指定的副本，等待目标副本成为领导
+func (rg *RaftGroup) TransferLeader(ctx context.Context, raftKey string, to uint64) error {
+	r := rg.raftList.get(raftKey)
+	if r == nil {
+		return ErrRaftNotExist
+	}
+	if !r.IsLeader() {
+		return raft.ErrNotLeader
+	}
+	err := rg.AddEventWait(raftKey, types.Event{
+		Type: types.TransferLeaderReq,
+		From: to,
+	})
+	if err != nil {
+		return err
+	}
+	for {
+		if r.LeaderId() == to {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-rg.stopper.ShouldStop():
+			return ErrGroupStopped
+		case <-time.After(time.Millisecond * 10):
+		}
+	}
+}
+
 func (rg *RaftGroup) Start() error {
 	rg.stopper.RunWorker(rg.loopEvent)
 	return nil
@@ -265,6 +300,12 @@ func (rg *RaftGroup) handleReady(r IRaft) bool {
 		case types.Destory: // 处理销毁请求
 			rg.handleDestory(r)
 			continue
+		case types.InstallSnapshotReq: // 安装快照请求
+			rg.handleInstallSnapshotReq(r, e)
+			continue
+		case types.CompactReq: // 压缩日志请求
+			rg.handleCompactReq(r, e)
+			continue
 
 			// 角色转换
 		case types.LearnerToFollowerReq,
@@ -464,6 +464,18 @@ func (t *testStorage) GetConfig(key string) (types.Config, error) {
 	return types.Config{}, nil
 }
 
+func (t *testStorage) GetSnapshot(key string) (types.Snapshot, error) {
+	return types.Snapshot{}, nil
+}
+
+func (t *testStorage) ApplySnapshot(key string, snapshot types.Snapshot) error {
+	return nil
+}
+
+func (t *testStorage) CompactLogTo(key string, index uint64) error {
+	return nil
+}
+
 // 等到选举出领导者
 func waitHasLeader(rr ...raftgroup.IRaft) {
 	for {
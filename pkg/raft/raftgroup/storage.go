@@ -13,6 +13,7 @@ type IStorage interface {
 	LeaderTermGreaterEqThan(key string, term uint32) (uint32, error)
 
 	// GetLogs  获取日志 startLogIndex日志开始下标,endLogIndex结束日志下标 limitSize限制每次查询日志大小,0表示不限制，结果包含startLogIndex不包含 endLogIndex
+	// 如果startLogIndex的日志已被压缩，则返回types.ErrCompacted
 	GetLogs(key string, startLogIndex uint64, endLogIndex uint64, limitSize uint64) ([]types.Log, error)
 	// Apply 应用日志
 	Apply(key string, logs []types.Log) error
@@ -22,4 +23,12 @@ type IStorage interface {
 	DeleteLeaderTermStartIndexGreaterThanTerm(key string, term uint32) error
 	// SaveConfig 保存配置
 	SaveConfig(key string, cfg types.Config) error
+
+	// GetSnapshot 获取状态机快照，快照下标为已应用的日志下标，数据量大时可以通过Source按块读取
+	GetSnapshot(key string) (types.Snapshot, error)
+	// ApplySnapshot 按块安装快照，块按顺序传入（Offset为0表示新快照的第一块），先保存收到的块，
+	// 收到最后一块后安装整个快照，会删除本地所有日志，并将已应用下标设置为快照下标
+	ApplySnapshot(key string, snapshot types.Snapshot) error
+	// CompactLogTo 压缩日志，删除index及之前的日志（index必须已应用）
+	CompactLogTo(key string, index uint64) error
 }
@@ -0,0 +1,8 @@
+package types
+
+import "errors"
+
+var (
+	// ErrCompacted 请求的日志已被压缩
+	ErrCompacted = errors.New("requested index is unavailable due to compaction")
+)
@@ -42,3 +42,136 @@ func TestEventMarshalUnmarshal(t *testing.T) {
 		assert.Equal(t, event.Logs[i].Data, decoded.Logs[i].Data)
 	}
 }
+
+func TestEventSnapshotMarshalUnmarshal(t *testing.T) {
+	event := Event{
+		Type:   SyncResp,
+		From:   1,
+		To:     2,
+		Term:   3,
+		Reason: ReasonSnapshot,
+		Snapshot: Snapshot{
+			Index:          100,
+			Term:           3,
+			TermStartIndex: 80,
+			Offset:         10,
+			Total:          15,
+			Data:           []byte("state"),
+		},
+	}
+
+	encoded, err := event.Marshal()
+	assert.NoError(t, err)
+	assert.Equal(t, event.Size(), uint64(len(encoded)))
+
+	var decoded Event
+	err = decoded.Unmarshal(encoded)
+	assert.NoError(t, err)
+	assert.Equal(t, event.Reason, decoded.Reason)
+	assert.Equal(t, event.Snapshot, decoded.Snapshot)
+}
+
+func TestSnapshotChunk(t *testing.T) {
+	snapshot := Snapshot{Index: 100, Term: 3, TermStartIndex: 80, Data: []byte("0123456789")}
+
+	chunk := snapshot.Chunk(0, 4)
+	assert.Equal(t, uint64(100), chunk.Index)
+	assert.Equal(t, uint32(3), chunk.Term)
+	assert.Equal(t, uint64(80), chunk.TermStartIndex)
+	assert.Equal(t, uint64(10), chunk.Total)
+	assert.Equal(t, []byte("0123"), chunk.Data)
+	assert.False(t, chunk.IsLastChunk())
+
+	chunk = snapshot.Chunk(8, 4)
+	assert.Equal(t, uint64(8), chunk.Offset)
+	assert.Equal(t, []byte("89"), chunk.Data)
+	assert.True(t, chunk.IsLastChunk())
+
+	// 不分块
+	chunk = snapshot.Chunk(0, 0)
+	assert.Equal(t, snapshot.Data, chunk.Data)
+	assert.True(t, chunk.IsLastChunk())
+
+	// 空快照数据
+	chunk = Snapshot{Index: 1}.Chunk(0, 4)
+	assert.Empty(t, chunk.Data)
+	assert.True(t, chunk.IsLastChunk())
+}
+
+func TestSnapshotCache(t *testing.T) {
+	cache := NewSnapshotCache()
+	var created int
+	getSnapshot := func() (Snapshot, error) {
+		created++
+		return Snapshot{Index: uint64(created * 10), Data: []byte("0123456789")}, nil
+	}
+
+	// 第一次请求生成快照
+	chunk, err := cache.Chunk("1", Snapshot{}, 4, getSnapshot)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, created)
+	assert.Equal(t, []byte("0123"), chunk.Data)
+
+	// 后续的块来自同一个快照
+	chunk, err = cache.Chunk("1", Snapshot{Index: 10, Offset: 4}, 4, getSnapshot)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, created)
+	assert.Equal(t, []byte("4567"), chunk.Data)
+
+	// 请求的快照下标不一致，重新生成快照并从头发送
+	chunk, err = cache.Chunk("1", Snapshot{Index: 5, Offset: 8}, 4, getSnapshot)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, created)
+	assert.Equal(t, uint64(20), chunk.Index)
+	assert.Equal(t, uint64(0), chunk.Offset)
+
+	chunk, err = cache.Chunk("1", Snapshot{Index: 20, Offset: 4}, 4, getSnapshot)
+	assert.NoError(t, err)
+	chunk, err = cache.Chunk("1", Snapshot{Index: 20, Offset: 8}, 4, getSnapshot)
+	assert.NoError(t, err)
+	assert.True(t, chunk.IsLastChunk())
+	assert.Equal(t, 2, created)
+
+	// 最后一块发送后移除缓存
+	_, err = cache.Chunk("1", Snapshot{Index: 20, Offset: 8}, 4, getSnapshot)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, created)
+
+	// 生成快照失败
+	_, err = cache.Chunk("2", Snapshot{}, 4, func() (Snapshot, error) {
+		return Snapshot{}, ErrCompacted
+	})
+	assert.Equal(t, ErrCompacted, err)
+}
+
+func TestEventSyncVersionMarshalUnmarshal(t *testing.T) {
+	event := Event{
+		Type:        SyncReq,
+		From:        1,
+		To:          2,
+		Term:        3,
+		Index:       10,
+		LastLogTerm: 3,
+		SyncVersion: SyncVersionTermTruncate,
+	}
+
+	encoded, err := event.Marshal()
+	assert.NoError(t, err)
+	assert.Equal(t, event.Size(), uint64(len(encoded)))
+
+	var decoded Event
+	err = decoded.Unmarshal(encoded)
+	assert.NoError(t, err)
+	assert.Equal(t, event, decoded)
+
+	// 版本字段追加在最后，旧版本的编码是新编码去掉这个字段后的前缀
+	event.SyncVersion = 0
+	legacy, err := event.Marshal()
+	assert.NoError(t, err)
+	assert.Equal(t, legacy[4:], encoded[4:len(encoded)-1])
+
+	decoded = Event{}
+	err = decoded.Unmarshal(legacy)
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(0), decoded.SyncVersion)
+}
@@ -0,0 +1,109 @@
+package types
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 缓存的快照超过这个时间没有被请求，说明副本已经不再接收，释放掉
+const snapshotCacheIdleTimeout = time.Minute * 5
+
+// SnapshotCache 领导缓存正在发给副本的快照
+// 快照按块发送，副本每次同步请求下一块，同一个快照只生成一次，保证各块来自同一份状态机数据
+type SnapshotCache struct {
+	mu        sync.Mutex
+	snapshots map[string]*cachedSnapshot
+}
+
+type cachedSnapshot struct {
+	snapshot   Snapshot
+	lastAccess time.Time
+}
+
+func NewSnapshotCache() *SnapshotCache {
+	return &SnapshotCache{
+		snapshots: make(map[string]*cachedSnapshot),
+	}
+}
+
+// Chunk 获取副本请求的快照块
+// req为副本请求的块（Index为正在接收的快照下标，Offset为已接收的大小），副本第一次请求时为空
+// 缓存中没有对应的快照时调用getSnapshot生成新快照，并从头开始发送
+func (c *SnapshotCache) Chunk(key string, req Snapshot, maxSize uint64, getSnapshot func() (Snapshot, error)) (Snapshot, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	c.removeIdle(now)
+
+	cached, ok := c.snapshots[key]
+	if !ok || req.IsEmpty() || req.Offset == 0 || cached.snapshot.Index != req.Index || req.Offset > cached.snapshot.Total {
+		if ok {
+			c.remove(key)
+		}
+		snapshot, err := getSnapshot()
+		if err != nil {
+			return Snapshot{}, err
+		}
+		snapshot.Total = snapshot.DataSize()
+		cached = &cachedSnapshot{snapshot: snapshot}
+		c.snapshots[key] = cached
+		req = Snapshot{}
+	}
+	cached.lastAccess = now
+	chunk, err := cached.snapshot.ReadChunk(req.Offset, maxSize)
+	if err != nil {
+		c.remove(key)
+		return Snapshot{}, fmt.Errorf("read snapshot chunk failed: %w", err)
+	}
+	if chunk.IsLastChunk() {
+		c.remove(key)
+	}
+	return chunk, nil
+}
+
+func (c *SnapshotCache) remove(key string) {
+	cached, ok := c.snapshots[key]
+	if !ok {
+		return
+	}
+	delete(c.snapshots, key)
+	_ = cached.snapshot.Close()
+}
+
+func (c *SnapshotCache) removeIdle(now time.Time) {
+	for key, cached := range c.snapshots {
+		if now.Sub(cached.lastAccess) > snapshotCacheIdleTimeout {
+			c.remove(key)
+		}
+	}
+}
+
+// SnapshotReceiver 在内存里拼接按块安装的快照，用于数据量很小的快照（比如集群配置）
+type SnapshotReceiver struct {
+	snapshot *Snapshot
+}
+
+// Append 追加一块快照数据，收完所有块后返回完整的快照
+func (r *SnapshotReceiver) Append(chunk Snapshot) (Snapshot, bool, error) {
+	if chunk.Offset == 0 {
+		r.snapshot = &Snapshot{
+			Index:          chunk.Index,
+			Term:           chunk.Term,
+			TermStartIndex: chunk.TermStartIndex,
+			Total:          chunk.Total,
+			Data:           make([]byte, 0, chunk.Total),
+		}
+	} else if r.snapshot == nil || r.snapshot.Index != chunk.Index || uint64(len(r.snapshot.Data)) != chunk.Offset {
+		r.snapshot = nil
+		return Snapshot{}, false, fmt.Errorf("snapshot chunk is discontinuous, index: %d offset: %d", chunk.Index, chunk.Offset)
+	}
+	r.snapshot.Data = append(r.snapshot.Data, chunk.Data...)
+	if !chunk.IsLastChunk() {
+		return Snapshot{}, false, nil
+	}
+	snapshot := *r.snapshot
+	r.snapshot = nil
+	return snapshot, true, nil
+}
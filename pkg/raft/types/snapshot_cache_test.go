@@ -0,0 +1,87 @@
+package types
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testSnapshotSource struct {
+	*bytes.Reader
+	closed bool
+}
+
+func (s *testSnapshotSource) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestSnapshotCacheChunkFromSource(t *testing.T) {
+	cache := NewSnapshotCache()
+	source := &testSnapshotSource{Reader: bytes.NewReader([]byte("0123456789"))}
+	var created int
+	getSnapshot := func() (Snapshot, error) {
+		created++
+		return Snapshot{Index: 100, Term: 2, Total: 10, Source: source}, nil
+	}
+
+	// 按块从数据源读取，同一个快照只生成一次
+	chunk, err := cache.Chunk("2", Snapshot{}, 4, getSnapshot)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("0123"), chunk.Data)
+	assert.Equal(t, uint64(10), chunk.Total)
+	assert.Nil(t, chunk.Source)
+
+	chunk, err = cache.Chunk("2", Snapshot{Index: 100, Offset: 4}, 4, getSnapshot)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("4567"), chunk.Data)
+	assert.False(t, source.closed)
+
+	// 发完最后一块后释放数据源
+	chunk, err = cache.Chunk("2", Snapshot{Index: 100, Offset: 8}, 4, getSnapshot)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("89"), chunk.Data)
+	assert.True(t, chunk.IsLastChunk())
+	assert.True(t, source.closed)
+	assert.Equal(t, 1, created)
+}
+
+func TestSnapshotCacheReplace(t *testing.T) {
+	cache := NewSnapshotCache()
+	first := &testSnapshotSource{Reader: bytes.NewReader([]byte("0123456789"))}
+	_, err := cache.Chunk("2", Snapshot{}, 4, func() (Snapshot, error) {
+		return Snapshot{Index: 100, Total: 10, Source: first}, nil
+	})
+	assert.NoError(t, err)
+
+	// 副本重新请求整个快照时，释放旧快照的数据源
+	chunk, err := cache.Chunk("2", Snapshot{}, 4, func() (Snapshot, error) {
+		return Snapshot{Index: 120, Data: []byte("abcdef")}, nil
+	})
+	assert.NoError(t, err)
+	assert.True(t, first.closed)
+	assert.Equal(t, uint64(120), chunk.Index)
+	assert.Equal(t, uint64(6), chunk.Total)
+}
+
+func TestSnapshotReceiver(t *testing.T) {
+	snapshot := Snapshot{Index: 100, Term: 2, TermStartIndex: 90, Data: []byte("0123456789")}
+	r := &SnapshotReceiver{}
+
+	_, ok, err := r.Append(snapshot.Chunk(0, 4))
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	_, ok, err = r.Append(snapshot.Chunk(4, 4))
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	full, ok, err := r.Append(snapshot.Chunk(8, 4))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, snapshot.Data, full.Data)
+	assert.Equal(t, uint64(90), full.TermStartIndex)
+
+	// 块不连续
+	_, _, err = r.Append(snapshot.Chunk(4, 4))
+	assert.Error(t, err)
+}
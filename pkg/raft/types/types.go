@@ -3,6 +3,7 @@ package types
 import (
 	"encoding/binary"
 	"fmt"
+	"io"
 	"math"
 	"time"
 
@@ -22,6 +23,10 @@ const (
 	ReasonTruncate
 	// ReasonOnlySync 只是同步, 不做截断判断
 	ReasonOnlySync
+	// ReasonSnapshot 日志已被压缩，需要安装快照
+	ReasonSnapshot
+	// ReasonLeaderTransfer 领导权转让发起的选举，投票节点不检查领导租约
+	ReasonLeaderTransfer
 )
 
 func (r Reason) Uint8() uint8 {
@@ -38,6 +43,10 @@ func (r Reason) String() string {
 		return "ReasonTruncate"
 	case ReasonOnlySync:
 		return "ReasonOnlySync"
+	case ReasonSnapshot:
+		return "ReasonSnapshot"
+	case ReasonLeaderTransfer:
+		return "ReasonLeaderTransfer"
 	default:
 		return fmt.Sprintf("ReasonUnknown[%d]", r)
 	}
@@ -106,6 +115,22 @@ const (
 	ConfigResp
 	// Destory 销毁节点
 	Destory
+	// InstallSnapshotReq 安装快照请求， local event
+	InstallSnapshotReq
+	// InstallSnapshotResp 安装快照响应， local event
+	InstallSnapshotResp
+	// CompactReq 压缩日志请求， local event
+	CompactReq
+	// CompactResp 压缩日志响应， local event
+	CompactResp
+	// TransferLeaderReq 领导权转让请求（From为转让目标）， local event
+	TransferLeaderReq
+	// TimeoutNow 领导通知转让目标立即发起选举 leader --> follower
+	TimeoutNow
+	// PreVoteReq 预投票请求 candidate --> follower
+	PreVoteReq
+	// PreVoteResp 预投票响应 follower --> candidate
+	PreVoteResp
 )
 
 func (e EventType) String() string {
@@ -168,6 +193,22 @@ func (e EventType) String() string {
 		return "ConfigResp"
 	case Destory:
 		return "Destory"
+	case InstallSnapshotReq:
+		return "InstallSnapshotReq"
+	case InstallSnapshotResp:
+		return "InstallSnapshotResp"
+	case CompactReq:
+		return "CompactReq"
+	case CompactResp:
+		return "CompactResp"
+	case TransferLeaderReq:
+		return "TransferLeaderReq"
+	case TimeoutNow:
+		return "TimeoutNow"
+	case PreVoteReq:
+		return "PreVoteReq"
+	case PreVoteResp:
+		return "PreVoteResp"
 	default:
 		return "Unknown"
 	}
@@ -182,6 +223,11 @@ const (
 	SpeedSuspend
 )
 
+// SyncVersionTermTruncate 按任期协商裁剪日志的同步协议版本。
+// 同步请求和响应都带上发送方的版本，旧版本节点不编码这个字段（为0），
+// 和旧版本节点同步时仍按旧的方式裁剪日志，保证滚动升级期间新旧节点可以互相同步
+const SyncVersionTermTruncate uint8 = 1
+
 type Event struct {
 	// Type 事件类型
 	Type EventType
@@ -211,6 +257,12 @@ type Event struct {
 	// Speed 同步速度
 	Speed Speed
 
+	// Snapshot 快照（副本落后太多，日志已被压缩时发送）
+	Snapshot Snapshot
+
+	// SyncVersion 发送方的日志同步协议版本，旧版本节点为0
+	SyncVersion uint8
+
 	// 不参与编码
 	TermStartIndexInfo *TermStartIndexInfo
 	StartIndex         uint64
@@ -231,6 +283,8 @@ const (
 	logsFlag
 	reasonFlag
 	speedFlag
+	snapshotFlag
+	syncVersionFlag
 )
 
 func (e Event) Marshal() ([]byte, error) {
@@ -302,6 +356,18 @@ func (e Event) Marshal() ([]byte, error) {
 	if flag&speedFlag != 0 {
 		enc.WriteUint8(uint8(e.Speed))
 	}
+	if flag&snapshotFlag != 0 {
+		data, err := e.Snapshot.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		enc.WriteUint32(uint32(len(data)))
+		enc.WriteBytes(data)
+	}
+	// 新字段只能加在最后，旧版本节点解码时会忽略多出来的数据
+	if flag&syncVersionFlag != 0 {
+		enc.WriteUint8(e.SyncVersion)
+	}
 
 	return enc.Bytes(), nil
 }
@@ -462,6 +528,28 @@ func (e *Event) Unmarshal(data []byte) error {
 		}
 		e.Speed = Speed(speed)
 	}
+
+	if flag&snapshotFlag != 0 {
+		dataLen, err := dec.Uint32()
+		if err != nil {
+			return err
+		}
+		data, err := dec.Bytes(int(dataLen))
+		if err != nil {
+			return err
+		}
+		if err := e.Snapshot.Unmarshal(data); err != nil {
+			return err
+		}
+	}
+
+	if flag&syncVersionFlag != 0 {
+		syncVersion, err := dec.Uint8()
+		if err != nil {
+			return err
+		}
+		e.SyncVersion = syncVersion
+	}
 	return nil
 }
 
@@ -506,6 +594,12 @@ func (e Event) getFlag() uint32 {
 	if e.Speed != SpeedFast {
 		flag |= speedFlag
 	}
+	if !e.Snapshot.IsEmpty() {
+		flag |= snapshotFlag
+	}
+	if e.SyncVersion != 0 {
+		flag |= syncVersionFlag
+	}
 	return flag
 }
 
@@ -554,6 +648,12 @@ func (e Event) Size() uint64 {
 	if e.Speed != SpeedFast {
 		size += 1
 	}
+	if !e.Snapshot.IsEmpty() {
+		size += 4 + e.Snapshot.Size()
+	}
+	if e.SyncVersion != 0 {
+		size += 1
+	}
 	return size
 }
 
@@ -705,6 +805,8 @@ const (
 	RoleLeader
 	// RoleLearner 学习者
 	RoleLearner
+	// RolePreCandidate 预候选人（预投票中，任期不变）
+	RolePreCandidate
 )
 
 func (r Role) String() string {
@@ -717,6 +819,8 @@ func (r Role) String() string {
 		return "Leader"
 	case RoleLearner:
 		return "Learner"
+	case RolePreCandidate:
+		return "PreCandidate"
 	default:
 		return "Unknown"
 	}
@@ -735,6 +839,127 @@ func (t *TermStartIndexInfo) Clone() *TermStartIndexInfo {
 	}
 }
 
+// Snapshot 状态机快照
+// 快照数据可能很大，副本之间按块传输，Offset和Total描述当前块在整个快照数据中的位置
+type Snapshot struct {
+	Index          uint64 // 快照对应的最后一条日志下标
+	Term           uint32 // 快照对应的最后一条日志任期
+	TermStartIndex uint64 // Term任期的开始日志下标
+	Offset         uint64 // 当前块数据在快照数据中的偏移
+	Total          uint64 // 快照数据的总大小
+	Data           []byte // 状态机数据
+
+	// Source 快照数据源（比如导出到本地的快照文件），有Source时Data为空，按块从Source读取，只在本节点使用，不会被序列化
+	Source SnapshotSource
+}
+
+// SnapshotSource 快照数据源，Close时释放（比如删除快照文件）
+type SnapshotSource interface {
+	io.ReaderAt
+	io.Closer
+}
+
+func (s Snapshot) IsEmpty() bool {
+	return s.Index == 0
+}
+
+// IsLastChunk 是否是快照的最后一块
+func (s Snapshot) IsLastChunk() bool {
+	return s.Offset+uint64(len(s.Data)) >= s.Total
+}
+
+// Chunk 获取快照从offset开始不超过maxSize的一块数据，maxSize为0表示不分块
+func (s Snapshot) Chunk(offset uint64, maxSize uint64) Snapshot {
+	total := uint64(len(s.Data))
+	if offset > total {
+		offset = total
+	}
+	end := total
+	if maxSize > 0 && offset+maxSize < total {
+		end = offset + maxSize
+	}
+	return Snapshot{
+		Index:          s.Index,
+		Term:           s.Term,
+		TermStartIndex: s.TermStartIndex,
+		Offset:         offset,
+		Total:          total,
+		Data:           s.Data[offset:end],
+	}
+}
+
+// ReadChunk 获取快照从offset开始不超过maxSize的一块数据，快照数据在Source里时从Source读取
+func (s Snapshot) ReadChunk(offset uint64, maxSize uint64) (Snapshot, error) {
+	if s.Source == nil {
+		return s.Chunk(offset, maxSize), nil
+	}
+	if offset > s.Total {
+		offset = s.Total
+	}
+	end := s.Total
+	if maxSize > 0 && offset+maxSize < s.Total {
+		end = offset + maxSize
+	}
+	data := make([]byte, end-offset)
+	if len(data) > 0 {
+		if _, err := s.Source.ReadAt(data, int64(offset)); err != nil && err != io.EOF {
+			return Snapshot{}, err
+		}
+	}
+	return Snapshot{
+		Index:          s.Index,
+		Term:           s.Term,
+		TermStartIndex: s.TermStartIndex,
+		Offset:         offset,
+		Total:          s.Total,
+		Data:           data,
+	}, nil
+}
+
+// DataSize 快照数据的总大小
+func (s Snapshot) DataSize() uint64 {
+	if s.Source != nil {
+		return s.Total
+	}
+	return uint64(len(s.Data))
+}
+
+// Close 释放快照数据源
+func (s Snapshot) Close() error {
+	if s.Source == nil {
+		return nil
+	}
+	return s.Source.Close()
+}
+
+func (s Snapshot) Size() uint64 {
+	return 8 + 4 + 8 + 8 + 8 + uint64(len(s.Data))
+}
+
+func (s Snapshot) Marshal() ([]byte, error) {
+	resultBytes := make([]byte, s.Size())
+	binary.BigEndian.PutUint64(resultBytes[0:8], s.Index)
+	binary.BigEndian.PutUint32(resultBytes[8:12], s.Term)
+	binary.BigEndian.PutUint64(resultBytes[12:20], s.TermStartIndex)
+	binary.BigEndian.PutUint64(resultBytes[20:28], s.Offset)
+	binary.BigEndian.PutUint64(resultBytes[28:36], s.Total)
+	copy(resultBytes[36:], s.Data)
+	return resultBytes, nil
+}
+
+func (s *Snapshot) Unmarshal(data []byte) error {
+	if len(data) < 36 {
+		return fmt.Errorf("snapshot data is too short[%d]", len(data))
+	}
+	s.Index = binary.BigEndian.Uint64(data[0:8])
+	s.Term = binary.BigEndian.Uint32(data[8:12])
+	s.TermStartIndex = binary.BigEndian.Uint64(data[12:20])
+	s.Offset = binary.BigEndian.Uint64(data[20:28])
+	s.Total = binary.BigEndian.Uint64(data[28:36])
+	s.Data = data[36:]
+	return nil
+}
+
 // 本节点
 const LocalNode = math.MaxUint64
 
@@ -745,6 +970,10 @@ type RaftState struct {
 	LastTerm uint32
 	// AppliedIndex 已应用的日志下标
 	AppliedIndex uint64
+	// Term 保存的当前任期（投票后还没有收到新任期的日志时，大于最后一个日志的任期）
+	Term uint32
+	// VoteFor 保存的当前任期投票给了谁
+	VoteFor uint64
 }
 
 // ProposeResp 提案返回
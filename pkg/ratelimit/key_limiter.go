@@ -0,0 +1,116 @@
+package ratelimit
+
+import (
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+)
+
+const shardCount = 32
+
+// KeyLimiter 按key限流的令牌桶，不同的key拥有独立的令牌桶
+// 令牌桶满了之后（长时间没有请求）会被清理，避免占用内存
+type KeyLimiter struct {
+	shards [shardCount]*keyLimiterShard
+}
+
+func NewKeyLimiter() *KeyLimiter {
+	k := &KeyLimiter{}
+	for i := 0; i < shardCount; i++ {
+		k.shards[i] = &keyLimiterShard{
+			buckets: make(map[string]*bucket),
+		}
+	}
+	return k
+}
+
+// Allow 判断key是否允许通过并消耗一个令牌
+// rate为每秒产生的令牌数量，小于等于0表示不限制；burst为桶的容量，小于1时取max(1, rate)
+func (k *KeyLimiter) Allow(key string, rate float64, burst int) bool {
+	if rate <= 0 {
+		return true
+	}
+	if burst < 1 {
+		burst = int(math.Max(1, math.Ceil(rate)))
+	}
+	return k.shard(key).allow(key, rate, burst, time.Now())
+}
+
+// Len 令牌桶数量
+func (k *KeyLimiter) Len() int {
+	count := 0
+	for _, s := range k.shards {
+		s.mu.Lock()
+		count += len(s.buckets)
+		s.mu.Unlock()
+	}
+	return count
+}
+
+func (k *KeyLimiter) shard(key string) *keyLimiterShard {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return k.shards[h.Sum32()%shardCount]
+}
+
+// 清理间隔
+const cleanInterval = time.Minute
+
+type keyLimiterShard struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastClean time.Time
+}
+
+func (s *keyLimiterShard) allow(key string, rate float64, burst int, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastClean) >= cleanInterval {
+		s.clean(now)
+		s.lastClean = now
+	}
+
+	b := s.buckets[key]
+	if b == nil {
+		b = &bucket{
+			tokens: float64(burst),
+			last:   now,
+		}
+		s.buckets[key] = b
+	}
+	b.rate = rate
+	b.burst = burst
+	b.refill(now)
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// 清理已经满了的令牌桶（满了的桶和新建的桶效果一样）
+func (s *keyLimiterShard) clean(now time.Time) {
+	for key, b := range s.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.burst) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+type bucket struct {
+	tokens float64   // 当前令牌数量
+	last   time.Time // 上次补充令牌的时间
+	rate   float64   // 每秒产生的令牌数量
+	burst  int       // 桶的容量
+}
+
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(float64(b.burst), b.tokens+elapsed*b.rate)
+		b.last = now
+	}
+}
@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyLimiterAllow(t *testing.T) {
+	k := NewKeyLimiter()
+
+	// 桶的容量为2，前两次通过，第三次被限制
+	assert.True(t, k.Allow("u1", 1, 2))
+	assert.True(t, k.Allow("u1", 1, 2))
+	assert.False(t, k.Allow("u1", 1, 2))
+
+	// 不同的key互不影响
+	assert.True(t, k.Allow("u2", 1, 2))
+
+	// rate小于等于0不限制
+	for i := 0; i < 10; i++ {
+		assert.True(t, k.Allow("u3", 0, 0))
+	}
+}
+
+func TestKeyLimiterRefill(t *testing.T) {
+	s := &keyLimiterShard{
+		buckets: make(map[string]*bucket),
+	}
+	now := time.Now()
+	s.lastClean = now
+
+	assert.True(t, s.allow("u1", 1, 1, now))
+	assert.False(t, s.allow("u1", 1, 1, now.Add(500*time.Millisecond)))
+	// 1秒后补充了一个令牌
+	assert.True(t, s.allow("u1", 1, 1, now.Add(1500*time.Millisecond)))
+}
+
+func TestKeyLimiterClean(t *testing.T) {
+	s := &keyLimiterShard{
+		buckets: make(map[string]*bucket),
+	}
+	now := time.Now()
+	s.lastClean = now
+
+	assert.True(t, s.allow("u1", 1, 1, now))
+	assert.Equal(t, 1, len(s.buckets))
+
+	// 清理时u1的桶已经满了，将被删除
+	assert.True(t, s.allow("u2", 1, 1, now.Add(cleanInterval)))
+	_, ok := s.buckets["u1"]
+	assert.False(t, ok)
+}
@@ -0,0 +1,106 @@
+package wkdb
+
+import (
+	"math"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb/key"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+	"github.com/cockroachdb/pebble"
+)
+
+func (wk *wukongDB) AddOrUpdateApiKey(apiKey ApiKey) error {
+	if apiKey.Id == 0 {
+		return ErrInvalidApiKeyId
+	}
+	return wk.defaultShardDB().Set(key.NewApiKeyKey(apiKey.Id), apiKey.Encode(), wk.sync)
+}
+
+func (wk *wukongDB) RemoveApiKey(id uint64) error {
+	return wk.defaultShardDB().Delete(key.NewApiKeyKey(id), wk.sync)
+}
+
+func (wk *wukongDB) GetApiKeys() ([]ApiKey, error) {
+	iter := wk.defaultShardDB().NewIter(&pebble.IterOptions{
+		LowerBound: key.NewApiKeyKey(0),
+		UpperBound: key.NewApiKeyKey(math.MaxUint64),
+	})
+	defer iter.Close()
+
+	var apiKeys []ApiKey
+	for iter.First(); iter.Valid(); iter.Next() {
+		var apiKey ApiKey
+		if err := apiKey.Decode(iter.Value()); err != nil {
+			return nil, err
+		}
+		apiKeys = append(apiKeys, apiKey)
+	}
+	return apiKeys, nil
+}
+
+// ApiKey 业务api的访问key
+type ApiKey struct {
+	version   int16      // 数据版本
+	Id        uint64     `json:"id"`                   // 主键
+	Name      string     `json:"name"`                 // 名称
+	KeyHash   string     `json:"key_hash"`             // key的sha256值（不保存key的明文）
+	Scopes    []string   `json:"scopes"`               // 权限范围 比如 message:send channel:write user:token
+	CreatedAt *time.Time `json:"created_at,omitempty"` // 创建时间
+}
+
+func (a *ApiKey) Encode() []byte {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteInt16(int(a.version))
+	enc.WriteUint64(a.Id)
+	enc.WriteString(a.Name)
+	enc.WriteString(a.KeyHash)
+	enc.WriteUint32(uint32(len(a.Scopes)))
+	for _, scope := range a.Scopes {
+		enc.WriteString(scope)
+	}
+	var createdAt int64
+	if a.CreatedAt != nil {
+		createdAt = a.CreatedAt.UnixNano()
+	}
+	enc.WriteInt64(createdAt)
+	return enc.Bytes()
+}
+
+func (a *ApiKey) Decode(data []byte) error {
+	dec := wkproto.NewDecoder(data)
+	var err error
+	if a.version, err = dec.Int16(); err != nil {
+		return err
+	}
+	if a.Id, err = dec.Uint64(); err != nil {
+		return err
+	}
+	if a.Name, err = dec.String(); err != nil {
+		return err
+	}
+	if a.KeyHash, err = dec.String(); err != nil {
+		return err
+	}
+	var scopeCount uint32
+	if scopeCount, err = dec.Uint32(); err != nil {
+		return err
+	}
+	a.Scopes = make([]string, 0, scopeCount)
+	for i := uint32(0); i < scopeCount; i++ {
+		var scope string
+		if scope, err = dec.String(); err != nil {
+			return err
+		}
+		a.Scopes = append(a.Scopes, scope)
+	}
+	var createdAt int64
+	if createdAt, err = dec.Int64(); err != nil {
+		return err
+	}
+	if createdAt > 0 {
+		t := time.Unix(0, createdAt)
+		a.CreatedAt = &t
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package wkdb_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddOrUpdateApiKey(t *testing.T) {
+	d := newTestDB(t)
+	err := d.Open()
+	assert.NoError(t, err)
+
+	defer func() {
+		err := d.Close()
+		assert.NoError(t, err)
+	}()
+
+	tn := time.Now()
+	err = d.AddOrUpdateApiKey(wkdb.ApiKey{
+		Id:        1,
+		Name:      "test",
+		KeyHash:   "hash",
+		Scopes:    []string{"message:send", "channel:write"},
+		CreatedAt: &tn,
+	})
+	assert.NoError(t, err)
+
+	apiKeys, err := d.GetApiKeys()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(apiKeys))
+	assert.Equal(t, uint64(1), apiKeys[0].Id)
+	assert.Equal(t, "test", apiKeys[0].Name)
+	assert.Equal(t, "hash", apiKeys[0].KeyHash)
+	assert.Equal(t, []string{"message:send", "channel:write"}, apiKeys[0].Scopes)
+	assert.Equal(t, tn.UnixNano(), apiKeys[0].CreatedAt.UnixNano())
+}
+
+func TestRemoveApiKey(t *testing.T) {
+	d := newTestDB(t)
+	err := d.Open()
+	assert.NoError(t, err)
+
+	defer func() {
+		err := d.Close()
+		assert.NoError(t, err)
+	}()
+
+	err = d.AddOrUpdateApiKey(wkdb.ApiKey{Id: 1, Name: "test1", KeyHash: "hash1"})
+	assert.NoError(t, err)
+	err = d.AddOrUpdateApiKey(wkdb.ApiKey{Id: 2, Name: "test2", KeyHash: "hash2"})
+	assert.NoError(t, err)
+
+	err = d.RemoveApiKey(1)
+	assert.NoError(t, err)
+
+	apiKeys, err := d.GetApiKeys()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(apiKeys))
+	assert.Equal(t, uint64(2), apiKeys[0].Id)
+}
@@ -0,0 +1,244 @@
+package wkdb
+
+import (
+	"math"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb/key"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+	"github.com/cockroachdb/pebble"
+)
+
+func (wk *wukongDB) AppendAuditLog(slotId uint32, log AuditLog) error {
+	if log.Id == 0 {
+		return ErrInvalidAuditId
+	}
+	if log.CreatedAt == nil {
+		now := time.Now()
+		log.CreatedAt = &now
+	}
+	batch := wk.defaultShardDB().NewBatch()
+	defer batch.Close()
+	if err := batch.Set(key.NewAuditLogKey(log.Id), log.Encode(), wk.noSync); err != nil {
+		return err
+	}
+	if err := batch.Set(key.NewAuditLogSlotIndexKey(slotId, log.Id), nil, wk.noSync); err != nil {
+		return err
+	}
+	return batch.Commit(wk.sync)
+}
+
+func (wk *wukongDB) GetAuditLogsBySlot(slotId uint32, offsetId uint64, limit int) ([]AuditLog, error) {
+	iter := wk.defaultShardDB().NewIter(&pebble.IterOptions{
+		LowerBound: key.NewAuditLogSlotIndexKey(slotId, offsetId+1),
+		UpperBound: key.NewAuditLogSlotIndexKey(slotId, math.MaxUint64),
+	})
+	defer iter.Close()
+
+	var logs []AuditLog
+	for iter.First(); iter.Valid(); iter.Next() {
+		_, id, err := key.ParseAuditLogSlotIndexKey(iter.Key())
+		if err != nil {
+			return nil, err
+		}
+		value, closer, err := wk.defaultShardDB().Get(key.NewAuditLogKey(id))
+		if err != nil {
+			if err == pebble.ErrNotFound { // 日志已被清理，索引还没删
+				continue
+			}
+			return nil, err
+		}
+		var l AuditLog
+		err = l.Decode(value)
+		closer.Close()
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, l)
+		if limit > 0 && len(logs) >= limit {
+			break
+		}
+	}
+	return logs, nil
+}
+
+func (wk *wukongDB) SearchAuditLog(req AuditLogSearchReq) ([]AuditLog, error) {
+	upperId := uint64(math.MaxUint64)
+	if req.OffsetId > 0 {
+		upperId = req.OffsetId
+	}
+	iter := wk.defaultShardDB().NewIter(&pebble.IterOptions{
+		LowerBound: key.NewAuditLogKey(0),
+		UpperBound: key.NewAuditLogKey(upperId),
+	})
+	defer iter.Close()
+
+	var logs []AuditLog
+	for iter.Last(); iter.Valid(); iter.Prev() {
+		var l AuditLog
+		if err := l.Decode(iter.Value()); err != nil {
+			return nil, err
+		}
+		var createdAt int64
+		if l.CreatedAt != nil {
+			createdAt = l.CreatedAt.UnixNano()
+		}
+		if req.StartAt > 0 && createdAt < req.StartAt { // id按时间递增，后面的日志更早
+			break
+		}
+		if req.EndAt > 0 && createdAt >= req.EndAt {
+			continue
+		}
+		if req.Actor != "" && l.Actor != req.Actor {
+			continue
+		}
+		if req.Resource != "" && l.Resource != req.Resource {
+			continue
+		}
+		logs = append(logs, l)
+		if req.Limit > 0 && len(logs) >= req.Limit {
+			break
+		}
+	}
+	return logs, nil
+}
+
+func (wk *wukongDB) RemoveAuditLogsBefore(t time.Time) error {
+	iter := wk.defaultShardDB().NewIter(&pebble.IterOptions{
+		LowerBound: key.NewAuditLogKey(0),
+		UpperBound: key.NewAuditLogKey(math.MaxUint64),
+	})
+	defer iter.Close()
+
+	batch := wk.defaultShardDB().NewBatch()
+	defer batch.Close()
+	keepId := uint64(math.MaxUint64) // 保留的第一条日志id，比它小的都删除
+	for iter.First(); iter.Valid(); iter.Next() {
+		var l AuditLog
+		if err := l.Decode(iter.Value()); err != nil {
+			return err
+		}
+		if l.CreatedAt != nil && !l.CreatedAt.Before(t) {
+			keepId = l.Id
+			break
+		}
+		if err := batch.Delete(iter.Key(), wk.noSync); err != nil {
+			return err
+		}
+	}
+	if err := wk.removeAuditLogSlotIndexBefore(batch, keepId); err != nil {
+		return err
+	}
+	return batch.Commit(wk.sync)
+}
+
+// removeAuditLogSlotIndexBefore 删除每个槽里日志id小于keepId的索引
+func (wk *wukongDB) removeAuditLogSlotIndexBefore(batch *pebble.Batch, keepId uint64) error {
+	iter := wk.defaultShardDB().NewIter(&pebble.IterOptions{
+		LowerBound: key.NewAuditLogSlotIndexKey(0, 0),
+		UpperBound: key.NewAuditLogSlotIndexKey(math.MaxUint32, math.MaxUint64),
+	})
+	defer iter.Close()
+
+	for iter.First(); iter.Valid(); {
+		slotId, _, err := key.ParseAuditLogSlotIndexKey(iter.Key())
+		if err != nil {
+			return err
+		}
+		if err = batch.DeleteRange(key.NewAuditLogSlotIndexKey(slotId, 0), key.NewAuditLogSlotIndexKey(slotId, keepId), wk.noSync); err != nil {
+			return err
+		}
+		if slotId == math.MaxUint32 {
+			break
+		}
+		iter.SeekGE(key.NewAuditLogSlotIndexKey(slotId+1, 0)) // 跳到下一个槽
+	}
+	return nil
+}
+
+// AuditLog 审计日志（记录修改类的api调用）
+type AuditLog struct {
+	version   int16      // 数据版本
+	Id        uint64     `json:"id"`                   // 主键（雪花id）
+	Actor     string     `json:"actor"`                // 操作者（管理后台用户名或api key名称）
+	ActorType string     `json:"actor_type"`           // 操作者类型 user: 管理后台用户 apikey: api key manager: 管理者token anonymous: 匿名
+	Ip        string     `json:"ip"`                   // 来源ip
+	Method    string     `json:"method"`               // 请求方法
+	Path      string     `json:"path"`                 // 请求路径
+	Resource  string     `json:"resource"`             // 资源
+	Params    string     `json:"params"`               // 请求参数（query和body，敏感字段已脱敏，过长会截断）
+	Status    int        `json:"status"`               // 响应的http状态码
+	NodeId    uint64     `json:"node_id"`              // 处理请求的节点
+	CreatedAt *time.Time `json:"created_at,omitempty"` // 创建时间
+}
+
+func (a *AuditLog) Encode() []byte {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteInt16(int(a.version))
+	enc.WriteUint64(a.Id)
+	enc.WriteString(a.Actor)
+	enc.WriteString(a.ActorType)
+	enc.WriteString(a.Ip)
+	enc.WriteString(a.Method)
+	enc.WriteString(a.Path)
+	enc.WriteString(a.Resource)
+	enc.WriteString(a.Params)
+	enc.WriteInt32(int32(a.Status))
+	enc.WriteUint64(a.NodeId)
+	var createdAt int64
+	if a.CreatedAt != nil {
+		createdAt = a.CreatedAt.UnixNano()
+	}
+	enc.WriteInt64(createdAt)
+	return enc.Bytes()
+}
+
+func (a *AuditLog) Decode(data []byte) error {
+	dec := wkproto.NewDecoder(data)
+	var err error
+	if a.version, err = dec.Int16(); err != nil {
+		return err
+	}
+	if a.Id, err = dec.Uint64(); err != nil {
+		return err
+	}
+	if a.Actor, err = dec.String(); err != nil {
+		return err
+	}
+	if a.ActorType, err = dec.String(); err != nil {
+		return err
+	}
+	if a.Ip, err = dec.String(); err != nil {
+		return err
+	}
+	if a.Method, err = dec.String(); err != nil {
+		return err
+	}
+	if a.Path, err = dec.String(); err != nil {
+		return err
+	}
+	if a.Resource, err = dec.String(); err != nil {
+		return err
+	}
+	if a.Params, err = dec.String(); err != nil {
+		return err
+	}
+	var status int32
+	if status, err = dec.Int32(); err != nil {
+		return err
+	}
+	a.Status = int(status)
+	if a.NodeId, err = dec.Uint64(); err != nil {
+		return err
+	}
+	var createdAt int64
+	if createdAt, err = dec.Int64(); err != nil {
+		return err
+	}
+	if createdAt > 0 {
+		t := time.Unix(0, createdAt)
+		a.CreatedAt = &t
+	}
+	return nil
+}
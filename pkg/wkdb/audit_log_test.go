@@ -0,0 +1,185 @@
+package wkdb_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppendAuditLog(t *testing.T) {
+	d := newTestDB(t)
+	err := d.Open()
+	assert.NoError(t, err)
+
+	defer func() {
+		err := d.Close()
+		assert.NoError(t, err)
+	}()
+
+	tn := time.Now()
+	err = d.AppendAuditLog(0, wkdb.AuditLog{
+		Id:        1,
+		Actor:     "admin",
+		ActorType: "user",
+		Ip:        "127.0.0.1",
+		Method:    "POST",
+		Path:      "/channel/delete",
+		Resource:  "channel",
+		Params:    `{"name":"test"}`,
+		Status:    200,
+		NodeId:    1,
+		CreatedAt: &tn,
+	})
+	assert.NoError(t, err)
+
+	logs, err := d.SearchAuditLog(wkdb.AuditLogSearchReq{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(logs))
+	assert.Equal(t, uint64(1), logs[0].Id)
+	assert.Equal(t, "admin", logs[0].Actor)
+	assert.Equal(t, "user", logs[0].ActorType)
+	assert.Equal(t, "127.0.0.1", logs[0].Ip)
+	assert.Equal(t, "POST", logs[0].Method)
+	assert.Equal(t, "/channel/delete", logs[0].Path)
+	assert.Equal(t, "channel", logs[0].Resource)
+	assert.Equal(t, `{"name":"test"}`, logs[0].Params)
+	assert.Equal(t, 200, logs[0].Status)
+	assert.Equal(t, uint64(1), logs[0].NodeId)
+	assert.Equal(t, tn.UnixNano(), logs[0].CreatedAt.UnixNano())
+}
+
+func TestSearchAuditLog(t *testing.T) {
+	d := newTestDB(t)
+	err := d.Open()
+	assert.NoError(t, err)
+
+	defer func() {
+		err := d.Close()
+		assert.NoError(t, err)
+	}()
+
+	tn := time.Now()
+	for i := 1; i <= 6; i++ {
+		createdAt := tn.Add(time.Duration(i) * time.Second)
+		actor := "admin"
+		resource := "channel"
+		if i%2 == 0 {
+			actor = "ops"
+			resource = "user"
+		}
+		err = d.AppendAuditLog(0, wkdb.AuditLog{
+			Id:        uint64(i),
+			Actor:     actor,
+			Resource:  resource,
+			CreatedAt: &createdAt,
+		})
+		assert.NoError(t, err)
+	}
+
+	// 按时间倒序
+	logs, err := d.SearchAuditLog(wkdb.AuditLogSearchReq{Limit: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(logs))
+	assert.Equal(t, uint64(6), logs[0].Id)
+	assert.Equal(t, uint64(5), logs[1].Id)
+
+	// 分页
+	logs, err = d.SearchAuditLog(wkdb.AuditLogSearchReq{OffsetId: 5, Limit: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(logs))
+	assert.Equal(t, uint64(4), logs[0].Id)
+
+	// 按操作者和资源过滤
+	logs, err = d.SearchAuditLog(wkdb.AuditLogSearchReq{Actor: "ops"})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(logs))
+	logs, err = d.SearchAuditLog(wkdb.AuditLogSearchReq{Resource: "channel"})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(logs))
+
+	// 按时间过滤
+	logs, err = d.SearchAuditLog(wkdb.AuditLogSearchReq{
+		StartAt: tn.Add(2 * time.Second).UnixNano(),
+		EndAt:   tn.Add(4 * time.Second).UnixNano(),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(logs))
+	assert.Equal(t, uint64(3), logs[0].Id)
+	assert.Equal(t, uint64(2), logs[1].Id)
+}
+
+func TestRemoveAuditLogsBefore(t *testing.T) {
+	d := newTestDB(t)
+	err := d.Open()
+	assert.NoError(t, err)
+
+	defer func() {
+		err := d.Close()
+		assert.NoError(t, err)
+	}()
+
+	tn := time.Now()
+	for i := 1; i <= 3; i++ {
+		createdAt := tn.Add(time.Duration(i) * time.Second)
+		err = d.AppendAuditLog(uint32(i%2), wkdb.AuditLog{
+			Id:        uint64(i),
+			CreatedAt: &createdAt,
+		})
+		assert.NoError(t, err)
+	}
+
+	err = d.RemoveAuditLogsBefore(tn.Add(2 * time.Second))
+	assert.NoError(t, err)
+
+	logs, err := d.SearchAuditLog(wkdb.AuditLogSearchReq{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(logs))
+	assert.Equal(t, uint64(3), logs[0].Id)
+	assert.Equal(t, uint64(2), logs[1].Id)
+
+	// 槽索引也一起删除
+	logs, err = d.GetAuditLogsBySlot(1, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(logs))
+	assert.Equal(t, uint64(3), logs[0].Id)
+	logs, err = d.GetAuditLogsBySlot(0, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(logs))
+	assert.Equal(t, uint64(2), logs[0].Id)
+}
+
+func TestGetAuditLogsBySlot(t *testing.T) {
+	d := newTestDB(t)
+	err := d.Open()
+	assert.NoError(t, err)
+
+	defer func() {
+		err := d.Close()
+		assert.NoError(t, err)
+	}()
+
+	for i := 1; i <= 6; i++ {
+		err = d.AppendAuditLog(uint32(i%2), wkdb.AuditLog{
+			Id: uint64(i),
+		})
+		assert.NoError(t, err)
+	}
+
+	// 按id升序分页
+	logs, err := d.GetAuditLogsBySlot(1, 0, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(logs))
+	assert.Equal(t, uint64(1), logs[0].Id)
+	assert.Equal(t, uint64(3), logs[1].Id)
+
+	logs, err = d.GetAuditLogsBySlot(1, 3, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(logs))
+	assert.Equal(t, uint64(5), logs[0].Id)
+
+	logs, err = d.GetAuditLogsBySlot(2, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(logs))
+}
@@ -549,6 +549,20 @@ func (wk *wukongDB) writeChannelInfo(primaryKey uint64, channelInfo ChannelInfo,
 		return err
 	}
 
+	// sendRateLimit
+	sendRateLimitBytes := make([]byte, 4)
+	wk.endian.PutUint32(sendRateLimitBytes, channelInfo.SendRateLimit)
+	if err = w.Set(key.NewChannelInfoColumnKey(primaryKey, key.TableChannelInfo.Column.SendRateLimit), sendRateLimitBytes, wk.noSync); err != nil {
+		return err
+	}
+
+	// slowMode
+	slowModeBytes := make([]byte, 4)
+	wk.endian.PutUint32(slowModeBytes, channelInfo.SlowMode)
+	if err = w.Set(key.NewChannelInfoColumnKey(primaryKey, key.TableChannelInfo.Column.SlowMode), slowModeBytes, wk.noSync); err != nil {
+		return err
+	}
+
 	// createdAt
 	if channelInfo.CreatedAt != nil {
 		ct := uint64(channelInfo.CreatedAt.UnixNano())
@@ -707,6 +721,10 @@ func (wk *wukongDB) iterChannelInfo(iter *pebble.Iterator, iterFnc func(channelI
 			preChannelInfo.AllowlistCount = int(wk.endian.Uint32(iter.Value()))
 		case key.TableChannelInfo.Column.DenylistCount:
 			preChannelInfo.DenylistCount = int(wk.endian.Uint32(iter.Value()))
+		case key.TableChannelInfo.Column.SendRateLimit:
+			preChannelInfo.SendRateLimit = wk.endian.Uint32(iter.Value())
+		case key.TableChannelInfo.Column.SlowMode:
+			preChannelInfo.SlowMode = wk.endian.Uint32(iter.Value())
 		case key.TableChannelInfo.Column.CreatedAt:
 			tm := int64(wk.endian.Uint64(iter.Value()))
 			if tm > 0 {
@@ -87,6 +87,41 @@ func TestUpdateChannel(t *testing.T) {
 	assert.Equal(t, channelInfo.UpdatedAt.Unix(), channelInfo2.UpdatedAt.Unix())
 }
 
+func TestChannelRateLimit(t *testing.T) {
+	d := newTestDB(t)
+	err := d.Open()
+	assert.NoError(t, err)
+
+	defer func() {
+		err := d.Close()
+		assert.NoError(t, err)
+	}()
+	channelInfo := wkdb.ChannelInfo{
+		ChannelId:     "channel1",
+		ChannelType:   2,
+		SendRateLimit: 10,
+		SlowMode:      5,
+	}
+	_, err = d.AddChannel(channelInfo)
+	assert.NoError(t, err)
+
+	channelInfo2, err := d.GetChannel(channelInfo.ChannelId, channelInfo.ChannelType)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(10), channelInfo2.SendRateLimit)
+	assert.Equal(t, uint32(5), channelInfo2.SlowMode)
+
+	// 更新为0表示使用全局配置
+	channelInfo.SendRateLimit = 0
+	channelInfo.SlowMode = 0
+	err = d.UpdateChannel(channelInfo)
+	assert.NoError(t, err)
+
+	channelInfo2, err = d.GetChannel(channelInfo.ChannelId, channelInfo.ChannelType)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(0), channelInfo2.SendRateLimit)
+	assert.Equal(t, uint32(0), channelInfo2.SlowMode)
+}
+
 func TestExistChannel(t *testing.T) {
 	d := newTestDB(t)
 	err := d.Open()
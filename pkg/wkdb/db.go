@@ -1,5 +1,7 @@
 package wkdb
 
+import "time"
+
 type DB interface {
 	Open() error
 	Close() error
@@ -29,6 +31,18 @@ type DB interface {
 	StreamDB
 	// 测试机
 	TesterDB
+	// 登录日志
+	LoginLogDB
+	// api key
+	ApiKeyDB
+	// 吊销的管理后台token
+	RevokedTokenDB
+	// 审计日志
+	AuditLogDB
+	// 连接的ip规则
+	IpRuleDB
+	// 槽的数据对象索引
+	SlotEntityDB
 }
 
 type MessageDB interface {
@@ -120,6 +134,9 @@ type UserDB interface {
 
 	// UpdateUser 更新用户
 	UpdateUser(u User) error
+
+	// UpdateUserOnlineStatus 更新用户和设备的最后上线/离线时间
+	UpdateUserOnlineStatus(uid string, deviceFlag uint64, online bool, at time.Time) error
 }
 
 type ChannelDB interface {
@@ -392,6 +409,75 @@ type TesterDB interface {
 	RemoveTester(no string) error
 }
 
+type LoginLogDB interface {
+
+	// AddLoginLog 添加登录日志，maxCount为每个用户最多保留的日志数量（超过的旧日志将被删除） maxCount<=0表示不限制
+	AddLoginLog(log LoginLog, maxCount int) error
+
+	// GetLoginLogs 获取用户的登录日志（按时间倒序） limit<=0表示不限制
+	GetLoginLogs(uid string, limit int) ([]LoginLog, error)
+}
+
+type ApiKeyDB interface {
+
+	// AddOrUpdateApiKey 添加或更新api key
+	AddOrUpdateApiKey(apiKey ApiKey) error
+
+	// RemoveApiKey 移除api key
+	RemoveApiKey(id uint64) error
+
+	// GetApiKeys 获取所有api key
+	GetApiKeys() ([]ApiKey, error)
+}
+
+type RevokedTokenDB interface {
+
+	// AddRevokedToken 添加吊销的token，同时会删除在token.CreatedAt之前已经过期的吊销记录
+	AddRevokedToken(token RevokedToken) error
+
+	// GetRevokedTokens 获取所有吊销的token
+	GetRevokedTokens() ([]RevokedToken, error)
+}
+
+type AuditLogDB interface {
+
+	// AppendAuditLog 追加审计日志，slotId为日志所在的槽
+	AppendAuditLog(slotId uint32, log AuditLog) error
+
+	// GetAuditLogsBySlot 按日志id升序获取槽的审计日志（id大于offsetId），生成槽快照时使用
+	GetAuditLogsBySlot(slotId uint32, offsetId uint64, limit int) ([]AuditLog, error)
+
+	// SearchAuditLog 搜索审计日志（按时间倒序）
+	SearchAuditLog(req AuditLogSearchReq) ([]AuditLog, error)
+
+	// RemoveAuditLogsBefore 删除指定时间之前的审计日志
+	RemoveAuditLogsBefore(t time.Time) error
+}
+
+type IpRuleDB interface {
+
+	// AddOrUpdateIpRule 添加或更新ip规则
+	AddOrUpdateIpRule(rule IpRule) error
+
+	// RemoveIpRule 移除ip规则
+	RemoveIpRule(id uint64) error
+
+	// GetIpRules 获取所有ip规则
+	GetIpRules() ([]IpRule, error)
+}
+
+type SlotEntityDB interface {
+
+	// AddSlotEntities 记录槽里的数据对象（已存在的不重复记录）
+	AddSlotEntities(slotId uint32, entities []SlotEntity) error
+
+	// GetSlotEntities 获取槽里的所有数据对象
+	GetSlotEntities(slotId uint32) ([]SlotEntity, error)
+
+	// RemoveSlotEntities 移除槽的所有数据对象记录
+	RemoveSlotEntities(slotId uint32) error
+}
+
 type MessageSearchReq struct {
 	MessageId        int64
 	FromUid          string // 发送者uid
@@ -422,6 +508,15 @@ type ChannelSearchReq struct {
 	Pre                bool   // 是否向前搜索
 }
 
+type AuditLogSearchReq struct {
+	Actor    string // 操作者
+	Resource string // 资源
+	StartAt  int64  // 开始时间（纳秒，包含） 0表示不限制
+	EndAt    int64  // 结束时间（纳秒，不包含） 0表示不限制
+	OffsetId uint64 // 偏移id，查询比此id更早的日志 0表示从最新的开始
+	Limit    int    // 限制查询数量
+}
+
 type UserSearchReq struct {
 	Uid             string // 用户id
 	Limit           int    // 限制查询数量
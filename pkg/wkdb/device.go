@@ -397,6 +397,33 @@ func (wk *wukongDB) writeDevice(d Device, w pebble.Writer) error {
 		}
 	}
 
+	if d.LastOnlineAt != nil {
+		// lastOnlineAt
+		lastOnlineAt := make([]byte, 8)
+		wk.endian.PutUint64(lastOnlineAt, uint64(d.LastOnlineAt.UnixNano()))
+		if err = w.Set(key.NewDeviceColumnKey(d.Id, key.TableDevice.Column.LastOnlineAt), lastOnlineAt, wk.noSync); err != nil {
+			return err
+		}
+	}
+
+	if d.LastOfflineAt != nil {
+		// lastOfflineAt
+		lastOfflineAt := make([]byte, 8)
+		wk.endian.PutUint64(lastOfflineAt, uint64(d.LastOfflineAt.UnixNano()))
+		if err = w.Set(key.NewDeviceColumnKey(d.Id, key.TableDevice.Column.LastOfflineAt), lastOfflineAt, wk.noSync); err != nil {
+			return err
+		}
+	}
+
+	// tokenExpireAt （token和过期时间一起更新，所以没有过期时间时也需要写入，覆盖掉旧token的过期时间）
+	var tokenExpireAt = make([]byte, 8)
+	if d.TokenExpireAt != nil {
+		wk.endian.PutUint64(tokenExpireAt, uint64(d.TokenExpireAt.UnixNano()))
+	}
+	if err = w.Set(key.NewDeviceColumnKey(d.Id, key.TableDevice.Column.TokenExpireAt), tokenExpireAt, wk.noSync); err != nil {
+		return err
+	}
+
 	// uid index
 	if err = w.Set(key.NewDeviceSecondIndexKey(key.TableDevice.SecondIndex.Uid, key.HashWithString(d.Uid), d.Id), nil, wk.noSync); err != nil {
 		return err
@@ -487,6 +514,24 @@ func (wk *wukongDB) iterDevice(iter *pebble.Iterator, iterFnc func(d Device) boo
 				t := time.Unix(tm/1e9, tm%1e9)
 				preDevice.UpdatedAt = &t
 			}
+		case key.TableDevice.Column.LastOnlineAt:
+			tm := int64(wk.endian.Uint64(iter.Value()))
+			if tm > 0 {
+				t := time.Unix(tm/1e9, tm%1e9)
+				preDevice.LastOnlineAt = &t
+			}
+		case key.TableDevice.Column.LastOfflineAt:
+			tm := int64(wk.endian.Uint64(iter.Value()))
+			if tm > 0 {
+				t := time.Unix(tm/1e9, tm%1e9)
+				preDevice.LastOfflineAt = &t
+			}
+		case key.TableDevice.Column.TokenExpireAt:
+			tm := int64(wk.endian.Uint64(iter.Value()))
+			if tm > 0 {
+				t := time.Unix(tm/1e9, tm%1e9)
+				preDevice.TokenExpireAt = &t
+			}
 
 		}
 		lastNeedAppend = true
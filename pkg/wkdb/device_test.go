@@ -62,6 +62,46 @@ func TestGetDevice(t *testing.T) {
 	assert.Equal(t, u.DeviceLevel, u2.DeviceLevel)
 }
 
+func TestDeviceTokenExpireAt(t *testing.T) {
+	d := wkdb.NewWukongDB(wkdb.NewOptions(wkdb.WithDir(t.TempDir())))
+	err := d.Open()
+	assert.NoError(t, err)
+
+	defer func() {
+		err := d.Close()
+		assert.NoError(t, err)
+	}()
+
+	expireAt := time.Now().Add(time.Hour)
+	u := wkdb.Device{
+		Id:            1,
+		Uid:           "test",
+		Token:         "token",
+		DeviceFlag:    2,
+		DeviceLevel:   1,
+		TokenExpireAt: &expireAt,
+	}
+
+	err = d.AddDevice(u)
+	assert.NoError(t, err)
+
+	u2, err := d.GetDevice("test", 2)
+	assert.NoError(t, err)
+	assert.NotNil(t, u2.TokenExpireAt)
+	assert.Equal(t, expireAt.UnixNano(), u2.TokenExpireAt.UnixNano())
+
+	// 更新token不带过期时间，则清除旧的过期时间
+	u.Token = "token2"
+	u.TokenExpireAt = nil
+	err = d.UpdateDevice(u)
+	assert.NoError(t, err)
+
+	u2, err = d.GetDevice("test", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "token2", u2.Token)
+	assert.Nil(t, u2.TokenExpireAt)
+}
+
 func TestGetDevices(t *testing.T) {
 	d := wkdb.NewWukongDB(wkdb.NewOptions(wkdb.WithDir(t.TempDir())))
 	err := d.Open()
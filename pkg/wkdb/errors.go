@@ -11,5 +11,9 @@ var (
 	ErrNotFound        = errors.New("not found")
 	ErrInvalidUserId   = errors.New("invalid user id")
 	ErrInvalidDeviceId = errors.New("invalid device id")
+	ErrInvalidApiKeyId = errors.New("invalid api key id")
+	ErrInvalidTokenId  = errors.New("invalid token id")
+	ErrInvalidAuditId  = errors.New("invalid audit log id")
+	ErrInvalidIpRuleId = errors.New("invalid ip rule id")
 	ErrAlreadyExist    = errors.New("already exist")
 )
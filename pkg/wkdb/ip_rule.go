@@ -0,0 +1,104 @@
+package wkdb
+
+import (
+	"math"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb/key"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+	"github.com/cockroachdb/pebble"
+)
+
+func (wk *wukongDB) AddOrUpdateIpRule(rule IpRule) error {
+	if rule.Id == 0 {
+		return ErrInvalidIpRuleId
+	}
+	return wk.defaultShardDB().Set(key.NewIpRuleKey(rule.Id), rule.Encode(), wk.sync)
+}
+
+func (wk *wukongDB) RemoveIpRule(id uint64) error {
+	return wk.defaultShardDB().Delete(key.NewIpRuleKey(id), wk.sync)
+}
+
+func (wk *wukongDB) GetIpRules() ([]IpRule, error) {
+	iter := wk.defaultShardDB().NewIter(&pebble.IterOptions{
+		LowerBound: key.NewIpRuleKey(0),
+		UpperBound: key.NewIpRuleKey(math.MaxUint64),
+	})
+	defer iter.Close()
+
+	var rules []IpRule
+	for iter.First(); iter.Valid(); iter.Next() {
+		var rule IpRule
+		if err := rule.Decode(iter.Value()); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// IpRuleAction ip规则的动作
+type IpRuleAction uint8
+
+const (
+	IpRuleAllow IpRuleAction = 1 // 白名单（配置了白名单后，只有白名单内的ip才能连接）
+	IpRuleDeny  IpRuleAction = 2 // 黑名单
+)
+
+// IpRule 连接的ip规则
+type IpRule struct {
+	version   int16        // 数据版本
+	Id        uint64       `json:"id"`                   // 主键
+	Cidr      string       `json:"cidr"`                 // ip段 比如 192.168.1.0/24，单个ip为 192.168.1.1/32
+	Action    IpRuleAction `json:"action"`               // 动作 1.白名单 2.黑名单
+	Remark    string       `json:"remark"`               // 备注
+	CreatedAt *time.Time   `json:"created_at,omitempty"` // 创建时间
+}
+
+func (r *IpRule) Encode() []byte {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteInt16(int(r.version))
+	enc.WriteUint64(r.Id)
+	enc.WriteString(r.Cidr)
+	enc.WriteUint8(uint8(r.Action))
+	enc.WriteString(r.Remark)
+	var createdAt int64
+	if r.CreatedAt != nil {
+		createdAt = r.CreatedAt.UnixNano()
+	}
+	enc.WriteInt64(createdAt)
+	return enc.Bytes()
+}
+
+func (r *IpRule) Decode(data []byte) error {
+	dec := wkproto.NewDecoder(data)
+	var err error
+	if r.version, err = dec.Int16(); err != nil {
+		return err
+	}
+	if r.Id, err = dec.Uint64(); err != nil {
+		return err
+	}
+	if r.Cidr, err = dec.String(); err != nil {
+		return err
+	}
+	var action uint8
+	if action, err = dec.Uint8(); err != nil {
+		return err
+	}
+	r.Action = IpRuleAction(action)
+	if r.Remark, err = dec.String(); err != nil {
+		return err
+	}
+	var createdAt int64
+	if createdAt, err = dec.Int64(); err != nil {
+		return err
+	}
+	if createdAt > 0 {
+		t := time.Unix(0, createdAt)
+		r.CreatedAt = &t
+	}
+	return nil
+}
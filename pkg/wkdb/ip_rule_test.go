@@ -0,0 +1,70 @@
+package wkdb_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddOrUpdateIpRule(t *testing.T) {
+	d := newTestDB(t)
+	err := d.Open()
+	assert.NoError(t, err)
+
+	defer func() {
+		err := d.Close()
+		assert.NoError(t, err)
+	}()
+
+	tn := time.Now()
+	err = d.AddOrUpdateIpRule(wkdb.IpRule{
+		Id:        1,
+		Cidr:      "192.168.1.0/24",
+		Action:    wkdb.IpRuleDeny,
+		Remark:    "flood",
+		CreatedAt: &tn,
+	})
+	assert.NoError(t, err)
+
+	rules, err := d.GetIpRules()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(rules))
+	assert.Equal(t, uint64(1), rules[0].Id)
+	assert.Equal(t, "192.168.1.0/24", rules[0].Cidr)
+	assert.Equal(t, wkdb.IpRuleDeny, rules[0].Action)
+	assert.Equal(t, "flood", rules[0].Remark)
+	assert.Equal(t, tn.UnixNano(), rules[0].CreatedAt.UnixNano())
+
+	err = d.AddOrUpdateIpRule(wkdb.IpRule{})
+	assert.Equal(t, wkdb.ErrInvalidIpRuleId, err)
+}
+
+func TestRemoveIpRule(t *testing.T) {
+	d := newTestDB(t)
+	err := d.Open()
+	assert.NoError(t, err)
+
+	defer func() {
+		err := d.Close()
+		assert.NoError(t, err)
+	}()
+
+	for i := 1; i <= 2; i++ {
+		err = d.AddOrUpdateIpRule(wkdb.IpRule{
+			Id:     uint64(i),
+			Cidr:   "10.0.0.0/8",
+			Action: wkdb.IpRuleAllow,
+		})
+		assert.NoError(t, err)
+	}
+
+	err = d.RemoveIpRule(1)
+	assert.NoError(t, err)
+
+	rules, err := d.GetIpRules()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(rules))
+	assert.Equal(t, uint64(2), rules[0].Id)
+}
@@ -1002,3 +1002,100 @@ func ParseTesterColumnKey(key []byte) (id uint64, columnName [2]byte, err error)
 	columnName[1] = key[13]
 	return
 }
+
+// ---------------------- LoginLog ----------------------
+
+func NewLoginLogKey(uid string, createdAt uint64) []byte {
+	key := make([]byte, TableLoginLog.Size)
+	key[0] = TableLoginLog.Id[0]
+	key[1] = TableLoginLog.Id[1]
+	key[2] = dataTypeTable
+	key[3] = 0
+	binary.BigEndian.PutUint64(key[4:], HashWithString(uid))
+	binary.BigEndian.PutUint64(key[12:], createdAt)
+	return key
+}
+
+// ---------------------- ApiKey ----------------------
+
+func NewApiKeyKey(id uint64) []byte {
+	key := make([]byte, TableApiKey.Size)
+	key[0] = TableApiKey.Id[0]
+	key[1] = TableApiKey.Id[1]
+	key[2] = dataTypeTable
+	key[3] = 0
+	binary.BigEndian.PutUint64(key[4:], id)
+	return key
+}
+
+// ---------------------- RevokedToken ----------------------
+
+func NewRevokedTokenKey(idHash uint64) []byte {
+	key := make([]byte, TableRevokedToken.Size)
+	key[0] = TableRevokedToken.Id[0]
+	key[1] = TableRevokedToken.Id[1]
+	key[2] = dataTypeTable
+	key[3] = 0
+	binary.BigEndian.PutUint64(key[4:], idHash)
+	return key
+}
+
+// ---------------------- AuditLog ----------------------
+
+func NewAuditLogKey(id uint64) []byte {
+	key := make([]byte, TableAuditLog.Size)
+	key[0] = TableAuditLog.Id[0]
+	key[1] = TableAuditLog.Id[1]
+	key[2] = dataTypeTable
+	key[3] = 0
+	binary.BigEndian.PutUint64(key[4:], id)
+	return key
+}
+
+// NewAuditLogSlotIndexKey 审计日志的槽索引
+func NewAuditLogSlotIndexKey(slotId uint32, id uint64) []byte {
+	key := make([]byte, TableAuditLog.SlotIndexSize)
+	key[0] = TableAuditLog.Id[0]
+	key[1] = TableAuditLog.Id[1]
+	key[2] = dataTypeSecondIndex
+	key[3] = 0
+	binary.BigEndian.PutUint32(key[4:], slotId)
+	binary.BigEndian.PutUint64(key[8:], id)
+	return key
+}
+
+func ParseAuditLogSlotIndexKey(key []byte) (slotId uint32, id uint64, err error) {
+	if len(key) != TableAuditLog.SlotIndexSize {
+		err = fmt.Errorf("audit log slot index key is invalid, key length is not %d", TableAuditLog.SlotIndexSize)
+		return
+	}
+	slotId = binary.BigEndian.Uint32(key[4:])
+	id = binary.BigEndian.Uint64(key[8:])
+	return
+}
+
+// ---------------------- IpRule ----------------------
+
+func NewIpRuleKey(id uint64) []byte {
+	key := make([]byte, TableIpRule.Size)
+	key[0] = TableIpRule.Id[0]
+	key[1] = TableIpRule.Id[1]
+	key[2] = dataTypeTable
+	key[3] = 0
+	binary.BigEndian.PutUint64(key[4:], id)
+	return key
+}
+
+// ---------------------- SlotEntity ----------------------
+
+func NewSlotEntityKey(slotId uint32, entityType uint8, entityHash uint64) []byte {
+	key := make([]byte, TableSlotEntity.Size)
+	key[0] = TableSlotEntity.Id[0]
+	key[1] = TableSlotEntity.Id[1]
+	key[2] = dataTypeTable
+	key[3] = 0
+	binary.BigEndian.PutUint32(key[4:], slotId)
+	key[8] = entityType
+	binary.BigEndian.PutUint64(key[9:], entityHash)
+	return key
+}
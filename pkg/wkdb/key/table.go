@@ -119,6 +119,8 @@ var TableUser = struct {
 		RecvMsgBytes      [2]byte // 接受消息字节数量
 		CreatedAt         [2]byte // 创建时间
 		UpdatedAt         [2]byte // 更新时间
+		LastOnlineAt      [2]byte // 最后一次上线时间
+		LastOfflineAt     [2]byte // 最后一次离线时间
 	}
 	Index struct {
 		Uid [2]byte
@@ -143,6 +145,8 @@ var TableUser = struct {
 		RecvMsgBytes      [2]byte // 接受消息字节数量
 		CreatedAt         [2]byte
 		UpdatedAt         [2]byte
+		LastOnlineAt      [2]byte
+		LastOfflineAt     [2]byte
 	}{
 		Uid:               [2]byte{0x02, 0x01},
 		DeviceCount:       [2]byte{0x02, 0x02},
@@ -154,6 +158,8 @@ var TableUser = struct {
 		RecvMsgBytes:      [2]byte{0x02, 0x08},
 		CreatedAt:         [2]byte{0x02, 0x09},
 		UpdatedAt:         [2]byte{0x02, 0x0A},
+		LastOnlineAt:      [2]byte{0x02, 0x0B},
+		LastOfflineAt:     [2]byte{0x02, 0x0C},
 	},
 	Index: struct {
 		Uid [2]byte
@@ -177,12 +183,15 @@ var TableDevice = struct {
 	IndexSize       int
 	SecondIndexSize int
 	Column          struct {
-		Uid         [2]byte // 用户uid
-		Token       [2]byte // 设备Token
-		DeviceFlag  [2]byte // 设备标识
-		DeviceLevel [2]byte // 设备等级
-		CreatedAt   [2]byte // 创建时间
-		UpdatedAt   [2]byte // 更新时间
+		Uid           [2]byte // 用户uid
+		Token         [2]byte // 设备Token
+		DeviceFlag    [2]byte // 设备标识
+		DeviceLevel   [2]byte // 设备等级
+		CreatedAt     [2]byte // 创建时间
+		UpdatedAt     [2]byte // 更新时间
+		LastOnlineAt  [2]byte // 最后一次上线时间
+		LastOfflineAt [2]byte // 最后一次离线时间
+		TokenExpireAt [2]byte // token过期时间
 	}
 	SecondIndex struct {
 		Uid         [2]byte
@@ -197,19 +206,25 @@ var TableDevice = struct {
 	IndexSize:       2 + 2 + 2 + 8,     // tableId + dataType + indexName + columnValue
 	SecondIndexSize: 2 + 2 + 2 + 8 + 8, // tableId + dataType + secondIndexName + columnValue + primaryKey
 	Column: struct {
-		Uid         [2]byte
-		Token       [2]byte
-		DeviceFlag  [2]byte
-		DeviceLevel [2]byte
-		CreatedAt   [2]byte
-		UpdatedAt   [2]byte
-	}{
-		Uid:         [2]byte{0x03, 0x01},
-		Token:       [2]byte{0x03, 0x02},
-		DeviceFlag:  [2]byte{0x03, 0x03},
-		DeviceLevel: [2]byte{0x03, 0x04},
-		CreatedAt:   [2]byte{0x03, 0x05},
-		UpdatedAt:   [2]byte{0x03, 0x06},
+		Uid           [2]byte
+		Token         [2]byte
+		DeviceFlag    [2]byte
+		DeviceLevel   [2]byte
+		CreatedAt     [2]byte
+		UpdatedAt     [2]byte
+		LastOnlineAt  [2]byte
+		LastOfflineAt [2]byte
+		TokenExpireAt [2]byte
+	}{
+		Uid:           [2]byte{0x03, 0x01},
+		Token:         [2]byte{0x03, 0x02},
+		DeviceFlag:    [2]byte{0x03, 0x03},
+		DeviceLevel:   [2]byte{0x03, 0x04},
+		CreatedAt:     [2]byte{0x03, 0x05},
+		UpdatedAt:     [2]byte{0x03, 0x06},
+		LastOnlineAt:  [2]byte{0x03, 0x07},
+		LastOfflineAt: [2]byte{0x03, 0x08},
+		TokenExpireAt: [2]byte{0x03, 0x09},
 	},
 	SecondIndex: struct {
 		Uid         [2]byte
@@ -313,6 +328,8 @@ var TableChannelInfo = struct {
 		DenylistCount   [2]byte // 黑名单数量
 		CreatedAt       [2]byte
 		UpdatedAt       [2]byte
+		SendRateLimit   [2]byte // 频道发送速率限制
+		SlowMode        [2]byte // 慢速模式
 	}
 	Index struct {
 		Channel [2]byte
@@ -343,6 +360,8 @@ var TableChannelInfo = struct {
 		DenylistCount   [2]byte
 		CreatedAt       [2]byte
 		UpdatedAt       [2]byte
+		SendRateLimit   [2]byte
+		SlowMode        [2]byte
 	}{
 		Id:              [2]byte{0x06, 0x01},
 		ChannelId:       [2]byte{0x06, 0x02},
@@ -355,6 +374,8 @@ var TableChannelInfo = struct {
 		DenylistCount:   [2]byte{0x06, 0x09},
 		CreatedAt:       [2]byte{0x06, 0x0A},
 		UpdatedAt:       [2]byte{0x06, 0x0B},
+		SendRateLimit:   [2]byte{0x06, 0x0C},
+		SlowMode:        [2]byte{0x06, 0x0D},
 	},
 	Index: struct {
 		Channel [2]byte
@@ -795,3 +816,71 @@ var TableTester = struct {
 		UpdatedAt: [2]byte{0x14, 0x04},
 	},
 }
+
+// ======================== TableLoginLog ========================
+
+// 用户登录日志表
+var TableLoginLog = struct {
+	Id   [2]byte
+	Size int
+}{
+	Id:   [2]byte{0x15, 0x01},
+	Size: 2 + 2 + 8 + 8, // tableId + dataType + uid hash + createdAt
+}
+
+// ======================== TableApiKey ========================
+
+// api key表
+var TableApiKey = struct {
+	Id   [2]byte
+	Size int
+}{
+	Id:   [2]byte{0x16, 0x01},
+	Size: 2 + 2 + 8, // tableId + dataType + primaryKey
+}
+
+// ======================== TableRevokedToken ========================
+
+// 吊销的管理后台token表
+var TableRevokedToken = struct {
+	Id   [2]byte
+	Size int
+}{
+	Id:   [2]byte{0x17, 0x01},
+	Size: 2 + 2 + 8, // tableId + dataType + token id hash
+}
+
+// ======================== TableAuditLog ========================
+
+// 审计日志表
+var TableAuditLog = struct {
+	Id            [2]byte
+	Size          int
+	SlotIndexSize int
+}{
+	Id:            [2]byte{0x18, 0x01},
+	Size:          2 + 2 + 8,     // tableId + dataType + id（雪花id，按时间递增）
+	SlotIndexSize: 2 + 2 + 4 + 8, // tableId + dataType + slotId + id（生成槽快照时按槽导出）
+}
+
+// ======================== TableIpRule ========================
+
+// 连接的ip规则表（黑白名单）
+var TableIpRule = struct {
+	Id   [2]byte
+	Size int
+}{
+	Id:   [2]byte{0x19, 0x01},
+	Size: 2 + 2 + 8, // tableId + dataType + primaryKey
+}
+
+// ======================== TableSlotEntity ========================
+
+// 槽的数据对象索引表（记录槽里有哪些频道、用户、流，生成槽快照时使用）
+var TableSlotEntity = struct {
+	Id   [2]byte
+	Size int
+}{
+	Id:   [2]byte{0x1A, 0x01},
+	Size: 2 + 2 + 4 + 1 + 8, // tableId + dataType + slotId + entityType + entity hash
+}
@@ -0,0 +1,140 @@
+package wkdb
+
+import (
+	"math"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb/key"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+	"github.com/cockroachdb/pebble"
+)
+
+func (wk *wukongDB) AddLoginLog(log LoginLog, maxCount int) error {
+
+	if log.CreatedAt == nil {
+		now := time.Now()
+		log.CreatedAt = &now
+	}
+
+	db := wk.shardDB(log.Uid)
+	batch := wk.sharedBatchDB(log.Uid).NewBatch()
+
+	// 超过最大数量的旧日志删除（新日志占一个位置）
+	if maxCount > 0 {
+		iter := db.NewIter(&pebble.IterOptions{
+			LowerBound: key.NewLoginLogKey(log.Uid, 0),
+			UpperBound: key.NewLoginLogKey(log.Uid, math.MaxUint64),
+		})
+		count := 0
+		for iter.Last(); iter.Valid(); iter.Prev() {
+			var l LoginLog
+			if err := l.Decode(iter.Value()); err != nil {
+				iter.Close()
+				return err
+			}
+			if l.Uid != log.Uid {
+				continue
+			}
+			count++
+			if count >= maxCount {
+				batch.Delete(append([]byte(nil), iter.Key()...))
+			}
+		}
+		iter.Close()
+	}
+
+	batch.Set(key.NewLoginLogKey(log.Uid, uint64(log.CreatedAt.UnixNano())), log.Encode())
+
+	return batch.CommitWait()
+}
+
+func (wk *wukongDB) GetLoginLogs(uid string, limit int) ([]LoginLog, error) {
+
+	db := wk.shardDB(uid)
+	iter := db.NewIter(&pebble.IterOptions{
+		LowerBound: key.NewLoginLogKey(uid, 0),
+		UpperBound: key.NewLoginLogKey(uid, math.MaxUint64),
+	})
+	defer iter.Close()
+
+	var logs []LoginLog
+	for iter.Last(); iter.Valid(); iter.Prev() {
+		var l LoginLog
+		if err := l.Decode(iter.Value()); err != nil {
+			return nil, err
+		}
+		if l.Uid != uid {
+			continue
+		}
+		logs = append(logs, l)
+		if limit > 0 && len(logs) >= limit {
+			break
+		}
+	}
+	return logs, nil
+}
+
+// LoginLog 用户登录日志
+type LoginLog struct {
+	version     int16      // 数据版本
+	Uid         string     // 用户uid
+	DeviceFlag  uint64     // 设备标记
+	DeviceLevel uint8      // 设备等级
+	DeviceId    string     // 设备id
+	Ip          string     // 登录ip
+	NodeId      uint64     // 登录的节点
+	CreatedAt   *time.Time // 登录时间
+}
+
+func (l *LoginLog) Encode() []byte {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteInt16(int(l.version))
+	enc.WriteString(l.Uid)
+	enc.WriteUint64(l.DeviceFlag)
+	enc.WriteUint8(l.DeviceLevel)
+	enc.WriteString(l.DeviceId)
+	enc.WriteString(l.Ip)
+	enc.WriteUint64(l.NodeId)
+	var createdAt int64
+	if l.CreatedAt != nil {
+		createdAt = l.CreatedAt.UnixNano()
+	}
+	enc.WriteInt64(createdAt)
+	return enc.Bytes()
+}
+
+func (l *LoginLog) Decode(data []byte) error {
+	dec := wkproto.NewDecoder(data)
+	var err error
+	if l.version, err = dec.Int16(); err != nil {
+		return err
+	}
+	if l.Uid, err = dec.String(); err != nil {
+		return err
+	}
+	if l.DeviceFlag, err = dec.Uint64(); err != nil {
+		return err
+	}
+	if l.DeviceLevel, err = dec.Uint8(); err != nil {
+		return err
+	}
+	if l.DeviceId, err = dec.String(); err != nil {
+		return err
+	}
+	if l.Ip, err = dec.String(); err != nil {
+		return err
+	}
+	if l.NodeId, err = dec.Uint64(); err != nil {
+		return err
+	}
+	var createdAt int64
+	if createdAt, err = dec.Int64(); err != nil {
+		return err
+	}
+	if createdAt > 0 {
+		t := time.Unix(0, createdAt)
+		l.CreatedAt = &t
+	}
+	return nil
+}
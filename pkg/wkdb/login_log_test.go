@@ -0,0 +1,78 @@
+package wkdb_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddLoginLog(t *testing.T) {
+	d := newTestDB(t)
+	err := d.Open()
+	assert.NoError(t, err)
+
+	defer func() {
+		err := d.Close()
+		assert.NoError(t, err)
+	}()
+
+	tn := time.Now()
+	err = d.AddLoginLog(wkdb.LoginLog{
+		Uid:         "test",
+		DeviceFlag:  1,
+		DeviceLevel: 1,
+		DeviceId:    "device1",
+		Ip:          "127.0.0.1",
+		NodeId:      1,
+		CreatedAt:   &tn,
+	}, 10)
+	assert.NoError(t, err)
+
+	logs, err := d.GetLoginLogs("test", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(logs))
+	assert.Equal(t, "test", logs[0].Uid)
+	assert.Equal(t, uint64(1), logs[0].DeviceFlag)
+	assert.Equal(t, uint8(1), logs[0].DeviceLevel)
+	assert.Equal(t, "device1", logs[0].DeviceId)
+	assert.Equal(t, "127.0.0.1", logs[0].Ip)
+	assert.Equal(t, uint64(1), logs[0].NodeId)
+	assert.Equal(t, tn.UnixNano(), logs[0].CreatedAt.UnixNano())
+}
+
+func TestGetLoginLogs(t *testing.T) {
+	d := newTestDB(t)
+	err := d.Open()
+	assert.NoError(t, err)
+
+	defer func() {
+		err := d.Close()
+		assert.NoError(t, err)
+	}()
+
+	tn := time.Now()
+	for i := 0; i < 5; i++ {
+		createdAt := tn.Add(time.Duration(i) * time.Second)
+		err = d.AddLoginLog(wkdb.LoginLog{
+			Uid:        "test",
+			DeviceFlag: uint64(i),
+			CreatedAt:  &createdAt,
+		}, 3)
+		assert.NoError(t, err)
+	}
+
+	// 只保留最新的3条，按时间倒序
+	logs, err := d.GetLoginLogs("test", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(logs))
+	assert.Equal(t, uint64(4), logs[0].DeviceFlag)
+	assert.Equal(t, uint64(3), logs[1].DeviceFlag)
+	assert.Equal(t, uint64(2), logs[2].DeviceFlag)
+
+	logs, err = d.GetLoginLogs("test", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(logs))
+	assert.Equal(t, uint64(4), logs[0].DeviceFlag)
+}
@@ -119,6 +119,10 @@ type Device struct {
 	RecvMsgBytes uint64     `json:"recv_msg_bytes,omitempty"` // 接收消息字节数
 	CreatedAt    *time.Time `json:"created_at,omitempty"`     // 创建时间
 	UpdatedAt    *time.Time `json:"updated_at,omitempty"`     // 更新时间
+
+	LastOnlineAt  *time.Time `json:"last_online_at,omitempty"`  // 最后一次上线时间
+	LastOfflineAt *time.Time `json:"last_offline_at,omitempty"` // 最后一次离线时间
+	TokenExpireAt *time.Time `json:"token_expire_at,omitempty"` // token过期时间 为空表示永不过期
 }
 
 var EmptyUser = User{}
@@ -139,6 +143,8 @@ type User struct {
 	RecvMsgBytes      uint64     `json:"recv_msg_bytes,omitempty"`      // 接收消息字节数
 	CreatedAt         *time.Time `json:"created_at,omitempty"`          // 创建时间
 	UpdatedAt         *time.Time `json:"updated_at,omitempty"`          // 更新时间
+	LastOnlineAt      *time.Time `json:"last_online_at,omitempty"`      // 最后一次上线时间
+	LastOfflineAt     *time.Time `json:"last_offline_at,omitempty"`     // 最后一次离线时间
 }
 
 var EmptyChannelInfo = ChannelInfo{}
@@ -156,6 +162,8 @@ type ChannelInfo struct {
 	LastMsgSeq      uint64     `json:"last_msg_seq,omitempty"`     // 最新消息序号
 	LastMsgTime     uint64     `json:"last_msg_time,omitempty"`    // 最后一次消息时间
 	Webhook         string     `json:"webhook,omitempty"`          // webhook地址
+	SendRateLimit   uint32     `json:"send_rate_limit,omitempty"`  // 频道的发送速率限制（所有成员每秒最多发送的消息数量） 0表示使用全局配置
+	SlowMode        uint32     `json:"slow_mode,omitempty"`        // 慢速模式（每个成员两条消息之间的最小间隔秒数） 0表示使用全局配置
 	CreatedAt       *time.Time `json:"created_at,omitempty"`       // 创建时间
 	UpdatedAt       *time.Time `json:"updated_at,omitempty"`       // 更新时间
 }
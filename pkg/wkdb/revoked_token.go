@@ -0,0 +1,115 @@
+package wkdb
+
+import (
+	"math"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb/key"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+	"github.com/cockroachdb/pebble"
+)
+
+func (wk *wukongDB) AddRevokedToken(token RevokedToken) error {
+	if token.Id == "" {
+		return ErrInvalidTokenId
+	}
+	if token.CreatedAt == nil {
+		now := time.Now()
+		token.CreatedAt = &now
+	}
+
+	batch := wk.defaultShardBatchDB().NewBatch()
+
+	// 删除已经过期的吊销记录（以token的创建时间为准，保证各个副本删除的数据一致）
+	iter := wk.defaultShardDB().NewIter(&pebble.IterOptions{
+		LowerBound: key.NewRevokedTokenKey(0),
+		UpperBound: key.NewRevokedTokenKey(math.MaxUint64),
+	})
+	for iter.First(); iter.Valid(); iter.Next() {
+		var t RevokedToken
+		if err := t.Decode(iter.Value()); err != nil {
+			iter.Close()
+			return err
+		}
+		if t.ExpireAt != nil && !t.ExpireAt.After(*token.CreatedAt) {
+			batch.Delete(append([]byte(nil), iter.Key()...))
+		}
+	}
+	iter.Close()
+
+	batch.Set(key.NewRevokedTokenKey(key.HashWithString(token.Id)), token.Encode())
+
+	return batch.CommitWait()
+}
+
+func (wk *wukongDB) GetRevokedTokens() ([]RevokedToken, error) {
+	iter := wk.defaultShardDB().NewIter(&pebble.IterOptions{
+		LowerBound: key.NewRevokedTokenKey(0),
+		UpperBound: key.NewRevokedTokenKey(math.MaxUint64),
+	})
+	defer iter.Close()
+
+	var tokens []RevokedToken
+	for iter.First(); iter.Valid(); iter.Next() {
+		var t RevokedToken
+		if err := t.Decode(iter.Value()); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, nil
+}
+
+// RevokedToken 吊销的管理后台token
+type RevokedToken struct {
+	version   int16      // 数据版本
+	Id        string     `json:"id"`                   // token的会话id（sid）或者token id（jti）
+	ExpireAt  *time.Time `json:"expire_at,omitempty"`  // 过期时间，过期后吊销记录可以删除
+	CreatedAt *time.Time `json:"created_at,omitempty"` // 吊销时间
+}
+
+func (r *RevokedToken) Encode() []byte {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteInt16(int(r.version))
+	enc.WriteString(r.Id)
+	var expireAt int64
+	if r.ExpireAt != nil {
+		expireAt = r.ExpireAt.UnixNano()
+	}
+	enc.WriteInt64(expireAt)
+	var createdAt int64
+	if r.CreatedAt != nil {
+		createdAt = r.CreatedAt.UnixNano()
+	}
+	enc.WriteInt64(createdAt)
+	return enc.Bytes()
+}
+
+func (r *RevokedToken) Decode(data []byte) error {
+	dec := wkproto.NewDecoder(data)
+	var err error
+	if r.version, err = dec.Int16(); err != nil {
+		return err
+	}
+	if r.Id, err = dec.String(); err != nil {
+		return err
+	}
+	var expireAt int64
+	if expireAt, err = dec.Int64(); err != nil {
+		return err
+	}
+	if expireAt > 0 {
+		t := time.Unix(0, expireAt)
+		r.ExpireAt = &t
+	}
+	var createdAt int64
+	if createdAt, err = dec.Int64(); err != nil {
+		return err
+	}
+	if createdAt > 0 {
+		t := time.Unix(0, createdAt)
+		r.CreatedAt = &t
+	}
+	return nil
+}
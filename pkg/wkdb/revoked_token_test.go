@@ -0,0 +1,74 @@
+package wkdb_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddRevokedToken(t *testing.T) {
+	d := newTestDB(t)
+	err := d.Open()
+	assert.NoError(t, err)
+
+	defer func() {
+		err := d.Close()
+		assert.NoError(t, err)
+	}()
+
+	tn := time.Now()
+	expireAt := tn.Add(time.Hour)
+	err = d.AddRevokedToken(wkdb.RevokedToken{
+		Id:        "sid1",
+		ExpireAt:  &expireAt,
+		CreatedAt: &tn,
+	})
+	assert.NoError(t, err)
+
+	tokens, err := d.GetRevokedTokens()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(tokens))
+	assert.Equal(t, "sid1", tokens[0].Id)
+	assert.Equal(t, expireAt.UnixNano(), tokens[0].ExpireAt.UnixNano())
+	assert.Equal(t, tn.UnixNano(), tokens[0].CreatedAt.UnixNano())
+
+	err = d.AddRevokedToken(wkdb.RevokedToken{})
+	assert.Equal(t, wkdb.ErrInvalidTokenId, err)
+}
+
+func TestAddRevokedTokenRemoveExpired(t *testing.T) {
+	d := newTestDB(t)
+	err := d.Open()
+	assert.NoError(t, err)
+
+	defer func() {
+		err := d.Close()
+		assert.NoError(t, err)
+	}()
+
+	tn := time.Now()
+	expireAt := tn.Add(time.Minute)
+	err = d.AddRevokedToken(wkdb.RevokedToken{
+		Id:        "sid1",
+		ExpireAt:  &expireAt,
+		CreatedAt: &tn,
+	})
+	assert.NoError(t, err)
+
+	// 在sid1过期之后添加，sid1将被删除
+	createdAt := tn.Add(time.Hour)
+	expireAt2 := createdAt.Add(time.Hour)
+	err = d.AddRevokedToken(wkdb.RevokedToken{
+		Id:        "sid2",
+		ExpireAt:  &expireAt2,
+		CreatedAt: &createdAt,
+	})
+	assert.NoError(t, err)
+
+	tokens, err := d.GetRevokedTokens()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(tokens))
+	assert.Equal(t, "sid2", tokens[0].Id)
+}
@@ -0,0 +1,112 @@
+package wkdb
+
+import (
+	"math"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb/key"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+	"github.com/cockroachdb/pebble"
+)
+
+func (wk *wukongDB) AddSlotEntities(slotId uint32, entities []SlotEntity) error {
+	if len(entities) == 0 {
+		return nil
+	}
+	batch := wk.defaultShardDB().NewBatch()
+	defer batch.Close()
+	for _, entity := range entities {
+		if err := batch.Set(entity.key(slotId), entity.Encode(), wk.noSync); err != nil {
+			return err
+		}
+	}
+	return batch.Commit(wk.sync)
+}
+
+func (wk *wukongDB) GetSlotEntities(slotId uint32) ([]SlotEntity, error) {
+	iter := wk.defaultShardDB().NewIter(&pebble.IterOptions{
+		LowerBound: key.NewSlotEntityKey(slotId, 0, 0),
+		UpperBound: key.NewSlotEntityKey(slotId, math.MaxUint8, math.MaxUint64),
+	})
+	defer iter.Close()
+
+	var entities []SlotEntity
+	for iter.First(); iter.Valid(); iter.Next() {
+		var entity SlotEntity
+		if err := entity.Decode(iter.Value()); err != nil {
+			return nil, err
+		}
+		entities = append(entities, entity)
+	}
+	return entities, nil
+}
+
+func (wk *wukongDB) RemoveSlotEntities(slotId uint32) error {
+	return wk.defaultShardDB().DeleteRange(key.NewSlotEntityKey(slotId, 0, 0), key.NewSlotEntityKey(slotId, math.MaxUint8, math.MaxUint64), wk.sync)
+}
+
+// SlotEntityType 槽的数据对象类型
+type SlotEntityType uint8
+
+const (
+	SlotEntityChannel SlotEntityType = 1 // 频道（频道信息、订阅者、黑白名单、频道分布式配置）
+	SlotEntityUser    SlotEntityType = 2 // 用户（用户信息、设备、最近会话、登录日志）
+	SlotEntityStream  SlotEntityType = 3 // 流（流元数据和流数据）
+)
+
+// SlotEntity 槽的数据对象
+// 槽的数据分散在各个表里，而且大部分表的key只有hash，所以应用日志时记录槽里有哪些数据对象，生成快照时按对象导出
+type SlotEntity struct {
+	version     int16          // 数据版本
+	Type        SlotEntityType // 对象类型
+	Id          string         // 频道id、用户uid或者流编号
+	ChannelType uint8          // 频道类型（频道才有）
+}
+
+func NewSlotChannelEntity(channelId string, channelType uint8) SlotEntity {
+	return SlotEntity{Type: SlotEntityChannel, Id: channelId, ChannelType: channelType}
+}
+
+func NewSlotUserEntity(uid string) SlotEntity {
+	return SlotEntity{Type: SlotEntityUser, Id: uid}
+}
+
+func NewSlotStreamEntity(streamNo string) SlotEntity {
+	return SlotEntity{Type: SlotEntityStream, Id: streamNo}
+}
+
+func (s SlotEntity) key(slotId uint32) []byte {
+	if s.Type == SlotEntityChannel {
+		return key.NewSlotEntityKey(slotId, uint8(s.Type), key.ChannelToNum(s.Id, s.ChannelType))
+	}
+	return key.NewSlotEntityKey(slotId, uint8(s.Type), key.HashWithString(s.Id))
+}
+
+func (s *SlotEntity) Encode() []byte {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteInt16(int(s.version))
+	enc.WriteUint8(uint8(s.Type))
+	enc.WriteString(s.Id)
+	enc.WriteUint8(s.ChannelType)
+	return enc.Bytes()
+}
+
+func (s *SlotEntity) Decode(data []byte) error {
+	dec := wkproto.NewDecoder(data)
+	var err error
+	if s.version, err = dec.Int16(); err != nil {
+		return err
+	}
+	var tp uint8
+	if tp, err = dec.Uint8(); err != nil {
+		return err
+	}
+	s.Type = SlotEntityType(tp)
+	if s.Id, err = dec.String(); err != nil {
+		return err
+	}
+	if s.ChannelType, err = dec.Uint8(); err != nil {
+		return err
+	}
+	return nil
+}
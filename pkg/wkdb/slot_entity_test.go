@@ -0,0 +1,79 @@
+package wkdb_test
+
+import (
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddSlotEntities(t *testing.T) {
+	d := newTestDB(t)
+	err := d.Open()
+	assert.NoError(t, err)
+
+	defer func() {
+		err := d.Close()
+		assert.NoError(t, err)
+	}()
+
+	err = d.AddSlotEntities(1, []wkdb.SlotEntity{
+		wkdb.NewSlotChannelEntity("g1", 2),
+		wkdb.NewSlotUserEntity("u1"),
+		wkdb.NewSlotStreamEntity("s1"),
+	})
+	assert.NoError(t, err)
+
+	// 重复记录的对象只保留一个，同一个频道id不同频道类型是不同的对象
+	err = d.AddSlotEntities(1, []wkdb.SlotEntity{
+		wkdb.NewSlotChannelEntity("g1", 2),
+		wkdb.NewSlotChannelEntity("g1", 1),
+		wkdb.NewSlotUserEntity("u1"),
+	})
+	assert.NoError(t, err)
+
+	err = d.AddSlotEntities(2, []wkdb.SlotEntity{
+		wkdb.NewSlotUserEntity("u2"),
+	})
+	assert.NoError(t, err)
+
+	entities, err := d.GetSlotEntities(1)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []wkdb.SlotEntity{
+		wkdb.NewSlotChannelEntity("g1", 2),
+		wkdb.NewSlotChannelEntity("g1", 1),
+		wkdb.NewSlotUserEntity("u1"),
+		wkdb.NewSlotStreamEntity("s1"),
+	}, entities)
+
+	entities, err = d.GetSlotEntities(2)
+	assert.NoError(t, err)
+	assert.Equal(t, []wkdb.SlotEntity{wkdb.NewSlotUserEntity("u2")}, entities)
+}
+
+func TestRemoveSlotEntities(t *testing.T) {
+	d := newTestDB(t)
+	err := d.Open()
+	assert.NoError(t, err)
+
+	defer func() {
+		err := d.Close()
+		assert.NoError(t, err)
+	}()
+
+	err = d.AddSlotEntities(1, []wkdb.SlotEntity{wkdb.NewSlotUserEntity("u1")})
+	assert.NoError(t, err)
+	err = d.AddSlotEntities(2, []wkdb.SlotEntity{wkdb.NewSlotUserEntity("u2")})
+	assert.NoError(t, err)
+
+	err = d.RemoveSlotEntities(1)
+	assert.NoError(t, err)
+
+	entities, err := d.GetSlotEntities(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(entities))
+
+	entities, err = d.GetSlotEntities(2)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(entities))
+}
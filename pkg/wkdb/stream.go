@@ -23,10 +23,13 @@ func (wk *wukongDB) GetStreamMeta(streamNo string) (*StreamMeta, error) {
 	db := wk.shardDB(streamNo)
 	keyBytes := key.NewStreamMetaKey(streamNo)
 	valueBytes, closer, err := db.Get(keyBytes)
-	defer closer.Close()
 	if err != nil {
+		if err == pebble.ErrNotFound {
+			return nil, nil
+		}
 		return nil, err
 	}
+	defer closer.Close()
 
 	if len(valueBytes) == 0 {
 		return nil, nil
@@ -62,10 +65,7 @@ func (wk *wukongDB) AddStreams(streams []*Stream) error {
 func (wk *wukongDB) GetStreams(streamNo string) ([]*Stream, error) {
 	db := wk.shardDB(streamNo)
 
-	batch := db.NewBatch()
-	defer batch.Close()
-
-	iter := batch.NewIter(&pebble.IterOptions{
+	iter := db.NewIter(&pebble.IterOptions{
 		LowerBound: key.NewStreamIndexKey(streamNo, 0),
 		UpperBound: key.NewStreamIndexKey(streamNo, math.MaxUint64),
 	})
@@ -281,6 +281,54 @@ func (wk *wukongDB) UpdateUser(u User) error {
 
 // }
 
+// UpdateUserOnlineStatus 更新用户和设备的最后上线/离线时间
+func (wk *wukongDB) UpdateUserOnlineStatus(uid string, deviceFlag uint64, online bool, at time.Time) error {
+
+	id, err := wk.getUserId(uid)
+	if err != nil {
+		return err
+	}
+
+	db := wk.sharedBatchDB(uid)
+	batch := db.NewBatch()
+
+	exist, err := wk.existUser(uid)
+	if err != nil {
+		return err
+	}
+
+	u := User{Id: id, Uid: uid}
+	if !exist { // 用户不存在（比如未开启token验证的情况），则顺便创建用户
+		u.CreatedAt = &at
+		u.UpdatedAt = &at
+	}
+	if online {
+		u.LastOnlineAt = &at
+	} else {
+		u.LastOfflineAt = &at
+	}
+	if err = wk.writeUser(u, batch); err != nil {
+		return err
+	}
+
+	// 设备存在则更新设备的上线/离线时间
+	deviceId, err := wk.getDeviceId(uid, deviceFlag)
+	if err != nil && err != ErrNotFound {
+		return err
+	}
+	if deviceId != 0 {
+		column := key.TableDevice.Column.LastOfflineAt
+		if online {
+			column = key.TableDevice.Column.LastOnlineAt
+		}
+		var atBytes = make([]byte, 8)
+		wk.endian.PutUint64(atBytes, uint64(at.UnixNano()))
+		batch.Set(key.NewDeviceColumnKey(deviceId, column), atBytes)
+	}
+
+	return batch.CommitWait()
+}
+
 func (wk *wukongDB) getUserId(uid string) (uint64, error) {
 	// indexKey := key.NewUserIndexUidKey(uid)
 	// uidIndexValue, closer, err := wk.shardDB(uid).Get(indexKey)
@@ -326,6 +374,20 @@ func (wk *wukongDB) writeUser(u User, w *Batch) error {
 
 	}
 
+	if u.LastOnlineAt != nil {
+		// lastOnlineAt
+		var lastOnlineAtBytes = make([]byte, 8)
+		wk.endian.PutUint64(lastOnlineAtBytes, uint64(u.LastOnlineAt.UnixNano()))
+		w.Set(key.NewUserColumnKey(u.Id, key.TableUser.Column.LastOnlineAt), lastOnlineAtBytes)
+	}
+
+	if u.LastOfflineAt != nil {
+		// lastOfflineAt
+		var lastOfflineAtBytes = make([]byte, 8)
+		wk.endian.PutUint64(lastOfflineAtBytes, uint64(u.LastOfflineAt.UnixNano()))
+		w.Set(key.NewUserColumnKey(u.Id, key.TableUser.Column.LastOfflineAt), lastOfflineAtBytes)
+	}
+
 	// write index
 	if err = wk.writeUserIndex(u, w); err != nil {
 		return err
@@ -418,6 +480,18 @@ func (wk *wukongDB) iteratorUser(iter *pebble.Iterator, iterFnc func(u User) boo
 				t := time.Unix(tm/1e9, tm%1e9)
 				preUser.UpdatedAt = &t
 			}
+		case key.TableUser.Column.LastOnlineAt:
+			tm := int64(wk.endian.Uint64(iter.Value()))
+			if tm > 0 {
+				t := time.Unix(tm/1e9, tm%1e9)
+				preUser.LastOnlineAt = &t
+			}
+		case key.TableUser.Column.LastOfflineAt:
+			tm := int64(wk.endian.Uint64(iter.Value()))
+			if tm > 0 {
+				t := time.Unix(tm/1e9, tm%1e9)
+				preUser.LastOfflineAt = &t
+			}
 
 		}
 		lastNeedAppend = true
@@ -125,3 +125,46 @@ func TestExistUser(t *testing.T) {
 	assert.NoError(t, err)
 	assert.True(t, exist)
 }
+
+func TestUpdateUserOnlineStatus(t *testing.T) {
+	d := newTestDB(t)
+	err := d.Open()
+	assert.NoError(t, err)
+
+	defer func() {
+		err := d.Close()
+		assert.NoError(t, err)
+	}()
+
+	tn := time.Now()
+	err = d.AddDevice(wkdb.Device{
+		Id:          1,
+		Uid:         "test",
+		Token:       "token",
+		DeviceFlag:  1,
+		DeviceLevel: 1,
+		CreatedAt:   &tn,
+		UpdatedAt:   &tn,
+	})
+	assert.NoError(t, err)
+
+	onlineAt := time.Now()
+	err = d.UpdateUserOnlineStatus("test", 1, true, onlineAt)
+	assert.NoError(t, err)
+
+	offlineAt := onlineAt.Add(time.Minute)
+	err = d.UpdateUserOnlineStatus("test", 1, false, offlineAt)
+	assert.NoError(t, err)
+
+	u, err := d.GetUser("test")
+	assert.NoError(t, err)
+	assert.Equal(t, "test", u.Uid)
+	assert.Equal(t, onlineAt.UnixNano(), u.LastOnlineAt.UnixNano())
+	assert.Equal(t, offlineAt.UnixNano(), u.LastOfflineAt.UnixNano())
+
+	device, err := d.GetDevice("test", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "token", device.Token)
+	assert.Equal(t, onlineAt.UnixNano(), device.LastOnlineAt.UnixNano())
+	assert.Equal(t, offlineAt.UnixNano(), device.LastOfflineAt.UnixNano())
+}
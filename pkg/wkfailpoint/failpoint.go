@@ -0,0 +1,208 @@
+// Package wkfailpoint 故障注入点，用于测试时在代码关键路径上模拟错误、延迟和崩溃。
+//
+// term 的语法和 pingcap/failpoint 相同（return(v)、sleep(ms)、panic、off 以及 N*action），
+// 但没有使用 pingcap/failpoint 的标记加仓库根目录的 failpoint-ctl，原因：
+//
+//   - failpoint-ctl 要先把源码里的 failpoint.Inject 标记改写成真正的代码才能生效，改写是直接修改工作区的文件，
+//     每次测试前后都要 enable/disable，中途失败会留下被改写的源码，也不能和普通的 go build/go test 共用一份代码
+//   - go.mod 没有依赖 pingcap/failpoint 的运行时库，仓库里的 failpoint-ctl 是 macOS（Mach-O）的二进制，Linux 的 CI 上无法执行
+//   - internal/testcluster 把节点作为子进程启动，需要在节点运行中通过控制接口随时开关注入点，
+//     这里的注入点在运行时判断，正式编译的程序里也存在，不需要为测试单独编译一份
+//
+// 代价是注入点会编译进正式的程序，未开启任何注入点时只有一次原子读的开销。
+// term 和 pingcap/failpoint 兼容，以后改用 failpoint-ctl 时注入点的名字和测试里的 term 都不需要改。
+package wkfailpoint
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrNotExist  = errors.New("failpoint: not exist")
+	ErrBadTerm   = errors.New("failpoint: bad term")
+	enabledCount atomic.Int32 // 开启的注入点数量
+	registry     = &failpoints{points: make(map[string]*failpoint)}
+)
+
+// Value 注入点的返回值，可能是 int、bool 或 string
+type Value interface{}
+
+type action int
+
+const (
+	actionOff action = iota
+	actionReturn
+	actionSleep
+	actionPanic
+)
+
+type failpoint struct {
+	act   action
+	val   Value
+	sleep time.Duration
+	count int64 // 剩余触发次数，小于0表示不限制
+	term  string
+}
+
+type failpoints struct {
+	mu     sync.RWMutex
+	points map[string]*failpoint
+}
+
+// Enable 开启注入点
+// term 例子: return(1)、return("err")、return(true)、sleep(100)、panic、3*return(1)、off
+func Enable(name string, term string) error {
+	fp, err := parseTerm(term)
+	if err != nil {
+		return err
+	}
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	_, exist := registry.points[name]
+	if fp.act == actionOff {
+		if exist {
+			delete(registry.points, name)
+			enabledCount.Add(-1)
+		}
+		return nil
+	}
+	registry.points[name] = fp
+	if !exist {
+		enabledCount.Add(1)
+	}
+	return nil
+}
+
+// Disable 关闭注入点
+func Disable(name string) error {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if _, ok := registry.points[name]; !ok {
+		return ErrNotExist
+	}
+	delete(registry.points, name)
+	enabledCount.Add(-1)
+	return nil
+}
+
+// DisableAll 关闭所有注入点
+func DisableAll() {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.points = make(map[string]*failpoint)
+	enabledCount.Store(0)
+}
+
+// Status 获取注入点的term
+func Status(name string) (string, error) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	fp, ok := registry.points[name]
+	if !ok {
+		return "", ErrNotExist
+	}
+	return fp.term, nil
+}
+
+// Eval 执行注入点，sleep和panic在这里生效，return返回注入的值
+// 注入点未开启或已用完触发次数时ok为false
+func Eval(name string) (Value, bool) {
+	if enabledCount.Load() == 0 {
+		return nil, false
+	}
+	registry.mu.Lock()
+	fp, ok := registry.points[name]
+	if !ok {
+		registry.mu.Unlock()
+		return nil, false
+	}
+	if fp.count == 0 {
+		registry.mu.Unlock()
+		return nil, false
+	}
+	if fp.count > 0 {
+		fp.count--
+	}
+	act, val, sleep := fp.act, fp.val, fp.sleep
+	registry.mu.Unlock()
+
+	switch act {
+	case actionSleep:
+		time.Sleep(sleep)
+		return nil, false
+	case actionPanic:
+		panic(fmt.Sprintf("failpoint %s panic", name))
+	}
+	return val, true
+}
+
+// Inject 注入点触发return时执行fn
+func Inject(name string, fn func(val Value)) {
+	val, ok := Eval(name)
+	if ok {
+		fn(val)
+	}
+}
+
+func parseTerm(term string) (*failpoint, error) {
+	fp := &failpoint{count: -1, term: term}
+	s := strings.TrimSpace(term)
+	if i := strings.Index(s, "*"); i > 0 {
+		count, err := strconv.ParseInt(strings.TrimSpace(s[:i]), 10, 64)
+		if err != nil || count <= 0 {
+			return nil, ErrBadTerm
+		}
+		fp.count = count
+		s = strings.TrimSpace(s[i+1:])
+	}
+
+	name, arg := s, ""
+	if i := strings.Index(s, "("); i > 0 {
+		if !strings.HasSuffix(s, ")") {
+			return nil, ErrBadTerm
+		}
+		name, arg = s[:i], strings.TrimSpace(s[i+1:len(s)-1])
+	}
+
+	switch name {
+	case "off":
+		fp.act = actionOff
+	case "panic":
+		fp.act = actionPanic
+	case "sleep":
+		ms, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil || ms < 0 {
+			return nil, ErrBadTerm
+		}
+		fp.act = actionSleep
+		fp.sleep = time.Duration(ms) * time.Millisecond
+	case "return":
+		fp.act = actionReturn
+		fp.val = parseValue(arg)
+	default:
+		return nil, ErrBadTerm
+	}
+	return fp, nil
+}
+
+func parseValue(arg string) Value {
+	if arg == "" {
+		return struct{}{}
+	}
+	if v, err := strconv.Unquote(arg); err == nil {
+		return v
+	}
+	if v, err := strconv.ParseBool(arg); err == nil {
+		return v
+	}
+	if v, err := strconv.Atoi(arg); err == nil {
+		return v
+	}
+	return arg
+}
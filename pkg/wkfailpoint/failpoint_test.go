@@ -0,0 +1,81 @@
+package wkfailpoint
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnableReturn(t *testing.T) {
+	defer DisableAll()
+
+	_, ok := Eval("test/return")
+	assert.False(t, ok)
+
+	err := Enable("test/return", `return("drop")`)
+	assert.Nil(t, err)
+	val, ok := Eval("test/return")
+	assert.True(t, ok)
+	assert.Equal(t, "drop", val)
+
+	err = Enable("test/return", "return(1001)")
+	assert.Nil(t, err)
+	var got Value
+	Inject("test/return", func(v Value) {
+		got = v
+	})
+	assert.Equal(t, 1001, got)
+
+	err = Disable("test/return")
+	assert.Nil(t, err)
+	_, ok = Eval("test/return")
+	assert.False(t, ok)
+
+	err = Disable("test/return")
+	assert.Equal(t, ErrNotExist, err)
+}
+
+func TestEnableCount(t *testing.T) {
+	defer DisableAll()
+
+	err := Enable("test/count", "2*return(true)")
+	assert.Nil(t, err)
+
+	hits := 0
+	for i := 0; i < 5; i++ {
+		if _, ok := Eval("test/count"); ok {
+			hits++
+		}
+	}
+	assert.Equal(t, 2, hits)
+}
+
+func TestEnableSleepAndPanic(t *testing.T) {
+	defer DisableAll()
+
+	err := Enable("test/sleep", "sleep(20)")
+	assert.Nil(t, err)
+	start := time.Now()
+	_, ok := Eval("test/sleep")
+	assert.False(t, ok)
+	assert.GreaterOrEqual(t, time.Since(start), time.Millisecond*20)
+
+	err = Enable("test/panic", "panic")
+	assert.Nil(t, err)
+	assert.Panics(t, func() {
+		Eval("test/panic")
+	})
+
+	err = Enable("test/panic", "off")
+	assert.Nil(t, err)
+	_, err = Status("test/panic")
+	assert.Equal(t, ErrNotExist, err)
+}
+
+func TestEnableBadTerm(t *testing.T) {
+	for _, term := range []string{"", "foo", "return(1", "sleep(x)", "0*return(1)"} {
+		err := Enable("test/bad", term)
+		assert.Equal(t, ErrBadTerm, err, term)
+	}
+}
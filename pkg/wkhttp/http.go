@@ -120,10 +120,12 @@ func (c *Context) ForwardWithBody(url string, body []byte) {
 	for key, value := range values {
 		queryMap[key] = value[0]
 	}
+	headers := c.CopyRequestHeader(c.Request)
+	headers[HeaderForwarded] = "1"
 	req := rest.Request{
 		Method:      rest.Method(strings.ToUpper(c.Request.Method)),
 		BaseURL:     url,
-		Headers:     c.CopyRequestHeader(c.Request),
+		Headers:     headers,
 		Body:        body,
 		QueryParams: queryMap,
 	}
@@ -139,6 +141,9 @@ func (c *Context) ForwardWithBody(url string, body []byte) {
 	_, _ = c.Writer.Write([]byte(resp.Body))
 }
 
+// HeaderForwarded 节点之间转发的请求会携带此请求头
+const HeaderForwarded = "X-Wk-Forwarded"
+
 // Forward 转发请求
 func (c *Context) Forward(url string) {
 	bodyBytes, _ := io.ReadAll(c.Request.Body)
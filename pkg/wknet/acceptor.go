@@ -24,9 +24,11 @@ type acceptor struct {
 	listenPoller      *netpoll.Poller
 	listenWSPoller    *netpoll.Poller
 	listenWSSPoller   *netpoll.Poller
+	listenMqttPoller  *netpoll.Poller
 	listen            *listener
 	listenWS          *listener // websocket
 	listenWSS         *listener // websocket
+	listenMqtt        *listener // mqtt
 	tcpRealListenAddr net.Addr  // tcp real listen addr
 	wsRealListenAddr  net.Addr  // websocket real listen addr
 
@@ -39,12 +41,13 @@ func newAcceptor(eg *Engine) *acceptor {
 		reactorSubs[i] = NewReactorSub(eg, i)
 	}
 	a := &acceptor{
-		eg:              eg,
-		reactorSubs:     reactorSubs,
-		listenPoller:    netpoll.NewPoller(0, "listenerPoller"),
-		listenWSPoller:  netpoll.NewPoller(0, "listenWSPoller"),
-		listenWSSPoller: netpoll.NewPoller(0, "listenWSSPoller"),
-		Log:             wklog.NewWKLog("Acceptor"),
+		eg:               eg,
+		reactorSubs:      reactorSubs,
+		listenPoller:     netpoll.NewPoller(0, "listenerPoller"),
+		listenWSPoller:   netpoll.NewPoller(0, "listenWSPoller"),
+		listenWSSPoller:  netpoll.NewPoller(0, "listenWSSPoller"),
+		listenMqttPoller: netpoll.NewPoller(0, "listenMqttPoller"),
+		Log:              wklog.NewWKLog("Acceptor"),
 	}
 
 	return a
@@ -93,6 +96,16 @@ func (a *acceptor) start() error {
 		}()
 	}
 
+	if strings.TrimSpace(a.eg.options.MqttAddr) != "" {
+		wg.Add(1)
+		go func() {
+			err := a.initMqttListener(wg)
+			if err != nil {
+				a.Panic("initMqttListener() failed", zap.Error(err))
+			}
+		}()
+	}
+
 	wg.Wait()
 	return nil
 }
@@ -136,6 +149,18 @@ func (a *acceptor) Stop() error {
 		}
 	}
 
+	// -----------------mqtt-----------------
+	err = a.listenMqttPoller.Close()
+	if err != nil {
+		a.Warn("listenMqttPoller.Close() failed", zap.Error(err))
+	}
+	if a.listenMqtt != nil {
+		err = a.listenMqtt.Close()
+		if err != nil {
+			a.Warn("listenMqtt.Close() failed", zap.Error(err))
+		}
+	}
+
 	// -----------------reactor sub-----------------
 	for _, reactorSub := range a.reactorSubs {
 		err = reactorSub.Stop()
@@ -167,6 +192,22 @@ func (a *acceptor) initTCPListener(wg *sync.WaitGroup) error {
 
 }
 
+func (a *acceptor) initMqttListener(wg *sync.WaitGroup) error {
+	// mqtt
+	a.listenMqtt = newListener(a.eg.options.MqttAddr, a.eg.options)
+	err := a.listenMqtt.init()
+	if err != nil {
+		return err
+	}
+	if err := a.listenMqttPoller.AddRead(a.listenMqtt.fd); err != nil {
+		return fmt.Errorf("add mqtt listener fd to poller failed %s", err)
+	}
+	wg.Done()
+	return a.listenMqttPoller.Polling(func(fd int, ev netpoll.PollEvent) error {
+		return a.acceptMqttConn(fd)
+	})
+}
+
 func (a *acceptor) initWSListener(wg *sync.WaitGroup) error {
 	// tcp
 	a.listenWS = newListener(a.eg.options.WsAddr, a.eg.options)
@@ -251,6 +292,41 @@ func (a *acceptor) acceptConn(listenFd int, ws bool, wss bool) error {
 	return nil
 }
 
+// 接受mqtt连接
+func (a *acceptor) acceptMqttConn(listenFd int) error {
+	connFd, sa, err := unix.Accept(listenFd)
+	if err != nil {
+		if err == unix.EAGAIN {
+			return nil
+		}
+		a.Error("Accept() failed", zap.Error(err))
+		return perrors.ErrAcceptSocket
+	}
+	if err = os.NewSyscallError("fcntl nonblock", unix.SetNonblock(connFd, true)); err != nil {
+		return err
+	}
+	remoteAddr := socket.SockaddrToTCPOrUnixAddr(sa)
+	if a.eg.options.TCPKeepAlive > 0 {
+		if err = socket.SetKeepAlivePeriod(connFd, int(a.eg.options.TCPKeepAlive.Seconds())); err != nil {
+			a.Error("SetKeepAlivePeriod() failed", zap.Error(err))
+		}
+	}
+	subReactor := a.reactorSubByConnFd(connFd)
+	conn, err := a.eg.eventHandler.OnNewMqttConn(a.eg.GenClientID(), newNetFd(connFd), a.mqttRealAddr(), remoteAddr, a.eg, subReactor)
+	if err != nil {
+		return err
+	}
+	err = subReactor.AddConn(conn)
+	if err != nil {
+		a.Warn("subReactor.AddConn() failed", zap.Error(err))
+	}
+	err = a.eg.eventHandler.OnConnect(conn)
+	if err != nil {
+		a.Warn("OnConnect() failed", zap.Error(err))
+	}
+	return nil
+}
+
 func (a *acceptor) reactorSubByConnFd(connfd int) *ReactorSub {
 
 	return a.reactorSubs[connfd%len(a.reactorSubs)]
@@ -267,3 +343,7 @@ func (a *acceptor) wsRealAddr() net.Addr {
 func (a *acceptor) wssRealAddr() net.Addr {
 	return a.listenWSS.realAddr
 }
+
+func (a *acceptor) mqttRealAddr() net.Addr {
+	return a.listenMqtt.realAddr
+}
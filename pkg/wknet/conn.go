@@ -118,6 +118,10 @@ type Conn interface {
 
 type IWSConn interface {
 	WriteServerBinary(data []byte) error
+	// WriteServerMessage 写入一条消息，协商了子协议时以文本帧写入（wss接受的子协议都是文本协议，例如wukongim.json），否则以二进制帧写入
+	WriteServerMessage(data []byte) error
+	// Subprotocol 升级时协商的子协议，没有协商时为空
+	Subprotocol() string
 }
 
 type DefaultConn struct {
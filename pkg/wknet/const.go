@@ -6,3 +6,12 @@ var (
 	// ErrUnsupportedOp occurs when calling some methods that has not been implemented yet.
 	ErrUnsupportedOp = errors.New("unsupported operation")
 )
+
+var (
+	// ErrInboundOverflow occurs when the inbound buffer exceeds the maximum size.
+	ErrInboundOverflow = errors.New("inbound buffer overflow")
+	// ErrOutboundOverflow occurs when the frames not received by the client exceed the maximum number.
+	ErrOutboundOverflow = errors.New("outbound buffer overflow")
+	// ErrNoProtoTranslator occurs when a translated connection has no translator.
+	ErrNoProtoTranslator = errors.New("no proto translator")
+)
@@ -0,0 +1,352 @@
+package wknet
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/RussellLuo/timingwheel"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+)
+
+// HttpFrame http连接下发的数据
+type HttpFrame struct {
+	Seq  uint64 // 下发序号（连接内递增，作为客户端恢复的游标）
+	Data []byte
+}
+
+// HttpConn http长连接（SSE/长轮询）的虚拟连接，没有对应的文件描述符
+// 上行数据通过Push写入后交给OnData处理，下行数据按递增的序号缓存，由http请求根据游标拉取
+type HttpConn struct {
+	id         atomic.Int64
+	eg         *Engine
+	remoteAddr net.Addr
+	localAddr  net.Addr
+
+	inMu          sync.Mutex
+	inboundBuffer InboundBuffer
+
+	mu        sync.Mutex
+	frames    []HttpFrame   // 还没被客户端确认的下行数据
+	seq       uint64        // 最新的下行序号
+	notify    chan struct{} // 有新的下行数据时关闭
+	maxFrames int           // 最多缓存的下行数据数量
+
+	closed  atomic.Bool
+	closeCh chan struct{}
+
+	context      atomic.Value
+	authed       atomic.Bool
+	uid          atomic.String
+	valueMap     sync.Map
+	uptime       atomic.Time
+	lastActivity atomic.Time
+	maxIdleLock  sync.Mutex
+	idleTimer    *timingwheel.Timer
+
+	wklog.Log
+}
+
+// NewHttpConn 创建http连接，maxFrames为最多缓存的下行数据数量（客户端断开后可以从游标处恢复）
+func (e *Engine) NewHttpConn(localAddr, remoteAddr net.Addr, maxFrames int) (*HttpConn, error) {
+	id := e.GenClientID()
+	h := &HttpConn{
+		eg:         e,
+		localAddr:  localAddr,
+		remoteAddr: remoteAddr,
+		notify:     make(chan struct{}),
+		maxFrames:  maxFrames,
+		closeCh:    make(chan struct{}),
+		Log:        wklog.NewWKLog(fmt.Sprintf("HttpConn[%d]", id)),
+	}
+	h.id.Store(id)
+	h.uptime.Store(time.Now())
+	h.lastActivity.Store(time.Now())
+	h.inboundBuffer = e.eventHandler.OnNewInboundConn(h, e)
+
+	if err := e.eventHandler.OnConnect(h); err != nil {
+		return nil, err
+	}
+	if h.closed.Load() { // 连接被拒绝
+		return nil, net.ErrClosed
+	}
+	return h, nil
+}
+
+// Push 写入客户端上行的数据
+func (h *HttpConn) Push(data []byte) error {
+	if h.closed.Load() {
+		return net.ErrClosed
+	}
+	h.inMu.Lock()
+	defer h.inMu.Unlock()
+
+	if h.eg.options.MaxReadBufferSize > 0 && h.inboundBuffer.BoundBufferSize()+len(data) > h.eg.options.MaxReadBufferSize {
+		return ErrInboundOverflow
+	}
+	if _, err := h.inboundBuffer.Write(data); err != nil {
+		return err
+	}
+	h.KeepLastActivity()
+	if h.eg.options.Event.OnReadBytes != nil {
+		h.eg.options.Event.OnReadBytes(len(data))
+	}
+	err := h.eg.eventHandler.OnData(h)
+	if h.closed.Load() { // OnData内关闭了连接
+		_ = h.inboundBuffer.Release()
+	}
+	return err
+}
+
+// Frames 获取序号大于cursor的下行数据，cursor之前（包含）的数据视为客户端已收到，将被清除
+// 返回的notify在有新数据或者连接关闭时关闭
+func (h *HttpConn) Frames(cursor uint64) ([]HttpFrame, <-chan struct{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	i := 0
+	for i < len(h.frames) && h.frames[i].Seq <= cursor {
+		i++
+	}
+	if i > 0 {
+		h.frames = append(h.frames[:0], h.frames[i:]...)
+	}
+	frames := make([]HttpFrame, len(h.frames))
+	copy(frames, h.frames)
+	return frames, h.notify
+}
+
+// Done 连接关闭时关闭
+func (h *HttpConn) Done() <-chan struct{} {
+	return h.closeCh
+}
+
+// KeepLastActivity 更新最后活动时间（上行数据和下行拉取都算活动）
+func (h *HttpConn) KeepLastActivity() {
+	h.lastActivity.Store(time.Now())
+}
+
+func (h *HttpConn) ID() int64 {
+	return h.id.Load()
+}
+
+func (h *HttpConn) SetID(id int64) {
+	h.id.Store(id)
+}
+
+func (h *HttpConn) UID() string {
+	return h.uid.Load()
+}
+
+func (h *HttpConn) SetUID(uid string) {
+	h.uid.Store(uid)
+}
+
+func (h *HttpConn) SetValue(key string, value interface{}) {
+	h.valueMap.Store(key, value)
+}
+
+func (h *HttpConn) Value(key string) interface{} {
+	value, _ := h.valueMap.Load(key)
+	return value
+}
+
+func (h *HttpConn) Flush() error {
+	return nil
+}
+
+func (h *HttpConn) Read(buf []byte) (int, error) {
+	if h.inboundBuffer.IsEmpty() {
+		return 0, nil
+	}
+	return h.inboundBuffer.Read(buf)
+}
+
+func (h *HttpConn) Peek(n int) ([]byte, error) {
+	totalLen := h.inboundBuffer.BoundBufferSize()
+	if n > totalLen {
+		return nil, io.ErrShortBuffer
+	} else if n <= 0 {
+		n = totalLen
+	}
+	if h.inboundBuffer.IsEmpty() {
+		return nil, nil
+	}
+	head, tail := h.inboundBuffer.Peek(n)
+	data := make([]byte, 0, len(head)+len(tail))
+	data = append(data, head...)
+	data = append(data, tail...)
+	return data, nil
+}
+
+func (h *HttpConn) Discard(n int) (int, error) {
+	return h.inboundBuffer.Discard(n)
+}
+
+func (h *HttpConn) Write(b []byte) (int, error) {
+	return h.WriteToOutboundBuffer(b)
+}
+
+func (h *HttpConn) WriteToOutboundBuffer(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+	if h.closed.Load() {
+		return -1, net.ErrClosed
+	}
+	data := make([]byte, len(b))
+	copy(data, b)
+
+	h.mu.Lock()
+	if h.maxFrames > 0 && len(h.frames) >= h.maxFrames {
+		// 不能丢弃客户端没收到的数据（客户端无法感知丢失），关闭会话，客户端重新建立会话后通过同步补齐
+		h.mu.Unlock()
+		h.Warn("too many frames not received by client, close the conn", zap.Int("maxFrames", h.maxFrames))
+		_ = h.Close()
+		return 0, ErrOutboundOverflow
+	}
+	defer h.mu.Unlock()
+	h.seq++
+	h.frames = append(h.frames, HttpFrame{Seq: h.seq, Data: data})
+	if h.eg.options.Event.OnWirteBytes != nil {
+		h.eg.options.Event.OnWirteBytes(len(b))
+	}
+	return len(b), nil
+}
+
+func (h *HttpConn) WakeWrite() error {
+	if h.closed.Load() {
+		return net.ErrClosed
+	}
+	h.mu.Lock()
+	close(h.notify)
+	h.notify = make(chan struct{})
+	h.mu.Unlock()
+	return nil
+}
+
+func (h *HttpConn) Fd() NetFd {
+	return NetFd{}
+}
+
+func (h *HttpConn) IsClosed() bool {
+	return h.closed.Load()
+}
+
+func (h *HttpConn) Close() error {
+	if !h.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+	h.maxIdleLock.Lock()
+	if h.idleTimer != nil {
+		h.idleTimer.Stop()
+		h.idleTimer = nil
+	}
+	h.maxIdleLock.Unlock()
+
+	close(h.closeCh)
+	h.eg.eventHandler.OnClose(h)
+
+	// 正在处理上行数据时由Push释放
+	if h.inMu.TryLock() {
+		_ = h.inboundBuffer.Release()
+		h.inMu.Unlock()
+	}
+	return nil
+}
+
+func (h *HttpConn) CloseWithErr(err error) error {
+	return h.Close()
+}
+
+func (h *HttpConn) RemoteAddr() net.Addr {
+	return h.remoteAddr
+}
+
+func (h *HttpConn) SetRemoteAddr(addr net.Addr) {
+	h.remoteAddr = addr
+}
+
+func (h *HttpConn) LocalAddr() net.Addr {
+	return h.localAddr
+}
+
+func (h *HttpConn) ReactorSub() *ReactorSub {
+	return nil
+}
+
+func (h *HttpConn) ReadToInboundBuffer() (int, error) {
+	return 0, ErrUnsupportedOp
+}
+
+func (h *HttpConn) SetContext(ctx interface{}) {
+	h.context.Store(ctx)
+}
+
+func (h *HttpConn) Context() interface{} {
+	return h.context.Load()
+}
+
+func (h *HttpConn) IsAuthed() bool {
+	return h.authed.Load()
+}
+
+func (h *HttpConn) SetAuthed(authed bool) {
+	h.authed.Store(authed)
+}
+
+func (h *HttpConn) LastActivity() time.Time {
+	return h.lastActivity.Load()
+}
+
+func (h *HttpConn) Uptime() time.Time {
+	return h.uptime.Load()
+}
+
+// SetMaxIdle 超过maxIdle没有活动则关闭连接（客户端没有下行请求也没有上行数据时会话过期）
+func (h *HttpConn) SetMaxIdle(maxIdle time.Duration) {
+	h.maxIdleLock.Lock()
+	defer h.maxIdleLock.Unlock()
+
+	if h.closed.Load() {
+		return
+	}
+	if h.idleTimer != nil {
+		h.idleTimer.Stop()
+		h.idleTimer = nil
+	}
+	if maxIdle <= 0 {
+		return
+	}
+	h.idleTimer = h.eg.Schedule(maxIdle/2, func() {
+		if h.lastActivity.Load().Add(maxIdle).After(time.Now()) {
+			return
+		}
+		h.Debug("max idle time exceeded, close the connection", zap.Duration("maxIdle", maxIdle))
+		_ = h.Close()
+	})
+}
+
+func (h *HttpConn) InboundBuffer() InboundBuffer {
+	return h.inboundBuffer
+}
+
+func (h *HttpConn) OutboundBuffer() OutboundBuffer {
+	return nil
+}
+
+func (h *HttpConn) SetDeadline(t time.Time) error {
+	return ErrUnsupportedOp
+}
+
+func (h *HttpConn) SetReadDeadline(t time.Time) error {
+	return ErrUnsupportedOp
+}
+
+func (h *HttpConn) SetWriteDeadline(t time.Time) error {
+	return ErrUnsupportedOp
+}
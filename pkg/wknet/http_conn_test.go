@@ -0,0 +1,79 @@
+package wknet
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHttpConn(t *testing.T) {
+	e := NewEngine()
+
+	var received []byte
+	closed := false
+	e.OnData(func(conn Conn) error {
+		buff, err := conn.Peek(-1)
+		if err != nil {
+			return err
+		}
+		received = append(received, buff...)
+		_, _ = conn.Discard(len(buff))
+		// 回写数据
+		_, err = conn.WriteToOutboundBuffer(buff)
+		if err != nil {
+			return err
+		}
+		return conn.WakeWrite()
+	})
+	e.OnClose(func(conn Conn) {
+		closed = true
+	})
+
+	conn, err := e.NewHttpConn(nil, &net.TCPAddr{IP: net.ParseIP("127.0.0.1")}, 2)
+	assert.NoError(t, err)
+
+	_, notify := conn.Frames(0)
+
+	err = conn.Push([]byte("hello"))
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(received))
+
+	select {
+	case <-notify:
+	default:
+		t.Fatal("notify is not closed")
+	}
+
+	err = conn.Push([]byte("world"))
+	assert.NoError(t, err)
+
+	frames, _ := conn.Frames(0)
+	assert.Equal(t, 2, len(frames))
+	assert.Equal(t, uint64(1), frames[0].Seq)
+	assert.Equal(t, "hello", string(frames[0].Data))
+
+	// 游标之前的数据被清除
+	frames, _ = conn.Frames(1)
+	assert.Equal(t, 1, len(frames))
+	assert.Equal(t, uint64(2), frames[0].Seq)
+
+	_ = conn.Push([]byte("a"))
+	frames, _ = conn.Frames(1)
+	assert.Equal(t, 2, len(frames))
+	assert.Equal(t, uint64(3), frames[1].Seq)
+
+	// 超过缓存数量关闭连接，不丢弃数据
+	err = conn.Push([]byte("b"))
+	assert.ErrorIs(t, err, ErrOutboundOverflow)
+	assert.True(t, closed)
+	assert.True(t, conn.IsClosed())
+	frames, _ = conn.Frames(1)
+	assert.Equal(t, 2, len(frames))
+
+	err = conn.Close()
+	assert.NoError(t, err)
+
+	err = conn.Push([]byte("closed"))
+	assert.Equal(t, net.ErrClosed, err)
+}
@@ -0,0 +1,116 @@
+package wknet
+
+import (
+	"io"
+	"net"
+)
+
+// ProtoTranslator 协议转换器，将其他协议（比如mqtt、websocket的json协议）与悟空IM协议互相转换
+type ProtoTranslator interface {
+	// Inbound 将客户端发来的数据转换为悟空IM协议的数据写入w，返回消费的字节数（数据不完整时返回0）
+	Inbound(conn Conn, data []byte, w io.Writer) (int, error)
+	// Outbound 将悟空IM协议的数据转换为客户端协议的数据
+	Outbound(conn Conn, data []byte) ([]byte, error)
+}
+
+// IMqttConn mqtt连接
+type IMqttConn interface {
+	// WriteMqtt 直接写入mqtt协议的数据（不经过协议转换）
+	WriteMqtt(data []byte) error
+}
+
+func CreateMqttConn(id int64, connFd NetFd, localAddr, remoteAddr net.Addr, eg *Engine, reactorSub *ReactorSub) (Conn, error) {
+	defaultConn := GetDefaultConn(id, connFd, localAddr, remoteAddr, eg, reactorSub)
+	return NewMqttConn(defaultConn), nil
+}
+
+// MqttConn mqtt连接
+// 读到的mqtt数据经过协议转换器转换为悟空IM协议的数据后再放入inboundBuffer，写入的悟空IM协议数据转换为mqtt数据后再写出
+type MqttConn struct {
+	*DefaultConn
+	tmpInboundBuffer InboundBuffer // 未转换的mqtt数据
+}
+
+func NewMqttConn(d *DefaultConn) *MqttConn {
+	return &MqttConn{
+		DefaultConn:      d,
+		tmpInboundBuffer: d.eg.eventHandler.OnNewInboundConn(d, d.eg),
+	}
+}
+
+func (m *MqttConn) ReadToInboundBuffer() (int, error) {
+	readBuffer := m.reactorSub.ReadBuffer
+	n, err := m.fd.Read(readBuffer)
+	if err != nil || n == 0 {
+		return 0, err
+	}
+	if m.eg.options.Event.OnReadBytes != nil {
+		m.eg.options.Event.OnReadBytes(n)
+	}
+	if m.overflowForInbound(m.tmpInboundBuffer.BoundBufferSize() + n) {
+		return 0, ErrInboundOverflow
+	}
+	_, err = m.tmpInboundBuffer.Write(readBuffer[:n])
+	if err != nil {
+		return 0, err
+	}
+	m.KeepLastActivity()
+
+	err = m.translateInbound()
+	return n, err
+}
+
+// 将tmpInboundBuffer内完整的mqtt数据包转换后写入inboundBuffer
+func (m *MqttConn) translateInbound() error {
+	translator := m.eg.options.MqttTranslator
+	if translator == nil {
+		return ErrNoProtoTranslator
+	}
+	for !m.tmpInboundBuffer.IsEmpty() {
+		head, tail := m.tmpInboundBuffer.Peek(-1)
+		m.reactorSub.cache.Reset()
+		m.reactorSub.cache.Write(head)
+		m.reactorSub.cache.Write(tail)
+
+		size, err := translator.Inbound(m, m.reactorSub.cache.Bytes(), m.inboundBuffer)
+		if err != nil {
+			return err
+		}
+		if size == 0 {
+			return nil
+		}
+		_, _ = m.tmpInboundBuffer.Discard(size)
+	}
+	return nil
+}
+
+// WriteToOutboundBuffer 写入的是悟空IM协议的数据，需要转换为mqtt数据
+func (m *MqttConn) WriteToOutboundBuffer(b []byte) (int, error) {
+	translator := m.eg.options.MqttTranslator
+	if translator == nil {
+		return 0, ErrNoProtoTranslator
+	}
+	data, err := translator.Outbound(m, b)
+	if err != nil {
+		return 0, err
+	}
+	if len(data) > 0 {
+		if _, err = m.DefaultConn.WriteToOutboundBuffer(data); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// WriteMqtt 直接写入mqtt协议的数据
+func (m *MqttConn) WriteMqtt(data []byte) error {
+	if _, err := m.DefaultConn.WriteToOutboundBuffer(data); err != nil {
+		return err
+	}
+	return m.WakeWrite()
+}
+
+func (m *MqttConn) Close() error {
+	_ = m.tmpInboundBuffer.Release()
+	return m.DefaultConn.Close()
+}
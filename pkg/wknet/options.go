@@ -18,6 +18,14 @@ type Options struct {
 	// WsAddr is the listen addr  example: ws://127.0.0.1:5200或 wss://127.0.0.1:5200
 	WsAddr  string
 	WssAddr string // wss addr
+	// MqttAddr is the mqtt listen addr example: tcp://127.0.0.1:1883
+	MqttAddr string
+	// MqttTranslator translate mqtt packets to wukongim packets
+	MqttTranslator ProtoTranslator
+	// WSSSubprotocols websocket subprotocols accepted during upgrade (Sec-WebSocket-Protocol), only on the wss listener
+	WSSSubprotocols []string
+	// WSCompression websocket permessage-deflate compression, nil means disabled
+	WSCompression *WSCompressionOptions
 	// WSTlsConfig ws tls config
 	// MaxOpenFiles is the maximum number of open files that the server can
 	MaxOpenFiles int
@@ -56,6 +64,34 @@ func NewOptions() *Options {
 
 type Option func(opts *Options)
 
+// WithMqttAddr set mqtt listen addr
+func WithMqttAddr(v string) Option {
+	return func(opts *Options) {
+		opts.MqttAddr = v
+	}
+}
+
+// WithMqttTranslator set mqtt translator
+func WithMqttTranslator(v ProtoTranslator) Option {
+	return func(opts *Options) {
+		opts.MqttTranslator = v
+	}
+}
+
+// WithWSSSubprotocols set websocket subprotocols accepted during upgrade on the wss listener
+func WithWSSSubprotocols(v ...string) Option {
+	return func(opts *Options) {
+		opts.WSSSubprotocols = v
+	}
+}
+
+// WithWSCompression set websocket permessage-deflate compression
+func WithWSCompression(v *WSCompressionOptions) Option {
+	return func(opts *Options) {
+		opts.WSCompression = v
+	}
+}
+
 // WithAddr set listen addr
 func WithAddr(v string) Option {
 	return func(opts *Options) {
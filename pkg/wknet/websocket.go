@@ -30,9 +30,28 @@ func CreateWSSConn(id int64, connFd NetFd, localAddr, remoteAddr net.Addr, eg *E
 	return NewWSSConn(tc), nil
 }
 
+// websocket升级器，只接受subprotocols中的子协议（ws监听不接受子协议，只有wss监听接受配置的子协议），配置了压缩时才协商permessage-deflate
+func wsUpgrader(subprotocols []string, deflateNegotiator *wsDeflateNegotiator) ws.Upgrader {
+	u := ws.Upgrader{
+		Protocol: func(p []byte) bool {
+			for _, subprotocol := range subprotocols {
+				if string(p) == subprotocol {
+					return true
+				}
+			}
+			return false
+		},
+	}
+	if deflateNegotiator != nil {
+		u.Negotiate = deflateNegotiator.Negotiate
+	}
+	return u
+}
+
 type WSConn struct {
 	*DefaultConn
 	upgraded         bool
+	deflate          *wsDeflate    // 协商了permessage-deflate时不为nil
 	tmpInboundBuffer InboundBuffer // inboundBuffer InboundBuffer
 }
 
@@ -67,7 +86,16 @@ func (w *WSConn) ReadToInboundBuffer() (int, error) {
 func (w *WSConn) WriteServerBinary(data []byte) error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
-	return wsutil.WriteServerBinary(w.outboundBuffer, data)
+	return w.deflate.writeServerMessage(w.outboundBuffer, ws.OpBinary, data)
+}
+
+// WriteServerMessage ws监听不协商子协议，都以二进制帧写入
+func (w *WSConn) WriteServerMessage(data []byte) error {
+	return w.WriteServerBinary(data)
+}
+
+func (w *WSConn) Subprotocol() string {
+	return ""
 }
 
 // 解包ws的数据
@@ -112,40 +140,23 @@ func (w *WSConn) decode() ([]wsutil.Message, error) {
 		w.Debug("数据不完整", zap.Int("len", len(buff)))
 		return nil, nil
 	}
-	tmpReader := bytes.NewReader(buff)
-	header, err := ws.ReadHeader(tmpReader)
+	messages, size, err := readClientMessages(buff, w.deflate)
 	if err != nil {
-		if err == io.EOF || err == io.ErrUnexpectedEOF { //数据不完整
-			return nil, nil
-		}
-		w.Debug("发送错误，丢弃数据", zap.Error(err))
+		w.Warn("read client message error", zap.Error(err))
 		w.DiscardFromTemp(len(buff)) // 发送错误，丢弃数据
+		w.writeClose(err)
 		return nil, err
 	}
-	dataLen := header.Length
-	if dataLen > int64(tmpReader.Len()) { // 数据不完整
-		w.Debug("数据不完整", zap.Int64("dataLen", dataLen), zap.Int64("tmpReader.Len()", int64(tmpReader.Len())))
-		return nil, nil
-	}
+	w.DiscardFromTemp(size)
+	return messages, nil
+}
 
-	if header.Fin { // 当前 frame 已经是最后一个frame
-		var messages []wsutil.Message
-		tmpReader.Reset(buff)
-		remLen := tmpReader.Len()
-		for tmpReader.Len() > 0 {
-			messages, err = wsutil.ReadClientMessage(tmpReader, messages)
-			if err != nil {
-				w.Warn("read client message error", zap.Error(err))
-				break
-			}
-		}
-		remLen = remLen - tmpReader.Len()
-		w.DiscardFromTemp(remLen)
-		return messages, nil
-	} else {
-		w.Debug("ws header not is fin", zap.Int("len", len(buff)))
-	}
-	return nil, nil
+// 发送关闭帧（尽力而为），连接由调用方关闭
+func (w *WSConn) writeClose(err error) {
+	w.mu.Lock()
+	_ = ws.WriteFrame(w.outboundBuffer, wsCloseFrame(err))
+	w.mu.Unlock()
+	_ = w.Flush()
 }
 
 func (w *WSConn) upgrade() error {
@@ -155,7 +166,8 @@ func (w *WSConn) upgrade() error {
 	}
 	tmpReader := bytes.NewReader(buff)
 	tmpWriter := bytes.NewBuffer(nil)
-	_, err = ws.Upgrade(&readWrite{
+	deflateNegotiator := newWSDeflateNegotiator(w.eg.options.WSCompression)
+	_, err = wsUpgrader(nil, deflateNegotiator).Upgrade(&readWrite{
 		Reader: tmpReader,
 		Writer: tmpWriter,
 	})
@@ -166,6 +178,7 @@ func (w *WSConn) upgrade() error {
 		w.DiscardFromTemp(len(buff)) // 发送错误，丢弃数据
 		return err
 	}
+	w.deflate = deflateNegotiator.deflate(w.eg.options.MaxReadBufferSize)
 
 	// 解析http请求
 	req, err := w.parseHttpRequest(buff)
@@ -256,7 +269,9 @@ type readWrite struct {
 
 type WSSConn struct {
 	*TLSConn
-	upgraded bool
+	upgraded    bool
+	subprotocol string     // 协商的子协议
+	deflate     *wsDeflate // 协商了permessage-deflate时不为nil
 
 	wsTmpInboundBuffer InboundBuffer // inboundBuffer InboundBuffer
 }
@@ -340,7 +355,8 @@ func (w *WSSConn) upgrade() error {
 
 	tmpReader := bytes.NewReader(buff)
 	tmpWriter := bytes.NewBuffer(nil)
-	_, err = ws.Upgrade(&readWrite{
+	deflateNegotiator := newWSDeflateNegotiator(w.d.eg.options.WSCompression)
+	hs, err := wsUpgrader(w.d.eg.options.WSSSubprotocols, deflateNegotiator).Upgrade(&readWrite{
 		Reader: tmpReader,
 		Writer: tmpWriter,
 	})
@@ -351,6 +367,8 @@ func (w *WSSConn) upgrade() error {
 		w.discardFromWSTemp(len(buff)) // 发送错误，丢弃数据
 		return err
 	}
+	w.subprotocol = hs.Protocol
+	w.deflate = deflateNegotiator.deflate(w.d.eg.options.MaxReadBufferSize)
 	_, err = w.TLSConn.Write(tmpWriter.Bytes())
 	if err != nil {
 		return err
@@ -404,7 +422,21 @@ func (w *WSSConn) Close() error {
 func (w *WSSConn) WriteServerBinary(data []byte) error {
 	w.d.mu.Lock()
 	defer w.d.mu.Unlock()
-	return wsutil.WriteServerBinary(w.TLSConn, data)
+	return w.deflate.writeServerMessage(w.TLSConn, ws.OpBinary, data)
+}
+
+func (w *WSSConn) WriteServerMessage(data []byte) error {
+	op := ws.OpBinary
+	if w.subprotocol != "" {
+		op = ws.OpText
+	}
+	w.d.mu.Lock()
+	defer w.d.mu.Unlock()
+	return w.deflate.writeServerMessage(w.TLSConn, op, data)
+}
+
+func (w *WSSConn) Subprotocol() string {
+	return w.subprotocol
 }
 
 func (w *WSSConn) decode() ([]wsutil.Message, error) {
@@ -416,38 +448,21 @@ func (w *WSSConn) decode() ([]wsutil.Message, error) {
 		w.d.Debug("数据还没读完", zap.Int("len", len(buff)))
 		return nil, nil
 	}
-	tmpReader := bytes.NewReader(buff)
-	header, err := ws.ReadHeader(tmpReader)
+	messages, size, err := readClientMessages(buff, w.deflate)
 	if err != nil {
-		if err == io.EOF || err == io.ErrUnexpectedEOF { //数据不完整
-			return nil, nil
-		}
-		w.d.Debug("wss: 发送错误，丢弃数据", zap.Error(err))
+		w.d.Warn("wss: read client message error", zap.Error(err))
 		w.discardFromWSTemp(len(buff)) // 发送错误，丢弃数据
+		w.writeClose(err)
 		return nil, err
 	}
-	dataLen := header.Length
-	if dataLen > int64(tmpReader.Len()) { // 数据不完整
-		w.d.Debug("wss: 数据还没读完....", zap.Int("dataLen", int(dataLen)), zap.Int("tmpReader.Len()", int(tmpReader.Len())))
-		return nil, nil
-	}
-	if header.Fin { // 当前 frame 已经是最后一个frame
+	w.discardFromWSTemp(size)
+	return messages, nil
+}
 
-		var messages []wsutil.Message
-		tmpReader.Reset(buff)
-		remLen := tmpReader.Len()
-		for tmpReader.Len() > 0 {
-			messages, err = wsutil.ReadClientMessage(tmpReader, messages)
-			if err != nil {
-				w.d.Warn("read client message error", zap.Error(err))
-				break
-			}
-		}
-		remLen = remLen - tmpReader.Len()
-		w.discardFromWSTemp(remLen)
-		return messages, nil
-	} else {
-		w.d.Debug("wss: ws header not is fin", zap.Int("len", len(buff)))
-	}
-	return nil, nil
+// 发送关闭帧（尽力而为），连接由调用方关闭
+func (w *WSSConn) writeClose(err error) {
+	w.d.mu.Lock()
+	_ = ws.WriteFrame(w.TLSConn, wsCloseFrame(err))
+	w.d.mu.Unlock()
+	_ = w.d.Flush()
 }
@@ -0,0 +1,306 @@
+package wknet
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/gobwas/httphead"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsflate"
+	"github.com/gobwas/ws/wsutil"
+)
+
+var (
+	ErrWSMessageTooLarge = errors.New("websocket message too large")
+	ErrWSInvalidPayload  = errors.New("websocket invalid payload")
+)
+
+// 压缩数据同步刷新后的结尾（RFC 7692 发送时去掉，接收时补上），再加一个空的最后块让解压正常结束
+var deflateTail = []byte{0x00, 0x00, 0xff, 0xff, 0x01, 0x00, 0x00, 0xff, 0xff}
+
+// WSCompressionOptions websocket的permessage-deflate压缩（RFC 7692）配置
+type WSCompressionOptions struct {
+	ServerNoContextTakeover bool // 服务端每个消息单独压缩（不使用之前消息的上下文，压缩率低但是每个连接不用保留压缩器的状态）
+	ClientNoContextTakeover bool // 要求客户端每个消息单独压缩（服务端不用为每个连接保留解压的字典）
+	Threshold               int  // 大于等于此大小的消息才压缩（字节）
+	Level                   int  // 压缩级别 1-9
+}
+
+// websocket的permessage-deflate协商
+type wsDeflateNegotiator struct {
+	opts     *WSCompressionOptions
+	accepted bool
+	params   wsflate.Parameters // 协商结果
+}
+
+func newWSDeflateNegotiator(opts *WSCompressionOptions) *wsDeflateNegotiator {
+	if opts == nil {
+		return nil
+	}
+	return &wsDeflateNegotiator{opts: opts}
+}
+
+// Negotiate 处理客户端提供的扩展，只接受第一个可以满足的permessage-deflate
+func (n *wsDeflateNegotiator) Negotiate(opt httphead.Option) (httphead.Option, error) {
+	if n.accepted || !bytes.Equal(opt.Name, wsflate.ExtensionNameBytes) {
+		return httphead.Option{}, nil
+	}
+	var offer wsflate.Parameters
+	if err := offer.Parse(opt); err != nil { // 参数不合法的不接受，继续看下一个
+		return httphead.Option{}, nil
+	}
+	// flate的滑动窗口固定为32KB，不能满足客户端要求的更小的窗口
+	if offer.ServerMaxWindowBits.Defined() && offer.ServerMaxWindowBits < 15 {
+		return httphead.Option{}, nil
+	}
+	n.params = wsflate.Parameters{
+		ServerNoContextTakeover: offer.ServerNoContextTakeover || n.opts.ServerNoContextTakeover,
+		ClientNoContextTakeover: offer.ClientNoContextTakeover || n.opts.ClientNoContextTakeover,
+		ServerMaxWindowBits:     offer.ServerMaxWindowBits,
+	}
+	n.accepted = true
+	return n.params.Option(), nil
+}
+
+// 协商成功返回连接的压缩器，否则返回nil
+func (n *wsDeflateNegotiator) deflate(maxMessageSize int) *wsDeflate {
+	if n == nil || !n.accepted {
+		return nil
+	}
+	level := n.opts.Level
+	if level < flate.BestSpeed || level > flate.BestCompression {
+		level = flate.DefaultCompression
+	}
+	return &wsDeflate{
+		params:         n.params,
+		threshold:      n.opts.Threshold,
+		level:          level,
+		maxMessageSize: maxMessageSize,
+	}
+}
+
+// 没有上下文接管时每个消息单独压缩/解压，压缩器和解压器从池里取，用完放回，连接不用保留它们的状态
+var (
+	flateWriterPools [flate.BestCompression - flate.DefaultCompression + 1]sync.Pool // 按压缩级别区分
+	flateReaderPool  sync.Pool
+)
+
+func getFlateWriter(w io.Writer, level int) (*flate.Writer, error) {
+	if fw, ok := flateWriterPools[level-flate.DefaultCompression].Get().(*flate.Writer); ok {
+		fw.Reset(w)
+		return fw, nil
+	}
+	return flate.NewWriter(w, level)
+}
+
+func putFlateWriter(fw *flate.Writer, level int) {
+	flateWriterPools[level-flate.DefaultCompression].Put(fw)
+}
+
+func getFlateReader(r io.Reader) (io.ReadCloser, error) {
+	if fr, ok := flateReaderPool.Get().(io.ReadCloser); ok {
+		if err := fr.(flate.Resetter).Reset(r, nil); err != nil {
+			return nil, err
+		}
+		return fr, nil
+	}
+	return flate.NewReader(r), nil
+}
+
+// 连接的压缩状态
+// 压缩只在写入时调用（调用方持有连接的写锁），解压只在读事件里调用，两边的状态互不影响
+type wsDeflate struct {
+	params         wsflate.Parameters
+	threshold      int
+	level          int
+	maxMessageSize int // 解压后消息的最大大小 0表示不限制
+
+	// 服务端使用上下文接管时连接自己的压缩器
+	fw   *flate.Writer
+	wbuf bytes.Buffer
+
+	// 客户端使用上下文接管时连接自己的解压器
+	fr   io.ReadCloser
+	dict []byte // 之前解压出来的数据（最多一个窗口大小）
+}
+
+// 压缩服务端发出的消息
+func (d *wsDeflate) compress(p []byte) ([]byte, error) {
+	if d.params.ServerNoContextTakeover {
+		var buf bytes.Buffer
+		fw, err := getFlateWriter(&buf, d.level)
+		if err != nil {
+			return nil, err
+		}
+		defer putFlateWriter(fw, d.level)
+		return flateCompress(fw, &buf, p)
+	}
+	d.wbuf.Reset()
+	if d.fw == nil {
+		var err error
+		if d.fw, err = flate.NewWriter(&d.wbuf, d.level); err != nil {
+			return nil, err
+		}
+	}
+	out, err := flateCompress(d.fw, &d.wbuf, p)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]byte, len(out)) // wbuf会被下一个消息复用
+	copy(result, out)
+	return result, nil
+}
+
+// 压缩并同步刷新，返回buf里去掉同步刷新结尾的数据
+func flateCompress(fw *flate.Writer, buf *bytes.Buffer, p []byte) ([]byte, error) {
+	if _, err := fw.Write(p); err != nil {
+		return nil, err
+	}
+	if err := fw.Flush(); err != nil {
+		return nil, err
+	}
+	out := buf.Bytes()
+	return out[:len(out)-4], nil // 去掉同步刷新的结尾 0x00 0x00 0xff 0xff
+}
+
+// 解压客户端发来的消息
+func (d *wsDeflate) decompress(p []byte) ([]byte, error) {
+	src := io.MultiReader(bytes.NewReader(p), bytes.NewReader(deflateTail))
+	if d.params.ClientNoContextTakeover {
+		fr, err := getFlateReader(src)
+		if err != nil {
+			return nil, err
+		}
+		defer flateReaderPool.Put(fr)
+		return d.readMessage(fr)
+	}
+	if d.fr == nil {
+		d.fr = flate.NewReaderDict(src, d.dict)
+	} else if err := d.fr.(flate.Resetter).Reset(src, d.dict); err != nil {
+		return nil, err
+	}
+	out, err := d.readMessage(d.fr)
+	if err != nil {
+		return nil, err
+	}
+	d.dict = append(d.dict, out...)
+	if len(d.dict) > wsflate.MaxLZ77WindowSize {
+		d.dict = append(d.dict[:0], d.dict[len(d.dict)-wsflate.MaxLZ77WindowSize:]...)
+	}
+	return out, nil
+}
+
+// 读取解压后的消息，超过maxMessageSize返回ErrWSMessageTooLarge
+func (d *wsDeflate) readMessage(fr io.Reader) ([]byte, error) {
+	reader := fr
+	if d.maxMessageSize > 0 {
+		reader = io.LimitReader(fr, int64(d.maxMessageSize)+1)
+	}
+	out, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if d.maxMessageSize > 0 && len(out) > d.maxMessageSize {
+		return nil, ErrWSMessageTooLarge
+	}
+	return out, nil
+}
+
+// 写一个消息，达到阈值的消息压缩后写入
+func (d *wsDeflate) writeServerMessage(w io.Writer, op ws.OpCode, p []byte) error {
+	if d == nil || len(p) < d.threshold {
+		return wsutil.WriteServerMessage(w, op, p)
+	}
+	compressed, err := d.compress(p)
+	if err != nil {
+		return err
+	}
+	frame := ws.NewFrame(op, true, compressed)
+	if frame.Header, err = wsflate.SetBit(frame.Header); err != nil {
+		return err
+	}
+	return ws.WriteFrame(w, frame)
+}
+
+// 读取buff里所有完整的客户端消息，返回消息和读取的字节数，不完整的消息留到数据读完后再读
+func readClientMessages(buff []byte, d *wsDeflate) ([]wsutil.Message, int, error) {
+	var (
+		messages []wsutil.Message
+		err      error
+	)
+	reader := bytes.NewReader(buff)
+	size := 0
+	for reader.Len() > 0 {
+		n := len(messages)
+		messages, err = readClientMessage(reader, messages, d)
+		if err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF { // 数据不完整
+				messages = messages[:n]
+				break
+			}
+			return nil, 0, err
+		}
+		size = len(buff) - reader.Len()
+	}
+	return messages, size, nil
+}
+
+// 读取出错时发给客户端的关闭帧
+func wsCloseFrame(err error) ws.Frame {
+	status := ws.StatusProtocolError // 1002
+	switch {
+	case errors.Is(err, ErrWSMessageTooLarge):
+		status = ws.StatusMessageTooBig // 1009
+	case errors.Is(err, ErrWSInvalidPayload), errors.Is(err, wsutil.ErrInvalidUTF8):
+		status = ws.StatusInvalidFramePayloadData // 1007
+	}
+	return ws.NewCloseFrame(ws.NewCloseFrameBody(status, err.Error()))
+}
+
+// 读取客户端的一个消息（和wsutil.ReadClientMessage一致），压缩的消息解压后返回
+func readClientMessage(r io.Reader, m []wsutil.Message, d *wsDeflate) ([]wsutil.Message, error) {
+	if d == nil {
+		return wsutil.ReadClientMessage(r, m)
+	}
+	var state wsflate.MessageState
+	rd := wsutil.Reader{
+		Source:     r,
+		State:      ws.StateServerSide | ws.StateExtended,
+		CheckUTF8:  false, // 压缩的数据解压后再检查
+		Extensions: []wsutil.RecvExtension{&state},
+		OnIntermediate: func(hdr ws.Header, src io.Reader) error {
+			bts, err := io.ReadAll(src)
+			if err != nil {
+				return err
+			}
+			m = append(m, wsutil.Message{OpCode: hdr.OpCode, Payload: bts})
+			return nil
+		},
+	}
+	h, err := rd.NextFrame()
+	if err != nil {
+		return m, err
+	}
+	var buf bytes.Buffer
+	if _, err = buf.ReadFrom(&rd); err != nil {
+		return m, err
+	}
+	p := buf.Bytes()
+	if state.IsCompressed() {
+		if p, err = d.decompress(p); err != nil {
+			if errors.Is(err, ErrWSMessageTooLarge) {
+				return m, err
+			}
+			return m, fmt.Errorf("%w: %v", ErrWSInvalidPayload, err) // 解压失败不能当作数据不完整
+		}
+	}
+	if h.OpCode == ws.OpText && !utf8.Valid(p) {
+		return m, wsutil.ErrInvalidUTF8
+	}
+	return append(m, wsutil.Message{OpCode: h.OpCode, Payload: p}), nil
+}
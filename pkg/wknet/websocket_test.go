@@ -13,6 +13,9 @@ import (
 	"time"
 
 	stls "github.com/WuKongIM/crypto/tls"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsflate"
+	"github.com/gobwas/ws/wsutil"
 	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 )
@@ -48,6 +51,222 @@ func TestWebsocket(t *testing.T) {
 
 }
 
+func TestWebsocketSubprotocol(t *testing.T) {
+	cert, err := stls.X509KeyPair(rsaCertPEM, rsaKeyPEM)
+	assert.NoError(t, err)
+	tlsConfig := &stls.Config{
+		Certificates: []stls.Certificate{cert},
+	}
+
+	e := NewEngine(WithWSSAddr("wss://0.0.0.0:0"), WithWSTLSConfig(tlsConfig), WithWSSSubprotocols("test.json"))
+	err = e.Start()
+	assert.NoError(t, err)
+	defer e.Stop()
+
+	e.OnData(func(conn Conn) error {
+		data, err := conn.Peek(-1)
+		assert.NoError(t, err)
+		if len(data) == 0 {
+			return nil
+		}
+		_, _ = conn.Discard(len(data))
+		assert.Equal(t, `{"type":"ping"}`, string(data))
+		wsConn := conn.(IWSConn)
+		assert.Equal(t, "test.json", wsConn.Subprotocol())
+		err = wsConn.WriteServerMessage([]byte(`{"type":"pong"}`))
+		assert.NoError(t, err)
+		return conn.WakeWrite()
+	})
+
+	u := url.URL{Scheme: "wss", Host: e.WSSRealListenAddr().String(), Path: "/"}
+	dialer := websocket.Dialer{
+		Subprotocols: []string{"test.json"},
+		NetDialTLSContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return tls.Dial(network, addr, &tls.Config{InsecureSkipVerify: true, MaxVersion: tls.VersionTLS13})
+		},
+	}
+	c1, _, err := dialer.Dial(u.String(), nil)
+	assert.NoError(t, err)
+	defer c1.Close()
+	assert.Equal(t, "test.json", c1.Subprotocol())
+
+	err = c1.WriteMessage(websocket.TextMessage, []byte(`{"type":"ping"}`))
+	assert.NoError(t, err)
+
+	msgType, msg, err := c1.ReadMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, websocket.TextMessage, msgType)
+	assert.Equal(t, `{"type":"pong"}`, string(msg))
+}
+
+func TestWebsocketSubprotocolNotNegotiated(t *testing.T) {
+	e := NewEngine(WithWSAddr("ws://0.0.0.0:0"), WithWSSSubprotocols("test.json"))
+	e.Start()
+	defer e.Stop()
+
+	u := url.URL{Scheme: "ws", Host: e.WSRealListenAddr().String(), Path: "/"}
+	dialer := websocket.Dialer{Subprotocols: []string{"test.json"}}
+	c1, _, err := dialer.Dial(u.String(), nil)
+	assert.NoError(t, err)
+	defer c1.Close()
+	assert.Equal(t, "", c1.Subprotocol()) // ws监听不协商子协议
+}
+
+func TestWebsocketCompression(t *testing.T) {
+	e := NewEngine(WithWSAddr("ws://0.0.0.0:0"), WithWSCompression(&WSCompressionOptions{Threshold: 64}))
+	e.Start()
+	defer e.Stop()
+
+	large := bytes.Repeat([]byte("hello wukongim "), 100)
+	e.OnData(func(conn Conn) error {
+		data, err := conn.Peek(-1)
+		assert.NoError(t, err)
+		if len(data) < len(large) {
+			return nil
+		}
+		_, _ = conn.Discard(len(data))
+		assert.Equal(t, large, data)
+		err = conn.(IWSConn).WriteServerBinary(data) // 回写
+		assert.NoError(t, err)
+		return conn.WakeWrite()
+	})
+
+	u := url.URL{Scheme: "ws", Host: e.WSRealListenAddr().String(), Path: "/"}
+	dialer := websocket.Dialer{EnableCompression: true}
+	c1, resp, err := dialer.Dial(u.String(), nil)
+	assert.NoError(t, err)
+	defer c1.Close()
+	assert.Contains(t, resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate")
+
+	c1.EnableWriteCompression(true)
+	err = c1.WriteMessage(websocket.BinaryMessage, large)
+	assert.NoError(t, err)
+
+	_, msg, err := c1.ReadMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, large, msg)
+}
+
+func TestWSDeflateContextTakeover(t *testing.T) {
+	n := newWSDeflateNegotiator(&WSCompressionOptions{})
+	_, err := n.Negotiate(wsflate.Parameters{}.Option())
+	assert.NoError(t, err)
+	server := n.deflate(0)
+	client := n.deflate(0)
+
+	msg := bytes.Repeat([]byte("hello wukongim "), 10)
+	var firstSize int
+	for i := 0; i < 3; i++ {
+		compressed, err := server.compress(msg)
+		assert.NoError(t, err)
+		if i == 0 {
+			firstSize = len(compressed)
+		} else { // 使用上下文后重复的消息压缩后更小
+			assert.Less(t, len(compressed), firstSize)
+		}
+
+		data, err := client.decompress(compressed)
+		assert.NoError(t, err)
+		assert.Equal(t, msg, data)
+	}
+}
+
+func TestWSDeflateNoContextTakeover(t *testing.T) {
+	n := newWSDeflateNegotiator(&WSCompressionOptions{ServerNoContextTakeover: true, ClientNoContextTakeover: true})
+	_, err := n.Negotiate(wsflate.Parameters{}.Option())
+	assert.NoError(t, err)
+	server := n.deflate(0)
+	client := n.deflate(0)
+
+	msg := bytes.Repeat([]byte("hello wukongim "), 10)
+	var firstSize int
+	for i := 0; i < 3; i++ {
+		compressed, err := server.compress(msg)
+		assert.NoError(t, err)
+		if i == 0 {
+			firstSize = len(compressed)
+		} else { // 每个消息单独压缩，压缩结果一样
+			assert.Equal(t, firstSize, len(compressed))
+		}
+
+		data, err := client.decompress(compressed)
+		assert.NoError(t, err)
+		assert.Equal(t, msg, data)
+	}
+	// 没有上下文接管时连接不保留压缩器和解压器
+	assert.Nil(t, server.fw)
+	assert.Nil(t, client.fr)
+	assert.Empty(t, client.dict)
+}
+
+func TestReadClientMessages(t *testing.T) {
+	clientFrame := func(f ws.Frame) []byte {
+		buf := bytes.NewBuffer(nil)
+		assert.NoError(t, ws.WriteFrame(buf, ws.MaskFrameInPlace(f)))
+		return buf.Bytes()
+	}
+	frame1 := clientFrame(ws.NewTextFrame([]byte("hello")))
+	frame2 := clientFrame(ws.NewBinaryFrame([]byte("world")))
+
+	// 不完整的消息留到下次读取
+	buff := append(append([]byte{}, frame1...), frame2[:len(frame2)-2]...)
+	messages, size, err := readClientMessages(buff, nil)
+	assert.NoError(t, err)
+	assert.Len(t, messages, 1)
+	assert.Equal(t, len(frame1), size)
+
+	buff = append(append([]byte{}, frame1...), frame2...)
+	messages, size, err = readClientMessages(buff, nil)
+	assert.NoError(t, err)
+	assert.Len(t, messages, 2)
+	assert.Equal(t, len(buff), size)
+	assert.Equal(t, "world", string(messages[1].Payload))
+
+	// 分片的消息
+	buff = append(clientFrame(ws.NewFrame(ws.OpText, false, []byte("hel"))), clientFrame(ws.NewFrame(ws.OpContinuation, true, []byte("lo")))...)
+	messages, _, err = readClientMessages(buff[:len(buff)-1], nil)
+	assert.NoError(t, err)
+	assert.Len(t, messages, 0)
+	messages, size, err = readClientMessages(buff, nil)
+	assert.NoError(t, err)
+	assert.Len(t, messages, 1)
+	assert.Equal(t, "hello", string(messages[0].Payload))
+	assert.Equal(t, len(buff), size)
+
+	// 错误的消息返回错误
+	_, _, err = readClientMessages(clientFrame(ws.NewTextFrame([]byte{0xff, 0xfe})), nil)
+	assert.ErrorIs(t, err, wsutil.ErrInvalidUTF8)
+}
+
+func TestWSCloseFrame(t *testing.T) {
+	closeStatus := func(err error) ws.StatusCode {
+		status, _ := ws.ParseCloseFrameData(wsCloseFrame(err).Payload)
+		return status
+	}
+	assert.Equal(t, ws.StatusMessageTooBig, closeStatus(ErrWSMessageTooLarge))
+	assert.Equal(t, ws.StatusInvalidFramePayloadData, closeStatus(wsutil.ErrInvalidUTF8))
+	assert.Equal(t, ws.StatusInvalidFramePayloadData, closeStatus(ErrWSInvalidPayload))
+	assert.Equal(t, ws.StatusProtocolError, closeStatus(ws.ErrProtocolMaskRequired))
+}
+
+func TestWebsocketInvalidPayload(t *testing.T) {
+	e := NewEngine(WithWSAddr("ws://0.0.0.0:0"))
+	e.Start()
+	defer e.Stop()
+
+	u := url.URL{Scheme: "ws", Host: e.WSRealListenAddr().String(), Path: "/"}
+	c1, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	assert.NoError(t, err)
+	defer c1.Close()
+
+	err = c1.WriteMessage(websocket.TextMessage, []byte{0xff, 0xfe}) // 不是utf8的文本消息
+	assert.NoError(t, err)
+
+	_ = c1.SetReadDeadline(time.Now().Add(time.Second * 5))
+	_, _, err = c1.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseInvalidFramePayloadData), "err: %v", err)
+}
+
 func TestBatchWSConn(t *testing.T) {
 	e := NewEngine(WithWSAddr("ws://0.0.0.0:0"))
 	e.Start()
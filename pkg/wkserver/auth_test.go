@@ -0,0 +1,368 @@
+package wkserver_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	rafttypes "github.com/WuKongIM/WuKongIM/pkg/raft/types"
+	"github.com/WuKongIM/WuKongIM/pkg/wkserver"
+	"github.com/WuKongIM/WuKongIM/pkg/wkserver/client"
+	"github.com/WuKongIM/WuKongIM/pkg/wkserver/proto"
+	"github.com/panjf2000/gnet/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerSecret(t *testing.T) {
+	addr := "tcp://127.0.0.1:10010"
+	s := wkserver.New(addr, wkserver.WithSecret("secret"))
+	s.Route("/test", func(c *wkserver.Context) {
+		c.Write([]byte("ok"))
+	})
+	err := s.Start()
+	assert.NoError(t, err)
+	defer s.Stop()
+
+	cli := client.New(addr, client.WithUid("uid"), client.WithSecret("secret"))
+	err = cli.Start()
+	assert.NoError(t, err)
+	defer cli.Stop()
+
+	badCli := client.New(addr, client.WithUid("bad"), client.WithSecret("wrong"))
+	err = badCli.Start()
+	assert.NoError(t, err)
+	defer badCli.Stop()
+
+	time.Sleep(time.Millisecond * 500)
+
+	resp, err := cli.Request("/test", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("ok"), resp.Body)
+
+	assert.False(t, badCli.IsAuthed())
+}
+
+// 连接的token和服务端每次下发的随机数绑定，截获的token不能重放
+func TestServerSecretChallenge(t *testing.T) {
+	addr := "tcp://127.0.0.1:10012"
+	s := wkserver.New(addr, wkserver.WithSecret("secret"))
+	err := s.Start()
+	assert.NoError(t, err)
+	defer s.Stop()
+
+	connect := func(cn net.Conn, id uint64, token string) *proto.Connack {
+		return writeTestConnect(t, cn, &proto.Connect{Id: id, Uid: "uid", Token: token})
+	}
+
+	time.Sleep(time.Millisecond * 200)
+
+	cn, err := net.Dial("tcp", "127.0.0.1:10012")
+	assert.NoError(t, err)
+	defer cn.Close()
+
+	// 没有回应随机数的token不被接受
+	ack := connect(cn, 1, proto.SecretToken("secret", "uid", nil))
+	assert.Equal(t, proto.StatusChallenge, ack.Status)
+	assert.Equal(t, proto.NonceSize, len(ack.Body))
+	token := proto.SecretToken("secret", "uid", ack.Body)
+	ack = connect(cn, 2, token)
+	assert.Equal(t, proto.StatusOK, ack.Status)
+
+	// 新连接的随机数不同，重放之前的token被拒绝
+	replay, err := net.Dial("tcp", "127.0.0.1:10012")
+	assert.NoError(t, err)
+	defer replay.Close()
+	ack = connect(replay, 1, "")
+	assert.Equal(t, proto.StatusChallenge, ack.Status)
+	ack = connect(replay, 2, token)
+	assert.Equal(t, proto.StatusUnauthorized, ack.Status)
+}
+
+// 认证后再发连接消息的连接被关闭（不能换成其他身份）
+func TestServerConnectAfterAuth(t *testing.T) {
+	ca := newTestCA(t)
+	tlsOpts := []wkserver.Option{wkserver.WithTLSConfig(&tls.Config{
+		Certificates: []tls.Certificate{ca.issue(t, "server")},
+		ClientCAs:    ca.pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})}
+	dialTLS := func(addr string) (net.Conn, error) {
+		return tls.Dial("tcp", addr, &tls.Config{
+			Certificates:       []tls.Certificate{ca.issue(t, "uid")},
+			RootCAs:            ca.pool,
+			InsecureSkipVerify: true,
+		})
+	}
+	cases := []struct {
+		name      string
+		port      int
+		opts      []wkserver.Option
+		dialFn    func(addr string) (net.Conn, error)
+		secondUid string
+	}{
+		{
+			name:      "plain",
+			port:      10013,
+			secondUid: "other",
+			dialFn: func(addr string) (net.Conn, error) {
+				return net.Dial("tcp", addr)
+			},
+		},
+		{
+			// 和证书一致的uid由gnet服务拒绝
+			name:      "tls",
+			port:      10014,
+			opts:      tlsOpts,
+			dialFn:    dialTLS,
+			secondUid: "uid",
+		},
+		{
+			// 和证书不一致的uid由tls代理拒绝
+			name:      "tlsOtherUid",
+			port:      10015,
+			opts:      tlsOpts,
+			dialFn:    dialTLS,
+			secondUid: "other",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			addr := fmt.Sprintf("127.0.0.1:%d", c.port)
+			s := wkserver.New("tcp://"+addr, c.opts...)
+			err := s.Start()
+			assert.NoError(t, err)
+			defer s.Stop()
+			time.Sleep(time.Millisecond * 200)
+
+			cn, err := c.dialFn(addr)
+			assert.NoError(t, err)
+			defer cn.Close()
+			ack := writeTestConnect(t, cn, &proto.Connect{Id: 1, Uid: "uid"})
+			assert.Equal(t, proto.StatusOK, ack.Status)
+
+			data, err := (&proto.Connect{Id: 2, Uid: c.secondUid}).Marshal()
+			assert.NoError(t, err)
+			msgData, err := proto.New().Encode(data, proto.MsgTypeConnect)
+			assert.NoError(t, err)
+			_, err = cn.Write(msgData)
+			assert.NoError(t, err)
+
+			_ = cn.SetReadDeadline(time.Now().Add(time.Second * 2))
+			_, err = cn.Read(make([]byte, 1))
+			assert.Equal(t, io.EOF, err)
+		})
+	}
+}
+
+func TestServerTLS(t *testing.T) {
+	ca := newTestCA(t)
+	serverTLS := &tls.Config{
+		Certificates: []tls.Certificate{ca.issue(t, "server")},
+		ClientCAs:    ca.pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+
+	addr := "tcp://127.0.0.1:10011"
+	s := wkserver.New(addr, wkserver.WithTLSConfig(serverTLS))
+	s.Route("/test", func(c *wkserver.Context) {
+		c.Write([]byte("ok"))
+	})
+	err := s.Start()
+	assert.NoError(t, err)
+	defer s.Stop()
+
+	newClient := func(uid, cn, serverUid string) *client.Client {
+		cli := client.New(addr, client.WithUid(uid), client.WithServerUid(serverUid), client.WithTLSConfig(&tls.Config{
+			Certificates: []tls.Certificate{ca.issue(t, cn)},
+			RootCAs:      ca.pool,
+		}))
+		assert.NoError(t, cli.Start())
+		return cli
+	}
+
+	cli := newClient("uid", "uid", "server")
+	defer cli.Stop()
+	// 证书的CommonName和uid不一致
+	otherCli := newClient("uid2", "other", "server")
+	defer otherCli.Stop()
+	// 服务端证书的CommonName和期望的不一致
+	wrongServerCli := newClient("uid3", "uid3", "server2")
+	defer wrongServerCli.Stop()
+	// 没有使用tls
+	plainCli := client.New(addr, client.WithUid("uid4"))
+	assert.NoError(t, plainCli.Start())
+	defer plainCli.Stop()
+
+	time.Sleep(time.Millisecond * 500)
+
+	resp, err := cli.Request("/test", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("ok"), resp.Body)
+
+	cli.Route("/hi", func(c *client.Context) {
+		c.Write([]byte("world"))
+	})
+	resp, err = s.Request("uid", "/hi", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("world"), resp.Body)
+
+	assert.False(t, otherCli.IsAuthed())
+	assert.False(t, wrongServerCli.IsAuthed())
+	assert.False(t, plainCli.IsAuthed())
+}
+
+// 节点之间raft同步日志的吞吐量（副本拉取日志的响应是一批日志），对比明文和开启tls时的开销
+func BenchmarkRaftSyncTransport(b *testing.B) {
+	logs := make([]rafttypes.Log, 64)
+	for i := range logs {
+		logs[i] = rafttypes.Log{Index: uint64(i + 1), Term: 1, Data: make([]byte, 256)}
+	}
+	content, err := rafttypes.Event{Type: rafttypes.SyncResp, From: 1, To: 2, Term: 1, Index: 1, Logs: logs, Reason: rafttypes.ReasonOk}.Marshal()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	ca := newTestCA(b)
+	cases := []struct {
+		name      string
+		serverOpt []wkserver.Option
+		clientOpt []client.Option
+	}{
+		{name: "plain"},
+		{
+			name: "tls",
+			serverOpt: []wkserver.Option{wkserver.WithTLSConfig(&tls.Config{
+				Certificates: []tls.Certificate{ca.issue(b, "2")},
+				ClientCAs:    ca.pool,
+				ClientAuth:   tls.RequireAndVerifyClientCert,
+			})},
+			clientOpt: []client.Option{client.WithServerUid("2"), client.WithTLSConfig(&tls.Config{
+				Certificates: []tls.Certificate{ca.issue(b, "1")},
+				RootCAs:      ca.pool,
+			})},
+		},
+	}
+	for i, c := range cases {
+		b.Run(c.name, func(b *testing.B) {
+			addr := fmt.Sprintf("tcp://127.0.0.1:%d", 10020+i)
+			// 和集群的节点服务一样在事件循环里直接处理消息
+			s := wkserver.New(addr, append([]wkserver.Option{wkserver.WithMessagePoolOn(false)}, c.serverOpt...)...)
+			var received atomic.Int64
+			done := make(chan struct{})
+			s.OnMessage(func(_ gnet.Conn, _ *proto.Message) {
+				if received.Add(1) == int64(b.N) {
+					close(done)
+				}
+			})
+			if err := s.Start(); err != nil {
+				b.Fatal(err)
+			}
+			defer s.Stop()
+
+			cli := client.New(addr, append([]client.Option{client.WithUid("1")}, c.clientOpt...)...)
+			if err := cli.Start(); err != nil {
+				b.Fatal(err)
+			}
+			defer cli.Stop()
+			for i := 0; i < 50 && !cli.IsAuthed(); i++ {
+				time.Sleep(time.Millisecond * 100)
+			}
+			if !cli.IsAuthed() {
+				b.Fatal("client is not authed")
+			}
+
+			b.SetBytes(int64(len(content)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := cli.Send(&proto.Message{MsgType: 1, Content: content}); err != nil {
+					b.Fatal(err)
+				}
+			}
+			select {
+			case <-done:
+			case <-time.After(time.Minute):
+				b.Fatalf("received %d of %d messages", received.Load(), b.N)
+			}
+		})
+	}
+}
+
+// 发送连接消息并读取连接回执
+func writeTestConnect(t *testing.T, cn net.Conn, connect *proto.Connect) *proto.Connack {
+	p := proto.New()
+	data, err := connect.Marshal()
+	assert.NoError(t, err)
+	msgData, err := p.Encode(data, proto.MsgTypeConnect)
+	assert.NoError(t, err)
+	_, err = cn.Write(msgData)
+	assert.NoError(t, err)
+
+	_ = cn.SetReadDeadline(time.Now().Add(time.Second * 2))
+	header := make([]byte, proto.MagicNumberStartLength+proto.MsgTypeLength+proto.MsgContentLength)
+	_, err = io.ReadFull(cn, header)
+	assert.NoError(t, err)
+	assert.Equal(t, proto.MsgTypeConnack, proto.MsgType(header[proto.MagicNumberStartLength]))
+	content := make([]byte, binary.BigEndian.Uint32(header[proto.MagicNumberStartLength+proto.MsgTypeLength:]))
+	_, err = io.ReadFull(cn, content)
+	assert.NoError(t, err)
+	connack := &proto.Connack{}
+	assert.NoError(t, connack.Unmarshal(content))
+	return connack
+}
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newTestCA(t testing.TB) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	tpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &testCA{cert: cert, key: key, pool: pool}
+}
+
+// 签发同时用于服务端和客户端的证书
+func (ca *testCA) issue(t testing.TB, cn string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	assert.NoError(t, err)
+	tpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, ca.cert, &key.PublicKey, ca.key)
+	assert.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
@@ -79,9 +79,13 @@ func (c *conn) startDial() {
 		c.gc = nil
 	}
 
-	c.gc, err = c.c.cli.DialContext(proto, addr, connCtx{
-		no: c.no,
-	})
+	if c.c.opts.TLSConfig != nil {
+		c.gc, err = c.dialTLS(proto, addr)
+	} else {
+		c.gc, err = c.c.cli.DialContext(proto, addr, connCtx{
+			no: c.no,
+		})
+	}
 	if err != nil {
 		// c.Foucs("conn failed", zap.Error(err))
 		c.status.Store(disconnect)
@@ -103,27 +107,49 @@ func (c *conn) startAuth() {
 }
 
 func (c *conn) sendAuth() error {
+	token := c.c.opts.Token
+	if c.c.opts.Secret != "" {
+		token = ""
+	}
+	ack, err := c.sendConnect(token)
+	if err != nil {
+		return err
+	}
+	if ack.Status == proto.StatusChallenge && c.c.opts.Secret != "" {
+		// 用共享密钥回应服务端下发的随机数
+		ack, err = c.sendConnect(proto.SecretToken(c.c.opts.Secret, c.c.opts.Uid, ack.Body))
+		if err != nil {
+			return err
+		}
+	}
+	if ack.Status != proto.StatusOK {
+		return fmt.Errorf("connect error：%d", ack.Status)
+	}
+	return nil
+}
+
+func (c *conn) sendConnect(token string) (*proto.Connack, error) {
 	conn := &proto.Connect{
 		Id:    c.c.reqIDGen.Inc(),
 		Uid:   c.c.opts.Uid,
-		Token: c.c.opts.Token,
+		Token: token,
 	}
 	data, err := conn.Marshal()
 	if err != nil {
 		c.Error("conn auth failed", zap.Error(err))
-		return err
+		return nil, err
 	}
 	msgData, err := c.c.proto.Encode(data, proto.MsgTypeConnect)
 	if err != nil {
 		c.Error("conn auth failed", zap.Error(err))
-		return err
+		return nil, err
 	}
 
 	waitC := c.c.w.Register(conn.Id)
 	err = c.gc.AsyncWrite(msgData, nil)
 	if err != nil {
 		c.Error("write failed", zap.Error(err))
-		return err
+		return nil, err
 	}
 
 	timeoutCtx, cancel := context.WithTimeout(c.c.cancelCtx, time.Second*4)
@@ -131,15 +157,11 @@ func (c *conn) sendAuth() error {
 	select {
 	case x := <-waitC:
 		if x == nil {
-			return errors.New("unknown error")
-		}
-		ack := x.(*proto.Connack)
-		if ack.Status != proto.StatusOK {
-			return fmt.Errorf("connect error：%d", ack.Status)
+			return nil, errors.New("unknown error")
 		}
-		return nil
+		return x.(*proto.Connack), nil
 	case <-timeoutCtx.Done():
-		return timeoutCtx.Err()
+		return nil, timeoutCtx.Err()
 	}
 }
 
@@ -1,10 +1,16 @@
 package client
 
+import "crypto/tls"
+
 type Options struct {
 	Addr  string
 	Uid   string
 	Token string
 
+	Secret    string      // 共享密钥，配置后用密钥和服务端下发的随机数生成连接的token（proto.SecretToken），忽略Token
+	TLSConfig *tls.Config // 配置后使用tls连接服务端
+	ServerUid string      // 服务端的uid，配置了TLSConfig时校验服务端证书的CommonName必须和此uid一致（不再校验主机名）
+
 	HeartbeatTick        int // 心跳间隔tick数
 	HeartbeatTimeoutTick int // 心跳超时tick数
 
@@ -31,3 +37,24 @@ func WithToken(token string) Option {
 		opts.Token = token
 	}
 }
+
+// WithSecret 共享密钥
+func WithSecret(secret string) Option {
+	return func(opts *Options) {
+		opts.Secret = secret
+	}
+}
+
+// WithTLSConfig 使用tls连接服务端
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(opts *Options) {
+		opts.TLSConfig = tlsConfig
+	}
+}
+
+// WithServerUid 服务端的uid（校验服务端证书）
+func WithServerUid(serverUid string) Option {
+	return func(opts *Options) {
+		opts.ServerUid = serverUid
+	}
+}
@@ -0,0 +1,36 @@
+//go:build !windows
+
+package client
+
+import (
+	"net"
+	"os"
+	"syscall"
+)
+
+// 创建一对相互连接的unix socket
+func socketPair() (net.Conn, net.Conn, error) {
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	syscall.CloseOnExec(fds[0])
+	syscall.CloseOnExec(fds[1])
+	conns := make([]net.Conn, 0, 2)
+	for i, fd := range fds {
+		f := os.NewFile(uintptr(fd), "socketpair")
+		cn, err := net.FileConn(f) // FileConn会复制fd
+		_ = f.Close()
+		if err != nil {
+			for _, c := range conns {
+				_ = c.Close()
+			}
+			if i == 0 {
+				_ = syscall.Close(fds[1])
+			}
+			return nil, nil, err
+		}
+		conns = append(conns, cn)
+	}
+	return conns[0], conns[1], nil
+}
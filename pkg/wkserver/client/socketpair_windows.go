@@ -0,0 +1,34 @@
+//go:build windows
+
+package client
+
+import (
+	"net"
+	"time"
+)
+
+// windows没有socketpair，用回环地址上的一对tcp连接代替（gnet在windows上也是用标准库的连接收发数据）
+func socketPair() (net.Conn, net.Conn, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer ln.Close()
+	dialed, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		return nil, nil, err
+	}
+	_ = ln.(*net.TCPListener).SetDeadline(time.Now().Add(tlsDialTimeout))
+	for {
+		accepted, err := ln.Accept()
+		if err != nil {
+			_ = dialed.Close()
+			return nil, nil, err
+		}
+		// 其他本地进程也可能连上这个端口，只接受自己发起的连接
+		if accepted.RemoteAddr().String() == dialed.LocalAddr().String() {
+			return dialed, accepted, nil
+		}
+		_ = accepted.Close()
+	}
+}
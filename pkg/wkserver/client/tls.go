@@ -0,0 +1,106 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/panjf2000/gnet/v2"
+	"go.uber.org/zap"
+)
+
+const tlsDialTimeout = 10 * time.Second // tls连接和握手的超时时间
+
+// gnet不支持tls，先用标准库完成tls握手，再通过socketpair把明文的一端交给gnet，另一端和tls连接之间双向转发。
+// 所有数据都要在用户态加解密并多拷贝一次，每个连接多两个转发协程（代价见wkserver的tlsProxy），
+// windows没有socketpair，用回环地址上的一对tcp连接代替
+func (c *conn) dialTLS(network, addr string) (gnet.Conn, error) {
+	rawConn, err := net.DialTimeout(network, addr, tlsDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	tlsConn := tls.Client(rawConn, c.tlsConfig(addr))
+	_ = tlsConn.SetDeadline(time.Now().Add(tlsDialTimeout))
+	if err = tlsConn.Handshake(); err != nil {
+		_ = tlsConn.Close()
+		return nil, err
+	}
+	_ = tlsConn.SetDeadline(time.Time{})
+
+	localConn, peerConn, err := socketPair()
+	if err != nil {
+		_ = tlsConn.Close()
+		return nil, err
+	}
+	gc, err := c.c.cli.EnrollContext(localConn, connCtx{ // EnrollContext会复制fd并关闭localConn
+		no: c.no,
+	})
+	if err != nil {
+		_ = tlsConn.Close()
+		_ = peerConn.Close()
+		return nil, err
+	}
+	go func() {
+		pipe(tlsConn, peerConn)
+		c.Debug("tls conn closed", zap.String("addr", addr))
+	}()
+	return gc, nil
+}
+
+// 配置了ServerUid时，不校验主机名，改为校验证书链和证书的CommonName
+func (c *conn) tlsConfig(addr string) *tls.Config {
+	cfg := c.c.opts.TLSConfig.Clone()
+	serverUid := c.c.opts.ServerUid
+	if serverUid == "" {
+		if cfg.ServerName == "" {
+			host, _, err := net.SplitHostPort(addr)
+			if err == nil {
+				cfg.ServerName = host
+			}
+		}
+		return cfg
+	}
+	roots := cfg.RootCAs
+	cfg.InsecureSkipVerify = true
+	cfg.VerifyConnection = func(state tls.ConnectionState) error {
+		if len(state.PeerCertificates) == 0 {
+			return fmt.Errorf("server[%s] did not provide a certificate", serverUid)
+		}
+		intermediates := x509.NewCertPool()
+		for _, cert := range state.PeerCertificates[1:] {
+			intermediates.AddCert(cert)
+		}
+		leaf := state.PeerCertificates[0]
+		_, err := leaf.Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		})
+		if err != nil {
+			return err
+		}
+		if leaf.Subject.CommonName != serverUid {
+			return fmt.Errorf("certificate common name %q does not match server uid %q", leaf.Subject.CommonName, serverUid)
+		}
+		return nil
+	}
+	return cfg
+}
+
+// 双向转发数据，任意一端关闭后关闭两端
+func pipe(a, b net.Conn) {
+	done := make(chan struct{}, 2)
+	cp := func(dst, src net.Conn) {
+		_, _ = io.Copy(dst, src)
+		done <- struct{}{}
+	}
+	go cp(a, b)
+	go cp(b, a)
+	<-done
+	_ = a.Close()
+	_ = b.Close()
+	<-done
+}
@@ -3,7 +3,12 @@ package wkserver
 import "go.uber.org/atomic"
 
 type connContext struct {
-	uid atomic.String
+	uid    atomic.String
+	authed atomic.Bool // 是否已通过连接认证
+	nonce  []byte      // 下发给客户端还未使用的认证随机数（只在连接的事件循环里读写）
+
+	tlsPeer    bool   // 是否收到了tls代理发来的对端身份（只在连接的事件循环里读写）
+	tlsPeerUid string // tls代理校验过的对端证书CommonName，不为空时作为连接的uid
 }
 
 func newConnContext(uid string) *connContext {
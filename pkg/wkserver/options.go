@@ -1,6 +1,7 @@
 package wkserver
 
 import (
+	"crypto/tls"
 	"time"
 
 	"github.com/WuKongIM/WuKongIM/pkg/wkserver/proto"
@@ -24,6 +25,8 @@ type Options struct {
 	OnResponse      func(conn gnet.Conn, resp *proto.Response)
 	LogDetailOn     bool // 是否开启详细日志
 
+	Secret    string      // 共享密钥，配置后连接时先下发随机数，客户端的token必须由此密钥和随机数生成（proto.SecretToken）
+	TLSConfig *tls.Config // 配置后Addr只接受tls连接，要求客户端证书时，证书的CommonName必须和连接的uid一致
 }
 
 func NewOptions() *Options {
@@ -126,3 +129,17 @@ func WithLogDetailOn(on bool) Option {
 		opts.LogDetailOn = on
 	}
 }
+
+// WithSecret 共享密钥，连接时用随机数挑战客户端并校验token
+func WithSecret(secret string) Option {
+	return func(opts *Options) {
+		opts.Secret = secret
+	}
+}
+
+// WithTLSConfig 开启tls（数据经过用户态的tls代理转发，吞吐量会明显下降，见tlsProxy）
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(opts *Options) {
+		opts.TLSConfig = tlsConfig
+	}
+}
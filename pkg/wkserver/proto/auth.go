@@ -0,0 +1,34 @@
+package proto
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// NonceSize 连接认证随机数的长度
+const NonceSize = 16
+
+// NewNonce 生成连接认证的随机数，服务端通过Connack下发给客户端（Status为StatusChallenge）
+func NewNonce() ([]byte, error) {
+	nonce := make([]byte, NonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return nonce, nil
+}
+
+// SecretToken 根据共享密钥生成连接的token（hmac-sha256(secret, uid||nonce)），
+// 密钥本身不在网络上传输，token和uid以及服务端本次下发的随机数绑定，截获的token不能在别的连接上重放
+func SecretToken(secret, uid string, nonce []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(uid))
+	mac.Write(nonce)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySecretToken 校验连接的token是否由共享密钥和随机数生成（常量时间比较）
+func VerifySecretToken(secret, uid string, nonce []byte, token string) bool {
+	return hmac.Equal([]byte(SecretToken(secret, uid, nonce)), []byte(token))
+}
@@ -35,6 +35,7 @@ const (
 	MsgTypeResp              // response
 	MsgTypeHeartbeat         // heartbeat
 	MsgTypeMessage           // message
+	MsgTypeTLSPeer           // tls代理校验过的对端证书CommonName（只由服务端的tls代理发给内部服务，客户端不能发送）
 )
 
 const (
@@ -66,6 +67,8 @@ func (m MsgType) String() string {
 		return "MsgTypeHeartbeat"
 	case MsgTypeMessage:
 		return "MsgTypeMessage"
+	case MsgTypeTLSPeer:
+		return "MsgTypeTLSPeer"
 	default:
 		return fmt.Sprintf("Unknown MsgType %d", m)
 	}
@@ -27,9 +27,11 @@ const (
 type Status uint8
 
 const (
-	StatusOK       Status = 0 // 成功
-	StatusError    Status = 1 // 错误
-	StatusNotFound Status = 2 // 未找到
+	StatusOK           Status = 0 // 成功
+	StatusError        Status = 1 // 错误
+	StatusNotFound     Status = 2 // 未找到
+	StatusUnauthorized Status = 3 // 未认证
+	StatusChallenge    Status = 4 // 需要回应认证挑战（Connack的Body为随机数）
 )
 
 type Request struct {
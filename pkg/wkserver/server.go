@@ -38,7 +38,8 @@ type Server struct {
 
 	requestObjPool *sync.Pool
 	stopper        *syncutil.Stopper
-	batchRead      int // 连接进来数据后，每次数据读取批数，超过此次数后下次再读
+	batchRead      int       // 连接进来数据后，每次数据读取批数，超过此次数后下次再读
+	tlsProxy       *tlsProxy // 开启tls后负责tls握手和转发
 }
 
 func New(addr string, ops ...Option) *Server {
@@ -104,8 +105,21 @@ func (s *Server) Start() error {
 		s.metrics.printMetrics(fmt.Sprintf("Server:%s", s.opts.Addr))
 	})
 
+	addr := s.opts.Addr
+	if s.opts.TLSConfig != nil {
+		tlsProxy, err := newTLSProxy(s)
+		if err != nil {
+			return err
+		}
+		if err = tlsProxy.start(); err != nil {
+			return err
+		}
+		s.tlsProxy = tlsProxy
+		addr = tlsProxy.innerAddr()
+	}
+
 	go func() {
-		err := gnet.Run(s, s.opts.Addr, gnet.WithTicker(true), gnet.WithReuseAddr(true))
+		err := gnet.Run(s, addr, gnet.WithTicker(true), gnet.WithReuseAddr(true))
 		if err != nil {
 			s.Panic("gnet run error", zap.Error(err))
 		}
@@ -117,6 +131,9 @@ func (s *Server) Start() error {
 func (s *Server) Stop() {
 	s.stopper.Stop()
 	s.timingWheel.Stop()
+	if s.tlsProxy != nil {
+		s.tlsProxy.stop()
+	}
 	timeCtx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 	defer cancel()
 	err := s.engine.Stop(timeCtx)
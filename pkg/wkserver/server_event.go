@@ -62,8 +62,17 @@ func (s *Server) handleMsg(conn gnet.Conn, msgType proto.MsgType, data []byte) {
 	s.metrics.recvMsgBytesAdd(uint64(len(data)))
 	s.metrics.recvMsgCountAdd(1)
 
+	// 需要认证的服务，连接认证通过前只处理连接消息
+	if msgType != proto.MsgTypeConnect && msgType != proto.MsgTypeTLSPeer && s.authRequired() && !connAuthed(conn) {
+		s.Warn("conn is not authed, close conn", zap.String("msgType", msgType.String()), zap.String("addr", conn.RemoteAddr().String()))
+		_ = conn.Close()
+		return
+	}
+
 	if msgType == proto.MsgTypeHeartbeat {
 		s.handleHeartbeat(conn)
+	} else if msgType == proto.MsgTypeTLSPeer {
+		s.handleTLSPeer(conn, data)
 	} else if msgType == proto.MsgTypeConnect {
 		req := &proto.Connect{}
 		err := req.Unmarshal(data)
@@ -151,10 +160,56 @@ func (s *Server) handleHeartbeat(conn gnet.Conn) {
 	}
 }
 
+// tls代理在转发连接消息前发来校验过的对端身份，只能是连接的第一个消息
+func (s *Server) handleTLSPeer(conn gnet.Conn, data []byte) {
+	if s.opts.TLSConfig == nil || conn.Context() != nil {
+		s.Warn("unexpected tls peer, close conn", zap.String("addr", conn.RemoteAddr().String()))
+		_ = conn.Close()
+		return
+	}
+	connCtx := newConnContext("")
+	connCtx.tlsPeer = true
+	connCtx.tlsPeerUid = string(data)
+	conn.SetContext(connCtx)
+}
+
 func (s *Server) handleConnack(conn gnet.Conn, req *proto.Connect) {
 
 	s.Info("收到连接。。。", zap.String("from", req.Uid))
-	conn.SetContext(newConnContext(req.Uid))
+	// 认证后不能再发连接消息换成其他身份
+	if connAuthed(conn) {
+		s.Warn("conn is already authed, close conn", zap.String("from", req.Uid), zap.String("uid", GetUidFromContext(conn)))
+		_ = conn.Close()
+		return
+	}
+	if s.opts.TLSConfig != nil {
+		// 连接都来自tls代理，代理校验过证书才会转发连接消息
+		connCtx, _ := conn.Context().(*connContext)
+		if connCtx == nil || !connCtx.tlsPeer || (connCtx.tlsPeerUid != "" && connCtx.tlsPeerUid != req.Uid) {
+			s.Warn("connect is not from tls proxy or uid does not match the certificate, close conn", zap.String("from", req.Uid))
+			s.rejectConn(conn, req.Id)
+			return
+		}
+	}
+	if s.opts.Secret != "" {
+		// 挑战应答：先下发随机数，客户端用hmac(secret, uid||nonce)作为token再次连接
+		connCtx, _ := conn.Context().(*connContext)
+		if connCtx == nil || connCtx.nonce == nil || connCtx.uid.Load() != req.Uid {
+			s.challengeConn(conn, req)
+			return
+		}
+		nonce := connCtx.nonce
+		connCtx.nonce = nil // 随机数只能用一次
+		if !proto.VerifySecretToken(s.opts.Secret, req.Uid, nonce, req.Token) {
+			s.Warn("connect token is invalid, close conn", zap.String("from", req.Uid), zap.String("addr", conn.RemoteAddr().String()))
+			s.rejectConn(conn, req.Id)
+			return
+		}
+	}
+	connCtx := s.getOrSetConnContext(conn)
+	connCtx.uid.Store(req.Uid)
+	connCtx.nonce = nil
+	connCtx.authed.Store(true)
 	s.connManager.AddConn(req.Uid, conn)
 
 	s.routeMapLock.RLock()
@@ -170,6 +225,78 @@ func (s *Server) handleConnack(conn gnet.Conn, req *proto.Connect) {
 	h(ctx)
 }
 
+// 下发认证随机数
+func (s *Server) challengeConn(conn gnet.Conn, req *proto.Connect) {
+	nonce, err := proto.NewNonce()
+	if err != nil {
+		s.Error("new nonce error", zap.Error(err))
+		_ = conn.Close()
+		return
+	}
+	connCtx := s.getOrSetConnContext(conn)
+	connCtx.uid.Store(req.Uid)
+	connCtx.nonce = nonce
+	s.writeConnack(conn, &proto.Connack{
+		Id:     req.Id,
+		Status: proto.StatusChallenge,
+		Body:   nonce,
+	}, false)
+}
+
+// 连接的上下文，没有时创建（tls代理发来的对端身份要保留）
+func (s *Server) getOrSetConnContext(conn gnet.Conn) *connContext {
+	connCtx, _ := conn.Context().(*connContext)
+	if connCtx == nil {
+		connCtx = newConnContext("")
+		conn.SetContext(connCtx)
+	}
+	return connCtx
+}
+
+// 回复未认证并关闭连接
+func (s *Server) rejectConn(conn gnet.Conn, id uint64) {
+	s.writeConnack(conn, &proto.Connack{
+		Id:     id,
+		Status: proto.StatusUnauthorized,
+	}, true)
+}
+
+// 回复连接消息，close为true时写完后关闭连接
+func (s *Server) writeConnack(conn gnet.Conn, connack *proto.Connack, close bool) {
+	data, err := connack.Marshal()
+	if err != nil {
+		s.Error("marshal connack error", zap.Error(err))
+		_ = conn.Close()
+		return
+	}
+	msgData, err := s.proto.Encode(data, proto.MsgTypeConnack)
+	if err != nil {
+		s.Error("encode connack error", zap.Error(err))
+		_ = conn.Close()
+		return
+	}
+	var callback gnet.AsyncCallback
+	if close {
+		callback = func(c gnet.Conn, _ error) error {
+			return c.Close()
+		}
+	}
+	err = conn.AsyncWrite(msgData, callback)
+	if err != nil {
+		_ = conn.Close()
+	}
+}
+
+// 是否需要连接认证
+func (s *Server) authRequired() bool {
+	return s.opts.Secret != "" || s.opts.TLSConfig != nil
+}
+
+func connAuthed(conn gnet.Conn) bool {
+	ctx, ok := conn.Context().(*connContext)
+	return ok && ctx.authed.Load()
+}
+
 func (s *Server) handleResp(_ gnet.Conn, resp *proto.Response) {
 	if s.w.IsRegistered(resp.Id) {
 		s.w.Trigger(resp.Id, resp)
@@ -309,6 +436,9 @@ func (s *Server) OnClose(conn gnet.Conn, err error) (action gnet.Action) {
 		return
 	}
 	connCtx := ctx.(*connContext)
+	if !connCtx.authed.Load() { // 没认证的连接没有加入连接管理
+		return
+	}
 
 	s.connManager.RemoveConn(connCtx.uid.Load())
 	s.routeMapLock.RLock()
@@ -0,0 +1,305 @@
+package wkserver
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/WuKongIM/WuKongIM/pkg/wkserver/proto"
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+)
+
+const (
+	tlsHandshakeTimeout = 10 * time.Second // tls握手和读取连接消息的超时时间
+	maxConnectSize      = 64 * 1024        // 连接消息的最大大小
+)
+
+// tlsProxy gnet不支持tls，开启tls后由tlsProxy监听Addr，完成tls握手和客户端身份校验后，
+// 把解密后的数据转发给只监听本地unix socket的gnet服务（socket所在目录只有当前用户可以访问）。
+//
+// 这样做的代价（开启前需要评估）：
+//   - 每个连接的所有数据都要在用户态加解密，再经过unix socket多拷贝一次，每个连接多两个转发协程，
+//     客户端（client/tls.go）也一样。单核机器上用BenchmarkRaftSyncTransport测量节点之间同步raft日志的吞吐量，
+//     明文约220-290MB/s，开启tls后约80-120MB/s，吞吐量下降一半以上，延迟增加一倍以上
+//   - gnet看到的是unix socket的连接，conn.RemoteAddr()不再是对端的真实地址，
+//     需要对端地址时看tlsProxy的日志（握手和身份校验失败时会记录真实地址）
+//   - 证书的CommonName必须和连接消息的uid一致，之后转发时只解析消息头，只有连接消息（共享密钥的挑战应答会再发一次）会解码校验uid，
+//     uid和证书不一致的连接直接关闭（不能换成其他身份）。校验过的CommonName在连接消息之前用MsgTypeTLSPeer发给gnet服务，
+//     gnet服务以它作为连接的uid，认证后再收到连接消息时关闭连接
+type tlsProxy struct {
+	s        *Server
+	ln       net.Listener
+	dir      string
+	sockPath string
+
+	connsLock sync.Mutex
+	conns     map[net.Conn]struct{}
+	stopped   atomic.Bool
+	wg        sync.WaitGroup
+	wklog.Log
+}
+
+func newTLSProxy(s *Server) (*tlsProxy, error) {
+	dir, err := os.MkdirTemp("", "wkserver")
+	if err != nil {
+		return nil, err
+	}
+	return &tlsProxy{
+		s:        s,
+		dir:      dir,
+		sockPath: filepath.Join(dir, "server.sock"),
+		conns:    make(map[net.Conn]struct{}),
+		Log:      wklog.NewWKLog(fmt.Sprintf("tlsProxy[%s]", s.opts.Addr)),
+	}, nil
+}
+
+// gnet服务实际监听的地址
+func (p *tlsProxy) innerAddr() string {
+	return "unix://" + p.sockPath
+}
+
+func (p *tlsProxy) start() error {
+	network, addr, err := ParseProtoAddr(p.s.opts.Addr)
+	if err != nil {
+		return err
+	}
+	p.ln, err = net.Listen(network, addr)
+	if err != nil {
+		return err
+	}
+	p.wg.Add(1)
+	go p.loopAccept()
+	return nil
+}
+
+func (p *tlsProxy) stop() {
+	p.stopped.Store(true)
+	if p.ln != nil {
+		_ = p.ln.Close()
+	}
+	p.connsLock.Lock()
+	for cn := range p.conns {
+		_ = cn.Close()
+	}
+	p.connsLock.Unlock()
+	p.wg.Wait()
+	_ = os.RemoveAll(p.dir)
+}
+
+func (p *tlsProxy) loopAccept() {
+	defer p.wg.Done()
+	for {
+		rawConn, err := p.ln.Accept()
+		if err != nil {
+			if p.stopped.Load() {
+				return
+			}
+			p.Warn("accept error", zap.Error(err))
+			time.Sleep(time.Millisecond * 100)
+			continue
+		}
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			p.handleConn(rawConn)
+		}()
+	}
+}
+
+func (p *tlsProxy) handleConn(rawConn net.Conn) {
+	tlsConn := tls.Server(rawConn, p.s.opts.TLSConfig)
+	if !p.addConn(tlsConn) {
+		_ = tlsConn.Close()
+		return
+	}
+	defer p.removeConn(tlsConn)
+	defer tlsConn.Close()
+
+	_ = tlsConn.SetDeadline(time.Now().Add(tlsHandshakeTimeout))
+	if err := tlsConn.Handshake(); err != nil {
+		p.Warn("tls handshake failed", zap.Error(err), zap.String("addr", rawConn.RemoteAddr().String()))
+		return
+	}
+	connectData, connect, err := readConnect(tlsConn)
+	if err != nil {
+		p.Warn("read connect failed", zap.Error(err), zap.String("addr", rawConn.RemoteAddr().String()))
+		return
+	}
+	if err = verifyPeerUid(tlsConn.ConnectionState(), connect.Uid); err != nil {
+		p.Warn("verify peer failed", zap.Error(err), zap.String("uid", connect.Uid), zap.String("addr", rawConn.RemoteAddr().String()))
+		return
+	}
+	_ = tlsConn.SetDeadline(time.Time{})
+
+	innerConn, err := net.DialTimeout("unix", p.sockPath, tlsHandshakeTimeout)
+	if err != nil {
+		p.Warn("dial inner addr failed", zap.Error(err))
+		return
+	}
+	if !p.addConn(innerConn) {
+		_ = innerConn.Close()
+		return
+	}
+	defer p.removeConn(innerConn)
+	defer innerConn.Close()
+
+	peerData, err := p.s.proto.Encode([]byte(peerCommonName(tlsConn.ConnectionState())), proto.MsgTypeTLSPeer)
+	if err != nil {
+		p.Warn("encode tls peer failed", zap.Error(err))
+		return
+	}
+	if _, err = innerConn.Write(append(peerData, connectData...)); err != nil {
+		p.Warn("write connect to inner conn failed", zap.Error(err))
+		return
+	}
+	p.forward(tlsConn, innerConn, rawConn.RemoteAddr().String())
+}
+
+// 双向转发数据，任意一端关闭后关闭两端。客户端发来的数据按消息转发，认证后不允许再发连接消息
+func (p *tlsProxy) forward(tlsConn, innerConn net.Conn, addr string) {
+	done := make(chan struct{}, 2)
+	go func() {
+		if err := forwardClientMsgs(innerConn, tlsConn, tlsConn.(*tls.Conn).ConnectionState()); err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+			p.Warn("forward client data failed, close conn", zap.Error(err), zap.String("addr", addr))
+		}
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(tlsConn, innerConn)
+		done <- struct{}{}
+	}()
+	<-done
+	_ = tlsConn.Close()
+	_ = innerConn.Close()
+	<-done
+}
+
+func (p *tlsProxy) addConn(cn net.Conn) bool {
+	p.connsLock.Lock()
+	defer p.connsLock.Unlock()
+	if p.stopped.Load() {
+		return false
+	}
+	p.conns[cn] = struct{}{}
+	return true
+}
+
+func (p *tlsProxy) removeConn(cn net.Conn) {
+	p.connsLock.Lock()
+	delete(p.conns, cn)
+	p.connsLock.Unlock()
+}
+
+// 对端证书的CommonName，没有客户端证书时为空
+func peerCommonName(state tls.ConnectionState) string {
+	if len(state.PeerCertificates) == 0 {
+		return ""
+	}
+	return state.PeerCertificates[0].Subject.CommonName
+}
+
+// 校验客户端证书的CommonName和连接的uid一致（没有要求客户端证书时不校验）
+func verifyPeerUid(state tls.ConnectionState, uid string) error {
+	if len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	cn := peerCommonName(state)
+	if cn != uid {
+		return fmt.Errorf("certificate common name %q does not match uid %q", cn, uid)
+	}
+	return nil
+}
+
+// 读取连接的第一个消息，必须是连接消息，返回原始数据和解码后的连接消息
+func readConnect(r io.Reader) ([]byte, *proto.Connect, error) {
+	headerLen := proto.MagicNumberStartLength + proto.MsgTypeLength + proto.MsgContentLength
+	header := make([]byte, headerLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, nil, err
+	}
+	if !bytes.Equal(header[:proto.MagicNumberStartLength], proto.MagicNumberStart) {
+		return nil, nil, errors.New("invalid magic number start")
+	}
+	if proto.MsgType(header[proto.MagicNumberStartLength]) != proto.MsgTypeConnect {
+		return nil, nil, fmt.Errorf("first message must be connect, but got %s", proto.MsgType(header[proto.MagicNumberStartLength]))
+	}
+	contentLen := binary.BigEndian.Uint32(header[proto.MagicNumberStartLength+proto.MsgTypeLength:])
+	if contentLen > maxConnectSize {
+		return nil, nil, fmt.Errorf("connect is too large: %d", contentLen)
+	}
+	data := make([]byte, headerLen+int(contentLen))
+	copy(data, header)
+	if _, err := io.ReadFull(r, data[headerLen:]); err != nil {
+		return nil, nil, err
+	}
+	connect := &proto.Connect{}
+	if err := connect.Unmarshal(data[headerLen:]); err != nil {
+		return nil, nil, err
+	}
+	return data, connect, nil
+}
+
+// 按消息把客户端的数据转发给gnet服务，连接消息的uid和证书不一致或者客户端发来MsgTypeTLSPeer时返回错误
+func forwardClientMsgs(dst io.Writer, src io.Reader, state tls.ConnectionState) error {
+	r := bufio.NewReaderSize(src, 64*1024)
+	w := bufio.NewWriterSize(dst, 64*1024)
+	headerLen := proto.MagicNumberStartLength + proto.MsgTypeLength + proto.MsgContentLength
+	header := make([]byte, headerLen)
+	for {
+		// 没有待读的数据时先把已读的发出去，避免消息停留在缓冲里
+		if r.Buffered() == 0 && w.Buffered() > 0 {
+			if err := w.Flush(); err != nil {
+				return err
+			}
+		}
+		if _, err := io.ReadFull(r, header[:proto.MagicNumberStartLength+proto.MsgTypeLength]); err != nil {
+			return err
+		}
+		if !bytes.Equal(header[:proto.MagicNumberStartLength], proto.MagicNumberStart) {
+			return errors.New("invalid magic number start")
+		}
+		msgType := proto.MsgType(header[proto.MagicNumberStartLength])
+		switch msgType {
+		case proto.MsgTypeTLSPeer:
+			return fmt.Errorf("unexpected %s from client", msgType)
+		case proto.MsgTypeConnect:
+			data, connect, err := readConnect(io.MultiReader(bytes.NewReader(header[:proto.MagicNumberStartLength+proto.MsgTypeLength]), r))
+			if err != nil {
+				return err
+			}
+			if err = verifyPeerUid(state, connect.Uid); err != nil {
+				return err
+			}
+			if _, err = w.Write(data); err != nil {
+				return err
+			}
+			continue
+		case proto.MsgTypeHeartbeat: // 心跳只有消息头
+			if _, err := w.Write(header[:proto.MagicNumberStartLength+proto.MsgTypeLength]); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := io.ReadFull(r, header[proto.MagicNumberStartLength+proto.MsgTypeLength:]); err != nil {
+			return err
+		}
+		if _, err := w.Write(header); err != nil {
+			return err
+		}
+		contentLen := binary.BigEndian.Uint32(header[proto.MagicNumberStartLength+proto.MsgTypeLength:])
+		if _, err := io.CopyN(w, r, int64(contentLen)); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package wkutil
+
+import (
+	"crypto/x509"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/WuKongIM/crypto/tls"
+)
+
+// CertReloader 证书热加载，证书文件变化（修改时间或大小变化）后，新的握手使用新的证书
+// 只在握手时按检查间隔检查文件，不需要额外的协程
+type CertReloader struct {
+	certFile      string
+	keyFile       string
+	checkInterval time.Duration
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	certStat  fileStat
+	keyStat   fileStat
+	lastCheck time.Time
+	OnReload  func(err error) // 重新加载证书后的回调（err不为nil表示加载失败，继续使用旧证书）
+}
+
+type fileStat struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewCertReloader 加载证书，checkInterval为检查文件变化的间隔
+func NewCertReloader(certFile, keyFile string, checkInterval time.Duration) (*CertReloader, error) {
+	r := &CertReloader{
+		certFile:      certFile,
+		keyFile:       keyFile,
+		checkInterval: checkInterval,
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	r.lastCheck = time.Now()
+	return r, nil
+}
+
+// GetCertificate 用于tls.Config的GetCertificate
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.checkInterval > 0 && time.Since(r.lastCheck) >= r.checkInterval {
+		r.lastCheck = time.Now()
+		if r.changed() {
+			err := r.load()
+			if r.OnReload != nil {
+				r.OnReload(err)
+			}
+		}
+	}
+	return r.cert, nil
+}
+
+func (r *CertReloader) changed() bool {
+	certStat, err := statFile(r.certFile)
+	if err != nil {
+		return false
+	}
+	keyStat, err := statFile(r.keyFile)
+	if err != nil {
+		return false
+	}
+	return certStat != r.certStat || keyStat != r.keyStat
+}
+
+func (r *CertReloader) load() error {
+	certStat, err := statFile(r.certFile)
+	if err != nil {
+		return err
+	}
+	keyStat, err := statFile(r.keyFile)
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.cert = &cert
+	r.certStat = certStat
+	r.keyStat = keyStat
+	return nil
+}
+
+func statFile(file string) (fileStat, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return fileStat{}, err
+	}
+	return fileStat{modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+// LoadCertPool 加载PEM格式的CA证书
+func LoadCertPool(caFile string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, errors.New("no valid certificate in ca file")
+	}
+	return pool, nil
+}
+
+// ParseTLSVersion 解析tls版本 支持 1.0 1.1 1.2 1.3，为空返回0（使用默认的最低版本）
+func ParseTLSVersion(version string) (uint16, error) {
+	switch version {
+	case "":
+		return 0, nil
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, errors.New("invalid tls version: " + version)
+}